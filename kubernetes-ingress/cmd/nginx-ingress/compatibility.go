@@ -0,0 +1,268 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/nginxinc/kubernetes-ingress/internal/configs"
+	conf_v1 "github.com/nginxinc/kubernetes-ingress/pkg/apis/configuration/v1"
+	"github.com/nginxinc/kubernetes-ingress/pkg/apis/configuration/validation"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// runCompatibilityReport prints the compatibility reports for the resources from the file.
+func runCompatibilityReport(fileName string, out io.Writer) error {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	virtualServers, virtualServerRoutes, policies, err := parseCustomResources(file)
+	if err != nil {
+		return fmt.Errorf("error parsing %v: %v", fileName, err)
+	}
+
+	reports := generateCompatibilityReports(virtualServers, virtualServerRoutes, policies)
+	printCompatibilityReports(out, reports)
+
+	return nil
+}
+
+// parseCustomResources parses the VirtualServer, VirtualServerRoute and Policy resources from YAML or JSON documents.
+// Other resources are ignored.
+func parseCustomResources(r io.Reader) ([]*conf_v1.VirtualServer, []*conf_v1.VirtualServerRoute, []*conf_v1.Policy, error) {
+	var virtualServers []*conf_v1.VirtualServer
+	var virtualServerRoutes []*conf_v1.VirtualServerRoute
+	var policies []*conf_v1.Policy
+
+	decoder := yaml.NewYAMLOrJSONDecoder(r, 4096)
+
+	for {
+		var raw runtime.RawExtension
+		err := decoder.Decode(&raw)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if len(raw.Raw) == 0 {
+			continue
+		}
+
+		var typeMeta meta_v1.TypeMeta
+		if err := json.Unmarshal(raw.Raw, &typeMeta); err != nil {
+			return nil, nil, nil, err
+		}
+
+		var obj interface{}
+		switch typeMeta.Kind {
+		case "VirtualServer":
+			vs := &conf_v1.VirtualServer{}
+			virtualServers = append(virtualServers, vs)
+			obj = vs
+		case "VirtualServerRoute":
+			vsr := &conf_v1.VirtualServerRoute{}
+			virtualServerRoutes = append(virtualServerRoutes, vsr)
+			obj = vsr
+		case "Policy":
+			pol := &conf_v1.Policy{}
+			policies = append(policies, pol)
+			obj = pol
+		default:
+			continue
+		}
+
+		if err := json.Unmarshal(raw.Raw, obj); err != nil {
+			return nil, nil, nil, fmt.Errorf("error parsing %v: %v", typeMeta.Kind, err)
+		}
+	}
+
+	for _, vs := range virtualServers {
+		setDefaultNamespace(&vs.ObjectMeta)
+	}
+	for _, vsr := range virtualServerRoutes {
+		setDefaultNamespace(&vsr.ObjectMeta)
+	}
+	for _, pol := range policies {
+		setDefaultNamespace(&pol.ObjectMeta)
+	}
+
+	return virtualServers, virtualServerRoutes, policies, nil
+}
+
+func setDefaultNamespace(meta *meta_v1.ObjectMeta) {
+	if meta.Namespace == "" {
+		meta.Namespace = meta_v1.NamespaceDefault
+	}
+}
+
+func printCompatibilityReports(out io.Writer, reports []compatibilityReport) {
+	if len(reports) == 0 {
+		fmt.Fprintln(out, "The resources are handled the same way by NGINX and NGINX Plus")
+		return
+	}
+
+	for _, report := range reports {
+		fmt.Fprintf(out, "%s:\n", report.Resource)
+		for _, p := range report.NginxProblems {
+			fmt.Fprintf(out, "  NGINX: %s\n", p)
+		}
+		for _, p := range report.NginxPlusProblems {
+			fmt.Fprintf(out, "  NGINX Plus: %s\n", p)
+		}
+	}
+}
+
+// compatibilityReport lists the problems of a resource that occur only with NGINX or only with NGINX Plus.
+// A problem is either a validation error, which means the resource is rejected, or a warning of the config generation.
+type compatibilityReport struct {
+	Resource          string
+	NginxProblems     []string
+	NginxPlusProblems []string
+}
+
+// generateCompatibilityReports renders the VirtualServers both for NGINX and NGINX Plus
+// and reports the resources that are handled differently.
+// The VirtualServers are rendered without Endpoints, and the Secrets referenced by the policies are considered valid,
+// so that the report doesn't depend on the state of the cluster.
+func generateCompatibilityReports(virtualServers []*conf_v1.VirtualServer, virtualServerRoutes []*conf_v1.VirtualServerRoute,
+	policies []*conf_v1.Policy) []compatibilityReport {
+	nginxProblems := findProblems(virtualServers, virtualServerRoutes, policies, false)
+	plusProblems := findProblems(virtualServers, virtualServerRoutes, policies, true)
+
+	resources := make(map[string]bool)
+	for res := range nginxProblems {
+		resources[res] = true
+	}
+	for res := range plusProblems {
+		resources[res] = true
+	}
+
+	var reports []compatibilityReport
+
+	for res := range resources {
+		report := compatibilityReport{
+			Resource:          res,
+			NginxProblems:     subtractProblems(nginxProblems[res], plusProblems[res]),
+			NginxPlusProblems: subtractProblems(plusProblems[res], nginxProblems[res]),
+		}
+
+		if len(report.NginxProblems) > 0 || len(report.NginxPlusProblems) > 0 {
+			reports = append(reports, report)
+		}
+	}
+
+	sort.Slice(reports, func(i, j int) bool {
+		return reports[i].Resource < reports[j].Resource
+	})
+
+	return reports
+}
+
+// findProblems returns the problems of the resources keyed by the resource description.
+func findProblems(virtualServers []*conf_v1.VirtualServer, virtualServerRoutes []*conf_v1.VirtualServerRoute,
+	policies []*conf_v1.Policy, isPlus bool) map[string][]string {
+	problems := make(map[string][]string)
+
+	// OIDC policies are only supported in NGINX Plus, so they are considered enabled for NGINX Plus
+	enableOIDC := isPlus
+
+	validPolicies := make(map[string]*conf_v1.Policy)
+	for _, pol := range policies {
+		if err := validation.ValidatePolicy(pol, isPlus, enableOIDC); err != nil {
+			res := describeResource(pol)
+			problems[res] = append(problems[res], fmt.Sprintf("is invalid: %v", err))
+			continue
+		}
+
+		validPolicies[fmt.Sprintf("%s/%s", pol.Namespace, pol.Name)] = pol
+	}
+
+	vsrs := make(map[string]*conf_v1.VirtualServerRoute)
+	for _, vsr := range virtualServerRoutes {
+		vsrs[fmt.Sprintf("%s/%s", vsr.Namespace, vsr.Name)] = vsr
+	}
+
+	for _, vs := range virtualServers {
+		if err := validation.ValidateVirtualServer(vs, isPlus); err != nil {
+			res := describeResource(vs)
+			problems[res] = append(problems[res], fmt.Sprintf("is invalid: %v", err))
+			continue
+		}
+
+		virtualServerEx := configs.VirtualServerEx{
+			VirtualServer: vs,
+			Policies:      validPolicies,
+		}
+
+		for _, r := range vs.Spec.Routes {
+			if r.Route == "" {
+				continue
+			}
+
+			vsrKey := r.Route
+			if !strings.Contains(vsrKey, "/") {
+				vsrKey = fmt.Sprintf("%s/%s", vs.Namespace, vsrKey)
+			}
+
+			vsr, exists := vsrs[vsrKey]
+			if !exists {
+				continue
+			}
+
+			if err := validation.ValidateVirtualServerRouteForVirtualServer(vsr, vs.Spec.Host, r.Path, isPlus); err != nil {
+				res := describeResource(vsr)
+				problems[res] = append(problems[res], fmt.Sprintf("is invalid: %v", err))
+				continue
+			}
+
+			virtualServerEx.VirtualServerRoutes = append(virtualServerEx.VirtualServerRoutes, vsr)
+		}
+
+		warnings := configs.GenerateVirtualServerWarnings(&virtualServerEx, isPlus)
+		for obj := range warnings {
+			res := describeResource(obj)
+			problems[res] = append(problems[res], warnings.Messages(obj)...)
+		}
+	}
+
+	return problems
+}
+
+func describeResource(obj runtime.Object) string {
+	switch o := obj.(type) {
+	case *conf_v1.VirtualServer:
+		return fmt.Sprintf("VirtualServer %s/%s", o.Namespace, o.Name)
+	case *conf_v1.VirtualServerRoute:
+		return fmt.Sprintf("VirtualServerRoute %s/%s", o.Namespace, o.Name)
+	case *conf_v1.Policy:
+		return fmt.Sprintf("Policy %s/%s", o.Namespace, o.Name)
+	}
+
+	return fmt.Sprintf("%T", obj)
+}
+
+// subtractProblems returns the problems that are not present in the excluded problems.
+func subtractProblems(problems []string, excluded []string) []string {
+	excludedSet := make(map[string]bool)
+	for _, p := range excluded {
+		excludedSet[p] = true
+	}
+
+	var result []string
+	for _, p := range problems {
+		if !excludedSet[p] {
+			result = append(result, p)
+		}
+	}
+
+	return result
+}
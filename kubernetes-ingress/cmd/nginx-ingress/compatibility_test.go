@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const compatibilityTestResources = `
+apiVersion: k8s.nginx.org/v1
+kind: VirtualServer
+metadata:
+  name: cafe
+spec:
+  host: cafe.example.com
+  policies:
+  - name: jwt-policy
+  upstreams:
+  - name: tea
+    service: tea-svc
+    port: 80
+  - name: coffee
+    service: coffee-svc
+    port: 80
+    lb-method: least_time header
+  routes:
+  - path: /tea
+    action:
+      pass: tea
+---
+apiVersion: k8s.nginx.org/v1
+kind: VirtualServer
+metadata:
+  name: tea
+  namespace: tea-ns
+spec:
+  host: tea.example.com
+  policies:
+  - name: jwt-policy
+    namespace: default
+  upstreams:
+  - name: tea
+    service: tea-svc
+    port: 80
+  routes:
+  - path: /
+    action:
+      pass: tea
+---
+apiVersion: k8s.nginx.org/v1
+kind: Policy
+metadata:
+  name: jwt-policy
+spec:
+  jwt:
+    realm: MyAPI
+    secret: jwk-secret
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: tea-svc
+`
+
+func TestParseCustomResources(t *testing.T) {
+	virtualServers, virtualServerRoutes, policies, err := parseCustomResources(strings.NewReader(compatibilityTestResources))
+	if err != nil {
+		t.Fatalf("parseCustomResources() returned unexpected error: %v", err)
+	}
+
+	if len(virtualServers) != 2 || len(virtualServerRoutes) != 0 || len(policies) != 1 {
+		t.Fatalf("parseCustomResources() returned %d VirtualServers, %d VirtualServerRoutes and %d Policies but expected 2, 0 and 1",
+			len(virtualServers), len(virtualServerRoutes), len(policies))
+	}
+
+	if virtualServers[0].Namespace != "default" {
+		t.Errorf("parseCustomResources() returned VirtualServer with namespace %q but expected the default namespace", virtualServers[0].Namespace)
+	}
+	if virtualServers[1].Namespace != "tea-ns" {
+		t.Errorf("parseCustomResources() returned VirtualServer with namespace %q but expected %q", virtualServers[1].Namespace, "tea-ns")
+	}
+	if policies[0].Spec.JWTAuth == nil || policies[0].Spec.JWTAuth.Secret != "jwk-secret" {
+		t.Errorf("parseCustomResources() returned Policy with spec %+v", policies[0].Spec)
+	}
+}
+
+func TestParseCustomResourcesFails(t *testing.T) {
+	input := `
+apiVersion: k8s.nginx.org/v1
+kind: VirtualServer
+spec:
+  host: [cafe.example.com]
+`
+
+	_, _, _, err := parseCustomResources(strings.NewReader(input))
+	if err == nil {
+		t.Errorf("parseCustomResources() returned no error for invalid input")
+	}
+}
+
+func TestGenerateCompatibilityReports(t *testing.T) {
+	virtualServers, virtualServerRoutes, policies, err := parseCustomResources(strings.NewReader(compatibilityTestResources))
+	if err != nil {
+		t.Fatalf("parseCustomResources() returned unexpected error: %v", err)
+	}
+
+	reports := generateCompatibilityReports(virtualServers, virtualServerRoutes, policies)
+
+	var resources []string
+	for _, r := range reports {
+		resources = append(resources, r.Resource)
+
+		if len(r.NginxProblems) == 0 {
+			t.Errorf("generateCompatibilityReports() returned no NGINX problems for %s", r.Resource)
+		}
+		if len(r.NginxPlusProblems) != 0 {
+			t.Errorf("generateCompatibilityReports() returned unexpected NGINX Plus problems %v for %s", r.NginxPlusProblems, r.Resource)
+		}
+	}
+
+	expectedResources := []string{
+		"Policy default/jwt-policy",
+		"VirtualServer default/cafe",
+		"VirtualServer tea-ns/tea",
+	}
+	if !reflect.DeepEqual(resources, expectedResources) {
+		t.Errorf("generateCompatibilityReports() returned reports for %v but expected %v", resources, expectedResources)
+	}
+}
+
+func TestPrintCompatibilityReports(t *testing.T) {
+	reports := []compatibilityReport{
+		{
+			Resource:          "VirtualServer default/cafe",
+			NginxProblems:     []string{"is invalid"},
+			NginxPlusProblems: []string{"has a warning"},
+		},
+	}
+
+	expected := `VirtualServer default/cafe:
+  NGINX: is invalid
+  NGINX Plus: has a warning
+`
+
+	var out bytes.Buffer
+	printCompatibilityReports(&out, reports)
+
+	if out.String() != expected {
+		t.Errorf("printCompatibilityReports() printed\n%s\nbut expected\n%s", out.String(), expected)
+	}
+}
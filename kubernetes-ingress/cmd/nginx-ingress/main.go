@@ -21,6 +21,8 @@ import (
 	"github.com/nginxinc/kubernetes-ingress/internal/metrics"
 	"github.com/nginxinc/kubernetes-ingress/internal/metrics/collectors"
 	"github.com/nginxinc/kubernetes-ingress/internal/nginx"
+	"github.com/nginxinc/kubernetes-ingress/internal/webhook"
+	cr_validation "github.com/nginxinc/kubernetes-ingress/pkg/apis/configuration/validation"
 	k8s_nginx "github.com/nginxinc/kubernetes-ingress/pkg/client/clientset/versioned"
 	conf_scheme "github.com/nginxinc/kubernetes-ingress/pkg/client/clientset/versioned/scheme"
 	"github.com/nginxinc/nginx-plus-go-client/client"
@@ -28,6 +30,7 @@ import (
 	api_v1 "k8s.io/api/core/v1"
 	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
@@ -86,18 +89,20 @@ var (
 	(default for NGINX "nginx.ingress.tmpl"; default for NGINX Plus "nginx-plus.ingress.tmpl")`)
 
 	virtualServerTemplatePath = flag.String("virtualserver-template-path", "",
-		`Path to the VirtualServer NGINX configuration template for a VirtualServer resource.
-	(default for NGINX "nginx.virtualserver.tmpl"; default for NGINX Plus "nginx-plus.virtualserver.tmpl")`)
+		`Path to the VirtualServer NGINX configuration template for a VirtualServer resource. The changes of the file, such as of a mounted ConfigMap,
+	are applied without a restart. (default for NGINX "nginx.virtualserver.tmpl"; default for NGINX Plus "nginx-plus.virtualserver.tmpl")`)
 
 	externalService = flag.String("external-service", "",
 		`Specifies the name of the service with the type LoadBalancer through which the Ingress controller pods are exposed externally.
-The external address of the service is used when reporting the status of Ingress resources. Requires -report-ingress-status.`)
+The external address of the service is used when reporting the status of Ingress resources (requires -report-ingress-status)
+and in the external endpoints of VirtualServer resources.`)
 
 	reportIngressStatus = flag.Bool("report-ingress-status", false,
 		"Update the address field in the status of Ingresses resources. Requires the -external-service flag, or the 'external-status-address' key in the ConfigMap.")
 
 	leaderElectionEnabled = flag.Bool("enable-leader-election", false,
-		"Enable Leader election to avoid multiple replicas of the controller reporting the status of Ingress resources -- only one replica will report status. See -report-ingress-status flag.")
+		`Enable Leader election to avoid multiple replicas of the controller reporting the status of Ingress, VirtualServer and VirtualServerRoute
+	resources and emitting the same events -- only one replica will report status and emit events. See -report-ingress-status flag.`)
 
 	leaderElectionLockName = flag.String("leader-election-lock-name", "nginx-ingress-leader-election",
 		`Specifies the name of the ConfigMap, within the same namespace as the controller, used as the lock for leader election. Requires -enable-leader-election.`)
@@ -110,6 +115,17 @@ The external address of the service is used when reporting the status of Ingress
 	nginxStatus = flag.Bool("nginx-status", true,
 		"Enable the NGINX stub_status, or the NGINX Plus API.")
 
+	nginxPlusDashboardLocation = flag.String("nginx-plus-dashboard-location", "/dashboard.html",
+		`Sets the location of the NGINX Plus live activity monitoring dashboard on the port of the NGINX Plus API. Requires -nginx-plus and -nginx-status`)
+
+	nginxPlusDashboardAllowCIDRs = flag.String("nginx-plus-dashboard-allow-cidrs", "",
+		`Whitelist IPv4 IP/CIDR blocks to allow access to the NGINX Plus dashboard. Separate multiple IP/CIDR by commas.
+	If not set, the value of -nginx-status-allow-cidrs is used. Requires -nginx-plus and -nginx-status`)
+
+	nginxPlusDashboardAuthSecret = flag.String("nginx-plus-dashboard-auth-secret", "",
+		`A Secret with an htpasswd file for the basic authentication of the NGINX Plus dashboard and the read-only NGINX Plus API.
+	Format: <namespace>/<name>. Requires -nginx-plus and -nginx-status`)
+
 	nginxDebug = flag.Bool("nginx-debug", false,
 		"Enable debugging for NGINX. Uses the nginx-debug binary. Requires 'error-log-level: debug' in the ConfigMap.")
 
@@ -126,6 +142,113 @@ The external address of the service is used when reporting the status of Ingress
 
 	enableCustomResources = flag.Bool("enable-custom-resources", true,
 		"Enable custom resources")
+
+	enableOIDC = flag.Bool("enable-oidc", false,
+		`Enable OIDC policies. Requires -nginx-plus and -enable-custom-resources`)
+
+	enableFinalizers = flag.Bool("enable-finalizers", false,
+		`Add a finalizer to VirtualServer and VirtualServerRoute resources, so that the Ingress Controller removes their configuration
+	before they are deleted. Requires -enable-custom-resources`)
+
+	enableResyncEndpoint = flag.Bool("enable-resync-endpoint", false,
+		`Enable the endpoint for the resync of the resources of a namespace: POST /resync?namespace=<namespace>.
+	The resync re-renders the configs of the Ingress, VirtualServer and VirtualServerRoute resources of the namespace and reloads NGINX`)
+
+	resyncEndpointListenPort = flag.Int("resync-endpoint-listen-port", 8082,
+		"Set the port where the resync endpoint is exposed. [1023 - 65535]")
+
+	resyncEndpointListenAddress = flag.String("resync-endpoint-listen-address", "127.0.0.1",
+		`Set the IP address where the resync endpoint is exposed. The endpoint doesn't require authentication,
+	so by default it listens only on the loopback interface. Set to 0.0.0.0 to expose the endpoint on all interfaces`)
+
+	enableProfiling = flag.Bool("enable-profiling", false,
+		`Enable the endpoint with the runtime profiles of the Ingress Controller in the format of pprof: /debug/pprof/.
+	The endpoint listens only on the loopback interface`)
+
+	profilingListenPort = flag.Int("profiling-listen-port", 6060,
+		"Set the port where the profiling endpoint is exposed. [1023 - 65535]")
+
+	validationStrictness = flag.String("validation-strictness", "strict",
+		`Set how the validation of VirtualServer and VirtualServerRoute resources treats the problems that NGINX can work around,
+	such as fields that are only supported in NGINX Plus. "strict" rejects such resources, "lenient" accepts them and reports the problems as warnings`)
+
+	emulateQueue = flag.Bool("emulate-queue", false,
+		`Approximate the queue of the upstreams of VirtualServer and VirtualServerRoute resources with limit_conn and limit_req
+	for NGINX, instead of rejecting the resources, so that they can be shared with NGINX Plus. Ignored for NGINX Plus`)
+
+	virtualServerUpstreamNamePrefix = flag.String("virtualserver-upstream-name-prefix", "vs",
+		`Set the prefix of the names of the upstreams of VirtualServer and VirtualServerRoute resources, for example,
+	to keep the names that the existing monitoring expects`)
+
+	virtualServerUpstreamNameExcludeNamespace = flag.Bool("virtualserver-upstream-name-exclude-namespace", false,
+		`Exclude the namespaces of the resources from the names of the upstreams of VirtualServer and VirtualServerRoute resources.
+	A VirtualServer with the same name as an older VirtualServer in another namespace is then rejected`)
+
+	testConfigBeforeReload = flag.Bool("test-config-before-reload", true,
+		`Test the NGINX config with nginx -t before reloading NGINX for VirtualServer resources. If the test fails,
+	the previous config of the VirtualServer is restored and the VirtualServer is marked invalid, so that NGINX keeps serving the other hosts`)
+
+	allowedVariables = flag.String("allowed-variables", "",
+		`A comma-separated list of the NGINX variables, in addition to the built-in ones, that are allowed in the conditions of matches
+	and in the bodies of return actions of VirtualServer and VirtualServerRoute resources, for example "ssl_client_s_dn,geoip_country_code"`)
+
+	endpointsChangeSuppressionPeriod = flag.Duration("endpoints-change-suppression-period", 0,
+		`Delay the sync of a changed Endpoints resource for the period, restarting the delay on every new change, up to twice the period. A change that is reverted
+	within the period, such as the flap of a crash-looping pod, is ignored and doesn't cause a reload of NGINX. 0 disables the delay`)
+
+	endpointsDrainDelay = flag.Duration("endpoints-drain-delay", 0,
+		`Keep the addresses removed from Endpoints, such as of terminating pods, in the upstreams of VirtualServer and VirtualServerRoute
+	resources for the delay before removing them. In NGINX Plus, the servers are marked as down. 0 disables the delay`)
+
+	enableEndpointSlices = flag.Bool("enable-endpoint-slices", false,
+		`Get the endpoints of services from the EndpointSlice resources (discovery.k8s.io/v1alpha1) instead of the Endpoints resources,
+	which are limited to 1000 addresses. Requires the EndpointSlice API to be enabled in the cluster`)
+
+	topologyZone = flag.String("topology-zone", "",
+		`The zone of the Ingress Controller for the upstreams with the prefer-zone topology policy. If not set, the zone is the
+	topology.kubernetes.io/zone label of the node in the NODE_NAME environment variable. Requires -enable-endpoint-slices`)
+
+	allowSnippets = flag.Bool("allow-snippets", true,
+		`Allow the snippets annotations of Ingress resources. The ConfigMap snippets are allowed regardless of this flag`)
+
+	allowedSnippetDirectives = flag.String("allowed-snippet-directives", "",
+		`A comma-separated list of the NGINX directives that are allowed in the snippets annotations of Ingress resources.
+	If empty, any directive that is valid in the context of a snippet is allowed`)
+
+	enableValidationWebhook = flag.Bool("enable-validation-webhook", false,
+		`Enable the validating admission webhook for VirtualServer and VirtualServerRoute resources, so that invalid resources are rejected
+	when they are applied. Requires -enable-custom-resources and -validation-webhook-tls-secret`)
+
+	validationWebhookListenPort = flag.Int("validation-webhook-listen-port", 8443,
+		"Set the port where the validating admission webhook is exposed. [1023 - 65535]")
+
+	validationWebhookTLSSecret = flag.String("validation-webhook-tls-secret", "",
+		`A Secret with a TLS certificate and key for the validating admission webhook. Format: <namespace>/<name>.
+	The Secret is reloaded periodically, so that a rotated certificate is used without a restart`)
+
+	virtualServerHostConflictPolicy = flag.String("virtualserver-host-conflict-policy", "oldest-wins",
+		`Set how the validation webhook handles a VirtualServer with a host that is used by another VirtualServer.
+	"oldest-wins" accepts such a VirtualServer, and the Ingress Controller configures only the oldest VirtualServer with the host.
+	"reject" rejects such a VirtualServer when it is applied`)
+
+	staging = flag.Bool("staging", false,
+		`Enable the debugging features for staging environments, such as -enable-warnings-header. Must not be used in production`)
+
+	enableWarningsHeader = flag.Bool("enable-warnings-header", false,
+		`Add the X-NGINX-Warnings-Count header with the number of the configuration warnings to the responses of a VirtualServer
+	with warnings. Requires -staging`)
+
+	enableWarningsHeaderCodes = flag.Bool("enable-warnings-header-codes", false,
+		`Also add the X-NGINX-Warnings-Codes header with the codes of the configuration warnings, which the Ingress Controller logs
+	along with the warnings. Requires -enable-warnings-header`)
+
+	streamVirtualServerConfigs = flag.Bool("stream-virtualserver-configs", false,
+		`Write the configs of VirtualServers directly to the files when the template is executed, without keeping the configs
+	in memory. Reduces the memory usage with many VirtualServers`)
+
+	compatibilityReportFile = flag.String("compatibility-report", "",
+		`Print the differences in how NGINX and NGINX Plus handle the VirtualServer, VirtualServerRoute and Policy resources
+	from the specified YAML or JSON file and exit. Useful for evaluating a migration between NGINX and NGINX Plus`)
 )
 
 func main() {
@@ -141,6 +264,13 @@ func main() {
 		os.Exit(0)
 	}
 
+	if *compatibilityReportFile != "" {
+		if err := runCompatibilityReport(*compatibilityReportFile, os.Stdout); err != nil {
+			glog.Fatalf("Error generating the compatibility report: %v", err)
+		}
+		os.Exit(0)
+	}
+
 	healthStatusURIValidationError := validateLocation(*healthStatusURI)
 	if healthStatusURIValidationError != nil {
 		glog.Fatalf("Invalid value for health-status-uri: %v", healthStatusURIValidationError)
@@ -161,11 +291,117 @@ func main() {
 		glog.Fatalf("Invalid value for prometheus-metrics-listen-port: %v", metricsPortValidationError)
 	}
 
+	resyncPortValidationError := validatePort(*resyncEndpointListenPort)
+	if resyncPortValidationError != nil {
+		glog.Fatalf("Invalid value for resync-endpoint-listen-port: %v", resyncPortValidationError)
+	}
+
+	resyncAddressValidationError := validateListenAddress(*resyncEndpointListenAddress)
+	if resyncAddressValidationError != nil {
+		glog.Fatalf("Invalid value for resync-endpoint-listen-address: %v", resyncAddressValidationError)
+	}
+
+	profilingPortValidationError := validatePort(*profilingListenPort)
+	if profilingPortValidationError != nil {
+		glog.Fatalf("Invalid value for profiling-listen-port: %v", profilingPortValidationError)
+	}
+
+	strictness, err := cr_validation.ParseStrictness(*validationStrictness)
+	if err != nil {
+		glog.Fatalf("Invalid value for validation-strictness: %v", err)
+	}
+
+	upstreamNamePrefixValidationError := validateUpstreamNamePrefix(*virtualServerUpstreamNamePrefix)
+	if upstreamNamePrefixValidationError != nil {
+		glog.Fatalf("Invalid value for virtualserver-upstream-name-prefix: %v", upstreamNamePrefixValidationError)
+	}
+
+	upstreamNamingScheme := configs.UpstreamNamingScheme{
+		Prefix:           *virtualServerUpstreamNamePrefix,
+		ExcludeNamespace: *virtualServerUpstreamNameExcludeNamespace,
+	}
+
+	// NGINX Plus supports the queue, so it is never emulated
+	queueEmulation := *emulateQueue && !*nginxPlus
+
+	allowedVariableNames, err := cr_validation.ParseAllowedVariables(parseAllowedVariables(*allowedVariables))
+	if err != nil {
+		glog.Fatalf("Invalid value for allowed-variables: %v", err)
+	}
+
+	if *endpointsChangeSuppressionPeriod < 0 {
+		glog.Fatalf("Invalid value for endpoints-change-suppression-period: %v must not be negative", *endpointsChangeSuppressionPeriod)
+	}
+
+	if *endpointsDrainDelay < 0 {
+		glog.Fatalf("Invalid value for endpoints-drain-delay: %v must not be negative", *endpointsDrainDelay)
+	}
+
+	snippetsValidator := configs.NewSnippetsValidator(*allowSnippets, parseAllowedSnippetDirectives(*allowedSnippetDirectives))
+
+	webhookPortValidationError := validatePort(*validationWebhookListenPort)
+	if webhookPortValidationError != nil {
+		glog.Fatalf("Invalid value for validation-webhook-listen-port: %v", webhookPortValidationError)
+	}
+
+	hostConflictPolicy, err := webhook.ParseHostConflictPolicy(*virtualServerHostConflictPolicy)
+	if err != nil {
+		glog.Fatalf("Invalid value for virtualserver-host-conflict-policy: %v", err)
+	}
+
+	if *enableValidationWebhook && !*enableCustomResources {
+		glog.Fatal("enable-validation-webhook requires -enable-custom-resources")
+	}
+
+	if *enableValidationWebhook && *validationWebhookTLSSecret == "" {
+		glog.Fatal("enable-validation-webhook requires -validation-webhook-tls-secret")
+	}
+
+	if *enableOIDC && !*nginxPlus {
+		glog.Fatal("enable-oidc is only supported with -nginx-plus")
+	}
+
+	if *enableOIDC && !*enableCustomResources {
+		glog.Fatal("enable-oidc requires -enable-custom-resources")
+	}
+
+	if *enableFinalizers && !*enableCustomResources {
+		glog.Fatal("enable-finalizers requires -enable-custom-resources")
+	}
+
 	allowedCIDRs, err := parseNginxStatusAllowCIDRs(*nginxStatusAllowCIDRs)
 	if err != nil {
 		glog.Fatalf(`Invalid value for nginx-status-allow-cidrs: %v`, err)
 	}
 
+	dashboardAllowedCIDRs := allowedCIDRs
+	if *nginxPlusDashboardAllowCIDRs != "" {
+		dashboardAllowedCIDRs, err = parseNginxStatusAllowCIDRs(*nginxPlusDashboardAllowCIDRs)
+		if err != nil {
+			glog.Fatalf(`Invalid value for nginx-plus-dashboard-allow-cidrs: %v`, err)
+		}
+	}
+
+	dashboardLocationValidationError := validateLocation(*nginxPlusDashboardLocation)
+	if dashboardLocationValidationError != nil {
+		glog.Fatalf("Invalid value for nginx-plus-dashboard-location: %v", dashboardLocationValidationError)
+	}
+	if *nginxPlusDashboardLocation == "/api" || strings.HasPrefix(*nginxPlusDashboardLocation, "/api/") {
+		glog.Fatalf("Invalid value for nginx-plus-dashboard-location: %v conflicts with the location of the NGINX Plus API", *nginxPlusDashboardLocation)
+	}
+
+	if *nginxPlusDashboardAuthSecret != "" && !*nginxPlus {
+		glog.Fatal("nginx-plus-dashboard-auth-secret is only supported with -nginx-plus")
+	}
+
+	if *enableWarningsHeader && !*staging {
+		glog.Fatal("enable-warnings-header requires -staging")
+	}
+
+	if *enableWarningsHeaderCodes && !*enableWarningsHeader {
+		glog.Fatal("enable-warnings-header-codes requires -enable-warnings-header")
+	}
+
 	glog.Infof("Starting NGINX Ingress controller Version=%v GitCommit=%v\n", version, gitCommit)
 
 	var config *rest.Config
@@ -242,14 +478,17 @@ func main() {
 	var registry *prometheus.Registry
 	var managerCollector collectors.ManagerCollector
 	var controllerCollector collectors.ControllerCollector
+	var configCollector collectors.ConfigCollector
 	constLabels := map[string]string{"class": *ingressClass}
 	managerCollector = collectors.NewManagerFakeCollector()
 	controllerCollector = collectors.NewControllerFakeCollector()
+	configCollector = collectors.NewConfigFakeCollector()
 
 	if *enablePrometheusMetrics {
 		registry = prometheus.NewRegistry()
 		managerCollector = collectors.NewLocalManagerMetricsCollector(constLabels)
 		controllerCollector = collectors.NewControllerMetricsCollector(*enableCustomResources, constLabels)
+		configCollector = collectors.NewConfigMetricsCollector(constLabels)
 
 		err = managerCollector.Register(registry)
 		if err != nil {
@@ -260,6 +499,11 @@ func main() {
 		if err != nil {
 			glog.Errorf("Error registering Controller Prometheus metrics: %v", err)
 		}
+
+		err = configCollector.Register(registry)
+		if err != nil {
+			glog.Errorf("Error registering Config Prometheus metrics: %v", err)
+		}
 	}
 
 	useFakeNginxManager := *proxyURL != ""
@@ -271,7 +515,7 @@ func main() {
 	}
 
 	if *defaultServerSecret != "" {
-		secret, err := getAndValidateSecret(kubeClient, *defaultServerSecret)
+		secret, err := getAndValidateSecret(kubeClient, *defaultServerSecret, k8s.ValidateTLSSecret)
 		if err != nil {
 			glog.Fatalf("Error trying to get the default server TLS secret %v: %v", *defaultServerSecret, err)
 		}
@@ -286,7 +530,7 @@ func main() {
 	}
 
 	if *wildcardTLSSecret != "" {
-		secret, err := getAndValidateSecret(kubeClient, *wildcardTLSSecret)
+		secret, err := getAndValidateSecret(kubeClient, *wildcardTLSSecret, k8s.ValidateTLSSecret)
 		if err != nil {
 			glog.Fatalf("Error trying to get the wildcard TLS secret %v: %v", *wildcardTLSSecret, err)
 		}
@@ -295,6 +539,16 @@ func main() {
 		nginxManager.CreateSecret(configs.WildcardSecretName, bytes, nginx.TLSSecretFileMode)
 	}
 
+	var dashboardAuthFile string
+	if *nginxPlusDashboardAuthSecret != "" {
+		secret, err := getAndValidateSecret(kubeClient, *nginxPlusDashboardAuthSecret, k8s.ValidateHtpasswdSecret)
+		if err != nil {
+			glog.Fatalf("Error trying to get the NGINX Plus dashboard auth secret %v: %v", *nginxPlusDashboardAuthSecret, err)
+		}
+
+		dashboardAuthFile = nginxManager.CreateSecret(configs.DashboardAuthSecretName, secret.Data[configs.HtpasswdKey], nginx.HtpasswdSecretFileMode)
+	}
+
 	cfgParams := configs.NewDefaultConfigParams()
 	if *nginxConfigMaps != "" {
 		ns, name, err := k8s.ParseNamespaceName(*nginxConfigMaps)
@@ -306,6 +560,10 @@ func main() {
 			glog.Fatalf("Error when getting %v: %v", *nginxConfigMaps, err)
 		}
 		cfgParams = configs.ParseConfigMap(cfm, *nginxPlus)
+		err = snippetsValidator.ValidateConfigMapSnippets(cfgParams)
+		if err != nil {
+			glog.Errorf("ConfigMap %v has invalid snippets: %v", *nginxConfigMaps, err)
+		}
 		if cfgParams.MainServerSSLDHParamFileContent != nil {
 			fileName, err := nginxManager.CreateDHParam(*cfgParams.MainServerSSLDHParamFileContent)
 			if err != nil {
@@ -334,7 +592,18 @@ func main() {
 		NginxStatus:                    *nginxStatus,
 		NginxStatusAllowCIDRs:          allowedCIDRs,
 		NginxStatusPort:                *nginxStatusPort,
+		NginxPlusDashboardLocation:     *nginxPlusDashboardLocation,
+		NginxPlusDashboardAllowCIDRs:   dashboardAllowedCIDRs,
+		NginxPlusDashboardAuthFile:     dashboardAuthFile,
 		StubStatusOverUnixSocketForOSS: *enablePrometheusMetrics,
+		EnableOIDC:                     *enableOIDC,
+		EnableWarningsHeader:           *enableWarningsHeader,
+		EnableWarningsHeaderCodes:      *enableWarningsHeaderCodes,
+		StreamVirtualServerConfigs:     *streamVirtualServerConfigs,
+		EmulateQueue:                   queueEmulation,
+		UpstreamNamingScheme:           upstreamNamingScheme,
+		TestConfigBeforeReload:         *testConfigBeforeReload,
+		VirtualServerTemplatePath:      *virtualServerTemplatePath,
 	}
 
 	ngxConfig := configs.GenerateNginxMainConfig(staticCfgParams, cfgParams)
@@ -370,6 +639,13 @@ func main() {
 
 	if *enablePrometheusMetrics {
 		if *nginxPlus {
+			if *enableCustomResources && plusClient != nil {
+				err = collectors.NewVirtualServerUpstreamsCollector(plusClient, upstreamNamingScheme.Prefix,
+					upstreamNamingScheme.ExcludeNamespace, constLabels).Register(registry)
+				if err != nil {
+					glog.Errorf("Error registering VirtualServer upstreams Prometheus metrics: %v", err)
+				}
+			}
 			go metrics.RunPrometheusListenerForNginxPlus(*prometheusMetricsListenPort, plusClient, registry, constLabels)
 		} else {
 			httpClient := getSocketClient("/var/lib/nginx/nginx-status.sock")
@@ -381,10 +657,39 @@ func main() {
 		}
 	}
 
+	if *enableProfiling {
+		go metrics.RunProfilingListener(*profilingListenPort)
+	}
+
 	isWildcardEnabled := *wildcardTLSSecret != ""
-	cnf := configs.NewConfigurator(nginxManager, staticCfgParams, cfgParams, templateExecutor, templateExecutorV2, *nginxPlus, isWildcardEnabled)
+	cnf := configs.NewConfigurator(nginxManager, staticCfgParams, cfgParams, templateExecutor, templateExecutorV2, *nginxPlus, isWildcardEnabled, configCollector)
 	controllerNamespace := os.Getenv("POD_NAMESPACE")
 
+	zone := *topologyZone
+	if zone == "" && *enableEndpointSlices {
+		zone, err = getZoneOfNode(kubeClient, os.Getenv("NODE_NAME"))
+		if err != nil {
+			glog.Warningf("The upstreams with the prefer-zone topology policy will not prefer the endpoints in the same zone: %v", err)
+		}
+	}
+
+	var reservedListenPorts []int
+	if *nginxStatus {
+		reservedListenPorts = append(reservedListenPorts, *nginxStatusPort)
+	}
+	if *enablePrometheusMetrics {
+		reservedListenPorts = append(reservedListenPorts, *prometheusMetricsListenPort)
+	}
+	if *enableResyncEndpoint {
+		reservedListenPorts = append(reservedListenPorts, *resyncEndpointListenPort)
+	}
+	if *enableValidationWebhook {
+		reservedListenPorts = append(reservedListenPorts, *validationWebhookListenPort)
+	}
+	if *enableProfiling {
+		reservedListenPorts = append(reservedListenPorts, *profilingListenPort)
+	}
+
 	lbcInput := k8s.NewLoadBalancerControllerInput{
 		KubeClient:                kubeClient,
 		ConfClient:                confClient,
@@ -400,14 +705,40 @@ func main() {
 		ReportIngressStatus:       *reportIngressStatus,
 		IsLeaderElectionEnabled:   *leaderElectionEnabled,
 		LeaderElectionLockName:    *leaderElectionLockName,
+		EnableFinalizers:          *enableFinalizers,
 		WildcardTLSSecret:         *wildcardTLSSecret,
 		ConfigMaps:                *nginxConfigMaps,
 		AreCustomResourcesEnabled: *enableCustomResources,
+		EnableOIDC:                *enableOIDC,
+		ReservedListenPorts:       reservedListenPorts,
+		ValidationStrictness:      strictness,
+		EmulateQueue:              queueEmulation,
+		AllowedVariables:          allowedVariableNames,
+		UpstreamNamingScheme:      upstreamNamingScheme,
+		SnippetsValidator:         snippetsValidator,
+		EndpointsDebouncePeriod:   *endpointsChangeSuppressionPeriod,
+		EndpointsDrainDelay:       *endpointsDrainDelay,
+		VirtualServerTemplatePath: *virtualServerTemplatePath,
+		UseEndpointSlices:         *enableEndpointSlices,
+		TopologyZone:              zone,
 		MetricsCollector:          controllerCollector,
 	}
 
 	lbc := k8s.NewLoadBalancerController(lbcInput)
 
+	if *enableResyncEndpoint {
+		go k8s.RunResyncListener(*resyncEndpointListenAddress, *resyncEndpointListenPort, lbc)
+	}
+
+	if *enableValidationWebhook {
+		ns, name, err := k8s.ParseNamespaceName(*validationWebhookTLSSecret)
+		if err != nil {
+			glog.Fatalf("Invalid value for validation-webhook-tls-secret: %v", err)
+		}
+		validator := webhook.NewValidator(*nginxPlus, queueEmulation, allowedVariableNames, strictness, hostConflictPolicy, lbc)
+		go webhook.RunServer(*validationWebhookListenPort, validator, kubeClient, ns, name, wait.NeverStop)
+	}
+
 	go handleTermination(lbc, nginxManager, nginxDone)
 	lbc.Run()
 
@@ -478,6 +809,14 @@ func validatePort(port int) error {
 	return nil
 }
 
+// validateListenAddress makes sure a given string is a valid IP address to listen on.
+func validateListenAddress(address string) error {
+	if net.ParseIP(address) == nil {
+		return fmt.Errorf("invalid IP address: %q", address)
+	}
+	return nil
+}
+
 // parseNginxStatusAllowCIDRs converts a comma separated CIDR/IP address string into an array of CIDR/IP addresses.
 // It returns an array of the valid CIDR/IP addresses or an error if given an invalid address.
 func parseNginxStatusAllowCIDRs(input string) (cidrs []string, err error) {
@@ -493,6 +832,32 @@ func parseNginxStatusAllowCIDRs(input string) (cidrs []string, err error) {
 	return cidrs, nil
 }
 
+// parseAllowedSnippetDirectives converts a comma separated list of directives into an array of directives.
+// The empty items of the list are ignored.
+func parseAllowedSnippetDirectives(input string) []string {
+	var directives []string
+	for _, d := range strings.Split(input, ",") {
+		trimmed := strings.TrimSpace(d)
+		if trimmed != "" {
+			directives = append(directives, trimmed)
+		}
+	}
+	return directives
+}
+
+// parseAllowedVariables converts a comma separated list of NGINX variables into an array of variables.
+// The empty items of the list are ignored.
+func parseAllowedVariables(input string) []string {
+	var variables []string
+	for _, v := range strings.Split(input, ",") {
+		trimmed := strings.TrimSpace(v)
+		if trimmed != "" {
+			variables = append(variables, trimmed)
+		}
+	}
+	return variables
+}
+
 // validateCIDRorIP makes sure a given string is either a valid CIDR block or IP address.
 // It an error if it is not valid.
 func validateCIDRorIP(cidr string) error {
@@ -510,8 +875,8 @@ func validateCIDRorIP(cidr string) error {
 	return nil
 }
 
-// getAndValidateSecret gets and validates a secret.
-func getAndValidateSecret(kubeClient *kubernetes.Clientset, secretNsName string) (secret *api_v1.Secret, err error) {
+// getAndValidateSecret gets a secret and validates it with the validate function.
+func getAndValidateSecret(kubeClient *kubernetes.Clientset, secretNsName string, validate func(*api_v1.Secret) error) (secret *api_v1.Secret, err error) {
 	ns, name, err := k8s.ParseNamespaceName(secretNsName)
 	if err != nil {
 		return nil, fmt.Errorf("could not parse the %v argument: %v", secretNsName, err)
@@ -520,13 +885,45 @@ func getAndValidateSecret(kubeClient *kubernetes.Clientset, secretNsName string)
 	if err != nil {
 		return nil, fmt.Errorf("could not get %v: %v", secretNsName, err)
 	}
-	err = k8s.ValidateTLSSecret(secret)
+	err = validate(secret)
 	if err != nil {
 		return nil, fmt.Errorf("%v is invalid: %v", secretNsName, err)
 	}
 	return secret, nil
 }
 
+// getZoneOfNode returns the zone of the node from its topology.kubernetes.io/zone label or,
+// for the older versions of Kubernetes, its failure-domain.beta.kubernetes.io/zone label.
+func getZoneOfNode(kubeClient *kubernetes.Clientset, nodeName string) (string, error) {
+	if nodeName == "" {
+		return "", fmt.Errorf("the NODE_NAME environment variable is not set")
+	}
+	node, err := kubeClient.CoreV1().Nodes().Get(nodeName, meta_v1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("could not get node %v: %v", nodeName, err)
+	}
+	for _, label := range []string{"topology.kubernetes.io/zone", api_v1.LabelZoneFailureDomain} {
+		if zone := node.Labels[label]; zone != "" {
+			return zone, nil
+		}
+	}
+	return "", fmt.Errorf("node %v has no zone label", nodeName)
+}
+
+const upstreamNamePrefixFmt = `[a-zA-Z0-9]([a-zA-Z0-9_-]*[a-zA-Z0-9])?`
+const upstreamNamePrefixErrMsg = "must consist of alphanumeric characters, '_' or '-', and must start and end with an alphanumeric character"
+
+var upstreamNamePrefixRegexp = regexp.MustCompile("^" + upstreamNamePrefixFmt + "$")
+
+// validateUpstreamNamePrefix validates the prefix of the names of upstreams, which NGINX requires to be a single word.
+func validateUpstreamNamePrefix(prefix string) error {
+	if !upstreamNamePrefixRegexp.MatchString(prefix) {
+		msg := validation.RegexError(upstreamNamePrefixErrMsg, upstreamNamePrefixFmt, "vs", "k8s_upstream")
+		return fmt.Errorf("invalid prefix format: %v", msg)
+	}
+	return nil
+}
+
 const locationFmt = `/[^\s{};]*`
 const locationErrMsg = "must start with / and must not include any whitespace character, `{`, `}` or `;`"
 
@@ -99,6 +99,24 @@ func TestValidateCIDRorIP(t *testing.T) {
 	}
 }
 
+func TestValidateListenAddress(t *testing.T) {
+	badAddresses := []string{"", "localhost", "127.0.0.1:8082", "127.0.0.0/8"}
+	for _, badAddress := range badAddresses {
+		err := validateListenAddress(badAddress)
+		if err == nil {
+			t.Errorf("validateListenAddress(%q) returned no error but expected an error", badAddress)
+		}
+	}
+
+	goodAddresses := []string{"127.0.0.1", "0.0.0.0", "::1", "10.0.0.1"}
+	for _, goodAddress := range goodAddresses {
+		err := validateListenAddress(goodAddress)
+		if err != nil {
+			t.Errorf("validateListenAddress(%q) returned an unexpected error: %v", goodAddress, err)
+		}
+	}
+}
+
 func TestValidateLocation(t *testing.T) {
 	badLocations := []string{
 		"",
@@ -124,3 +142,85 @@ func TestValidateLocation(t *testing.T) {
 		}
 	}
 }
+
+func TestValidateUpstreamNamePrefix(t *testing.T) {
+	badPrefixes := []string{
+		"",
+		"_vs",
+		"vs-",
+		"vs prefix",
+		"vs;",
+	}
+	for _, badPrefix := range badPrefixes {
+		err := validateUpstreamNamePrefix(badPrefix)
+		if err == nil {
+			t.Errorf("validateUpstreamNamePrefix(%v) returned no error when it should have returned an error", badPrefix)
+		}
+	}
+
+	goodPrefixes := []string{
+		"vs",
+		"k8s_upstream",
+		"ingress-nginx",
+	}
+	for _, goodPrefix := range goodPrefixes {
+		err := validateUpstreamNamePrefix(goodPrefix)
+		if err != nil {
+			t.Errorf("validateUpstreamNamePrefix(%v) returned an error when it should have returned no error: %v", goodPrefix, err)
+		}
+	}
+}
+
+func TestParseAllowedSnippetDirectives(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{
+			input:    "",
+			expected: nil,
+		},
+		{
+			input:    "add_header",
+			expected: []string{"add_header"},
+		},
+		{
+			input:    "add_header, proxy_set_header,,",
+			expected: []string{"add_header", "proxy_set_header"},
+		},
+	}
+
+	for _, test := range tests {
+		result := parseAllowedSnippetDirectives(test.input)
+		if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("parseAllowedSnippetDirectives(%q) returned %v but expected %v", test.input, result, test.expected)
+		}
+	}
+}
+
+func TestParseAllowedVariables(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{
+			input:    "",
+			expected: nil,
+		},
+		{
+			input:    "ssl_client_s_dn",
+			expected: []string{"ssl_client_s_dn"},
+		},
+		{
+			input:    "ssl_client_s_dn, $geoip_country_code,,",
+			expected: []string{"ssl_client_s_dn", "$geoip_country_code"},
+		},
+	}
+
+	for _, test := range tests {
+		result := parseAllowedVariables(test.input)
+		if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("parseAllowedVariables(%q) returned %v but expected %v", test.input, result, test.expected)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+#!/usr/bin/env bash
+
+# Generates the CustomResourceDefinitions, including their OpenAPI schemas, from the types in pkg/apis
+# and updates the manifests and the Helm chart.
+#
+# Usage:
+# hack/update-crds.sh
+
+set -o errexit
+set -o nounset
+set -o pipefail
+
+SCRIPT_ROOT=$(dirname ${BASH_SOURCE})/..
+CONTROLLER_GEN=${CONTROLLER_GEN:-go run -mod=vendor sigs.k8s.io/controller-tools/cmd/controller-gen}
+
+CRDS_FILE=${SCRIPT_ROOT}/deployments/common/custom-resource-definitions.yaml
+HELM_CRDS_FILE=${SCRIPT_ROOT}/deployments/helm-chart/templates/controller-custom-resources.yaml
+
+_tmp=$(mktemp -d)
+cleanup() {
+  rm -rf "${_tmp}"
+}
+trap "cleanup" EXIT SIGINT
+
+(cd ${SCRIPT_ROOT}; ${CONTROLLER_GEN} crd:trivialVersions=true,preserveUnknownFields=false paths=./pkg/apis/configuration/... output:crd:dir=${_tmp})
+
+# the status of a CustomResourceDefinition is set by Kubernetes
+crds=()
+for crd in virtualservers virtualserverroutes policies; do
+  sed -e '1{/^$/d}' -e '/^  creationTimestamp: null$/d' -e '/^status:$/,$d' ${_tmp}/k8s.nginx.org_${crd}.yaml > ${_tmp}/${crd}.yaml
+  crds+=(${_tmp}/${crd}.yaml)
+done
+
+cat "${crds[@]}" | sed '1d' > ${CRDS_FILE}
+
+{
+  echo '{{- if .Values.controller.enableCustomResources }}'
+  cat "${crds[@]}" | sed -e '1d' -e 's/^  name: .*$/&\n  labels:\n    {{- include "nginx-ingress.labels" . | nindent 4 }}/'
+  echo '{{- end }}'
+} > ${HELM_CRDS_FILE}
@@ -11,6 +11,10 @@ import (
 // JWTKeyAnnotation is the annotation where the Secret with a JWK is specified.
 const JWTKeyAnnotation = "nginx.com/jwt-key"
 
+// RegenerateAnnotation is the annotation that users change (for example, to the current timestamp)
+// to force the re-render of the config of a VirtualServer and a reload of NGINX.
+const RegenerateAnnotation = "nginx.org/regenerate"
+
 var masterBlacklist = map[string]bool{
 	"nginx.org/rewrites":                      true,
 	"nginx.org/ssl-services":                  true,
@@ -20,6 +20,8 @@ type ConfigParams struct {
 	MainServerNamesHashMaxSize    string
 	MainAccessLogOff              bool
 	MainLogFormat                 string
+	MainLogFormatEscaping         string
+	MainLogFormatPreset           string
 	MainErrorLogLevel             string
 	MainStreamLogFormat           string
 	ProxyBuffering                bool
@@ -47,15 +49,21 @@ type ConfigParams struct {
 	HealthCheckEnabled            bool
 	HealthCheckMandatory          bool
 	HealthCheckMandatoryQueue     int64
+	HealthCheckInterval           string
+	HealthCheckJitter             string
+	HealthCheckFails              int
+	HealthCheckPasses             int
 	SlowStart                     string
 	ResolverAddresses             []string
 	ResolverIPV6                  bool
 	ResolverValid                 string
 	ResolverTimeout               string
+	ResolverClusterDomain         string
 	MainKeepaliveTimeout          string
 	MainKeepaliveRequests         int64
 	VariablesHashBucketSize       uint64
 	VariablesHashMaxSize          uint64
+	VirtualServerMaxVariables     int
 	MainOpenTracingLoadModule     bool
 	MainOpenTracingEnabled        bool
 	MainOpenTracingTracer         string
@@ -65,14 +73,20 @@ type ConfigParams struct {
 	SetRealIPFrom   []string
 	RealIPRecursive bool
 
+	XForwardedFor   string
+	XForwardedProto string
+	XForwardedHost  string
+	XForwardedPort  string
+
 	MainServerSSLProtocols           string
 	MainServerSSLPreferServerCiphers bool
 	MainServerSSLCiphers             string
 	MainServerSSLDHParam             string
 	MainServerSSLDHParamFileContent  *string
 
-	MainTemplate    *string
-	IngressTemplate *string
+	MainTemplate          *string
+	IngressTemplate       *string
+	VirtualServerTemplate *string
 
 	JWTRealm    string
 	JWTKey      string
@@ -90,7 +104,24 @@ type StaticConfigParams struct {
 	NginxStatus                    bool
 	NginxStatusAllowCIDRs          []string
 	NginxStatusPort                int
+	NginxPlusDashboardLocation     string
+	NginxPlusDashboardAllowCIDRs   []string
+	NginxPlusDashboardAuthFile     string
 	StubStatusOverUnixSocketForOSS bool
+	EnableOIDC                     bool
+	EnableWarningsHeader           bool
+	EnableWarningsHeaderCodes      bool
+	// StreamVirtualServerConfigs makes the template of VirtualServers write the configs directly to the files,
+	// so that the configs are not kept in memory.
+	StreamVirtualServerConfigs bool
+	// EmulateQueue makes the VirtualServers approximate the queue of the upstreams for NGINX with limit_conn and limit_req.
+	EmulateQueue bool
+	// UpstreamNamingScheme configures the names of the upstreams of VirtualServers and VirtualServerRoutes.
+	UpstreamNamingScheme UpstreamNamingScheme
+	// VirtualServerTemplatePath is the path to the template of VirtualServers, which is re-read when it changes.
+	VirtualServerTemplatePath string
+	// TestConfigBeforeReload makes the configurator test the config with nginx -t before reloading NGINX for VirtualServers.
+	TestConfigBeforeReload bool
 }
 
 // NewDefaultConfigParams creates a ConfigParams with default values.
@@ -117,6 +148,7 @@ func NewDefaultConfigParams() *ConfigParams {
 		LBMethod:                      "random two least_conn",
 		MainErrorLogLevel:             "notice",
 		ResolverIPV6:                  true,
+		ResolverClusterDomain:         "cluster.local",
 		MainKeepaliveTimeout:          "65s",
 		MainKeepaliveRequests:         100,
 		VariablesHashBucketSize:       256,
@@ -0,0 +1,106 @@
+package configs
+
+import (
+	"github.com/nginxinc/kubernetes-ingress/internal/configs/version1"
+	"github.com/nginxinc/kubernetes-ingress/internal/configs/version2"
+	"github.com/nginxinc/kubernetes-ingress/internal/metrics/collectors"
+	conf_v1 "github.com/nginxinc/kubernetes-ingress/pkg/apis/configuration/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	ingressResourceType       = "ingress"
+	virtualServerResourceType = "virtualserver"
+)
+
+// getIngressConfigStats returns the stats of the NGINX configuration generated for an Ingress resource.
+// The default server of an upstream without endpoints is not counted as an endpoint.
+func getIngressConfigStats(cfg version1.IngressNginxConfig) collectors.ResourceConfigStats {
+	defaultServer := version1.NewUpstreamWithDefaultServer("").UpstreamServers[0]
+
+	stats := collectors.ResourceConfigStats{
+		UpstreamEndpoints: make(map[string]int),
+	}
+
+	for _, u := range cfg.Upstreams {
+		count := 0
+		for _, server := range u.UpstreamServers {
+			if server.Address == defaultServer.Address && server.Port == defaultServer.Port {
+				continue
+			}
+			count++
+		}
+		stats.UpstreamEndpoints[u.Name] = count
+	}
+
+	for _, server := range cfg.Servers {
+		stats.Locations += len(server.Locations)
+	}
+
+	return stats
+}
+
+// getVirtualServerConfigStats returns the stats of the NGINX configuration generated for a VirtualServer resource,
+// including the warnings of its VirtualServerRoutes.
+// The 502 server of an upstream without endpoints is not counted as an endpoint.
+func getVirtualServerConfigStats(cfg version2.VirtualServerConfig, warnings Warnings) collectors.ResourceConfigStats {
+	stats := collectors.ResourceConfigStats{
+		UpstreamEndpoints: make(map[string]int),
+		Locations:         len(cfg.Server.Locations) + len(cfg.Server.InternalRedirectLocations),
+	}
+
+	for _, u := range cfg.Upstreams {
+		count := 0
+		for _, server := range u.Servers {
+			if server.Address == nginx502Server {
+				continue
+			}
+			count++
+		}
+		stats.UpstreamEndpoints[u.Name] = count
+	}
+
+	for _, objWarnings := range warnings {
+		stats.Warnings += len(objWarnings)
+	}
+	stats.WarningCodes = getWarningCodes(warnings)
+
+	return stats
+}
+
+// getWarningCodes returns the number of the warnings of each code by the resource with the warnings.
+// It returns nil if there are no warnings.
+func getWarningCodes(warnings Warnings) map[collectors.WarnedResource]map[string]int {
+	var codes map[collectors.WarnedResource]map[string]int
+
+	for obj, objWarnings := range warnings {
+		r, ok := getWarnedResource(obj)
+		if !ok || len(objWarnings) == 0 {
+			continue
+		}
+
+		if codes == nil {
+			codes = make(map[collectors.WarnedResource]map[string]int)
+		}
+		if codes[r] == nil {
+			codes[r] = make(map[string]int)
+		}
+		for _, w := range objWarnings {
+			codes[r][w.Code]++
+		}
+	}
+
+	return codes
+}
+
+// getWarnedResource returns the kind, the namespace and the name of a resource with warnings.
+// The kind is not taken from the object, because the objects of the informers don't have their type meta.
+func getWarnedResource(obj runtime.Object) (collectors.WarnedResource, bool) {
+	switch o := obj.(type) {
+	case *conf_v1.VirtualServer:
+		return collectors.WarnedResource{Kind: "VirtualServer", Namespace: o.Namespace, Name: o.Name}, true
+	case *conf_v1.VirtualServerRoute:
+		return collectors.WarnedResource{Kind: "VirtualServerRoute", Namespace: o.Namespace, Name: o.Name}, true
+	}
+	return collectors.WarnedResource{}, false
+}
@@ -0,0 +1,117 @@
+package configs
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/nginxinc/kubernetes-ingress/internal/configs/version1"
+	"github.com/nginxinc/kubernetes-ingress/internal/configs/version2"
+	"github.com/nginxinc/kubernetes-ingress/internal/metrics/collectors"
+	conf_v1 "github.com/nginxinc/kubernetes-ingress/pkg/apis/configuration/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetIngressConfigStats(t *testing.T) {
+	cfg := version1.IngressNginxConfig{
+		Upstreams: []version1.Upstream{
+			{
+				Name: "default-cafe-ingress-cafe.example.com-coffee-svc-80",
+				UpstreamServers: []version1.UpstreamServer{
+					{Address: "10.0.0.1", Port: "80"},
+					{Address: "10.0.0.2", Port: "80"},
+				},
+			},
+			version1.NewUpstreamWithDefaultServer("default-cafe-ingress-cafe.example.com-tea-svc-80"),
+		},
+		Servers: []version1.Server{
+			{
+				Locations: []version1.Location{
+					{Path: "/coffee"},
+					{Path: "/tea"},
+				},
+			},
+		},
+	}
+
+	expected := collectors.ResourceConfigStats{
+		UpstreamEndpoints: map[string]int{
+			"default-cafe-ingress-cafe.example.com-coffee-svc-80": 2,
+			"default-cafe-ingress-cafe.example.com-tea-svc-80":    0,
+		},
+		Locations: 2,
+	}
+
+	result := getIngressConfigStats(cfg)
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("getIngressConfigStats() returned %+v but expected %+v", result, expected)
+	}
+}
+
+func TestGetVirtualServerConfigStats(t *testing.T) {
+	vs := &conf_v1.VirtualServer{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "cafe",
+			Namespace: "default",
+		},
+	}
+	vsr := &conf_v1.VirtualServerRoute{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "coffee",
+			Namespace: "default",
+		},
+	}
+
+	cfg := version2.VirtualServerConfig{
+		Upstreams: []version2.Upstream{
+			{
+				Name: "vs_default_cafe_tea",
+				Servers: []version2.UpstreamServer{
+					{Address: "10.0.0.1:80"},
+				},
+			},
+			{
+				Name: "vs_default_cafe_vsr_default_coffee_coffee",
+				Servers: []version2.UpstreamServer{
+					{Address: nginx502Server},
+				},
+			},
+		},
+		Server: version2.Server{
+			Locations: []version2.Location{
+				{Path: "/tea"},
+				{Path: "/coffee"},
+			},
+			InternalRedirectLocations: []version2.InternalRedirectLocation{
+				{Path: "/split"},
+			},
+		},
+	}
+
+	warnings := newWarnings()
+	warnings.AddWarning(vs, NewWarning(WarningCodeIgnoredSetting, WarningSeverityLow, "ignored setting"))
+	warnings.AddWarning(vsr, NewWarning(WarningCodeInvalidPolicy, WarningSeverityHigh, "invalid policy"))
+	warnings.AddWarning(vsr, NewWarning(WarningCodeResolverRequired, WarningSeverityMedium, "resolver required"))
+
+	expected := collectors.ResourceConfigStats{
+		UpstreamEndpoints: map[string]int{
+			"vs_default_cafe_tea":                       1,
+			"vs_default_cafe_vsr_default_coffee_coffee": 0,
+		},
+		Locations: 3,
+		Warnings:  3,
+		WarningCodes: map[collectors.WarnedResource]map[string]int{
+			{Kind: "VirtualServer", Namespace: "default", Name: "cafe"}: {
+				WarningCodeIgnoredSetting: 1,
+			},
+			{Kind: "VirtualServerRoute", Namespace: "default", Name: "coffee"}: {
+				WarningCodeInvalidPolicy:    1,
+				WarningCodeResolverRequired: 1,
+			},
+		},
+	}
+
+	result := getVirtualServerConfigStats(cfg, warnings)
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("getVirtualServerConfigStats() returned %+v but expected %+v", result, expected)
+	}
+}
@@ -162,7 +162,12 @@ func ParseConfigMap(cfgm *v1.ConfigMap, nginxPlus bool) *ConfigParams {
 		if err != nil {
 			glog.Error(err)
 		} else {
-			cfgParams.SetRealIPFrom = setRealIPFrom
+			parsedSetRealIPFrom, err := ParseSetRealIPFrom(setRealIPFrom)
+			if err != nil {
+				glog.Errorf("Configmap %s/%s: Invalid value for the set-real-ip-from key: got %q: %v", cfgm.GetNamespace(), cfgm.GetName(), setRealIPFrom, err)
+			} else {
+				cfgParams.SetRealIPFrom = parsedSetRealIPFrom
+			}
 		}
 	}
 
@@ -174,6 +179,26 @@ func ParseConfigMap(cfgm *v1.ConfigMap, nginxPlus bool) *ConfigParams {
 		}
 	}
 
+	xForwardedModes := []struct {
+		key  string
+		mode *string
+	}{
+		{"x-forwarded-for", &cfgParams.XForwardedFor},
+		{"x-forwarded-proto", &cfgParams.XForwardedProto},
+		{"x-forwarded-host", &cfgParams.XForwardedHost},
+		{"x-forwarded-port", &cfgParams.XForwardedPort},
+	}
+	for _, m := range xForwardedModes {
+		if mode, exists := cfgm.Data[m.key]; exists {
+			parsedMode, err := ParseXForwardedMode(mode, m.key == "x-forwarded-for")
+			if err != nil {
+				glog.Errorf("Configmap %s/%s: Invalid value for the %s key: got %q: %v", cfgm.GetNamespace(), cfgm.GetName(), m.key, mode, err)
+			} else {
+				*m.mode = parsedMode
+			}
+		}
+	}
+
 	if sslProtocols, exists := cfgm.Data["ssl-protocols"]; exists {
 		cfgParams.MainServerSSLProtocols = sslProtocols
 	}
@@ -211,6 +236,18 @@ func ParseConfigMap(cfgm *v1.ConfigMap, nginxPlus bool) *ConfigParams {
 		cfgParams.MainLogFormat = logFormat
 	}
 
+	if logFormatPreset, exists := cfgm.Data["log-format-preset"]; exists {
+		if logFormatPreset != logFormatPresetJSON {
+			glog.Errorf("Configmap %s/%s: Invalid value for the log-format-preset key: got %q: the only supported preset is %q", cfgm.GetNamespace(), cfgm.GetName(), logFormatPreset, logFormatPresetJSON)
+		} else if _, logFormatExists := cfgm.Data["log-format"]; logFormatExists {
+			glog.Errorf("Configmap %s/%s: The log-format-preset key is ignored because the log-format key is set", cfgm.GetNamespace(), cfgm.GetName())
+		} else {
+			cfgParams.MainLogFormatPreset = logFormatPreset
+			cfgParams.MainLogFormat = generateJSONLogFormat(nil)
+			cfgParams.MainLogFormatEscaping = jsonLogFormatEscaping
+		}
+	}
+
 	if streamLogFormat, exists := cfgm.Data["stream-log-format"]; exists {
 		cfgParams.MainStreamLogFormat = streamLogFormat
 	}
@@ -307,6 +344,42 @@ func ParseConfigMap(cfgm *v1.ConfigMap, nginxPlus bool) *ConfigParams {
 		}
 	}
 
+	if hcInterval, exists := cfgm.Data["health-check-interval"]; exists {
+		if interval, err := ParseTime(hcInterval); err != nil {
+			glog.Errorf("Configmap %s/%s: Invalid value for the health-check-interval key: %v", cfgm.GetNamespace(), cfgm.GetName(), err)
+		} else {
+			cfgParams.HealthCheckInterval = interval
+		}
+	}
+
+	if hcJitter, exists := cfgm.Data["health-check-jitter"]; exists {
+		if jitter, err := ParseTime(hcJitter); err != nil {
+			glog.Errorf("Configmap %s/%s: Invalid value for the health-check-jitter key: %v", cfgm.GetNamespace(), cfgm.GetName(), err)
+		} else {
+			cfgParams.HealthCheckJitter = jitter
+		}
+	}
+
+	if hcFails, exists, err := GetMapKeyAsInt(cfgm.Data, "health-check-fails", cfgm); exists {
+		if err != nil {
+			glog.Error(err)
+		} else if hcFails <= 0 {
+			glog.Errorf("Configmap %s/%s: Invalid value for the health-check-fails key: must be positive, got %d", cfgm.GetNamespace(), cfgm.GetName(), hcFails)
+		} else {
+			cfgParams.HealthCheckFails = hcFails
+		}
+	}
+
+	if hcPasses, exists, err := GetMapKeyAsInt(cfgm.Data, "health-check-passes", cfgm); exists {
+		if err != nil {
+			glog.Error(err)
+		} else if hcPasses <= 0 {
+			glog.Errorf("Configmap %s/%s: Invalid value for the health-check-passes key: must be positive, got %d", cfgm.GetNamespace(), cfgm.GetName(), hcPasses)
+		} else {
+			cfgParams.HealthCheckPasses = hcPasses
+		}
+	}
+
 	if upstreamZoneSize, exists := cfgm.Data["upstream-zone-size"]; exists {
 		cfgParams.UpstreamZoneSize = upstreamZoneSize
 	}
@@ -323,6 +396,10 @@ func ParseConfigMap(cfgm *v1.ConfigMap, nginxPlus bool) *ConfigParams {
 		cfgParams.IngressTemplate = &ingressTemplate
 	}
 
+	if virtualServerTemplate, exists := cfgm.Data["virtualserver-template"]; exists {
+		cfgParams.VirtualServerTemplate = &virtualServerTemplate
+	}
+
 	if mainStreamSnippets, exists, err := GetMapKeyAsStringSlice(cfgm.Data, "stream-snippets", cfgm, "\n"); exists {
 		if err != nil {
 			glog.Error(err)
@@ -335,11 +412,7 @@ func ParseConfigMap(cfgm *v1.ConfigMap, nginxPlus bool) *ConfigParams {
 		if err != nil {
 			glog.Error(err)
 		} else {
-			if nginxPlus {
-				cfgParams.ResolverAddresses = resolverAddresses
-			} else {
-				glog.Warning("ConfigMap key 'resolver-addresses' requires NGINX Plus")
-			}
+			cfgParams.ResolverAddresses = resolverAddresses
 		}
 	}
 
@@ -347,27 +420,23 @@ func ParseConfigMap(cfgm *v1.ConfigMap, nginxPlus bool) *ConfigParams {
 		if err != nil {
 			glog.Error(err)
 		} else {
-			if nginxPlus {
-				cfgParams.ResolverIPV6 = resolverIpv6
-			} else {
-				glog.Warning("ConfigMap key 'resolver-ipv6' requires NGINX Plus")
-			}
+			cfgParams.ResolverIPV6 = resolverIpv6
 		}
 	}
 
 	if resolverValid, exists := cfgm.Data["resolver-valid"]; exists {
-		if nginxPlus {
-			cfgParams.ResolverValid = resolverValid
-		} else {
-			glog.Warning("ConfigMap key 'resolver-valid' requires NGINX Plus")
-		}
+		cfgParams.ResolverValid = resolverValid
 	}
 
 	if resolverTimeout, exists := cfgm.Data["resolver-timeout"]; exists {
+		cfgParams.ResolverTimeout = resolverTimeout
+	}
+
+	if resolverClusterDomain, exists := cfgm.Data["resolver-cluster-domain"]; exists {
 		if nginxPlus {
-			cfgParams.ResolverTimeout = resolverTimeout
+			cfgParams.ResolverClusterDomain = resolverClusterDomain
 		} else {
-			glog.Warning("ConfigMap key 'resolver-timeout' requires NGINX Plus")
+			glog.Warning("ConfigMap key 'resolver-cluster-domain' requires NGINX Plus")
 		}
 	}
 
@@ -399,6 +468,16 @@ func ParseConfigMap(cfgm *v1.ConfigMap, nginxPlus bool) *ConfigParams {
 		}
 	}
 
+	if vsMaxVariables, exists, err := GetMapKeyAsInt(cfgm.Data, "virtualserver-max-variables", cfgm); exists {
+		if err != nil {
+			glog.Error(err)
+		} else if vsMaxVariables < 0 {
+			glog.Errorf("Configmap %s/%s: Invalid value for the virtualserver-max-variables key: must not be negative, got %d", cfgm.GetNamespace(), cfgm.GetName(), vsMaxVariables)
+		} else {
+			cfgParams.VirtualServerMaxVariables = vsMaxVariables
+		}
+	}
+
 	if openTracingTracer, exists := cfgm.Data["opentracing-tracer"]; exists {
 		cfgParams.MainOpenTracingTracer = openTracingTracer
 	}
@@ -434,7 +513,11 @@ func GenerateNginxMainConfig(staticCfgParams *StaticConfigParams, config *Config
 		NginxStatus:                    staticCfgParams.NginxStatus,
 		NginxStatusAllowCIDRs:          staticCfgParams.NginxStatusAllowCIDRs,
 		NginxStatusPort:                staticCfgParams.NginxStatusPort,
+		NginxPlusDashboardLocation:     staticCfgParams.NginxPlusDashboardLocation,
+		NginxPlusDashboardAllowCIDRs:   staticCfgParams.NginxPlusDashboardAllowCIDRs,
+		NginxPlusDashboardAuthFile:     staticCfgParams.NginxPlusDashboardAuthFile,
 		StubStatusOverUnixSocketForOSS: staticCfgParams.StubStatusOverUnixSocketForOSS,
+		OIDC:                           staticCfgParams.EnableOIDC,
 		MainSnippets:                   config.MainMainSnippets,
 		HTTPSnippets:                   config.MainHTTPSnippets,
 		StreamSnippets:                 config.MainStreamSnippets,
@@ -442,6 +525,7 @@ func GenerateNginxMainConfig(staticCfgParams *StaticConfigParams, config *Config
 		ServerNamesHashMaxSize:         config.MainServerNamesHashMaxSize,
 		AccessLogOff:                   config.MainAccessLogOff,
 		LogFormat:                      config.MainLogFormat,
+		LogFormatEscaping:              config.MainLogFormatEscaping,
 		ErrorLogLevel:                  config.MainErrorLogLevel,
 		StreamLogFormat:                config.MainStreamLogFormat,
 		SSLProtocols:                   config.MainServerSSLProtocols,
@@ -2,18 +2,30 @@ package configs
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"runtime"
+	"runtime/pprof"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/nginxinc/kubernetes-ingress/internal/configs/version2"
 
 	"github.com/golang/glog"
 	"github.com/nginxinc/kubernetes-ingress/internal/configs/version1"
+	"github.com/nginxinc/kubernetes-ingress/internal/metrics/collectors"
 	"github.com/nginxinc/kubernetes-ingress/internal/nginx"
 	conf_v1 "github.com/nginxinc/kubernetes-ingress/pkg/apis/configuration/v1"
 	api_v1 "k8s.io/api/core/v1"
 	extensions "k8s.io/api/extensions/v1beta1"
 	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
 )
 
 const pemFileNameForMissingTLSSecret = "/etc/nginx/secrets/default"
@@ -25,9 +37,21 @@ const DefaultServerSecretName = "default"
 // WildcardSecretName is the filename of the Secret with a TLS cert and a key for the ingress resources with TLS termination enabled but not secret defined.
 const WildcardSecretName = "wildcard"
 
+// DashboardAuthSecretName is the filename of the Secret with an htpasswd file for the NGINX Plus dashboard.
+const DashboardAuthSecretName = "dashboard-auth"
+
 // JWTKeyKey is the key of the data field of a Secret where the JWK must be stored.
 const JWTKeyKey = "jwk"
 
+// ClientSecretKey is the key of the data field of a Secret where the OIDC client secret must be stored.
+const ClientSecretKey = "client-secret"
+
+// CAKey is the key of the data field of a Secret where the certificate authority must be stored.
+const CAKey = "ca.crt"
+
+// HtpasswdKey is the key of the data field of a Secret where the htpasswd file must be stored.
+const HtpasswdKey = "htpasswd"
+
 // Configurator configures NGINX.
 type Configurator struct {
 	nginxManager       nginx.Manager
@@ -40,11 +64,39 @@ type Configurator struct {
 	virtualServers     map[string]*VirtualServerEx
 	isWildcardEnabled  bool
 	isPlus             bool
+	fallbackCerts      *fallbackCertificates
+	// virtualServerConfigs stores the last generated config of each VirtualServer, unless the configs are streamed to the files.
+	virtualServerConfigs map[string][]byte
+	// generatedVirtualServers stores the last generated config of each VirtualServer before the execution of the template,
+	// so that the upstreams can be updated without generating the whole config when only the endpoints change.
+	generatedVirtualServers map[string]generatedVirtualServer
+	// quarantinedVirtualServers stores the errors of the VirtualServers which configs couldn't be applied.
+	quarantinedVirtualServers map[string]error
+	// forcedVirtualServers stores the VirtualServers which configs are written at their next update even if the configs
+	// didn't change, so that a drift of the config files is fixed.
+	forcedVirtualServers map[string]bool
+	// stagedIngresses stores the states of the Ingresses before their configs were staged, until the configs are promoted.
+	stagedIngresses map[string]ingressSnapshot
+	// ingressConfigHashes stores the hash of the last written config of each Ingress, so that an unchanged config is not written.
+	ingressConfigHashes map[string]string
+	// virtualServerTemplate is the template of VirtualServers of the ConfigMap or of the template file that is in use.
+	virtualServerTemplate *string
+	metricsCollector      collectors.ConfigCollector
+}
+
+// generatedVirtualServer is the generated config of a VirtualServer, its hash and its warnings.
+type generatedVirtualServer struct {
+	cfg  version2.VirtualServerConfig
+	hash string
+	// secretsHash is the hash of the content of the Secret files referenced by the config.
+	// The config doesn't change when a Secret is rotated, but NGINX must be reloaded to read the new file.
+	secretsHash string
+	warnings    Warnings
 }
 
 // NewConfigurator creates a new Configurator.
 func NewConfigurator(nginxManager nginx.Manager, staticCfgParams *StaticConfigParams, config *ConfigParams, templateExecutor *version1.TemplateExecutor,
-	templateExecutorV2 *version2.TemplateExecutor, isPlus bool, isWildcardEnabled bool) *Configurator {
+	templateExecutorV2 *version2.TemplateExecutor, isPlus bool, isWildcardEnabled bool, metricsCollector collectors.ConfigCollector) *Configurator {
 	cnf := Configurator{
 		nginxManager:       nginxManager,
 		staticCfgParams:    staticCfgParams,
@@ -56,6 +108,15 @@ func NewConfigurator(nginxManager nginx.Manager, staticCfgParams *StaticConfigPa
 		minions:            make(map[string]map[string]bool),
 		isPlus:             isPlus,
 		isWildcardEnabled:  isWildcardEnabled,
+		fallbackCerts:      newFallbackCertificates(nginxManager),
+
+		virtualServerConfigs:      make(map[string][]byte),
+		generatedVirtualServers:   make(map[string]generatedVirtualServer),
+		quarantinedVirtualServers: make(map[string]error),
+		forcedVirtualServers:      make(map[string]bool),
+		stagedIngresses:           make(map[string]ingressSnapshot),
+		ingressConfigHashes:       make(map[string]string),
+		metricsCollector:          metricsCollector,
 	}
 	return &cnf
 }
@@ -71,8 +132,8 @@ func (cnf *Configurator) AddOrUpdateIngress(ingEx *IngressEx) error {
 		return fmt.Errorf("Error adding or updating ingress %v/%v: %v", ingEx.Ingress.Namespace, ingEx.Ingress.Name, err)
 	}
 
-	if err := cnf.nginxManager.Reload(); err != nil {
-		return fmt.Errorf("Error reloading NGINX for %v/%v: %v", ingEx.Ingress.Namespace, ingEx.Ingress.Name, err)
+	if err := cnf.commitConfigChanges(nil, nil, true); err != nil {
+		return fmt.Errorf("Error applying the config for %v/%v: %v", ingEx.Ingress.Namespace, ingEx.Ingress.Name, err)
 	}
 
 	return nil
@@ -82,6 +143,7 @@ func (cnf *Configurator) addOrUpdateIngress(ingEx *IngressEx) error {
 	pems := cnf.updateTLSSecrets(ingEx)
 	jwtKeyFileName := cnf.updateJWKSecret(ingEx)
 
+	generationStart := time.Now()
 	isMinion := false
 	nginxCfg := generateNginxCfg(ingEx, pems, isMinion, cnf.cfgParams, cnf.isPlus, cnf.IsResolverConfigured(), jwtKeyFileName)
 
@@ -90,21 +152,96 @@ func (cnf *Configurator) addOrUpdateIngress(ingEx *IngressEx) error {
 	if err != nil {
 		return fmt.Errorf("Error generating Ingress Config %v: %v", name, err)
 	}
-	cnf.nginxManager.CreateConfig(name, content)
+	generationDuration := time.Since(generationStart)
+	cnf.stageIngressConfig(name, content)
 
 	cnf.ingresses[name] = ingEx
+	stats := getIngressConfigStats(nginxCfg)
+	stats.GenerationDuration = generationDuration
+	cnf.metricsCollector.UpdateResourceConfig(ingressResourceType, ingEx.Ingress.Namespace, ingEx.Ingress.Name, stats)
 
 	return nil
 }
 
+// ingressSnapshot is the state of an Ingress before its config is staged.
+// The state is restored if the staged config fails the test of the NGINX config.
+type ingressSnapshot struct {
+	// ingEx is nil if the Ingress didn't exist before the change
+	ingEx *IngressEx
+	// minions is nil if the Ingress wasn't a master
+	minions map[string]bool
+	hash    string
+}
+
+// stageIngressConfig stages the config of an Ingress, unless it is the same as the last written config. The state of the Ingress
+// before the first staged config is kept until the configs are promoted.
+func (cnf *Configurator) stageIngressConfig(name string, content []byte) {
+	hash := fmt.Sprintf("%x", sha256.Sum256(content))
+	if hash == cnf.ingressConfigHashes[name] {
+		glog.V(3).Infof("The config of Ingress %v didn't change", name)
+		return
+	}
+
+	if _, staged := cnf.stagedIngresses[name]; !staged {
+		cnf.stagedIngresses[name] = ingressSnapshot{
+			ingEx:   cnf.ingresses[name],
+			minions: cnf.minions[name],
+			hash:    cnf.ingressConfigHashes[name],
+		}
+	}
+	cnf.nginxManager.CreateConfig(name, content)
+	cnf.ingressConfigHashes[name] = hash
+}
+
+// restoreIngresses discards the staged configs of the Ingresses (the file names) and restores their previous states,
+// so that NGINX keeps their previous configs. The Ingresses that didn't exist before are removed.
+func (cnf *Configurator) restoreIngresses(names []string) {
+	for _, name := range names {
+		snapshot := cnf.stagedIngresses[name]
+		delete(cnf.stagedIngresses, name)
+		cnf.nginxManager.DiscardStagedConfig(name)
+
+		if snapshot.ingEx != nil {
+			cnf.ingresses[name] = snapshot.ingEx
+		} else {
+			if ingEx, exists := cnf.ingresses[name]; exists {
+				cnf.metricsCollector.DeleteResourceConfig(ingressResourceType, ingEx.Ingress.Namespace, ingEx.Ingress.Name)
+			}
+			delete(cnf.ingresses, name)
+		}
+
+		if snapshot.minions != nil {
+			cnf.minions[name] = snapshot.minions
+		} else {
+			delete(cnf.minions, name)
+		}
+
+		if snapshot.hash != "" {
+			cnf.ingressConfigHashes[name] = snapshot.hash
+		} else {
+			delete(cnf.ingressConfigHashes, name)
+		}
+	}
+}
+
+// getStagedIngresses returns the sorted file names of the Ingresses which configs are staged.
+func (cnf *Configurator) getStagedIngresses() []string {
+	var names []string
+	for name := range cnf.stagedIngresses {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 // AddOrUpdateMergeableIngress adds or updates NGINX configuration for the Ingress resources with Mergeable Types.
 func (cnf *Configurator) AddOrUpdateMergeableIngress(mergeableIngs *MergeableIngresses) error {
 	if err := cnf.addOrUpdateMergeableIngress(mergeableIngs); err != nil {
 		return fmt.Errorf("Error when adding or updating ingress %v/%v: %v", mergeableIngs.Master.Ingress.Namespace, mergeableIngs.Master.Ingress.Name, err)
 	}
 
-	if err := cnf.nginxManager.Reload(); err != nil {
-		return fmt.Errorf("Error reloading NGINX for %v/%v: %v", mergeableIngs.Master.Ingress.Namespace, mergeableIngs.Master.Ingress.Name, err)
+	if err := cnf.commitConfigChanges(nil, nil, true); err != nil {
+		return fmt.Errorf("Error applying the config for %v/%v: %v", mergeableIngs.Master.Ingress.Namespace, mergeableIngs.Master.Ingress.Name, err)
 	}
 
 	return nil
@@ -119,6 +256,7 @@ func (cnf *Configurator) addOrUpdateMergeableIngress(mergeableIngs *MergeableIng
 		minionJwtKeyFileNames[minionName] = cnf.updateJWKSecret(minion)
 	}
 
+	generationStart := time.Now()
 	nginxCfg := generateNginxCfgForMergeableIngresses(mergeableIngs, masterPems, masterJwtKeyFileName, minionJwtKeyFileNames, cnf.cfgParams, cnf.isPlus, cnf.IsResolverConfigured())
 
 	name := objectMetaToFileName(&mergeableIngs.Master.Ingress.ObjectMeta)
@@ -126,7 +264,8 @@ func (cnf *Configurator) addOrUpdateMergeableIngress(mergeableIngs *MergeableIng
 	if err != nil {
 		return fmt.Errorf("Error generating Ingress Config %v: %v", name, err)
 	}
-	cnf.nginxManager.CreateConfig(name, content)
+	generationDuration := time.Since(generationStart)
+	cnf.stageIngressConfig(name, content)
 
 	cnf.ingresses[name] = mergeableIngs.Master
 	cnf.minions[name] = make(map[string]bool)
@@ -135,46 +274,632 @@ func (cnf *Configurator) addOrUpdateMergeableIngress(mergeableIngs *MergeableIng
 		cnf.minions[name][minionName] = true
 	}
 
+	master := mergeableIngs.Master.Ingress
+	stats := getIngressConfigStats(nginxCfg)
+	stats.GenerationDuration = generationDuration
+	cnf.metricsCollector.UpdateResourceConfig(ingressResourceType, master.Namespace, master.Name, stats)
+
 	return nil
 }
 
 // AddOrUpdateVirtualServer adds or updates NGINX configuration for the VirtualServer resource.
+// If the config of the VirtualServer can't be generated, the previous config of the VirtualServer is kept.
+// If the new config fails the test before the reload or NGINX fails to reload with it, the previous config is restored
+// (or the config is removed, if the VirtualServer is new). In all cases, the VirtualServer is quarantined and an error is returned.
 func (cnf *Configurator) AddOrUpdateVirtualServer(virtualServerEx *VirtualServerEx) (Warnings, error) {
-	warnings, err := cnf.addOrUpdateVirtualServer(virtualServerEx)
+	name := getFileNameForVirtualServer(virtualServerEx.VirtualServer)
+	snapshots := cnf.snapshotVirtualServers([]*VirtualServerEx{virtualServerEx})
+
+	changed, warnings, err := cnf.addOrUpdateVirtualServer(virtualServerEx)
 	if err != nil {
 		return warnings, fmt.Errorf("Error adding or updating VirtualServer %v/%v: %v", virtualServerEx.VirtualServer.Namespace, virtualServerEx.VirtualServer.Name, err)
 	}
 
-	if err := cnf.nginxManager.Reload(); err != nil {
-		return warnings, fmt.Errorf("Error reloading NGINX for VirtualServer %v/%v: %v", virtualServerEx.VirtualServer.Namespace, virtualServerEx.VirtualServer.Name, err)
+	if !changed {
+		glog.V(3).Infof("No need to reload nginx: the config of VirtualServer %v/%v didn't change", virtualServerEx.VirtualServer.Namespace, virtualServerEx.VirtualServer.Name)
+		cnf.setKeyValPairsForVirtualServer(name)
+		return warnings, nil
+	}
+
+	if err := cnf.commitConfigChanges(snapshots, []string{name}, true); err != nil {
+		return warnings, fmt.Errorf("Error applying the config of VirtualServer %v/%v: %v", virtualServerEx.VirtualServer.Namespace, virtualServerEx.VirtualServer.Name, err)
+	}
+	if quarantineErr := cnf.quarantinedVirtualServers[name]; quarantineErr != nil {
+		return warnings, fmt.Errorf("Error applying the config of VirtualServer %v/%v: %v", virtualServerEx.VirtualServer.Namespace, virtualServerEx.VirtualServer.Name, quarantineErr)
 	}
 
+	cnf.setKeyValPairsForVirtualServer(name)
+
 	return warnings, nil
 }
 
+// setKeyValPairsForVirtualServer sets the key-value pairs of the applied config of the VirtualServer through the NGINX Plus API,
+// for example, the weights of the splits. The pairs are set without a reload, so the errors are only logged.
+func (cnf *Configurator) setKeyValPairsForVirtualServer(name string) {
+	if !cnf.isPlus {
+		return
+	}
+
+	for _, pair := range cnf.generatedVirtualServers[name].cfg.KeyValPairs {
+		if err := cnf.nginxManager.SetKeyValPairInPlus(pair.ZoneName, pair.Key, pair.Value); err != nil {
+			glog.Warningf("Couldn't set the key-value pairs of VirtualServer %v: %v", name, err)
+		}
+	}
+}
+
+// testConfig tests the NGINX config with the staged configs, except for the excluded ones, before a reload,
+// unless the test is disabled.
+func (cnf *Configurator) testConfig(excludedConfigs ...string) error {
+	if !cnf.staticCfgParams.TestConfigBeforeReload {
+		return nil
+	}
+	return cnf.nginxManager.TestConfig(excludedConfigs...)
+}
+
+// virtualServerSnapshot is the state of a VirtualServer before its config is changed.
+// The state is restored if NGINX can't be reloaded with the changed config.
+type virtualServerSnapshot struct {
+	// virtualServerEx is nil if the VirtualServer didn't exist before the change
+	virtualServerEx *VirtualServerEx
+	// content is nil if the previous config was streamed to the file
+	content       []byte
+	quarantineErr error
+}
+
+// snapshotVirtualServers returns the states of the VirtualServers before their configs are changed, keyed by the file names.
+func (cnf *Configurator) snapshotVirtualServers(virtualServerExes []*VirtualServerEx) map[string]virtualServerSnapshot {
+	snapshots := make(map[string]virtualServerSnapshot)
+
+	for _, vsEx := range virtualServerExes {
+		name := getFileNameForVirtualServer(vsEx.VirtualServer)
+		snapshots[name] = virtualServerSnapshot{
+			virtualServerEx: cnf.virtualServers[name],
+			content:         cnf.virtualServerConfigs[name],
+			quarantineErr:   cnf.quarantinedVirtualServers[name],
+		}
+	}
+
+	return snapshots
+}
+
+// commitConfigChanges tests the staged configs, promotes them to the configs that NGINX uses and reloads NGINX,
+// unless reload is false because the changes were applied through the NGINX Plus API. The changed VirtualServers are
+// the file names of the VirtualServers which configs were staged, and the snapshots must include their states before the change.
+// If the test fails, the staged Ingresses which configs fail the test are restored, the changed VirtualServers which configs
+// fail the test are restored and quarantined, and the other changes are applied. If NGINX fails to reload, the changed
+// VirtualServers are restored, and, if NGINX works with the restored configs and only one VirtualServer changed,
+// that VirtualServer is quarantined.
+// Without a reload, the configs are tested only if configs of Ingresses or VirtualServers were staged.
+// Every reload of NGINX goes through this function. It returns an error if the changes were not applied
+// or if the configs of Ingresses were rejected.
+func (cnf *Configurator) commitConfigChanges(snapshots map[string]virtualServerSnapshot, changed []string, reload bool) error {
+	if !reload && len(changed) == 0 && len(cnf.stagedIngresses) == 0 {
+		glog.V(3).Info("No need to test nginx config: no configs changed")
+		return nil
+	}
+
+	var rejectErr error
+
+	if err := cnf.testConfig(); err != nil {
+		testErr := fmt.Errorf("Error testing NGINX config, NGINX keeps the previous config: %v", err)
+		stagedIngresses := cnf.getStagedIngresses()
+
+		invalid, invalidErrs, err := cnf.findInvalidConfigs(append(append([]string{}, stagedIngresses...), changed...))
+		if err != nil {
+			// the config is invalid even without the changed configs, so the Ingresses and VirtualServers are not the cause
+			glog.Errorf("Error testing NGINX config without the changed configs of Ingresses and VirtualServers: %v", err)
+
+			// the changed configs weren't promoted, so it is enough to restore the staged configs
+			cnf.restoreIngresses(stagedIngresses)
+			cnf.restoreVirtualServers(snapshots, changed)
+			return testErr
+		}
+
+		var invalidIngresses, invalidVirtualServers []string
+		for _, name := range invalid {
+			if _, staged := cnf.stagedIngresses[name]; staged {
+				glog.Errorf("Discarding the config of Ingress %v: %v", name, invalidErrs[name])
+				invalidIngresses = append(invalidIngresses, name)
+			} else {
+				invalidVirtualServers = append(invalidVirtualServers, name)
+			}
+		}
+
+		if len(invalidIngresses) > 0 {
+			cnf.restoreIngresses(invalidIngresses)
+			rejectErr = fmt.Errorf("Error testing NGINX config, NGINX keeps the previous configs of Ingresses %v: %v",
+				strings.Join(invalidIngresses, ", "), invalidErrs[invalidIngresses[0]])
+		}
+
+		candidates := cnf.getVirtualServers(invalidVirtualServers)
+		cnf.restoreVirtualServers(snapshots, invalidVirtualServers)
+		for _, vsEx := range candidates {
+			name := getFileNameForVirtualServer(vsEx.VirtualServer)
+			glog.Errorf("Quarantining VirtualServer %v/%v: %v", vsEx.VirtualServer.Namespace, vsEx.VirtualServer.Name, invalidErrs[name])
+			cnf.quarantineVirtualServer(vsEx, snapshots[name], invalidErrs[name])
+		}
+		changed = removeNames(changed, invalidVirtualServers)
+	}
+
+	cnf.nginxManager.PromoteStagedConfigs()
+	cnf.stagedIngresses = make(map[string]ingressSnapshot)
+	if !reload {
+		return rejectErr
+	}
+
+	if err := cnf.nginxManager.Reload(); err != nil {
+		reloadErr := fmt.Errorf("Error reloading NGINX: %v", err)
+		candidates := cnf.getVirtualServers(changed)
+
+		cnf.restoreVirtualServers(snapshots, changed)
+		cnf.nginxManager.PromoteStagedConfigs()
+		if err := cnf.nginxManager.Reload(); err != nil {
+			// NGINX fails to reload even without the changed configs, so the VirtualServers are not the cause
+			glog.Errorf("Error reloading NGINX after restoring the previous configs of VirtualServers: %v", err)
+			return reloadErr
+		}
+
+		// the errors of batches can't be attributed to a VirtualServer without reloading NGINX for each of them
+		if len(candidates) == 1 {
+			name := getFileNameForVirtualServer(candidates[0].VirtualServer)
+			cnf.quarantineVirtualServer(candidates[0], snapshots[name], reloadErr)
+		}
+
+		return reloadErr
+	}
+
+	return rejectErr
+}
+
+// findInvalidConfigs finds the changed configs (the file names of Ingresses and VirtualServers) which fail the test of the NGINX config.
+// The config is tested without the changed configs, and then the changed configs are added one by one:
+// a config that fails the test together with the previously added configs is invalid, and it is not added.
+// It returns the invalid configs with their errors, or an error if the config is invalid even without the changed configs.
+func (cnf *Configurator) findInvalidConfigs(changed []string) ([]string, map[string]error, error) {
+	if err := cnf.testConfig(changed...); err != nil {
+		return nil, nil, err
+	}
+
+	var invalid []string
+	errs := make(map[string]error)
+
+	for i, name := range changed {
+		// the invalid configs and the configs that are not added yet are excluded
+		excluded := append(append([]string{}, invalid...), changed[i+1:]...)
+		if err := cnf.testConfig(excluded...); err != nil {
+			invalid = append(invalid, name)
+			errs[name] = fmt.Errorf("Error testing NGINX config with the config %v: %v", name, err)
+		}
+	}
+
+	return invalid, errs, nil
+}
+
+func removeNames(names []string, removed []string) []string {
+	removedNames := make(map[string]bool)
+	for _, name := range removed {
+		removedNames[name] = true
+	}
+
+	var result []string
+	for _, name := range names {
+		if !removedNames[name] {
+			result = append(result, name)
+		}
+	}
+	return result
+}
+
+// getVirtualServers returns the VirtualServers with the file names.
+func (cnf *Configurator) getVirtualServers(names []string) []*VirtualServerEx {
+	var virtualServerExes []*VirtualServerEx
+	for _, name := range names {
+		if vsEx, exists := cnf.virtualServers[name]; exists {
+			virtualServerExes = append(virtualServerExes, vsEx)
+		}
+	}
+	return virtualServerExes
+}
+
+// quarantineVirtualServer quarantines a VirtualServer which config was rolled back because of the error.
+func (cnf *Configurator) quarantineVirtualServer(virtualServerEx *VirtualServerEx, snapshot virtualServerSnapshot, err error) {
+	vs := virtualServerEx.VirtualServer
+	if snapshot.virtualServerEx == nil {
+		cnf.metricsCollector.DeleteResourceConfig(virtualServerResourceType, vs.Namespace, vs.Name)
+	}
+	cnf.quarantinedVirtualServers[getFileNameForVirtualServer(vs)] = err
+}
+
+// restoreVirtualServers restores the states of the snapshots of the changed VirtualServers (the file names) without reloading NGINX.
+func (cnf *Configurator) restoreVirtualServers(snapshots map[string]virtualServerSnapshot, changed []string) {
+	for _, name := range changed {
+		cnf.restoreVirtualServer(name, snapshots[name])
+	}
+
+	cnf.removeUnusedFallbackCertificates()
+}
+
+// restoreVirtualServer restores the previous config of a VirtualServer without reloading NGINX.
+// If the VirtualServer didn't exist before, its config is removed.
+func (cnf *Configurator) restoreVirtualServer(name string, snapshot virtualServerSnapshot) {
+	prevVsEx := snapshot.virtualServerEx
+
+	if prevVsEx != nil && snapshot.content == nil {
+		// the previous config was streamed to the file, so it is generated again
+		if _, _, err := cnf.addOrUpdateVirtualServer(prevVsEx); err == nil {
+			cnf.restoreQuarantineError(name, snapshot.quarantineErr)
+			return
+		}
+		glog.Warningf("Couldn't generate the previous config of VirtualServer %v/%v, the config is removed", prevVsEx.VirtualServer.Namespace, prevVsEx.VirtualServer.Name)
+		prevVsEx = nil
+	}
+
+	if prevVsEx != nil {
+		cnf.nginxManager.CreateConfig(name, snapshot.content)
+		cnf.virtualServers[name] = prevVsEx
+		cnf.virtualServerConfigs[name] = snapshot.content
+	} else {
+		cnf.nginxManager.DeleteConfig(name)
+		delete(cnf.virtualServers, name)
+		delete(cnf.virtualServerConfigs, name)
+	}
+	// the previous config is restored without its generated config, so the next update of the endpoints regenerates it
+	delete(cnf.generatedVirtualServers, name)
+	cnf.restoreQuarantineError(name, snapshot.quarantineErr)
+}
+
+func (cnf *Configurator) restoreQuarantineError(name string, err error) {
+	if err != nil {
+		cnf.quarantinedVirtualServers[name] = err
+	} else {
+		delete(cnf.quarantinedVirtualServers, name)
+	}
+}
+
+// GetVirtualServerQuarantineError returns the error that caused the VirtualServer to be quarantined
+// or nil if the VirtualServer is not quarantined.
+func (cnf *Configurator) GetVirtualServerQuarantineError(virtualServer *conf_v1.VirtualServer) error {
+	return cnf.quarantinedVirtualServers[getFileNameForVirtualServer(virtualServer)]
+}
+
+// UpdateVirtualServers updates NGINX configuration for the VirtualServer resources.
+func (cnf *Configurator) UpdateVirtualServers(virtualServerExes []*VirtualServerEx) error {
+	snapshots := cnf.snapshotVirtualServers(virtualServerExes)
+
+	// It is safe to ignore warnings here as no new warnings should appear when updating VirtualServers
+	changed, _ := cnf.addOrUpdateVirtualServers(virtualServerExes)
+
+	if len(changed) == 0 {
+		glog.V(3).Info("No need to reload nginx: the configs of the VirtualServers didn't change")
+		return nil
+	}
+
+	if err := cnf.commitConfigChanges(snapshots, changed, true); err != nil {
+		return fmt.Errorf("Error when updating VirtualServers: %v", err)
+	}
+
+	return nil
+}
+
 func (cnf *Configurator) addOrUpdateOpenTracingTracerConfig(content string) error {
 	err := cnf.nginxManager.CreateOpenTracingTracerConfig(content)
 	return err
 }
 
-func (cnf *Configurator) addOrUpdateVirtualServer(virtualServerEx *VirtualServerEx) (Warnings, error) {
-	tlsPemFileName := ""
+// addOrUpdateVirtualServer generates the config of a VirtualServer and writes it, unless it is the same as the last written config.
+// It returns false if neither the config nor the fallback certificate changed, so NGINX doesn't need to be reloaded for the VirtualServer.
+func (cnf *Configurator) addOrUpdateVirtualServer(virtualServerEx *VirtualServerEx) (bool, Warnings, error) {
+	job := cnf.prepareVirtualServerGeneration(virtualServerEx)
+	cnf.generateVirtualServer(context.Background(), job)
+
+	changed, warnings, err := cnf.applyVirtualServerGeneration(job)
+	if err == nil {
+		cnf.removeUnusedFallbackCertificates()
+	}
+
+	return changed, warnings, err
+}
+
+// addOrUpdateVirtualServers adds or updates the configs of the VirtualServers like addOrUpdateVirtualServer, but generates them
+// concurrently. The errors are logged and the VirtualServers are quarantined, so that a VirtualServer with an invalid config
+// doesn't block the configuration of other resources.
+// It returns the file names of the VirtualServers which configs changed, and the warnings of the VirtualServers that were added or updated.
+func (cnf *Configurator) addOrUpdateVirtualServers(virtualServerExes []*VirtualServerEx) ([]string, Warnings) {
+	var jobs []*virtualServerGenerationJob
+	for _, vsEx := range virtualServerExes {
+		jobs = append(jobs, cnf.prepareVirtualServerGeneration(vsEx))
+	}
+
+	cnf.generateVirtualServers(context.Background(), jobs)
+
+	var changed []string
+	allWarnings := newWarnings()
+
+	for _, job := range jobs {
+		vs := job.virtualServerEx.VirtualServer
+		vsChanged, warnings, err := cnf.applyVirtualServerGeneration(job)
+		if err != nil {
+			glog.Errorf("Error adding or updating VirtualServer %v/%v: %v", vs.Namespace, vs.Name, err)
+			continue
+		}
+		if vsChanged {
+			changed = append(changed, getFileNameForVirtualServer(vs))
+		}
+		allWarnings.Add(warnings)
+	}
+
+	// the fallback certificates are removed after all VirtualServers are applied, because the certificates
+	// of the VirtualServers that are not applied yet are not in use
+	cnf.removeUnusedFallbackCertificates()
+
+	return changed, allWarnings
+}
+
+// virtualServerGenerationJob is the generation of the config of a VirtualServer. The input is prepared and the result is applied
+// sequentially, while the config is generated without modifying the Configurator, so that VirtualServers can be generated concurrently.
+type virtualServerGenerationJob struct {
+	virtualServerEx *VirtualServerEx
+	tlsPemFileName  string
+	policyOpts      policyOptions
+	fallbackWarning *Warning
+	// a rotated fallback certificate keeps its file name, so NGINX must be reloaded even if the config doesn't change
+	fallbackCertGenerated bool
+	secretsHash           string
+
+	vsCfg              version2.VirtualServerConfig
+	warnings           Warnings
+	content            []byte
+	err                error
+	generationDuration time.Duration
+	// prevHash is the hash of the config that was last applied. If the generated config has the same hash,
+	// the template is not executed, because the content is the same as the last written content.
+	prevHash  string
+	hash      string
+	unchanged bool
+	// forced means that the config is written and NGINX is reloaded even if the config didn't change
+	forced bool
+	// streamed means that the config was written to the file during the generation, and its content is not kept
+	streamed bool
+}
+
+// prepareVirtualServerGeneration writes the Secrets referenced by the VirtualServer and returns the job that generates its config.
+func (cnf *Configurator) prepareVirtualServerGeneration(virtualServerEx *VirtualServerEx) *virtualServerGenerationJob {
+	vs := virtualServerEx.VirtualServer
+	job := &virtualServerGenerationJob{
+		virtualServerEx: virtualServerEx,
+	}
+
+	name := getFileNameForVirtualServer(vs)
+	if cnf.forcedVirtualServers[name] || isRegenerationRequested(cnf.virtualServers[name], virtualServerEx) {
+		job.forced = true
+	} else if _, quarantined := cnf.quarantinedVirtualServers[name]; !quarantined {
+		job.prevHash = cnf.generatedVirtualServers[name].hash
+	}
+
 	if virtualServerEx.TLSSecret != nil {
-		tlsPemFileName = cnf.addOrUpdateTLSSecret(virtualServerEx.TLSSecret)
+		job.tlsPemFileName = cnf.addOrUpdateTLSSecret(virtualServerEx.TLSSecret)
+	} else if isTLSSecretReferenced(vs) {
+		fileName, generated, err := cnf.fallbackCerts.addOrUpdate(vs.Spec.Host)
+		if err != nil {
+			glog.Errorf("%v", err)
+		} else {
+			job.tlsPemFileName = fileName
+			job.fallbackCertGenerated = generated
+			warning := NewWarning(WarningCodeFallbackCertificate, WarningSeverityMedium,
+				"TLS secret %s is missing or invalid, a self-signed certificate for host %s is used", vs.Spec.TLS.Secret, vs.Spec.Host)
+			job.fallbackWarning = &warning
+		}
 	}
+
+	job.policyOpts = policyOptions{
+		jwtKeyFileNames:          cnf.addOrUpdateJWKSecretsForVirtualServer(virtualServerEx),
+		oidcClientSecrets:        getOIDCClientSecretsForVirtualServer(virtualServerEx),
+		egressTLSSecretFileNames: cnf.addOrUpdateEgressTLSSecretsForVirtualServer(virtualServerEx),
+		trustedCAFileNames:       cnf.addOrUpdateCASecretsForVirtualServer(virtualServerEx),
+		htpasswdFileNames:        cnf.addOrUpdateHtpasswdSecretsForVirtualServer(virtualServerEx),
+	}
+	job.secretsHash = hashVirtualServerSecrets(virtualServerEx)
+
+	return job
+}
+
+// generateVirtualServer generates the config of the job. It is safe to call concurrently for different jobs.
+// The profiling labels of ctx are restored after the generation.
+func (cnf *Configurator) generateVirtualServer(ctx context.Context, job *virtualServerGenerationJob) {
+	vs := job.virtualServerEx.VirtualServer
+
+	// the label allows to filter the CPU profiles of the generation by the VirtualServer
+	pprof.Do(ctx, pprof.Labels("virtualserver", vs.Namespace+"/"+vs.Name), func(context.Context) {
+		cnf.generateVirtualServerConfig(job)
+	})
+}
+
+func (cnf *Configurator) generateVirtualServerConfig(job *virtualServerGenerationJob) {
+	vs := job.virtualServerEx.VirtualServer
+
+	generationStart := time.Now()
 	vsc := newVirtualServerConfigurator(cnf.cfgParams, cnf.isPlus, cnf.IsResolverConfigured())
-	vsCfg, warnings := vsc.GenerateVirtualServerConfig(virtualServerEx, tlsPemFileName)
+	vsc.emulateQueue = cnf.staticCfgParams.EmulateQueue
+	vsc.upstreamNamingScheme = cnf.staticCfgParams.UpstreamNamingScheme
+	vsCfg, warnings := vsc.GenerateVirtualServerConfig(job.virtualServerEx, job.tlsPemFileName, job.policyOpts)
+	if job.fallbackWarning != nil {
+		warnings.AddWarning(vs, *job.fallbackWarning)
+	}
 
-	name := getFileNameForVirtualServer(virtualServerEx.VirtualServer)
-	content, err := cnf.templateExecutorV2.ExecuteVirtualServerTemplate(&vsCfg)
+	if cnf.staticCfgParams.EnableWarningsHeader {
+		addServerHeaders(&vsCfg, generateWarningsHeaders(warnings, cnf.staticCfgParams.EnableWarningsHeaderCodes))
+
+		if cnf.staticCfgParams.EnableWarningsHeaderCodes {
+			for _, objWarnings := range warnings {
+				for _, w := range objWarnings {
+					glog.Infof("VirtualServer %v/%v has %v warning %v (%v): %v", vs.Namespace, vs.Name, w.Severity, getWarningCode(w.Message), w.Code, w.Message)
+				}
+			}
+		}
+	}
+
+	job.vsCfg = vsCfg
+	job.warnings = warnings
+
+	if err := checkVariablesLimit(&vsCfg, cnf.cfgParams.VirtualServerMaxVariables); err != nil {
+		job.err = err
+		job.generationDuration = time.Since(generationStart)
+		return
+	}
+
+	if err := checkVariableCollisions(&vsCfg, cnf.cfgParams.MainHTTPSnippets); err != nil {
+		job.err = err
+		job.generationDuration = time.Since(generationStart)
+		return
+	}
+
+	hash, err := vsCfg.Hash()
 	if err != nil {
-		return warnings, fmt.Errorf("Error generating VirtualServer config: %v: %v", name, err)
+		glog.Warningf("Couldn't hash the config of VirtualServer %v/%v: %v", vs.Namespace, vs.Name, err)
 	}
-	cnf.nginxManager.CreateConfig(name, content)
+	job.hash = hash
+
+	if hash != "" && hash == job.prevHash {
+		job.unchanged = true
+	} else if cnf.staticCfgParams.StreamVirtualServerConfigs {
+		job.err = cnf.streamVirtualServerConfig(getFileNameForVirtualServer(vs), &vsCfg)
+		job.streamed = job.err == nil
+	} else {
+		job.content, job.err = cnf.templateExecutorV2.ExecuteVirtualServerTemplate(&vsCfg)
+	}
+	job.generationDuration = time.Since(generationStart)
+}
 
-	cnf.virtualServers[name] = virtualServerEx
+// generateVirtualServers generates the configs of the jobs concurrently by a pool of workers.
+func (cnf *Configurator) generateVirtualServers(ctx context.Context, jobs []*virtualServerGenerationJob) {
+	workers := runtime.GOMAXPROCS(0)
+	if workers > len(jobs) {
+		workers = len(jobs)
+	}
 
-	return warnings, nil
+	jobsCh := make(chan *virtualServerGenerationJob)
+	var wg sync.WaitGroup
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobsCh {
+				cnf.generateVirtualServer(ctx, job)
+			}
+		}()
+	}
+
+	for _, job := range jobs {
+		jobsCh <- job
+	}
+	close(jobsCh)
+
+	wg.Wait()
+}
+
+// applyVirtualServerGeneration writes the config generated by the job, unless it is the same as the last written config.
+// If the config couldn't be generated, the VirtualServer is quarantined. The caller must remove the unused fallback certificates.
+func (cnf *Configurator) applyVirtualServerGeneration(job *virtualServerGenerationJob) (bool, Warnings, error) {
+	vs := job.virtualServerEx.VirtualServer
+	name := getFileNameForVirtualServer(vs)
+
+	if job.err != nil {
+		// the previous config of the VirtualServer, if any, stays in place
+		err := fmt.Errorf("Error generating VirtualServer config: %v: %v", name, job.err)
+		cnf.quarantinedVirtualServers[name] = err
+		return false, job.warnings, err
+	}
+
+	// a rotated Secret keeps its file name, so NGINX must be reloaded even if the config doesn't change
+	secretsChanged := job.secretsHash != cnf.generatedVirtualServers[name].secretsHash
+
+	var configChanged bool
+	if job.streamed {
+		// a streamed config is detected as unchanged only by its hash
+		configChanged = true
+		delete(cnf.virtualServerConfigs, name)
+	} else if job.forced || !job.unchanged && !bytes.Equal(job.content, cnf.virtualServerConfigs[name]) {
+		configChanged = true
+		cnf.nginxManager.CreateConfig(name, job.content)
+		cnf.virtualServerConfigs[name] = job.content
+	}
+
+	cnf.virtualServers[name] = job.virtualServerEx
+	delete(cnf.forcedVirtualServers, name)
+	cnf.generatedVirtualServers[name] = generatedVirtualServer{cfg: job.vsCfg, hash: job.hash, secretsHash: job.secretsHash, warnings: job.warnings}
+	delete(cnf.quarantinedVirtualServers, name)
+	stats := getVirtualServerConfigStats(job.vsCfg, job.warnings)
+	stats.GenerationDuration = job.generationDuration
+	cnf.metricsCollector.UpdateResourceConfig(virtualServerResourceType, vs.Namespace, vs.Name, stats)
+
+	return configChanged || job.fallbackCertGenerated || secretsChanged, job.warnings, nil
+}
+
+// isRegenerationRequested checks if the regenerate annotation of the VirtualServer changed since the previous update.
+func isRegenerationRequested(prevVirtualServerEx *VirtualServerEx, virtualServerEx *VirtualServerEx) bool {
+	if prevVirtualServerEx == nil {
+		return false
+	}
+	return prevVirtualServerEx.VirtualServer.Annotations[RegenerateAnnotation] != virtualServerEx.VirtualServer.Annotations[RegenerateAnnotation]
+}
+
+// ForceRegeneration forces the regeneration of the configs of the Ingresses and the VirtualServers of the namespace
+// and of the VirtualServers that include VirtualServerRoutes of the namespace: their configs are written and NGINX
+// is reloaded at their next update, even if the configs didn't change.
+func (cnf *Configurator) ForceRegeneration(namespace string) {
+	for name, ingEx := range cnf.ingresses {
+		if ingEx.Ingress.Namespace == namespace {
+			delete(cnf.ingressConfigHashes, name)
+		}
+	}
+
+	for name, vsEx := range cnf.virtualServers {
+		if vsEx.VirtualServer.Namespace == namespace {
+			cnf.forcedVirtualServers[name] = true
+			continue
+		}
+
+		for _, vsr := range vsEx.VirtualServerRoutes {
+			if vsr.Namespace == namespace {
+				cnf.forcedVirtualServers[name] = true
+				break
+			}
+		}
+	}
+}
+
+// streamVirtualServerConfig executes the template of VirtualServers writing the config directly to its file.
+// If the template fails, the previous config stays in place.
+func (cnf *Configurator) streamVirtualServerConfig(name string, vsCfg *version2.VirtualServerConfig) error {
+	return cnf.nginxManager.StreamConfig(name, func(w io.Writer) error {
+		return cnf.templateExecutorV2.WriteVirtualServerTemplate(w, vsCfg)
+	})
+}
+
+func isTLSSecretReferenced(vs *conf_v1.VirtualServer) bool {
+	return vs.Spec.TLS != nil && vs.Spec.TLS.Secret != ""
+}
+
+// removeUnusedFallbackCertificates removes the fallback certificates of the hosts
+// of the VirtualServers that no longer reference a missing TLS Secret.
+func (cnf *Configurator) removeUnusedFallbackCertificates() {
+	hostsInUse := make(map[string]bool)
+
+	for _, vsEx := range cnf.virtualServers {
+		if vsEx.TLSSecret == nil && isTLSSecretReferenced(vsEx.VirtualServer) {
+			hostsInUse[vsEx.VirtualServer.Spec.Host] = true
+		}
+	}
+
+	cnf.fallbackCerts.removeUnused(hostsInUse)
+}
+
+// GetHostsWithFallbackCertificatesDueForRotation returns the hosts of the VirtualServers
+// with self-signed fallback certificates that must be regenerated.
+// Unlike other methods of the Configurator, it is safe to call it concurrently.
+func (cnf *Configurator) GetHostsWithFallbackCertificatesDueForRotation() []string {
+	return cnf.fallbackCerts.getHostsDueForRotation()
 }
 
 func (cnf *Configurator) updateTLSSecrets(ingEx *IngressEx) map[string]string {
@@ -219,12 +944,123 @@ func (cnf *Configurator) addOrUpdateJWKSecret(secret *api_v1.Secret) string {
 	return cnf.nginxManager.CreateSecret(name, data, nginx.JWKSecretFileMode)
 }
 
+// addOrUpdateJWKSecretsForVirtualServer writes the JWK Secrets referenced by the policies of a VirtualServer
+// and returns their file names keyed by the Secret keys (namespace/name).
+func (cnf *Configurator) addOrUpdateJWKSecretsForVirtualServer(virtualServerEx *VirtualServerEx) map[string]string {
+	fileNames := make(map[string]string)
+
+	for key, secret := range virtualServerEx.JWTKeys {
+		fileNames[key] = cnf.addOrUpdateJWKSecret(secret)
+	}
+
+	return fileNames
+}
+
+// addOrUpdateEgressTLSSecretsForVirtualServer writes the TLS Secrets referenced by the egressMTLS policies of a VirtualServer
+// and returns their file names keyed by the Secret keys (namespace/name).
+func (cnf *Configurator) addOrUpdateEgressTLSSecretsForVirtualServer(virtualServerEx *VirtualServerEx) map[string]string {
+	fileNames := make(map[string]string)
+
+	for key, secret := range virtualServerEx.EgressTLSSecrets {
+		fileNames[key] = cnf.addOrUpdateTLSSecret(secret)
+	}
+
+	return fileNames
+}
+
+// addOrUpdateCASecretsForVirtualServer writes the CA Secrets referenced by the egressMTLS policies of a VirtualServer
+// and returns their file names keyed by the Secret keys (namespace/name).
+func (cnf *Configurator) addOrUpdateCASecretsForVirtualServer(virtualServerEx *VirtualServerEx) map[string]string {
+	fileNames := make(map[string]string)
+
+	for key, secret := range virtualServerEx.TrustedCASecrets {
+		fileNames[key] = cnf.addOrUpdateCASecret(secret)
+	}
+
+	return fileNames
+}
+
+func (cnf *Configurator) addOrUpdateCASecret(secret *api_v1.Secret) string {
+	name := objectMetaToFileName(&secret.ObjectMeta)
+	data := secret.Data[CAKey]
+	return cnf.nginxManager.CreateSecret(name, data, nginx.TLSSecretFileMode)
+}
+
+// AddOrUpdateCASecret adds or updates a file with the content of the CA Secret.
+func (cnf *Configurator) AddOrUpdateCASecret(secret *api_v1.Secret) {
+	cnf.addOrUpdateCASecret(secret)
+}
+
+// addOrUpdateHtpasswdSecretsForVirtualServer writes the htpasswd Secrets referenced by the basicAuth policies of a VirtualServer
+// and returns their file names keyed by the Secret keys (namespace/name).
+func (cnf *Configurator) addOrUpdateHtpasswdSecretsForVirtualServer(virtualServerEx *VirtualServerEx) map[string]string {
+	fileNames := make(map[string]string)
+
+	for key, secret := range virtualServerEx.HtpasswdSecrets {
+		fileNames[key] = cnf.addOrUpdateHtpasswdSecret(secret)
+	}
+
+	return fileNames
+}
+
+func (cnf *Configurator) addOrUpdateHtpasswdSecret(secret *api_v1.Secret) string {
+	name := objectMetaToFileName(&secret.ObjectMeta)
+	data := secret.Data[HtpasswdKey]
+	return cnf.nginxManager.CreateSecret(name, data, nginx.HtpasswdSecretFileMode)
+}
+
+// AddOrUpdateHtpasswdSecret adds or updates a file with the content of the htpasswd Secret.
+func (cnf *Configurator) AddOrUpdateHtpasswdSecret(secret *api_v1.Secret) {
+	cnf.addOrUpdateHtpasswdSecret(secret)
+}
+
+// getOIDCClientSecretsForVirtualServer returns the client secrets of the OIDC Secrets referenced by the policies
+// of a VirtualServer keyed by the Secret keys (namespace/name).
+func getOIDCClientSecretsForVirtualServer(virtualServerEx *VirtualServerEx) map[string]string {
+	clientSecrets := make(map[string]string)
+
+	for key, secret := range virtualServerEx.OIDCSecrets {
+		clientSecrets[key] = string(secret.Data[ClientSecretKey])
+	}
+
+	return clientSecrets
+}
+
+// hashVirtualServerSecrets returns the hash of the content of the Secrets which a VirtualServer references by files.
+func hashVirtualServerSecrets(virtualServerEx *VirtualServerEx) string {
+	// the keys of the maps are sorted by json.Marshal, so the hash doesn't depend on the iteration order
+	secrets := map[string]map[string][]byte{}
+	if virtualServerEx.TLSSecret != nil {
+		secrets["tls"] = virtualServerEx.TLSSecret.Data
+	}
+	for kind, refs := range map[string]map[string]*api_v1.Secret{
+		"jwk":      virtualServerEx.JWTKeys,
+		"egress":   virtualServerEx.EgressTLSSecrets,
+		"ca":       virtualServerEx.TrustedCASecrets,
+		"htpasswd": virtualServerEx.HtpasswdSecrets,
+	} {
+		for key, secret := range refs {
+			secrets[kind+"/"+key] = secret.Data
+		}
+	}
+
+	data, err := json.Marshal(secrets)
+	if err != nil {
+		glog.Warningf("Couldn't hash the Secrets of VirtualServer %v/%v: %v", virtualServerEx.VirtualServer.Namespace, virtualServerEx.VirtualServer.Name, err)
+		return ""
+	}
+
+	return fmt.Sprintf("%x", sha256.Sum256(data))
+}
+
 func (cnf *Configurator) AddOrUpdateJWKSecret(secret *api_v1.Secret) {
 	cnf.addOrUpdateJWKSecret(secret)
 }
 
 // AddOrUpdateTLSSecret adds or updates a file with the content of the TLS secret.
 func (cnf *Configurator) AddOrUpdateTLSSecret(secret *api_v1.Secret, ingExes []IngressEx, mergeableIngresses []MergeableIngresses, virtualServerExes []*VirtualServerEx) error {
+	snapshots := cnf.snapshotVirtualServers(virtualServerExes)
+
 	cnf.addOrUpdateTLSSecret(secret)
 	for i := range ingExes {
 		err := cnf.addOrUpdateIngress(&ingExes[i])
@@ -240,16 +1076,11 @@ func (cnf *Configurator) AddOrUpdateTLSSecret(secret *api_v1.Secret, ingExes []I
 		}
 	}
 
-	for _, vsEx := range virtualServerExes {
-		// It is safe to ignore warnings here as no new warnings should appear when adding or updating a secret
-		_, err := cnf.addOrUpdateVirtualServer(vsEx)
-		if err != nil {
-			return fmt.Errorf("Error adding or updating VirtualServer %v/%v: %v", vsEx.VirtualServer.Namespace, vsEx.VirtualServer.Name, err)
-		}
-	}
+	// It is safe to ignore warnings here as no new warnings should appear when adding or updating a secret
+	changed, _ := cnf.addOrUpdateVirtualServers(virtualServerExes)
 
-	if err := cnf.nginxManager.Reload(); err != nil {
-		return fmt.Errorf("Error when reloading NGINX when updating Secret: %v", err)
+	if err := cnf.commitConfigChanges(snapshots, changed, true); err != nil {
+		return fmt.Errorf("Error when updating Secret: %v", err)
 	}
 
 	return nil
@@ -269,8 +1100,8 @@ func (cnf *Configurator) AddOrUpdateSpecialTLSSecrets(secret *api_v1.Secret, sec
 		cnf.nginxManager.CreateSecret(secretName, data, nginx.TLSSecretFileMode)
 	}
 
-	if err := cnf.nginxManager.Reload(); err != nil {
-		return fmt.Errorf("Error when reloading NGINX when updating the special Secrets: %v", err)
+	if err := cnf.commitConfigChanges(nil, nil, true); err != nil {
+		return fmt.Errorf("Error when updating the special Secrets: %v", err)
 	}
 
 	return nil
@@ -290,6 +1121,8 @@ func GenerateCertAndKeyFileContent(secret *api_v1.Secret) []byte {
 // DeleteSecret deletes the file associated with the secret and the configuration files for Ingress and VirtualServer resources.
 // NGINX is reloaded only when the total number of the resources > 0.
 func (cnf *Configurator) DeleteSecret(key string, ingExes []IngressEx, mergeableIngresses []MergeableIngresses, virtualServerExes []*VirtualServerEx) error {
+	snapshots := cnf.snapshotVirtualServers(virtualServerExes)
+
 	cnf.nginxManager.DeleteSecret(keyToFileName(key))
 
 	for i := range ingExes {
@@ -306,17 +1139,12 @@ func (cnf *Configurator) DeleteSecret(key string, ingExes []IngressEx, mergeable
 		}
 	}
 
-	for _, vsEx := range virtualServerExes {
-		// It is safe to ignore warnings here as no new warnings should appear when deleting a secret
-		_, err := cnf.addOrUpdateVirtualServer(vsEx)
-		if err != nil {
-			return fmt.Errorf("Error adding or updating VirtualServer %v/%v: %v", vsEx.VirtualServer.Namespace, vsEx.VirtualServer.Name, err)
-		}
-	}
+	// It is safe to ignore warnings here as no new warnings should appear when deleting a secret
+	changed, _ := cnf.addOrUpdateVirtualServers(virtualServerExes)
 
 	if len(ingExes)+len(mergeableIngresses)+len(virtualServerExes) > 0 {
-		if err := cnf.nginxManager.Reload(); err != nil {
-			return fmt.Errorf("Error when reloading NGINX when deleting Secret %v: %v", key, err)
+		if err := cnf.commitConfigChanges(snapshots, changed, true); err != nil {
+			return fmt.Errorf("Error when deleting Secret %v: %v", key, err)
 		}
 	}
 
@@ -330,22 +1158,43 @@ func (cnf *Configurator) DeleteIngress(key string) error {
 
 	delete(cnf.ingresses, name)
 	delete(cnf.minions, name)
+	delete(cnf.ingressConfigHashes, name)
+	// the deletion replaces the staged config, so the Ingress is not restored if the test fails
+	delete(cnf.stagedIngresses, name)
+	cnf.deleteResourceConfigMetrics(ingressResourceType, key)
 
-	if err := cnf.nginxManager.Reload(); err != nil {
+	if err := cnf.commitConfigChanges(nil, nil, true); err != nil {
 		return fmt.Errorf("Error when removing ingress %v: %v", key, err)
 	}
 
 	return nil
 }
 
+// deleteResourceConfigMetrics deletes the metrics of the configuration of the resource with the key.
+func (cnf *Configurator) deleteResourceConfigMetrics(resourceType string, key string) {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		glog.Errorf("Error deleting the config metrics of %v %v: %v", resourceType, key, err)
+		return
+	}
+	cnf.metricsCollector.DeleteResourceConfig(resourceType, namespace, name)
+}
+
 // DeleteVirtualServer deletes NGINX configuration for the VirtualServer resource.
 func (cnf *Configurator) DeleteVirtualServer(key string) error {
 	name := getFileNameForVirtualServerFromKey(key)
 	cnf.nginxManager.DeleteConfig(name)
 
 	delete(cnf.virtualServers, name)
-
-	if err := cnf.nginxManager.Reload(); err != nil {
+	delete(cnf.virtualServerConfigs, name)
+	delete(cnf.generatedVirtualServers, name)
+	delete(cnf.quarantinedVirtualServers, name)
+	delete(cnf.forcedVirtualServers, name)
+	cnf.removeUnusedFallbackCertificates()
+	cnf.deleteResourceConfigMetrics(virtualServerResourceType, key)
+
+	// the config of a removed VirtualServer is not restored if the test fails
+	if err := cnf.commitConfigChanges(nil, nil, true); err != nil {
 		return fmt.Errorf("Error when removing VirtualServer %v: %v", key, err)
 	}
 
@@ -373,11 +1222,15 @@ func (cnf *Configurator) UpdateEndpoints(ingExes []*IngressEx) error {
 
 	if cnf.isPlus && !reloadPlus {
 		glog.V(3).Info("No need to reload nginx")
+		// the configs are promoted, so that NGINX uses the new endpoints after the next reload
+		if err := cnf.commitConfigChanges(nil, nil, false); err != nil {
+			return fmt.Errorf("Error when updating endpoints: %v", err)
+		}
 		return nil
 	}
 
-	if err := cnf.nginxManager.Reload(); err != nil {
-		return fmt.Errorf("Error reloading NGINX when updating endpoints: %v", err)
+	if err := cnf.commitConfigChanges(nil, nil, true); err != nil {
+		return fmt.Errorf("Error when updating endpoints: %v", err)
 	}
 
 	return nil
@@ -406,25 +1259,34 @@ func (cnf *Configurator) UpdateEndpointsMergeableIngress(mergeableIngresses []*M
 
 	if cnf.isPlus && !reloadPlus {
 		glog.V(3).Info("No need to reload nginx")
+		// the configs are promoted, so that NGINX uses the new endpoints after the next reload
+		if err := cnf.commitConfigChanges(nil, nil, false); err != nil {
+			return fmt.Errorf("Error when updating endpoints for %v: %v", mergeableIngresses, err)
+		}
 		return nil
 	}
 
-	if err := cnf.nginxManager.Reload(); err != nil {
-		return fmt.Errorf("Error reloading NGINX when updating endpoints for %v: %v", mergeableIngresses, err)
+	if err := cnf.commitConfigChanges(nil, nil, true); err != nil {
+		return fmt.Errorf("Error when updating endpoints for %v: %v", mergeableIngresses, err)
 	}
 
 	return nil
 }
 
-// UpdateEndpointsForVirtualServers updates endpoints in NGINX configuration for the s resources.
+// UpdateEndpointsForVirtualServers updates endpoints in NGINX configuration for the VirtualServer resources.
 func (cnf *Configurator) UpdateEndpointsForVirtualServers(virtualServerExes []*VirtualServerEx) error {
+	snapshots := cnf.snapshotVirtualServers(virtualServerExes)
 	reloadPlus := false
+	var changed []string
 
 	for _, vs := range virtualServerExes {
-		// It is safe to ignore warnings here as no new warnings should appear when updating Endpoints for VirtualServers
-		_, err := cnf.addOrUpdateVirtualServer(vs)
+		vsChanged, err := cnf.updateEndpointsForVirtualServer(vs)
 		if err != nil {
-			return fmt.Errorf("Error adding or updating VirtualServer %v/%v: %v", vs.VirtualServer.Namespace, vs.VirtualServer.Name, err)
+			glog.Errorf("Error adding or updating VirtualServer %v/%v: %v", vs.VirtualServer.Namespace, vs.VirtualServer.Name, err)
+			continue
+		}
+		if vsChanged {
+			changed = append(changed, getFileNameForVirtualServer(vs.VirtualServer))
 		}
 
 		if cnf.isPlus {
@@ -436,20 +1298,97 @@ func (cnf *Configurator) UpdateEndpointsForVirtualServers(virtualServerExes []*V
 		}
 	}
 
-	if cnf.isPlus && !reloadPlus {
+	if len(changed) == 0 && !reloadPlus {
 		glog.V(3).Info("No need to reload nginx")
 		return nil
 	}
 
-	if err := cnf.nginxManager.Reload(); err != nil {
-		return fmt.Errorf("Error reloading NGINX when updating endpoints: %v", err)
+	// with NGINX Plus, the configs are promoted without a reload, so that NGINX uses the new endpoints after the next reload
+	if err := cnf.commitConfigChanges(snapshots, changed, !cnf.isPlus || reloadPlus); err != nil {
+		return fmt.Errorf("Error when updating endpoints: %v", err)
 	}
 
 	return nil
 }
 
+// updateEndpointsForVirtualServer updates the upstreams in the last generated config of a VirtualServer with the endpoints
+// of the VirtualServerEx, without generating the rest of the config. The whole config is generated if the VirtualServer
+// doesn't have a generated config, is quarantined, its regeneration is forced or its upstreams changed, for example,
+// because the VirtualServer was updated.
+// It returns false if the config didn't change, so NGINX doesn't need to be reloaded.
+func (cnf *Configurator) updateEndpointsForVirtualServer(virtualServerEx *VirtualServerEx) (bool, error) {
+	name := getFileNameForVirtualServer(virtualServerEx.VirtualServer)
+
+	generated, exists := cnf.generatedVirtualServers[name]
+	if _, quarantined := cnf.quarantinedVirtualServers[name]; !exists || quarantined || cnf.forcedVirtualServers[name] {
+		// It is safe to ignore warnings here as no new warnings should appear when updating Endpoints for VirtualServers
+		changed, _, err := cnf.addOrUpdateVirtualServer(virtualServerEx)
+		return changed, err
+	}
+
+	generationStart := time.Now()
+	vsc := newVirtualServerConfigurator(cnf.cfgParams, cnf.isPlus, cnf.IsResolverConfigured())
+	vsc.emulateQueue = cnf.staticCfgParams.EmulateQueue
+	vsc.upstreamNamingScheme = cnf.staticCfgParams.UpstreamNamingScheme
+	upstreams := vsc.generateUpstreams(virtualServerEx)
+
+	if !haveSameUpstreamNames(upstreams, generated.cfg.Upstreams) {
+		changed, _, err := cnf.addOrUpdateVirtualServer(virtualServerEx)
+		return changed, err
+	}
+
+	vsCfg := generated.cfg
+	vsCfg.Upstreams = upstreams
+
+	hash, err := vsCfg.Hash()
+	if err != nil {
+		glog.Warningf("Couldn't hash the config of VirtualServer %v/%v: %v", virtualServerEx.VirtualServer.Namespace, virtualServerEx.VirtualServer.Name, err)
+	} else if hash == generated.hash {
+		glog.V(3).Infof("The upstreams of VirtualServer %v/%v didn't change", virtualServerEx.VirtualServer.Namespace, virtualServerEx.VirtualServer.Name)
+		return false, nil
+	}
+
+	if cnf.staticCfgParams.StreamVirtualServerConfigs {
+		if err := cnf.streamVirtualServerConfig(name, &vsCfg); err != nil {
+			return false, fmt.Errorf("Error generating VirtualServer config: %v: %v", name, err)
+		}
+		delete(cnf.virtualServerConfigs, name)
+	} else {
+		content, err := cnf.templateExecutorV2.ExecuteVirtualServerTemplate(&vsCfg)
+		if err != nil {
+			return false, fmt.Errorf("Error generating VirtualServer config: %v: %v", name, err)
+		}
+		cnf.nginxManager.CreateConfig(name, content)
+		cnf.virtualServerConfigs[name] = content
+	}
+	generationDuration := time.Since(generationStart)
+
+	cnf.virtualServers[name] = virtualServerEx
+	cnf.generatedVirtualServers[name] = generatedVirtualServer{cfg: vsCfg, hash: hash, secretsHash: generated.secretsHash, warnings: generated.warnings}
+	stats := getVirtualServerConfigStats(vsCfg, generated.warnings)
+	stats.GenerationDuration = generationDuration
+	cnf.metricsCollector.UpdateResourceConfig(virtualServerResourceType, virtualServerEx.VirtualServer.Namespace, virtualServerEx.VirtualServer.Name, stats)
+
+	return true, nil
+}
+
+// haveSameUpstreamNames checks if the upstreams have the same names in the same order.
+func haveSameUpstreamNames(upstreams []version2.Upstream, otherUpstreams []version2.Upstream) bool {
+	if len(upstreams) != len(otherUpstreams) {
+		return false
+	}
+
+	for i := range upstreams {
+		if upstreams[i].Name != otherUpstreams[i].Name {
+			return false
+		}
+	}
+
+	return true
+}
+
 func (cnf *Configurator) updatePlusEndpointsForVirtualServer(virtualServerEx *VirtualServerEx) error {
-	upstreams := createUpstreamsForPlus(virtualServerEx, cnf.cfgParams)
+	upstreams := createUpstreamsForPlus(virtualServerEx, cnf.cfgParams, cnf.staticCfgParams.UpstreamNamingScheme)
 	for _, upstream := range upstreams {
 		serverCfg := createUpstreamServersConfigForPlus(upstream)
 
@@ -541,6 +1480,11 @@ func (cnf *Configurator) UpdateConfig(cfgParams *ConfigParams, ingExes []*Ingres
 		}
 	}
 
+	// an invalid template doesn't prevent the rest of the ConfigMap from being applied
+	if _, err := cnf.updateVirtualServerTemplate(cfgParams.VirtualServerTemplate); err != nil {
+		glog.Errorf("The previous virtualserver template is kept: %v", err)
+	}
+
 	mainCfg := GenerateNginxMainConfig(cnf.staticCfgParams, cfgParams)
 	mainCfgContent, err := cnf.templateExecutor.ExecuteMainConfigTemplate(mainCfg)
 	if err != nil {
@@ -558,13 +1502,9 @@ func (cnf *Configurator) UpdateConfig(cfgParams *ConfigParams, ingExes []*Ingres
 			return allWarnings, err
 		}
 	}
-	for _, vsEx := range virtualServerExes {
-		warnings, err := cnf.addOrUpdateVirtualServer(vsEx)
-		if err != nil {
-			return allWarnings, err
-		}
-		allWarnings.Add(warnings)
-	}
+	snapshots := cnf.snapshotVirtualServers(virtualServerExes)
+	changed, warnings := cnf.addOrUpdateVirtualServers(virtualServerExes)
+	allWarnings.Add(warnings)
 
 	if mainCfg.OpenTracingLoadModule {
 		if err := cnf.addOrUpdateOpenTracingTracerConfig(mainCfg.OpenTracingTracerConfig); err != nil {
@@ -573,13 +1513,67 @@ func (cnf *Configurator) UpdateConfig(cfgParams *ConfigParams, ingExes []*Ingres
 	}
 
 	cnf.nginxManager.SetOpenTracing(mainCfg.OpenTracingLoadModule)
-	if err := cnf.nginxManager.Reload(); err != nil {
+	if err := cnf.commitConfigChanges(snapshots, changed, true); err != nil {
 		return allWarnings, fmt.Errorf("Error when updating config from ConfigMap: %v", err)
 	}
 
 	return allWarnings, nil
 }
 
+// UpdateVirtualServerTemplateFromFile re-reads the template of VirtualServers from the file of VirtualServerTemplatePath
+// and, if the template changed, updates the configs of the VirtualServers and reloads NGINX. The template of the ConfigMap
+// takes precedence over the file. If the template is invalid, the previous template is kept and an error is returned.
+func (cnf *Configurator) UpdateVirtualServerTemplateFromFile(virtualServerExes []*VirtualServerEx) error {
+	changed, err := cnf.updateVirtualServerTemplate(cnf.cfgParams.VirtualServerTemplate)
+	if err != nil {
+		return err
+	}
+	if !changed {
+		return nil
+	}
+
+	glog.V(3).Infof("The virtualserver template %v changed, updating VirtualServers", cnf.staticCfgParams.VirtualServerTemplatePath)
+
+	return cnf.UpdateVirtualServers(virtualServerExes)
+}
+
+// updateVirtualServerTemplate updates the template of VirtualServers to the template of the ConfigMap or, without it,
+// to the template of the file of VirtualServerTemplatePath, if the template changed. The template is tested before it is used:
+// if it is invalid, the previous template is kept. It returns true if the template changed.
+func (cnf *Configurator) updateVirtualServerTemplate(configMapTemplate *string) (bool, error) {
+	vsTemplate := configMapTemplate
+	if vsTemplate == nil && cnf.staticCfgParams.VirtualServerTemplatePath != "" {
+		content, err := ioutil.ReadFile(cnf.staticCfgParams.VirtualServerTemplatePath)
+		if err != nil {
+			return false, fmt.Errorf("Error when reading the virtualserver template: %v", err)
+		}
+		fileTemplate := string(content)
+		vsTemplate = &fileTemplate
+	}
+
+	if vsTemplate == nil || (cnf.virtualServerTemplate != nil && *vsTemplate == *cnf.virtualServerTemplate) {
+		return false, nil
+	}
+
+	if err := cnf.templateExecutorV2.UpdateVirtualServerTemplate(vsTemplate, cnf.nginxManager.TestVirtualServerConfig); err != nil {
+		return false, fmt.Errorf("Error when parsing the virtualserver template: %v", err)
+	}
+
+	cnf.virtualServerTemplate = vsTemplate
+	cnf.clearVirtualServerHashes()
+
+	return true, nil
+}
+
+// clearVirtualServerHashes makes the configs of all VirtualServers written again, even if the generated configs don't change,
+// for example, after the template changes.
+func (cnf *Configurator) clearVirtualServerHashes() {
+	for name, generated := range cnf.generatedVirtualServers {
+		generated.hash = ""
+		cnf.generatedVirtualServers[name] = generated
+	}
+}
+
 func keyToFileName(key string) string {
 	return strings.Replace(key, "/", "-", -1)
 }
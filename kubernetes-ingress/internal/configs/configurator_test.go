@@ -1,12 +1,18 @@
 package configs
 
 import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
 	"testing"
 
 	"github.com/nginxinc/kubernetes-ingress/internal/configs/version1"
 	"github.com/nginxinc/kubernetes-ingress/internal/configs/version2"
+	"github.com/nginxinc/kubernetes-ingress/internal/metrics/collectors"
 	"github.com/nginxinc/kubernetes-ingress/internal/nginx"
 	conf_v1 "github.com/nginxinc/kubernetes-ingress/pkg/apis/configuration/v1"
+	api_v1 "k8s.io/api/core/v1"
 	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -17,6 +23,8 @@ func createTestStaticConfigParams() *StaticConfigParams {
 		NginxStatus:                    true,
 		NginxStatusAllowCIDRs:          []string{"127.0.0.1"},
 		NginxStatusPort:                8080,
+		NginxPlusDashboardLocation:     "/dashboard.html",
+		NginxPlusDashboardAllowCIDRs:   []string{"127.0.0.1"},
 		StubStatusOverUnixSocketForOSS: false,
 	}
 }
@@ -34,7 +42,7 @@ func createTestConfigurator() (*Configurator, error) {
 
 	manager := nginx.NewFakeManager("/etc/nginx")
 
-	return NewConfigurator(manager, createTestStaticConfigParams(), NewDefaultConfigParams(), templateExecutor, templateExecutorV2, false, false), nil
+	return NewConfigurator(manager, createTestStaticConfigParams(), NewDefaultConfigParams(), templateExecutor, templateExecutorV2, false, false, collectors.NewConfigFakeCollector()), nil
 }
 
 func createTestConfiguratorInvalidIngressTemplate() (*Configurator, error) {
@@ -50,7 +58,48 @@ func createTestConfiguratorInvalidIngressTemplate() (*Configurator, error) {
 
 	manager := nginx.NewFakeManager("/etc/nginx")
 
-	return NewConfigurator(manager, createTestStaticConfigParams(), NewDefaultConfigParams(), templateExecutor, &version2.TemplateExecutor{}, false, false), nil
+	return NewConfigurator(manager, createTestStaticConfigParams(), NewDefaultConfigParams(), templateExecutor, &version2.TemplateExecutor{}, false, false, collectors.NewConfigFakeCollector()), nil
+}
+
+func createTestConfiguratorInvalidVirtualServerTemplate(t *testing.T) *Configurator {
+	templateExecutor, err := version1.NewTemplateExecutor("version1/nginx-plus.tmpl", "version1/nginx-plus.ingress.tmpl")
+	if err != nil {
+		t.Fatalf("Failed to create a template executor: %v", err)
+	}
+
+	file, err := ioutil.TempFile("", "virtualserver.tmpl")
+	if err != nil {
+		t.Fatalf("Failed to create a template file: %v", err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	if _, err := file.WriteString("{{.Server.This.Field.Does.Not.Exist}}"); err != nil {
+		t.Fatalf("Failed to write a template file: %v", err)
+	}
+
+	templateExecutorV2, err := version2.NewTemplateExecutor(file.Name())
+	if err != nil {
+		t.Fatalf("Failed to create a template executor: %v", err)
+	}
+
+	manager := nginx.NewFakeManager("/etc/nginx")
+
+	return NewConfigurator(manager, createTestStaticConfigParams(), NewDefaultConfigParams(), templateExecutor, templateExecutorV2, false, false, collectors.NewConfigFakeCollector())
+}
+
+func createTestVirtualServerEx() *VirtualServerEx {
+	return &VirtualServerEx{
+		VirtualServer: &conf_v1.VirtualServer{
+			ObjectMeta: meta_v1.ObjectMeta{
+				Namespace: "default",
+				Name:      "cafe",
+			},
+			Spec: conf_v1.VirtualServerSpec{
+				Host: "cafe.example.com",
+			},
+		},
+	}
 }
 
 func TestAddOrUpdateIngress(t *testing.T) {
@@ -215,3 +264,677 @@ func TestGetFileNameForVirtualServerFromKey(t *testing.T) {
 		t.Errorf("getFileNameForVirtualServerFromKey returned %v, but expected %v", result, expected)
 	}
 }
+
+func TestAddOrUpdateVirtualServer(t *testing.T) {
+	cnf, err := createTestConfigurator()
+	if err != nil {
+		t.Fatalf("Failed to create a test configurator: %v", err)
+	}
+
+	vsEx := createTestVirtualServerEx()
+
+	_, err = cnf.AddOrUpdateVirtualServer(vsEx)
+	if err != nil {
+		t.Errorf("AddOrUpdateVirtualServer returned:  \n%v, but expected: \n%v", err, nil)
+	}
+
+	if quarantineErr := cnf.GetVirtualServerQuarantineError(vsEx.VirtualServer); quarantineErr != nil {
+		t.Errorf("GetVirtualServerQuarantineError returned %v for a successfully added VirtualServer", quarantineErr)
+	}
+}
+
+func TestAddOrUpdateVirtualServerWithUnchangedConfig(t *testing.T) {
+	cnf, err := createTestConfigurator()
+	if err != nil {
+		t.Fatalf("Failed to create a test configurator: %v", err)
+	}
+
+	vsEx := createTestVirtualServerEx()
+
+	changed, _, err := cnf.addOrUpdateVirtualServer(vsEx)
+	if err != nil {
+		t.Fatalf("addOrUpdateVirtualServer returned an unexpected error: %v", err)
+	}
+	if !changed {
+		t.Errorf("addOrUpdateVirtualServer returned false for a new VirtualServer, but expected true")
+	}
+
+	changed, _, err = cnf.addOrUpdateVirtualServer(createTestVirtualServerEx())
+	if err != nil {
+		t.Fatalf("addOrUpdateVirtualServer returned an unexpected error: %v", err)
+	}
+	if changed {
+		t.Errorf("addOrUpdateVirtualServer returned true for an unchanged VirtualServer, but expected false")
+	}
+
+	updatedVsEx := createTestVirtualServerEx()
+	updatedVsEx.VirtualServer.Spec.Host = "tea.example.com"
+
+	changed, _, err = cnf.addOrUpdateVirtualServer(updatedVsEx)
+	if err != nil {
+		t.Fatalf("addOrUpdateVirtualServer returned an unexpected error: %v", err)
+	}
+	if !changed {
+		t.Errorf("addOrUpdateVirtualServer returned false for an updated VirtualServer, but expected true")
+	}
+}
+
+func TestAddOrUpdateVirtualServerWithRotatedSecret(t *testing.T) {
+	cnf, err := createTestConfigurator()
+	if err != nil {
+		t.Fatalf("Failed to create a test configurator: %v", err)
+	}
+
+	createVsEx := func(htpasswd string) *VirtualServerEx {
+		vsEx := createTestVirtualServerEx()
+		vsEx.HtpasswdSecrets = map[string]*api_v1.Secret{
+			"default/htpasswd": {
+				ObjectMeta: meta_v1.ObjectMeta{Name: "htpasswd", Namespace: "default"},
+				Data:       map[string][]byte{HtpasswdKey: []byte(htpasswd)},
+			},
+		}
+		return vsEx
+	}
+
+	_, _, err = cnf.addOrUpdateVirtualServer(createVsEx("user:password"))
+	if err != nil {
+		t.Fatalf("addOrUpdateVirtualServer returned an unexpected error: %v", err)
+	}
+
+	changed, _, err := cnf.addOrUpdateVirtualServer(createVsEx("user:password"))
+	if err != nil {
+		t.Fatalf("addOrUpdateVirtualServer returned an unexpected error: %v", err)
+	}
+	if changed {
+		t.Errorf("addOrUpdateVirtualServer returned true for an unchanged Secret, but expected false")
+	}
+
+	changed, _, err = cnf.addOrUpdateVirtualServer(createVsEx("user:rotated-password"))
+	if err != nil {
+		t.Fatalf("addOrUpdateVirtualServer returned an unexpected error: %v", err)
+	}
+	if !changed {
+		t.Errorf("addOrUpdateVirtualServer returned false for a rotated Secret, but expected true")
+	}
+}
+
+func TestAddOrUpdateVirtualServerWithStreamedConfig(t *testing.T) {
+	cnf, err := createTestConfigurator()
+	if err != nil {
+		t.Fatalf("Failed to create a test configurator: %v", err)
+	}
+	cnf.staticCfgParams.StreamVirtualServerConfigs = true
+
+	changed, _, err := cnf.addOrUpdateVirtualServer(createTestVirtualServerEx())
+	if err != nil {
+		t.Fatalf("addOrUpdateVirtualServer returned an unexpected error: %v", err)
+	}
+	if !changed {
+		t.Errorf("addOrUpdateVirtualServer returned false for a new VirtualServer, but expected true")
+	}
+	if _, exists := cnf.virtualServerConfigs["vs_default_cafe"]; exists {
+		t.Errorf("addOrUpdateVirtualServer kept the streamed config in memory")
+	}
+
+	changed, _, err = cnf.addOrUpdateVirtualServer(createTestVirtualServerEx())
+	if err != nil {
+		t.Fatalf("addOrUpdateVirtualServer returned an unexpected error: %v", err)
+	}
+	if changed {
+		t.Errorf("addOrUpdateVirtualServer returned true for an unchanged VirtualServer, but expected false")
+	}
+}
+
+func TestAddOrUpdateVirtualServers(t *testing.T) {
+	cnf, err := createTestConfigurator()
+	if err != nil {
+		t.Fatalf("Failed to create a test configurator: %v", err)
+	}
+
+	var vsExes []*VirtualServerEx
+	for i := 0; i < 20; i++ {
+		vsEx := createTestVirtualServerEx()
+		vsEx.VirtualServer.Name = fmt.Sprintf("cafe-%d", i)
+		vsEx.VirtualServer.Spec.Host = fmt.Sprintf("cafe-%d.example.com", i)
+		vsEx.VirtualServer.Spec.TLS = &conf_v1.TLS{Secret: "missing-secret"}
+		vsExes = append(vsExes, vsEx)
+	}
+
+	changed, warnings := cnf.addOrUpdateVirtualServers(vsExes)
+	if len(changed) != len(vsExes) {
+		t.Errorf("addOrUpdateVirtualServers returned %d changed VirtualServers for new VirtualServers, but expected %d", len(changed), len(vsExes))
+	}
+	if len(warnings) != len(vsExes) {
+		t.Errorf("addOrUpdateVirtualServers returned the warnings of %d VirtualServers, but expected %d", len(warnings), len(vsExes))
+	}
+
+	for _, vsEx := range vsExes {
+		name := getFileNameForVirtualServer(vsEx.VirtualServer)
+		if !strings.Contains(string(cnf.virtualServerConfigs[name]), vsEx.VirtualServer.Spec.Host) {
+			t.Errorf("addOrUpdateVirtualServers didn't generate the config of VirtualServer %s", vsEx.VirtualServer.Name)
+		}
+	}
+
+	// the fallback certificates of all VirtualServers must be kept
+	if hosts := len(cnf.fallbackCerts.certs); hosts != len(vsExes) {
+		t.Errorf("addOrUpdateVirtualServers kept the fallback certificates of %d hosts, but expected %d", hosts, len(vsExes))
+	}
+
+	changed, _ = cnf.addOrUpdateVirtualServers(vsExes)
+	if len(changed) != 0 {
+		t.Errorf("addOrUpdateVirtualServers returned %d changed VirtualServers for unchanged VirtualServers, but expected 0", len(changed))
+	}
+}
+
+func TestUpdateEndpointsForVirtualServer(t *testing.T) {
+	cnf, err := createTestConfigurator()
+	if err != nil {
+		t.Fatalf("Failed to create a test configurator: %v", err)
+	}
+
+	vsEx := createTestVirtualServerEx()
+	vsEx.VirtualServer.Spec.Upstreams = []conf_v1.Upstream{
+		{
+			Name:    "tea",
+			Service: "tea-svc",
+			Port:    80,
+		},
+	}
+	vsEx.Endpoints = map[string][]string{
+		"default/tea-svc:80": {"10.0.0.1:80"},
+	}
+
+	if _, err := cnf.AddOrUpdateVirtualServer(vsEx); err != nil {
+		t.Fatalf("AddOrUpdateVirtualServer returned an unexpected error: %v", err)
+	}
+
+	changed, err := cnf.updateEndpointsForVirtualServer(vsEx)
+	if err != nil {
+		t.Errorf("updateEndpointsForVirtualServer returned an unexpected error: %v", err)
+	}
+	if changed {
+		t.Errorf("updateEndpointsForVirtualServer returned true for the same endpoints, but expected false")
+	}
+
+	updatedVsEx := createTestVirtualServerEx()
+	updatedVsEx.VirtualServer.Spec.Upstreams = vsEx.VirtualServer.Spec.Upstreams
+	updatedVsEx.Endpoints = map[string][]string{
+		"default/tea-svc:80": {"10.0.0.2:80"},
+	}
+
+	changed, err = cnf.updateEndpointsForVirtualServer(updatedVsEx)
+	if err != nil {
+		t.Errorf("updateEndpointsForVirtualServer returned an unexpected error: %v", err)
+	}
+	if !changed {
+		t.Errorf("updateEndpointsForVirtualServer returned false for new endpoints, but expected true")
+	}
+
+	content := string(cnf.virtualServerConfigs["vs_default_cafe"])
+	if !strings.Contains(content, "server 10.0.0.2:80") || strings.Contains(content, "server 10.0.0.1:80") {
+		t.Errorf("updateEndpointsForVirtualServer didn't update the servers of the upstream in the config:\n%s", content)
+	}
+}
+
+func TestAddOrUpdateVirtualServerQuarantinesWithInvalidTemplate(t *testing.T) {
+	cnf := createTestConfiguratorInvalidVirtualServerTemplate(t)
+
+	vsEx := createTestVirtualServerEx()
+
+	_, err := cnf.AddOrUpdateVirtualServer(vsEx)
+	if err == nil {
+		t.Errorf("AddOrUpdateVirtualServer returned\n%v, but expected \n%v", nil, "template execution error")
+	}
+
+	if quarantineErr := cnf.GetVirtualServerQuarantineError(vsEx.VirtualServer); quarantineErr == nil {
+		t.Errorf("GetVirtualServerQuarantineError returned nil for a VirtualServer which config couldn't be generated")
+	}
+
+	if vsCount, _ := cnf.GetVirtualServerCounts(); vsCount != 0 {
+		t.Errorf("GetVirtualServerCounts returned %v for a quarantined new VirtualServer, but expected 0", vsCount)
+	}
+
+	err = cnf.DeleteVirtualServer("default/cafe")
+	if err != nil {
+		t.Errorf("DeleteVirtualServer returned:  \n%v, but expected: \n%v", err, nil)
+	}
+
+	if quarantineErr := cnf.GetVirtualServerQuarantineError(vsEx.VirtualServer); quarantineErr != nil {
+		t.Errorf("GetVirtualServerQuarantineError returned %v for a deleted VirtualServer", quarantineErr)
+	}
+}
+
+func TestUpdateConfigQuarantinesVirtualServerWithInvalidTemplate(t *testing.T) {
+	cnf := createTestConfiguratorInvalidVirtualServerTemplate(t)
+
+	ingress := createCafeIngressEx()
+	vsEx := createTestVirtualServerEx()
+
+	_, err := cnf.UpdateConfig(NewDefaultConfigParams(), []*IngressEx{&ingress}, nil, []*VirtualServerEx{vsEx})
+	if err != nil {
+		t.Errorf("UpdateConfig returned:  \n%v, but expected: \n%v", err, nil)
+	}
+
+	if !cnf.HasIngress(ingress.Ingress) {
+		t.Errorf("UpdateConfig didn't add the Ingress because of a quarantined VirtualServer")
+	}
+
+	if quarantineErr := cnf.GetVirtualServerQuarantineError(vsEx.VirtualServer); quarantineErr == nil {
+		t.Errorf("GetVirtualServerQuarantineError returned nil for a VirtualServer which config couldn't be generated")
+	}
+}
+
+// failingTestConfigManager is a fake manager which stages the configs and which config test fails
+// if a config contains the host.
+type failingTestConfigManager struct {
+	*nginx.FakeManager
+	host    string
+	configs map[string][]byte
+	// staged stores the staged configs; the content is nil if the deletion of the config is staged
+	staged map[string][]byte
+}
+
+func newFailingTestConfigManager(host string) *failingTestConfigManager {
+	return &failingTestConfigManager{
+		FakeManager: nginx.NewFakeManager("/etc/nginx"),
+		host:        host,
+		configs:     make(map[string][]byte),
+		staged:      make(map[string][]byte),
+	}
+}
+
+func (m *failingTestConfigManager) CreateConfig(name string, content []byte) {
+	m.staged[name] = content
+}
+
+func (m *failingTestConfigManager) DeleteConfig(name string) {
+	m.staged[name] = nil
+}
+
+func (m *failingTestConfigManager) DiscardStagedConfig(name string) {
+	delete(m.staged, name)
+}
+
+func (m *failingTestConfigManager) PromoteStagedConfigs() {
+	for name, content := range m.staged {
+		if content != nil {
+			m.configs[name] = content
+		} else {
+			delete(m.configs, name)
+		}
+	}
+	m.staged = make(map[string][]byte)
+}
+
+func (m *failingTestConfigManager) TestConfig(excludedConfigs ...string) error {
+	configs := make(map[string][]byte)
+	for name, content := range m.configs {
+		configs[name] = content
+	}
+
+	excluded := make(map[string]bool)
+	for _, name := range excludedConfigs {
+		excluded[name] = true
+	}
+	for name, content := range m.staged {
+		if excluded[name] {
+			continue
+		}
+		if content != nil {
+			configs[name] = content
+		} else {
+			delete(configs, name)
+		}
+	}
+
+	for name, content := range configs {
+		if strings.Contains(string(content), m.host) {
+			return fmt.Errorf("invalid config %v", name)
+		}
+	}
+	return nil
+}
+
+func TestAddOrUpdateVirtualServerRestoresConfigWhenTestFails(t *testing.T) {
+	cnf, err := createTestConfigurator()
+	if err != nil {
+		t.Fatalf("Failed to create a test configurator: %v", err)
+	}
+	cnf.staticCfgParams.TestConfigBeforeReload = true
+	cnf.nginxManager = newFailingTestConfigManager("invalid.example.com")
+
+	vsEx := createTestVirtualServerEx()
+	if _, err := cnf.AddOrUpdateVirtualServer(vsEx); err != nil {
+		t.Fatalf("AddOrUpdateVirtualServer returned an unexpected error: %v", err)
+	}
+	prevContent := string(cnf.virtualServerConfigs["vs_default_cafe"])
+
+	invalidVsEx := createTestVirtualServerEx()
+	invalidVsEx.VirtualServer.Spec.Host = "invalid.example.com"
+	if _, err := cnf.AddOrUpdateVirtualServer(invalidVsEx); err == nil {
+		t.Errorf("AddOrUpdateVirtualServer returned no error for a VirtualServer which config failed the test")
+	}
+
+	if content := string(cnf.virtualServerConfigs["vs_default_cafe"]); content != prevContent {
+		t.Errorf("AddOrUpdateVirtualServer didn't restore the previous config of the VirtualServer:\n%s", content)
+	}
+	if quarantineErr := cnf.GetVirtualServerQuarantineError(invalidVsEx.VirtualServer); quarantineErr == nil {
+		t.Errorf("GetVirtualServerQuarantineError returned nil for a VirtualServer which config failed the test")
+	}
+
+	newVsEx := createTestVirtualServerEx()
+	newVsEx.VirtualServer.Name = "invalid"
+	newVsEx.VirtualServer.Spec.Host = "invalid.example.com"
+	if _, err := cnf.AddOrUpdateVirtualServer(newVsEx); err == nil {
+		t.Errorf("AddOrUpdateVirtualServer returned no error for a new VirtualServer which config failed the test")
+	}
+	if _, exists := cnf.virtualServerConfigs["vs_default_invalid"]; exists {
+		t.Errorf("AddOrUpdateVirtualServer didn't remove the config of a new VirtualServer which config failed the test")
+	}
+	if quarantineErr := cnf.GetVirtualServerQuarantineError(newVsEx.VirtualServer); quarantineErr == nil {
+		t.Errorf("GetVirtualServerQuarantineError returned nil for a new VirtualServer which config failed the test")
+	}
+}
+
+func TestUpdateVirtualServersQuarantinesVirtualServerWhenTestFails(t *testing.T) {
+	cnf, err := createTestConfigurator()
+	if err != nil {
+		t.Fatalf("Failed to create a test configurator: %v", err)
+	}
+	cnf.staticCfgParams.TestConfigBeforeReload = true
+	cnf.nginxManager = newFailingTestConfigManager("invalid.example.com")
+
+	cafeVsEx := createTestVirtualServerEx()
+	teaVsEx := createTestVirtualServerEx()
+	teaVsEx.VirtualServer.Name = "tea"
+	teaVsEx.VirtualServer.Spec.Host = "tea.example.com"
+	if err := cnf.UpdateVirtualServers([]*VirtualServerEx{cafeVsEx, teaVsEx}); err != nil {
+		t.Fatalf("UpdateVirtualServers returned an unexpected error: %v", err)
+	}
+	prevCafeContent := string(cnf.virtualServerConfigs["vs_default_cafe"])
+
+	updatedCafeVsEx := createTestVirtualServerEx()
+	updatedCafeVsEx.VirtualServer.Spec.Host = "invalid.example.com"
+	updatedTeaVsEx := createTestVirtualServerEx()
+	updatedTeaVsEx.VirtualServer.Name = "tea"
+	updatedTeaVsEx.VirtualServer.Spec.Host = "green-tea.example.com"
+	// only the VirtualServer which config fails the test is restored and quarantined
+	if err := cnf.UpdateVirtualServers([]*VirtualServerEx{updatedCafeVsEx, updatedTeaVsEx}); err != nil {
+		t.Errorf("UpdateVirtualServers returned an unexpected error: %v", err)
+	}
+
+	if content := string(cnf.virtualServerConfigs["vs_default_cafe"]); content != prevCafeContent {
+		t.Errorf("UpdateVirtualServers didn't restore the previous config of VirtualServer cafe:\n%s", content)
+	}
+	if quarantineErr := cnf.GetVirtualServerQuarantineError(updatedCafeVsEx.VirtualServer); quarantineErr == nil {
+		t.Errorf("GetVirtualServerQuarantineError returned nil for a VirtualServer which config failed the test")
+	}
+
+	if content := string(cnf.virtualServerConfigs["vs_default_tea"]); !strings.Contains(content, "green-tea.example.com") {
+		t.Errorf("UpdateVirtualServers didn't apply the config of VirtualServer tea:\n%s", content)
+	}
+	if quarantineErr := cnf.GetVirtualServerQuarantineError(updatedTeaVsEx.VirtualServer); quarantineErr != nil {
+		t.Errorf("GetVirtualServerQuarantineError returned %v for a VirtualServer which config passed the test", quarantineErr)
+	}
+}
+
+func TestAddOrUpdateIngressRestoresConfigWhenTestFails(t *testing.T) {
+	cnf, err := createTestConfigurator()
+	if err != nil {
+		t.Fatalf("Failed to create a test configurator: %v", err)
+	}
+	cnf.staticCfgParams.TestConfigBeforeReload = true
+	manager := newFailingTestConfigManager("invalid.example.com")
+	cnf.nginxManager = manager
+
+	ingress := createCafeIngressEx()
+	if err := cnf.AddOrUpdateIngress(&ingress); err != nil {
+		t.Fatalf("AddOrUpdateIngress returned an unexpected error: %v", err)
+	}
+	prevContent := string(manager.configs["default-cafe-ingress"])
+
+	invalidIngress := createCafeIngressEx()
+	invalidIngress.Ingress.Spec.Rules[0].Host = "invalid.example.com"
+	if err := cnf.AddOrUpdateIngress(&invalidIngress); err == nil {
+		t.Errorf("AddOrUpdateIngress returned no error for an Ingress which config failed the test")
+	}
+
+	if content := string(manager.configs["default-cafe-ingress"]); content != prevContent {
+		t.Errorf("AddOrUpdateIngress didn't restore the previous config of the Ingress:\n%s", content)
+	}
+	if cnf.ingresses["default-cafe-ingress"] != &ingress {
+		t.Errorf("AddOrUpdateIngress didn't restore the previous Ingress")
+	}
+
+	// the rejected config of the Ingress doesn't block the next changes
+	vsEx := createTestVirtualServerEx()
+	if _, err := cnf.AddOrUpdateVirtualServer(vsEx); err != nil {
+		t.Errorf("AddOrUpdateVirtualServer returned an unexpected error: %v", err)
+	}
+	if _, applied := manager.configs["vs_default_cafe"]; !applied {
+		t.Errorf("AddOrUpdateVirtualServer didn't apply the config of the VirtualServer")
+	}
+
+	newIngress := createCafeIngressEx()
+	newIngress.Ingress.Name = "new-cafe-ingress"
+	newIngress.Ingress.Spec.Rules[0].Host = "invalid.example.com"
+	if err := cnf.AddOrUpdateIngress(&newIngress); err == nil {
+		t.Errorf("AddOrUpdateIngress returned no error for a new Ingress which config failed the test")
+	}
+	if cnf.HasIngress(newIngress.Ingress) {
+		t.Errorf("AddOrUpdateIngress didn't remove a new Ingress which config failed the test")
+	}
+}
+
+// countingManager is a fake manager which counts the written configs, the tests of the config and the reloads.
+type countingManager struct {
+	*nginx.FakeManager
+	writes  int
+	tests   int
+	reloads int
+}
+
+func (m *countingManager) TestConfig(excludedConfigs ...string) error {
+	m.tests++
+	return nil
+}
+
+func (m *countingManager) CreateConfig(name string, content []byte) {
+	m.writes++
+}
+
+func (m *countingManager) Reload() error {
+	m.reloads++
+	return nil
+}
+
+func TestAddOrUpdateVirtualServerWithRegenerateAnnotation(t *testing.T) {
+	cnf, err := createTestConfigurator()
+	if err != nil {
+		t.Fatalf("Failed to create a test configurator: %v", err)
+	}
+	manager := &countingManager{FakeManager: nginx.NewFakeManager("/etc/nginx")}
+	cnf.nginxManager = manager
+
+	vsEx := createTestVirtualServerEx()
+	if _, err := cnf.AddOrUpdateVirtualServer(vsEx); err != nil {
+		t.Fatalf("AddOrUpdateVirtualServer returned an unexpected error: %v", err)
+	}
+	if _, err := cnf.AddOrUpdateVirtualServer(vsEx); err != nil {
+		t.Fatalf("AddOrUpdateVirtualServer returned an unexpected error: %v", err)
+	}
+	if manager.writes != 1 || manager.reloads != 1 {
+		t.Fatalf("AddOrUpdateVirtualServer wrote the config %d times and reloaded NGINX %d times for an unchanged VirtualServer, expected 1 and 1",
+			manager.writes, manager.reloads)
+	}
+
+	regeneratedVsEx := createTestVirtualServerEx()
+	regeneratedVsEx.VirtualServer.Annotations = map[string]string{RegenerateAnnotation: "1"}
+	if _, err := cnf.AddOrUpdateVirtualServer(regeneratedVsEx); err != nil {
+		t.Fatalf("AddOrUpdateVirtualServer returned an unexpected error: %v", err)
+	}
+	if manager.writes != 2 || manager.reloads != 2 {
+		t.Errorf("AddOrUpdateVirtualServer wrote the config %d times and reloaded NGINX %d times after the regenerate annotation changed, expected 2 and 2",
+			manager.writes, manager.reloads)
+	}
+
+	cnf.ForceRegeneration(regeneratedVsEx.VirtualServer.Namespace)
+	if _, err := cnf.AddOrUpdateVirtualServer(regeneratedVsEx); err != nil {
+		t.Fatalf("AddOrUpdateVirtualServer returned an unexpected error: %v", err)
+	}
+	if manager.writes != 3 || manager.reloads != 3 {
+		t.Errorf("AddOrUpdateVirtualServer wrote the config %d times and reloaded NGINX %d times after a forced regeneration, expected 3 and 3",
+			manager.writes, manager.reloads)
+	}
+
+	// the regeneration is forced only once
+	if _, err := cnf.AddOrUpdateVirtualServer(regeneratedVsEx); err != nil {
+		t.Fatalf("AddOrUpdateVirtualServer returned an unexpected error: %v", err)
+	}
+	if manager.writes != 3 || manager.reloads != 3 {
+		t.Errorf("AddOrUpdateVirtualServer wrote the config %d times and reloaded NGINX %d times for an unchanged VirtualServer, expected 3 and 3",
+			manager.writes, manager.reloads)
+	}
+}
+
+func TestUpdateEndpointsWithPlusSkipsTestOfUnchangedConfigs(t *testing.T) {
+	cnf, err := createTestConfigurator()
+	if err != nil {
+		t.Fatalf("Failed to create a test configurator: %v", err)
+	}
+	cnf.isPlus = true
+	cnf.staticCfgParams.TestConfigBeforeReload = true
+	manager := &countingManager{FakeManager: nginx.NewFakeManager("/etc/nginx")}
+	cnf.nginxManager = manager
+
+	ingress := createCafeIngressEx()
+	if err := cnf.AddOrUpdateIngress(&ingress); err != nil {
+		t.Fatalf("AddOrUpdateIngress returned an unexpected error: %v", err)
+	}
+	if manager.writes != 1 || manager.tests != 1 {
+		t.Fatalf("AddOrUpdateIngress wrote the config %d times and tested the config %d times, expected 1 and 1", manager.writes, manager.tests)
+	}
+
+	// the endpoints didn't change, so the config is the same and it is neither written nor tested
+	if err := cnf.UpdateEndpoints([]*IngressEx{&ingress}); err != nil {
+		t.Fatalf("UpdateEndpoints returned an unexpected error: %v", err)
+	}
+	if manager.writes != 1 || manager.tests != 1 || manager.reloads != 1 {
+		t.Errorf("UpdateEndpoints wrote the config %d times, tested the config %d times and reloaded NGINX %d times for unchanged endpoints, expected 1, 1 and 1",
+			manager.writes, manager.tests, manager.reloads)
+	}
+
+	cnf.ForceRegeneration(ingress.Ingress.Namespace)
+	if err := cnf.AddOrUpdateIngress(&ingress); err != nil {
+		t.Fatalf("AddOrUpdateIngress returned an unexpected error: %v", err)
+	}
+	if manager.writes != 2 || manager.reloads != 2 {
+		t.Errorf("AddOrUpdateIngress wrote the config %d times and reloaded NGINX %d times after a forced regeneration, expected 2 and 2",
+			manager.writes, manager.reloads)
+	}
+}
+
+func TestUpdateConfigWithVirtualServerTemplate(t *testing.T) {
+	cnf, err := createTestConfigurator()
+	if err != nil {
+		t.Fatalf("Failed to create a test configurator: %v", err)
+	}
+
+	vsEx := createTestVirtualServerEx()
+	if _, _, err := cnf.addOrUpdateVirtualServer(vsEx); err != nil {
+		t.Fatalf("addOrUpdateVirtualServer returned an unexpected error: %v", err)
+	}
+
+	cfgParams := NewDefaultConfigParams()
+	vsTemplate := "server_name {{ .Server.ServerName }};"
+	cfgParams.VirtualServerTemplate = &vsTemplate
+
+	if _, err := cnf.UpdateConfig(cfgParams, nil, nil, []*VirtualServerEx{vsEx}); err != nil {
+		t.Fatalf("UpdateConfig returned an unexpected error: %v", err)
+	}
+
+	name := getFileNameForVirtualServer(vsEx.VirtualServer)
+	expected := fmt.Sprintf("server_name %s;", vsEx.VirtualServer.Spec.Host)
+	if content := string(cnf.virtualServerConfigs[name]); content != expected {
+		t.Errorf("UpdateConfig generated the config %q for an unchanged VirtualServer but expected %q", content, expected)
+	}
+
+	invalidTemplate := "{{ .Server.Unknown }}"
+	cfgParams.VirtualServerTemplate = &invalidTemplate
+	ingress := createCafeIngressEx()
+
+	// the rest of the ConfigMap is applied with the previous template
+	if _, err := cnf.UpdateConfig(cfgParams, []*IngressEx{&ingress}, nil, []*VirtualServerEx{vsEx}); err != nil {
+		t.Errorf("UpdateConfig returned an unexpected error for an invalid virtualserver template: %v", err)
+	}
+	if content := string(cnf.virtualServerConfigs[name]); content != expected {
+		t.Errorf("UpdateConfig changed the config to %q after an invalid virtualserver template", content)
+	}
+	if !cnf.HasIngress(ingress.Ingress) {
+		t.Errorf("UpdateConfig didn't add the Ingress because of an invalid virtualserver template")
+	}
+}
+
+func TestUpdateVirtualServerTemplateFromFile(t *testing.T) {
+	cnf, err := createTestConfigurator()
+	if err != nil {
+		t.Fatalf("Failed to create a test configurator: %v", err)
+	}
+
+	file, err := ioutil.TempFile("", "virtualserver.tmpl")
+	if err != nil {
+		t.Fatalf("Failed to create a template file: %v", err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	if _, err := file.WriteString("server_name {{ .Server.ServerName }};"); err != nil {
+		t.Fatalf("Failed to write a template file: %v", err)
+	}
+	cnf.staticCfgParams.VirtualServerTemplatePath = file.Name()
+
+	vsEx := createTestVirtualServerEx()
+	if err := cnf.UpdateVirtualServerTemplateFromFile([]*VirtualServerEx{vsEx}); err != nil {
+		t.Fatalf("UpdateVirtualServerTemplateFromFile returned an unexpected error: %v", err)
+	}
+
+	name := getFileNameForVirtualServer(vsEx.VirtualServer)
+	expected := fmt.Sprintf("server_name %s;", vsEx.VirtualServer.Spec.Host)
+	if content := string(cnf.virtualServerConfigs[name]); content != expected {
+		t.Errorf("UpdateVirtualServerTemplateFromFile generated the config %q but expected %q", content, expected)
+	}
+
+	if err := ioutil.WriteFile(file.Name(), []byte("{{ .Server.Unknown }}"), 0644); err != nil {
+		t.Fatalf("Failed to update a template file: %v", err)
+	}
+
+	if err := cnf.UpdateVirtualServerTemplateFromFile([]*VirtualServerEx{vsEx}); err == nil {
+		t.Errorf("UpdateVirtualServerTemplateFromFile returned no error for an invalid virtualserver template")
+	}
+	if content := string(cnf.virtualServerConfigs[name]); content != expected {
+		t.Errorf("UpdateVirtualServerTemplateFromFile changed the config to %q after an invalid virtualserver template", content)
+	}
+}
+
+func BenchmarkAddOrUpdateVirtualServers(b *testing.B) {
+	cnf, err := createTestConfigurator()
+	if err != nil {
+		b.Fatalf("Failed to create a test configurator: %v", err)
+	}
+
+	var vsExes []*VirtualServerEx
+	for i := 0; i < 50; i++ {
+		vsEx := createLargeVirtualServerEx(100)
+		vsEx.VirtualServer.Name = fmt.Sprintf("cafe-%d", i)
+		vsEx.VirtualServer.Spec.Host = fmt.Sprintf("cafe-%d.example.com", i)
+		vsExes = append(vsExes, vsEx)
+	}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		// the previous configs are forgotten, so that the configs are generated every time
+		cnf.generatedVirtualServers = make(map[string]generatedVirtualServer)
+		cnf.virtualServerConfigs = make(map[string][]byte)
+		cnf.addOrUpdateVirtualServers(vsExes)
+	}
+}
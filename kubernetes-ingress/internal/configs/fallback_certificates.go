@@ -0,0 +1,179 @@
+package configs
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/nginxinc/kubernetes-ingress/internal/nginx"
+)
+
+// fallbackCertificateValidity is the validity period of a generated fallback certificate.
+const fallbackCertificateValidity = 90 * 24 * time.Hour
+
+// fallbackCertificateRenewBefore defines how long before its expiration a fallback certificate gets rotated.
+const fallbackCertificateRenewBefore = 30 * 24 * time.Hour
+
+type fallbackCertificate struct {
+	fileName string
+	notAfter time.Time
+}
+
+// fallbackCertificates manages self-signed certificates for the hosts of VirtualServers with a missing or invalid TLS Secret.
+// Unlike the rest of the Configurator, it is safe for concurrent use, so that the controller can check
+// for certificates due for rotation outside of its sync loop.
+type fallbackCertificates struct {
+	mu           sync.Mutex
+	certs        map[string]fallbackCertificate
+	nginxManager nginx.Manager
+	now          func() time.Time
+}
+
+func newFallbackCertificates(nginxManager nginx.Manager) *fallbackCertificates {
+	return &fallbackCertificates{
+		certs:        make(map[string]fallbackCertificate),
+		nginxManager: nginxManager,
+		now:          time.Now,
+	}
+}
+
+func getFileNameForFallbackCertificate(host string) string {
+	// a regex host can include characters that are not safe in a file name
+	if strings.HasPrefix(host, "~") {
+		return fmt.Sprintf("fallback_regex_%x", sha256.Sum256([]byte(host)))
+	}
+	// underscores are not allowed in the names of Secrets, so the name can't clash with the file of a TLS Secret
+	return fmt.Sprintf("fallback_%s", host)
+}
+
+// addOrUpdate returns the file name of the fallback certificate for the host and whether the certificate was generated.
+// The certificate is generated if it doesn't exist or is due for rotation.
+func (fc *fallbackCertificates) addOrUpdate(host string) (string, bool, error) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	now := fc.now()
+
+	if cert, exists := fc.certs[host]; exists && !isDueForRotation(cert, now) {
+		return cert.fileName, false, nil
+	}
+
+	notAfter := now.Add(fallbackCertificateValidity)
+
+	content, err := generateSelfSignedCertificate(host, now, notAfter)
+	if err != nil {
+		return "", false, fmt.Errorf("Error generating a fallback certificate for host %v: %v", host, err)
+	}
+
+	glog.V(3).Infof("Generated a fallback certificate for host %v valid until %v", host, notAfter)
+
+	fileName := fc.nginxManager.CreateSecret(getFileNameForFallbackCertificate(host), content, nginx.TLSSecretFileMode)
+	fc.certs[host] = fallbackCertificate{
+		fileName: fileName,
+		notAfter: notAfter,
+	}
+
+	return fileName, true, nil
+}
+
+// removeUnused deletes the fallback certificates of the hosts that no longer need them.
+func (fc *fallbackCertificates) removeUnused(hostsInUse map[string]bool) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	for host := range fc.certs {
+		if hostsInUse[host] {
+			continue
+		}
+
+		fc.nginxManager.DeleteSecret(getFileNameForFallbackCertificate(host))
+		delete(fc.certs, host)
+	}
+}
+
+// getHostsDueForRotation returns the sorted hosts with fallback certificates that must be rotated.
+func (fc *fallbackCertificates) getHostsDueForRotation() []string {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	now := fc.now()
+
+	var hosts []string
+	for host, cert := range fc.certs {
+		if isDueForRotation(cert, now) {
+			hosts = append(hosts, host)
+		}
+	}
+
+	sort.Strings(hosts)
+
+	return hosts
+}
+
+func isDueForRotation(cert fallbackCertificate, now time.Time) bool {
+	return !now.Before(cert.notAfter.Add(-fallbackCertificateRenewBefore))
+}
+
+// generateSelfSignedCertificate generates a pem file content with a self-signed certificate and its key.
+// The host is used as the Common Name and as the only Subject Alternative Name of the certificate.
+func generateSelfSignedCertificate(host string, notBefore time.Time, notAfter time.Time) ([]byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate a private key: %v", err)
+	}
+
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate a serial number: %v", err)
+	}
+
+	template := x509.Certificate{
+		SerialNumber: serialNumber,
+		Subject: pkix.Name{
+			CommonName:   host,
+			Organization: []string{"NGINX Ingress Controller fallback certificate"},
+		},
+		DNSNames:              []string{host},
+		NotBefore:             notBefore.Add(-time.Hour),
+		NotAfter:              notAfter,
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create a certificate: %v", err)
+	}
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal the private key: %v", err)
+	}
+
+	var res bytes.Buffer
+
+	err = pem.Encode(&res, &pem.Block{Type: "CERTIFICATE", Bytes: der})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode the certificate: %v", err)
+	}
+
+	err = pem.Encode(&res, &pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode the private key: %v", err)
+	}
+
+	return res.Bytes(), nil
+}
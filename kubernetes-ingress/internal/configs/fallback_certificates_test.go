@@ -0,0 +1,135 @@
+package configs
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nginxinc/kubernetes-ingress/internal/nginx"
+)
+
+func TestGenerateSelfSignedCertificate(t *testing.T) {
+	host := "cafe.example.com"
+	notBefore := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	notAfter := notBefore.Add(fallbackCertificateValidity)
+
+	content, err := generateSelfSignedCertificate(host, notBefore, notAfter)
+	if err != nil {
+		t.Fatalf("generateSelfSignedCertificate() returned unexpected error: %v", err)
+	}
+
+	certBlock, rest := pem.Decode(content)
+	if certBlock == nil || certBlock.Type != "CERTIFICATE" {
+		t.Fatalf("generateSelfSignedCertificate() returned content without a certificate")
+	}
+
+	keyBlock, _ := pem.Decode(rest)
+	if keyBlock == nil || keyBlock.Type != "EC PRIVATE KEY" {
+		t.Fatalf("generateSelfSignedCertificate() returned content without a key")
+	}
+
+	cert, err := x509.ParseCertificate(certBlock.Bytes)
+	if err != nil {
+		t.Fatalf("generateSelfSignedCertificate() returned an invalid certificate: %v", err)
+	}
+
+	expectedDNSNames := []string{host}
+	if !reflect.DeepEqual(cert.DNSNames, expectedDNSNames) {
+		t.Errorf("generateSelfSignedCertificate() returned a certificate with DNS names %v but expected %v", cert.DNSNames, expectedDNSNames)
+	}
+	if cert.Subject.CommonName != host {
+		t.Errorf("generateSelfSignedCertificate() returned a certificate with CN %q but expected %q", cert.Subject.CommonName, host)
+	}
+	if !cert.NotAfter.Equal(notAfter) {
+		t.Errorf("generateSelfSignedCertificate() returned a certificate valid until %v but expected %v", cert.NotAfter, notAfter)
+	}
+	if err := cert.VerifyHostname(host); err != nil {
+		t.Errorf("generateSelfSignedCertificate() returned a certificate that doesn't match the host: %v", err)
+	}
+
+	if _, err := x509.ParseECPrivateKey(keyBlock.Bytes); err != nil {
+		t.Errorf("generateSelfSignedCertificate() returned an invalid key: %v", err)
+	}
+}
+
+func TestFallbackCertificatesRotation(t *testing.T) {
+	now := time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	fc := newFallbackCertificates(nginx.NewFakeManager("/etc/nginx"))
+	fc.now = func() time.Time { return now }
+
+	fileName, generated, err := fc.addOrUpdate("cafe.example.com")
+	if err != nil {
+		t.Fatalf("addOrUpdate() returned unexpected error: %v", err)
+	}
+	if !generated {
+		t.Errorf("addOrUpdate() returned false for a new certificate")
+	}
+
+	expectedFileName := "/etc/nginx/secrets/fallback_cafe.example.com"
+	if fileName != expectedFileName {
+		t.Errorf("addOrUpdate() returned %q but expected %q", fileName, expectedFileName)
+	}
+
+	if hosts := fc.getHostsDueForRotation(); len(hosts) != 0 {
+		t.Errorf("getHostsDueForRotation() returned %v for a new certificate", hosts)
+	}
+
+	if _, generated, _ := fc.addOrUpdate("cafe.example.com"); generated {
+		t.Errorf("addOrUpdate() returned true for a certificate that is not due for rotation")
+	}
+
+	notAfter := fc.certs["cafe.example.com"].notAfter
+
+	now = notAfter.Add(-fallbackCertificateRenewBefore)
+
+	expectedHosts := []string{"cafe.example.com"}
+	if hosts := fc.getHostsDueForRotation(); !reflect.DeepEqual(hosts, expectedHosts) {
+		t.Errorf("getHostsDueForRotation() returned %v but expected %v", hosts, expectedHosts)
+	}
+
+	_, generated, err = fc.addOrUpdate("cafe.example.com")
+	if err != nil {
+		t.Fatalf("addOrUpdate() returned unexpected error: %v", err)
+	}
+
+	if !generated || !fc.certs["cafe.example.com"].notAfter.After(notAfter) {
+		t.Errorf("addOrUpdate() didn't rotate the certificate due for rotation")
+	}
+	if hosts := fc.getHostsDueForRotation(); len(hosts) != 0 {
+		t.Errorf("getHostsDueForRotation() returned %v after the rotation", hosts)
+	}
+}
+
+func TestFallbackCertificatesRemoveUnused(t *testing.T) {
+	fc := newFallbackCertificates(nginx.NewFakeManager("/etc/nginx"))
+
+	for _, host := range []string{"cafe.example.com", "tea.example.com"} {
+		if _, _, err := fc.addOrUpdate(host); err != nil {
+			t.Fatalf("addOrUpdate() returned unexpected error: %v", err)
+		}
+	}
+
+	fc.removeUnused(map[string]bool{"tea.example.com": true})
+
+	if _, exists := fc.certs["cafe.example.com"]; exists {
+		t.Errorf("removeUnused() didn't remove the certificate of an unused host")
+	}
+	if _, exists := fc.certs["tea.example.com"]; !exists {
+		t.Errorf("removeUnused() removed the certificate of a host in use")
+	}
+}
+
+func TestGetFileNameForFallbackCertificate(t *testing.T) {
+	if fileName := getFileNameForFallbackCertificate("cafe.example.com"); fileName != "fallback_cafe.example.com" {
+		t.Errorf("getFileNameForFallbackCertificate() returned %q for a host", fileName)
+	}
+
+	fileName := getFileNameForFallbackCertificate(`~^(?<subdomain>[^/]+)\.example\.com$`)
+	if strings.ContainsAny(fileName, `~^()<>[]/\$`) {
+		t.Errorf("getFileNameForFallbackCertificate() returned %q with unsafe characters for a regex host", fileName)
+	}
+}
@@ -99,6 +99,7 @@ func generateNginxCfg(ingEx *IngressEx, pems map[string]string, isMinion bool, b
 			RealIPHeader:          cfgParams.RealIPHeader,
 			SetRealIPFrom:         cfgParams.SetRealIPFrom,
 			RealIPRecursive:       cfgParams.RealIPRecursive,
+			XForwardedHeaders:     generateIngressXForwardedHeaders(&cfgParams),
 			ProxyHideHeaders:      cfgParams.ProxyHideHeaders,
 			ProxyPassHeaders:      cfgParams.ProxyPassHeaders,
 			ServerSnippets:        cfgParams.ServerSnippets,
@@ -228,6 +229,20 @@ func generateNginxCfg(ingEx *IngressEx, pems map[string]string, isMinion bool, b
 	}
 }
 
+func generateIngressXForwardedHeaders(cfg *ConfigParams) []version1.XForwardedHeader {
+	proto := "$scheme"
+	if cfg.RedirectToHTTPS {
+		proto = "https"
+	}
+
+	var headers []version1.XForwardedHeader
+	for _, h := range generateXForwardedHeaders(nil, cfg, proto) {
+		headers = append(headers, version1.XForwardedHeader{Name: h.name, Value: h.value})
+	}
+
+	return headers
+}
+
 func createLocation(path string, upstream version1.Upstream, cfg *ConfigParams, websocket bool, rewrite string, ssl bool, grpc bool) version1.Location {
 	loc := version1.Location{
 		Path:                 path,
@@ -275,6 +290,7 @@ func createUpstream(ingEx *IngressEx, name string, backend *extensions.IngressBa
 
 	endps, exists := ingEx.Endpoints[backend.ServiceName+backend.ServicePort.String()]
 	if exists {
+		endps = sortEndpoints(endps)
 		var upsServers []version1.UpstreamServer
 		// Always false for NGINX OSS
 		_, isExternalNameSvc := ingEx.ExternalNameSvcs[backend.ServiceName]
@@ -305,6 +321,14 @@ func createUpstream(ingEx *IngressEx, name string, backend *extensions.IngressBa
 	return ups
 }
 
+// sortEndpoints returns a sorted copy of the endpoints. The order of the addresses in an Endpoints resource
+// can change without any change of the addresses, so the servers of the upstreams are sorted to generate the same config.
+func sortEndpoints(endpoints []string) []string {
+	sorted := append([]string{}, endpoints...)
+	sort.Strings(sorted)
+	return sorted
+}
+
 func createHealthCheck(hc *api_v1.Probe, upstreamName string, cfg *ConfigParams) version1.HealthCheck {
 	return version1.HealthCheck{
 		UpstreamName:   upstreamName,
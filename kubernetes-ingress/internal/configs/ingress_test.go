@@ -121,6 +121,13 @@ func TestPathOrDefaultReturnActual(t *testing.T) {
 	}
 }
 
+var defaultIngressXForwardedHeaders = []version1.XForwardedHeader{
+	{Name: "X-Forwarded-For", Value: "$proxy_add_x_forwarded_for"},
+	{Name: "X-Forwarded-Host", Value: "$host"},
+	{Name: "X-Forwarded-Port", Value: "$server_port"},
+	{Name: "X-Forwarded-Proto", Value: "$scheme"},
+}
+
 func createExpectedConfigForCafeIngressEx() version1.IngressNginxConfig {
 	coffeeUpstream := version1.Upstream{
 		Name:             "default-cafe-ingress-cafe.example.com-coffee-svc-80",
@@ -188,6 +195,7 @@ func createExpectedConfigForCafeIngressEx() version1.IngressNginxConfig {
 				SSLPorts:          []int{443},
 				SSLRedirect:       true,
 				HealthChecks:      make(map[string]version1.HealthCheck),
+				XForwardedHeaders: defaultIngressXForwardedHeaders,
 			},
 		},
 		Ingress: version1.Ingress{
@@ -559,6 +567,7 @@ func createExpectedConfigForMergeableCafeIngress() version1.IngressNginxConfig {
 				SSLPorts:          []int{443},
 				SSLRedirect:       true,
 				HealthChecks:      make(map[string]version1.HealthCheck),
+				XForwardedHeaders: defaultIngressXForwardedHeaders,
 			},
 		},
 		Ingress: version1.Ingress{
@@ -573,3 +582,17 @@ func createExpectedConfigForMergeableCafeIngress() version1.IngressNginxConfig {
 
 	return expected
 }
+
+func TestSortEndpoints(t *testing.T) {
+	endpoints := []string{"10.0.0.2:80", "10.0.0.10:80", "10.0.0.1:80"}
+
+	expected := []string{"10.0.0.10:80", "10.0.0.1:80", "10.0.0.2:80"}
+	result := sortEndpoints(endpoints)
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("sortEndpoints() returned %v but expected %v", result, expected)
+	}
+
+	if endpoints[0] != "10.0.0.2:80" {
+		t.Errorf("sortEndpoints() modified the endpoints %v", endpoints)
+	}
+}
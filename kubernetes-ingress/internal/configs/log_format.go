@@ -0,0 +1,52 @@
+package configs
+
+import (
+	"fmt"
+	"strings"
+)
+
+// logFormatPresetJSON is the preset of the log format that logs a request as a JSON object.
+const logFormatPresetJSON = "json"
+
+// jsonLogFormatEscaping is the escaping of the variables of the JSON log format.
+const jsonLogFormatEscaping = "json"
+
+// logField is a field of a JSON log format. The value is an NGINX variable or a static string.
+type logField struct {
+	name  string
+	value string
+}
+
+// jsonLogFormatFields are the fields of the JSON log format common to all resources.
+var jsonLogFormatFields = []logField{
+	{name: "time", value: "$time_iso8601"},
+	{name: "request_id", value: "$request_id"},
+	{name: "remote_addr", value: "$remote_addr"},
+	{name: "remote_user", value: "$remote_user"},
+	{name: "host", value: "$host"},
+	{name: "request_method", value: "$request_method"},
+	{name: "request_uri", value: "$request_uri"},
+	{name: "server_protocol", value: "$server_protocol"},
+	{name: "status", value: "$status"},
+	{name: "body_bytes_sent", value: "$body_bytes_sent"},
+	{name: "request_time", value: "$request_time"},
+	{name: "http_referer", value: "$http_referer"},
+	{name: "http_user_agent", value: "$http_user_agent"},
+	{name: "http_x_forwarded_for", value: "$http_x_forwarded_for"},
+	{name: "upstream_addr", value: "$upstream_addr"},
+	{name: "upstream_status", value: "$upstream_status"},
+	{name: "upstream_connect_time", value: "$upstream_connect_time"},
+	{name: "upstream_header_time", value: "$upstream_header_time"},
+	{name: "upstream_response_time", value: "$upstream_response_time"},
+}
+
+// generateJSONLogFormat generates the JSON log format with the common fields followed by the static fields,
+// which must not require JSON escaping. All values are strings, because the variables of the upstreams are empty
+// for the requests that are not proxied. The log format must be used with the escape=json parameter.
+func generateJSONLogFormat(staticFields []logField) string {
+	var fields []string
+	for _, f := range append(append([]logField{}, jsonLogFormatFields...), staticFields...) {
+		fields = append(fields, fmt.Sprintf(`"%s":"%s"`, f.name, f.value))
+	}
+	return "{" + strings.Join(fields, ",") + "}"
+}
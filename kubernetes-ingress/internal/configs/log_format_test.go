@@ -0,0 +1,30 @@
+package configs
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestGenerateJSONLogFormat(t *testing.T) {
+	format := generateJSONLogFormat([]logField{
+		{name: "virtualserver_namespace", value: "default"},
+		{name: "virtualserver_name", value: "cafe"},
+	})
+
+	if !strings.HasPrefix(format, `{"time":"$time_iso8601","request_id":"$request_id",`) {
+		t.Errorf("generateJSONLogFormat() returned %q which doesn't start with the common fields", format)
+	}
+	if !strings.HasSuffix(format, `,"virtualserver_namespace":"default","virtualserver_name":"cafe"}`) {
+		t.Errorf("generateJSONLogFormat() returned %q which doesn't end with the static fields", format)
+	}
+
+	// NGINX replaces the variables with the JSON-escaped values, so the format must be a valid JSON object as is
+	var fields map[string]string
+	if err := json.Unmarshal([]byte(format), &fields); err != nil {
+		t.Errorf("generateJSONLogFormat() returned %q which is not a valid JSON object: %v", format, err)
+	}
+	if len(fields) != len(jsonLogFormatFields)+2 {
+		t.Errorf("generateJSONLogFormat() returned %d fields but expected %d", len(fields), len(jsonLogFormatFields)+2)
+	}
+}
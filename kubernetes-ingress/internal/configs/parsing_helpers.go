@@ -3,6 +3,7 @@ package configs
 import (
 	"errors"
 	"fmt"
+	"net"
 	"regexp"
 	"strconv"
 	"strings"
@@ -186,3 +187,89 @@ func ParseTime(s string) (string, error) {
 	}
 	return "", errors.New("Invalid time string")
 }
+
+// timeSuffixSeconds are the numbers of seconds of the suffixes of NGINX time, except ms.
+var timeSuffixSeconds = map[string]int64{
+	"":  1,
+	"s": 1,
+	"m": 60,
+	"h": 60 * 60,
+	"d": 24 * 60 * 60,
+	"w": 7 * 24 * 60 * 60,
+	"M": 30 * 24 * 60 * 60,
+	"y": 365 * 24 * 60 * 60,
+}
+
+var nginxTimeParts = regexp.MustCompile(`([0-9]+)(ms|[smhdwMy]?)`)
+
+// ParseTimeToSeconds converts a valid time to the number of seconds, rounding the milliseconds down,
+// for example, for the Max-Age attribute of cookies.
+func ParseTimeToSeconds(s string) (int64, error) {
+	s, err := ParseTime(s)
+	if err != nil {
+		return 0, err
+	}
+
+	var seconds int64
+	for _, part := range nginxTimeParts.FindAllStringSubmatch(s, -1) {
+		value, err := strconv.ParseInt(part[1], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("Invalid time string: %v", err)
+		}
+
+		if part[2] == "ms" {
+			seconds += value / 1000
+		} else {
+			seconds += value * timeSuffixSeconds[part[2]]
+		}
+	}
+
+	return seconds, nil
+}
+
+// ParseSetRealIPFrom ensures that the addresses of the set-real-ip-from key are valid IPv4 or IPv6 addresses,
+// CIDRs or unix:. It returns the addresses without the surrounding whitespace.
+func ParseSetRealIPFrom(addresses []string) ([]string, error) {
+	var result []string
+
+	for _, a := range addresses {
+		a = strings.TrimSpace(a)
+
+		if a == "unix:" {
+			result = append(result, a)
+			continue
+		}
+
+		if strings.Contains(a, "/") {
+			if _, _, err := net.ParseCIDR(a); err != nil {
+				return nil, fmt.Errorf("invalid CIDR %q", a)
+			}
+		} else if net.ParseIP(a) == nil {
+			return nil, fmt.Errorf("invalid address %q: must be an IPv4 or IPv6 address, a CIDR or unix:", a)
+		}
+
+		result = append(result, a)
+	}
+
+	return result, nil
+}
+
+// ParseXForwardedMode ensures that the mode of an X-Forwarded-* header is append, overwrite, preserve or remove.
+// Only X-Forwarded-For can be appended.
+func ParseXForwardedMode(mode string, canAppend bool) (string, error) {
+	mode = strings.TrimSpace(mode)
+
+	switch mode {
+	case "overwrite", "preserve", "remove":
+		return mode, nil
+	case "append":
+		if canAppend {
+			return mode, nil
+		}
+	}
+
+	if canAppend {
+		return "", fmt.Errorf("must be append, overwrite, preserve or remove")
+	}
+	return "", fmt.Errorf("must be overwrite, preserve or remove")
+}
@@ -382,3 +382,98 @@ func TestParseTime(t *testing.T) {
 		}
 	}
 }
+
+func TestParseTimeToSeconds(t *testing.T) {
+	var testsWithValidInput = map[string]int64{
+		"1":      1,
+		"1m10s":  70,
+		"5m 30s": 330,
+		"3h":     10800,
+		"1d":     86400,
+		"2w":     1209600,
+		"1y":     31536000,
+	}
+	var invalidInput = []string{"ss", "m0m", "-5s", "", "1L"}
+	for test, expected := range testsWithValidInput {
+		result, err := ParseTimeToSeconds(test)
+		if err != nil {
+			t.Errorf("ParseTimeToSeconds(%q) returned an error for valid input", test)
+		}
+		if result != expected {
+			t.Errorf("ParseTimeToSeconds(%q) returned %d expected %d", test, result, expected)
+		}
+	}
+	for _, test := range invalidInput {
+		result, err := ParseTimeToSeconds(test)
+		if err == nil {
+			t.Errorf("ParseTimeToSeconds(%q) didn't return error. Returned: %d", test, result)
+		}
+	}
+}
+
+func TestParseSetRealIPFrom(t *testing.T) {
+	input := []string{"10.0.0.1", " 192.168.0.0/16", "2001:db8::/32 ", "unix:"}
+	expected := []string{"10.0.0.1", "192.168.0.0/16", "2001:db8::/32", "unix:"}
+
+	result, err := ParseSetRealIPFrom(input)
+	if err != nil {
+		t.Errorf("ParseSetRealIPFrom(%q) returned an error for valid input: %v", input, err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("ParseSetRealIPFrom(%q) returned %q expected %q", input, result, expected)
+	}
+
+	invalidInput := [][]string{
+		{""},
+		{"10.0.0.256"},
+		{"10.0.0.0/33"},
+		{"10.0.0.1", "example.com"},
+	}
+	for _, test := range invalidInput {
+		_, err := ParseSetRealIPFrom(test)
+		if err == nil {
+			t.Errorf("ParseSetRealIPFrom(%q) didn't return an error for invalid input", test)
+		}
+	}
+}
+
+func TestParseXForwardedMode(t *testing.T) {
+	tests := []struct {
+		mode      string
+		canAppend bool
+		expected  string
+	}{
+		{"append", true, "append"},
+		{"overwrite", true, "overwrite"},
+		{" preserve ", false, "preserve"},
+		{"remove", false, "remove"},
+	}
+
+	for _, test := range tests {
+		result, err := ParseXForwardedMode(test.mode, test.canAppend)
+		if err != nil {
+			t.Errorf("ParseXForwardedMode(%q, %v) returned an error for valid input: %v", test.mode, test.canAppend, err)
+		}
+		if result != test.expected {
+			t.Errorf("ParseXForwardedMode(%q, %v) returned %q expected %q", test.mode, test.canAppend, result, test.expected)
+		}
+	}
+}
+
+func TestParseXForwardedModeFails(t *testing.T) {
+	tests := []struct {
+		mode      string
+		canAppend bool
+	}{
+		{"", true},
+		{"set", true},
+		{"append", false},
+	}
+
+	for _, test := range tests {
+		_, err := ParseXForwardedMode(test.mode, test.canAppend)
+		if err == nil {
+			t.Errorf("ParseXForwardedMode(%q, %v) didn't return an error for invalid input", test.mode, test.canAppend)
+		}
+	}
+}
@@ -0,0 +1,363 @@
+package configs
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SnippetContext is the NGINX configuration context where a snippet is inserted.
+type SnippetContext string
+
+const (
+	// MainSnippetContext is the context of the main-snippets ConfigMap key.
+	MainSnippetContext SnippetContext = "main"
+	// HTTPSnippetContext is the context of the http-snippets ConfigMap key.
+	HTTPSnippetContext SnippetContext = "http"
+	// StreamSnippetContext is the context of the stream-snippets ConfigMap key.
+	StreamSnippetContext SnippetContext = "stream"
+	// ServerSnippetContext is the context of the server-snippets ConfigMap key and annotation.
+	ServerSnippetContext SnippetContext = "server"
+	// LocationSnippetContext is the context of the location-snippets ConfigMap key and annotation.
+	LocationSnippetContext SnippetContext = "location"
+)
+
+// forbiddenSnippetDirectives are the directives that are not allowed at any level of the snippets of a context,
+// because NGINX doesn't accept them there or because they affect the whole NGINX process.
+var forbiddenSnippetDirectives = map[SnippetContext]map[string]bool{
+	MainSnippetContext: {
+		"http":   true,
+		"stream": true,
+		"events": true,
+	},
+	HTTPSnippetContext: {
+		"load_module": true,
+		"http":        true,
+		"stream":      true,
+		"events":      true,
+		"mail":        true,
+	},
+	StreamSnippetContext: {
+		"load_module": true,
+		"http":        true,
+		"stream":      true,
+		"events":      true,
+		"mail":        true,
+	},
+	ServerSnippetContext: {
+		"load_module": true,
+		"http":        true,
+		"stream":      true,
+		"events":      true,
+		"mail":        true,
+		"server":      true,
+		"upstream":    true,
+	},
+	LocationSnippetContext: {
+		"load_module": true,
+		"http":        true,
+		"stream":      true,
+		"events":      true,
+		"mail":        true,
+		"server":      true,
+		"upstream":    true,
+	},
+}
+
+// SnippetsValidator validates snippets before they are inserted into the NGINX config,
+// so that a broken or dangerous snippet is reported as an error rather than failing a reload of NGINX.
+type SnippetsValidator struct {
+	allowResourceSnippets bool
+	allowedDirectives     map[string]bool
+}
+
+// NewSnippetsValidator creates a SnippetsValidator. allowResourceSnippets and allowedDirectives apply
+// only to the snippets of resources, such as the snippets annotations of Ingress resources.
+// If allowedDirectives is empty, any directive that is not forbidden in the context of a snippet is allowed.
+func NewSnippetsValidator(allowResourceSnippets bool, allowedDirectives []string) *SnippetsValidator {
+	var allowed map[string]bool
+	if len(allowedDirectives) > 0 {
+		allowed = make(map[string]bool)
+		for _, d := range allowedDirectives {
+			allowed[d] = true
+		}
+	}
+
+	return &SnippetsValidator{
+		allowResourceSnippets: allowResourceSnippets,
+		allowedDirectives:     allowed,
+	}
+}
+
+// ValidateSnippets validates the lines of the snippets of the ConfigMap for the context.
+func (sv *SnippetsValidator) ValidateSnippets(snippets []string, context SnippetContext) error {
+	if len(snippets) == 0 {
+		return nil
+	}
+
+	return validateSnippetDirectives(snippets, context, nil)
+}
+
+// ValidateResourceSnippets validates the lines of the snippets of a resource for the context.
+func (sv *SnippetsValidator) ValidateResourceSnippets(snippets []string, context SnippetContext) error {
+	if len(snippets) == 0 {
+		return nil
+	}
+
+	if !sv.allowResourceSnippets {
+		return fmt.Errorf("snippets are not allowed")
+	}
+
+	return validateSnippetDirectives(snippets, context, sv.allowedDirectives)
+}
+
+// ValidateIngressSnippets validates the snippets annotations of an Ingress.
+func (sv *SnippetsValidator) ValidateIngressSnippets(annotations map[string]string) error {
+	snippetAnnotations := []struct {
+		key     string
+		context SnippetContext
+	}{
+		{key: "nginx.org/server-snippets", context: ServerSnippetContext},
+		{key: "nginx.org/location-snippets", context: LocationSnippetContext},
+	}
+
+	for _, a := range snippetAnnotations {
+		value, exists := annotations[a.key]
+		if !exists {
+			continue
+		}
+
+		err := sv.ValidateResourceSnippets(strings.Split(value, "\n"), a.context)
+		if err != nil {
+			return fmt.Errorf("invalid annotation %v: %v", a.key, err)
+		}
+	}
+
+	return nil
+}
+
+// ValidateConfigMapSnippets validates the snippets of the ConfigMap and removes the invalid ones from the config params.
+// It returns an error that describes all invalid snippets.
+func (sv *SnippetsValidator) ValidateConfigMapSnippets(cfgParams *ConfigParams) error {
+	snippetKeys := []struct {
+		key      string
+		context  SnippetContext
+		snippets *[]string
+	}{
+		{key: "main-snippets", context: MainSnippetContext, snippets: &cfgParams.MainMainSnippets},
+		{key: "http-snippets", context: HTTPSnippetContext, snippets: &cfgParams.MainHTTPSnippets},
+		{key: "stream-snippets", context: StreamSnippetContext, snippets: &cfgParams.MainStreamSnippets},
+		{key: "server-snippets", context: ServerSnippetContext, snippets: &cfgParams.ServerSnippets},
+		{key: "location-snippets", context: LocationSnippetContext, snippets: &cfgParams.LocationSnippets},
+	}
+
+	var msgs []string
+
+	for _, k := range snippetKeys {
+		err := sv.ValidateSnippets(*k.snippets, k.context)
+		if err != nil {
+			msgs = append(msgs, fmt.Sprintf("ConfigMap key %v will be ignored: %v", k.key, err))
+			*k.snippets = nil
+		}
+	}
+
+	if len(msgs) > 0 {
+		return fmt.Errorf("%v", strings.Join(msgs, "; "))
+	}
+
+	return nil
+}
+
+func validateSnippetDirectives(snippets []string, context SnippetContext, allowedDirectives map[string]bool) error {
+	directives, err := parseSnippetDirectives(strings.Join(snippets, "\n"))
+	if err != nil {
+		return err
+	}
+
+	forbidden := forbiddenSnippetDirectives[context]
+
+	for _, d := range directives {
+		if forbidden[d.name] {
+			return fmt.Errorf("directive %q in line %d is not allowed in the %v context", d.name, d.line, context)
+		}
+
+		if allowedDirectives != nil && !allowedDirectives[d.name] {
+			return fmt.Errorf("directive %q in line %d is not allowed, the allowed directives are: %v", d.name, d.line, sortedKeys(allowedDirectives))
+		}
+	}
+
+	return nil
+}
+
+func sortedKeys(m map[string]bool) string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return strings.Join(keys, ", ")
+}
+
+type snippetDirective struct {
+	name string
+	args []string
+	line int
+}
+
+// variableDefinitionArgs maps the directives that define variables to the index of the argument with the variable.
+// The index -1 means the last argument, as the source of geo is optional.
+var variableDefinitionArgs = map[string]int{
+	"set":           0,
+	"js_set":        0,
+	"perl_set":      0,
+	"map":           1,
+	"split_clients": 1,
+	"geo":           -1,
+}
+
+// getSnippetVariables returns the names of the variables that the directives of the snippets define, without the $.
+// It ignores the snippets that can't be parsed, which NGINX reports anyway.
+func getSnippetVariables(snippets []string) []string {
+	directives, err := parseSnippetDirectives(strings.Join(snippets, "\n"))
+	if err != nil {
+		return nil
+	}
+
+	var variables []string
+
+	for _, d := range directives {
+		index, defines := variableDefinitionArgs[d.name]
+		if !defines || len(d.args) == 0 {
+			continue
+		}
+
+		if index == -1 {
+			index = len(d.args) - 1
+		}
+		if index >= len(d.args) {
+			continue
+		}
+
+		arg := strings.Trim(d.args[index], `"'`)
+		if strings.HasPrefix(arg, "$") {
+			variables = append(variables, strings.TrimPrefix(arg, "$"))
+		}
+	}
+
+	return variables
+}
+
+// parseSnippetDirectives returns the directives of a snippet with their arguments, including the directives inside blocks.
+// It checks that every directive is terminated with a semicolon or a block, that the braces are balanced
+// and that the quotes are closed.
+func parseSnippetDirectives(snippet string) ([]snippetDirective, error) {
+	var directives []snippetDirective
+
+	depth := 0
+	line := 1
+	// the number of words of the current directive
+	words := 0
+
+	for i := 0; i < len(snippet); i++ {
+		c := snippet[i]
+
+		switch {
+		case c == '\n':
+			line++
+		case c == ' ' || c == '\t' || c == '\r':
+		case c == '#':
+			for i+1 < len(snippet) && snippet[i+1] != '\n' {
+				i++
+			}
+		case c == ';':
+			if words == 0 {
+				return nil, fmt.Errorf("unexpected \";\" in line %d", line)
+			}
+			words = 0
+		case c == '{':
+			if words == 0 {
+				return nil, fmt.Errorf("unexpected \"{\" in line %d", line)
+			}
+			depth++
+			words = 0
+		case c == '}':
+			if words != 0 {
+				return nil, fmt.Errorf("unexpected \"}\" in line %d, the previous directive must end with \";\"", line)
+			}
+			if depth == 0 {
+				return nil, fmt.Errorf("unexpected \"}\" in line %d", line)
+			}
+			depth--
+		default:
+			start := line
+
+			end, lines, err := scanSnippetWord(snippet, i)
+			if err != nil {
+				return nil, fmt.Errorf("%v in line %d", err, start)
+			}
+
+			if words == 0 {
+				directives = append(directives, snippetDirective{
+					name: snippet[i:end],
+					line: start,
+				})
+			} else {
+				last := &directives[len(directives)-1]
+				last.args = append(last.args, snippet[i:end])
+			}
+
+			words++
+			line += lines
+			i = end - 1
+		}
+	}
+
+	if words != 0 {
+		return nil, fmt.Errorf("unexpected end of snippet, the directive %q must end with \";\"", directives[len(directives)-1].name)
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf("unexpected end of snippet, expecting \"}\"")
+	}
+
+	return directives, nil
+}
+
+// scanSnippetWord returns the end of the word that starts at the index and the number of new lines inside it.
+func scanSnippetWord(snippet string, start int) (end int, lines int, err error) {
+	if quote := snippet[start]; quote == '"' || quote == '\'' {
+		for i := start + 1; i < len(snippet); i++ {
+			switch snippet[i] {
+			case '\\':
+				i++
+			case '\n':
+				lines++
+			case quote:
+				return i + 1, lines, nil
+			}
+		}
+
+		return 0, 0, fmt.Errorf("unterminated quoted string")
+	}
+
+	for i := start; i < len(snippet); i++ {
+		switch snippet[i] {
+		case ' ', '\t', '\r', '\n', ';', '{', '}':
+			return i, lines, nil
+		case '$':
+			// a variable like ${name} includes braces
+			if i+1 < len(snippet) && snippet[i+1] == '{' {
+				closing := strings.IndexByte(snippet[i:], '}')
+				if closing == -1 {
+					return 0, 0, fmt.Errorf("unterminated variable")
+				}
+				i += closing
+			}
+		case '\\':
+			i++
+		}
+	}
+
+	return len(snippet), lines, nil
+}
@@ -0,0 +1,236 @@
+package configs
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSnippetDirectives(t *testing.T) {
+	snippet := `# a comment
+add_header X-Test "value; with {braces}";
+location /test {
+    if ($http_x_test = 'a b') {
+        return 200 ${host};
+    }
+}
+proxy_set_header X-Multi "line one
+line two";
+rewrite ^/(.*)$ /new/$1 break;`
+
+	expected := []snippetDirective{
+		{name: "add_header", args: []string{"X-Test", `"value; with {braces}"`}, line: 2},
+		{name: "location", args: []string{"/test"}, line: 3},
+		{name: "if", args: []string{"($http_x_test", "=", "'a b'", ")"}, line: 4},
+		{name: "return", args: []string{"200", "${host}"}, line: 5},
+		{name: "proxy_set_header", args: []string{"X-Multi", "\"line one\nline two\""}, line: 8},
+		{name: "rewrite", args: []string{"^/(.*)$", "/new/$1", "break"}, line: 10},
+	}
+
+	result, err := parseSnippetDirectives(snippet)
+	if err != nil {
+		t.Fatalf("parseSnippetDirectives() returned unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("parseSnippetDirectives() returned %v but expected %v", result, expected)
+	}
+}
+
+func TestParseSnippetDirectivesFails(t *testing.T) {
+	snippets := []string{
+		"add_header X-Test value",
+		"location / {",
+		"location / { return 200; ",
+		"}",
+		"location / { return 200 }",
+		";",
+		"{ return 200; }",
+		`add_header X-Test "value;`,
+		"return 200 ${host;",
+	}
+
+	for _, snippet := range snippets {
+		_, err := parseSnippetDirectives(snippet)
+		if err == nil {
+			t.Errorf("parseSnippetDirectives(%q) returned no error", snippet)
+		}
+	}
+}
+
+func TestGetSnippetVariables(t *testing.T) {
+	snippets := []string{
+		`set $tea "green";`,
+		`map $http_x_coffee $coffee {`,
+		`    default 0;`,
+		`}`,
+		`geo $remote_addr $trusted { default 0; }`,
+		`geo $office { 10.0.0.0/8 1; }`,
+		`split_clients "${remote_addr}" $variant { 50% a; * b; }`,
+		`js_set $jwt_sub jwt.sub;`,
+		`add_header X-Tea $tea;`,
+	}
+
+	expected := []string{"tea", "coffee", "trusted", "office", "variant", "jwt_sub"}
+
+	result := getSnippetVariables(snippets)
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("getSnippetVariables() returned %v but expected %v", result, expected)
+	}
+
+	if result := getSnippetVariables([]string{"set $tea"}); result != nil {
+		t.Errorf("getSnippetVariables() returned %v for an invalid snippet but expected nil", result)
+	}
+}
+
+func TestValidateSnippets(t *testing.T) {
+	validator := NewSnippetsValidator(false, []string{"add_header"})
+
+	tests := []struct {
+		snippets []string
+		context  SnippetContext
+		msg      string
+	}{
+		{
+			snippets: nil,
+			context:  ServerSnippetContext,
+			msg:      "no snippets",
+		},
+		{
+			snippets: []string{"load_module modules/ngx_http_js_module.so;"},
+			context:  MainSnippetContext,
+			msg:      "load_module in main",
+		},
+		{
+			snippets: []string{"upstream backend {", "    server 127.0.0.1;", "}"},
+			context:  HTTPSnippetContext,
+			msg:      "upstream in http",
+		},
+		{
+			snippets: []string{"location /test {", "    proxy_pass http://backend;", "}"},
+			context:  ServerSnippetContext,
+			msg:      "location in server",
+		},
+	}
+
+	for _, test := range tests {
+		err := validator.ValidateSnippets(test.snippets, test.context)
+		if err != nil {
+			t.Errorf("ValidateSnippets() returned unexpected error for the case of %s: %v", test.msg, err)
+		}
+	}
+}
+
+func TestValidateSnippetsFails(t *testing.T) {
+	validator := NewSnippetsValidator(true, nil)
+
+	tests := []struct {
+		snippets []string
+		context  SnippetContext
+		msg      string
+	}{
+		{
+			snippets: []string{"http {", "}"},
+			context:  MainSnippetContext,
+			msg:      "http in main",
+		},
+		{
+			snippets: []string{"load_module modules/ngx_http_js_module.so;"},
+			context:  HTTPSnippetContext,
+			msg:      "load_module in http",
+		},
+		{
+			snippets: []string{"server {", "    listen 8080;", "}"},
+			context:  ServerSnippetContext,
+			msg:      "nested server",
+		},
+		{
+			snippets: []string{"if ($request_method = POST) {", "    upstream backend {", "    }", "}"},
+			context:  LocationSnippetContext,
+			msg:      "nested upstream in location",
+		},
+		{
+			snippets: []string{"add_header X-Test value"},
+			context:  LocationSnippetContext,
+			msg:      "missing semicolon",
+		},
+	}
+
+	for _, test := range tests {
+		err := validator.ValidateSnippets(test.snippets, test.context)
+		if err == nil {
+			t.Errorf("ValidateSnippets() returned no error for the case of %s", test.msg)
+		}
+	}
+}
+
+func TestValidateResourceSnippets(t *testing.T) {
+	snippets := []string{"add_header X-Test value;"}
+
+	err := NewSnippetsValidator(true, nil).ValidateResourceSnippets(snippets, LocationSnippetContext)
+	if err != nil {
+		t.Errorf("ValidateResourceSnippets() returned unexpected error when all directives are allowed: %v", err)
+	}
+
+	err = NewSnippetsValidator(true, []string{"add_header"}).ValidateResourceSnippets(snippets, LocationSnippetContext)
+	if err != nil {
+		t.Errorf("ValidateResourceSnippets() returned unexpected error for an allowed directive: %v", err)
+	}
+
+	err = NewSnippetsValidator(false, nil).ValidateResourceSnippets(nil, LocationSnippetContext)
+	if err != nil {
+		t.Errorf("ValidateResourceSnippets() returned unexpected error for no snippets: %v", err)
+	}
+}
+
+func TestValidateResourceSnippetsFails(t *testing.T) {
+	snippets := []string{"add_header X-Test value;"}
+
+	err := NewSnippetsValidator(false, nil).ValidateResourceSnippets(snippets, LocationSnippetContext)
+	if err == nil {
+		t.Errorf("ValidateResourceSnippets() returned no error when snippets are not allowed")
+	}
+
+	err = NewSnippetsValidator(true, []string{"proxy_set_header"}).ValidateResourceSnippets(snippets, LocationSnippetContext)
+	if err == nil {
+		t.Errorf("ValidateResourceSnippets() returned no error for a directive that is not allowed")
+	}
+}
+
+func TestValidateIngressSnippets(t *testing.T) {
+	validator := NewSnippetsValidator(true, nil)
+
+	annotations := map[string]string{
+		"nginx.org/server-snippets":   "location /test {\n    return 200;\n}",
+		"nginx.org/location-snippets": "add_header X-Test value;",
+	}
+
+	err := validator.ValidateIngressSnippets(annotations)
+	if err != nil {
+		t.Errorf("ValidateIngressSnippets() returned unexpected error: %v", err)
+	}
+
+	annotations["nginx.org/location-snippets"] = "server {\n}"
+
+	err = validator.ValidateIngressSnippets(annotations)
+	if err == nil {
+		t.Errorf("ValidateIngressSnippets() returned no error for a nested server")
+	}
+}
+
+func TestValidateConfigMapSnippets(t *testing.T) {
+	validator := NewSnippetsValidator(false, nil)
+
+	cfgParams := NewDefaultConfigParams()
+	cfgParams.MainHTTPSnippets = []string{"map $host $test {", "    default 1;", "}"}
+	cfgParams.ServerSnippets = []string{"server {", "}"}
+
+	err := validator.ValidateConfigMapSnippets(cfgParams)
+	if err == nil {
+		t.Errorf("ValidateConfigMapSnippets() returned no error for an invalid server snippet")
+	}
+	if cfgParams.ServerSnippets != nil {
+		t.Errorf("ValidateConfigMapSnippets() didn't remove the invalid server snippets: %v", cfgParams.ServerSnippets)
+	}
+	if len(cfgParams.MainHTTPSnippets) != 3 {
+		t.Errorf("ValidateConfigMapSnippets() removed the valid http snippets")
+	}
+}
@@ -79,6 +79,8 @@ type Server struct {
 	SetRealIPFrom   []string
 	RealIPRecursive bool
 
+	XForwardedHeaders []XForwardedHeader
+
 	JWTAuth              *JWTAuth
 	JWTRedirectLocations []JWTRedirectLocation
 
@@ -86,6 +88,12 @@ type Server struct {
 	SSLPorts []int
 }
 
+// XForwardedHeader describes an X-Forwarded-* header passed to the upstreams.
+type XForwardedHeader struct {
+	Name  string
+	Value string
+}
+
 // JWTRedirectLocation describes a location for redirecting client requests to a login URL for JWT Authentication.
 type JWTRedirectLocation struct {
 	Name     string
@@ -128,6 +136,7 @@ type MainConfig struct {
 	ServerNamesHashMaxSize         string
 	AccessLogOff                   bool
 	LogFormat                      string
+	LogFormatEscaping              string
 	ErrorLogLevel                  string
 	StreamLogFormat                string
 	HealthStatus                   bool
@@ -135,6 +144,9 @@ type MainConfig struct {
 	NginxStatus                    bool
 	NginxStatusAllowCIDRs          []string
 	NginxStatusPort                int
+	NginxPlusDashboardLocation     string
+	NginxPlusDashboardAllowCIDRs   []string
+	NginxPlusDashboardAuthFile     string
 	StubStatusOverUnixSocketForOSS bool
 	MainSnippets                   []string
 	HTTPSnippets                   []string
@@ -163,6 +175,7 @@ type MainConfig struct {
 	OpenTracingEnabled             bool
 	OpenTracingTracer              string
 	OpenTracingTracerConfig        string
+	OIDC                           bool
 }
 
 // NewUpstreamWithDefaultServer creates an upstream with the default server.
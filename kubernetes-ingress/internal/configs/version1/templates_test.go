@@ -2,6 +2,7 @@ package version1
 
 import (
 	"bytes"
+	"strings"
 	"testing"
 	"text/template"
 )
@@ -54,6 +55,10 @@ var ingCfg = IngressNginxConfig{
 			SSLCiphers:        "NULL",
 			SSLPorts:          []int{443},
 			SSLRedirect:       true,
+			XForwardedHeaders: []XForwardedHeader{
+				{Name: "X-Forwarded-For", Value: "$proxy_add_x_forwarded_for"},
+				{Name: "X-Forwarded-Proto", Value: "https"},
+			},
 			Locations: []Location{
 				{
 					Path:                "/tea",
@@ -99,6 +104,8 @@ var mainCfg = MainConfig{
 	WorkerConnections:       "1024",
 	WorkerRlimitNofile:      "65536",
 	StreamSnippets:          []string{"# comment"},
+	LogFormat:               `{"remote_addr":"$remote_addr","status":"$status"}`,
+	LogFormatEscaping:       "json",
 	StreamLogFormat:         "$remote_addr",
 	ResolverAddresses:       []string{"example.com", "127.0.0.1"},
 	ResolverIPV6:            false,
@@ -170,6 +177,40 @@ func TestMainForNGINX(t *testing.T) {
 	}
 }
 
+func TestMainForNGINXPlusWithDashboard(t *testing.T) {
+	tmpl, err := template.New(nginxPlusMainTmpl).ParseFiles(nginxPlusMainTmpl)
+	if err != nil {
+		t.Fatalf("Failed to parse template file: %v", err)
+	}
+
+	cfg := mainCfg
+	cfg.NginxStatus = true
+	cfg.NginxStatusPort = 8080
+	cfg.NginxStatusAllowCIDRs = []string{"127.0.0.1"}
+	cfg.NginxPlusDashboardLocation = "/dashboard"
+	cfg.NginxPlusDashboardAllowCIDRs = []string{"10.0.0.0/8"}
+	cfg.NginxPlusDashboardAuthFile = "/etc/nginx/secrets/dashboard-auth"
+
+	var buf bytes.Buffer
+
+	err = tmpl.Execute(&buf, cfg)
+	if err != nil {
+		t.Fatalf("Failed to write template %v", err)
+	}
+
+	expected := []string{
+		"location = /dashboard {",
+		"allow 10.0.0.0/8;",
+		"try_files /dashboard.html =404;",
+		"auth_basic_user_file /etc/nginx/secrets/dashboard-auth;",
+	}
+	for _, e := range expected {
+		if !strings.Contains(buf.String(), e) {
+			t.Errorf("The main config doesn't include %q:\n%v", e, buf.String())
+		}
+	}
+}
+
 func TestSplitHelperFunction(t *testing.T) {
 	const tpl = `{{range $n := split . ","}}{{$n}} {{end}}`
 
@@ -2,11 +2,21 @@ package version2
 
 // VirtualServerConfig holds NGINX configuration for a VirtualServer.
 type VirtualServerConfig struct {
-	Server        Server
-	Upstreams     []Upstream
-	SplitClients  []SplitClient
-	Maps          []Map
-	StatusMatches []StatusMatch
+	Server         Server
+	Upstreams      []Upstream
+	SplitClients   []SplitClient
+	Maps           []Map
+	Geos           []Geo
+	StatusMatches  []StatusMatch
+	LimitReqZones  []LimitReqZone
+	LimitConnZones []LimitConnZone
+	CacheZones     []CacheZone
+	KeyValZones    []KeyValZone
+	KeyVals        []KeyVal
+	LogFormat      *LogFormat
+	// KeyValPairs are set through the NGINX Plus API after the config is applied. They are not part of the config,
+	// so they are not hashed.
+	KeyValPairs []KeyValPair `json:"-"`
 }
 
 // Upstream defines an upstream.
@@ -28,6 +38,9 @@ type Upstream struct {
 // UpstreamServer defines an upstream server.
 type UpstreamServer struct {
 	Address string
+	Drain   bool
+	Down    bool
+	Backup  bool
 }
 
 // Server defines a server.
@@ -35,16 +48,32 @@ type Server struct {
 	ServerName                string
 	StatusZone                string
 	ProxyProtocol             bool
+	HTTPPort                  int
+	HTTPSPort                 int
 	SSL                       *SSL
 	ServerTokens              string
+	AddHeaders                []AddHeader
 	RealIPHeader              string
 	SetRealIPFrom             []string
 	RealIPRecursive           bool
+	XForwardedHeaders         []XForwardedHeader
+	AccessLog                 *AccessLog
+	ErrorLog                  *ErrorLog
+	RequestID                 *RequestID
 	Snippets                  []string
 	InternalRedirectLocations []InternalRedirectLocation
 	Locations                 []Location
 	HealthChecks              []HealthCheck
 	TLSRedirect               *TLSRedirect
+	LimitReqOptions           LimitReqOptions
+	LimitReqs                 []LimitReq
+	LimitConns                []LimitConn
+	PoliciesErrorReturn       *Return
+	JWTAuth                   *JWTAuth
+	BasicAuth                 *BasicAuth
+	OIDC                      *OIDC
+	EgressMTLS                *EgressMTLS
+	Tracing                   *Tracing
 }
 
 // SSL defines SSL configuration for a server.
@@ -55,6 +84,45 @@ type SSL struct {
 	Ciphers        string
 }
 
+// AccessLog defines the access log of a server.
+type AccessLog struct {
+	Off         bool
+	Destination string
+	FormatName  string
+}
+
+// ErrorLog defines the error log of a server.
+type ErrorLog struct {
+	Destination string
+	Level       string
+}
+
+// RequestID defines the header with the ID of a request passed to the upstreams of a server.
+type RequestID struct {
+	Header   string
+	Variable string
+}
+
+// LogFormat defines a log format.
+type LogFormat struct {
+	Name   string
+	Escape string
+	Format string
+}
+
+// Tracing defines the OpenTracing configuration of a server.
+type Tracing struct {
+	Enable        bool
+	OperationName string
+	Tags          []TracingTag
+}
+
+// TracingTag defines a tag of the spans of the requests to a server.
+type TracingTag struct {
+	Name  string
+	Value string
+}
+
 // Location defines a location.
 type Location struct {
 	Path                     string
@@ -63,17 +131,35 @@ type Location struct {
 	ProxyReadTimeout         string
 	ProxySendTimeout         string
 	ClientMaxBodySize        string
+	ClientBodyTimeout        string
 	ProxyMaxTempFileSize     string
 	ProxyBuffering           bool
 	ProxyBuffers             string
 	ProxyBufferSize          string
+	ProxyRequestBuffering    string
+	ProxyIgnoreHeaders       string
 	ProxyPass                string
 	ProxyNextUpstream        string
 	ProxyNextUpstreamTimeout string
 	ProxyNextUpstreamTries   int
+	LimitRate                string
+	LimitRateAfter           string
+	ProxyCache               *ProxyCache
+	StaticRoot               string
+	ServeContent             *ServeContent
 	HasKeepalive             bool
 	DefaultType              string
 	Return                   *Return
+	LimitReqOptions          LimitReqOptions
+	LimitReqs                []LimitReq
+	LimitConns               []LimitConn
+	PoliciesErrorReturn      *Return
+	JWTAuth                  *JWTAuth
+	BasicAuth                *BasicAuth
+	EgressMTLS               *EgressMTLS
+	ProxyHideHeaders         []string
+	AddHeaders               []AddHeader
+	CORS                     *CORS
 }
 
 // SplitClient defines a split_clients.
@@ -83,6 +169,33 @@ type SplitClient struct {
 	Distributions []Distribution
 }
 
+// Geo defines a geo, which sets a variable by the address of the client.
+type Geo struct {
+	Source     string
+	Variable   string
+	Parameters []Parameter
+}
+
+// KeyValZone defines a keyval_zone.
+type KeyValZone struct {
+	Name string
+	Size string
+}
+
+// KeyVal defines a keyval, which sets a variable to the value of a key in a keyval_zone.
+type KeyVal struct {
+	Key      string
+	Variable string
+	ZoneName string
+}
+
+// KeyValPair defines a pair of a keyval_zone set through the NGINX Plus API.
+type KeyValPair struct {
+	ZoneName string
+	Key      string
+	Value    string
+}
+
 // Return defines a Return directive used for redirects and canned responses.
 type Return struct {
 	Code int
@@ -104,6 +217,13 @@ type HealthCheck struct {
 	ProxySendTimeout    string
 	Headers             map[string]string
 	Match               string
+	Mandatory           bool
+	Persistent          bool
+	KeepaliveTime       string
+	ProxySSLServerName  bool
+	ProxySSLName        string
+	ProxySSLVerify      bool
+	ProxySSLTrustedCert string
 }
 
 // TLSRedirect defines a redirect in a Server.
@@ -148,10 +268,12 @@ type Parameter struct {
 	Result string
 }
 
-// StatusMatch defines a Match block for status codes.
+// StatusMatch defines a Match block for the responses to health checks.
 type StatusMatch struct {
-	Name string
-	Code string
+	Name    string
+	Code    string
+	Headers []string
+	Body    string
 }
 
 // Queue defines a queue in upstream.
@@ -159,3 +281,132 @@ type Queue struct {
 	Size    int
 	Timeout string
 }
+
+// LimitReqZone defines a rate limit shared memory zone.
+type LimitReqZone struct {
+	Key      string
+	ZoneName string
+	ZoneSize string
+	Rate     string
+}
+
+// LimitReq defines a rate limit.
+type LimitReq struct {
+	ZoneName string
+	Burst    int
+	NoDelay  bool
+	Delay    int
+}
+
+// LimitReqOptions defines rate limit options.
+type LimitReqOptions struct {
+	DryRun     bool
+	LogLevel   string
+	RejectCode int
+}
+
+// LimitConnZone defines a connection limit shared memory zone.
+type LimitConnZone struct {
+	Key      string
+	ZoneName string
+	ZoneSize string
+}
+
+// LimitConn defines a connection limit.
+type LimitConn struct {
+	ZoneName string
+	Max      int
+}
+
+// CacheZone defines a cache with its shared memory zone.
+type CacheZone struct {
+	Name string
+	Path string
+	Size string
+}
+
+// ProxyCache defines the caching of the responses of a location.
+type ProxyCache struct {
+	ZoneName string
+	// ZoneSize is used to generate the CacheZone of the ZoneName.
+	ZoneSize string
+	Key      string
+	Valid    []string
+	Bypass   []string
+	NoCache  []string
+	MinUses  int
+	Purge    *ProxyCachePurge
+}
+
+// ServeContent defines the content of a ConfigMap served by a location.
+type ServeContent struct {
+	ConfigMap string
+	Key       string
+}
+
+// ProxyCachePurge defines the purging of the cache of a location.
+type ProxyCachePurge struct {
+	// Variable is not empty and not "0" for the requests that purge the cache.
+	Variable string
+	// Allow is used to generate the Variable.
+	Allow []string
+}
+
+// XForwardedHeader defines an X-Forwarded-* header passed to the upstreams.
+type XForwardedHeader struct {
+	Name  string
+	Value string
+}
+
+// AddHeader defines a header to be added to a response.
+type AddHeader struct {
+	Name  string
+	Value string
+}
+
+// JWTAuth holds JWT authentication configuration.
+type JWTAuth struct {
+	Secret string
+	Realm  string
+	Token  string
+}
+
+// BasicAuth holds HTTP Basic authentication configuration.
+type BasicAuth struct {
+	Secret string
+	Realm  string
+}
+
+// CORS holds the Cross-Origin Resource Sharing headers of a location.
+// The preflight requests are answered by NGINX with the preflight headers.
+type CORS struct {
+	Headers           []AddHeader
+	PreflightVariable string
+	PreflightHeaders  []AddHeader
+}
+
+// EgressMTLS holds the configuration of TLS connections to upstreams.
+type EgressMTLS struct {
+	Certificate    string
+	CertificateKey string
+	VerifyServer   bool
+	VerifyDepth    int
+	Protocols      string
+	SessionReuse   bool
+	Ciphers        string
+	TrustedCert    string
+	ServerName     bool
+	SSLName        string
+}
+
+// OIDC holds OpenID Connect configuration.
+type OIDC struct {
+	AuthEndpoint  string
+	TokenEndpoint string
+	JwksURI       string
+	ClientID      string
+	ClientSecret  string
+	Scope         string
+	RedirectURI   string
+	HMACKey       string
+}
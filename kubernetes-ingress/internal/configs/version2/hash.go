@@ -0,0 +1,20 @@
+package version2
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+)
+
+// Hash returns the hash of the canonical serialization of the config. The template of a VirtualServer is executed
+// only with the config, so the configs with the same hash generate the same NGINX configuration.
+// The serialization is canonical, because the fields of the structs are serialized in the order of their declaration
+// and the keys of the maps are sorted.
+func (cfg *VirtualServerConfig) Hash() (string, error) {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return "", fmt.Errorf("Error serializing VirtualServer config: %v", err)
+	}
+
+	return fmt.Sprintf("%x", sha256.Sum256(data)), nil
+}
@@ -0,0 +1,30 @@
+package version2
+
+import "testing"
+
+func TestVirtualServerConfigHash(t *testing.T) {
+	hash, err := virtualServerCfg.Hash()
+	if err != nil {
+		t.Fatalf("Hash() returned an unexpected error: %v", err)
+	}
+
+	cfg := virtualServerCfg
+	sameHash, err := cfg.Hash()
+	if err != nil {
+		t.Fatalf("Hash() returned an unexpected error: %v", err)
+	}
+	if sameHash != hash {
+		t.Errorf("Hash() returned %s for the same config, but expected %s", sameHash, hash)
+	}
+
+	cfg.Upstreams = append([]Upstream{}, cfg.Upstreams...)
+	cfg.Upstreams[0].Servers = []UpstreamServer{{Address: "10.0.0.100:8080"}}
+
+	changedHash, err := cfg.Hash()
+	if err != nil {
+		t.Fatalf("Hash() returned an unexpected error: %v", err)
+	}
+	if changedHash == hash {
+		t.Errorf("Hash() returned the same hash %s for a changed config", changedHash)
+	}
+}
@@ -2,13 +2,87 @@ package version2
 
 import (
 	"bytes"
+	"fmt"
+	"io"
 	"path"
+	"sync"
 	"text/template"
 )
 
 // TemplateExecutor executes NGINX configuration templates.
 type TemplateExecutor struct {
 	virtualServerTemplate *template.Template
+	// templateMutex protects virtualServerTemplate, which can be updated while the configs are generated.
+	templateMutex sync.RWMutex
+	// buffers are reused across the executions, so that a buffer doesn't grow to the size of a config every time.
+	buffers sync.Pool
+}
+
+// sampleVirtualServerConfig is the config that a new template of VirtualServers is executed with before it is used.
+// It has an upstream, a split client, a map and a location, so the result covers the common parts of a config.
+var sampleVirtualServerConfig = VirtualServerConfig{
+	Upstreams: []Upstream{
+		{
+			Name: "vs_default_sample_backend",
+			Servers: []UpstreamServer{
+				{
+					Address: "127.0.0.1:8080",
+				},
+			},
+			MaxFails:         1,
+			FailTimeout:      "10s",
+			UpstreamZoneSize: "256k",
+		},
+	},
+	SplitClients: []SplitClient{
+		{
+			Source:   "$request_id",
+			Variable: "$vs_default_sample_splits_0",
+			Distributions: []Distribution{
+				{
+					Weight: "100%",
+					Value:  "@splits_0_split_0",
+				},
+			},
+		},
+	},
+	Maps: []Map{
+		{
+			Source:   "$http_x_version",
+			Variable: "$vs_default_sample_matches_0",
+			Parameters: []Parameter{
+				{
+					Value:  "default",
+					Result: "@splits_0_split_0",
+				},
+			},
+		},
+	},
+	Server: Server{
+		ServerName:   "sample.example.com",
+		StatusZone:   "sample.example.com",
+		ServerTokens: "on",
+		InternalRedirectLocations: []InternalRedirectLocation{
+			{
+				Path:        "/",
+				Destination: "$vs_default_sample_matches_0",
+			},
+		},
+		Locations: []Location{
+			{
+				Path:                     "@splits_0_split_0",
+				ProxyConnectTimeout:      "60s",
+				ProxyReadTimeout:         "60s",
+				ProxySendTimeout:         "60s",
+				ClientMaxBodySize:        "1m",
+				ProxyBuffering:           true,
+				ProxyPass:                "http://vs_default_sample_backend",
+				ProxyNextUpstream:        "error timeout",
+				ProxyNextUpstreamTimeout: "0s",
+				ProxyNextUpstreamTries:   0,
+			},
+		},
+	},
 }
 
 // NewTemplateExecutor creates a TemplateExecutor.
@@ -24,10 +98,62 @@ func NewTemplateExecutor(virtualServerTemplatePath string) (*TemplateExecutor, e
 	}, nil
 }
 
+// UpdateVirtualServerTemplate replaces the template of VirtualServers, for example, with the template of the ConfigMap.
+// The new template is executed with a sample config, and the result is checked with testConfig, if it's not nil.
+// If the template fails to parse, to execute or the check, the current template is kept.
+func (te *TemplateExecutor) UpdateVirtualServerTemplate(templateString *string, testConfig func(content []byte) error) error {
+	newTemplate, err := template.New("virtualServerTemplate").Parse(*templateString)
+	if err != nil {
+		return err
+	}
+
+	var sample bytes.Buffer
+	if err := newTemplate.Execute(&sample, &sampleVirtualServerConfig); err != nil {
+		return fmt.Errorf("error executing the template with a sample config: %v", err)
+	}
+
+	if testConfig != nil {
+		if err := testConfig(sample.Bytes()); err != nil {
+			return fmt.Errorf("the config generated from the template with a sample config is invalid: %v", err)
+		}
+	}
+
+	te.templateMutex.Lock()
+	defer te.templateMutex.Unlock()
+
+	te.virtualServerTemplate = newTemplate
+
+	return nil
+}
+
+func (te *TemplateExecutor) getVirtualServerTemplate() *template.Template {
+	te.templateMutex.RLock()
+	defer te.templateMutex.RUnlock()
+
+	return te.virtualServerTemplate
+}
+
 // ExecuteVirtualServerTemplate generates the content of an NGINX configuration file for a VirtualServer resource.
+// It is safe to call it concurrently.
 func (te *TemplateExecutor) ExecuteVirtualServerTemplate(cfg *VirtualServerConfig) ([]byte, error) {
-	var configBuffer bytes.Buffer
-	err := te.virtualServerTemplate.Execute(&configBuffer, cfg)
+	configBuffer, ok := te.buffers.Get().(*bytes.Buffer)
+	if !ok {
+		configBuffer = new(bytes.Buffer)
+	}
+	defer te.buffers.Put(configBuffer)
+	configBuffer.Reset()
+
+	err := te.getVirtualServerTemplate().Execute(configBuffer, cfg)
+
+	// the buffer is reused, so the content is copied
+	content := make([]byte, configBuffer.Len())
+	copy(content, configBuffer.Bytes())
+
+	return content, err
+}
 
-	return configBuffer.Bytes(), err
+// WriteVirtualServerTemplate generates the content of an NGINX configuration file for a VirtualServer resource
+// and writes it to w. It is safe to call it concurrently for different writers.
+func (te *TemplateExecutor) WriteVirtualServerTemplate(w io.Writer, cfg *VirtualServerConfig) error {
+	return te.getVirtualServerTemplate().Execute(w, cfg)
 }
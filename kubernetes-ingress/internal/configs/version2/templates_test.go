@@ -1,6 +1,11 @@
 package version2
 
-import "testing"
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
 
 const nginxPlusVirtualServerTmpl = "nginx-plus.virtualserver.tmpl"
 const nginxVirtualServerTmpl = "nginx.virtualserver.tmpl"
@@ -42,6 +47,18 @@ var virtualServerCfg = VirtualServerConfig{
 				{
 					Address: "10.0.0.32:8001",
 				},
+				{
+					Address: "10.0.0.33:8001",
+					Drain:   true,
+				},
+				{
+					Address: "10.0.0.34:8001",
+					Down:    true,
+				},
+				{
+					Address: "10.0.0.35:8001",
+					Backup:  true,
+				},
 			},
 			MaxFails:         12,
 			FailTimeout:      "20s",
@@ -49,6 +66,19 @@ var virtualServerCfg = VirtualServerConfig{
 			UpstreamZoneSize: "256k",
 		},
 	},
+	KeyValZones: []KeyValZone{
+		{
+			Name: "vs_default_cafe_split_weights",
+			Size: "64k",
+		},
+	},
+	KeyVals: []KeyVal{
+		{
+			Key:      "vs_default_cafe_splits_0",
+			Variable: "$vs_default_cafe_splits_0_weight",
+			ZoneName: "vs_default_cafe_split_weights",
+		},
+	},
 	SplitClients: []SplitClient{
 		{
 			Source:   "$request_id",
@@ -65,6 +95,22 @@ var virtualServerCfg = VirtualServerConfig{
 			},
 		},
 	},
+	Geos: []Geo{
+		{
+			Source:   "$remote_addr",
+			Variable: "$match_0_1_geo",
+			Parameters: []Parameter{
+				{
+					Value:  "default",
+					Result: "0",
+				},
+				{
+					Value:  "10.0.0.0/8",
+					Result: "1",
+				},
+			},
+		},
+	},
 	Maps: []Map{
 		{
 			Source:   "$match_0_0",
@@ -95,10 +141,32 @@ var virtualServerCfg = VirtualServerConfig{
 			},
 		},
 	},
+	LimitReqZones: []LimitReqZone{
+		{
+			ZoneName: "pol_rl_test_test_test", Rate: "10r/s", ZoneSize: "10m", Key: "$url",
+		},
+	},
+	LimitConnZones: []LimitConnZone{
+		{
+			ZoneName: "vs_default_cafe_server_lc", ZoneSize: "10m", Key: "${binary_remote_addr}",
+		},
+	},
+	CacheZones: []CacheZone{
+		{
+			Name: "vs_default_cafe_cache_tea", Path: "/var/cache/nginx/vs_default_cafe_cache_tea", Size: "10m",
+		},
+	},
+	LogFormat: &LogFormat{
+		Name:   "vs_default_cafe_log_format",
+		Escape: "json",
+		Format: `{\"remote_addr\":\"$remote_addr\",\"status\":\"$status\"}`,
+	},
 	Server: Server{
 		ServerName:    "example.com",
 		StatusZone:    "example.com",
 		ProxyProtocol: true,
+		HTTPPort:      8080,
+		HTTPSPort:     8443,
 		SSL: &SSL{
 			HTTP2:          true,
 			Certificate:    "cafe-secret.pem",
@@ -109,11 +177,79 @@ var virtualServerCfg = VirtualServerConfig{
 			BasedOn: "$scheme",
 			Code:    301,
 		},
-		ServerTokens:    "off",
+		XForwardedHeaders: []XForwardedHeader{
+			{Name: "X-Forwarded-For", Value: "$proxy_add_x_forwarded_for"},
+			{Name: "X-Forwarded-Proto", Value: "$scheme"},
+			{Name: "X-Forwarded-Host", Value: `""`},
+		},
+		ServerTokens: "off",
+		AddHeaders: []AddHeader{
+			{
+				Name:  "X-NGINX-Warnings-Count",
+				Value: "1",
+			},
+		},
 		SetRealIPFrom:   []string{"0.0.0.0/0"},
 		RealIPHeader:    "X-Real-IP",
 		RealIPRecursive: true,
-		Snippets:        []string{"# server snippet"},
+		AccessLog: &AccessLog{
+			Destination: "/var/log/nginx/access.log",
+			FormatName:  "vs_default_cafe_log_format",
+		},
+		ErrorLog: &ErrorLog{
+			Destination: "syslog:server=10.0.0.1:514,tag=cafe",
+			Level:       "warn",
+		},
+		Tracing: &Tracing{
+			Enable:        true,
+			OperationName: "${request_method} ${uri}",
+			Tags: []TracingTag{
+				{Name: "component", Value: "nginx-ingress"},
+			},
+		},
+		RequestID: &RequestID{
+			Header:   "X-Request-ID",
+			Variable: "$request_id",
+		},
+		Snippets: []string{"# server snippet"},
+		LimitReqOptions: LimitReqOptions{
+			DryRun:     true,
+			LogLevel:   "error",
+			RejectCode: 503,
+		},
+		LimitReqs: []LimitReq{
+			{
+				ZoneName: "pol_rl_test_test_test",
+				Delay:    10,
+				Burst:    5,
+			},
+		},
+		LimitConns: []LimitConn{
+			{
+				ZoneName: "vs_default_cafe_server_lc",
+				Max:      100,
+			},
+		},
+		JWTAuth: &JWTAuth{
+			Realm:  "My Api",
+			Secret: "jwk-secret",
+		},
+		BasicAuth: &BasicAuth{
+			Realm:  "My Api",
+			Secret: "htpasswd-secret",
+		},
+		EgressMTLS: &EgressMTLS{
+			Certificate:    "mtls-secret.pem",
+			CertificateKey: "mtls-secret.pem",
+			VerifyServer:   true,
+			VerifyDepth:    1,
+			Protocols:      "TLSv1.3",
+			SessionReuse:   true,
+			Ciphers:        "DEFAULT",
+			TrustedCert:    "ca-secret.crt",
+			ServerName:     true,
+			SSLName:        "$proxy_host",
+		},
 		InternalRedirectLocations: []InternalRedirectLocation{
 			{
 				Path:        "/split",
@@ -132,13 +268,29 @@ var virtualServerCfg = VirtualServerConfig{
 				ProxyReadTimeout:         "31s",
 				ProxySendTimeout:         "32s",
 				ClientMaxBodySize:        "1m",
+				ClientBodyTimeout:        "60s",
 				ProxyBuffering:           true,
 				ProxyBuffers:             "8 4k",
 				ProxyBufferSize:          "4k",
+				ProxyRequestBuffering:    "off",
+				ProxyIgnoreHeaders:       "X-Accel-Expires Cache-Control",
 				ProxyMaxTempFileSize:     "1024m",
 				ProxyPass:                "http://test-upstream",
 				ProxyNextUpstream:        "error timeout",
 				ProxyNextUpstreamTimeout: "5s",
+				LimitRate:                "100k",
+				LimitRateAfter:           "10m",
+				ProxyCache: &ProxyCache{
+					ZoneName: "vs_default_cafe_cache_tea",
+					Key:      "${scheme}${proxy_host}${request_uri}",
+					Valid:    []string{"200 302 10m", "404 1m"},
+					Bypass:   []string{"${cookie_nocache}", "${arg_nocache}"},
+					NoCache:  []string{"${http_pragma}"},
+					MinUses:  2,
+					Purge: &ProxyCachePurge{
+						Variable: "$vs_default_cafe_cache_purge_0",
+					},
+				},
 			},
 			{
 				Path:                     "@loc0",
@@ -149,6 +301,13 @@ var virtualServerCfg = VirtualServerConfig{
 				ProxyPass:                "http://coffee-v1",
 				ProxyNextUpstream:        "error timeout",
 				ProxyNextUpstreamTimeout: "5s",
+				ProxyHideHeaders:         []string{"Cache-Control"},
+				AddHeaders: []AddHeader{
+					{
+						Name:  "Cache-Control",
+						Value: "no-store",
+					},
+				},
 			},
 			{
 				Path:                     "@loc1",
@@ -159,6 +318,29 @@ var virtualServerCfg = VirtualServerConfig{
 				ProxyPass:                "http://coffee-v2",
 				ProxyNextUpstream:        "error timeout",
 				ProxyNextUpstreamTimeout: "5s",
+				CORS: &CORS{
+					Headers: []AddHeader{
+						{
+							Name:  "Access-Control-Allow-Origin",
+							Value: "$pol_cors_default_cors_policy_default_cafe_origin",
+						},
+						{
+							Name:  "Vary",
+							Value: "Origin",
+						},
+					},
+					PreflightVariable: "$vs_default_cafe_cors_preflight",
+					PreflightHeaders: []AddHeader{
+						{
+							Name:  "Access-Control-Allow-Methods",
+							Value: "GET, POST",
+						},
+						{
+							Name:  "Access-Control-Max-Age",
+							Value: "3600",
+						},
+					},
+				},
 			},
 			{
 				Path:                     "@match_loc_0",
@@ -180,6 +362,10 @@ var virtualServerCfg = VirtualServerConfig{
 				ProxyNextUpstream:        "error timeout",
 				ProxyNextUpstreamTimeout: "5s",
 			},
+			{
+				Path:       "/maintenance",
+				StaticRoot: "/var/lib/nginx/static/maintenance",
+			},
 		},
 	},
 }
@@ -198,6 +384,43 @@ func TestVirtualServerForNginxPlus(t *testing.T) {
 	t.Log(string(data))
 }
 
+func TestVirtualServerWithOIDCForNginxPlus(t *testing.T) {
+	executor, err := NewTemplateExecutor(nginxPlusVirtualServerTmpl)
+	if err != nil {
+		t.Fatalf("Failed to create template executor: %v", err)
+	}
+
+	cfg := virtualServerCfg
+	cfg.Server.JWTAuth = nil
+	cfg.Server.OIDC = &OIDC{
+		AuthEndpoint:  "https://idp.example.com/auth",
+		TokenEndpoint: "https://idp.example.com/token",
+		JwksURI:       "https://idp.example.com/certs",
+		ClientID:      "nginx-plus",
+		ClientSecret:  "super-secret",
+		Scope:         "openid",
+		RedirectURI:   "/_codexch",
+		HMACKey:       "hmac-key",
+	}
+
+	data, err := executor.ExecuteVirtualServerTemplate(&cfg)
+	if err != nil {
+		t.Fatalf("Failed to execute template: %v", err)
+	}
+
+	expectedDirectives := []string{
+		"include oidc/openid_connect.server_conf;",
+		`set $oidc_client_secret "super-secret";`,
+		"location = /_codexch {",
+		"error_page 401 = @do_oidc_flow;",
+	}
+	for _, d := range expectedDirectives {
+		if !strings.Contains(string(data), d) {
+			t.Errorf("ExecuteVirtualServerTemplate() returned config without %q", d)
+		}
+	}
+}
+
 func TestVirtualServerForNginx(t *testing.T) {
 	executor, err := NewTemplateExecutor(nginxVirtualServerTmpl)
 	if err != nil {
@@ -211,3 +434,141 @@ func TestVirtualServerForNginx(t *testing.T) {
 
 	t.Log(string(data))
 }
+
+func TestExecuteVirtualServerTemplateWithReusedBuffers(t *testing.T) {
+	executor, err := NewTemplateExecutor(nginxVirtualServerTmpl)
+	if err != nil {
+		t.Fatalf("Failed to create template executor: %v", err)
+	}
+
+	first, err := executor.ExecuteVirtualServerTemplate(&virtualServerCfg)
+	if err != nil {
+		t.Fatalf("Failed to execute template: %v", err)
+	}
+	expected := string(first)
+
+	cfg := virtualServerCfg
+	cfg.Server.ServerName = "tea.example.com"
+	if _, err := executor.ExecuteVirtualServerTemplate(&cfg); err != nil {
+		t.Fatalf("Failed to execute template: %v", err)
+	}
+
+	if string(first) != expected {
+		t.Error("ExecuteVirtualServerTemplate() returned config that was changed by the next execution")
+	}
+}
+
+func TestWriteVirtualServerTemplate(t *testing.T) {
+	executor, err := NewTemplateExecutor(nginxVirtualServerTmpl)
+	if err != nil {
+		t.Fatalf("Failed to create template executor: %v", err)
+	}
+
+	expected, err := executor.ExecuteVirtualServerTemplate(&virtualServerCfg)
+	if err != nil {
+		t.Fatalf("Failed to execute template: %v", err)
+	}
+
+	var result bytes.Buffer
+	if err := executor.WriteVirtualServerTemplate(&result, &virtualServerCfg); err != nil {
+		t.Fatalf("Failed to write template: %v", err)
+	}
+
+	if !bytes.Equal(result.Bytes(), expected) {
+		t.Errorf("WriteVirtualServerTemplate() wrote %q but expected %q", result.String(), expected)
+	}
+}
+
+func BenchmarkExecuteVirtualServerTemplate(b *testing.B) {
+	executor, err := NewTemplateExecutor(nginxPlusVirtualServerTmpl)
+	if err != nil {
+		b.Fatalf("Failed to create template executor: %v", err)
+	}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := executor.ExecuteVirtualServerTemplate(&virtualServerCfg); err != nil {
+			b.Fatalf("Failed to execute template: %v", err)
+		}
+	}
+}
+
+func TestUpdateVirtualServerTemplate(t *testing.T) {
+	executor, err := NewTemplateExecutor(nginxVirtualServerTmpl)
+	if err != nil {
+		t.Fatalf("Failed to create template executor: %v", err)
+	}
+
+	var tested []byte
+	testConfig := func(content []byte) error {
+		tested = content
+		return nil
+	}
+
+	newTemplate := "# custom\nserver_name {{ .Server.ServerName }};"
+	if err := executor.UpdateVirtualServerTemplate(&newTemplate, testConfig); err != nil {
+		t.Fatalf("UpdateVirtualServerTemplate() returned unexpected error: %v", err)
+	}
+
+	if expected := "# custom\nserver_name sample.example.com;"; string(tested) != expected {
+		t.Errorf("UpdateVirtualServerTemplate() tested %q but expected %q", tested, expected)
+	}
+
+	result, err := executor.ExecuteVirtualServerTemplate(&virtualServerCfg)
+	if err != nil {
+		t.Fatalf("Failed to execute template: %v", err)
+	}
+	if expected := "# custom\nserver_name example.com;"; string(result) != expected {
+		t.Errorf("ExecuteVirtualServerTemplate() returned %q after the update but expected %q", result, expected)
+	}
+}
+
+func TestUpdateVirtualServerTemplateFails(t *testing.T) {
+	executor, err := NewTemplateExecutor(nginxVirtualServerTmpl)
+	if err != nil {
+		t.Fatalf("Failed to create template executor: %v", err)
+	}
+
+	expected, err := executor.ExecuteVirtualServerTemplate(&virtualServerCfg)
+	if err != nil {
+		t.Fatalf("Failed to execute template: %v", err)
+	}
+
+	failingTest := func(content []byte) error {
+		return errors.New("nginx -t failed")
+	}
+
+	tests := []struct {
+		template   string
+		testConfig func(content []byte) error
+		msg        string
+	}{
+		{
+			template: "{{ .Server.ServerName ",
+			msg:      "template that can't be parsed",
+		},
+		{
+			template: "{{ .Server.Unknown }}",
+			msg:      "template that can't be executed",
+		},
+		{
+			template:   "server_name {{ .Server.ServerName }};",
+			testConfig: failingTest,
+			msg:        "template that fails the test",
+		},
+	}
+
+	for _, test := range tests {
+		if err := executor.UpdateVirtualServerTemplate(&test.template, test.testConfig); err == nil {
+			t.Errorf("UpdateVirtualServerTemplate() returned no error for the case of %s", test.msg)
+		}
+
+		result, err := executor.ExecuteVirtualServerTemplate(&virtualServerCfg)
+		if err != nil {
+			t.Fatalf("Failed to execute template: %v", err)
+		}
+		if !bytes.Equal(result, expected) {
+			t.Errorf("UpdateVirtualServerTemplate() changed the template for the case of %s", test.msg)
+		}
+	}
+}
@@ -1,7 +1,11 @@
 package configs
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/golang/glog"
@@ -16,6 +20,12 @@ import (
 
 const nginx502Server = "unix:/var/lib/nginx/nginx-502-server.sock"
 
+// cacheRootPath is the directory of the caches of VirtualServers.
+const cacheRootPath = "/var/cache/nginx"
+
+// staticContentRootPath is the directory where the volumes with the static content served by VirtualServers are mounted.
+const staticContentRootPath = "/var/lib/nginx/static"
+
 var incompatibleLBMethodsForSlowStart = map[string]bool{
 	"random":                          true,
 	"ip_hash":                         true,
@@ -27,11 +37,23 @@ var incompatibleLBMethodsForSlowStart = map[string]bool{
 
 // VirtualServerEx holds a VirtualServer along with the resources that are referenced in this VirtualServer.
 type VirtualServerEx struct {
-	VirtualServer       *conf_v1.VirtualServer
-	Endpoints           map[string][]string
-	TLSSecret           *api_v1.Secret
-	VirtualServerRoutes []*conf_v1.VirtualServerRoute
-	ExternalNameSvcs    map[string]bool
+	VirtualServer        *conf_v1.VirtualServer
+	Endpoints            map[string][]string
+	TLSSecret            *api_v1.Secret
+	VirtualServerRoutes  []*conf_v1.VirtualServerRoute
+	ExternalNameSvcs     map[string]bool
+	DrainedEndpoints     map[string]bool
+	TerminatingEndpoints map[string]bool
+	RemoteZoneEndpoints  map[string]bool
+	HealthCheckPorts     map[string]int32
+	Policies             map[string]*conf_v1.Policy
+	JWTKeys              map[string]*api_v1.Secret
+	OIDCSecrets          map[string]*api_v1.Secret
+	EgressTLSSecrets     map[string]*api_v1.Secret
+	TrustedCASecrets     map[string]*api_v1.Secret
+	HtpasswdSecrets      map[string]*api_v1.Secret
+	// ConfigMaps are the ConfigMaps with the content served by the actions, keyed by their namespace/name.
+	ConfigMaps map[string]*api_v1.ConfigMap
 }
 
 func (vsx *VirtualServerEx) String() string {
@@ -47,26 +69,119 @@ func (vsx *VirtualServerEx) String() string {
 }
 
 // GenerateEndpointsKey generates a key for the Endpoints map in VirtualServerEx.
-func GenerateEndpointsKey(serviceNamespace string, serviceName string, subselector map[string]string, port uint16) string {
+func GenerateEndpointsKey(serviceNamespace string, serviceName string, subselector map[string]string, port uint16, portName string) string {
+	servicePort := fmt.Sprint(port)
+	if portName != "" {
+		servicePort = portName
+	}
+
 	if len(subselector) > 0 {
-		return fmt.Sprintf("%s/%s_%s:%d", serviceNamespace, serviceName, labels.Set(subselector).String(), port)
+		return fmt.Sprintf("%s/%s_%s:%s", serviceNamespace, serviceName, labels.Set(subselector).String(), servicePort)
+	}
+	return fmt.Sprintf("%s/%s:%s", serviceNamespace, serviceName, servicePort)
+}
+
+// maxNameComponentLength is the maximum length of a namespace or a name of a resource in the names of upstreams,
+// variables and zones. Kubernetes allows names of up to 253 characters, so without a limit the names of the upstreams
+// of a VirtualServerRoute could exceed the limits of NGINX.
+const maxNameComponentLength = 40
+
+// nameComponentHashLength is the number of hex characters of the hash that replaces the end of an over-long component.
+const nameComponentHashLength = 8
+
+// shortenNameComponent shortens a namespace or a name longer than maxNameComponentLength to a prefix of it followed by
+// a dash and a hash of the whole component, so that the shortened component is deterministic and unlikely to collide.
+// The shortened component doesn't include underscores, which separate the components of the names of upstreams.
+func shortenNameComponent(component string) string {
+	if len(component) <= maxNameComponentLength {
+		return component
+	}
+
+	h := sha256.Sum256([]byte(component))
+	prefixLength := maxNameComponentLength - nameComponentHashLength - 1
+
+	return fmt.Sprintf("%s-%s", component[:prefixLength], hex.EncodeToString(h[:])[:nameComponentHashLength])
+}
+
+// GetSafeNsNameForVirtualServer returns the namespace and the name of the VirtualServer as they appear in the names
+// of the variables and zones of its config. The controller uses it to detect VirtualServers whose names collide.
+func GetSafeNsNameForVirtualServer(virtualServer *conf_v1.VirtualServer) string {
+	return getSafeNsName(virtualServer.Namespace, virtualServer.Name)
+}
+
+func getSafeNsName(namespace string, name string) string {
+	return strings.ReplaceAll(fmt.Sprintf("%s_%s", shortenNameComponent(namespace), shortenNameComponent(name)), "-", "_")
+}
+
+// routeIDLength is the number of hex characters of the hash of the path of a route in its ID.
+const routeIDLength = 8
+
+// generateRouteID generates the ID of a route from a hash of its path. The names of the variables, the zones
+// and the locations of the route include the ID instead of the position of the route, so that adding or removing a route
+// doesn't rename the variables and the zones of the other routes. The paths of the routes are unique, so the IDs only collide if their
+// hashes do, in which case the path is hashed again with a counter.
+func generateRouteID(path string, routeIDs map[string]bool) string {
+	content := path
+	for i := 1; ; i++ {
+		h := sha256.Sum256([]byte(content))
+		id := hex.EncodeToString(h[:])[:routeIDLength]
+		if !routeIDs[id] {
+			routeIDs[id] = true
+			return id
+		}
+		content = fmt.Sprintf("%s\x00%d", path, i)
+	}
+}
+
+// defaultUpstreamNamePrefix is the prefix of the names of the upstreams of VirtualServers and VirtualServerRoutes.
+const defaultUpstreamNamePrefix = "vs"
+
+// UpstreamNamingScheme configures the names of the upstreams of VirtualServers and VirtualServerRoutes,
+// which appear in the logs and the metrics of NGINX. The zero value is the default scheme:
+// "vs_<namespace>_<name>_<upstream>" and "vs_<namespace>_<name>_vsr_<namespace>_<name>_<upstream>".
+type UpstreamNamingScheme struct {
+	// Prefix replaces vs at the beginning of the names.
+	Prefix string
+	// ExcludeNamespace removes the namespaces of the resources from the names.
+	// VirtualServers with the same name in different namespaces then get the same names of upstreams.
+	ExcludeNamespace bool
+}
+
+func (s UpstreamNamingScheme) getPrefix() string {
+	if s.Prefix == "" {
+		return defaultUpstreamNamePrefix
 	}
-	return fmt.Sprintf("%s/%s:%d", serviceNamespace, serviceName, port)
+	return s.Prefix
+}
+
+func (s UpstreamNamingScheme) getNsName(namespace string, name string) string {
+	if s.ExcludeNamespace {
+		return shortenNameComponent(name)
+	}
+	return fmt.Sprintf("%s_%s", shortenNameComponent(namespace), shortenNameComponent(name))
+}
+
+// GetUpstreamNamePrefixForVirtualServer returns the beginning of the names of the upstreams of the VirtualServer
+// and its VirtualServerRoutes. The controller uses it to detect VirtualServers whose upstreams collide.
+func (s UpstreamNamingScheme) GetUpstreamNamePrefixForVirtualServer(virtualServer *conf_v1.VirtualServer) string {
+	return fmt.Sprintf("%s_%s", s.getPrefix(), s.getNsName(virtualServer.Namespace, virtualServer.Name))
 }
 
 type upstreamNamer struct {
 	prefix string
 }
 
-func newUpstreamNamerForVirtualServer(virtualServer *conf_v1.VirtualServer) *upstreamNamer {
+func newUpstreamNamerForVirtualServer(virtualServer *conf_v1.VirtualServer, scheme UpstreamNamingScheme) *upstreamNamer {
 	return &upstreamNamer{
-		prefix: fmt.Sprintf("vs_%s_%s", virtualServer.Namespace, virtualServer.Name),
+		prefix: scheme.GetUpstreamNamePrefixForVirtualServer(virtualServer),
 	}
 }
 
-func newUpstreamNamerForVirtualServerRoute(virtualServer *conf_v1.VirtualServer, virtualServerRoute *conf_v1.VirtualServerRoute) *upstreamNamer {
+func newUpstreamNamerForVirtualServerRoute(virtualServer *conf_v1.VirtualServer, virtualServerRoute *conf_v1.VirtualServerRoute,
+	scheme UpstreamNamingScheme) *upstreamNamer {
 	return &upstreamNamer{
-		prefix: fmt.Sprintf("vs_%s_%s_vsr_%s_%s", virtualServer.Namespace, virtualServer.Name, virtualServerRoute.Namespace, virtualServerRoute.Name),
+		prefix: fmt.Sprintf("%s_vsr_%s", scheme.GetUpstreamNamePrefixForVirtualServer(virtualServer),
+			scheme.getNsName(virtualServerRoute.Namespace, virtualServerRoute.Name)),
 	}
 }
 
@@ -79,32 +194,132 @@ type variableNamer struct {
 }
 
 func newVariableNamer(virtualServer *conf_v1.VirtualServer) *variableNamer {
-	safeNsName := strings.ReplaceAll(fmt.Sprintf("%s_%s", virtualServer.Namespace, virtualServer.Name), "-", "_")
 	return &variableNamer{
-		safeNsName: safeNsName,
+		safeNsName: GetSafeNsNameForVirtualServer(virtualServer),
 	}
 }
 
-func (namer *variableNamer) GetNameForSplitClientVariable(index int) string {
-	return fmt.Sprintf("$vs_%s_splits_%d", namer.safeNsName, index)
+func (namer *variableNamer) GetNameForSplitClientVariable(id string) string {
+	return fmt.Sprintf("$vs_%s_splits_%s", namer.safeNsName, id)
+}
+
+func (namer *variableNamer) GetNameForSplitsPersistenceVariable(id string) string {
+	return fmt.Sprintf("$vs_%s_splits_%s_persistence", namer.safeNsName, id)
+}
+
+func (namer *variableNamer) GetNameForSplitsWeightVariable(id string) string {
+	return fmt.Sprintf("$vs_%s_splits_%s_weight", namer.safeNsName, id)
+}
+
+func (namer *variableNamer) GetNameForSplitClientVariableWithWeight(id string, weight int) string {
+	return fmt.Sprintf("$vs_%s_splits_%s_weight_%d", namer.safeNsName, id, weight)
+}
+
+func (namer *variableNamer) GetNameForDynamicSplitsVariable(id string) string {
+	return fmt.Sprintf("$vs_%s_splits_%s_dynamic", namer.safeNsName, id)
+}
+
+func (namer *variableNamer) GetKeyForSplitsWeight(id string) string {
+	return fmt.Sprintf("vs_%s_splits_%s", namer.safeNsName, id)
+}
+
+func (namer *variableNamer) GetNameForSplitWeightsZone() string {
+	return fmt.Sprintf("vs_%s_split_weights", namer.safeNsName)
+}
+
+func (namer *variableNamer) GetNameForVariableForMatchesRouteMap(matchesID string, matchIndex int, conditionIndex int) string {
+	return fmt.Sprintf("$vs_%s_matches_%s_match_%d_cond_%d", namer.safeNsName, matchesID, matchIndex, conditionIndex)
+}
+
+func (namer *variableNamer) GetNameForVariableForMatchesRouteGeo(matchesID string, matchIndex int, conditionIndex int) string {
+	return fmt.Sprintf("$vs_%s_matches_%s_match_%d_cond_%d_geo", namer.safeNsName, matchesID, matchIndex, conditionIndex)
+}
+
+func (namer *variableNamer) GetNameForVariableForMatchesRouteMainMap(matchesID string) string {
+	return fmt.Sprintf("$vs_%s_matches_%s", namer.safeNsName, matchesID)
+}
+
+func (namer *variableNamer) GetNameForVariableForMatchesRouteChunkMap(matchesID string, chunkIndex int) string {
+	return fmt.Sprintf("$vs_%s_matches_%s_chunk_%d", namer.safeNsName, matchesID, chunkIndex)
+}
+
+func (namer *variableNamer) GetNameForVariableForMatchesRouteChunkResultMap(matchesID string, chunkIndex int) string {
+	return fmt.Sprintf("$vs_%s_matches_%s_chunk_%d_result", namer.safeNsName, matchesID, chunkIndex)
+}
+
+func (namer *variableNamer) GetNameForTracingSamplingVariable() string {
+	return fmt.Sprintf("$vs_%s_tracing_sampled", namer.safeNsName)
+}
+
+func (namer *variableNamer) GetNameForLogFormat() string {
+	return fmt.Sprintf("vs_%s_log_format", namer.safeNsName)
+}
+
+func (namer *variableNamer) GetNameForRequestIDVariable() string {
+	return fmt.Sprintf("$vs_%s_request_id", namer.safeNsName)
+}
+
+func (namer *variableNamer) GetNameForRouteLimitReqZone(routeID string) string {
+	return fmt.Sprintf("vs_%s_route_rl_%s", namer.safeNsName, routeID)
+}
+
+func (namer *variableNamer) GetNameForServerLimitConnZone() string {
+	return fmt.Sprintf("vs_%s_server_lc", namer.safeNsName)
+}
+
+func (namer *variableNamer) GetNameForRouteLimitConnZone(routeID string) string {
+	return fmt.Sprintf("vs_%s_route_lc_%s", namer.safeNsName, routeID)
+}
+
+func (namer *variableNamer) GetNameForCacheZone(name string) string {
+	return fmt.Sprintf("vs_%s_cache_%s", namer.safeNsName, strings.ReplaceAll(name, "-", "_"))
+}
+
+func (namer *variableNamer) GetNameForDollarVariable() string {
+	return fmt.Sprintf("$vs_%s_dollar", namer.safeNsName)
+}
+
+func (namer *variableNamer) GetNameForCachePurgeVariable(index int) string {
+	return fmt.Sprintf("$vs_%s_cache_purge_%d", namer.safeNsName, index)
+}
+
+func (namer *variableNamer) GetNameForCachePurgeAllowedVariable(index int) string {
+	return fmt.Sprintf("$vs_%s_cache_purge_%d_allowed", namer.safeNsName, index)
+}
+
+func (namer *variableNamer) GetNameForRateLimitZone(policyNamespace string, policyName string) string {
+	safePolicyNsName := getSafeNsName(policyNamespace, policyName)
+	return fmt.Sprintf("pol_rl_%s_%s", safePolicyNsName, namer.safeNsName)
 }
 
-func (namer *variableNamer) GetNameForVariableForMatchesRouteMap(matchesIndex int, matchIndex int, conditionIndex int) string {
-	return fmt.Sprintf("$vs_%s_matches_%d_match_%d_cond_%d", namer.safeNsName, matchesIndex, matchIndex, conditionIndex)
+func (namer *variableNamer) GetNameForCORSOriginVariable(policyNamespace string, policyName string) string {
+	safePolicyNsName := getSafeNsName(policyNamespace, policyName)
+	return fmt.Sprintf("$pol_cors_%s_%s_origin", safePolicyNsName, namer.safeNsName)
 }
 
-func (namer *variableNamer) GetNameForVariableForMatchesRouteMainMap(matchesIndex int) string {
-	return fmt.Sprintf("$vs_%s_matches_%d", namer.safeNsName, matchesIndex)
+func (namer *variableNamer) GetNameForCORSPreflightVariable() string {
+	return fmt.Sprintf("$vs_%s_cors_preflight", namer.safeNsName)
 }
 
+// newHealthCheckWithDefaults creates a health check with the defaults of the ConfigMap, which fall back to the defaults of NGINX Plus.
 func newHealthCheckWithDefaults(upstream conf_v1.Upstream, upstreamName string, cfgParams *ConfigParams) *version2.HealthCheck {
+	fails := 1
+	if cfgParams.HealthCheckFails > 0 {
+		fails = cfgParams.HealthCheckFails
+	}
+
+	passes := 1
+	if cfgParams.HealthCheckPasses > 0 {
+		passes = cfgParams.HealthCheckPasses
+	}
+
 	return &version2.HealthCheck{
 		Name:                upstreamName,
 		URI:                 "/",
-		Interval:            "5s",
-		Jitter:              "0s",
-		Fails:               1,
-		Passes:              1,
+		Interval:            generateString(cfgParams.HealthCheckInterval, "5s"),
+		Jitter:              generateString(cfgParams.HealthCheckJitter, "0s"),
+		Fails:               fails,
+		Passes:              passes,
 		Port:                int(upstream.Port),
 		ProxyPass:           fmt.Sprintf("%v://%v", generateProxyPassProtocol(upstream.TLS.Enable), upstreamName),
 		ProxyConnectTimeout: generateString(upstream.ProxyConnectTimeout, cfgParams.ProxyConnectTimeout),
@@ -119,15 +334,18 @@ type virtualServerConfigurator struct {
 	cfgParams            *ConfigParams
 	isPlus               bool
 	isResolverConfigured bool
+	// emulateQueue makes the configurator approximate the queue of the upstreams with limit_conn and limit_req for NGINX
+	emulateQueue         bool
+	upstreamNamingScheme UpstreamNamingScheme
 	warnings             Warnings
 }
 
-func (vsc *virtualServerConfigurator) addWarningf(obj runtime.Object, msgFmt string, args ...interface{}) {
-	vsc.warnings[obj] = append(vsc.warnings[obj], fmt.Sprintf(msgFmt, args...))
+func (vsc *virtualServerConfigurator) addWarningf(obj runtime.Object, code string, severity WarningSeverity, msgFmt string, args ...interface{}) {
+	vsc.warnings.AddWarning(obj, NewWarning(code, severity, msgFmt, args...))
 }
 
 func (vsc *virtualServerConfigurator) clearWarnings() {
-	vsc.warnings = make(map[runtime.Object][]string)
+	vsc.warnings = newWarnings()
 }
 
 // newVirtualServerConfigurator creates a new VirtualServerConfigurator
@@ -136,30 +354,193 @@ func newVirtualServerConfigurator(cfgParams *ConfigParams, isPlus bool, isResolv
 		cfgParams:            cfgParams,
 		isPlus:               isPlus,
 		isResolverConfigured: isResolverConfigured,
-		warnings:             make(map[runtime.Object][]string),
+		warnings:             newWarnings(),
+	}
+}
+
+// GenerateVirtualServerWarnings generates the config for the VirtualServer with the default config parameters
+// and returns the warnings. The Secrets referenced by the policies are considered valid.
+func GenerateVirtualServerWarnings(virtualServerEx *VirtualServerEx, isPlus bool) Warnings {
+	_, warnings := GenerateVirtualServerConfigForValidSecrets(virtualServerEx, NewDefaultConfigParams(), isPlus, "")
+	return warnings
+}
+
+// GenerateVirtualServerConfigForValidSecrets generates the config for the VirtualServer outside of a Configurator,
+// for example, to preview the config. The Secrets referenced by the policies and the health checks are considered valid,
+// and the TLS Secret of the VirtualServer is the file tlsPemFileName.
+func GenerateVirtualServerConfigForValidSecrets(virtualServerEx *VirtualServerEx, cfgParams *ConfigParams, isPlus bool,
+	tlsPemFileName string) (version2.VirtualServerConfig, Warnings) {
+	vsc := newVirtualServerConfigurator(cfgParams, isPlus, true)
+
+	policyOpts := generatePolicyOptionsForValidSecrets(virtualServerEx.Policies)
+	addCAFileNames := func(namespace string, upstreams []conf_v1.Upstream) {
+		for _, key := range GetHealthCheckCASecretKeys(namespace, upstreams) {
+			policyOpts.trustedCAFileNames[key] = "/etc/nginx/secrets/" + strings.Replace(key, "/", "-", 1)
+		}
+	}
+
+	addCAFileNames(virtualServerEx.VirtualServer.Namespace, virtualServerEx.VirtualServer.Spec.Upstreams)
+	for _, vsr := range virtualServerEx.VirtualServerRoutes {
+		addCAFileNames(vsr.Namespace, vsr.Spec.Upstreams)
+	}
+
+	return vsc.GenerateVirtualServerConfig(virtualServerEx, tlsPemFileName, policyOpts)
+}
+
+// GetHealthCheckCASecretKeys returns the keys (namespace/name) of the CA Secrets referenced by the health checks of the upstreams.
+func GetHealthCheckCASecretKeys(namespace string, upstreams []conf_v1.Upstream) []string {
+	var keys []string
+
+	for _, u := range upstreams {
+		if u.HealthCheck != nil && u.HealthCheck.TLS != nil && u.HealthCheck.TLS.TrustedCertSecret != "" {
+			keys = append(keys, namespace+"/"+u.HealthCheck.TLS.TrustedCertSecret)
+		}
+	}
+
+	return keys
+}
+
+// generatePolicyOptionsForValidSecrets generates policy options as if all Secrets referenced by the policies were valid.
+func generatePolicyOptionsForValidSecrets(policies map[string]*conf_v1.Policy) policyOptions {
+	opts := policyOptions{
+		jwtKeyFileNames:          make(map[string]string),
+		oidcClientSecrets:        make(map[string]string),
+		egressTLSSecretFileNames: make(map[string]string),
+		trustedCAFileNames:       make(map[string]string),
+		htpasswdFileNames:        make(map[string]string),
 	}
+
+	addFileName := func(fileNames map[string]string, namespace string, name string) {
+		if name != "" {
+			fileNames[namespace+"/"+name] = fmt.Sprintf("/etc/nginx/secrets/%s-%s", namespace, name)
+		}
+	}
+
+	for _, pol := range policies {
+		if pol.Spec.JWTAuth != nil {
+			addFileName(opts.jwtKeyFileNames, pol.Namespace, pol.Spec.JWTAuth.Secret)
+		}
+		if pol.Spec.OIDC != nil {
+			opts.oidcClientSecrets[pol.Namespace+"/"+pol.Spec.OIDC.ClientSecret] = "client-secret"
+		}
+		if pol.Spec.EgressMTLS != nil {
+			addFileName(opts.egressTLSSecretFileNames, pol.Namespace, pol.Spec.EgressMTLS.TLSSecret)
+			addFileName(opts.trustedCAFileNames, pol.Namespace, pol.Spec.EgressMTLS.TrustedCertSecret)
+		}
+		if pol.Spec.BasicAuth != nil {
+			addFileName(opts.htpasswdFileNames, pol.Namespace, pol.Spec.BasicAuth.Secret)
+		}
+	}
+
+	return opts
 }
 
 func (vsc *virtualServerConfigurator) generateEndpointsForUpstream(owner runtime.Object, namespace string, upstream conf_v1.Upstream, virtualServerEx *VirtualServerEx) []string {
-	endpointsKey := GenerateEndpointsKey(namespace, upstream.Service, upstream.Subselector, upstream.Port)
+	if vsc.isPlus && upstream.Resolve {
+		if vsc.isResolverConfigured {
+			return []string{generateServiceAddress(namespace, upstream.Service, upstream.Port, vsc.cfgParams.ResolverClusterDomain)}
+		}
+
+		msgFmt := "Resolve in upstream %v will be ignored. To resolve the name of service %v, a resolver must be configured in the ConfigMap"
+		vsc.addWarningf(owner, WarningCodeResolverRequired, WarningSeverityMedium, msgFmt, upstream.Name, upstream.Service)
+	}
+
+	endpointsKey := GenerateEndpointsKey(namespace, upstream.Service, upstream.Subselector, upstream.Port, upstream.PortName)
 	externalNameSvcKey := GenerateExternalNameSvcKey(namespace, upstream.Service)
 	endpoints := virtualServerEx.Endpoints[endpointsKey]
-	if !vsc.isPlus && len(endpoints) == 0 {
-		return []string{nginx502Server}
+	if !vsc.isPlus {
+		// NGINX doesn't support draining servers, so the drained endpoints are removed from the upstream.
+		endpoints = removeDrainedEndpoints(endpoints, virtualServerEx.DrainedEndpoints)
 	}
 
 	_, isExternalNameSvc := virtualServerEx.ExternalNameSvcs[externalNameSvcKey]
 	if isExternalNameSvc && !vsc.isResolverConfigured {
 		msgFmt := "Type ExternalName service %v in upstream %v will be ignored. To use ExternaName services, a resolver must be configured in the ConfigMap"
-		vsc.addWarningf(owner, msgFmt, upstream.Service, upstream.Name)
+		vsc.addWarningf(owner, WarningCodeResolverRequired, WarningSeverityMedium, msgFmt, upstream.Service, upstream.Name)
 		endpoints = []string{}
 	}
 
+	// NGINX resolves the address of an ExternalName service only in the proxy_pass of the locations,
+	// so the upstream of the service is not used.
+	if !vsc.isPlus && (isExternalNameSvc || len(endpoints) == 0) {
+		return []string{nginx502Server}
+	}
+
 	return endpoints
 }
 
+// getExternalNameAddressForNGINX returns the address of the ExternalName service of the upstream for NGINX,
+// which resolves the name of the service at runtime only when the address is in a variable of the proxy_pass.
+func (vsc *virtualServerConfigurator) getExternalNameAddressForNGINX(owner runtime.Object, namespace string, upstream conf_v1.Upstream,
+	virtualServerEx *VirtualServerEx) (string, bool) {
+	if vsc.isPlus || !vsc.isResolverConfigured || !virtualServerEx.ExternalNameSvcs[GenerateExternalNameSvcKey(namespace, upstream.Service)] {
+		return "", false
+	}
+
+	endpoints := virtualServerEx.Endpoints[GenerateEndpointsKey(namespace, upstream.Service, upstream.Subselector, upstream.Port, upstream.PortName)]
+	if len(endpoints) == 0 {
+		return "", false
+	}
+
+	msgFmt := "Type ExternalName service %v in upstream %v is resolved by NGINX for every request with the TTL of the DNS records. " +
+		"The upstream settings, such as lb-method, keepalive, max-fails, fail-timeout and max-conns, don't apply"
+	vsc.addWarningf(owner, WarningCodeApproximatedSetting, WarningSeverityLow, msgFmt, upstream.Service, upstream.Name)
+
+	return endpoints[0], true
+}
+
+func removeDrainedEndpoints(endpoints []string, drainedEndpoints map[string]bool) []string {
+	if len(drainedEndpoints) == 0 {
+		return endpoints
+	}
+
+	var result []string
+	for _, e := range endpoints {
+		if !drainedEndpoints[e] {
+			result = append(result, e)
+		}
+	}
+
+	return result
+}
+
+// generateUpstreams generates the upstreams of a VirtualServer and its VirtualServerRoutes in the same order as
+// GenerateVirtualServerConfig, so that the upstreams of a generated config can be updated when only the endpoints change.
+func (vsc *virtualServerConfigurator) generateUpstreams(virtualServerEx *VirtualServerEx) []version2.Upstream {
+	var upstreams []version2.Upstream
+
+	upstreamNamer := newUpstreamNamerForVirtualServer(virtualServerEx.VirtualServer, vsc.upstreamNamingScheme)
+	for _, u := range virtualServerEx.VirtualServer.Spec.Upstreams {
+		upstreamName := upstreamNamer.GetNameForUpstream(u.Name)
+		upstreamNamespace := virtualServerEx.VirtualServer.Namespace
+		endpoints := vsc.generateEndpointsForUpstream(virtualServerEx.VirtualServer, upstreamNamespace, u, virtualServerEx)
+
+		_, isExternalNameSvc := virtualServerEx.ExternalNameSvcs[GenerateExternalNameSvcKey(upstreamNamespace, u.Service)]
+		resolve := isExternalNameSvc || vsc.isUpstreamResolved(u)
+		upstreams = append(upstreams, vsc.generateUpstream(virtualServerEx.VirtualServer, upstreamName, u, resolve, endpoints, virtualServerEx.DrainedEndpoints, virtualServerEx.TerminatingEndpoints,
+			virtualServerEx.RemoteZoneEndpoints))
+	}
+
+	for _, vsr := range virtualServerEx.VirtualServerRoutes {
+		upstreamNamer = newUpstreamNamerForVirtualServerRoute(virtualServerEx.VirtualServer, vsr, vsc.upstreamNamingScheme)
+		for _, u := range vsr.Spec.Upstreams {
+			upstreamName := upstreamNamer.GetNameForUpstream(u.Name)
+			upstreamNamespace := vsr.Namespace
+			endpoints := vsc.generateEndpointsForUpstream(vsr, upstreamNamespace, u, virtualServerEx)
+
+			_, isExternalNameSvc := virtualServerEx.ExternalNameSvcs[GenerateExternalNameSvcKey(upstreamNamespace, u.Service)]
+			resolve := isExternalNameSvc || vsc.isUpstreamResolved(u)
+			upstreams = append(upstreams, vsc.generateUpstream(vsr, upstreamName, u, resolve, endpoints, virtualServerEx.DrainedEndpoints, virtualServerEx.TerminatingEndpoints,
+				virtualServerEx.RemoteZoneEndpoints))
+		}
+	}
+
+	return upstreams
+}
+
 // GenerateVirtualServerConfig generates a full configuration for a VirtualServer
-func (vsc *virtualServerConfigurator) GenerateVirtualServerConfig(virtualServerEx *VirtualServerEx, tlsPemFileName string) (version2.VirtualServerConfig, Warnings) {
+func (vsc *virtualServerConfigurator) GenerateVirtualServerConfig(virtualServerEx *VirtualServerEx, tlsPemFileName string,
+	policyOpts policyOptions) (version2.VirtualServerConfig, Warnings) {
 	vsc.clearWarnings()
 	ssl := generateSSLConfig(virtualServerEx.VirtualServer.Spec.TLS, tlsPemFileName, vsc.cfgParams)
 	tlsRedirectConfig := generateTLSRedirectConfig(virtualServerEx.VirtualServer.Spec.TLS)
@@ -168,7 +549,10 @@ func (vsc *virtualServerConfigurator) GenerateVirtualServerConfig(virtualServerE
 	// necessary for generateLocation to know what Upstream each Location references
 	crUpstreams := make(map[string]conf_v1.Upstream)
 
-	virtualServerUpstreamNamer := newUpstreamNamerForVirtualServer(virtualServerEx.VirtualServer)
+	// externalNameAddresses maps the names of the upstreams of ExternalName services to the addresses of the services for NGINX
+	externalNameAddresses := make(map[string]string)
+
+	virtualServerUpstreamNamer := newUpstreamNamerForVirtualServer(virtualServerEx.VirtualServer, vsc.upstreamNamingScheme)
 
 	var upstreams []version2.Upstream
 	var statusMatches []version2.StatusMatch
@@ -180,37 +564,51 @@ func (vsc *virtualServerConfigurator) GenerateVirtualServerConfig(virtualServerE
 		upstreamNamespace := virtualServerEx.VirtualServer.Namespace
 		endpoints := vsc.generateEndpointsForUpstream(virtualServerEx.VirtualServer, upstreamNamespace, u, virtualServerEx)
 
-		// isExternalNameSvc is always false for OSS
 		_, isExternalNameSvc := virtualServerEx.ExternalNameSvcs[GenerateExternalNameSvcKey(upstreamNamespace, u.Service)]
-		ups := vsc.generateUpstream(virtualServerEx.VirtualServer, upstreamName, u, isExternalNameSvc, endpoints)
+		resolve := (vsc.isPlus && isExternalNameSvc) || vsc.isUpstreamResolved(u)
+		ups := vsc.generateUpstream(virtualServerEx.VirtualServer, upstreamName, u, resolve, endpoints, virtualServerEx.DrainedEndpoints, virtualServerEx.TerminatingEndpoints,
+			virtualServerEx.RemoteZoneEndpoints)
 		upstreams = append(upstreams, ups)
 		crUpstreams[upstreamName] = u
 
+		if address, ok := vsc.getExternalNameAddressForNGINX(virtualServerEx.VirtualServer, upstreamNamespace, u, virtualServerEx); ok {
+			externalNameAddresses[upstreamName] = address
+		}
+
 		if hc := generateHealthCheck(u, upstreamName, vsc.cfgParams); hc != nil {
+			vsc.addHealthCheckTrustedCert(virtualServerEx.VirtualServer, upstreamNamespace, u, hc, policyOpts.trustedCAFileNames)
+			vsc.addHealthCheckServicePort(virtualServerEx.VirtualServer, upstreamNamespace, u, hc, virtualServerEx.HealthCheckPorts)
 			healthChecks = append(healthChecks, *hc)
-			if u.HealthCheck.StatusMatch != "" {
-				statusMatches = append(statusMatches, generateUpstreamStatusMatch(upstreamName, u.HealthCheck.StatusMatch))
+			if hasHealthCheckMatch(u.HealthCheck) {
+				statusMatches = append(statusMatches, generateUpstreamStatusMatch(upstreamName, u.HealthCheck))
 			}
 		}
 	}
 	// generate upstreams for each VirtualServerRoute
 	for _, vsr := range virtualServerEx.VirtualServerRoutes {
-		upstreamNamer := newUpstreamNamerForVirtualServerRoute(virtualServerEx.VirtualServer, vsr)
+		upstreamNamer := newUpstreamNamerForVirtualServerRoute(virtualServerEx.VirtualServer, vsr, vsc.upstreamNamingScheme)
 		for _, u := range vsr.Spec.Upstreams {
 			upstreamName := upstreamNamer.GetNameForUpstream(u.Name)
 			upstreamNamespace := vsr.Namespace
 			endpoints := vsc.generateEndpointsForUpstream(vsr, upstreamNamespace, u, virtualServerEx)
 
-			// isExternalNameSvc is always false for OSS
 			_, isExternalNameSvc := virtualServerEx.ExternalNameSvcs[GenerateExternalNameSvcKey(upstreamNamespace, u.Service)]
-			ups := vsc.generateUpstream(vsr, upstreamName, u, isExternalNameSvc, endpoints)
+			resolve := (vsc.isPlus && isExternalNameSvc) || vsc.isUpstreamResolved(u)
+			ups := vsc.generateUpstream(vsr, upstreamName, u, resolve, endpoints, virtualServerEx.DrainedEndpoints, virtualServerEx.TerminatingEndpoints,
+				virtualServerEx.RemoteZoneEndpoints)
 			upstreams = append(upstreams, ups)
 			crUpstreams[upstreamName] = u
 
+			if address, ok := vsc.getExternalNameAddressForNGINX(vsr, upstreamNamespace, u, virtualServerEx); ok {
+				externalNameAddresses[upstreamName] = address
+			}
+
 			if hc := generateHealthCheck(u, upstreamName, vsc.cfgParams); hc != nil {
+				vsc.addHealthCheckTrustedCert(vsr, upstreamNamespace, u, hc, policyOpts.trustedCAFileNames)
+				vsc.addHealthCheckServicePort(vsr, upstreamNamespace, u, hc, virtualServerEx.HealthCheckPorts)
 				healthChecks = append(healthChecks, *hc)
-				if u.HealthCheck.StatusMatch != "" {
-					statusMatches = append(statusMatches, generateUpstreamStatusMatch(upstreamName, u.HealthCheck.StatusMatch))
+				if hasHealthCheckMatch(u.HealthCheck) {
+					statusMatches = append(statusMatches, generateUpstreamStatusMatch(upstreamName, u.HealthCheck))
 				}
 			}
 		}
@@ -220,10 +618,26 @@ func (vsc *virtualServerConfigurator) GenerateVirtualServerConfig(virtualServerE
 	var internalRedirectLocations []version2.InternalRedirectLocation
 	var splitClients []version2.SplitClient
 	var maps []version2.Map
+	var geos []version2.Geo
+
+	routeIDs := make(map[string]bool)
+	conditionMaps := make(conditionMapVariables)
 
-	matchesRoutes := 0
+	var limitReqZones []version2.LimitReqZone
+	var limitConnZones []version2.LimitConnZone
+
+	var dynamicSplitClients []version2.SplitClient
+	var keyVals []version2.KeyVal
+	var keyValPairs []version2.KeyValPair
 
 	variableNamer := newVariableNamer(virtualServerEx.VirtualServer)
+	vsSplitWeights := vsc.parseSplitWeights(virtualServerEx.VirtualServer, virtualServerEx.VirtualServer.Annotations)
+
+	// generates config for VirtualServer policies
+	policiesCfg := vsc.generatePolicies(virtualServerEx.VirtualServer, virtualServerEx.VirtualServer.Namespace,
+		virtualServerEx.VirtualServer.Spec.Policies, virtualServerEx.Policies, specContext, variableNamer, policyOpts)
+	limitReqZones = append(limitReqZones, policiesCfg.LimitReqZones...)
+	maps = append(maps, policiesCfg.Maps...)
 
 	// generates config for VirtualServer routes
 	for _, r := range virtualServerEx.VirtualServer.Spec.Routes {
@@ -232,25 +646,66 @@ func (vsc *virtualServerConfigurator) GenerateVirtualServerConfig(virtualServerE
 			continue
 		}
 
+		routePoliciesCfg := vsc.generatePolicies(virtualServerEx.VirtualServer, virtualServerEx.VirtualServer.Namespace,
+			r.Policies, virtualServerEx.Policies, routeContext, variableNamer, policyOpts)
+		limitReqZones = append(limitReqZones, routePoliciesCfg.LimitReqZones...)
+		maps = append(maps, routePoliciesCfg.Maps...)
+
+		// the ID names the zones and the variables of the route, so that they are kept when other routes are added or reordered
+		routeID := generateRouteID(r.Path, routeIDs)
+
+		if r.LimitReq != nil {
+			zoneName := variableNamer.GetNameForRouteLimitReqZone(routeID)
+			limitReqZones = append(limitReqZones, generateRouteLimitReqZone(zoneName, r.LimitReq))
+			addRouteLimitReqToPoliciesCfg(zoneName, r.LimitReq, &routePoliciesCfg)
+		}
+
+		if r.LimitConn != nil {
+			zoneName := variableNamer.GetNameForRouteLimitConnZone(routeID)
+			limitConnZones = append(limitConnZones, generateLimitConnZone(zoneName, r.LimitConn))
+			routePoliciesCfg.LimitConns = append(routePoliciesCfg.LimitConns, generateLimitConn(zoneName, r.LimitConn))
+		}
+
 		if len(r.Matches) > 0 {
-			cfg := generateMatchesConfig(r, virtualServerUpstreamNamer, crUpstreams, variableNamer, matchesRoutes, len(splitClients), vsc.cfgParams)
+			vsc.checkJWTClaimConditions(virtualServerEx.VirtualServer, r, policiesCfg.JWTAuth != nil || routePoliciesCfg.JWTAuth != nil)
+			vsc.checkOverlappingMatches(virtualServerEx.VirtualServer, r)
+			cfg := generateMatchesConfig(r, virtualServerUpstreamNamer, crUpstreams, variableNamer, conditionMaps, routeID, vsc.cfgParams)
+			addPoliciesCfgToLocations(routePoliciesCfg, cfg.Locations)
+			addIgnoreHeadersToLocations(r.IgnoreHeaders, cfg.Locations)
+			addClientBodyToLocations(r, cfg.Locations)
+			vsc.addServeContentToLocations(virtualServerEx.VirtualServer, virtualServerEx.VirtualServer.Namespace, virtualServerEx.ConfigMaps,
+				variableNamer, cfg.Locations)
 
 			maps = append(maps, cfg.Maps...)
+			geos = append(geos, cfg.Geos...)
 			locations = append(locations, cfg.Locations...)
 			internalRedirectLocations = append(internalRedirectLocations, cfg.InternalRedirectLocation)
 			splitClients = append(splitClients, cfg.SplitClients...)
-
-			matchesRoutes++
 		} else if len(r.Splits) > 0 {
-			cfg := generateDefaultSplitsConfig(r, virtualServerUpstreamNamer, crUpstreams, variableNamer, len(splitClients), vsc.cfgParams)
+			dynamicWeights := vsc.isPlus && r.SplitsDynamicWeights && len(r.Splits) == 2
+			cfg := generateDefaultSplitsConfig(r, virtualServerUpstreamNamer, crUpstreams, variableNamer, routeID, vsc.cfgParams, dynamicWeights)
+			addPoliciesCfgToLocations(routePoliciesCfg, cfg.Locations)
+			addIgnoreHeadersToLocations(r.IgnoreHeaders, cfg.Locations)
+			addClientBodyToLocations(r, cfg.Locations)
+			vsc.addServeContentToLocations(virtualServerEx.VirtualServer, virtualServerEx.VirtualServer.Namespace, virtualServerEx.ConfigMaps,
+				variableNamer, cfg.Locations)
 
+			maps = append(maps, cfg.Maps...)
 			splitClients = append(splitClients, cfg.SplitClients...)
+			dynamicSplitClients = append(dynamicSplitClients, cfg.DynamicSplitClients...)
+			keyVals = append(keyVals, cfg.KeyVals...)
+			keyValPairs = append(keyValPairs, generateSplitWeightsKeyValPairs(r, cfg.KeyVals, vsSplitWeights)...)
 			locations = append(locations, cfg.Locations...)
 			internalRedirectLocations = append(internalRedirectLocations, cfg.InternalRedirectLocation)
 		} else {
 			upstreamName := virtualServerUpstreamNamer.GetNameForUpstream(r.Action.Pass)
 			upstream := crUpstreams[upstreamName]
 			loc := generateLocation(r.Path, upstreamName, upstream, r.Action, vsc.cfgParams)
+			addPoliciesCfgToLocation(routePoliciesCfg, &loc)
+			loc.ProxyIgnoreHeaders = strings.Join(r.IgnoreHeaders, " ")
+			addClientBodyToLocation(r, &loc)
+			vsc.addServeContentToLocation(virtualServerEx.VirtualServer, virtualServerEx.VirtualServer.Namespace, virtualServerEx.ConfigMaps,
+				variableNamer, &loc)
 			locations = append(locations, loc)
 		}
 
@@ -258,115 +713,974 @@ func (vsc *virtualServerConfigurator) GenerateVirtualServerConfig(virtualServerE
 
 	// generate config for subroutes of each VirtualServerRoute
 	for _, vsr := range virtualServerEx.VirtualServerRoutes {
-		upstreamNamer := newUpstreamNamerForVirtualServerRoute(virtualServerEx.VirtualServer, vsr)
+		upstreamNamer := newUpstreamNamerForVirtualServerRoute(virtualServerEx.VirtualServer, vsr, vsc.upstreamNamingScheme)
+		vsrSplitWeights := vsc.parseSplitWeights(vsr, vsr.Annotations)
 		for _, r := range vsr.Spec.Subroutes {
+			routePoliciesCfg := vsc.generatePolicies(vsr, vsr.Namespace, r.Policies, virtualServerEx.Policies, subRouteContext, variableNamer, policyOpts)
+			limitReqZones = append(limitReqZones, routePoliciesCfg.LimitReqZones...)
+			maps = append(maps, routePoliciesCfg.Maps...)
+
+			// the ID names the zones and the variables of the route, so that they are kept when other routes are added or reordered
+			routeID := generateRouteID(r.Path, routeIDs)
+
+			if r.LimitReq != nil {
+				zoneName := variableNamer.GetNameForRouteLimitReqZone(routeID)
+				limitReqZones = append(limitReqZones, generateRouteLimitReqZone(zoneName, r.LimitReq))
+				addRouteLimitReqToPoliciesCfg(zoneName, r.LimitReq, &routePoliciesCfg)
+			}
+
+			if r.LimitConn != nil {
+				zoneName := variableNamer.GetNameForRouteLimitConnZone(routeID)
+				limitConnZones = append(limitConnZones, generateLimitConnZone(zoneName, r.LimitConn))
+				routePoliciesCfg.LimitConns = append(routePoliciesCfg.LimitConns, generateLimitConn(zoneName, r.LimitConn))
+			}
+
 			if len(r.Matches) > 0 {
-				cfg := generateMatchesConfig(r, upstreamNamer, crUpstreams, variableNamer, matchesRoutes, len(splitClients), vsc.cfgParams)
+				vsc.checkJWTClaimConditions(vsr, r, policiesCfg.JWTAuth != nil || routePoliciesCfg.JWTAuth != nil)
+				vsc.checkOverlappingMatches(vsr, r)
+				cfg := generateMatchesConfig(r, upstreamNamer, crUpstreams, variableNamer, conditionMaps, routeID, vsc.cfgParams)
+				addPoliciesCfgToLocations(routePoliciesCfg, cfg.Locations)
+				addIgnoreHeadersToLocations(r.IgnoreHeaders, cfg.Locations)
+				addClientBodyToLocations(r, cfg.Locations)
+				vsc.addServeContentToLocations(vsr, vsr.Namespace, virtualServerEx.ConfigMaps, variableNamer, cfg.Locations)
 
 				maps = append(maps, cfg.Maps...)
+				geos = append(geos, cfg.Geos...)
 				locations = append(locations, cfg.Locations...)
 				internalRedirectLocations = append(internalRedirectLocations, cfg.InternalRedirectLocation)
 				splitClients = append(splitClients, cfg.SplitClients...)
-
-				matchesRoutes++
 			} else if len(r.Splits) > 0 {
-				cfg := generateDefaultSplitsConfig(r, upstreamNamer, crUpstreams, variableNamer, len(splitClients), vsc.cfgParams)
+				dynamicWeights := vsc.isPlus && r.SplitsDynamicWeights && len(r.Splits) == 2
+				cfg := generateDefaultSplitsConfig(r, upstreamNamer, crUpstreams, variableNamer, routeID, vsc.cfgParams, dynamicWeights)
+				addPoliciesCfgToLocations(routePoliciesCfg, cfg.Locations)
+				addIgnoreHeadersToLocations(r.IgnoreHeaders, cfg.Locations)
+				addClientBodyToLocations(r, cfg.Locations)
+				vsc.addServeContentToLocations(vsr, vsr.Namespace, virtualServerEx.ConfigMaps, variableNamer, cfg.Locations)
 
+				maps = append(maps, cfg.Maps...)
 				splitClients = append(splitClients, cfg.SplitClients...)
+				dynamicSplitClients = append(dynamicSplitClients, cfg.DynamicSplitClients...)
+				keyVals = append(keyVals, cfg.KeyVals...)
+				keyValPairs = append(keyValPairs, generateSplitWeightsKeyValPairs(r, cfg.KeyVals, vsrSplitWeights)...)
 				locations = append(locations, cfg.Locations...)
 				internalRedirectLocations = append(internalRedirectLocations, cfg.InternalRedirectLocation)
 			} else {
 				upstreamName := upstreamNamer.GetNameForUpstream(r.Action.Pass)
 				upstream := crUpstreams[upstreamName]
 				loc := generateLocation(r.Path, upstreamName, upstream, r.Action, vsc.cfgParams)
+				addPoliciesCfgToLocation(routePoliciesCfg, &loc)
+				loc.ProxyIgnoreHeaders = strings.Join(r.IgnoreHeaders, " ")
+				addClientBodyToLocation(r, &loc)
+				vsc.addServeContentToLocation(vsr, vsr.Namespace, virtualServerEx.ConfigMaps, variableNamer, &loc)
 				locations = append(locations, loc)
 			}
 		}
 	}
 
+	var httpPort, httpsPort int
+	if listener := virtualServerEx.VirtualServer.Spec.Listener; listener != nil {
+		httpPort = listener.HTTP
+		httpsPort = listener.HTTPS
+	}
+
+	setRealIPFrom, realIPHeader, realIPRecursive := generateRealIP(virtualServerEx.VirtualServer.Spec.Server, vsc.cfgParams)
+	xForwardedHeaders := generateVirtualServerXForwardedHeaders(virtualServerEx.VirtualServer.Spec.Server, vsc.cfgParams, tlsRedirectConfig)
+
+	accessLog, logFormat := generateAccessLog(virtualServerEx.VirtualServer, vsc.cfgParams, variableNamer)
+	errorLog := generateErrorLog(virtualServerEx.VirtualServer.Spec.Server, vsc.cfgParams)
+
+	var serverLimitConns []version2.LimitConn
+	if server := virtualServerEx.VirtualServer.Spec.Server; server != nil && server.LimitConn != nil {
+		zoneName := variableNamer.GetNameForServerLimitConnZone()
+		limitConnZones = append(limitConnZones, generateLimitConnZone(zoneName, server.LimitConn))
+		serverLimitConns = append(serverLimitConns, generateLimitConn(zoneName, server.LimitConn))
+	}
+
+	tracing, tracingSplitClients := vsc.generateTracing(virtualServerEx.VirtualServer, variableNamer)
+	splitClients = append(splitClients, tracingSplitClients...)
+	splitClients = append(splitClients, dynamicSplitClients...)
+
+	var keyValZones []version2.KeyValZone
+	if len(keyVals) > 0 {
+		keyValZones = append(keyValZones, version2.KeyValZone{
+			Name: variableNamer.GetNameForSplitWeightsZone(),
+			Size: splitWeightsZoneSize,
+		})
+	}
+
+	requestID, requestIDMaps := generateRequestID(virtualServerEx.VirtualServer.Spec.Server, variableNamer)
+	maps = append(maps, requestIDMaps...)
+	if requestID != nil {
+		// the splits must be consistent with the ID passed to the upstreams
+		for i := range splitClients {
+			if splitClients[i].Source == "$request_id" {
+				splitClients[i].Source = requestID.Variable
+			}
+		}
+	}
+
+	if locationsServeContent(locations) {
+		geos = append(geos, generateDollarGeo(variableNamer.GetNameForDollarVariable()))
+	}
+
+	// the CORS policy of the spec applies to the locations without their own CORS policy
+	addCORSToLocations(policiesCfg.CORS, locations)
+	if locationsUseCORS(locations) {
+		maps = append(maps, generateCORSPreflightMap(variableNamer.GetNameForCORSPreflightVariable()))
+	}
+
+	cacheZones := generateCacheZones(locations, variableNamer)
+	cachePurgeGeos, cachePurgeMaps := generateCachePurges(locations, variableNamer, vsc.isPlus)
+	geos = append(geos, cachePurgeGeos...)
+	maps = append(maps, cachePurgeMaps...)
+
+	if vsc.emulateQueue && !vsc.isPlus {
+		queueLimitReqZones, queueLimitConnZones := emulateUpstreamQueues(upstreams, crUpstreams, locations, policiesCfg, serverLimitConns)
+		limitReqZones = append(limitReqZones, queueLimitReqZones...)
+		limitConnZones = append(limitConnZones, queueLimitConnZones...)
+	}
+
+	maps = append(maps, addExternalNameAddressesToLocations(upstreams, crUpstreams, externalNameAddresses, locations)...)
+
 	vscfg := version2.VirtualServerConfig{
-		Upstreams:     upstreams,
-		SplitClients:  splitClients,
-		Maps:          maps,
-		StatusMatches: statusMatches,
+		Upstreams:      upstreams,
+		SplitClients:   splitClients,
+		Maps:           removeDuplicateMaps(maps),
+		Geos:           geos,
+		StatusMatches:  statusMatches,
+		LimitReqZones:  removeDuplicateLimitReqZones(limitReqZones),
+		LimitConnZones: limitConnZones,
+		CacheZones:     cacheZones,
+		KeyValZones:    keyValZones,
+		KeyVals:        keyVals,
+		LogFormat:      logFormat,
+		KeyValPairs:    keyValPairs,
 		Server: version2.Server{
 			ServerName:                virtualServerEx.VirtualServer.Spec.Host,
 			StatusZone:                virtualServerEx.VirtualServer.Spec.Host,
 			ProxyProtocol:             vsc.cfgParams.ProxyProtocol,
+			HTTPPort:                  httpPort,
+			HTTPSPort:                 httpsPort,
 			SSL:                       ssl,
 			ServerTokens:              vsc.cfgParams.ServerTokens,
-			SetRealIPFrom:             vsc.cfgParams.SetRealIPFrom,
-			RealIPHeader:              vsc.cfgParams.RealIPHeader,
-			RealIPRecursive:           vsc.cfgParams.RealIPRecursive,
+			SetRealIPFrom:             setRealIPFrom,
+			RealIPHeader:              realIPHeader,
+			RealIPRecursive:           realIPRecursive,
+			XForwardedHeaders:         xForwardedHeaders,
+			AccessLog:                 accessLog,
+			ErrorLog:                  errorLog,
 			Snippets:                  vsc.cfgParams.ServerSnippets,
 			InternalRedirectLocations: internalRedirectLocations,
 			Locations:                 locations,
 			HealthChecks:              healthChecks,
 			TLSRedirect:               tlsRedirectConfig,
+			LimitReqOptions:           policiesCfg.LimitReqOptions,
+			LimitReqs:                 policiesCfg.LimitReqs,
+			LimitConns:                serverLimitConns,
+			PoliciesErrorReturn:       policiesCfg.ErrorReturn,
+			JWTAuth:                   policiesCfg.JWTAuth,
+			BasicAuth:                 policiesCfg.BasicAuth,
+			OIDC:                      policiesCfg.OIDC,
+			EgressMTLS:                policiesCfg.EgressMTLS,
+			Tracing:                   tracing,
+			RequestID:                 requestID,
 		},
 	}
 
+	if requestID != nil {
+		addServerHeaders(&vscfg, []version2.AddHeader{{Name: requestID.Header, Value: requestID.Variable}})
+	}
+
 	return vscfg, vsc.warnings
 }
 
-func (vsc *virtualServerConfigurator) generateUpstream(owner runtime.Object, upstreamName string, upstream conf_v1.Upstream, isExternalNameSvc bool, endpoints []string) version2.Upstream {
-	var upsServers []version2.UpstreamServer
-	for _, e := range endpoints {
-		s := version2.UpstreamServer{
-			Address: e,
-		}
+type policiesCfg struct {
+	LimitReqOptions version2.LimitReqOptions
+	LimitReqZones   []version2.LimitReqZone
+	LimitReqs       []version2.LimitReq
+	LimitConns      []version2.LimitConn
+	JWTAuth         *version2.JWTAuth
+	BasicAuth       *version2.BasicAuth
+	OIDC            *version2.OIDC
+	EgressMTLS      *version2.EgressMTLS
+	CORS            *version2.CORS
+	Maps            []version2.Map
+	ErrorReturn     *version2.Return
+}
 
-		upsServers = append(upsServers, s)
-	}
+// policyOptions holds the data required to generate policies that is not part of the Policy resources.
+type policyOptions struct {
+	// jwtKeyFileNames maps the keys (namespace/name) of the JWK Secrets to their file names.
+	jwtKeyFileNames map[string]string
+	// oidcClientSecrets maps the keys (namespace/name) of the OIDC client Secrets to the client secrets.
+	oidcClientSecrets map[string]string
+	// egressTLSSecretFileNames maps the keys (namespace/name) of the TLS Secrets of egressMTLS policies to their file names.
+	egressTLSSecretFileNames map[string]string
+	// trustedCAFileNames maps the keys (namespace/name) of the CA Secrets to their file names.
+	trustedCAFileNames map[string]string
+	// htpasswdFileNames maps the keys (namespace/name) of the htpasswd Secrets to their file names.
+	htpasswdFileNames map[string]string
+}
 
-	lbMethod := generateLBMethod(upstream.LBMethod, vsc.cfgParams.LBMethod)
+// The contexts where policies can be referenced.
+const (
+	specContext     = "spec"
+	routeContext    = "route"
+	subRouteContext = "subroute"
+)
 
-	ups := version2.Upstream{
-		Name:             upstreamName,
-		Servers:          upsServers,
-		Resolve:          isExternalNameSvc,
-		LBMethod:         lbMethod,
-		Keepalive:        generateIntFromPointer(upstream.Keepalive, vsc.cfgParams.Keepalive),
-		MaxFails:         generateIntFromPointer(upstream.MaxFails, vsc.cfgParams.MaxFails),
-		FailTimeout:      generateString(upstream.FailTimeout, vsc.cfgParams.FailTimeout),
-		MaxConns:         generateIntFromPointer(upstream.MaxConns, vsc.cfgParams.MaxConns),
-		UpstreamZoneSize: vsc.cfgParams.UpstreamZoneSize,
-	}
+const (
+	defaultOIDCScope       = "openid"
+	defaultOIDCRedirectURI = "/_codexch"
+)
 
-	if vsc.isPlus {
-		ups.SlowStart = vsc.generateSlowStartForPlus(owner, upstream, lbMethod)
-		ups.Queue = generateQueueForPlus(upstream.Queue, "60s")
-		ups.SessionCookie = generateSessionCookie(upstream.SessionCookie)
-	}
+const (
+	defaultEgressMTLSVerifyDepth = 1
+	defaultEgressMTLSProtocols   = "TLSv1 TLSv1.1 TLSv1.2"
+	defaultEgressMTLSCiphers     = "DEFAULT"
+	defaultEgressMTLSSSLName     = "$proxy_host"
+)
 
-	return ups
-}
+func (vsc *virtualServerConfigurator) generatePolicies(owner runtime.Object, ownerNamespace string, policyRefs []conf_v1.PolicyReference,
+	policies map[string]*conf_v1.Policy, context string, variableNamer *variableNamer, policyOpts policyOptions) policiesCfg {
+	var res policiesCfg
+	appliedPolicies := make(map[string]bool)
 
-func (vsc *virtualServerConfigurator) generateSlowStartForPlus(owner runtime.Object, upstream conf_v1.Upstream, lbMethod string) string {
-	if upstream.SlowStart == "" {
-		return ""
-	}
+	for _, p := range policyRefs {
+		polNamespace := p.Namespace
+		if polNamespace == "" {
+			polNamespace = ownerNamespace
+		}
 
-	_, isIncompatible := incompatibleLBMethodsForSlowStart[lbMethod]
-	isHash := strings.HasPrefix(lbMethod, "hash")
-	if isIncompatible || isHash {
-		msgFmt := "Slow start will be disabled for upstream %v because lb method '%v' is incompatible with slow start"
-		vsc.addWarningf(owner, msgFmt, upstream.Name, lbMethod)
-		return ""
-	}
+		key := fmt.Sprintf("%s/%s", polNamespace, p.Name)
 
-	return upstream.SlowStart
-}
+		// a policy referenced more than once is applied only once
+		if appliedPolicies[key] {
+			continue
+		}
+		appliedPolicies[key] = true
 
-func generateHealthCheck(upstream conf_v1.Upstream, upstreamName string, cfgParams *ConfigParams) *version2.HealthCheck {
-	if upstream.HealthCheck == nil || !upstream.HealthCheck.Enable {
-		return nil
-	}
+		pol, exists := policies[key]
+		if !exists {
+			vsc.addWarningf(owner, WarningCodeInvalidPolicy, WarningSeverityHigh, "Policy %s is missing or invalid", key)
+			res.ErrorReturn = &version2.Return{Code: 500}
+			continue
+		}
 
-	hc := newHealthCheckWithDefaults(upstream, upstreamName, cfgParams)
+		if pol.Spec.RateLimit != nil {
+			rlZoneName := variableNamer.GetNameForRateLimitZone(polNamespace, p.Name)
+			res.LimitReqZones = append(res.LimitReqZones, generateLimitReqZone(rlZoneName, pol.Spec.RateLimit))
+			res.LimitReqs = append(res.LimitReqs, generateLimitReq(rlZoneName, pol.Spec.RateLimit))
+
+			options := generateLimitReqOptions(pol.Spec.RateLimit)
+			if len(res.LimitReqs) == 1 {
+				res.LimitReqOptions = options
+			} else if options != res.LimitReqOptions {
+				msgFmt := "Policy %s: the dryRun, logLevel and rejectCode fields of rate limit policies applied to the same context must be equal, the values of the first rate limit policy will be used"
+				vsc.addWarningf(owner, WarningCodeConflictingPolicies, WarningSeverityLow, msgFmt, key)
+			}
+		} else if pol.Spec.JWTAuth != nil {
+			if res.JWTAuth != nil {
+				vsc.addWarningf(owner, WarningCodeConflictingPolicies, WarningSeverityMedium, "Multiple jwt policies in the same context is not valid. Policy %s will be ignored", key)
+				continue
+			}
 
-	if upstream.HealthCheck.Path != "" {
-		hc.URI = upstream.HealthCheck.Path
+			if res.OIDC != nil {
+				vsc.addWarningf(owner, WarningCodeConflictingPolicies, WarningSeverityMedium, "A jwt policy and an oidc policy in the same context is not valid. Policy %s will be ignored", key)
+				continue
+			}
+
+			jwtSecretKey := fmt.Sprintf("%s/%s", polNamespace, pol.Spec.JWTAuth.Secret)
+			fileName, exists := policyOpts.jwtKeyFileNames[jwtSecretKey]
+			if !exists {
+				vsc.addWarningf(owner, WarningCodeInvalidSecret, WarningSeverityHigh, "Policy %s references a JWK Secret %s which does not exist or is invalid", key, jwtSecretKey)
+				res.ErrorReturn = &version2.Return{Code: 500}
+				continue
+			}
+
+			res.JWTAuth = &version2.JWTAuth{
+				Secret: fileName,
+				Realm:  pol.Spec.JWTAuth.Realm,
+				Token:  pol.Spec.JWTAuth.Token,
+			}
+		} else if pol.Spec.BasicAuth != nil {
+			if res.BasicAuth != nil {
+				vsc.addWarningf(owner, WarningCodeConflictingPolicies, WarningSeverityMedium, "Multiple basicAuth policies in the same context is not valid. Policy %s will be ignored", key)
+				continue
+			}
+
+			htpasswdSecretKey := fmt.Sprintf("%s/%s", polNamespace, pol.Spec.BasicAuth.Secret)
+			fileName, exists := policyOpts.htpasswdFileNames[htpasswdSecretKey]
+			if !exists {
+				vsc.addWarningf(owner, WarningCodeInvalidSecret, WarningSeverityHigh, "Policy %s references an htpasswd Secret %s which does not exist or is invalid", key, htpasswdSecretKey)
+				res.ErrorReturn = &version2.Return{Code: 500}
+				continue
+			}
+
+			res.BasicAuth = &version2.BasicAuth{
+				Secret: fileName,
+				Realm:  pol.Spec.BasicAuth.Realm,
+			}
+		} else if pol.Spec.CORS != nil {
+			if res.CORS != nil {
+				vsc.addWarningf(owner, WarningCodeConflictingPolicies, WarningSeverityMedium, "Multiple cors policies in the same context is not valid. Policy %s will be ignored", key)
+				continue
+			}
+
+			originVariable := variableNamer.GetNameForCORSOriginVariable(polNamespace, p.Name)
+			cors, originMaps := generateCORS(pol.Spec.CORS, originVariable, variableNamer.GetNameForCORSPreflightVariable())
+			res.CORS = cors
+			res.Maps = append(res.Maps, originMaps...)
+		} else if pol.Spec.OIDC != nil {
+			if context != specContext {
+				vsc.addWarningf(owner, WarningCodeInvalidPolicy, WarningSeverityHigh, "OIDC policies can only be referenced in the spec of a VirtualServer. Policy %s will be ignored", key)
+				continue
+			}
+
+			if res.OIDC != nil {
+				vsc.addWarningf(owner, WarningCodeConflictingPolicies, WarningSeverityMedium, "Multiple oidc policies in the same context is not valid. Policy %s will be ignored", key)
+				continue
+			}
+
+			if res.JWTAuth != nil {
+				vsc.addWarningf(owner, WarningCodeConflictingPolicies, WarningSeverityMedium, "A jwt policy and an oidc policy in the same context is not valid. Policy %s will be ignored", key)
+				continue
+			}
+
+			if !vsc.isResolverConfigured {
+				vsc.addWarningf(owner, WarningCodeResolverRequired, WarningSeverityHigh, "Policy %s requires a resolver to reach the OpenID Connect provider, but the resolver is not configured", key)
+				res.ErrorReturn = &version2.Return{Code: 500}
+				continue
+			}
+
+			oidcSecretKey := fmt.Sprintf("%s/%s", polNamespace, pol.Spec.OIDC.ClientSecret)
+			clientSecret, exists := policyOpts.oidcClientSecrets[oidcSecretKey]
+			if !exists {
+				vsc.addWarningf(owner, WarningCodeInvalidSecret, WarningSeverityHigh, "Policy %s references an OIDC client Secret %s which does not exist or is invalid", key, oidcSecretKey)
+				res.ErrorReturn = &version2.Return{Code: 500}
+				continue
+			}
+
+			res.OIDC = &version2.OIDC{
+				AuthEndpoint:  pol.Spec.OIDC.AuthEndpoint,
+				TokenEndpoint: pol.Spec.OIDC.TokenEndpoint,
+				JwksURI:       pol.Spec.OIDC.JWKSURI,
+				ClientID:      pol.Spec.OIDC.ClientID,
+				ClientSecret:  clientSecret,
+				Scope:         generateString(pol.Spec.OIDC.Scope, defaultOIDCScope),
+				RedirectURI:   generateString(pol.Spec.OIDC.RedirectURI, defaultOIDCRedirectURI),
+				HMACKey:       generateOIDCHMACKey(key, clientSecret),
+			}
+		} else if pol.Spec.EgressMTLS != nil {
+			if res.EgressMTLS != nil {
+				vsc.addWarningf(owner, WarningCodeConflictingPolicies, WarningSeverityMedium, "Multiple egressMTLS policies in the same context is not valid. Policy %s will be ignored", key)
+				continue
+			}
+
+			egressMTLS := pol.Spec.EgressMTLS
+
+			var certFileName string
+			if egressMTLS.TLSSecret != "" {
+				tlsSecretKey := fmt.Sprintf("%s/%s", polNamespace, egressMTLS.TLSSecret)
+				fileName, exists := policyOpts.egressTLSSecretFileNames[tlsSecretKey]
+				if !exists {
+					vsc.addWarningf(owner, WarningCodeInvalidSecret, WarningSeverityHigh, "Policy %s references a TLS Secret %s which does not exist or is invalid", key, tlsSecretKey)
+					res.ErrorReturn = &version2.Return{Code: 500}
+					continue
+				}
+				certFileName = fileName
+			}
+
+			var trustedCertFileName string
+			if egressMTLS.TrustedCertSecret != "" {
+				caSecretKey := fmt.Sprintf("%s/%s", polNamespace, egressMTLS.TrustedCertSecret)
+				fileName, exists := policyOpts.trustedCAFileNames[caSecretKey]
+				if !exists {
+					vsc.addWarningf(owner, WarningCodeInvalidSecret, WarningSeverityHigh, "Policy %s references a CA Secret %s which does not exist or is invalid", key, caSecretKey)
+					res.ErrorReturn = &version2.Return{Code: 500}
+					continue
+				}
+				trustedCertFileName = fileName
+			}
+
+			res.EgressMTLS = &version2.EgressMTLS{
+				Certificate:    certFileName,
+				CertificateKey: certFileName,
+				VerifyServer:   egressMTLS.VerifyServer,
+				VerifyDepth:    generateIntFromPointer(egressMTLS.VerifyDepth, defaultEgressMTLSVerifyDepth),
+				Protocols:      generateString(egressMTLS.Protocols, defaultEgressMTLSProtocols),
+				SessionReuse:   generateBool(egressMTLS.SessionReuse, true),
+				Ciphers:        generateString(egressMTLS.Ciphers, defaultEgressMTLSCiphers),
+				TrustedCert:    trustedCertFileName,
+				ServerName:     egressMTLS.ServerName,
+				SSLName:        generateString(egressMTLS.SSLName, defaultEgressMTLSSSLName),
+			}
+		}
+	}
+
+	return res
+}
+
+// generateOIDCHMACKey generates the key for signing the nonces of the OpenID Connect flow.
+// The key is derived from the client secret, so that it is not predictable and stays the same across reloads.
+func generateOIDCHMACKey(policyKey string, clientSecret string) string {
+	h := sha256.Sum256([]byte(policyKey + "/" + clientSecret))
+	return hex.EncodeToString(h[:])
+}
+
+func generateLimitReqZone(zoneName string, rateLimit *conf_v1.RateLimit) version2.LimitReqZone {
+	return version2.LimitReqZone{
+		ZoneName: zoneName,
+		Key:      rateLimit.Key,
+		ZoneSize: rateLimit.ZoneSize,
+		Rate:     rateLimit.Rate,
+	}
+}
+
+func generateLimitReq(zoneName string, rateLimit *conf_v1.RateLimit) version2.LimitReq {
+	return version2.LimitReq{
+		ZoneName: zoneName,
+		Burst:    generateIntFromPointer(rateLimit.Burst, 0),
+		Delay:    generateIntFromPointer(rateLimit.Delay, 0),
+		NoDelay:  generateBool(rateLimit.NoDelay, false),
+	}
+}
+
+func generateLimitReqOptions(rateLimit *conf_v1.RateLimit) version2.LimitReqOptions {
+	return version2.LimitReqOptions{
+		DryRun:     generateBool(rateLimit.DryRun, false),
+		LogLevel:   generateString(rateLimit.LogLevel, "error"),
+		RejectCode: generateIntFromPointer(rateLimit.RejectCode, 503),
+	}
+}
+
+func generateRouteLimitReqZone(zoneName string, limitReq *conf_v1.RouteLimitReq) version2.LimitReqZone {
+	return version2.LimitReqZone{
+		ZoneName: zoneName,
+		Key:      generateString(limitReq.Key, "${binary_remote_addr}"),
+		ZoneSize: "10m",
+		Rate:     limitReq.Rate,
+	}
+}
+
+// addRouteLimitReqToPoliciesCfg adds the limit of the limitReq field of a route to the limits of the policies of the route.
+// If the policies don't limit the rate of requests, the limit uses the default options.
+func addRouteLimitReqToPoliciesCfg(zoneName string, limitReq *conf_v1.RouteLimitReq, cfg *policiesCfg) {
+	if len(cfg.LimitReqs) == 0 {
+		cfg.LimitReqOptions = generateLimitReqOptions(&conf_v1.RateLimit{})
+	}
+
+	cfg.LimitReqs = append(cfg.LimitReqs, version2.LimitReq{
+		ZoneName: zoneName,
+		Burst:    generateIntFromPointer(limitReq.Burst, 0),
+	})
+}
+
+func generateLimitConnZone(zoneName string, limitConn *conf_v1.LimitConn) version2.LimitConnZone {
+	return version2.LimitConnZone{
+		ZoneName: zoneName,
+		Key:      generateString(limitConn.Key, "${binary_remote_addr}"),
+		ZoneSize: generateString(limitConn.ZoneSize, "10m"),
+	}
+}
+
+func generateLimitConn(zoneName string, limitConn *conf_v1.LimitConn) version2.LimitConn {
+	return version2.LimitConn{
+		ZoneName: zoneName,
+		Max:      limitConn.Max,
+	}
+}
+
+// addExternalNameAddressesToLocations makes the locations of the upstreams of ExternalName services pass the requests
+// to the addresses of the services through variables, so that NGINX resolves the addresses at runtime with the resolver
+// of the ConfigMap rather than once on reload, which fails if the name doesn't resolve. It returns the maps of the variables.
+func addExternalNameAddressesToLocations(upstreams []version2.Upstream, crUpstreams map[string]conf_v1.Upstream, addresses map[string]string,
+	locations []version2.Location) []version2.Map {
+	var maps []version2.Map
+
+	for _, ups := range upstreams {
+		address, exists := addresses[ups.Name]
+		if !exists {
+			continue
+		}
+
+		variable := fmt.Sprintf("$%s_address", ups.Name)
+		protocol := generateProxyPassProtocol(crUpstreams[ups.Name].TLS.Enable)
+		proxyPass := fmt.Sprintf("%v://%v", protocol, ups.Name)
+
+		for i := range locations {
+			if locations[i].ProxyPass == proxyPass {
+				locations[i].ProxyPass = fmt.Sprintf("%v://%v", protocol, variable)
+			}
+		}
+
+		maps = append(maps, version2.Map{
+			Source:   "$host",
+			Variable: variable,
+			Parameters: []version2.Parameter{
+				{
+					Value:  "default",
+					Result: fmt.Sprintf("%q", address),
+				},
+			},
+		})
+	}
+
+	return maps
+}
+
+// emulateUpstreamQueues approximates the queue of the upstreams for NGINX, which doesn't support the queue directive.
+// The requests to an upstream are limited to the size of the queue drained within the timeout, with the size as the
+// burst. If the upstream limits the connections to its servers, the requests are also limited to the total of max-conns.
+// Because a location-level limit_req or limit_conn cancels the server-level ones, the locations keep the limits of the server.
+func emulateUpstreamQueues(upstreams []version2.Upstream, crUpstreams map[string]conf_v1.Upstream, locations []version2.Location,
+	serverPoliciesCfg policiesCfg, serverLimitConns []version2.LimitConn) ([]version2.LimitReqZone, []version2.LimitConnZone) {
+	var limitReqZones []version2.LimitReqZone
+	var limitConnZones []version2.LimitConnZone
+
+	for _, ups := range upstreams {
+		u := crUpstreams[ups.Name]
+		if u.Queue == nil {
+			continue
+		}
+
+		limitReqZone, limitReq := generateQueueLimitReq(ups.Name, u.Queue)
+		limitReqZones = append(limitReqZones, limitReqZone)
+
+		var limitConn *version2.LimitConn
+		if ups.MaxConns > 0 {
+			zoneName := fmt.Sprintf("%s_queue_conn", ups.Name)
+			limitConnZones = append(limitConnZones, version2.LimitConnZone{
+				Key:      ups.Name,
+				ZoneName: zoneName,
+				ZoneSize: "10m",
+			})
+			limitConn = &version2.LimitConn{
+				ZoneName: zoneName,
+				Max:      ups.MaxConns * len(ups.Servers),
+			}
+		}
+
+		proxyPass := fmt.Sprintf("%v://%v", generateProxyPassProtocol(u.TLS.Enable), ups.Name)
+		for i := range locations {
+			loc := &locations[i]
+			if loc.ProxyPass != proxyPass {
+				continue
+			}
+
+			if len(loc.LimitReqs) == 0 {
+				loc.LimitReqs = append(loc.LimitReqs, serverPoliciesCfg.LimitReqs...)
+				loc.LimitReqOptions = serverPoliciesCfg.LimitReqOptions
+				if len(serverPoliciesCfg.LimitReqs) == 0 {
+					loc.LimitReqOptions = generateLimitReqOptions(&conf_v1.RateLimit{})
+				}
+			}
+			loc.LimitReqs = append(loc.LimitReqs, limitReq)
+
+			if limitConn != nil {
+				if len(loc.LimitConns) == 0 {
+					loc.LimitConns = append(loc.LimitConns, serverLimitConns...)
+				}
+				loc.LimitConns = append(loc.LimitConns, *limitConn)
+			}
+		}
+	}
+
+	return limitReqZones, limitConnZones
+}
+
+// generateQueueLimitReq generates the rate limit that drains the queue within the timeout.
+// The key of the zone is constant, so that all requests to the upstream share the limit.
+func generateQueueLimitReq(upstreamName string, queue *conf_v1.UpstreamQueue) (version2.LimitReqZone, version2.LimitReq) {
+	timeout, err := ParseTimeToSeconds(generateString(queue.Timeout, "60s"))
+	if err != nil || timeout < 1 {
+		timeout = 1
+	}
+
+	// the rate is per minute, so that small queues with long timeouts don't round to zero
+	rate := (int64(queue.Size)*60 + timeout - 1) / timeout
+	zoneName := fmt.Sprintf("%s_queue", upstreamName)
+
+	zone := version2.LimitReqZone{
+		Key:      upstreamName,
+		ZoneName: zoneName,
+		ZoneSize: "10m",
+		Rate:     fmt.Sprintf("%dr/m", rate),
+	}
+	limitReq := version2.LimitReq{
+		ZoneName: zoneName,
+		Burst:    queue.Size,
+	}
+
+	return zone, limitReq
+}
+
+func removeDuplicateLimitReqZones(zones []version2.LimitReqZone) []version2.LimitReqZone {
+	var result []version2.LimitReqZone
+	seen := make(map[string]bool)
+
+	for _, z := range zones {
+		if seen[z.ZoneName] {
+			continue
+		}
+
+		seen[z.ZoneName] = true
+		result = append(result, z)
+	}
+
+	return result
+}
+
+const defaultCORSAllowMethods = "GET, HEAD, POST, PUT, PATCH, DELETE"
+
+// generateCORS generates the headers of a CORS policy. Unless the policy allows any origin, the allowed origins are
+// matched by a map, which returns the origin of the request if it is allowed.
+func generateCORS(cors *conf_v1.CORS, originVariable string, preflightVariable string) (*version2.CORS, []version2.Map) {
+	var maps []version2.Map
+
+	allowOrigin := "*"
+	if len(cors.AllowOrigins) != 1 || cors.AllowOrigins[0] != "*" {
+		allowOrigin = originVariable
+		maps = append(maps, generateCORSOriginMap(cors.AllowOrigins, originVariable))
+	}
+
+	headers := []version2.AddHeader{{Name: "Access-Control-Allow-Origin", Value: allowOrigin}}
+	if cors.AllowCredentials {
+		headers = append(headers, version2.AddHeader{Name: "Access-Control-Allow-Credentials", Value: "true"})
+	}
+	if len(cors.ExposeHeaders) > 0 {
+		headers = append(headers, version2.AddHeader{Name: "Access-Control-Expose-Headers", Value: strings.Join(cors.ExposeHeaders, ", ")})
+	}
+	if allowOrigin != "*" {
+		// the response depends on the origin of the request, so that shared caches must not reuse it for other origins
+		headers = append(headers, version2.AddHeader{Name: "Vary", Value: "Origin"})
+	}
+
+	allowMethods := defaultCORSAllowMethods
+	if len(cors.AllowMethods) > 0 {
+		allowMethods = strings.Join(cors.AllowMethods, ", ")
+	}
+
+	// without the allowed headers, the headers requested by the browser are allowed
+	allowHeaders := "$http_access_control_request_headers"
+	if len(cors.AllowHeaders) > 0 {
+		allowHeaders = strings.Join(cors.AllowHeaders, ", ")
+	}
+
+	preflightHeaders := []version2.AddHeader{
+		{Name: "Access-Control-Allow-Methods", Value: allowMethods},
+		{Name: "Access-Control-Allow-Headers", Value: allowHeaders},
+	}
+	if cors.MaxAge != nil {
+		preflightHeaders = append(preflightHeaders, version2.AddHeader{Name: "Access-Control-Max-Age", Value: strconv.Itoa(*cors.MaxAge)})
+	}
+
+	return &version2.CORS{
+		Headers:           headers,
+		PreflightVariable: preflightVariable,
+		PreflightHeaders:  preflightHeaders,
+	}, maps
+}
+
+// generateCORSOriginMap generates the map that returns the origin of the request if it is allowed.
+// The origins with a wildcard host or port are converted to regular expressions.
+func generateCORSOriginMap(origins []string, variable string) version2.Map {
+	var params []version2.Parameter
+
+	for _, origin := range origins {
+		value := origin
+		if !strings.HasPrefix(origin, "~") && strings.Contains(origin, "*") {
+			value = "~^" + strings.ReplaceAll(origin, ".", `\.`) + "$"
+			value = strings.Replace(value, `://*\.`, `://[a-zA-Z0-9-]+(\.[a-zA-Z0-9-]+)*\.`, 1)
+			value = strings.Replace(value, ":*$", "(:[0-9]+)?$", 1)
+		}
+
+		params = append(params, version2.Parameter{
+			Value:  fmt.Sprintf(`"%s"`, value),
+			Result: "$http_origin",
+		})
+	}
+
+	params = append(params, version2.Parameter{
+		Value:  "default",
+		Result: `""`,
+	})
+
+	return version2.Map{
+		Source:     "$http_origin",
+		Variable:   variable,
+		Parameters: params,
+	}
+}
+
+// generateCORSPreflightMap generates the map that detects the CORS preflight requests,
+// which are OPTIONS requests with the Access-Control-Request-Method header.
+func generateCORSPreflightMap(variable string) version2.Map {
+	return version2.Map{
+		Source:   `"$request_method:$http_access_control_request_method"`,
+		Variable: variable,
+		Parameters: []version2.Parameter{
+			{
+				Value:  `"~^OPTIONS:.+"`,
+				Result: "1",
+			},
+			{
+				Value:  "default",
+				Result: "0",
+			},
+		},
+	}
+}
+
+func addCORSToLocations(cors *version2.CORS, locations []version2.Location) {
+	if cors == nil {
+		return
+	}
+
+	for i := range locations {
+		if locations[i].CORS == nil {
+			locations[i].CORS = cors
+		}
+	}
+}
+
+func locationsUseCORS(locations []version2.Location) bool {
+	for _, loc := range locations {
+		if loc.CORS != nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// removeDuplicateMaps removes the maps of the policies referenced more than once, which define the same variable.
+func removeDuplicateMaps(maps []version2.Map) []version2.Map {
+	var result []version2.Map
+	seen := make(map[string]bool)
+
+	for _, m := range maps {
+		if seen[m.Variable] {
+			continue
+		}
+
+		seen[m.Variable] = true
+		result = append(result, m)
+	}
+
+	return result
+}
+
+func addPoliciesCfgToLocation(cfg policiesCfg, location *version2.Location) {
+	location.LimitReqOptions = cfg.LimitReqOptions
+	location.LimitReqs = cfg.LimitReqs
+	location.LimitConns = cfg.LimitConns
+	location.PoliciesErrorReturn = cfg.ErrorReturn
+	location.JWTAuth = cfg.JWTAuth
+	location.BasicAuth = cfg.BasicAuth
+	location.EgressMTLS = cfg.EgressMTLS
+	location.CORS = cfg.CORS
+}
+
+func addPoliciesCfgToLocations(cfg policiesCfg, locations []version2.Location) {
+	for i := range locations {
+		addPoliciesCfgToLocation(cfg, &locations[i])
+	}
+}
+
+// isUpstreamResolved returns true if NGINX discovers the servers of the upstream by resolving the name of its service.
+func (vsc *virtualServerConfigurator) isUpstreamResolved(upstream conf_v1.Upstream) bool {
+	return vsc.isPlus && upstream.Resolve && vsc.isResolverConfigured
+}
+
+// generateServiceAddress returns the address of a service with the fully qualified domain name of the service.
+// For a headless service, the name resolves to the IP addresses of the pods of the service.
+func generateServiceAddress(namespace string, service string, port uint16, clusterDomain string) string {
+	return fmt.Sprintf("%s.%s.svc.%s:%d", service, namespace, clusterDomain, port)
+}
+
+func (vsc *virtualServerConfigurator) generateUpstream(owner runtime.Object, upstreamName string, upstream conf_v1.Upstream, resolve bool,
+	endpoints []string, drainedEndpoints map[string]bool, terminatingEndpoints map[string]bool, remoteZoneEndpoints map[string]bool) version2.Upstream {
+	var upsServers []version2.UpstreamServer
+	for _, e := range sortEndpoints(endpoints) {
+		s := version2.UpstreamServer{
+			Address: e,
+			Drain:   vsc.isPlus && drainedEndpoints[e],
+			Down:    vsc.isPlus && terminatingEndpoints[e],
+		}
+
+		upsServers = append(upsServers, s)
+	}
+
+	lbMethod := generateLBMethod(upstream.LBMethod, vsc.cfgParams.LBMethod)
+	if upstream.TopologyPolicy == topologyPolicyPreferZone && upstream.LBMethod == "" && isLBMethodIncompatibleWithBackup(lbMethod) {
+		// the lb method from the ConfigMap, like the default random two least_conn, doesn't support backup servers
+		lbMethod = "least_conn"
+	}
+
+	vsc.markRemoteZoneServersAsBackup(owner, upstream, lbMethod, upsServers, remoteZoneEndpoints)
+
+	ups := version2.Upstream{
+		Name:             upstreamName,
+		Servers:          upsServers,
+		Resolve:          resolve,
+		LBMethod:         lbMethod,
+		Keepalive:        generateIntFromPointer(upstream.Keepalive, vsc.cfgParams.Keepalive),
+		MaxFails:         generateIntFromPointer(upstream.MaxFails, vsc.cfgParams.MaxFails),
+		FailTimeout:      generateString(upstream.FailTimeout, vsc.cfgParams.FailTimeout),
+		MaxConns:         generateIntFromPointer(upstream.MaxConns, vsc.cfgParams.MaxConns),
+		UpstreamZoneSize: vsc.cfgParams.UpstreamZoneSize,
+	}
+
+	vsc.checkContradictoryUpstreamSettings(owner, upstream)
+	vsc.checkUpstreamRetries(owner, upstream)
+
+	if vsc.isPlus {
+		ups.SlowStart = vsc.generateSlowStartForPlus(owner, upstream, lbMethod)
+		ups.Queue = generateQueueForPlus(upstream.Queue, "60s")
+		ups.SessionCookie = generateSessionCookie(upstream.SessionCookie)
+	} else if vsc.emulateQueue && upstream.Queue != nil {
+		vsc.addWarningf(owner, WarningCodeApproximatedSetting, WarningSeverityMedium,
+			"queue of upstream %v is approximated with limit_conn and limit_req: the requests over the limits are rejected rather than wait for a free server",
+			upstream.Name)
+	}
+
+	return ups
+}
+
+// topologyPolicyPreferZone is the topology policy of an upstream that prefers the endpoints in the zone
+// of the Ingress Controller.
+const topologyPolicyPreferZone = "prefer-zone"
+
+// isLBMethodIncompatibleWithBackup returns true if NGINX doesn't support backup servers with the lb method.
+func isLBMethodIncompatibleWithBackup(lbMethod string) bool {
+	return lbMethod == "ip_hash" || strings.HasPrefix(lbMethod, "hash") || strings.HasPrefix(lbMethod, "random")
+}
+
+// markRemoteZoneServersAsBackup makes the servers in other zones than the zone of the Ingress Controller backup
+// servers, if the upstream prefers the endpoints in the same zone. NGINX passes the requests to the backup servers
+// only when all primary servers are unavailable. Because an upstream can't consist of backup servers only,
+// the servers stay primary if none of the available servers is in the same zone.
+func (vsc *virtualServerConfigurator) markRemoteZoneServersAsBackup(owner runtime.Object, upstream conf_v1.Upstream, lbMethod string,
+	servers []version2.UpstreamServer, remoteZoneEndpoints map[string]bool) {
+	if upstream.TopologyPolicy != topologyPolicyPreferZone || len(remoteZoneEndpoints) == 0 {
+		return
+	}
+
+	if isLBMethodIncompatibleWithBackup(lbMethod) {
+		msgFmt := "Topology policy %v will be ignored for upstream %v because lb method '%v' is incompatible with backup servers"
+		vsc.addWarningf(owner, WarningCodeIgnoredSetting, WarningSeverityLow, msgFmt, upstream.TopologyPolicy, upstream.Name, lbMethod)
+		return
+	}
+
+	hasLocalServers := false
+	for _, s := range servers {
+		if !remoteZoneEndpoints[s.Address] && !s.Drain && !s.Down {
+			hasLocalServers = true
+			break
+		}
+	}
+	if !hasLocalServers {
+		return
+	}
+
+	for i := range servers {
+		servers[i].Backup = remoteZoneEndpoints[servers[i].Address]
+	}
+}
+
+func (vsc *virtualServerConfigurator) generateSlowStartForPlus(owner runtime.Object, upstream conf_v1.Upstream, lbMethod string) string {
+	if upstream.SlowStart == "" {
+		return ""
+	}
+
+	_, isIncompatible := incompatibleLBMethodsForSlowStart[lbMethod]
+	isHash := strings.HasPrefix(lbMethod, "hash")
+	if isIncompatible || isHash {
+		msgFmt := "Slow start will be disabled for upstream %v because lb method '%v' is incompatible with slow start"
+		vsc.addWarningf(owner, WarningCodeIgnoredSetting, WarningSeverityLow, msgFmt, upstream.Name, lbMethod)
+		return ""
+	}
+
+	return upstream.SlowStart
+}
+
+// defaultRetriesTries is the number of the tries of a request, including the first one, when the retries of an upstream
+// don't set it.
+const defaultRetriesTries = 3
+
+// checkUpstreamRetries adds a warning if the upstream passes the requests with non-idempotent methods, like POST,
+// to the next server, so that the same request can be processed by several servers.
+func (vsc *virtualServerConfigurator) checkUpstreamRetries(owner runtime.Object, upstream conf_v1.Upstream) {
+	conditions := strings.Fields(upstream.ProxyNextUpstream)
+	fieldName := "next-upstream"
+	if upstream.Retries != nil {
+		conditions = upstream.Retries.Conditions
+		fieldName = "retries.conditions"
+	}
+
+	for _, c := range conditions {
+		if c == "non_idempotent" {
+			msgFmt := "Upstream %v: %v includes non_idempotent, so the requests with non-idempotent methods, like POST, can be processed by several servers"
+			vsc.addWarningf(owner, WarningCodeUnsafeRetries, WarningSeverityMedium, msgFmt, upstream.Name, fieldName)
+			return
+		}
+	}
+}
+
+// upstreamHasRetries returns true if the upstream can pass a request to the next server.
+func upstreamHasRetries(upstream conf_v1.Upstream) bool {
+	if upstream.Retries != nil {
+		return len(upstream.Retries.Conditions) != 1 || upstream.Retries.Conditions[0] != "off"
+	}
+
+	return upstream.ProxyNextUpstream != "off" && (upstream.ProxyNextUpstream != "" || upstream.ProxyNextUpstreamTries != 0)
+}
+
+// checkContradictoryUpstreamSettings adds warnings for the settings of an upstream that are valid on their own,
+// but contradict each other, so that NGINX silently ignores some of them or talks to the backend in an unexpected way.
+func (vsc *virtualServerConfigurator) checkContradictoryUpstreamSettings(owner runtime.Object, upstream conf_v1.Upstream) {
+	if upstream.TLS.Enable && upstream.Port == 80 {
+		msgFmt := "Upstream %v: tls.enable is true, but port 80 is used for plain HTTP. NGINX will attempt a TLS handshake with port 80"
+		vsc.addWarningf(owner, WarningCodeContradictorySetting, WarningSeverityMedium, msgFmt, upstream.Name)
+	}
+
+	if !upstream.TLS.Enable && upstream.Port == 443 {
+		msgFmt := "Upstream %v: port 443 is used for HTTPS, but tls.enable is false. NGINX will send plain HTTP requests to port 443"
+		vsc.addWarningf(owner, WarningCodeContradictorySetting, WarningSeverityMedium, msgFmt, upstream.Name)
+	}
+
+	if upstream.ProxyBuffering != nil && !*upstream.ProxyBuffering && upstream.ProxyBuffers != nil {
+		msgFmt := "Upstream %v: buffers has no effect because buffering is false"
+		vsc.addWarningf(owner, WarningCodeContradictorySetting, WarningSeverityLow, msgFmt, upstream.Name)
+	}
+
+	if upstream.ProxyNextUpstream == "off" && (upstream.ProxyNextUpstreamTries != 0 || upstream.ProxyNextUpstreamTimeout != "") {
+		msgFmt := "Upstream %v: next-upstream-tries and next-upstream-timeout have no effect because next-upstream is off"
+		vsc.addWarningf(owner, WarningCodeContradictorySetting, WarningSeverityLow, msgFmt, upstream.Name)
+	}
+
+	if upstream.ProxyRequestBuffering != nil && !*upstream.ProxyRequestBuffering && upstreamHasRetries(upstream) {
+		msgFmt := "Upstream %v: request-buffering is false, so a request is not passed to the next server once its body has been sent. The retries only apply before the body is sent"
+		vsc.addWarningf(owner, WarningCodeContradictorySetting, WarningSeverityLow, msgFmt, upstream.Name)
+	}
+
+	hc := upstream.HealthCheck
+	if hc != nil && hc.Enable && hc.TLS != nil && hc.TLS.Enable != upstream.TLS.Enable && (hc.Port == 0 || hc.Port == int(upstream.Port)) {
+		msgFmt := "Upstream %v: healthCheck.tls.enable is %v, but tls.enable is %v for the same port. Health checks will use a different protocol than the requests"
+		vsc.addWarningf(owner, WarningCodeContradictorySetting, WarningSeverityMedium, msgFmt, upstream.Name, hc.TLS.Enable, upstream.TLS.Enable)
+	}
+}
+
+func generateHealthCheck(upstream conf_v1.Upstream, upstreamName string, cfgParams *ConfigParams) *version2.HealthCheck {
+	if upstream.HealthCheck == nil || !upstream.HealthCheck.Enable {
+		return nil
+	}
+
+	hc := newHealthCheckWithDefaults(upstream, upstreamName, cfgParams)
+
+	if upstream.HealthCheck.Path != "" {
+		hc.URI = upstream.HealthCheck.Path
 	}
 
 	if upstream.HealthCheck.Interval != "" {
@@ -405,14 +1719,32 @@ func generateHealthCheck(upstream conf_v1.Upstream, upstreamName string, cfgPara
 		hc.Headers[h.Name] = h.Value
 	}
 
+	if upstream.HealthCheck.CloseConnection {
+		hc.Headers["Connection"] = "close"
+	}
+
 	if upstream.HealthCheck.TLS != nil {
 		hc.ProxyPass = fmt.Sprintf("%v://%v", generateProxyPassProtocol(upstream.HealthCheck.TLS.Enable), upstreamName)
 	}
 
-	if upstream.HealthCheck.StatusMatch != "" {
+	if tls := upstream.HealthCheck.TLS; tls != nil && tls.Enable {
+		hc.ProxySSLServerName = tls.ServerName
+		hc.ProxySSLName = tls.SSLName
+
+		// the endpoints expect the same host in the Host header as in SNI
+		if tls.SSLName != "" && !hasHeader(upstream.HealthCheck.Headers, "Host") {
+			hc.Headers["Host"] = tls.SSLName
+		}
+	}
+
+	if hasHealthCheckMatch(upstream.HealthCheck) {
 		hc.Match = generateStatusMatchName(upstreamName)
 	}
 
+	hc.Mandatory = upstream.HealthCheck.Mandatory
+	hc.Persistent = upstream.HealthCheck.Persistent
+	hc.KeepaliveTime = upstream.HealthCheck.KeepaliveTime
+
 	return hc
 }
 
@@ -436,11 +1768,121 @@ func generateStatusMatchName(upstreamName string) string {
 	return fmt.Sprintf("%s_match", upstreamName)
 }
 
-func generateUpstreamStatusMatch(upstreamName string, status string) version2.StatusMatch {
+// hasHeader returns true if the headers include the header with the name.
+func hasHeader(headers []conf_v1.Header, name string) bool {
+	for _, h := range headers {
+		if strings.EqualFold(h.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// addHealthCheckTrustedCert configures the health check to verify the certificates of the endpoints with the CA certificate
+// of the trusted Secret. Without a valid Secret, the health check doesn't verify the certificates.
+func (vsc *virtualServerConfigurator) addHealthCheckTrustedCert(owner runtime.Object, namespace string, upstream conf_v1.Upstream,
+	hc *version2.HealthCheck, trustedCAFileNames map[string]string) {
+	tls := upstream.HealthCheck.TLS
+	if tls == nil || !tls.Enable || !tls.VerifyServer {
+		return
+	}
+
+	caSecretKey := fmt.Sprintf("%s/%s", namespace, tls.TrustedCertSecret)
+	fileName, exists := trustedCAFileNames[caSecretKey]
+	if !exists {
+		vsc.addWarningf(owner, WarningCodeInvalidSecret, WarningSeverityHigh,
+			"Upstream %s references a CA Secret %s which does not exist or is invalid. The health checks will not verify the certificates of the endpoints",
+			upstream.Name, caSecretKey)
+		return
+	}
+
+	hc.ProxySSLVerify = true
+	hc.ProxySSLTrustedCert = fileName
+}
+
+// addHealthCheckServicePort makes the health check of the upstream use the target port of the port of the service
+// referenced by portName. If the port can't be resolved, the health checks use the ports of the upstream servers.
+func (vsc *virtualServerConfigurator) addHealthCheckServicePort(owner runtime.Object, namespace string, upstream conf_v1.Upstream,
+	hc *version2.HealthCheck, healthCheckPorts map[string]int32) {
+	portName := upstream.HealthCheck.PortName
+	if portName == "" {
+		return
+	}
+
+	port, exists := healthCheckPorts[GenerateHealthCheckPortKey(namespace, upstream.Service, portName)]
+	if !exists {
+		vsc.addWarningf(owner, WarningCodeIgnoredSetting, WarningSeverityMedium,
+			"Upstream %s references the port %s of service %s for the health checks, which can't be resolved. The health checks will use the ports of the upstream servers",
+			upstream.Name, portName, upstream.Service)
+		hc.Port = 0
+		return
+	}
+
+	hc.Port = int(port)
+}
+
+// GenerateHealthCheckPortKey generates a key for the HealthCheckPorts map in VirtualServerEx.
+func GenerateHealthCheckPortKey(namespace string, service string, portName string) string {
+	return fmt.Sprintf("%s/%s:%s", namespace, service, portName)
+}
+
+// defaultHealthCheckStatusMatch are the status codes NGINX Plus expects from the health checks without a match block.
+const defaultHealthCheckStatusMatch = "200-399"
+
+// hasHealthCheckMatch returns true if the health check requires a match block for the responses.
+func hasHealthCheckMatch(hc *conf_v1.HealthCheck) bool {
+	return hc.StatusMatch != "" || hc.BodyMatch != "" || len(hc.HeaderMatches) > 0
+}
+
+// generateUpstreamStatusMatch generates the match block for the responses to the health checks. Without statusMatch,
+// the match block keeps the status codes NGINX Plus expects by default.
+func generateUpstreamStatusMatch(upstreamName string, hc *conf_v1.HealthCheck) version2.StatusMatch {
+	status := hc.StatusMatch
+	if status == "" {
+		status = defaultHealthCheckStatusMatch
+	}
+
+	var headers []string
+	for _, h := range hc.HeaderMatches {
+		headers = append(headers, generateHealthCheckHeaderMatch(h))
+	}
+
+	var body string
+	if hc.BodyMatch != "" {
+		body = generateHealthCheckRegexMatch(hc.BodyMatch)
+	}
+
 	return version2.StatusMatch{
-		Name: generateStatusMatchName(upstreamName),
-		Code: status,
+		Name:    generateStatusMatchName(upstreamName),
+		Code:    status,
+		Headers: headers,
+		Body:    body,
+	}
+}
+
+// generateHealthCheckHeaderMatch generates the condition of the header directive of a match block.
+func generateHealthCheckHeaderMatch(h conf_v1.HeaderMatch) string {
+	if h.ValueRegex != "" {
+		return fmt.Sprintf("%s %s", h.Name, generateHealthCheckRegexMatch(h.ValueRegex))
+	}
+
+	if h.Value != "" {
+		return fmt.Sprintf(`%s = "%s"`, h.Name, nginxQuotedStringEscaper.Replace(h.Value))
+	}
+
+	return h.Name
+}
+
+// generateHealthCheckRegexMatch generates the operator and the quoted regular expression of a match block.
+// A leading '!' of the regular expression negates the match.
+func generateHealthCheckRegexMatch(regex string) string {
+	operator := "~"
+	if strings.HasPrefix(regex, "!") {
+		operator = "!~"
+		regex = regex[1:]
 	}
+
+	return fmt.Sprintf(`%s "%s"`, operator, nginxQuotedStringEscaper.Replace(regex))
 }
 
 // GenerateExternalNameSvcKey returns the key to identify an ExternalName service.
@@ -457,6 +1899,276 @@ func generateLBMethod(method string, defaultMethod string) string {
 	return method
 }
 
+const (
+	defaultAccessLogDestination = "/var/log/nginx/access.log"
+	defaultAccessLogFormatName  = "main"
+	defaultErrorLogDestination  = "/var/log/nginx/error.log"
+)
+
+// generateAccessLog returns the access log of the server and the log format defined for it.
+// It returns nil if the server doesn't configure the access log, so that the access log of the http context applies,
+// unless the JSON preset is configured globally: the JSON log format of a VirtualServer includes its namespace and name,
+// so it is defined for each VirtualServer.
+func generateAccessLog(vs *conf_v1.VirtualServer, cfgParams *ConfigParams, variableNamer *variableNamer) (*version2.AccessLog, *version2.LogFormat) {
+	var accessLog *conf_v1.AccessLog
+	if vs.Spec.Server != nil {
+		accessLog = vs.Spec.Server.AccessLog
+	}
+
+	if accessLog == nil {
+		if cfgParams.MainLogFormatPreset != logFormatPresetJSON || cfgParams.MainAccessLogOff {
+			return nil, nil
+		}
+		accessLog = &conf_v1.AccessLog{}
+	}
+
+	if accessLog.Enable != nil && !*accessLog.Enable {
+		return &version2.AccessLog{Off: true}, nil
+	}
+
+	destination := defaultAccessLogDestination
+	if accessLog.Destination != "" {
+		destination = accessLog.Destination
+	}
+
+	preset := accessLog.FormatPreset
+	if preset == "" && accessLog.FormatName == "" && accessLog.Format == "" {
+		preset = cfgParams.MainLogFormatPreset
+	}
+
+	if preset == logFormatPresetJSON {
+		staticFields := []logField{
+			{name: "virtualserver_namespace", value: vs.Namespace},
+			{name: "virtualserver_name", value: vs.Name},
+		}
+		logFormat := &version2.LogFormat{
+			Name:   variableNamer.GetNameForLogFormat(),
+			Escape: jsonLogFormatEscaping,
+			// the template puts the log format into double quotes
+			Format: strings.ReplaceAll(generateJSONLogFormat(staticFields), `"`, `\"`),
+		}
+		return &version2.AccessLog{Destination: destination, FormatName: logFormat.Name}, logFormat
+	}
+
+	if accessLog.Format != "" {
+		logFormat := &version2.LogFormat{
+			Name:   variableNamer.GetNameForLogFormat(),
+			Format: accessLog.Format,
+		}
+		return &version2.AccessLog{Destination: destination, FormatName: logFormat.Name}, logFormat
+	}
+
+	formatName := defaultAccessLogFormatName
+	if accessLog.FormatName != "" {
+		formatName = accessLog.FormatName
+	}
+
+	return &version2.AccessLog{Destination: destination, FormatName: formatName}, nil
+}
+
+// generateErrorLog returns the error log of the server. It returns nil if the server doesn't configure the error log,
+// so that the error log of the main context applies.
+func generateErrorLog(server *conf_v1.VirtualServerServer, cfgParams *ConfigParams) *version2.ErrorLog {
+	if server == nil || server.ErrorLog == nil {
+		return nil
+	}
+
+	errorLog := &version2.ErrorLog{
+		Destination: defaultErrorLogDestination,
+		Level:       cfgParams.MainErrorLogLevel,
+	}
+
+	if server.ErrorLog.Destination != "" {
+		errorLog.Destination = server.ErrorLog.Destination
+	}
+	if server.ErrorLog.Level != "" {
+		errorLog.Level = server.ErrorLog.Level
+	}
+
+	return errorLog
+}
+
+// defaultRequestIDHeader is the header with the ID of a request if the requestID of the server doesn't set it.
+const defaultRequestIDHeader = "X-Request-ID"
+
+// generateRequestID returns the ID of the requests passed to the upstreams and returned to the clients,
+// and the map that keeps the ID of the request header if the ID is preserved. It returns nil if the ID is not enabled.
+func generateRequestID(server *conf_v1.VirtualServerServer, variableNamer *variableNamer) (*version2.RequestID, []version2.Map) {
+	if server == nil || server.RequestID == nil || !server.RequestID.Enable {
+		return nil, nil
+	}
+
+	header := defaultRequestIDHeader
+	if server.RequestID.Header != "" {
+		header = server.RequestID.Header
+	}
+
+	if !server.RequestID.Preserve {
+		return &version2.RequestID{Header: header, Variable: "$request_id"}, nil
+	}
+
+	source := "$http_" + strings.ReplaceAll(strings.ToLower(header), "-", "_")
+	variable := variableNamer.GetNameForRequestIDVariable()
+
+	idMap := version2.Map{
+		Source:   source,
+		Variable: variable,
+		Parameters: []version2.Parameter{
+			{
+				Value:  "default",
+				Result: source,
+			},
+			{
+				Value:  `""`,
+				Result: "$request_id",
+			},
+		},
+	}
+
+	return &version2.RequestID{Header: header, Variable: variable}, []version2.Map{idMap}
+}
+
+// tracingSamplingPriorityTag is the tag through which the tracer is asked to sample a span (1) or to drop it (0).
+const tracingSamplingPriorityTag = "sampling.priority"
+
+// generateTracing returns the OpenTracing configuration of the server of the VirtualServer and the split clients
+// that choose the requests to sample. Tracing requires the OpenTracing module, which is loaded through the ConfigMap.
+func (vsc *virtualServerConfigurator) generateTracing(vs *conf_v1.VirtualServer, variableNamer *variableNamer) (*version2.Tracing, []version2.SplitClient) {
+	tracing := vs.Spec.Tracing
+	if tracing == nil {
+		return nil, nil
+	}
+
+	if !vsc.cfgParams.MainOpenTracingLoadModule {
+		msg := "Tracing is ignored because the OpenTracing module is not loaded. Configure the opentracing-tracer and opentracing-tracer-config ConfigMap keys to load it"
+		vsc.addWarningf(vs, WarningCodeIgnoredSetting, WarningSeverityMedium, msg)
+		return nil, nil
+	}
+
+	if !tracing.Enable {
+		return &version2.Tracing{Enable: false}, nil
+	}
+
+	var tags []version2.TracingTag
+	for _, t := range tracing.Tags {
+		tags = append(tags, version2.TracingTag{Name: t.Name, Value: t.Value})
+	}
+
+	var splitClients []version2.SplitClient
+	if tracing.SamplingPercentage != nil {
+		variable := variableNamer.GetNameForTracingSamplingVariable()
+
+		var distributions []version2.Distribution
+		if *tracing.SamplingPercentage > 0 {
+			distributions = append(distributions, version2.Distribution{Weight: fmt.Sprintf("%d%%", *tracing.SamplingPercentage), Value: "1"})
+		}
+		distributions = append(distributions, version2.Distribution{Weight: "*", Value: "0"})
+
+		splitClients = append(splitClients, version2.SplitClient{
+			Source:        "$request_id",
+			Variable:      variable,
+			Distributions: distributions,
+		})
+		tags = append(tags, version2.TracingTag{Name: tracingSamplingPriorityTag, Value: variable})
+	}
+
+	return &version2.Tracing{
+		Enable:        true,
+		OperationName: tracing.OperationName,
+		Tags:          tags,
+	}, splitClients
+}
+
+// generateRealIP returns the real IP configuration of the server. The fields of realIP that are set
+// override the corresponding ConfigMap keys.
+func generateRealIP(server *conf_v1.VirtualServerServer, cfgParams *ConfigParams) (setRealIPFrom []string, header string, recursive bool) {
+	setRealIPFrom = cfgParams.SetRealIPFrom
+	header = cfgParams.RealIPHeader
+	recursive = cfgParams.RealIPRecursive
+
+	if server == nil || server.RealIP == nil {
+		return setRealIPFrom, header, recursive
+	}
+
+	realIP := server.RealIP
+
+	if len(realIP.SetRealIPFrom) > 0 {
+		setRealIPFrom = realIP.SetRealIPFrom
+	}
+	if realIP.Header != "" {
+		header = realIP.Header
+	}
+	if realIP.Recursive != nil {
+		recursive = *realIP.Recursive
+	}
+
+	return setRealIPFrom, header, recursive
+}
+
+// xForwardedHeader is an X-Forwarded-* header passed to the upstreams.
+type xForwardedHeader struct {
+	name  string
+	value string
+}
+
+// generateXForwardedHeaders returns the X-Forwarded-* headers passed to the upstreams. The fields of xForwarded that
+// are set override the x-forwarded-* keys of the ConfigMap. The headers in the preserve mode are not set, so that
+// the headers of the client are passed as is. proto is the value of X-Forwarded-Proto in the overwrite mode.
+func generateXForwardedHeaders(xForwarded *conf_v1.XForwarded, cfgParams *ConfigParams, proto string) []xForwardedHeader {
+	forMode := cfgParams.XForwardedFor
+	protoMode := cfgParams.XForwardedProto
+	hostMode := cfgParams.XForwardedHost
+	portMode := cfgParams.XForwardedPort
+
+	if xForwarded != nil {
+		forMode = generateString(xForwarded.For, forMode)
+		protoMode = generateString(xForwarded.Proto, protoMode)
+		hostMode = generateString(xForwarded.Host, hostMode)
+		portMode = generateString(xForwarded.Port, portMode)
+	}
+
+	var headers []xForwardedHeader
+
+	addHeader := func(name string, mode string, value string) {
+		switch mode {
+		case "preserve":
+			return
+		case "remove":
+			value = `""`
+		case "append":
+			value = "$proxy_add_x_forwarded_for"
+		}
+		headers = append(headers, xForwardedHeader{name: name, value: value})
+	}
+
+	addHeader("X-Forwarded-For", generateString(forMode, "append"), "$remote_addr")
+	addHeader("X-Forwarded-Host", hostMode, "$host")
+	addHeader("X-Forwarded-Port", portMode, "$server_port")
+	addHeader("X-Forwarded-Proto", protoMode, proto)
+
+	return headers
+}
+
+func generateVirtualServerXForwardedHeaders(server *conf_v1.VirtualServerServer, cfgParams *ConfigParams,
+	tlsRedirect *version2.TLSRedirect) []version2.XForwardedHeader {
+	var xForwarded *conf_v1.XForwarded
+	if server != nil {
+		xForwarded = server.XForwarded
+	}
+
+	proto := "$scheme"
+	if tlsRedirect != nil {
+		proto = tlsRedirect.BasedOn
+	}
+
+	var headers []version2.XForwardedHeader
+	for _, h := range generateXForwardedHeaders(xForwarded, cfgParams, proto) {
+		headers = append(headers, version2.XForwardedHeader{Name: h.name, Value: h.value})
+	}
+
+	return headers
+}
+
 func generateIntFromPointer(n *int, defaultN int) int {
 	if n == nil {
 		return defaultN
@@ -539,66 +2251,409 @@ func generateLocation(path string, upstreamName string, upstream conf_v1.Upstrea
 		return generateLocationForReturnBlock(path, cfgParams.LocationSnippets, returnBlock, defaultType)
 	}
 
-	return generateLocationForProxying(path, upstreamName, upstream, cfgParams)
+	if action.Serve != nil {
+		return generateLocationForServe(path, cfgParams.LocationSnippets, action.Serve)
+	}
+
+	loc := generateLocationForProxying(path, upstreamName, upstream, cfgParams)
+	loc.LimitRate = action.LimitRate
+	loc.LimitRateAfter = action.LimitRateAfter
+	loc.ProxyCache = generateProxyCache(action.Cache)
+
+	return loc
+}
+
+// generateProxyCache generates the caching of a location. The zone name is the name from the action,
+// which generateCacheZones replaces with the name of the zone of the VirtualServer.
+func generateProxyCache(cache *conf_v1.ActionCache) *version2.ProxyCache {
+	if cache == nil {
+		return nil
+	}
+
+	var valid []string
+	for _, v := range cache.Valid {
+		var parts []string
+		for _, code := range v.Codes {
+			parts = append(parts, strconv.Itoa(code))
+		}
+		parts = append(parts, v.Time)
+		valid = append(valid, strings.Join(parts, " "))
+	}
+
+	return &version2.ProxyCache{
+		ZoneName: cache.ZoneName,
+		ZoneSize: generateString(cache.ZoneSize, "10m"),
+		Key:      cache.Key,
+		Valid:    valid,
+		Bypass:   cache.Bypass,
+		NoCache:  cache.NoCache,
+		MinUses:  cache.MinUses,
+		Purge:    generateProxyCachePurge(cache.Purge),
+	}
+}
+
+func generateProxyCachePurge(purge *conf_v1.ActionCachePurge) *version2.ProxyCachePurge {
+	if purge == nil {
+		return nil
+	}
+
+	return &version2.ProxyCachePurge{
+		Allow: purge.Allow,
+	}
+}
+
+// generateCachePurges generates the variables of the locations that purge the cache with the PURGE method.
+// A request purges the cache if its client is allowed to. Only NGINX Plus supports the purging.
+func generateCachePurges(locations []version2.Location, variableNamer *variableNamer, isPlus bool) ([]version2.Geo, []version2.Map) {
+	var geos []version2.Geo
+	var maps []version2.Map
+
+	index := 0
+	for i := range locations {
+		cache := locations[i].ProxyCache
+		if cache == nil || cache.Purge == nil {
+			continue
+		}
+
+		if !isPlus {
+			cache.Purge = nil
+			continue
+		}
+
+		allowed := variableNamer.GetNameForCachePurgeAllowedVariable(index)
+		geo := generateClientIPGeo(cache.Purge.Allow)
+		geo.Variable = allowed
+		geos = append(geos, geo)
+
+		cache.Purge.Variable = variableNamer.GetNameForCachePurgeVariable(index)
+		maps = append(maps, version2.Map{
+			Source:   "$request_method",
+			Variable: cache.Purge.Variable,
+			Parameters: []version2.Parameter{
+				{
+					Value:  "PURGE",
+					Result: allowed,
+				},
+				{
+					Value:  "default",
+					Result: "0",
+				},
+			},
+		})
+
+		index++
+	}
+
+	return geos, maps
+}
+
+// generateCacheZones generates the caches of the locations. The caches are named after the VirtualServer, so that
+// they are removed with it. The locations with the same zone name share the cache, whose size is the size of the first one.
+func generateCacheZones(locations []version2.Location, variableNamer *variableNamer) []version2.CacheZone {
+	var zones []version2.CacheZone
+	seen := make(map[string]bool)
+
+	for i := range locations {
+		cache := locations[i].ProxyCache
+		if cache == nil {
+			continue
+		}
+
+		cache.ZoneName = variableNamer.GetNameForCacheZone(cache.ZoneName)
+		if seen[cache.ZoneName] {
+			continue
+		}
+		seen[cache.ZoneName] = true
+
+		zones = append(zones, version2.CacheZone{
+			Name: cache.ZoneName,
+			Path: fmt.Sprintf("%s/%s", cacheRootPath, cache.ZoneName),
+			Size: cache.ZoneSize,
+		})
+	}
+
+	return zones
 }
 
 func generateLocationForProxying(path string, upstreamName string, upstream conf_v1.Upstream, cfgParams *ConfigParams) version2.Location {
-	return version2.Location{
+	loc := version2.Location{
 		Path:                     generatePath(path),
 		Snippets:                 cfgParams.LocationSnippets,
 		ProxyConnectTimeout:      generateString(upstream.ProxyConnectTimeout, cfgParams.ProxyConnectTimeout),
 		ProxyReadTimeout:         generateString(upstream.ProxyReadTimeout, cfgParams.ProxyReadTimeout),
 		ProxySendTimeout:         generateString(upstream.ProxySendTimeout, cfgParams.ProxySendTimeout),
 		ClientMaxBodySize:        generateString(upstream.ClientMaxBodySize, cfgParams.ClientMaxBodySize),
+		ClientBodyTimeout:        upstream.ClientBodyTimeout,
 		ProxyMaxTempFileSize:     cfgParams.ProxyMaxTempFileSize,
 		ProxyBuffering:           generateBool(upstream.ProxyBuffering, cfgParams.ProxyBuffering),
 		ProxyBuffers:             generateBuffers(upstream.ProxyBuffers, cfgParams.ProxyBuffers),
 		ProxyBufferSize:          generateString(upstream.ProxyBufferSize, cfgParams.ProxyBufferSize),
+		ProxyRequestBuffering:    generateRequestBuffering(upstream.ProxyRequestBuffering),
 		ProxyPass:                fmt.Sprintf("%v://%v", generateProxyPassProtocol(upstream.TLS.Enable), upstreamName),
 		ProxyNextUpstream:        generateString(upstream.ProxyNextUpstream, "error timeout"),
 		ProxyNextUpstreamTimeout: generateString(upstream.ProxyNextUpstreamTimeout, "0s"),
 		ProxyNextUpstreamTries:   upstream.ProxyNextUpstreamTries,
 		HasKeepalive:             upstreamHasKeepalive(upstream, cfgParams),
 	}
+
+	if upstream.Retries != nil {
+		addRetriesToLocation(upstream.Retries, &loc)
+	}
+
+	return loc
+}
+
+// addRetriesToLocation configures passing the requests of the location to the next server according to the retries
+// of the upstream, which replace the next-upstream fields.
+func addRetriesToLocation(retries *conf_v1.UpstreamRetries, location *version2.Location) {
+	location.ProxyNextUpstream = "error timeout"
+	if len(retries.Conditions) > 0 {
+		location.ProxyNextUpstream = strings.Join(retries.Conditions, " ")
+	}
+
+	location.ProxyNextUpstreamTries = retries.Tries
+	if location.ProxyNextUpstreamTries == 0 {
+		location.ProxyNextUpstreamTries = defaultRetriesTries
+	}
+
+	location.ProxyNextUpstreamTimeout = generateString(retries.Budget, "0s")
+	location.ProxyReadTimeout = generateString(retries.PerTryTimeout, location.ProxyReadTimeout)
+}
+
+// generateRequestBuffering returns the value of the proxy_request_buffering directive. If the upstream doesn't set
+// request buffering, the directive is not generated, so NGINX buffers the request bodies.
+func generateRequestBuffering(requestBuffering *bool) string {
+	if requestBuffering == nil {
+		return ""
+	}
+
+	if *requestBuffering {
+		return "on"
+	}
+
+	return "off"
+}
+
+// generateLocationForServe generates a location that serves the files of a directory or the content of a ConfigMap.
+// The content is added to the location by addServeContentToLocation.
+func generateLocationForServe(path string, locationSnippets []string, serve *conf_v1.ActionServe) version2.Location {
+	if serve.Directory != "" {
+		return version2.Location{
+			Path:       path,
+			Snippets:   locationSnippets,
+			StaticRoot: fmt.Sprintf("%s/%s", staticContentRootPath, serve.Directory),
+		}
+	}
+
+	return version2.Location{
+		Path:        path,
+		Snippets:    locationSnippets,
+		DefaultType: generateString(serve.Type, "text/plain"),
+		ServeContent: &version2.ServeContent{
+			ConfigMap: serve.ConfigMap,
+			Key:       serve.Key,
+		},
+	}
+}
+
+func (vsc *virtualServerConfigurator) addServeContentToLocations(owner runtime.Object, namespace string, configMaps map[string]*api_v1.ConfigMap,
+	variableNamer *variableNamer, locations []version2.Location) {
+	for i := range locations {
+		vsc.addServeContentToLocation(owner, namespace, configMaps, variableNamer, &locations[i])
+	}
+}
+
+// addServeContentToLocation makes the location return the content of the ConfigMap it serves.
+// If the ConfigMap or its key doesn't exist, the location returns 500.
+func (vsc *virtualServerConfigurator) addServeContentToLocation(owner runtime.Object, namespace string, configMaps map[string]*api_v1.ConfigMap,
+	variableNamer *variableNamer, location *version2.Location) {
+	if location.ServeContent == nil {
+		return
+	}
+
+	configMapKey := fmt.Sprintf("%s/%s", namespace, location.ServeContent.ConfigMap)
+	configMap, exists := configMaps[configMapKey]
+	if !exists {
+		vsc.addWarningf(owner, WarningCodeMissingConfigMap, WarningSeverityHigh,
+			"ConfigMap %s served by location %s doesn't exist", configMapKey, location.Path)
+		location.Return = &version2.Return{Code: 500}
+		return
+	}
+
+	content, exists := configMap.Data[location.ServeContent.Key]
+	if !exists {
+		vsc.addWarningf(owner, WarningCodeMissingConfigMap, WarningSeverityHigh,
+			"ConfigMap %s served by location %s doesn't have the key %s", configMapKey, location.Path, location.ServeContent.Key)
+		location.Return = &version2.Return{Code: 500}
+		return
+	}
+
+	location.Return = &version2.Return{
+		Code: 200,
+		Text: escapeServeContent(content, variableNamer.GetNameForDollarVariable()),
+	}
+}
+
+// escapeServeContent escapes the content for the quoted text of the return directive. NGINX can't escape '$',
+// so it is replaced with the variable generated by generateDollarGeo.
+func escapeServeContent(content string, dollarVariable string) string {
+	escaped := nginxQuotedStringEscaper.Replace(content)
+	return strings.ReplaceAll(escaped, "$", fmt.Sprintf("${%s}", strings.TrimPrefix(dollarVariable, "$")))
+}
+
+// generateDollarGeo generates the geo of the variable that evaluates to '$'.
+func generateDollarGeo(dollarVariable string) version2.Geo {
+	return version2.Geo{
+		Source:   "$remote_addr",
+		Variable: dollarVariable,
+		Parameters: []version2.Parameter{
+			{
+				Value:  "default",
+				Result: `"$"`,
+			},
+		},
+	}
+}
+
+func locationsServeContent(locations []version2.Location) bool {
+	for _, l := range locations {
+		if l.ServeContent != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func generateLocationForReturnBlock(path string, locationSnippets []string, r *version2.Return, defaultType string) version2.Location {
+	return version2.Location{
+		Path:        path,
+		Snippets:    locationSnippets,
+		DefaultType: defaultType,
+		Return:      r,
+	}
+}
+
+// splitWeightsAnnotation is the annotation of VirtualServers and VirtualServerRoutes that sets the weights of the splits
+// of their routes with dynamic weights by the paths of the routes, for example, "/coffee=80:20,/tea=50:50".
+const splitWeightsAnnotation = "nginx.com/split-weights"
+
+// splitWeightsZoneSize is the size of the keyval zone of the weights of the splits of a VirtualServer.
+const splitWeightsZoneSize = "64k"
+
+// parseSplitWeights parses the split weights annotation into the weights of the first split by the paths of the routes.
+// The invalid weights are ignored with a warning.
+func (vsc *virtualServerConfigurator) parseSplitWeights(owner runtime.Object, annotations map[string]string) map[string]int {
+	value, exists := annotations[splitWeightsAnnotation]
+	if !exists {
+		return nil
+	}
+
+	weights := make(map[string]int)
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		// the path of a regex route can include '='
+		i := strings.LastIndex(item, "=")
+		if i < 0 {
+			vsc.addWarningf(owner, WarningCodeIgnoredSetting, WarningSeverityMedium,
+				"Weights %q of annotation %s will be ignored: must be path=weight:weight", item, splitWeightsAnnotation)
+			continue
+		}
+
+		path, weightsValue := strings.TrimSpace(item[:i]), item[i+1:]
+		first, second, err := parseSplitWeightsValue(weightsValue)
+		if err != nil || first+second != 100 {
+			vsc.addWarningf(owner, WarningCodeIgnoredSetting, WarningSeverityMedium,
+				"Weights %q of annotation %s will be ignored: must be two weights in the range 0..100 with the sum of 100", item, splitWeightsAnnotation)
+			continue
+		}
+
+		weights[path] = first
+	}
+
+	return weights
+}
+
+func parseSplitWeightsValue(value string) (int, int, error) {
+	parts := strings.Split(value, ":")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("%q must include two weights", value)
+	}
+
+	var weights []int
+	for _, p := range parts {
+		weight, err := strconv.Atoi(strings.TrimSpace(p))
+		if err != nil || weight < 0 || weight > 100 {
+			return 0, 0, fmt.Errorf("%q must be in the range 0..100", p)
+		}
+		weights = append(weights, weight)
+	}
+
+	return weights[0], weights[1], nil
 }
 
-func generateLocationForReturnBlock(path string, locationSnippets []string, r *version2.Return, defaultType string) version2.Location {
-	return version2.Location{
-		Path:        path,
-		Snippets:    locationSnippets,
-		DefaultType: defaultType,
-		Return:      r,
+// generateSplitWeightsKeyValPairs generates the pairs that store the weight of the first split of a route with dynamic weights.
+// Without the weights in the annotation, the weights of the route are stored, so that removing the weights from
+// the annotation restores them.
+func generateSplitWeightsKeyValPairs(route conf_v1.Route, keyVals []version2.KeyVal, splitWeights map[string]int) []version2.KeyValPair {
+	var pairs []version2.KeyValPair
+
+	for _, kv := range keyVals {
+		weight, exists := splitWeights[route.Path]
+		if !exists {
+			weight = route.Splits[0].Weight
+		}
+
+		pairs = append(pairs, version2.KeyValPair{
+			ZoneName: kv.ZoneName,
+			Key:      kv.Key,
+			Value:    strconv.Itoa(weight),
+		})
 	}
+
+	return pairs
 }
 
 type routingCfg struct {
-	Maps                     []version2.Map
-	SplitClients             []version2.SplitClient
+	Maps         []version2.Map
+	Geos         []version2.Geo
+	SplitClients []version2.SplitClient
+	// DynamicSplitClients are the split clients for the weights of the splits set through the NGINX Plus API.
+	DynamicSplitClients      []version2.SplitClient
+	KeyVals                  []version2.KeyVal
 	Locations                []version2.Location
 	InternalRedirectLocation version2.InternalRedirectLocation
 }
 
-func generateSplits(splits []conf_v1.Split, upstreamNamer *upstreamNamer, crUpstreams map[string]conf_v1.Upstream, variableNamer *variableNamer, scIndex int, cfgParams *ConfigParams) (version2.SplitClient, []version2.Location) {
+func generateSplits(splits []conf_v1.Split, upstreamNamer *upstreamNamer, crUpstreams map[string]conf_v1.Upstream, variableNamer *variableNamer, scID string, cfgParams *ConfigParams) (version2.SplitClient, []version2.Location) {
 	var distributions []version2.Distribution
 
 	for i, s := range splits {
+		// the splits with the weight 0 only get their locations, so that they are ready to receive traffic
+		if s.Weight == 0 {
+			continue
+		}
+
 		d := version2.Distribution{
 			Weight: fmt.Sprintf("%d%%", s.Weight),
-			Value:  fmt.Sprintf("@splits_%d_split_%d", scIndex, i),
+			Value:  fmt.Sprintf("@splits_%s_split_%d", scID, i),
 		}
 		distributions = append(distributions, d)
 	}
 
 	splitClient := version2.SplitClient{
 		Source:        "$request_id",
-		Variable:      variableNamer.GetNameForSplitClientVariable(scIndex),
+		Variable:      variableNamer.GetNameForSplitClientVariable(scID),
 		Distributions: distributions,
 	}
 
 	var locations []version2.Location
 
 	for i, s := range splits {
-		path := fmt.Sprintf("@splits_%d_split_%d", scIndex, i)
+		path := fmt.Sprintf("@splits_%s_split_%d", scID, i)
 		upstreamName := upstreamNamer.GetNameForUpstream(s.Action.Pass)
 		upstream := crUpstreams[upstreamName]
 		loc := generateLocation(path, upstreamName, upstream, s.Action, cfgParams)
@@ -608,89 +2663,551 @@ func generateSplits(splits []conf_v1.Split, upstreamNamer *upstreamNamer, crUpst
 	return splitClient, locations
 }
 
-func generateDefaultSplitsConfig(route conf_v1.Route, upstreamNamer *upstreamNamer, crUpstreams map[string]conf_v1.Upstream, variableNamer *variableNamer, scIndex int, cfgParams *ConfigParams) routingCfg {
-	sc, locs := generateSplits(route.Splits, upstreamNamer, crUpstreams, variableNamer, scIndex, cfgParams)
+// addServerHeaders adds the headers to the responses of the server. NGINX only inherits add_header directives
+// into the locations without their own add_header directives, so the headers are also added to the other locations.
+func addServerHeaders(vsCfg *version2.VirtualServerConfig, headers []version2.AddHeader) {
+	if len(headers) == 0 {
+		return
+	}
+
+	vsCfg.Server.AddHeaders = append(vsCfg.Server.AddHeaders, headers...)
+
+	for i := range vsCfg.Server.Locations {
+		location := &vsCfg.Server.Locations[i]
+		if len(location.AddHeaders) > 0 || location.CORS != nil {
+			// the generator can share the headers between locations, so they are copied
+			location.AddHeaders = append(append([]version2.AddHeader{}, location.AddHeaders...), headers...)
+		}
+	}
+}
+
+// addSplitsCacheToLocations adds the headers that prevent shared caches from pinning all clients
+// to the response of a single split.
+func addSplitsCacheToLocations(splitsCache *conf_v1.SplitsCache, locations []version2.Location) {
+	if splitsCache == nil {
+		return
+	}
+
+	var hideHeaders []string
+	var addHeaders []version2.AddHeader
+
+	if splitsCache.NoStore {
+		// the Cache-Control header of the upstream is replaced, so that it doesn't contradict no-store
+		hideHeaders = append(hideHeaders, "Cache-Control")
+		addHeaders = append(addHeaders, version2.AddHeader{Name: "Cache-Control", Value: "no-store"})
+	}
+
+	if len(splitsCache.Vary) > 0 {
+		addHeaders = append(addHeaders, version2.AddHeader{Name: "Vary", Value: strings.Join(splitsCache.Vary, ", ")})
+	}
+
+	for i := range locations {
+		locations[i].ProxyHideHeaders = hideHeaders
+		locations[i].AddHeaders = addHeaders
+	}
+}
+
+// addIgnoreHeadersToLocations disables the processing of the given upstream response headers in the locations.
+func addIgnoreHeadersToLocations(headers []string, locations []version2.Location) {
+	for i := range locations {
+		locations[i].ProxyIgnoreHeaders = strings.Join(headers, " ")
+	}
+}
+
+// addClientBodyToLocation overrides the limits of the client request body of the location with the ones of the route.
+func addClientBodyToLocation(route conf_v1.Route, location *version2.Location) {
+	location.ClientMaxBodySize = generateString(route.ClientMaxBodySize, location.ClientMaxBodySize)
+	location.ClientBodyTimeout = generateString(route.ClientBodyTimeout, location.ClientBodyTimeout)
+}
+
+func addClientBodyToLocations(route conf_v1.Route, locations []version2.Location) {
+	for i := range locations {
+		addClientBodyToLocation(route, &locations[i])
+	}
+}
+
+// generateSplitsPersistence generates the map that evaluates to the split stored in the persistence cookie or,
+// if the request doesn't have the cookie, to the split chosen by the split client. The locations of the splits
+// set the cookie, so the next requests of the client go to the same split. The setIndex distinguishes
+// the sets of splits of a route with matches, which share the cookie.
+func generateSplitsPersistence(persistence *conf_v1.SplitsPersistence, path string, setIndex int, sc version2.SplitClient,
+	locations []version2.Location, variable string) version2.Map {
+	cookiePath := "/"
+	if strings.HasPrefix(path, "/") {
+		cookiePath = path
+	}
+
+	var maxAge string
+	if persistence.TTL != "" {
+		// the TTL is validated
+		seconds, _ := ParseTimeToSeconds(persistence.TTL)
+		maxAge = fmt.Sprintf("; Max-Age=%d", seconds)
+	}
+
+	var params []version2.Parameter
+	for i := range locations {
+		loc := &locations[i]
+		value := fmt.Sprintf("%d-%d", setIndex, i)
+
+		// the clients pinned to a split with the weight 0 are distributed again
+		if !splitClientHasDistribution(sc, loc.Path) {
+			continue
+		}
+
+		params = append(params, version2.Parameter{
+			Value:  fmt.Sprintf(`"%s"`, value),
+			Result: loc.Path,
+		})
+
+		// the headers can be shared by the locations, so they are copied
+		loc.AddHeaders = append(append([]version2.AddHeader{}, loc.AddHeaders...), version2.AddHeader{
+			Name:  "Set-Cookie",
+			Value: fmt.Sprintf("%s=%s; Path=%s%s", persistence.Cookie, value, cookiePath, maxAge),
+		})
+	}
+
+	params = append(params, version2.Parameter{
+		Value:  "default",
+		Result: sc.Variable,
+	})
+
+	return version2.Map{
+		Source:     fmt.Sprintf("$cookie_%s", persistence.Cookie),
+		Variable:   variable,
+		Parameters: params,
+	}
+}
+
+func splitClientHasDistribution(sc version2.SplitClient, value string) bool {
+	for _, d := range sc.Distributions {
+		if d.Value == value {
+			return true
+		}
+	}
+	return false
+}
+
+func generateDefaultSplitsConfig(route conf_v1.Route, upstreamNamer *upstreamNamer, crUpstreams map[string]conf_v1.Upstream, variableNamer *variableNamer, scID string, cfgParams *ConfigParams, dynamicWeights bool) routingCfg {
+	sc, locs := generateSplits(route.Splits, upstreamNamer, crUpstreams, variableNamer, scID, cfgParams)
+	addSplitsCacheToLocations(route.SplitsCache, locs)
 
-	splitClientVarName := variableNamer.GetNameForSplitClientVariable(scIndex)
+	splitClientVarName := variableNamer.GetNameForSplitClientVariable(scID)
 
 	irl := version2.InternalRedirectLocation{
 		Path:        route.Path,
 		Destination: splitClientVarName,
 	}
 
+	var maps []version2.Map
+	var dynamicSplitClients []version2.SplitClient
+	var keyVals []version2.KeyVal
+
+	// the persistence chooses from the splits chosen by the weights
+	persistenceSc := sc
+	if dynamicWeights {
+		var dynamicMap version2.Map
+		var keyVal version2.KeyVal
+		dynamicSplitClients, dynamicMap, keyVal = generateDynamicSplits(sc, locs, variableNamer, scID)
+		maps = append(maps, dynamicMap)
+		keyVals = append(keyVals, keyVal)
+		irl.Destination = dynamicMap.Variable
+
+		// any split can get traffic through the weights set dynamically
+		persistenceSc = version2.SplitClient{Variable: dynamicMap.Variable}
+		for _, loc := range locs {
+			persistenceSc.Distributions = append(persistenceSc.Distributions, version2.Distribution{Value: loc.Path})
+		}
+	}
+
+	if route.SplitsPersistence != nil {
+		persistenceMap := generateSplitsPersistence(route.SplitsPersistence, route.Path, 0, persistenceSc, locs,
+			variableNamer.GetNameForSplitsPersistenceVariable(scID))
+		maps = append(maps, persistenceMap)
+		irl.Destination = persistenceMap.Variable
+	}
+
 	return routingCfg{
+		Maps:                     maps,
 		SplitClients:             []version2.SplitClient{sc},
+		DynamicSplitClients:      dynamicSplitClients,
+		KeyVals:                  keyVals,
 		Locations:                locs,
 		InternalRedirectLocation: irl,
 	}
 }
 
+// generateDynamicSplits generates a split client for every weight of the first of two splits, and the map that chooses
+// the split client by the weight stored in the keyval zone, so that the weights can be changed through the NGINX Plus API
+// without a reload. Until the weight is stored, the map evaluates to the split client with the weights of the route.
+func generateDynamicSplits(sc version2.SplitClient, locations []version2.Location, variableNamer *variableNamer, scID string) ([]version2.SplitClient, version2.Map, version2.KeyVal) {
+	keyVal := version2.KeyVal{
+		Key:      variableNamer.GetKeyForSplitsWeight(scID),
+		Variable: variableNamer.GetNameForSplitsWeightVariable(scID),
+		ZoneName: variableNamer.GetNameForSplitWeightsZone(),
+	}
+
+	// the weights 0 and 100 choose a split without a split client
+	params := []version2.Parameter{
+		{
+			Value:  `"0"`,
+			Result: locations[1].Path,
+		},
+	}
+
+	var splitClients []version2.SplitClient
+	for weight := 1; weight < 100; weight++ {
+		variable := variableNamer.GetNameForSplitClientVariableWithWeight(scID, weight)
+		splitClients = append(splitClients, version2.SplitClient{
+			Source:   sc.Source,
+			Variable: variable,
+			Distributions: []version2.Distribution{
+				{
+					Weight: fmt.Sprintf("%d%%", weight),
+					Value:  locations[0].Path,
+				},
+				{
+					Weight: fmt.Sprintf("%d%%", 100-weight),
+					Value:  locations[1].Path,
+				},
+			},
+		})
+		params = append(params, version2.Parameter{
+			Value:  fmt.Sprintf(`"%d"`, weight),
+			Result: variable,
+		})
+	}
+
+	params = append(params,
+		version2.Parameter{
+			Value:  `"100"`,
+			Result: locations[0].Path,
+		},
+		version2.Parameter{
+			Value:  "default",
+			Result: sc.Variable,
+		})
+
+	dynamicMap := version2.Map{
+		Source:     keyVal.Variable,
+		Variable:   variableNamer.GetNameForDynamicSplitsVariable(scID),
+		Parameters: params,
+	}
+
+	return splitClients, dynamicMap, keyVal
+}
+
+// getSplitClientIDForMatches returns the ID of a split client of a route with matches. The split clients of the matches
+// with splits and the default splits are numbered in the order of the matches.
+func getSplitClientIDForMatches(routeID string, index int) string {
+	return fmt.Sprintf("%s_%d", routeID, index)
+}
+
+// conditionMapVariables stores the variables of the maps of the conditions of matches by the source and the parameters
+// of the maps, so that the routes with the same conditions share the maps instead of defining more variables.
+type conditionMapVariables map[string]string
+
+func getConditionMapKey(source string, params []version2.Parameter) string {
+	parts := []string{source}
+	for _, p := range params {
+		parts = append(parts, p.Value, p.Result)
+	}
+	// the parts can't include the NUL character, which NGINX doesn't allow in the config
+	return strings.Join(parts, "\x00")
+}
+
+// checkVariablesLimit returns an error if the maps, the geos and the split clients of the config define more variables than the limit.
+// The limit of 0 means no limit. The limit is hard: the config is not split to stay within the limit.
+func checkVariablesLimit(vsCfg *version2.VirtualServerConfig, limit int) error {
+	variables := len(vsCfg.Maps) + len(vsCfg.Geos) + len(vsCfg.SplitClients)
+	if limit > 0 && variables > limit {
+		return fmt.Errorf("the maps, the geos and the split clients of the config define %d variables, more than the limit of %d "+
+			"set by the virtualserver-max-variables ConfigMap key; reduce the number of the matches, the conditions or the splits",
+			variables, limit)
+	}
+	return nil
+}
+
+// nginxBuiltInVariables are the variables of NGINX and of the modules of the NGINX and NGINX Plus images.
+var nginxBuiltInVariables = map[string]bool{
+	"args":                       true,
+	"binary_remote_addr":         true,
+	"body_bytes_sent":            true,
+	"bytes_sent":                 true,
+	"connection":                 true,
+	"connection_requests":        true,
+	"connection_time":            true,
+	"connections_active":         true,
+	"connections_reading":        true,
+	"connections_waiting":        true,
+	"connections_writing":        true,
+	"content_length":             true,
+	"content_type":               true,
+	"document_root":              true,
+	"document_uri":               true,
+	"host":                       true,
+	"hostname":                   true,
+	"https":                      true,
+	"is_args":                    true,
+	"jwt_payload":                true,
+	"limit_conn_status":          true,
+	"limit_rate":                 true,
+	"limit_req_status":           true,
+	"msec":                       true,
+	"nginx_version":              true,
+	"pid":                        true,
+	"pipe":                       true,
+	"proxy_add_x_forwarded_for":  true,
+	"proxy_host":                 true,
+	"proxy_port":                 true,
+	"proxy_protocol_addr":        true,
+	"proxy_protocol_port":        true,
+	"proxy_protocol_server_addr": true,
+	"proxy_protocol_server_port": true,
+	"query_string":               true,
+	"realip_remote_addr":         true,
+	"realip_remote_port":         true,
+	"realpath_root":              true,
+	"remote_addr":                true,
+	"remote_port":                true,
+	"remote_user":                true,
+	"request":                    true,
+	"request_body":               true,
+	"request_body_file":          true,
+	"request_body_length":        true,
+	"request_completion":         true,
+	"request_filename":           true,
+	"request_id":                 true,
+	"request_length":             true,
+	"request_method":             true,
+	"request_time":               true,
+	"request_uri":                true,
+	"scheme":                     true,
+	"server_addr":                true,
+	"server_name":                true,
+	"server_port":                true,
+	"server_protocol":            true,
+	"session_time":               true,
+	"ssl_cipher":                 true,
+	"ssl_ciphers":                true,
+	"ssl_client_cert":            true,
+	"ssl_client_escaped_cert":    true,
+	"ssl_client_fingerprint":     true,
+	"ssl_client_i_dn":            true,
+	"ssl_client_raw_cert":        true,
+	"ssl_client_s_dn":            true,
+	"ssl_client_serial":          true,
+	"ssl_client_v_end":           true,
+	"ssl_client_v_remain":        true,
+	"ssl_client_v_start":         true,
+	"ssl_client_verify":          true,
+	"ssl_protocol":               true,
+	"ssl_server_name":            true,
+	"ssl_session_id":             true,
+	"ssl_session_reused":         true,
+	"status":                     true,
+	"tcpinfo_rcv_space":          true,
+	"tcpinfo_rtt":                true,
+	"tcpinfo_rttvar":             true,
+	"tcpinfo_snd_cwnd":           true,
+	"time_iso8601":               true,
+	"time_local":                 true,
+	"upstream_addr":              true,
+	"upstream_bytes_received":    true,
+	"upstream_bytes_sent":        true,
+	"upstream_cache_status":      true,
+	"upstream_connect_time":      true,
+	"upstream_header_time":       true,
+	"upstream_queue_time":        true,
+	"upstream_response_length":   true,
+	"upstream_response_time":     true,
+	"upstream_status":            true,
+	"uri":                        true,
+}
+
+// nginxBuiltInVariablePrefixes are the prefixes of the NGINX variables of the headers, the cookies and the arguments
+// of requests and responses, and of the claims of JWTs.
+var nginxBuiltInVariablePrefixes = []string{
+	"arg_", "cookie_", "http_", "jwt_claim_", "jwt_header_", "sent_http_", "sent_trailer_",
+	"upstream_cookie_", "upstream_http_", "upstream_trailer_",
+}
+
+// ingressControllerVariables are the variables that the templates define for the main config and for the policies.
+var ingressControllerVariables = map[string]bool{
+	"connection_upgrade":        true,
+	"default_connection_header": true,
+	"hsts_header_val":           true,
+	"redir_location":            true,
+	"redirect_base":             true,
+	"vs_connection_header":      true,
+}
+
+// ingressControllerVariablePrefixes are the prefixes of the variables that the OIDC policy defines.
+var ingressControllerVariablePrefixes = []string{"oidc_"}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkVariableCollisions returns an error if a variable defined by the maps, the geos, the split clients or the keyvals
+// of the config has the name of an NGINX variable, of a variable of the templates or of a variable defined by the snippets
+// of the config or by httpSnippets. NGINX either refuses to load such a config or silently uses one of the definitions.
+func checkVariableCollisions(vsCfg *version2.VirtualServerConfig, httpSnippets []string) error {
+	snippetVariables := make(map[string]bool)
+	addSnippetVariables := func(snippets []string) {
+		for _, v := range getSnippetVariables(snippets) {
+			snippetVariables[v] = true
+		}
+	}
+
+	addSnippetVariables(httpSnippets)
+	addSnippetVariables(vsCfg.Server.Snippets)
+	for _, l := range vsCfg.Server.Locations {
+		addSnippetVariables(l.Snippets)
+	}
+
+	var variables []string
+	for _, m := range vsCfg.Maps {
+		variables = append(variables, m.Variable)
+	}
+	for _, g := range vsCfg.Geos {
+		variables = append(variables, g.Variable)
+	}
+	for _, sc := range vsCfg.SplitClients {
+		variables = append(variables, sc.Variable)
+	}
+	for _, kv := range vsCfg.KeyVals {
+		variables = append(variables, kv.Variable)
+	}
+
+	for _, v := range variables {
+		name := strings.TrimPrefix(v, "$")
+
+		if nginxBuiltInVariables[name] || hasAnyPrefix(name, nginxBuiltInVariablePrefixes) {
+			return fmt.Errorf("the variable %s generated for the config collides with an NGINX variable", v)
+		}
+
+		if ingressControllerVariables[name] || hasAnyPrefix(name, ingressControllerVariablePrefixes) {
+			return fmt.Errorf("the variable %s generated for the config collides with a variable of the Ingress Controller", v)
+		}
+
+		if snippetVariables[name] {
+			return fmt.Errorf("the variable %s generated for the config is also defined by the snippets; rename the variable in the snippets", v)
+		}
+	}
+
+	return nil
+}
+
 func generateMatchesConfig(route conf_v1.Route, upstreamNamer *upstreamNamer, crUpstreams map[string]conf_v1.Upstream,
-	variableNamer *variableNamer, index int, scIndex int, cfgParams *ConfigParams) routingCfg {
+	variableNamer *variableNamer, conditionMaps conditionMapVariables, routeID string, cfgParams *ConfigParams) routingCfg {
+	var matches []conf_v1.Match
+	for _, i := range getMatchesEvaluationOrder(route.Matches) {
+		matches = append(matches, route.Matches[i])
+	}
+	route.Matches = matches
+
 	// Generate maps
 	var maps []version2.Map
+	var geos []version2.Geo
+	var sources []string
 
 	for i, m := range route.Matches {
-		for j, c := range m.Conditions {
-			source := getNameForSourceForMatchesRouteMapFromCondition(c)
-			variable := variableNamer.GetNameForVariableForMatchesRouteMap(index, i, j)
-			successfulResult := "1"
-			if j < len(m.Conditions)-1 {
-				successfulResult = variableNamer.GetNameForVariableForMatchesRouteMap(index, i, j+1)
+		var matchMaps []version2.Map
+		firstVariable := variableNamer.GetNameForVariableForMatchesRouteMap(routeID, i, 0)
+		successfulResult := "1"
+		failedResult := "0"
+
+		// the maps are generated from the last condition, so that the map of a condition references
+		// the reused map of the next condition: for all of the conditions, the next condition is evaluated
+		// if the condition matches; for any of the conditions, the next condition is evaluated if it doesn't
+		for j := len(m.Conditions) - 1; j >= 0; j-- {
+			source := getNameForSourceForMatchesRouteMapFromCondition(m.Conditions[j])
+			var params []version2.Parameter
+			if len(m.Conditions[j].Values) > 0 {
+				params = generateParametersForMatchesRouteMapWithValues(m.Conditions[j].Values, successfulResult, failedResult)
+			} else {
+				params = generateParametersForMatchesRouteMap(getMatchedValueFromCondition(m.Conditions[j]), successfulResult, failedResult)
+			}
+
+			// the geo of the addresses of the clients evaluates to 1 for the matching clients, which the map of the condition matches
+			if m.Conditions[j].ClientIP != nil {
+				geo := generateClientIPGeo(m.Conditions[j].ClientIP)
+				geoKey := getConditionMapKey("geo "+geo.Source, geo.Parameters)
+				geoVariable, exists := conditionMaps[geoKey]
+				if !exists {
+					geoVariable = variableNamer.GetNameForVariableForMatchesRouteGeo(routeID, i, j)
+					conditionMaps[geoKey] = geoVariable
+					geo.Variable = geoVariable
+					geos = append(geos, geo)
+				}
+
+				source = geoVariable
+				params = generateParametersForMatchesRouteMap("1", successfulResult, failedResult)
 			}
 
-			params := generateParametersForMatchesRouteMap(c.Value, successfulResult)
+			key := getConditionMapKey(source, params)
+			variable, exists := conditionMaps[key]
+			if !exists {
+				variable = variableNamer.GetNameForVariableForMatchesRouteMap(routeID, i, j)
+				conditionMaps[key] = variable
+
+				matchMap := version2.Map{
+					Source:     source,
+					Variable:   variable,
+					Parameters: params,
+				}
+				matchMaps = append([]version2.Map{matchMap}, matchMaps...)
+			}
 
-			matchMap := version2.Map{
-				Source:     source,
-				Variable:   variable,
-				Parameters: params,
+			if m.Any {
+				failedResult = variable
+			} else {
+				successfulResult = variable
 			}
-			maps = append(maps, matchMap)
+			firstVariable = variable
 		}
+
+		maps = append(maps, matchMaps...)
+		sources = append(sources, firstVariable)
 	}
 
 	scLocalIndex := 0
 
 	// Generate the main map
-	source := ""
-	var params []version2.Parameter
+	// with the persistence, the maps of the persistence choose the splits
+	getSplitsVariable := variableNamer.GetNameForSplitClientVariable
+	if route.SplitsPersistence != nil {
+		getSplitsVariable = variableNamer.GetNameForSplitsPersistenceVariable
+	}
+
+	var results []string
 	for i, m := range route.Matches {
-		source += variableNamer.GetNameForVariableForMatchesRouteMap(index, i, 0)
 
-		v := fmt.Sprintf("~^%s1", strings.Repeat("0", i))
-		r := fmt.Sprintf("@matches_%d_match_%d", index, i)
+		r := fmt.Sprintf("@matches_%s_match_%d", routeID, i)
 		if len(m.Splits) > 0 {
-			r = variableNamer.GetNameForSplitClientVariable(scIndex + scLocalIndex)
+			r = getSplitsVariable(getSplitClientIDForMatches(routeID, scLocalIndex))
 			scLocalIndex++
 		}
-
-		p := version2.Parameter{
-			Value:  v,
-			Result: r,
-		}
-		params = append(params, p)
+		results = append(results, r)
 	}
 
-	defaultResult := fmt.Sprintf("@matches_%d_default", index)
+	defaultResult := fmt.Sprintf("@matches_%s_default", routeID)
 	if len(route.Splits) > 0 {
-		defaultResult = variableNamer.GetNameForSplitClientVariable(scIndex + scLocalIndex)
-	}
-
-	defaultParam := version2.Parameter{
-		Value:  "default",
-		Result: defaultResult,
+		defaultResult = getSplitsVariable(getSplitClientIDForMatches(routeID, scLocalIndex))
 	}
-	params = append(params, defaultParam)
 
-	variable := variableNamer.GetNameForVariableForMatchesRouteMainMap(index)
+	variable := variableNamer.GetNameForVariableForMatchesRouteMainMap(routeID)
 
-	mainMap := version2.Map{
-		Source:     source,
-		Variable:   variable,
-		Parameters: params,
+	if len(sources) <= maxMatchesPerMap {
+		maps = append(maps, generateFirstMatchMap(sources, results, defaultResult, variable))
+	} else {
+		maps = append(maps, generateChunkedFirstMatchMaps(sources, results, defaultResult, variable, variableNamer, routeID)...)
 	}
-	maps = append(maps, mainMap)
 
 	// Generate locations for each match and split client
 	var locations []version2.Location
@@ -699,13 +3216,18 @@ func generateMatchesConfig(route conf_v1.Route, upstreamNamer *upstreamNamer, cr
 
 	for i, m := range route.Matches {
 		if len(m.Splits) > 0 {
-			sc, locs := generateSplits(m.Splits, upstreamNamer, crUpstreams, variableNamer, scIndex+scLocalIndex, cfgParams)
+			sc, locs := generateSplits(m.Splits, upstreamNamer, crUpstreams, variableNamer, getSplitClientIDForMatches(routeID, scLocalIndex), cfgParams)
+			addSplitsCacheToLocations(route.SplitsCache, locs)
+			if route.SplitsPersistence != nil {
+				maps = append(maps, generateSplitsPersistence(route.SplitsPersistence, route.Path, scLocalIndex, sc, locs,
+					variableNamer.GetNameForSplitsPersistenceVariable(getSplitClientIDForMatches(routeID, scLocalIndex))))
+			}
 			scLocalIndex++
 
 			splitClients = append(splitClients, sc)
 			locations = append(locations, locs...)
 		} else {
-			path := fmt.Sprintf("@matches_%d_match_%d", index, i)
+			path := fmt.Sprintf("@matches_%s_match_%d", routeID, i)
 			upstreamName := upstreamNamer.GetNameForUpstream(m.Action.Pass)
 			upstream := crUpstreams[upstreamName]
 			loc := generateLocation(path, upstreamName, upstream, m.Action, cfgParams)
@@ -715,11 +3237,16 @@ func generateMatchesConfig(route conf_v1.Route, upstreamNamer *upstreamNamer, cr
 
 	// Generate default splits or default action
 	if len(route.Splits) > 0 {
-		sc, locs := generateSplits(route.Splits, upstreamNamer, crUpstreams, variableNamer, scIndex+scLocalIndex, cfgParams)
+		sc, locs := generateSplits(route.Splits, upstreamNamer, crUpstreams, variableNamer, getSplitClientIDForMatches(routeID, scLocalIndex), cfgParams)
+		addSplitsCacheToLocations(route.SplitsCache, locs)
+		if route.SplitsPersistence != nil {
+			maps = append(maps, generateSplitsPersistence(route.SplitsPersistence, route.Path, scLocalIndex, sc, locs,
+				variableNamer.GetNameForSplitsPersistenceVariable(getSplitClientIDForMatches(routeID, scLocalIndex))))
+		}
 		splitClients = append(splitClients, sc)
 		locations = append(locations, locs...)
 	} else {
-		path := fmt.Sprintf("@matches_%d_default", index)
+		path := fmt.Sprintf("@matches_%s_default", routeID)
 		upstreamName := upstreamNamer.GetNameForUpstream(route.Action.Pass)
 		upstream := crUpstreams[upstreamName]
 		loc := generateLocation(path, upstreamName, upstream, route.Action, cfgParams)
@@ -734,12 +3261,100 @@ func generateMatchesConfig(route conf_v1.Route, upstreamNamer *upstreamNamer, cr
 
 	return routingCfg{
 		Maps:                     maps,
+		Geos:                     geos,
 		Locations:                locations,
 		InternalRedirectLocation: irl,
 		SplitClients:             splitClients,
 	}
 }
 
+// checkJWTClaimConditions warns about the conditions of the route on the claims of JWTs if no JWT policy applies to the route,
+// because the variables of the claims are empty without a validated JWT.
+func (vsc *virtualServerConfigurator) checkJWTClaimConditions(owner runtime.Object, route conf_v1.Route, hasJWTPolicy bool) {
+	if hasJWTPolicy {
+		return
+	}
+
+	for _, m := range route.Matches {
+		for _, c := range m.Conditions {
+			if strings.HasPrefix(c.Variable, "$jwt_claim_") {
+				vsc.addWarningf(owner, WarningCodeContradictorySetting, WarningSeverityMedium,
+					"Condition on %v of route %v is evaluated with an empty claim, because no JWT policy applies to the route", c.Variable, route.Path)
+			}
+		}
+	}
+}
+
+// getMatchesEvaluationOrder returns the indexes of the matches in the order NGINX evaluates them:
+// the matches with a higher priority come first, and the matches with the same priority keep their order.
+func getMatchesEvaluationOrder(matches []conf_v1.Match) []int {
+	order := make([]int, len(matches))
+	for i := range order {
+		order[i] = i
+	}
+
+	sort.SliceStable(order, func(i, j int) bool {
+		return matches[order[i]].Priority > matches[order[j]].Priority
+	})
+
+	return order
+}
+
+// getMatchConditionsKey returns a key that is the same for the matches with the same conditions in any order.
+func getMatchConditionsKey(match conf_v1.Match) string {
+	var parts []string
+	for _, c := range match.Conditions {
+		parts = append(parts, fmt.Sprintf("%#v", c))
+	}
+	sort.Strings(parts)
+
+	// a single condition matches the same way for all and for any of the conditions
+	if match.Any && len(match.Conditions) > 1 {
+		parts = append(parts, "any")
+	}
+
+	return strings.Join(parts, "\x00")
+}
+
+// checkOverlappingMatches reports the matches with the same conditions as a match evaluated before them,
+// because such matches are never used.
+func (vsc *virtualServerConfigurator) checkOverlappingMatches(owner runtime.Object, route conf_v1.Route) {
+	firstMatches := make(map[string]int)
+
+	for _, i := range getMatchesEvaluationOrder(route.Matches) {
+		key := getMatchConditionsKey(route.Matches[i])
+		if first, exists := firstMatches[key]; exists {
+			vsc.addWarningf(owner, WarningCodeOverlappingMatch, WarningSeverityLow,
+				"Match %d of route %v is never used, because it has the same conditions as match %d, which is evaluated first", i, route.Path, first)
+			continue
+		}
+		firstMatches[key] = i
+	}
+}
+
+// generateClientIPGeo generates the geo that evaluates to 1 for the clients with the addresses, and to 0 otherwise.
+// The address of a client is $remote_addr, so it respects the real IP configuration of the server.
+func generateClientIPGeo(addresses []string) version2.Geo {
+	params := []version2.Parameter{
+		{
+			Value:  "default",
+			Result: "0",
+		},
+	}
+
+	for _, a := range addresses {
+		params = append(params, version2.Parameter{
+			Value:  a,
+			Result: "1",
+		})
+	}
+
+	return version2.Geo{
+		Source:     "$remote_addr",
+		Parameters: params,
+	}
+}
+
 var specialMapParameters = map[string]bool{
 	"default":   true,
 	"hostnames": true,
@@ -747,6 +3362,25 @@ var specialMapParameters = map[string]bool{
 	"volatile":  true,
 }
 
+// getMatchedValueFromCondition returns the value of the condition for the map of the condition. The regular expression
+// of valueRegex is converted to a regex value escaped for the quoted string of the map, keeping its negation.
+func getMatchedValueFromCondition(condition conf_v1.Condition) string {
+	if condition.ValueRegex == "" {
+		return condition.Value
+	}
+
+	regex := condition.ValueRegex
+	negation := ""
+	if strings.HasPrefix(regex, "!") {
+		negation = "!"
+		regex = regex[1:]
+	}
+
+	return negation + "~" + nginxQuotedStringEscaper.Replace(regex)
+}
+
+var nginxQuotedStringEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
 func generateValueForMatchesRouteMap(matchedValue string) (value string, isNegative bool) {
 	if len(matchedValue) == 0 {
 		return `""`, false
@@ -764,13 +3398,84 @@ func generateValueForMatchesRouteMap(matchedValue string) (value string, isNegat
 	return fmt.Sprintf(`"%s"`, matchedValue), isNegative
 }
 
-func generateParametersForMatchesRouteMap(matchedValue string, successfulResult string) []version2.Parameter {
+// maxMatchesPerMap limits the number of matches handled by a single map, so that the source
+// and the regular expressions of the map stay short for routes with many matches.
+const maxMatchesPerMap = 16
+
+// generateFirstMatchMap generates a map that evaluates to the result of the first source that evaluates to 1.
+// Every source must evaluate to either 0 or 1.
+func generateFirstMatchMap(sources []string, results []string, defaultResult string, variable string) version2.Map {
+	var params []version2.Parameter
+	for i, r := range results {
+		params = append(params, version2.Parameter{
+			Value:  fmt.Sprintf("~^%s1", strings.Repeat("0", i)),
+			Result: r,
+		})
+	}
+
+	params = append(params, version2.Parameter{
+		Value:  "default",
+		Result: defaultResult,
+	})
+
+	return version2.Map{
+		Source:     strings.Join(sources, ""),
+		Variable:   variable,
+		Parameters: params,
+	}
+}
+
+// generateChunkedFirstMatchMaps generates maps that evaluate the variable like generateFirstMatchMap but split the sources
+// into chunks of maxMatchesPerMap. For every chunk, a map tells if any source of the chunk matches, and another map
+// evaluates to the result of the first matching source of the chunk. The main map selects the first matching chunk.
+func generateChunkedFirstMatchMaps(sources []string, results []string, defaultResult string, variable string,
+	variableNamer *variableNamer, matchesID string) []version2.Map {
+	var maps []version2.Map
+	var chunkSources []string
+	var chunkResults []string
+
+	for start := 0; start < len(sources); start += maxMatchesPerMap {
+		end := start + maxMatchesPerMap
+		if end > len(sources) {
+			end = len(sources)
+		}
+
+		chunkIndex := len(chunkSources)
+		chunkVariable := variableNamer.GetNameForVariableForMatchesRouteChunkMap(matchesID, chunkIndex)
+		chunkResultVariable := variableNamer.GetNameForVariableForMatchesRouteChunkResultMap(matchesID, chunkIndex)
+
+		chunkMap := version2.Map{
+			Source:   strings.Join(sources[start:end], ""),
+			Variable: chunkVariable,
+			Parameters: []version2.Parameter{
+				{
+					Value:  "~1",
+					Result: "1",
+				},
+				{
+					Value:  "default",
+					Result: "0",
+				},
+			},
+		}
+
+		maps = append(maps, chunkMap)
+		maps = append(maps, generateFirstMatchMap(sources[start:end], results[start:end], defaultResult, chunkResultVariable))
+
+		chunkSources = append(chunkSources, chunkVariable)
+		chunkResults = append(chunkResults, chunkResultVariable)
+	}
+
+	return append(maps, generateFirstMatchMap(chunkSources, chunkResults, defaultResult, variable))
+}
+
+func generateParametersForMatchesRouteMap(matchedValue string, successfulResult string, failedResult string) []version2.Parameter {
 	value, isNegative := generateValueForMatchesRouteMap(matchedValue)
 
 	valueResult := successfulResult
-	defaultResult := "0"
+	defaultResult := failedResult
 	if isNegative {
-		valueResult = "0"
+		valueResult = failedResult
 		defaultResult = successfulResult
 	}
 
@@ -788,6 +3493,25 @@ func generateParametersForMatchesRouteMap(matchedValue string, successfulResult
 	return params
 }
 
+// generateParametersForMatchesRouteMapWithValues generates the parameters of the map of a condition that matches
+// any of the values. The values are never negated.
+func generateParametersForMatchesRouteMapWithValues(values []string, successfulResult string, failedResult string) []version2.Parameter {
+	var params []version2.Parameter
+
+	for _, v := range values {
+		value, _ := generateValueForMatchesRouteMap(v)
+		params = append(params, version2.Parameter{
+			Value:  value,
+			Result: successfulResult,
+		})
+	}
+
+	return append(params, version2.Parameter{
+		Value:  "default",
+		Result: failedResult,
+	})
+}
+
 func getNameForSourceForMatchesRouteMapFromCondition(condition conf_v1.Condition) string {
 	if condition.Header != "" {
 		return fmt.Sprintf("$http_%s", strings.ReplaceAll(condition.Header, "-", "_"))
@@ -863,12 +3587,58 @@ func createEndpointsFromUpstream(upstream version2.Upstream) []string {
 	return endpoints
 }
 
-func createUpstreamsForPlus(virtualServerEx *VirtualServerEx, baseCfgParams *ConfigParams) []version2.Upstream {
+func createDrainedServersFromUpstream(upstream version2.Upstream) map[string]bool {
+	var drainedServers map[string]bool
+
+	for _, server := range upstream.Servers {
+		if server.Drain {
+			if drainedServers == nil {
+				drainedServers = make(map[string]bool)
+			}
+			drainedServers[server.Address] = true
+		}
+	}
+
+	return drainedServers
+}
+
+func createDownServersFromUpstream(upstream version2.Upstream) map[string]bool {
+	var downServers map[string]bool
+
+	for _, server := range upstream.Servers {
+		if server.Down {
+			if downServers == nil {
+				downServers = make(map[string]bool)
+			}
+			downServers[server.Address] = true
+		}
+	}
+
+	return downServers
+}
+
+func createBackupServersFromUpstream(upstream version2.Upstream) map[string]bool {
+	var backupServers map[string]bool
+
+	for _, server := range upstream.Servers {
+		if server.Backup {
+			if backupServers == nil {
+				backupServers = make(map[string]bool)
+			}
+			backupServers[server.Address] = true
+		}
+	}
+
+	return backupServers
+}
+
+func createUpstreamsForPlus(virtualServerEx *VirtualServerEx, baseCfgParams *ConfigParams, upstreamNamingScheme UpstreamNamingScheme) []version2.Upstream {
 	var upstreams []version2.Upstream
 
 	isPlus := true
-	upstreamNamer := newUpstreamNamerForVirtualServer(virtualServerEx.VirtualServer)
-	vsc := newVirtualServerConfigurator(baseCfgParams, isPlus, false)
+	isResolverConfigured := len(baseCfgParams.ResolverAddresses) != 0
+	upstreamNamer := newUpstreamNamerForVirtualServer(virtualServerEx.VirtualServer, upstreamNamingScheme)
+	vsc := newVirtualServerConfigurator(baseCfgParams, isPlus, isResolverConfigured)
 
 	for _, u := range virtualServerEx.VirtualServer.Spec.Upstreams {
 		isExternalNameSvc := virtualServerEx.ExternalNameSvcs[GenerateExternalNameSvcKey(virtualServerEx.VirtualServer.Namespace, u.Service)]
@@ -877,18 +3647,24 @@ func createUpstreamsForPlus(virtualServerEx *VirtualServerEx, baseCfgParams *Con
 			continue
 		}
 
+		if vsc.isUpstreamResolved(u) {
+			glog.V(3).Infof("Upstream %s resolves the name of service %s, skipping NGINX Plus endpoints update via API", u.Name, u.Service)
+			continue
+		}
+
 		upstreamName := upstreamNamer.GetNameForUpstream(u.Name)
 		upstreamNamespace := virtualServerEx.VirtualServer.Namespace
 
-		endpointsKey := GenerateEndpointsKey(upstreamNamespace, u.Service, u.Subselector, u.Port)
+		endpointsKey := GenerateEndpointsKey(upstreamNamespace, u.Service, u.Subselector, u.Port, u.PortName)
 		endpoints := virtualServerEx.Endpoints[endpointsKey]
 
-		ups := vsc.generateUpstream(virtualServerEx.VirtualServer, upstreamName, u, isExternalNameSvc, endpoints)
+		ups := vsc.generateUpstream(virtualServerEx.VirtualServer, upstreamName, u, isExternalNameSvc, endpoints, virtualServerEx.DrainedEndpoints, virtualServerEx.TerminatingEndpoints,
+			virtualServerEx.RemoteZoneEndpoints)
 		upstreams = append(upstreams, ups)
 	}
 
 	for _, vsr := range virtualServerEx.VirtualServerRoutes {
-		upstreamNamer = newUpstreamNamerForVirtualServerRoute(virtualServerEx.VirtualServer, vsr)
+		upstreamNamer = newUpstreamNamerForVirtualServerRoute(virtualServerEx.VirtualServer, vsr, upstreamNamingScheme)
 		for _, u := range vsr.Spec.Upstreams {
 			isExternalNameSvc := virtualServerEx.ExternalNameSvcs[GenerateExternalNameSvcKey(vsr.Namespace, u.Service)]
 			if isExternalNameSvc {
@@ -896,13 +3672,19 @@ func createUpstreamsForPlus(virtualServerEx *VirtualServerEx, baseCfgParams *Con
 				continue
 			}
 
+			if vsc.isUpstreamResolved(u) {
+				glog.V(3).Infof("Upstream %s resolves the name of service %s, skipping NGINX Plus endpoints update via API", u.Name, u.Service)
+				continue
+			}
+
 			upstreamName := upstreamNamer.GetNameForUpstream(u.Name)
 			upstreamNamespace := vsr.Namespace
 
-			endpointsKey := GenerateEndpointsKey(upstreamNamespace, u.Service, u.Subselector, u.Port)
+			endpointsKey := GenerateEndpointsKey(upstreamNamespace, u.Service, u.Subselector, u.Port, u.PortName)
 			endpoints := virtualServerEx.Endpoints[endpointsKey]
 
-			ups := vsc.generateUpstream(vsr, upstreamName, u, isExternalNameSvc, endpoints)
+			ups := vsc.generateUpstream(vsr, upstreamName, u, isExternalNameSvc, endpoints, virtualServerEx.DrainedEndpoints, virtualServerEx.TerminatingEndpoints,
+				virtualServerEx.RemoteZoneEndpoints)
 			upstreams = append(upstreams, ups)
 		}
 	}
@@ -915,10 +3697,13 @@ func createUpstreamServersConfigForPlus(upstream version2.Upstream) nginx.Server
 		return nginx.ServerConfig{}
 	}
 	return nginx.ServerConfig{
-		MaxFails:    upstream.MaxFails,
-		FailTimeout: upstream.FailTimeout,
-		MaxConns:    upstream.MaxConns,
-		SlowStart:   upstream.SlowStart,
+		MaxFails:       upstream.MaxFails,
+		FailTimeout:    upstream.FailTimeout,
+		MaxConns:       upstream.MaxConns,
+		SlowStart:      upstream.SlowStart,
+		DrainedServers: createDrainedServersFromUpstream(upstream),
+		DownServers:    createDownServersFromUpstream(upstream),
+		BackupServers:  createBackupServersFromUpstream(upstream),
 	}
 }
 
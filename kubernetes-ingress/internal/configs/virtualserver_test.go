@@ -3,14 +3,23 @@ package configs
 import (
 	"fmt"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/nginxinc/kubernetes-ingress/internal/configs/version2"
 	"github.com/nginxinc/kubernetes-ingress/internal/nginx"
 	conf_v1 "github.com/nginxinc/kubernetes-ingress/pkg/apis/configuration/v1"
+	api_v1 "k8s.io/api/core/v1"
 	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+var defaultXForwardedHeaders = []version2.XForwardedHeader{
+	{Name: "X-Forwarded-For", Value: "$proxy_add_x_forwarded_for"},
+	{Name: "X-Forwarded-Host", Value: "$host"},
+	{Name: "X-Forwarded-Port", Value: "$server_port"},
+	{Name: "X-Forwarded-Proto", Value: "$scheme"},
+}
+
 func TestVirtualServerExString(t *testing.T) {
 	tests := []struct {
 		input    *VirtualServerEx
@@ -52,6 +61,7 @@ func TestGenerateEndpointsKey(t *testing.T) {
 
 	tests := []struct {
 		subselector map[string]string
+		portName    string
 		expected    string
 	}{
 		{
@@ -62,10 +72,20 @@ func TestGenerateEndpointsKey(t *testing.T) {
 			subselector: map[string]string{"version": "v1"},
 			expected:    "default/test_version=v1:80",
 		},
+		{
+			subselector: nil,
+			portName:    "http",
+			expected:    "default/test:http",
+		},
+		{
+			subselector: map[string]string{"version": "v1"},
+			portName:    "http",
+			expected:    "default/test_version=v1:http",
+		},
 	}
 
 	for _, test := range tests {
-		result := GenerateEndpointsKey(serviceNamespace, serviceName, test.subselector, port)
+		result := GenerateEndpointsKey(serviceNamespace, serviceName, test.subselector, port, test.portName)
 		if result != test.expected {
 			t.Errorf("GenerateEndpointsKey() returned %q but expected %q", result, test.expected)
 		}
@@ -80,7 +100,7 @@ func TestUpstreamNamerForVirtualServer(t *testing.T) {
 			Namespace: "default",
 		},
 	}
-	upstreamNamer := newUpstreamNamerForVirtualServer(&virtualServer)
+	upstreamNamer := newUpstreamNamerForVirtualServer(&virtualServer, UpstreamNamingScheme{})
 	upstream := "test"
 
 	expected := "vs_default_cafe_test"
@@ -104,7 +124,7 @@ func TestUpstreamNamerForVirtualServerRoute(t *testing.T) {
 			Namespace: "default",
 		},
 	}
-	upstreamNamer := newUpstreamNamerForVirtualServerRoute(&virtualServer, &virtualServerRoute)
+	upstreamNamer := newUpstreamNamerForVirtualServerRoute(&virtualServer, &virtualServerRoute, UpstreamNamingScheme{})
 	upstream := "test"
 
 	expected := "vs_default_cafe_vsr_default_coffee_test"
@@ -115,6 +135,130 @@ func TestUpstreamNamerForVirtualServerRoute(t *testing.T) {
 	}
 }
 
+func TestUpstreamNamerForVirtualServerRouteWithLongNames(t *testing.T) {
+	longName := strings.Repeat("a", 253)
+	virtualServer := conf_v1.VirtualServer{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      longName,
+			Namespace: strings.Repeat("b", 63),
+		},
+	}
+	virtualServerRoute := conf_v1.VirtualServerRoute{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      longName,
+			Namespace: strings.Repeat("c", 63),
+		},
+	}
+	upstreamNamer := newUpstreamNamerForVirtualServerRoute(&virtualServer, &virtualServerRoute, UpstreamNamingScheme{})
+
+	result := upstreamNamer.GetNameForUpstream(strings.Repeat("d", 63))
+	if len(result) > 255 {
+		t.Errorf("GetNameForUpstream() returned a name of %d characters but expected at most 255", len(result))
+	}
+	if result != upstreamNamer.GetNameForUpstream(strings.Repeat("d", 63)) {
+		t.Errorf("GetNameForUpstream() returned different names for the same upstream")
+	}
+}
+
+func TestUpstreamNamerWithNamingScheme(t *testing.T) {
+	virtualServer := conf_v1.VirtualServer{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "cafe",
+			Namespace: "default",
+		},
+	}
+	virtualServerRoute := conf_v1.VirtualServerRoute{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "coffee",
+			Namespace: "coffee-ns",
+		},
+	}
+
+	tests := []struct {
+		scheme      UpstreamNamingScheme
+		expectedVS  string
+		expectedVSR string
+	}{
+		{
+			scheme:      UpstreamNamingScheme{Prefix: "ingress"},
+			expectedVS:  "ingress_default_cafe_tea",
+			expectedVSR: "ingress_default_cafe_vsr_coffee-ns_coffee_tea",
+		},
+		{
+			scheme:      UpstreamNamingScheme{ExcludeNamespace: true},
+			expectedVS:  "vs_cafe_tea",
+			expectedVSR: "vs_cafe_vsr_coffee_tea",
+		},
+		{
+			scheme:      UpstreamNamingScheme{Prefix: "k8s-upstream", ExcludeNamespace: true},
+			expectedVS:  "k8s-upstream_cafe_tea",
+			expectedVSR: "k8s-upstream_cafe_vsr_coffee_tea",
+		},
+	}
+
+	for _, test := range tests {
+		result := newUpstreamNamerForVirtualServer(&virtualServer, test.scheme).GetNameForUpstream("tea")
+		if result != test.expectedVS {
+			t.Errorf("GetNameForUpstream() returned %q for the scheme %+v but expected %q", result, test.scheme, test.expectedVS)
+		}
+
+		result = newUpstreamNamerForVirtualServerRoute(&virtualServer, &virtualServerRoute, test.scheme).GetNameForUpstream("tea")
+		if result != test.expectedVSR {
+			t.Errorf("GetNameForUpstream() returned %q for the scheme %+v but expected %q", result, test.scheme, test.expectedVSR)
+		}
+	}
+}
+
+func TestShortenNameComponent(t *testing.T) {
+	short := strings.Repeat("a", maxNameComponentLength)
+	if result := shortenNameComponent(short); result != short {
+		t.Errorf("shortenNameComponent(%q) returned %q but expected the component unchanged", short, result)
+	}
+
+	longA := strings.Repeat("a", 100) + "-x"
+	longB := strings.Repeat("a", 100) + "-y"
+
+	resultA := shortenNameComponent(longA)
+	resultB := shortenNameComponent(longB)
+
+	if len(resultA) != maxNameComponentLength {
+		t.Errorf("shortenNameComponent() returned %q of %d characters but expected %d", resultA, len(resultA), maxNameComponentLength)
+	}
+	if !strings.HasPrefix(resultA, longA[:maxNameComponentLength-nameComponentHashLength-1]) {
+		t.Errorf("shortenNameComponent() returned %q which doesn't start with the beginning of the component", resultA)
+	}
+	if strings.Contains(resultA, "_") {
+		t.Errorf("shortenNameComponent() returned %q which includes an underscore", resultA)
+	}
+	if resultA == resultB {
+		t.Errorf("shortenNameComponent() returned %q for different components", resultA)
+	}
+	if resultA != shortenNameComponent(longA) {
+		t.Errorf("shortenNameComponent() returned different results for the same component")
+	}
+}
+
+func TestGenerateRouteID(t *testing.T) {
+	routeIDs := make(map[string]bool)
+
+	coffeeID := generateRouteID("/coffee", routeIDs)
+	if coffeeID != "67dd099d" {
+		t.Errorf("generateRouteID() returned %q but expected %q", coffeeID, "67dd099d")
+	}
+
+	// the ID doesn't depend on the other routes
+	teaID := generateRouteID("/tea", make(map[string]bool))
+	if result := generateRouteID("/tea", routeIDs); result != teaID {
+		t.Errorf("generateRouteID() returned %q but expected %q", result, teaID)
+	}
+
+	// a colliding hash gets another ID
+	collidingID := generateRouteID("/coffee", routeIDs)
+	if collidingID == coffeeID || len(collidingID) != routeIDLength {
+		t.Errorf("generateRouteID() returned %q for a colliding hash of %q", collidingID, coffeeID)
+	}
+}
+
 func TestVariableNamerSafeNsName(t *testing.T) {
 	virtualServer := conf_v1.VirtualServer{
 		ObjectMeta: meta_v1.ObjectMeta{
@@ -142,36 +286,60 @@ func TestVariableNamer(t *testing.T) {
 	variableNamer := newVariableNamer(&virtualServer)
 
 	// GetNameForSplitClientVariable()
-	index := 0
+	id := "9f86d081"
 
-	expected := "$vs_default_cafe_splits_0"
+	expected := "$vs_default_cafe_splits_9f86d081"
 
-	result := variableNamer.GetNameForSplitClientVariable(index)
+	result := variableNamer.GetNameForSplitClientVariable(id)
 	if result != expected {
 		t.Errorf("GetNameForSplitClientVariable() returned %q but expected %q", result, expected)
 	}
 
 	// GetNameForVariableForMatchesRouteMap()
-	matchesIndex := 1
+	matchesID := "60303ae2"
 	matchIndex := 2
 	conditionIndex := 3
 
-	expected = "$vs_default_cafe_matches_1_match_2_cond_3"
+	expected = "$vs_default_cafe_matches_60303ae2_match_2_cond_3"
 
-	result = variableNamer.GetNameForVariableForMatchesRouteMap(matchesIndex, matchIndex, conditionIndex)
+	result = variableNamer.GetNameForVariableForMatchesRouteMap(matchesID, matchIndex, conditionIndex)
 	if result != expected {
 		t.Errorf("GetNameForVariableForMatchesRouteMap() returned %q but expected %q", result, expected)
 	}
 
 	// GetNameForVariableForMatchesRouteMainMap()
-	matchesIndex = 2
-
-	expected = "$vs_default_cafe_matches_2"
+	expected = "$vs_default_cafe_matches_60303ae2"
 
-	result = variableNamer.GetNameForVariableForMatchesRouteMainMap(matchesIndex)
+	result = variableNamer.GetNameForVariableForMatchesRouteMainMap(matchesID)
 	if result != expected {
 		t.Errorf("GetNameForVariableForMatchesRouteMainMap() returned %q but expected %q", result, expected)
 	}
+
+	// GetNameForVariableForMatchesRouteChunkMap()
+	chunkIndex := 3
+
+	expected = "$vs_default_cafe_matches_60303ae2_chunk_3"
+
+	result = variableNamer.GetNameForVariableForMatchesRouteChunkMap(matchesID, chunkIndex)
+	if result != expected {
+		t.Errorf("GetNameForVariableForMatchesRouteChunkMap() returned %q but expected %q", result, expected)
+	}
+
+	// GetNameForVariableForMatchesRouteChunkResultMap()
+	expected = "$vs_default_cafe_matches_60303ae2_chunk_3_result"
+
+	result = variableNamer.GetNameForVariableForMatchesRouteChunkResultMap(matchesID, chunkIndex)
+	if result != expected {
+		t.Errorf("GetNameForVariableForMatchesRouteChunkResultMap() returned %q but expected %q", result, expected)
+	}
+
+	// GetNameForRateLimitZone()
+	expected = "pol_rl_policy_ns_rate_limit_default_cafe"
+
+	result = variableNamer.GetNameForRateLimitZone("policy-ns", "rate-limit")
+	if result != expected {
+		t.Errorf("GetNameForRateLimitZone() returned %q but expected %q", result, expected)
+	}
 }
 
 func TestGenerateVirtualServerConfig(t *testing.T) {
@@ -183,6 +351,9 @@ func TestGenerateVirtualServerConfig(t *testing.T) {
 			},
 			Spec: conf_v1.VirtualServerSpec{
 				Host: "cafe.example.com",
+				Listener: &conf_v1.VirtualServerListener{
+					HTTP: 8080,
+				},
 				Upstreams: []conf_v1.Upstream{
 					{
 						Name:    "tea",
@@ -337,14 +508,16 @@ func TestGenerateVirtualServerConfig(t *testing.T) {
 			},
 		},
 		Server: version2.Server{
-			ServerName:      "cafe.example.com",
-			StatusZone:      "cafe.example.com",
-			ProxyProtocol:   true,
-			ServerTokens:    "off",
-			SetRealIPFrom:   []string{"0.0.0.0/0"},
-			RealIPHeader:    "X-Real-IP",
-			RealIPRecursive: true,
-			Snippets:        []string{"# server snippet"},
+			ServerName:        "cafe.example.com",
+			StatusZone:        "cafe.example.com",
+			ProxyProtocol:     true,
+			HTTPPort:          8080,
+			ServerTokens:      "off",
+			SetRealIPFrom:     []string{"0.0.0.0/0"},
+			RealIPHeader:      "X-Real-IP",
+			RealIPRecursive:   true,
+			XForwardedHeaders: defaultXForwardedHeaders,
+			Snippets:          []string{"# server snippet"},
 			Locations: []version2.Location{
 				{
 					Path:                     "/tea",
@@ -386,7 +559,7 @@ func TestGenerateVirtualServerConfig(t *testing.T) {
 	isResolverConfigured := false
 	tlsPemFileName := ""
 	vsc := newVirtualServerConfigurator(&baseCfgParams, isPlus, isResolverConfigured)
-	result, warnings := vsc.GenerateVirtualServerConfig(&virtualServerEx, tlsPemFileName)
+	result, warnings := vsc.GenerateVirtualServerConfig(&virtualServerEx, tlsPemFileName, policyOptions{})
 	if !reflect.DeepEqual(result, expected) {
 		t.Errorf("GenerateVirtualServerConfig returned \n%v but expected \n%v", result, expected)
 	}
@@ -539,70 +712,71 @@ func TestGenerateVirtualServerConfigForVirtualServerWithSplits(t *testing.T) {
 		SplitClients: []version2.SplitClient{
 			{
 				Source:   "$request_id",
-				Variable: "$vs_default_cafe_splits_0",
+				Variable: "$vs_default_cafe_splits_85c724e5",
 				Distributions: []version2.Distribution{
 					{
 						Weight: "90%",
-						Value:  "@splits_0_split_0",
+						Value:  "@splits_85c724e5_split_0",
 					},
 					{
 						Weight: "10%",
-						Value:  "@splits_0_split_1",
+						Value:  "@splits_85c724e5_split_1",
 					},
 				},
 			},
 			{
 				Source:   "$request_id",
-				Variable: "$vs_default_cafe_splits_1",
+				Variable: "$vs_default_cafe_splits_67dd099d",
 				Distributions: []version2.Distribution{
 					{
 						Weight: "40%",
-						Value:  "@splits_1_split_0",
+						Value:  "@splits_67dd099d_split_0",
 					},
 					{
 						Weight: "60%",
-						Value:  "@splits_1_split_1",
+						Value:  "@splits_67dd099d_split_1",
 					},
 				},
 			},
 		},
 		Server: version2.Server{
-			ServerName: "cafe.example.com",
-			StatusZone: "cafe.example.com",
+			ServerName:        "cafe.example.com",
+			StatusZone:        "cafe.example.com",
+			XForwardedHeaders: defaultXForwardedHeaders,
 			InternalRedirectLocations: []version2.InternalRedirectLocation{
 				{
 					Path:        "/tea",
-					Destination: "$vs_default_cafe_splits_0",
+					Destination: "$vs_default_cafe_splits_85c724e5",
 				},
 				{
 					Path:        "/coffee",
-					Destination: "$vs_default_cafe_splits_1",
+					Destination: "$vs_default_cafe_splits_67dd099d",
 				},
 			},
 			Locations: []version2.Location{
 				{
-					Path:                     "@splits_0_split_0",
+					Path:                     "@splits_85c724e5_split_0",
 					ProxyPass:                "http://vs_default_cafe_tea-v1",
 					ProxyNextUpstream:        "error timeout",
 					ProxyNextUpstreamTimeout: "0s",
 					ProxyNextUpstreamTries:   0,
 				},
 				{
-					Path:                     "@splits_0_split_1",
+					Path:                     "@splits_85c724e5_split_1",
 					ProxyPass:                "http://vs_default_cafe_tea-v2",
 					ProxyNextUpstream:        "error timeout",
 					ProxyNextUpstreamTimeout: "0s",
 					ProxyNextUpstreamTries:   0,
 				},
 				{
-					Path:                     "@splits_1_split_0",
+					Path:                     "@splits_67dd099d_split_0",
 					ProxyPass:                "http://vs_default_cafe_vsr_default_coffee_coffee-v1",
 					ProxyNextUpstream:        "error timeout",
 					ProxyNextUpstreamTimeout: "0s",
 					ProxyNextUpstreamTries:   0,
 				},
 				{
-					Path:                     "@splits_1_split_1",
+					Path:                     "@splits_67dd099d_split_1",
 					ProxyPass:                "http://vs_default_cafe_vsr_default_coffee_coffee-v2",
 					ProxyNextUpstream:        "error timeout",
 					ProxyNextUpstreamTimeout: "0s",
@@ -616,7 +790,7 @@ func TestGenerateVirtualServerConfigForVirtualServerWithSplits(t *testing.T) {
 	isResolverConfigured := false
 	tlsPemFileName := ""
 	vsc := newVirtualServerConfigurator(&baseCfgParams, isPlus, isResolverConfigured)
-	result, warnings := vsc.GenerateVirtualServerConfig(&virtualServerEx, tlsPemFileName)
+	result, warnings := vsc.GenerateVirtualServerConfig(&virtualServerEx, tlsPemFileName, policyOptions{})
 	if !reflect.DeepEqual(result, expected) {
 		t.Errorf("GenerateVirtualServerConfig returned \n%v but expected \n%v", result, expected)
 	}
@@ -774,7 +948,7 @@ func TestGenerateVirtualServerConfigForVirtualServerWithMatches(t *testing.T) {
 		Maps: []version2.Map{
 			{
 				Source:   "$http_x_version",
-				Variable: "$vs_default_cafe_matches_0_match_0_cond_0",
+				Variable: "$vs_default_cafe_matches_85c724e5_match_0_cond_0",
 				Parameters: []version2.Parameter{
 					{
 						Value:  `"v2"`,
@@ -787,22 +961,22 @@ func TestGenerateVirtualServerConfigForVirtualServerWithMatches(t *testing.T) {
 				},
 			},
 			{
-				Source:   "$vs_default_cafe_matches_0_match_0_cond_0",
-				Variable: "$vs_default_cafe_matches_0",
+				Source:   "$vs_default_cafe_matches_85c724e5_match_0_cond_0",
+				Variable: "$vs_default_cafe_matches_85c724e5",
 				Parameters: []version2.Parameter{
 					{
 						Value:  "~^1",
-						Result: "@matches_0_match_0",
+						Result: "@matches_85c724e5_match_0",
 					},
 					{
 						Value:  "default",
-						Result: "@matches_0_default",
+						Result: "@matches_85c724e5_default",
 					},
 				},
 			},
 			{
 				Source:   "$arg_version",
-				Variable: "$vs_default_cafe_matches_1_match_0_cond_0",
+				Variable: "$vs_default_cafe_matches_67dd099d_match_0_cond_0",
 				Parameters: []version2.Parameter{
 					{
 						Value:  `"v2"`,
@@ -815,57 +989,58 @@ func TestGenerateVirtualServerConfigForVirtualServerWithMatches(t *testing.T) {
 				},
 			},
 			{
-				Source:   "$vs_default_cafe_matches_1_match_0_cond_0",
-				Variable: "$vs_default_cafe_matches_1",
+				Source:   "$vs_default_cafe_matches_67dd099d_match_0_cond_0",
+				Variable: "$vs_default_cafe_matches_67dd099d",
 				Parameters: []version2.Parameter{
 					{
 						Value:  "~^1",
-						Result: "@matches_1_match_0",
+						Result: "@matches_67dd099d_match_0",
 					},
 					{
 						Value:  "default",
-						Result: "@matches_1_default",
+						Result: "@matches_67dd099d_default",
 					},
 				},
 			},
 		},
 		Server: version2.Server{
-			ServerName: "cafe.example.com",
-			StatusZone: "cafe.example.com",
+			ServerName:        "cafe.example.com",
+			StatusZone:        "cafe.example.com",
+			XForwardedHeaders: defaultXForwardedHeaders,
 			InternalRedirectLocations: []version2.InternalRedirectLocation{
 				{
 					Path:        "/tea",
-					Destination: "$vs_default_cafe_matches_0",
+					Destination: "$vs_default_cafe_matches_85c724e5",
 				},
 				{
 					Path:        "/coffee",
-					Destination: "$vs_default_cafe_matches_1",
+					Destination: "$vs_default_cafe_matches_67dd099d",
 				},
 			},
 			Locations: []version2.Location{
 				{
-					Path:                     "@matches_0_match_0",
+					Path:                     "@matches_85c724e5_match_0",
 					ProxyPass:                "http://vs_default_cafe_tea-v2",
 					ProxyNextUpstream:        "error timeout",
 					ProxyNextUpstreamTimeout: "0s",
 					ProxyNextUpstreamTries:   0,
 				},
 				{
-					Path:                     "@matches_0_default",
+					Path:                     "@matches_85c724e5_default",
 					ProxyPass:                "http://vs_default_cafe_tea-v1",
 					ProxyNextUpstream:        "error timeout",
 					ProxyNextUpstreamTimeout: "0s",
 					ProxyNextUpstreamTries:   0,
 				},
 				{
-					Path:                     "@matches_1_match_0",
+					Path:                     "@matches_67dd099d_match_0",
 					ProxyPass:                "http://vs_default_cafe_vsr_default_coffee_coffee-v2",
 					ProxyNextUpstream:        "error timeout",
 					ProxyNextUpstreamTimeout: "0s",
 					ProxyNextUpstreamTries:   0,
 				},
 				{
-					Path:                     "@matches_1_default",
+					Path:                     "@matches_67dd099d_default",
 					ProxyPass:                "http://vs_default_cafe_vsr_default_coffee_coffee-v1",
 					ProxyNextUpstream:        "error timeout",
 					ProxyNextUpstreamTimeout: "0s",
@@ -879,7 +1054,7 @@ func TestGenerateVirtualServerConfigForVirtualServerWithMatches(t *testing.T) {
 	isResolverConfigured := false
 	tlsPemFileName := ""
 	vsc := newVirtualServerConfigurator(&baseCfgParams, isPlus, isResolverConfigured)
-	result, warnings := vsc.GenerateVirtualServerConfig(&virtualServerEx, tlsPemFileName)
+	result, warnings := vsc.GenerateVirtualServerConfig(&virtualServerEx, tlsPemFileName, policyOptions{})
 	if !reflect.DeepEqual(result, expected) {
 		t.Errorf("GenerateVirtualServerConfig returned \n%v but expected \n%v", result, expected)
 	}
@@ -920,7 +1095,7 @@ func TestGenerateUpstream(t *testing.T) {
 	}
 
 	vsc := newVirtualServerConfigurator(&cfgParams, false, false)
-	result := vsc.generateUpstream(&conf_v1.VirtualServer{}, name, upstream, false, endpoints)
+	result := vsc.generateUpstream(&conf_v1.VirtualServer{}, name, upstream, false, endpoints, nil, nil, nil)
 	if !reflect.DeepEqual(result, expected) {
 		t.Errorf("generateUpstream() returned %v but expected %v", result, expected)
 	}
@@ -989,7 +1164,7 @@ func TestGenerateUpstreamWithKeepalive(t *testing.T) {
 
 	for _, test := range tests {
 		vsc := newVirtualServerConfigurator(test.cfgParams, false, false)
-		result := vsc.generateUpstream(&conf_v1.VirtualServer{}, name, test.upstream, false, endpoints)
+		result := vsc.generateUpstream(&conf_v1.VirtualServer{}, name, test.upstream, false, endpoints, nil, nil, nil)
 		if !reflect.DeepEqual(result, test.expected) {
 			t.Errorf("generateUpstream() returned %v but expected %v for the case of %v", result, test.expected, test.msg)
 		}
@@ -1000,6 +1175,164 @@ func TestGenerateUpstreamWithKeepalive(t *testing.T) {
 	}
 }
 
+func TestGenerateUpstreamWithDrainedEndpoints(t *testing.T) {
+	name := "test-upstream"
+	endpoints := []string{"192.168.10.10:8080", "192.168.10.11:8080"}
+	drainedEndpoints := map[string]bool{"192.168.10.11:8080": true}
+	upstream := conf_v1.Upstream{Service: name, Port: 8080}
+
+	tests := []struct {
+		isPlus   bool
+		expected []version2.UpstreamServer
+	}{
+		{
+			isPlus: true,
+			expected: []version2.UpstreamServer{
+				{
+					Address: "192.168.10.10:8080",
+				},
+				{
+					Address: "192.168.10.11:8080",
+					Drain:   true,
+				},
+			},
+		},
+		{
+			isPlus: false,
+			expected: []version2.UpstreamServer{
+				{
+					Address: "192.168.10.10:8080",
+				},
+				{
+					Address: "192.168.10.11:8080",
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		vsc := newVirtualServerConfigurator(&ConfigParams{}, test.isPlus, false)
+		result := vsc.generateUpstream(&conf_v1.VirtualServer{}, name, upstream, false, endpoints, drainedEndpoints, nil, nil)
+		if !reflect.DeepEqual(result.Servers, test.expected) {
+			t.Errorf("generateUpstream(isPlus=%v) returned servers %v but expected %v", test.isPlus, result.Servers, test.expected)
+		}
+	}
+}
+
+func TestGenerateUpstreamWithTerminatingEndpoints(t *testing.T) {
+	name := "test-upstream"
+	endpoints := []string{"192.168.10.10:8080", "192.168.10.11:8080"}
+	terminatingEndpoints := map[string]bool{"192.168.10.11:8080": true}
+	upstream := conf_v1.Upstream{Service: name, Port: 8080}
+
+	tests := []struct {
+		isPlus   bool
+		expected []version2.UpstreamServer
+	}{
+		{
+			isPlus: true,
+			expected: []version2.UpstreamServer{
+				{
+					Address: "192.168.10.10:8080",
+				},
+				{
+					Address: "192.168.10.11:8080",
+					Down:    true,
+				},
+			},
+		},
+		{
+			isPlus: false,
+			expected: []version2.UpstreamServer{
+				{
+					Address: "192.168.10.10:8080",
+				},
+				{
+					Address: "192.168.10.11:8080",
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		vsc := newVirtualServerConfigurator(&ConfigParams{}, test.isPlus, false)
+		result := vsc.generateUpstream(&conf_v1.VirtualServer{}, name, upstream, false, endpoints, nil, terminatingEndpoints, nil)
+		if !reflect.DeepEqual(result.Servers, test.expected) {
+			t.Errorf("generateUpstream(isPlus=%v) returned servers %v but expected %v", test.isPlus, result.Servers, test.expected)
+		}
+	}
+}
+
+func TestGenerateUpstreamWithRemoteZoneEndpoints(t *testing.T) {
+	name := "test-upstream"
+	endpoints := []string{"192.168.10.10:8080", "192.168.10.11:8080", "192.168.10.12:8080"}
+	remoteZoneEndpoints := map[string]bool{"192.168.10.11:8080": true, "192.168.10.12:8080": true}
+
+	tests := []struct {
+		upstream         conf_v1.Upstream
+		drainedEndpoints map[string]bool
+		expected         []version2.UpstreamServer
+		expectedLBMethod string
+		msg              string
+	}{
+		{
+			upstream: conf_v1.Upstream{Service: name, Port: 8080, TopologyPolicy: "prefer-zone"},
+			expected: []version2.UpstreamServer{
+				{Address: "192.168.10.10:8080"},
+				{Address: "192.168.10.11:8080", Backup: true},
+				{Address: "192.168.10.12:8080", Backup: true},
+			},
+			expectedLBMethod: "least_conn",
+			msg:              "prefer-zone with the default lb method",
+		},
+		{
+			upstream: conf_v1.Upstream{Service: name, Port: 8080, TopologyPolicy: "prefer-zone", LBMethod: "round_robin"},
+			expected: []version2.UpstreamServer{
+				{Address: "192.168.10.10:8080"},
+				{Address: "192.168.10.11:8080", Backup: true},
+				{Address: "192.168.10.12:8080", Backup: true},
+			},
+			expectedLBMethod: "",
+			msg:              "prefer-zone with round_robin",
+		},
+		{
+			upstream:         conf_v1.Upstream{Service: name, Port: 8080, TopologyPolicy: "prefer-zone"},
+			drainedEndpoints: map[string]bool{"192.168.10.10:8080": true},
+			expected: []version2.UpstreamServer{
+				{Address: "192.168.10.10:8080", Drain: true},
+				{Address: "192.168.10.11:8080"},
+				{Address: "192.168.10.12:8080"},
+			},
+			expectedLBMethod: "least_conn",
+			msg:              "prefer-zone without available servers in the same zone",
+		},
+		{
+			upstream: conf_v1.Upstream{Service: name, Port: 8080},
+			expected: []version2.UpstreamServer{
+				{Address: "192.168.10.10:8080"},
+				{Address: "192.168.10.11:8080"},
+				{Address: "192.168.10.12:8080"},
+			},
+			expectedLBMethod: "random two least_conn",
+			msg:              "no topology policy",
+		},
+	}
+
+	for _, test := range tests {
+		vsc := newVirtualServerConfigurator(&ConfigParams{LBMethod: "random two least_conn"}, true, false)
+		result := vsc.generateUpstream(&conf_v1.VirtualServer{}, name, test.upstream, false, endpoints, test.drainedEndpoints, nil, remoteZoneEndpoints)
+		if !reflect.DeepEqual(result.Servers, test.expected) {
+			t.Errorf("generateUpstream() returned servers %v but expected %v for the case of %v", result.Servers, test.expected, test.msg)
+		}
+		if result.LBMethod != test.expectedLBMethod {
+			t.Errorf("generateUpstream() returned lb method %q but expected %q for the case of %v", result.LBMethod, test.expectedLBMethod, test.msg)
+		}
+		if len(vsc.warnings) > 0 {
+			t.Errorf("generateUpstream() returned warnings %v for the case of %v", vsc.warnings, test.msg)
+		}
+	}
+}
+
 func TestGenerateUpstreamForExternalNameService(t *testing.T) {
 	name := "test-upstream"
 	endpoints := []string{"example.com"}
@@ -1017,7 +1350,7 @@ func TestGenerateUpstreamForExternalNameService(t *testing.T) {
 	}
 
 	vsc := newVirtualServerConfigurator(&cfgParams, true, true)
-	result := vsc.generateUpstream(&conf_v1.VirtualServer{}, name, upstream, true, endpoints)
+	result := vsc.generateUpstream(&conf_v1.VirtualServer{}, name, upstream, true, endpoints, nil, nil, nil)
 	if !reflect.DeepEqual(result, expected) {
 		t.Errorf("generateUpstream() returned %v but expected %v", result, expected)
 	}
@@ -1138,6 +1471,70 @@ func TestGenerateLocationForProxying(t *testing.T) {
 	}
 }
 
+func TestGenerateRequestBuffering(t *testing.T) {
+	tests := []struct {
+		requestBuffering *bool
+		expected         string
+	}{
+		{
+			requestBuffering: nil,
+			expected:         "",
+		},
+		{
+			requestBuffering: createPointerFromBool(true),
+			expected:         "on",
+		},
+		{
+			requestBuffering: createPointerFromBool(false),
+			expected:         "off",
+		},
+	}
+
+	for _, test := range tests {
+		result := generateRequestBuffering(test.requestBuffering)
+		if result != test.expected {
+			t.Errorf("generateRequestBuffering(%v) returned %q but expected %q", test.requestBuffering, result, test.expected)
+		}
+	}
+}
+
+func TestGenerateLocationWithLimitRate(t *testing.T) {
+	action := &conf_v1.Action{
+		Pass:           "test",
+		LimitRate:      "100k",
+		LimitRateAfter: "10m",
+	}
+
+	result := generateLocation("/", "test-upstream", conf_v1.Upstream{}, action, &ConfigParams{})
+	if result.LimitRate != "100k" || result.LimitRateAfter != "10m" {
+		t.Errorf("generateLocation() returned limit rate %q and limit rate after %q but expected %q and %q",
+			result.LimitRate, result.LimitRateAfter, "100k", "10m")
+	}
+}
+
+func TestAddClientBodyToLocations(t *testing.T) {
+	route := conf_v1.Route{
+		ClientMaxBodySize: "100m",
+	}
+	locations := []version2.Location{
+		{
+			ClientMaxBodySize: "1m",
+			ClientBodyTimeout: "30s",
+		},
+	}
+	expected := []version2.Location{
+		{
+			ClientMaxBodySize: "100m",
+			ClientBodyTimeout: "30s",
+		},
+	}
+
+	addClientBodyToLocations(route, locations)
+	if !reflect.DeepEqual(locations, expected) {
+		t.Errorf("addClientBodyToLocations() returned %+v but expected %+v", locations, expected)
+	}
+}
+
 func TestGenerateReturnBlock(t *testing.T) {
 	tests := []struct {
 		text        string
@@ -1327,697 +1724,1108 @@ func TestGenerateRedirectConfig(t *testing.T) {
 	}
 }
 
-func TestGenerateTLSRedirectBasedOn(t *testing.T) {
+func TestGenerateRealIP(t *testing.T) {
+	disabled := false
+
+	cfgParams := &ConfigParams{
+		SetRealIPFrom:   []string{"10.0.0.0/8"},
+		RealIPHeader:    "X-Real-IP",
+		RealIPRecursive: true,
+	}
+
 	tests := []struct {
-		basedOn  string
-		expected string
+		server            *conf_v1.VirtualServerServer
+		expectedFrom      []string
+		expectedHeader    string
+		expectedRecursive bool
+		msg               string
 	}{
 		{
-			basedOn:  "scheme",
-			expected: "$scheme",
+			server:            nil,
+			expectedFrom:      []string{"10.0.0.0/8"},
+			expectedHeader:    "X-Real-IP",
+			expectedRecursive: true,
+			msg:               "no server",
 		},
 		{
-			basedOn:  "x-forwarded-proto",
-			expected: "$http_x_forwarded_proto",
+			server:            &conf_v1.VirtualServerServer{},
+			expectedFrom:      []string{"10.0.0.0/8"},
+			expectedHeader:    "X-Real-IP",
+			expectedRecursive: true,
+			msg:               "no realIP",
 		},
 		{
-			basedOn:  "",
-			expected: "$scheme",
+			server: &conf_v1.VirtualServerServer{
+				RealIP: &conf_v1.RealIP{
+					Header: "X-Forwarded-For",
+				},
+			},
+			expectedFrom:      []string{"10.0.0.0/8"},
+			expectedHeader:    "X-Forwarded-For",
+			expectedRecursive: true,
+			msg:               "header override",
+		},
+		{
+			server: &conf_v1.VirtualServerServer{
+				RealIP: &conf_v1.RealIP{
+					SetRealIPFrom: []string{"2001:db8::/32"},
+					Recursive:     &disabled,
+				},
+			},
+			expectedFrom:      []string{"2001:db8::/32"},
+			expectedHeader:    "X-Real-IP",
+			expectedRecursive: false,
+			msg:               "setRealIPFrom and recursive override",
 		},
 	}
+
 	for _, test := range tests {
-		result := generateTLSRedirectBasedOn(test.basedOn)
-		if result != test.expected {
-			t.Errorf("generateTLSRedirectBasedOn(%v) returned %v but expected %v", test.basedOn, result, test.expected)
+		from, header, recursive := generateRealIP(test.server, cfgParams)
+		if !reflect.DeepEqual(from, test.expectedFrom) {
+			t.Errorf("generateRealIP() returned setRealIPFrom %v but expected %v for the case of %s", from, test.expectedFrom, test.msg)
+		}
+		if header != test.expectedHeader {
+			t.Errorf("generateRealIP() returned header %q but expected %q for the case of %s", header, test.expectedHeader, test.msg)
+		}
+		if recursive != test.expectedRecursive {
+			t.Errorf("generateRealIP() returned recursive %v but expected %v for the case of %s", recursive, test.expectedRecursive, test.msg)
 		}
 	}
 }
 
-func TestCreateUpstreamsForPlus(t *testing.T) {
-	virtualServerEx := VirtualServerEx{
-		VirtualServer: &conf_v1.VirtualServer{
-			ObjectMeta: meta_v1.ObjectMeta{
-				Name:      "cafe",
-				Namespace: "default",
-			},
-			Spec: conf_v1.VirtualServerSpec{
-				Host: "cafe.example.com",
-				Upstreams: []conf_v1.Upstream{
-					{
-						Name:    "tea",
-						Service: "tea-svc",
-						Port:    80,
-					},
-					{
-						Name:    "test",
-						Service: "test-svc",
-						Port:    80,
-					},
-					{
-						Name:        "subselector-test",
-						Service:     "test-svc",
-						Subselector: map[string]string{"vs": "works"},
-						Port:        80,
-					},
-					{
-						Name:    "external",
-						Service: "external-svc",
-						Port:    80,
-					},
-				},
-				Routes: []conf_v1.Route{
-					{
-						Path: "/tea",
-						Action: &conf_v1.Action{
-							Pass: "tea",
-						},
-					},
-					{
-						Path:  "/coffee",
-						Route: "default/coffee",
-					},
-					{
-						Path: "/external",
-						Action: &conf_v1.Action{
-							Pass: "external",
-						},
-					},
-				},
+func TestGenerateVirtualServerXForwardedHeaders(t *testing.T) {
+	cfgParams := &ConfigParams{
+		XForwardedHost: "preserve",
+		XForwardedPort: "remove",
+	}
+
+	tests := []struct {
+		server      *conf_v1.VirtualServerServer
+		tlsRedirect *version2.TLSRedirect
+		expected    []version2.XForwardedHeader
+		msg         string
+	}{
+		{
+			server: nil,
+			expected: []version2.XForwardedHeader{
+				{Name: "X-Forwarded-For", Value: "$proxy_add_x_forwarded_for"},
+				{Name: "X-Forwarded-Port", Value: `""`},
+				{Name: "X-Forwarded-Proto", Value: "$scheme"},
 			},
+			msg: "no server",
 		},
-		Endpoints: map[string][]string{
-			"default/tea-svc:80": {
-				"10.0.0.20:80",
-			},
-			"default/test-svc:80": {},
-			"default/test-svc_vs=works:80": {
-				"10.0.0.30:80",
-			},
-			"default/coffee-svc:80": {
-				"10.0.0.40:80",
-			},
-			"default/test-svc_vsr=works:80": {
-				"10.0.0.50:80",
+		{
+			server: &conf_v1.VirtualServerServer{
+				XForwarded: &conf_v1.XForwarded{
+					For:  "overwrite",
+					Host: "overwrite",
+				},
 			},
-			"default/external-svc:80": {
-				"example.com:80",
+			tlsRedirect: &version2.TLSRedirect{BasedOn: "$http_x_forwarded_proto"},
+			expected: []version2.XForwardedHeader{
+				{Name: "X-Forwarded-For", Value: "$remote_addr"},
+				{Name: "X-Forwarded-Host", Value: "$host"},
+				{Name: "X-Forwarded-Port", Value: `""`},
+				{Name: "X-Forwarded-Proto", Value: "$http_x_forwarded_proto"},
 			},
+			msg: "for and host override with TLS redirect",
 		},
-		ExternalNameSvcs: map[string]bool{
-			"default/external-svc": true,
-		},
-		VirtualServerRoutes: []*conf_v1.VirtualServerRoute{
-			{
-				ObjectMeta: meta_v1.ObjectMeta{
-					Name:      "coffee",
-					Namespace: "default",
-				},
-				Spec: conf_v1.VirtualServerRouteSpec{
-					Host: "cafe.example.com",
-					Upstreams: []conf_v1.Upstream{
-						{
-							Name:    "coffee",
-							Service: "coffee-svc",
-							Port:    80,
-						},
-						{
-							Name:        "subselector-test",
-							Service:     "test-svc",
-							Subselector: map[string]string{"vsr": "works"},
-							Port:        80,
-						},
-					},
-					Subroutes: []conf_v1.Route{
-						{
-							Path: "/coffee",
-							Action: &conf_v1.Action{
-								Pass: "coffee",
-							},
-						},
-						{
-							Path: "/coffee/sub",
-							Action: &conf_v1.Action{
-								Pass: "subselector-test",
-							},
-						},
-					},
+		{
+			server: &conf_v1.VirtualServerServer{
+				XForwarded: &conf_v1.XForwarded{
+					For:   "preserve",
+					Proto: "remove",
+					Port:  "preserve",
 				},
 			},
+			expected: []version2.XForwardedHeader{
+				{Name: "X-Forwarded-Proto", Value: `""`},
+			},
+			msg: "for, proto and port override",
 		},
 	}
 
-	expected := []version2.Upstream{
+	for _, test := range tests {
+		result := generateVirtualServerXForwardedHeaders(test.server, cfgParams, test.tlsRedirect)
+		if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("generateVirtualServerXForwardedHeaders() returned %v but expected %v for the case of %s", result, test.expected, test.msg)
+		}
+	}
+}
+
+func TestGenerateAccessLog(t *testing.T) {
+	disabled := false
+
+	vs := &conf_v1.VirtualServer{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "cafe",
+			Namespace: "default",
+		},
+	}
+
+	tests := []struct {
+		server            *conf_v1.VirtualServerServer
+		expectedAccessLog *version2.AccessLog
+		expectedLogFormat *version2.LogFormat
+		msg               string
+	}{
 		{
-			Name: "vs_default_cafe_tea",
-			Servers: []version2.UpstreamServer{
-				{
-					Address: "10.0.0.20:80",
-				},
-			},
+			server:            nil,
+			expectedAccessLog: nil,
+			expectedLogFormat: nil,
+			msg:               "no server",
 		},
 		{
-			Name:    "vs_default_cafe_test",
-			Servers: nil,
+			server:            &conf_v1.VirtualServerServer{},
+			expectedAccessLog: nil,
+			expectedLogFormat: nil,
+			msg:               "no accessLog",
 		},
 		{
-			Name: "vs_default_cafe_subselector-test",
-			Servers: []version2.UpstreamServer{
-				{
-					Address: "10.0.0.30:80",
-				},
+			server: &conf_v1.VirtualServerServer{
+				AccessLog: &conf_v1.AccessLog{Enable: &disabled},
 			},
+			expectedAccessLog: &version2.AccessLog{Off: true},
+			expectedLogFormat: nil,
+			msg:               "disabled access log",
 		},
 		{
-			Name: "vs_default_cafe_vsr_default_coffee_coffee",
-			Servers: []version2.UpstreamServer{
-				{
-					Address: "10.0.0.40:80",
-				},
+			server: &conf_v1.VirtualServerServer{
+				AccessLog: &conf_v1.AccessLog{},
 			},
+			expectedAccessLog: &version2.AccessLog{Destination: "/var/log/nginx/access.log", FormatName: "main"},
+			expectedLogFormat: nil,
+			msg:               "defaults",
 		},
 		{
-			Name: "vs_default_cafe_vsr_default_coffee_subselector-test",
-			Servers: []version2.UpstreamServer{
-				{
-					Address: "10.0.0.50:80",
-				},
+			server: &conf_v1.VirtualServerServer{
+				AccessLog: &conf_v1.AccessLog{FormatName: "combined", Destination: "syslog:server=10.0.0.1"},
+			},
+			expectedAccessLog: &version2.AccessLog{Destination: "syslog:server=10.0.0.1", FormatName: "combined"},
+			expectedLogFormat: nil,
+			msg:               "format name and destination",
+		},
+		{
+			server: &conf_v1.VirtualServerServer{
+				AccessLog: &conf_v1.AccessLog{Format: "$remote_addr $status"},
 			},
+			expectedAccessLog: &version2.AccessLog{Destination: "/var/log/nginx/access.log", FormatName: "vs_default_cafe_log_format"},
+			expectedLogFormat: &version2.LogFormat{Name: "vs_default_cafe_log_format", Format: "$remote_addr $status"},
+			msg:               "inline format",
 		},
 	}
 
-	result := createUpstreamsForPlus(&virtualServerEx, &ConfigParams{})
-	if !reflect.DeepEqual(result, expected) {
-		t.Errorf("createUpstreamsForPlus returned \n%v but expected \n%v", result, expected)
+	for _, test := range tests {
+		vs.Spec.Server = test.server
+		accessLog, logFormat := generateAccessLog(vs, &ConfigParams{}, newVariableNamer(vs))
+		if !reflect.DeepEqual(accessLog, test.expectedAccessLog) {
+			t.Errorf("generateAccessLog() returned access log %+v but expected %+v for the case of %s", accessLog, test.expectedAccessLog, test.msg)
+		}
+		if !reflect.DeepEqual(logFormat, test.expectedLogFormat) {
+			t.Errorf("generateAccessLog() returned log format %+v but expected %+v for the case of %s", logFormat, test.expectedLogFormat, test.msg)
+		}
 	}
 }
 
-func TestCreateUpstreamServersConfigForPlus(t *testing.T) {
-	upstream := version2.Upstream{
-		Servers: []version2.UpstreamServer{
-			{
-				Address: "10.0.0.20:80",
-			},
+func TestGenerateAccessLogWithJSONPreset(t *testing.T) {
+	vs := &conf_v1.VirtualServer{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "cafe",
+			Namespace: "default",
 		},
-		MaxFails:    21,
-		MaxConns:    16,
-		FailTimeout: "30s",
-		SlowStart:   "50s",
 	}
 
-	expected := nginx.ServerConfig{
-		MaxFails:    21,
-		MaxConns:    16,
-		FailTimeout: "30s",
-		SlowStart:   "50s",
+	jsonLogFormat := &version2.LogFormat{
+		Name:   "vs_default_cafe_log_format",
+		Escape: "json",
+		Format: strings.ReplaceAll(generateJSONLogFormat([]logField{
+			{name: "virtualserver_namespace", value: "default"},
+			{name: "virtualserver_name", value: "cafe"},
+		}), `"`, `\"`),
 	}
 
-	result := createUpstreamServersConfigForPlus(upstream)
-	if !reflect.DeepEqual(result, expected) {
-		t.Errorf("createUpstreamServersConfigForPlus returned %v but expected %v", result, expected)
+	tests := []struct {
+		server            *conf_v1.VirtualServerServer
+		cfgParams         *ConfigParams
+		expectedAccessLog *version2.AccessLog
+		expectedLogFormat *version2.LogFormat
+		msg               string
+	}{
+		{
+			server: &conf_v1.VirtualServerServer{
+				AccessLog: &conf_v1.AccessLog{FormatPreset: "json", Destination: "/var/log/nginx/cafe.log"},
+			},
+			cfgParams:         &ConfigParams{},
+			expectedAccessLog: &version2.AccessLog{Destination: "/var/log/nginx/cafe.log", FormatName: "vs_default_cafe_log_format"},
+			expectedLogFormat: jsonLogFormat,
+			msg:               "preset of the VirtualServer",
+		},
+		{
+			server:            nil,
+			cfgParams:         &ConfigParams{MainLogFormatPreset: "json"},
+			expectedAccessLog: &version2.AccessLog{Destination: "/var/log/nginx/access.log", FormatName: "vs_default_cafe_log_format"},
+			expectedLogFormat: jsonLogFormat,
+			msg:               "global preset",
+		},
+		{
+			server:            nil,
+			cfgParams:         &ConfigParams{MainLogFormatPreset: "json", MainAccessLogOff: true},
+			expectedAccessLog: nil,
+			expectedLogFormat: nil,
+			msg:               "global preset with the access log turned off",
+		},
+		{
+			server: &conf_v1.VirtualServerServer{
+				AccessLog: &conf_v1.AccessLog{FormatName: "combined"},
+			},
+			cfgParams:         &ConfigParams{MainLogFormatPreset: "json"},
+			expectedAccessLog: &version2.AccessLog{Destination: "/var/log/nginx/access.log", FormatName: "combined"},
+			expectedLogFormat: nil,
+			msg:               "global preset overridden by a format name",
+		},
 	}
-}
 
-func TestCreateUpstreamServersConfigForPlusNoUpstreams(t *testing.T) {
-	noUpstream := version2.Upstream{}
-	expected := nginx.ServerConfig{}
-
-	result := createUpstreamServersConfigForPlus(noUpstream)
-	if !reflect.DeepEqual(result, expected) {
-		t.Errorf("createUpstreamServersConfigForPlus returned %v but expected %v", result, expected)
+	for _, test := range tests {
+		vs.Spec.Server = test.server
+		accessLog, logFormat := generateAccessLog(vs, test.cfgParams, newVariableNamer(vs))
+		if !reflect.DeepEqual(accessLog, test.expectedAccessLog) {
+			t.Errorf("generateAccessLog() returned access log %+v but expected %+v for the case of %s", accessLog, test.expectedAccessLog, test.msg)
+		}
+		if !reflect.DeepEqual(logFormat, test.expectedLogFormat) {
+			t.Errorf("generateAccessLog() returned log format %+v but expected %+v for the case of %s", logFormat, test.expectedLogFormat, test.msg)
+		}
 	}
 }
 
-func TestGenerateSplits(t *testing.T) {
-	splits := []conf_v1.Split{
+func TestGenerateErrorLog(t *testing.T) {
+	cfgParams := &ConfigParams{MainErrorLogLevel: "notice"}
+
+	tests := []struct {
+		server   *conf_v1.VirtualServerServer
+		expected *version2.ErrorLog
+		msg      string
+	}{
 		{
-			Weight: 90,
-			Action: &conf_v1.Action{
-				Pass: "coffee-v1",
+			server:   nil,
+			expected: nil,
+			msg:      "no server",
+		},
+		{
+			server:   &conf_v1.VirtualServerServer{},
+			expected: nil,
+			msg:      "no errorLog",
+		},
+		{
+			server: &conf_v1.VirtualServerServer{
+				ErrorLog: &conf_v1.ErrorLog{},
 			},
+			expected: &version2.ErrorLog{Destination: "/var/log/nginx/error.log", Level: "notice"},
+			msg:      "defaults",
 		},
 		{
-			Weight: 10,
-			Action: &conf_v1.Action{
-				Pass: "coffee-v2",
+			server: &conf_v1.VirtualServerServer{
+				ErrorLog: &conf_v1.ErrorLog{Destination: "syslog:server=10.0.0.1:514", Level: "warn"},
 			},
+			expected: &version2.ErrorLog{Destination: "syslog:server=10.0.0.1:514", Level: "warn"},
+			msg:      "destination and level",
 		},
 	}
 
-	virtualServer := conf_v1.VirtualServer{
-		ObjectMeta: meta_v1.ObjectMeta{
-			Name:      "cafe",
-			Namespace: "default",
-		},
+	for _, test := range tests {
+		result := generateErrorLog(test.server, cfgParams)
+		if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("generateErrorLog() returned %+v but expected %+v for the case of %s", result, test.expected, test.msg)
+		}
 	}
-	upstreamNamer := newUpstreamNamerForVirtualServer(&virtualServer)
-	variableNamer := newVariableNamer(&virtualServer)
-	scIndex := 1
-	cfgParams := ConfigParams{}
-	crUpstreams := make(map[string]conf_v1.Upstream)
+}
 
-	expectedSplitClient := version2.SplitClient{
-		Source:   "$request_id",
-		Variable: "$vs_default_cafe_splits_1",
-		Distributions: []version2.Distribution{
-			{
-				Weight: "90%",
-				Value:  "@splits_1_split_0",
-			},
-			{
-				Weight: "10%",
-				Value:  "@splits_1_split_1",
+func TestAddServerHeaders(t *testing.T) {
+	sharedHeaders := make([]version2.AddHeader, 1, 2)
+	sharedHeaders[0] = version2.AddHeader{Name: "X-Location", Value: "shared"}
+
+	vsCfg := version2.VirtualServerConfig{
+		Server: version2.Server{
+			Locations: []version2.Location{
+				{
+					Path: "/",
+				},
+				{
+					Path:       "/tea",
+					AddHeaders: sharedHeaders,
+				},
+				{
+					Path:       "/coffee",
+					AddHeaders: sharedHeaders,
+				},
 			},
 		},
 	}
-	expectedLocations := []version2.Location{
-		{
-			Path:                     "@splits_1_split_0",
-			ProxyPass:                "http://vs_default_cafe_coffee-v1",
-			ProxyNextUpstream:        "error timeout",
-			ProxyNextUpstreamTimeout: "0s",
-			ProxyNextUpstreamTries:   0,
-		},
+
+	headers := []version2.AddHeader{
 		{
-			Path:                     "@splits_1_split_1",
-			ProxyPass:                "http://vs_default_cafe_coffee-v2",
-			ProxyNextUpstream:        "error timeout",
-			ProxyNextUpstreamTimeout: "0s",
-			ProxyNextUpstreamTries:   0,
+			Name:  "X-NGINX-Warnings-Count",
+			Value: "1",
 		},
 	}
 
-	resultSplitClient, resultLocations := generateSplits(splits, upstreamNamer, crUpstreams, variableNamer, scIndex, &cfgParams)
-	if !reflect.DeepEqual(resultSplitClient, expectedSplitClient) {
-		t.Errorf("generateSplits() returned %v but expected %v", resultSplitClient, expectedSplitClient)
+	addServerHeaders(&vsCfg, headers)
+
+	if !reflect.DeepEqual(vsCfg.Server.AddHeaders, headers) {
+		t.Errorf("addServerHeaders() set the headers of the server to %v but expected %v", vsCfg.Server.AddHeaders, headers)
 	}
-	if !reflect.DeepEqual(resultLocations, expectedLocations) {
-		t.Errorf("generateSplits() returned %v but expected %v", resultLocations, expectedLocations)
+	if len(vsCfg.Server.Locations[0].AddHeaders) != 0 {
+		t.Errorf("addServerHeaders() added the headers to a location that inherits them from the server")
+	}
+
+	expected := []version2.AddHeader{sharedHeaders[0], headers[0]}
+	for _, l := range vsCfg.Server.Locations[1:] {
+		if !reflect.DeepEqual(l.AddHeaders, expected) {
+			t.Errorf("addServerHeaders() set the headers of location %v to %v but expected %v", l.Path, l.AddHeaders, expected)
+		}
+	}
+	if len(sharedHeaders) != 1 {
+		t.Errorf("addServerHeaders() modified the headers shared by the locations")
 	}
 }
 
-func TestGenerateDefaultSplitsConfig(t *testing.T) {
-	route := conf_v1.Route{
-		Path: "/",
-		Splits: []conf_v1.Split{
-			{
-				Weight: 90,
-				Action: &conf_v1.Action{
-					Pass: "coffee-v1",
-				},
-			},
-			{
-				Weight: 10,
-				Action: &conf_v1.Action{
-					Pass: "coffee-v2",
-				},
-			},
-		},
-	}
-	virtualServer := conf_v1.VirtualServer{
+func TestGenerateRequestID(t *testing.T) {
+	vs := &conf_v1.VirtualServer{
 		ObjectMeta: meta_v1.ObjectMeta{
 			Name:      "cafe",
 			Namespace: "default",
 		},
 	}
-	upstreamNamer := newUpstreamNamerForVirtualServer(&virtualServer)
-	variableNamer := newVariableNamer(&virtualServer)
-	index := 1
+	variableNamer := newVariableNamer(vs)
 
-	expected := routingCfg{
-		SplitClients: []version2.SplitClient{
-			{
-				Source:   "$request_id",
-				Variable: "$vs_default_cafe_splits_1",
-				Distributions: []version2.Distribution{
-					{
-						Weight: "90%",
-						Value:  "@splits_1_split_0",
-					},
-					{
-						Weight: "10%",
-						Value:  "@splits_1_split_1",
-					},
-				},
-			},
+	tests := []struct {
+		server       *conf_v1.VirtualServerServer
+		expected     *version2.RequestID
+		expectedMaps []version2.Map
+		msg          string
+	}{
+		{
+			server:   nil,
+			expected: nil,
+			msg:      "no server",
 		},
-		Locations: []version2.Location{
-			{
-				Path:                     "@splits_1_split_0",
-				ProxyPass:                "http://vs_default_cafe_coffee-v1",
-				ProxyNextUpstream:        "error timeout",
-				ProxyNextUpstreamTimeout: "0s",
-				ProxyNextUpstreamTries:   0,
+		{
+			server: &conf_v1.VirtualServerServer{
+				RequestID: &conf_v1.RequestID{Enable: false},
 			},
-			{
-				Path:                     "@splits_1_split_1",
-				ProxyPass:                "http://vs_default_cafe_coffee-v2",
-				ProxyNextUpstream:        "error timeout",
-				ProxyNextUpstreamTimeout: "0s",
-				ProxyNextUpstreamTries:   0,
+			expected: nil,
+			msg:      "disabled",
+		},
+		{
+			server: &conf_v1.VirtualServerServer{
+				RequestID: &conf_v1.RequestID{Enable: true},
 			},
+			expected: &version2.RequestID{Header: "X-Request-ID", Variable: "$request_id"},
+			msg:      "default header",
 		},
-		InternalRedirectLocation: version2.InternalRedirectLocation{
-			Path:        "/",
-			Destination: "$vs_default_cafe_splits_1",
+		{
+			server: &conf_v1.VirtualServerServer{
+				RequestID: &conf_v1.RequestID{Enable: true, Header: "X-Correlation-ID", Preserve: true},
+			},
+			expected: &version2.RequestID{Header: "X-Correlation-ID", Variable: "$vs_default_cafe_request_id"},
+			expectedMaps: []version2.Map{
+				{
+					Source:   "$http_x_correlation_id",
+					Variable: "$vs_default_cafe_request_id",
+					Parameters: []version2.Parameter{
+						{
+							Value:  "default",
+							Result: "$http_x_correlation_id",
+						},
+						{
+							Value:  `""`,
+							Result: "$request_id",
+						},
+					},
+				},
+			},
+			msg: "preserved custom header",
 		},
 	}
 
-	cfgParams := ConfigParams{}
-
-	result := generateDefaultSplitsConfig(route, upstreamNamer, map[string]conf_v1.Upstream{}, variableNamer, index, &cfgParams)
-	if !reflect.DeepEqual(result, expected) {
-		t.Errorf("generateDefaultSplitsConfig() returned %v but expected %v", result, expected)
+	for _, test := range tests {
+		result, maps := generateRequestID(test.server, variableNamer)
+		if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("generateRequestID() returned %+v but expected %+v for the case of %s", result, test.expected, test.msg)
+		}
+		if !reflect.DeepEqual(maps, test.expectedMaps) {
+			t.Errorf("generateRequestID() returned maps %+v but expected %+v for the case of %s", maps, test.expectedMaps, test.msg)
+		}
 	}
 }
 
-func TestGenerateMatchesConfig(t *testing.T) {
-	route := conf_v1.Route{
-		Path: "/",
-		Matches: []conf_v1.Match{
-			{
-				Conditions: []conf_v1.Condition{
-					{
-						Header: "x-version",
-						Value:  "v1",
-					},
-					{
-						Cookie: "user",
-						Value:  "john",
-					},
-					{
-						Argument: "answer",
-						Value:    "yes",
-					},
-					{
-						Variable: "$request_method",
-						Value:    "GET",
-					},
-				},
-				Action: &conf_v1.Action{
-					Pass: "coffee-v1",
-				},
+func TestGenerateVirtualServerConfigWithRequestID(t *testing.T) {
+	virtualServerEx := VirtualServerEx{
+		VirtualServer: &conf_v1.VirtualServer{
+			ObjectMeta: meta_v1.ObjectMeta{
+				Name:      "cafe",
+				Namespace: "default",
 			},
-			{
-				Conditions: []conf_v1.Condition{
-					{
-						Header: "x-version",
-						Value:  "v2",
-					},
-					{
-						Cookie: "user",
-						Value:  "paul",
-					},
+			Spec: conf_v1.VirtualServerSpec{
+				Host: "cafe.example.com",
+				Server: &conf_v1.VirtualServerServer{
+					RequestID: &conf_v1.RequestID{Enable: true, Preserve: true},
+				},
+				Upstreams: []conf_v1.Upstream{
 					{
-						Argument: "answer",
-						Value:    "no",
+						Name:    "tea-v1",
+						Service: "tea-svc-v1",
+						Port:    80,
 					},
 					{
-						Variable: "$request_method",
-						Value:    "POST",
+						Name:    "tea-v2",
+						Service: "tea-svc-v2",
+						Port:    80,
 					},
 				},
-				Splits: []conf_v1.Split{
-					{
-						Weight: 90,
-						Action: &conf_v1.Action{
-							Pass: "coffee-v1",
-						},
-					},
+				Routes: []conf_v1.Route{
 					{
-						Weight: 10,
-						Action: &conf_v1.Action{
-							Pass: "coffee-v2",
+						Path: "/tea",
+						Splits: []conf_v1.Split{
+							{
+								Weight: 90,
+								Action: &conf_v1.Action{Pass: "tea-v1"},
+							},
+							{
+								Weight: 10,
+								Action: &conf_v1.Action{Pass: "tea-v2"},
+							},
 						},
 					},
 				},
 			},
 		},
-		Action: &conf_v1.Action{
-			Pass: "tea",
-		},
 	}
-	virtualServer := conf_v1.VirtualServer{
+
+	vsc := newVirtualServerConfigurator(&ConfigParams{}, false, false)
+	result, warnings := vsc.GenerateVirtualServerConfig(&virtualServerEx, "", policyOptions{})
+
+	if len(warnings) != 0 {
+		t.Errorf("GenerateVirtualServerConfig returned warnings: %v", vsc.warnings)
+	}
+
+	expectedRequestID := &version2.RequestID{Header: "X-Request-ID", Variable: "$vs_default_cafe_request_id"}
+	if !reflect.DeepEqual(result.Server.RequestID, expectedRequestID) {
+		t.Errorf("GenerateVirtualServerConfig returned the request ID %+v but expected %+v", result.Server.RequestID, expectedRequestID)
+	}
+
+	if len(result.SplitClients) != 1 || result.SplitClients[0].Source != "$vs_default_cafe_request_id" {
+		t.Errorf("GenerateVirtualServerConfig returned split clients %+v but expected the source $vs_default_cafe_request_id", result.SplitClients)
+	}
+
+	expectedHeaders := []version2.AddHeader{{Name: "X-Request-ID", Value: "$vs_default_cafe_request_id"}}
+	if !reflect.DeepEqual(result.Server.AddHeaders, expectedHeaders) {
+		t.Errorf("GenerateVirtualServerConfig returned the headers %+v but expected %+v", result.Server.AddHeaders, expectedHeaders)
+	}
+}
+
+func TestGenerateTracing(t *testing.T) {
+	percentage := 25
+	zero := 0
+
+	vs := &conf_v1.VirtualServer{
 		ObjectMeta: meta_v1.ObjectMeta{
 			Name:      "cafe",
 			Namespace: "default",
 		},
 	}
-	upstreamNamer := newUpstreamNamerForVirtualServer(&virtualServer)
-	variableNamer := newVariableNamer(&virtualServer)
-	index := 1
-	scIndex := 2
 
-	expected := routingCfg{
-		Maps: []version2.Map{
-			{
-				Source:   "$http_x_version",
-				Variable: "$vs_default_cafe_matches_1_match_0_cond_0",
-				Parameters: []version2.Parameter{
-					{
-						Value:  `"v1"`,
-						Result: "$vs_default_cafe_matches_1_match_0_cond_1",
-					},
-					{
-						Value:  "default",
-						Result: "0",
-					},
+	tests := []struct {
+		tracing              *conf_v1.Tracing
+		expectedTracing      *version2.Tracing
+		expectedSplitClients []version2.SplitClient
+		msg                  string
+	}{
+		{
+			tracing:              nil,
+			expectedTracing:      nil,
+			expectedSplitClients: nil,
+			msg:                  "no tracing",
+		},
+		{
+			tracing:              &conf_v1.Tracing{Enable: false},
+			expectedTracing:      &version2.Tracing{Enable: false},
+			expectedSplitClients: nil,
+			msg:                  "disabled tracing",
+		},
+		{
+			tracing: &conf_v1.Tracing{
+				Enable:        true,
+				OperationName: "${request_method} ${uri}",
+				Tags: []conf_v1.TracingTag{
+					{Name: "component", Value: "nginx-ingress"},
 				},
 			},
-			{
-				Source:   "$cookie_user",
-				Variable: "$vs_default_cafe_matches_1_match_0_cond_1",
-				Parameters: []version2.Parameter{
-					{
-						Value:  `"john"`,
-						Result: "$vs_default_cafe_matches_1_match_0_cond_2",
-					},
-					{
-						Value:  "default",
-						Result: "0",
-					},
+			expectedTracing: &version2.Tracing{
+				Enable:        true,
+				OperationName: "${request_method} ${uri}",
+				Tags: []version2.TracingTag{
+					{Name: "component", Value: "nginx-ingress"},
 				},
 			},
-			{
-				Source:   "$arg_answer",
-				Variable: "$vs_default_cafe_matches_1_match_0_cond_2",
-				Parameters: []version2.Parameter{
-					{
-						Value:  `"yes"`,
-						Result: "$vs_default_cafe_matches_1_match_0_cond_3",
-					},
-					{
-						Value:  "default",
-						Result: "0",
-					},
+			expectedSplitClients: nil,
+			msg:                  "operation name and tags",
+		},
+		{
+			tracing: &conf_v1.Tracing{
+				Enable:             true,
+				SamplingPercentage: &percentage,
+			},
+			expectedTracing: &version2.Tracing{
+				Enable: true,
+				Tags: []version2.TracingTag{
+					{Name: "sampling.priority", Value: "$vs_default_cafe_tracing_sampled"},
 				},
 			},
-			{
-				Source:   "$request_method",
-				Variable: "$vs_default_cafe_matches_1_match_0_cond_3",
-				Parameters: []version2.Parameter{
-					{
-						Value:  `"GET"`,
-						Result: "1",
-					},
-					{
-						Value:  "default",
-						Result: "0",
+			expectedSplitClients: []version2.SplitClient{
+				{
+					Source:   "$request_id",
+					Variable: "$vs_default_cafe_tracing_sampled",
+					Distributions: []version2.Distribution{
+						{Weight: "25%", Value: "1"},
+						{Weight: "*", Value: "0"},
 					},
 				},
 			},
-			{
-				Source:   "$http_x_version",
-				Variable: "$vs_default_cafe_matches_1_match_1_cond_0",
-				Parameters: []version2.Parameter{
-					{
-						Value:  `"v2"`,
-						Result: "$vs_default_cafe_matches_1_match_1_cond_1",
-					},
-					{
-						Value:  "default",
-						Result: "0",
-					},
+			msg: "sampling percentage",
+		},
+		{
+			tracing: &conf_v1.Tracing{
+				Enable:             true,
+				SamplingPercentage: &zero,
+			},
+			expectedTracing: &version2.Tracing{
+				Enable: true,
+				Tags: []version2.TracingTag{
+					{Name: "sampling.priority", Value: "$vs_default_cafe_tracing_sampled"},
 				},
 			},
-			{
-				Source:   "$cookie_user",
-				Variable: "$vs_default_cafe_matches_1_match_1_cond_1",
-				Parameters: []version2.Parameter{
-					{
-						Value:  `"paul"`,
-						Result: "$vs_default_cafe_matches_1_match_1_cond_2",
-					},
-					{
-						Value:  "default",
-						Result: "0",
+			expectedSplitClients: []version2.SplitClient{
+				{
+					Source:   "$request_id",
+					Variable: "$vs_default_cafe_tracing_sampled",
+					Distributions: []version2.Distribution{
+						{Weight: "*", Value: "0"},
 					},
 				},
 			},
-			{
-				Source:   "$arg_answer",
-				Variable: "$vs_default_cafe_matches_1_match_1_cond_2",
-				Parameters: []version2.Parameter{
+			msg: "zero sampling percentage",
+		},
+	}
+
+	cfgParams := &ConfigParams{MainOpenTracingLoadModule: true}
+
+	for _, test := range tests {
+		vs.Spec.Tracing = test.tracing
+		vsc := newVirtualServerConfigurator(cfgParams, false, false)
+
+		tracing, splitClients := vsc.generateTracing(vs, newVariableNamer(vs))
+		if !reflect.DeepEqual(tracing, test.expectedTracing) {
+			t.Errorf("generateTracing() returned tracing %+v but expected %+v for the case of %s", tracing, test.expectedTracing, test.msg)
+		}
+		if !reflect.DeepEqual(splitClients, test.expectedSplitClients) {
+			t.Errorf("generateTracing() returned split clients %+v but expected %+v for the case of %s", splitClients, test.expectedSplitClients, test.msg)
+		}
+		if len(vsc.warnings) != 0 {
+			t.Errorf("generateTracing() returned warnings %v for the case of %s", vsc.warnings, test.msg)
+		}
+	}
+}
+
+func TestGenerateTracingWithoutModule(t *testing.T) {
+	vs := &conf_v1.VirtualServer{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "cafe",
+			Namespace: "default",
+		},
+		Spec: conf_v1.VirtualServerSpec{
+			Tracing: &conf_v1.Tracing{Enable: true},
+		},
+	}
+
+	vsc := newVirtualServerConfigurator(&ConfigParams{}, false, false)
+
+	tracing, splitClients := vsc.generateTracing(vs, newVariableNamer(vs))
+	if tracing != nil || splitClients != nil {
+		t.Errorf("generateTracing() returned %+v and %+v but expected nil when the OpenTracing module is not loaded", tracing, splitClients)
+	}
+	if len(vsc.warnings[vs]) != 1 {
+		t.Errorf("generateTracing() returned warnings %v but expected one warning for the VirtualServer", vsc.warnings)
+	}
+}
+
+func TestGenerateTLSRedirectBasedOn(t *testing.T) {
+	tests := []struct {
+		basedOn  string
+		expected string
+	}{
+		{
+			basedOn:  "scheme",
+			expected: "$scheme",
+		},
+		{
+			basedOn:  "x-forwarded-proto",
+			expected: "$http_x_forwarded_proto",
+		},
+		{
+			basedOn:  "",
+			expected: "$scheme",
+		},
+	}
+	for _, test := range tests {
+		result := generateTLSRedirectBasedOn(test.basedOn)
+		if result != test.expected {
+			t.Errorf("generateTLSRedirectBasedOn(%v) returned %v but expected %v", test.basedOn, result, test.expected)
+		}
+	}
+}
+
+func TestCreateUpstreamsForPlus(t *testing.T) {
+	virtualServerEx := VirtualServerEx{
+		VirtualServer: &conf_v1.VirtualServer{
+			ObjectMeta: meta_v1.ObjectMeta{
+				Name:      "cafe",
+				Namespace: "default",
+			},
+			Spec: conf_v1.VirtualServerSpec{
+				Host: "cafe.example.com",
+				Upstreams: []conf_v1.Upstream{
 					{
-						Value:  `"no"`,
-						Result: "$vs_default_cafe_matches_1_match_1_cond_3",
+						Name:    "tea",
+						Service: "tea-svc",
+						Port:    80,
 					},
 					{
-						Value:  "default",
-						Result: "0",
+						Name:    "test",
+						Service: "test-svc",
+						Port:    80,
 					},
-				},
-			},
-			{
-				Source:   "$request_method",
-				Variable: "$vs_default_cafe_matches_1_match_1_cond_3",
-				Parameters: []version2.Parameter{
 					{
-						Value:  `"POST"`,
-						Result: "1",
+						Name:        "subselector-test",
+						Service:     "test-svc",
+						Subselector: map[string]string{"vs": "works"},
+						Port:        80,
 					},
 					{
-						Value:  "default",
-						Result: "0",
+						Name:    "external",
+						Service: "external-svc",
+						Port:    80,
 					},
 				},
-			},
-			{
-				Source:   "$vs_default_cafe_matches_1_match_0_cond_0$vs_default_cafe_matches_1_match_1_cond_0",
-				Variable: "$vs_default_cafe_matches_1",
-				Parameters: []version2.Parameter{
+				Routes: []conf_v1.Route{
 					{
-						Value:  "~^1",
-						Result: "@matches_1_match_0",
+						Path: "/tea",
+						Action: &conf_v1.Action{
+							Pass: "tea",
+						},
 					},
 					{
-						Value:  "~^01",
-						Result: "$vs_default_cafe_splits_2",
+						Path:  "/coffee",
+						Route: "default/coffee",
 					},
 					{
-						Value:  "default",
-						Result: "@matches_1_default",
+						Path: "/external",
+						Action: &conf_v1.Action{
+							Pass: "external",
+						},
 					},
 				},
 			},
 		},
-		Locations: []version2.Location{
-			{
-				Path:                     "@matches_1_match_0",
-				ProxyPass:                "http://vs_default_cafe_coffee-v1",
-				ProxyNextUpstream:        "error timeout",
-				ProxyNextUpstreamTimeout: "0s",
-				ProxyNextUpstreamTries:   0,
+		Endpoints: map[string][]string{
+			"default/tea-svc:80": {
+				"10.0.0.20:80",
 			},
-			{
-				Path:                     "@splits_2_split_0",
-				ProxyPass:                "http://vs_default_cafe_coffee-v1",
-				ProxyNextUpstream:        "error timeout",
-				ProxyNextUpstreamTimeout: "0s",
-				ProxyNextUpstreamTries:   0,
+			"default/test-svc:80": {},
+			"default/test-svc_vs=works:80": {
+				"10.0.0.30:80",
 			},
-			{
-				Path:                     "@splits_2_split_1",
-				ProxyPass:                "http://vs_default_cafe_coffee-v2",
-				ProxyNextUpstream:        "error timeout",
-				ProxyNextUpstreamTimeout: "0s",
-				ProxyNextUpstreamTries:   0,
+			"default/coffee-svc:80": {
+				"10.0.0.40:80",
 			},
-			{
-				Path:                     "@matches_1_default",
-				ProxyPass:                "http://vs_default_cafe_tea",
-				ProxyNextUpstream:        "error timeout",
-				ProxyNextUpstreamTimeout: "0s",
-				ProxyNextUpstreamTries:   0,
+			"default/test-svc_vsr=works:80": {
+				"10.0.0.50:80",
+			},
+			"default/external-svc:80": {
+				"example.com:80",
 			},
 		},
-		InternalRedirectLocation: version2.InternalRedirectLocation{
-			Path:        "/",
-			Destination: "$vs_default_cafe_matches_1",
+		ExternalNameSvcs: map[string]bool{
+			"default/external-svc": true,
 		},
-		SplitClients: []version2.SplitClient{
+		VirtualServerRoutes: []*conf_v1.VirtualServerRoute{
 			{
-				Source:   "$request_id",
-				Variable: "$vs_default_cafe_splits_2",
-				Distributions: []version2.Distribution{
-					{
-						Weight: "90%",
-						Value:  "@splits_2_split_0",
+				ObjectMeta: meta_v1.ObjectMeta{
+					Name:      "coffee",
+					Namespace: "default",
+				},
+				Spec: conf_v1.VirtualServerRouteSpec{
+					Host: "cafe.example.com",
+					Upstreams: []conf_v1.Upstream{
+						{
+							Name:    "coffee",
+							Service: "coffee-svc",
+							Port:    80,
+						},
+						{
+							Name:        "subselector-test",
+							Service:     "test-svc",
+							Subselector: map[string]string{"vsr": "works"},
+							Port:        80,
+						},
 					},
-					{
-						Weight: "10%",
-						Value:  "@splits_2_split_1",
+					Subroutes: []conf_v1.Route{
+						{
+							Path: "/coffee",
+							Action: &conf_v1.Action{
+								Pass: "coffee",
+							},
+						},
+						{
+							Path: "/coffee/sub",
+							Action: &conf_v1.Action{
+								Pass: "subselector-test",
+							},
+						},
 					},
 				},
 			},
 		},
 	}
 
-	cfgParams := ConfigParams{}
+	expected := []version2.Upstream{
+		{
+			Name: "vs_default_cafe_tea",
+			Servers: []version2.UpstreamServer{
+				{
+					Address: "10.0.0.20:80",
+				},
+			},
+		},
+		{
+			Name:    "vs_default_cafe_test",
+			Servers: nil,
+		},
+		{
+			Name: "vs_default_cafe_subselector-test",
+			Servers: []version2.UpstreamServer{
+				{
+					Address: "10.0.0.30:80",
+				},
+			},
+		},
+		{
+			Name: "vs_default_cafe_vsr_default_coffee_coffee",
+			Servers: []version2.UpstreamServer{
+				{
+					Address: "10.0.0.40:80",
+				},
+			},
+		},
+		{
+			Name: "vs_default_cafe_vsr_default_coffee_subselector-test",
+			Servers: []version2.UpstreamServer{
+				{
+					Address: "10.0.0.50:80",
+				},
+			},
+		},
+	}
 
-	result := generateMatchesConfig(route, upstreamNamer, map[string]conf_v1.Upstream{}, variableNamer, index, scIndex, &cfgParams)
+	result := createUpstreamsForPlus(&virtualServerEx, &ConfigParams{}, UpstreamNamingScheme{})
 	if !reflect.DeepEqual(result, expected) {
-		t.Errorf("generateMatchesConfig() returned \n%v but expected \n%v", result, expected)
+		t.Errorf("createUpstreamsForPlus returned \n%v but expected \n%v", result, expected)
 	}
 }
 
-func TestGenerateMatchesConfigWithMultipleSplits(t *testing.T) {
-	route := conf_v1.Route{
-		Path: "/",
-		Matches: []conf_v1.Match{
+func TestCreateUpstreamServersConfigForPlus(t *testing.T) {
+	upstream := version2.Upstream{
+		Servers: []version2.UpstreamServer{
 			{
-				Conditions: []conf_v1.Condition{
-					{
-						Header: "x-version",
-						Value:  "v1",
-					},
-				},
-				Splits: []conf_v1.Split{
-					{
-						Weight: 30,
-						Action: &conf_v1.Action{
-							Pass: "coffee-v1",
-						},
-					},
-					{
-						Weight: 70,
-						Action: &conf_v1.Action{
-							Pass: "coffee-v2",
-						},
-					},
-				},
+				Address: "10.0.0.20:80",
 			},
+		},
+		MaxFails:    21,
+		MaxConns:    16,
+		FailTimeout: "30s",
+		SlowStart:   "50s",
+	}
+
+	expected := nginx.ServerConfig{
+		MaxFails:    21,
+		MaxConns:    16,
+		FailTimeout: "30s",
+		SlowStart:   "50s",
+	}
+
+	result := createUpstreamServersConfigForPlus(upstream)
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("createUpstreamServersConfigForPlus returned %v but expected %v", result, expected)
+	}
+}
+
+func TestCreateUpstreamServersConfigForPlusWithDrainedAndDownServers(t *testing.T) {
+	upstream := version2.Upstream{
+		Servers: []version2.UpstreamServer{
 			{
-				Conditions: []conf_v1.Condition{
-					{
-						Header: "x-version",
-						Value:  "v2",
-					},
-				},
-				Splits: []conf_v1.Split{
-					{
-						Weight: 90,
-						Action: &conf_v1.Action{
-							Pass: "coffee-v2",
-						},
-					},
-					{
-						Weight: 10,
-						Action: &conf_v1.Action{
-							Pass: "coffee-v1",
-						},
-					},
-				},
+				Address: "10.0.0.20:80",
+			},
+			{
+				Address: "10.0.0.21:80",
+				Drain:   true,
+			},
+			{
+				Address: "10.0.0.22:80",
+				Down:    true,
+			},
+		},
+		MaxFails:    21,
+		MaxConns:    16,
+		FailTimeout: "30s",
+		SlowStart:   "50s",
+	}
+
+	expected := nginx.ServerConfig{
+		MaxFails:    21,
+		MaxConns:    16,
+		FailTimeout: "30s",
+		SlowStart:   "50s",
+		DrainedServers: map[string]bool{
+			"10.0.0.21:80": true,
+		},
+		DownServers: map[string]bool{
+			"10.0.0.22:80": true,
+		},
+	}
+
+	result := createUpstreamServersConfigForPlus(upstream)
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("createUpstreamServersConfigForPlus returned %v but expected %v", result, expected)
+	}
+}
+
+func TestCreateUpstreamServersConfigForPlusNoUpstreams(t *testing.T) {
+	noUpstream := version2.Upstream{}
+	expected := nginx.ServerConfig{}
+
+	result := createUpstreamServersConfigForPlus(noUpstream)
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("createUpstreamServersConfigForPlus returned %v but expected %v", result, expected)
+	}
+}
+
+func TestGenerateSplits(t *testing.T) {
+	splits := []conf_v1.Split{
+		{
+			Weight: 90,
+			Action: &conf_v1.Action{
+				Pass: "coffee-v1",
+			},
+		},
+		{
+			Weight: 10,
+			Action: &conf_v1.Action{
+				Pass: "coffee-v2",
+			},
+		},
+	}
+
+	virtualServer := conf_v1.VirtualServer{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "cafe",
+			Namespace: "default",
+		},
+	}
+	upstreamNamer := newUpstreamNamerForVirtualServer(&virtualServer, UpstreamNamingScheme{})
+	variableNamer := newVariableNamer(&virtualServer)
+	scID := "1"
+	cfgParams := ConfigParams{}
+	crUpstreams := make(map[string]conf_v1.Upstream)
+
+	expectedSplitClient := version2.SplitClient{
+		Source:   "$request_id",
+		Variable: "$vs_default_cafe_splits_1",
+		Distributions: []version2.Distribution{
+			{
+				Weight: "90%",
+				Value:  "@splits_1_split_0",
+			},
+			{
+				Weight: "10%",
+				Value:  "@splits_1_split_1",
+			},
+		},
+	}
+	expectedLocations := []version2.Location{
+		{
+			Path:                     "@splits_1_split_0",
+			ProxyPass:                "http://vs_default_cafe_coffee-v1",
+			ProxyNextUpstream:        "error timeout",
+			ProxyNextUpstreamTimeout: "0s",
+			ProxyNextUpstreamTries:   0,
+		},
+		{
+			Path:                     "@splits_1_split_1",
+			ProxyPass:                "http://vs_default_cafe_coffee-v2",
+			ProxyNextUpstream:        "error timeout",
+			ProxyNextUpstreamTimeout: "0s",
+			ProxyNextUpstreamTries:   0,
+		},
+	}
+
+	resultSplitClient, resultLocations := generateSplits(splits, upstreamNamer, crUpstreams, variableNamer, scID, &cfgParams)
+	if !reflect.DeepEqual(resultSplitClient, expectedSplitClient) {
+		t.Errorf("generateSplits() returned %v but expected %v", resultSplitClient, expectedSplitClient)
+	}
+	if !reflect.DeepEqual(resultLocations, expectedLocations) {
+		t.Errorf("generateSplits() returned %v but expected %v", resultLocations, expectedLocations)
+	}
+}
+
+func TestGenerateSplitsWithZeroWeight(t *testing.T) {
+	splits := []conf_v1.Split{
+		{
+			Weight: 100,
+			Action: &conf_v1.Action{
+				Pass: "coffee-v1",
 			},
 		},
+		{
+			Weight: 0,
+			Action: &conf_v1.Action{
+				Pass: "coffee-v2",
+			},
+		},
+	}
+
+	virtualServer := conf_v1.VirtualServer{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "cafe",
+			Namespace: "default",
+		},
+	}
+	upstreamNamer := newUpstreamNamerForVirtualServer(&virtualServer, UpstreamNamingScheme{})
+	variableNamer := newVariableNamer(&virtualServer)
+
+	expectedDistributions := []version2.Distribution{
+		{
+			Weight: "100%",
+			Value:  "@splits_1_split_0",
+		},
+	}
+	expectedPaths := []string{"@splits_1_split_0", "@splits_1_split_1"}
+
+	resultSplitClient, resultLocations := generateSplits(splits, upstreamNamer, map[string]conf_v1.Upstream{}, variableNamer, "1", &ConfigParams{})
+	if !reflect.DeepEqual(resultSplitClient.Distributions, expectedDistributions) {
+		t.Errorf("generateSplits() returned distributions %v but expected %v", resultSplitClient.Distributions, expectedDistributions)
+	}
+
+	var paths []string
+	for _, loc := range resultLocations {
+		paths = append(paths, loc.Path)
+	}
+	if !reflect.DeepEqual(paths, expectedPaths) {
+		t.Errorf("generateSplits() returned locations %v but expected %v", paths, expectedPaths)
+	}
+
+	persistence := &conf_v1.SplitsPersistence{
+		Cookie: "canary",
+	}
+	expectedParameters := []version2.Parameter{
+		{
+			Value:  `"0-0"`,
+			Result: "@splits_1_split_0",
+		},
+		{
+			Value:  "default",
+			Result: "$vs_default_cafe_splits_1",
+		},
+	}
+
+	persistenceMap := generateSplitsPersistence(persistence, "/", 0, resultSplitClient, resultLocations, "$vs_default_cafe_splits_1_persistence")
+	if !reflect.DeepEqual(persistenceMap.Parameters, expectedParameters) {
+		t.Errorf("generateSplitsPersistence() returned parameters %v but expected %v", persistenceMap.Parameters, expectedParameters)
+	}
+	if len(resultLocations[1].AddHeaders) != 0 {
+		t.Errorf("generateSplitsPersistence() set the cookie %v for the split with the weight 0", resultLocations[1].AddHeaders)
+	}
+}
+
+func TestAddSplitsCacheToLocations(t *testing.T) {
+	splitsCache := &conf_v1.SplitsCache{
+		NoStore: true,
+		Vary:    []string{"Cookie", "User-Agent"},
+	}
+	locations := []version2.Location{
+		{
+			Path: "@splits_0_split_0",
+		},
+		{
+			Path: "@splits_0_split_1",
+		},
+	}
+
+	expectedHideHeaders := []string{"Cache-Control"}
+	expectedAddHeaders := []version2.AddHeader{
+		{
+			Name:  "Cache-Control",
+			Value: "no-store",
+		},
+		{
+			Name:  "Vary",
+			Value: "Cookie, User-Agent",
+		},
+	}
+
+	addSplitsCacheToLocations(splitsCache, locations)
+
+	for _, loc := range locations {
+		if !reflect.DeepEqual(loc.ProxyHideHeaders, expectedHideHeaders) {
+			t.Errorf("addSplitsCacheToLocations() set hidden headers %v but expected %v for location %s", loc.ProxyHideHeaders, expectedHideHeaders, loc.Path)
+		}
+		if !reflect.DeepEqual(loc.AddHeaders, expectedAddHeaders) {
+			t.Errorf("addSplitsCacheToLocations() set added headers %v but expected %v for location %s", loc.AddHeaders, expectedAddHeaders, loc.Path)
+		}
+	}
+}
+
+func TestAddIgnoreHeadersToLocations(t *testing.T) {
+	locations := []version2.Location{
+		{
+			Path: "@matches_0_match_0",
+		},
+		{
+			Path: "@matches_0_default",
+		},
+	}
+
+	expected := "X-Accel-Expires Cache-Control"
+
+	addIgnoreHeadersToLocations([]string{"X-Accel-Expires", "Cache-Control"}, locations)
+
+	for _, loc := range locations {
+		if loc.ProxyIgnoreHeaders != expected {
+			t.Errorf("addIgnoreHeadersToLocations() set ignored headers %q but expected %q for location %s", loc.ProxyIgnoreHeaders, expected, loc.Path)
+		}
+	}
+}
+
+func TestGenerateDefaultSplitsConfig(t *testing.T) {
+	route := conf_v1.Route{
+		Path: "/",
 		Splits: []conf_v1.Split{
 			{
-				Weight: 99,
+				Weight: 90,
 				Action: &conf_v1.Action{
 					Pass: "coffee-v1",
 				},
 			},
 			{
-				Weight: 1,
+				Weight: 10,
 				Action: &conf_v1.Action{
 					Pass: "coffee-v2",
 				},
@@ -2030,98 +2838,37 @@ func TestGenerateMatchesConfigWithMultipleSplits(t *testing.T) {
 			Namespace: "default",
 		},
 	}
-	upstreamNamer := newUpstreamNamerForVirtualServer(&virtualServer)
+	upstreamNamer := newUpstreamNamerForVirtualServer(&virtualServer, UpstreamNamingScheme{})
 	variableNamer := newVariableNamer(&virtualServer)
-	index := 1
-	scIndex := 2
+	scID := "1"
 
 	expected := routingCfg{
-		Maps: []version2.Map{
+		SplitClients: []version2.SplitClient{
 			{
-				Source:   "$http_x_version",
-				Variable: "$vs_default_cafe_matches_1_match_0_cond_0",
-				Parameters: []version2.Parameter{
+				Source:   "$request_id",
+				Variable: "$vs_default_cafe_splits_1",
+				Distributions: []version2.Distribution{
 					{
-						Value:  `"v1"`,
-						Result: "1",
+						Weight: "90%",
+						Value:  "@splits_1_split_0",
 					},
 					{
-						Value:  "default",
-						Result: "0",
+						Weight: "10%",
+						Value:  "@splits_1_split_1",
 					},
 				},
 			},
+		},
+		Locations: []version2.Location{
 			{
-				Source:   "$http_x_version",
-				Variable: "$vs_default_cafe_matches_1_match_1_cond_0",
-				Parameters: []version2.Parameter{
-					{
-						Value:  `"v2"`,
-						Result: "1",
-					},
-					{
-						Value:  "default",
-						Result: "0",
-					},
-				},
-			},
-			{
-				Source:   "$vs_default_cafe_matches_1_match_0_cond_0$vs_default_cafe_matches_1_match_1_cond_0",
-				Variable: "$vs_default_cafe_matches_1",
-				Parameters: []version2.Parameter{
-					{
-						Value:  "~^1",
-						Result: "$vs_default_cafe_splits_2",
-					},
-					{
-						Value:  "~^01",
-						Result: "$vs_default_cafe_splits_3",
-					},
-					{
-						Value:  "default",
-						Result: "$vs_default_cafe_splits_4",
-					},
-				},
-			},
-		},
-		Locations: []version2.Location{
-			{
-				Path:                     "@splits_2_split_0",
-				ProxyPass:                "http://vs_default_cafe_coffee-v1",
-				ProxyNextUpstream:        "error timeout",
-				ProxyNextUpstreamTimeout: "0s",
-				ProxyNextUpstreamTries:   0,
-			},
-			{
-				Path:                     "@splits_2_split_1",
-				ProxyPass:                "http://vs_default_cafe_coffee-v2",
-				ProxyNextUpstream:        "error timeout",
-				ProxyNextUpstreamTimeout: "0s",
-				ProxyNextUpstreamTries:   0,
-			},
-			{
-				Path:                     "@splits_3_split_0",
-				ProxyPass:                "http://vs_default_cafe_coffee-v2",
-				ProxyNextUpstream:        "error timeout",
-				ProxyNextUpstreamTimeout: "0s",
-				ProxyNextUpstreamTries:   0,
-			},
-			{
-				Path:                     "@splits_3_split_1",
-				ProxyPass:                "http://vs_default_cafe_coffee-v1",
-				ProxyNextUpstream:        "error timeout",
-				ProxyNextUpstreamTimeout: "0s",
-				ProxyNextUpstreamTries:   0,
-			},
-			{
-				Path:                     "@splits_4_split_0",
+				Path:                     "@splits_1_split_0",
 				ProxyPass:                "http://vs_default_cafe_coffee-v1",
 				ProxyNextUpstream:        "error timeout",
 				ProxyNextUpstreamTimeout: "0s",
 				ProxyNextUpstreamTries:   0,
 			},
 			{
-				Path:                     "@splits_4_split_1",
+				Path:                     "@splits_1_split_1",
 				ProxyPass:                "http://vs_default_cafe_coffee-v2",
 				ProxyNextUpstream:        "error timeout",
 				ProxyNextUpstreamTimeout: "0s",
@@ -2130,850 +2877,4976 @@ func TestGenerateMatchesConfigWithMultipleSplits(t *testing.T) {
 		},
 		InternalRedirectLocation: version2.InternalRedirectLocation{
 			Path:        "/",
-			Destination: "$vs_default_cafe_matches_1",
-		},
-		SplitClients: []version2.SplitClient{
-			{
-				Source:   "$request_id",
-				Variable: "$vs_default_cafe_splits_2",
-				Distributions: []version2.Distribution{
-					{
-						Weight: "30%",
-						Value:  "@splits_2_split_0",
-					},
-					{
-						Weight: "70%",
-						Value:  "@splits_2_split_1",
-					},
-				},
-			},
-			{
-				Source:   "$request_id",
-				Variable: "$vs_default_cafe_splits_3",
-				Distributions: []version2.Distribution{
-					{
-						Weight: "90%",
-						Value:  "@splits_3_split_0",
-					},
-					{
-						Weight: "10%",
-						Value:  "@splits_3_split_1",
-					},
-				},
-			},
-			{
-				Source:   "$request_id",
-				Variable: "$vs_default_cafe_splits_4",
-				Distributions: []version2.Distribution{
-					{
-						Weight: "99%",
-						Value:  "@splits_4_split_0",
-					},
-					{
-						Weight: "1%",
-						Value:  "@splits_4_split_1",
-					},
-				},
-			},
+			Destination: "$vs_default_cafe_splits_1",
 		},
 	}
 
 	cfgParams := ConfigParams{}
 
-	result := generateMatchesConfig(route, upstreamNamer, map[string]conf_v1.Upstream{}, variableNamer, index, scIndex, &cfgParams)
+	result := generateDefaultSplitsConfig(route, upstreamNamer, map[string]conf_v1.Upstream{}, variableNamer, scID, &cfgParams, false)
 	if !reflect.DeepEqual(result, expected) {
-		t.Errorf("generateMatchesConfig() returned \n%v but expected \n%v", result, expected)
+		t.Errorf("generateDefaultSplitsConfig() returned %v but expected %v", result, expected)
 	}
 }
 
-func TestGenerateValueForMatchesRouteMap(t *testing.T) {
-	tests := []struct {
-		input              string
-		expectedValue      string
-		expectedIsNegative bool
-	}{
-		{
-			input:              "default",
-			expectedValue:      `\default`,
-			expectedIsNegative: false,
-		},
-		{
-			input:              "!default",
-			expectedValue:      `\default`,
-			expectedIsNegative: true,
-		},
-		{
-			input:              "hostnames",
-			expectedValue:      `\hostnames`,
-			expectedIsNegative: false,
+func TestGenerateSplitsPersistence(t *testing.T) {
+	sc := version2.SplitClient{
+		Source:   "$request_id",
+		Variable: "$vs_default_cafe_splits_1",
+		Distributions: []version2.Distribution{
+			{
+				Weight: "90%",
+				Value:  "@splits_1_split_0",
+			},
+			{
+				Weight: "10%",
+				Value:  "@splits_1_split_1",
+			},
 		},
+	}
+	sharedHeaders := []version2.AddHeader{
 		{
-			input:              "include",
-			expectedValue:      `\include`,
-			expectedIsNegative: false,
+			Name:  "Cache-Control",
+			Value: "no-store",
 		},
+	}
+	locations := []version2.Location{
 		{
-			input:              "volatile",
-			expectedValue:      `\volatile`,
-			expectedIsNegative: false,
+			Path:       "@splits_1_split_0",
+			AddHeaders: sharedHeaders,
 		},
 		{
-			input:              "abc",
-			expectedValue:      `"abc"`,
-			expectedIsNegative: false,
+			Path:       "@splits_1_split_1",
+			AddHeaders: sharedHeaders,
 		},
-		{
-			input:              "!abc",
-			expectedValue:      `"abc"`,
-			expectedIsNegative: true,
+	}
+	persistence := &conf_v1.SplitsPersistence{
+		Cookie: "canary",
+		TTL:    "1h",
+	}
+
+	expectedMap := version2.Map{
+		Source:   "$cookie_canary",
+		Variable: "$vs_default_cafe_splits_1_persistence",
+		Parameters: []version2.Parameter{
+			{
+				Value:  `"1-0"`,
+				Result: "@splits_1_split_0",
+			},
+			{
+				Value:  `"1-1"`,
+				Result: "@splits_1_split_1",
+			},
+			{
+				Value:  "default",
+				Result: "$vs_default_cafe_splits_1",
+			},
 		},
+	}
+	expectedAddHeaders := [][]version2.AddHeader{
 		{
-			input:              "",
-			expectedValue:      `""`,
-			expectedIsNegative: false,
+			{
+				Name:  "Cache-Control",
+				Value: "no-store",
+			},
+			{
+				Name:  "Set-Cookie",
+				Value: "canary=1-0; Path=/coffee; Max-Age=3600",
+			},
 		},
 		{
-			input:              "!",
-			expectedValue:      `""`,
-			expectedIsNegative: true,
+			{
+				Name:  "Cache-Control",
+				Value: "no-store",
+			},
+			{
+				Name:  "Set-Cookie",
+				Value: "canary=1-1; Path=/coffee; Max-Age=3600",
+			},
 		},
 	}
 
-	for _, test := range tests {
-		resultValue, resultIsNegative := generateValueForMatchesRouteMap(test.input)
-		if resultValue != test.expectedValue {
-			t.Errorf("generateValueForMatchesRouteMap(%q) returned %q but expected %q as the value", test.input, resultValue, test.expectedValue)
-		}
-		if resultIsNegative != test.expectedIsNegative {
-			t.Errorf("generateValueForMatchesRouteMap(%q) returned %v but expected %v as the isNegative", test.input, resultIsNegative, test.expectedIsNegative)
+	result := generateSplitsPersistence(persistence, "/coffee", 1, sc, locations, "$vs_default_cafe_splits_1_persistence")
+	if !reflect.DeepEqual(result, expectedMap) {
+		t.Errorf("generateSplitsPersistence() returned %v but expected %v", result, expectedMap)
+	}
+	for i, loc := range locations {
+		if !reflect.DeepEqual(loc.AddHeaders, expectedAddHeaders[i]) {
+			t.Errorf("generateSplitsPersistence() set added headers %v but expected %v for location %s", loc.AddHeaders, expectedAddHeaders[i], loc.Path)
 		}
 	}
-}
 
-func TestGenerateParametersForMatchesRouteMap(t *testing.T) {
-	tests := []struct {
-		inputMatchedValue     string
-		inputSuccessfulResult string
-		expected              []version2.Parameter
-	}{
+	// the cookie of a regex path applies to all paths and expires with the session without a TTL
+	persistence = &conf_v1.SplitsPersistence{
+		Cookie: "canary",
+	}
+	locations = []version2.Location{
 		{
-			inputMatchedValue:     "abc",
-			inputSuccessfulResult: "1",
-			expected: []version2.Parameter{
-				{
-					Value:  `"abc"`,
-					Result: "1",
-				},
-				{
-					Value:  "default",
-					Result: "0",
-				},
-			},
+			Path: "@splits_1_split_0",
 		},
 		{
-			inputMatchedValue:     "!abc",
-			inputSuccessfulResult: "1",
-			expected: []version2.Parameter{
-				{
-					Value:  `"abc"`,
-					Result: "0",
-				},
-				{
-					Value:  "default",
-					Result: "1",
-				},
-			},
+			Path: "@splits_1_split_1",
 		},
 	}
+	expectedCookie := "canary=0-1; Path=/"
 
-	for _, test := range tests {
-		result := generateParametersForMatchesRouteMap(test.inputMatchedValue, test.inputSuccessfulResult)
-		if !reflect.DeepEqual(result, test.expected) {
-			t.Errorf("generateParametersForMatchesRouteMap(%q, %q) returned %v but expected %v", test.inputMatchedValue, test.inputSuccessfulResult, result, test.expected)
-		}
+	generateSplitsPersistence(persistence, "~ ^/coffee", 0, sc, locations, "$vs_default_cafe_splits_1_persistence")
+	if cookie := locations[1].AddHeaders[0].Value; cookie != expectedCookie {
+		t.Errorf("generateSplitsPersistence() set the cookie %q but expected %q", cookie, expectedCookie)
 	}
 }
 
-func TestGetNameForSourceForMatchesRouteMapFromCondition(t *testing.T) {
-	tests := []struct {
-		input    conf_v1.Condition
-		expected string
-	}{
-		{
-			input: conf_v1.Condition{
-				Header: "x-version",
+func TestGenerateDefaultSplitsConfigWithPersistence(t *testing.T) {
+	route := conf_v1.Route{
+		Path: "/",
+		Splits: []conf_v1.Split{
+			{
+				Weight: 90,
+				Action: &conf_v1.Action{
+					Pass: "coffee-v1",
+				},
 			},
-			expected: "$http_x_version",
-		},
-		{
-			input: conf_v1.Condition{
-				Cookie: "mycookie",
+			{
+				Weight: 10,
+				Action: &conf_v1.Action{
+					Pass: "coffee-v2",
+				},
 			},
-			expected: "$cookie_mycookie",
 		},
-		{
-			input: conf_v1.Condition{
-				Argument: "arg",
-			},
-			expected: "$arg_arg",
+		SplitsPersistence: &conf_v1.SplitsPersistence{
+			Cookie: "canary",
 		},
-		{
-			input: conf_v1.Condition{
-				Variable: "$request_method",
-			},
-			expected: "$request_method",
+	}
+	virtualServer := conf_v1.VirtualServer{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "cafe",
+			Namespace: "default",
 		},
 	}
+	upstreamNamer := newUpstreamNamerForVirtualServer(&virtualServer, UpstreamNamingScheme{})
+	variableNamer := newVariableNamer(&virtualServer)
 
-	for _, test := range tests {
-		result := getNameForSourceForMatchesRouteMapFromCondition(test.input)
-		if result != test.expected {
-			t.Errorf("getNameForSourceForMatchesRouteMapFromCondition() returned %q but expected %q for input %v", result, test.expected, test.input)
-		}
+	result := generateDefaultSplitsConfig(route, upstreamNamer, map[string]conf_v1.Upstream{}, variableNamer, "1", &ConfigParams{}, false)
+
+	expectedDestination := "$vs_default_cafe_splits_1_persistence"
+	if result.InternalRedirectLocation.Destination != expectedDestination {
+		t.Errorf("generateDefaultSplitsConfig() returned the destination %q but expected %q", result.InternalRedirectLocation.Destination, expectedDestination)
+	}
+	if len(result.Maps) != 1 || result.Maps[0].Variable != expectedDestination {
+		t.Errorf("generateDefaultSplitsConfig() returned maps %v but expected the map of the persistence", result.Maps)
 	}
 }
 
-func TestGenerateLBMethod(t *testing.T) {
-	defaultMethod := "random two least_conn"
-
-	tests := []struct {
-		input    string
-		expected string
-	}{
-		{
-			input:    "",
-			expected: defaultMethod,
+func TestGenerateDefaultSplitsConfigWithDynamicWeights(t *testing.T) {
+	route := conf_v1.Route{
+		Path: "/",
+		Splits: []conf_v1.Split{
+			{
+				Weight: 90,
+				Action: &conf_v1.Action{
+					Pass: "coffee-v1",
+				},
+			},
+			{
+				Weight: 10,
+				Action: &conf_v1.Action{
+					Pass: "coffee-v2",
+				},
+			},
 		},
-		{
-			input:    "round_robin",
-			expected: "",
+		SplitsDynamicWeights: true,
+	}
+	virtualServer := conf_v1.VirtualServer{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "cafe",
+			Namespace: "default",
 		},
+	}
+	upstreamNamer := newUpstreamNamerForVirtualServer(&virtualServer, UpstreamNamingScheme{})
+	variableNamer := newVariableNamer(&virtualServer)
+
+	expectedKeyVals := []version2.KeyVal{
 		{
-			input:    "random",
-			expected: "random",
+			Key:      "vs_default_cafe_splits_1",
+			Variable: "$vs_default_cafe_splits_1_weight",
+			ZoneName: "vs_default_cafe_split_weights",
 		},
 	}
-	for _, test := range tests {
-		result := generateLBMethod(test.input, defaultMethod)
-		if result != test.expected {
-			t.Errorf("generateLBMethod() returned %q but expected %q for input '%v'", result, test.expected, test.input)
-		}
+	expectedDestination := "$vs_default_cafe_splits_1_dynamic"
+	expectedSplitClient := version2.SplitClient{
+		Source:   "$request_id",
+		Variable: "$vs_default_cafe_splits_1_weight_30",
+		Distributions: []version2.Distribution{
+			{
+				Weight: "30%",
+				Value:  "@splits_1_split_0",
+			},
+			{
+				Weight: "70%",
+				Value:  "@splits_1_split_1",
+			},
+		},
 	}
-}
 
-func TestUpstreamHasKeepalive(t *testing.T) {
-	noKeepalive := 0
-	keepalive := 32
+	result := generateDefaultSplitsConfig(route, upstreamNamer, map[string]conf_v1.Upstream{}, variableNamer, "1", &ConfigParams{}, true)
 
-	tests := []struct {
-		upstream  conf_v1.Upstream
-		cfgParams *ConfigParams
-		expected  bool
-		msg       string
-	}{
+	if !reflect.DeepEqual(result.KeyVals, expectedKeyVals) {
+		t.Errorf("generateDefaultSplitsConfig() returned keyvals %v but expected %v", result.KeyVals, expectedKeyVals)
+	}
+	if result.InternalRedirectLocation.Destination != expectedDestination {
+		t.Errorf("generateDefaultSplitsConfig() returned the destination %q but expected %q", result.InternalRedirectLocation.Destination, expectedDestination)
+	}
+	if len(result.SplitClients) != 1 {
+		t.Errorf("generateDefaultSplitsConfig() returned %d split clients but expected 1", len(result.SplitClients))
+	}
+	if len(result.DynamicSplitClients) != 99 {
+		t.Fatalf("generateDefaultSplitsConfig() returned %d dynamic split clients but expected 99", len(result.DynamicSplitClients))
+	}
+	if !reflect.DeepEqual(result.DynamicSplitClients[29], expectedSplitClient) {
+		t.Errorf("generateDefaultSplitsConfig() returned the split client %v but expected %v", result.DynamicSplitClients[29], expectedSplitClient)
+	}
+
+	dynamicMap := result.Maps[0]
+	if dynamicMap.Source != "$vs_default_cafe_splits_1_weight" || dynamicMap.Variable != expectedDestination {
+		t.Errorf("generateDefaultSplitsConfig() returned the map %v of the dynamic weights", dynamicMap)
+	}
+
+	expectedEdgeParams := []version2.Parameter{
 		{
-			conf_v1.Upstream{},
-			&ConfigParams{Keepalive: keepalive},
-			true,
-			"upstream keepalive not set, configparam keepalive set",
+			Value:  `"0"`,
+			Result: "@splits_1_split_1",
 		},
 		{
-			conf_v1.Upstream{Keepalive: &noKeepalive},
-			&ConfigParams{Keepalive: keepalive},
-			false,
-			"upstream keepalive set to 0, configparam keepive set",
+			Value:  `"100"`,
+			Result: "@splits_1_split_0",
 		},
 		{
-			conf_v1.Upstream{Keepalive: &keepalive},
-			&ConfigParams{Keepalive: noKeepalive},
-			true,
-			"upstream keepalive set, configparam keepalive set to 0",
+			Value:  "default",
+			Result: "$vs_default_cafe_splits_1",
 		},
 	}
-
-	for _, test := range tests {
-		result := upstreamHasKeepalive(test.upstream, test.cfgParams)
-		if result != test.expected {
-			t.Errorf("upstreamHasKeepalive() returned %v, but expected %v for the case of %v", result, test.expected, test.msg)
-		}
+	params := dynamicMap.Parameters
+	edgeParams := []version2.Parameter{params[0], params[len(params)-2], params[len(params)-1]}
+	if !reflect.DeepEqual(edgeParams, expectedEdgeParams) {
+		t.Errorf("generateDefaultSplitsConfig() returned the parameters %v but expected %v", edgeParams, expectedEdgeParams)
 	}
 }
 
-func TestNewHealthCheckWithDefaults(t *testing.T) {
-	upstreamName := "test-upstream"
-	baseCfgParams := &ConfigParams{
-		ProxySendTimeout:    "5s",
-		ProxyReadTimeout:    "5s",
-		ProxyConnectTimeout: "5s",
+func TestParseSplitWeights(t *testing.T) {
+	vsc := newVirtualServerConfigurator(&ConfigParams{}, true, false)
+	virtualServer := &conf_v1.VirtualServer{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "cafe",
+			Namespace: "default",
+		},
 	}
-	expected := &version2.HealthCheck{
-		Name:                upstreamName,
-		ProxySendTimeout:    "5s",
-		ProxyReadTimeout:    "5s",
-		ProxyConnectTimeout: "5s",
-		ProxyPass:           fmt.Sprintf("http://%v", upstreamName),
-		URI:                 "/",
-		Interval:            "5s",
-		Jitter:              "0s",
-		Fails:               1,
-		Passes:              1,
-		Headers:             make(map[string]string),
+	annotations := map[string]string{
+		splitWeightsAnnotation: "/coffee=80:20, ~ ^/tea=a=0:100,/juice=50,/water=60:60",
+	}
+	expected := map[string]int{
+		"/coffee":   80,
+		"~ ^/tea=a": 0,
 	}
 
-	result := newHealthCheckWithDefaults(conf_v1.Upstream{}, upstreamName, baseCfgParams)
-
+	vsc.clearWarnings()
+	result := vsc.parseSplitWeights(virtualServer, annotations)
 	if !reflect.DeepEqual(result, expected) {
-		t.Errorf("newHealthCheckWithDefaults returned \n%v but expected \n%v", result, expected)
+		t.Errorf("parseSplitWeights() returned %v but expected %v", result, expected)
+	}
+	if len(vsc.warnings[virtualServer]) != 2 {
+		t.Errorf("parseSplitWeights() returned warnings %v but expected 2 warnings for the invalid weights", vsc.warnings[virtualServer])
 	}
 }
 
-func TestGenerateHealthCheck(t *testing.T) {
-	upstreamName := "test-upstream"
+func TestGenerateSplitWeightsKeyValPairs(t *testing.T) {
+	route := conf_v1.Route{
+		Path: "/coffee",
+		Splits: []conf_v1.Split{
+			{
+				Weight: 90,
+			},
+			{
+				Weight: 10,
+			},
+		},
+	}
+	keyVals := []version2.KeyVal{
+		{
+			Key:      "vs_default_cafe_splits_0",
+			Variable: "$vs_default_cafe_splits_0_weight",
+			ZoneName: "vs_default_cafe_split_weights",
+		},
+	}
+
 	tests := []struct {
-		upstream     conf_v1.Upstream
-		upstreamName string
-		expected     *version2.HealthCheck
+		splitWeights map[string]int
+		expected     string
 		msg          string
 	}{
 		{
+			splitWeights: map[string]int{"/coffee": 30},
+			expected:     "30",
+			msg:          "weights in the annotation",
+		},
+		{
+			splitWeights: nil,
+			expected:     "90",
+			msg:          "weights of the route",
+		},
+	}
 
-			upstream: conf_v1.Upstream{
-				HealthCheck: &conf_v1.HealthCheck{
-					Enable:         true,
-					Path:           "/healthz",
-					Interval:       "5s",
-					Jitter:         "2s",
-					Fails:          3,
-					Passes:         2,
-					Port:           8080,
-					ConnectTimeout: "20s",
-					SendTimeout:    "20s",
-					ReadTimeout:    "20s",
-					Headers: []conf_v1.Header{
-						{
-							Name:  "Host",
-							Value: "my.service",
-						},
-						{
-							Name:  "User-Agent",
-							Value: "nginx",
-						},
-					},
-					StatusMatch: "! 500",
-				},
+	for _, test := range tests {
+		expected := []version2.KeyValPair{
+			{
+				ZoneName: "vs_default_cafe_split_weights",
+				Key:      "vs_default_cafe_splits_0",
+				Value:    test.expected,
 			},
-			upstreamName: upstreamName,
-			expected: &version2.HealthCheck{
-				Name:                upstreamName,
-				ProxyConnectTimeout: "20s",
-				ProxySendTimeout:    "20s",
-				ProxyReadTimeout:    "20s",
-				ProxyPass:           fmt.Sprintf("http://%v", upstreamName),
-				URI:                 "/healthz",
-				Interval:            "5s",
-				Jitter:              "2s",
-				Fails:               3,
-				Passes:              2,
-				Port:                8080,
-				Headers: map[string]string{
-					"Host":       "my.service",
-					"User-Agent": "nginx",
-				},
-				Match: fmt.Sprintf("%v_match", upstreamName),
+		}
+		result := generateSplitWeightsKeyValPairs(route, keyVals, test.splitWeights)
+		if !reflect.DeepEqual(result, expected) {
+			t.Errorf("generateSplitWeightsKeyValPairs() returned %v but expected %v for the case of %s", result, expected, test.msg)
+		}
+	}
+}
+
+func TestGenerateMatchesConfigWithSplitsPersistence(t *testing.T) {
+	splits := []conf_v1.Split{
+		{
+			Weight: 90,
+			Action: &conf_v1.Action{
+				Pass: "coffee-v1",
 			},
-			msg: "HealthCheck with changed parameters",
 		},
 		{
-			upstream: conf_v1.Upstream{
-				HealthCheck: &conf_v1.HealthCheck{
-					Enable: true,
-				},
-				ProxyConnectTimeout: "30s",
-				ProxyReadTimeout:    "30s",
-				ProxySendTimeout:    "30s",
-			},
-			upstreamName: upstreamName,
-			expected: &version2.HealthCheck{
-				Name:                upstreamName,
-				ProxyConnectTimeout: "30s",
-				ProxyReadTimeout:    "30s",
-				ProxySendTimeout:    "30s",
-				ProxyPass:           fmt.Sprintf("http://%v", upstreamName),
-				URI:                 "/",
-				Interval:            "5s",
-				Jitter:              "0s",
-				Fails:               1,
-				Passes:              1,
-				Headers:             make(map[string]string),
+			Weight: 10,
+			Action: &conf_v1.Action{
+				Pass: "coffee-v2",
 			},
-			msg: "HealthCheck with default parameters from Upstream",
 		},
-		{
-			upstream: conf_v1.Upstream{
-				HealthCheck: &conf_v1.HealthCheck{
-					Enable: true,
+	}
+	route := conf_v1.Route{
+		Path: "/",
+		Matches: []conf_v1.Match{
+			{
+				Conditions: []conf_v1.Condition{
+					{
+						Header: "x-version",
+						Value:  "v2",
+					},
 				},
+				Splits: splits,
 			},
-			upstreamName: upstreamName,
-			expected: &version2.HealthCheck{
-				Name:                upstreamName,
-				ProxyConnectTimeout: "5s",
-				ProxyReadTimeout:    "5s",
-				ProxySendTimeout:    "5s",
-				ProxyPass:           fmt.Sprintf("http://%v", upstreamName),
-				URI:                 "/",
-				Interval:            "5s",
-				Jitter:              "0s",
-				Fails:               1,
-				Passes:              1,
-				Headers:             make(map[string]string),
-			},
-			msg: "HealthCheck with default parameters from ConfigMap (not defined in Upstream)",
 		},
-		{
-			upstream:     conf_v1.Upstream{},
-			upstreamName: upstreamName,
-			expected:     nil,
-			msg:          "HealthCheck not enabled",
+		Splits: splits,
+		SplitsPersistence: &conf_v1.SplitsPersistence{
+			Cookie: "canary",
+		},
+	}
+	virtualServer := conf_v1.VirtualServer{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "cafe",
+			Namespace: "default",
 		},
 	}
+	upstreamNamer := newUpstreamNamerForVirtualServer(&virtualServer, UpstreamNamingScheme{})
+	variableNamer := newVariableNamer(&virtualServer)
 
-	baseCfgParams := &ConfigParams{
-		ProxySendTimeout:    "5s",
-		ProxyReadTimeout:    "5s",
-		ProxyConnectTimeout: "5s",
+	result := generateMatchesConfig(route, upstreamNamer, map[string]conf_v1.Upstream{}, variableNamer, make(conditionMapVariables), "0", &ConfigParams{})
+
+	mainMap := result.Maps[1]
+	expectedResults := []string{"$vs_default_cafe_splits_0_0_persistence", "$vs_default_cafe_splits_0_1_persistence"}
+	results := []string{mainMap.Parameters[0].Result, mainMap.Parameters[1].Result}
+	if !reflect.DeepEqual(results, expectedResults) {
+		t.Errorf("generateMatchesConfig() returned the results %v of the main map but expected %v", results, expectedResults)
 	}
 
-	for _, test := range tests {
-		result := generateHealthCheck(test.upstream, test.upstreamName, baseCfgParams)
+	var persistenceMaps []version2.Map
+	for _, m := range result.Maps {
+		if m.Source == "$cookie_canary" {
+			persistenceMaps = append(persistenceMaps, m)
+		}
+	}
+	if len(persistenceMaps) != 2 {
+		t.Fatalf("generateMatchesConfig() returned %d maps of the persistence but expected 2", len(persistenceMaps))
+	}
+
+	// the splits of the match and the default splits share the cookie, so their values differ
+	expectedValues := []string{`"0-0"`, `"1-0"`}
+	for i, m := range persistenceMaps {
+		if m.Parameters[0].Value != expectedValues[i] {
+			t.Errorf("generateMatchesConfig() returned the value %s of the map %s but expected %s", m.Parameters[0].Value, m.Variable, expectedValues[i])
+		}
+	}
+}
+
+func TestGenerateChunkedFirstMatchMaps(t *testing.T) {
+	virtualServer := conf_v1.VirtualServer{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "cafe",
+			Namespace: "default",
+		},
+	}
+	variableNamer := newVariableNamer(&virtualServer)
+
+	var sources []string
+	var results []string
+	for i := 0; i < maxMatchesPerMap+2; i++ {
+		sources = append(sources, fmt.Sprintf("$source_%d", i))
+		results = append(results, fmt.Sprintf("@matches_0_match_%d", i))
+	}
+
+	maps := generateChunkedFirstMatchMaps(sources, results, "@matches_0_default", "$vs_default_cafe_matches_0", variableNamer, "0")
+
+	expectedMapsCount := 5
+	if len(maps) != expectedMapsCount {
+		t.Fatalf("generateChunkedFirstMatchMaps() returned %d maps but expected %d", len(maps), expectedMapsCount)
+	}
+
+	expectedSecondChunkMap := version2.Map{
+		Source:   fmt.Sprintf("$source_%d$source_%d", maxMatchesPerMap, maxMatchesPerMap+1),
+		Variable: "$vs_default_cafe_matches_0_chunk_1",
+		Parameters: []version2.Parameter{
+			{
+				Value:  "~1",
+				Result: "1",
+			},
+			{
+				Value:  "default",
+				Result: "0",
+			},
+		},
+	}
+	if !reflect.DeepEqual(maps[2], expectedSecondChunkMap) {
+		t.Errorf("generateChunkedFirstMatchMaps() returned chunk map \n%+v but expected \n%+v", maps[2], expectedSecondChunkMap)
+	}
+
+	expectedSecondChunkResultMap := version2.Map{
+		Source:   fmt.Sprintf("$source_%d$source_%d", maxMatchesPerMap, maxMatchesPerMap+1),
+		Variable: "$vs_default_cafe_matches_0_chunk_1_result",
+		Parameters: []version2.Parameter{
+			{
+				Value:  "~^1",
+				Result: fmt.Sprintf("@matches_0_match_%d", maxMatchesPerMap),
+			},
+			{
+				Value:  "~^01",
+				Result: fmt.Sprintf("@matches_0_match_%d", maxMatchesPerMap+1),
+			},
+			{
+				Value:  "default",
+				Result: "@matches_0_default",
+			},
+		},
+	}
+	if !reflect.DeepEqual(maps[3], expectedSecondChunkResultMap) {
+		t.Errorf("generateChunkedFirstMatchMaps() returned chunk result map \n%+v but expected \n%+v", maps[3], expectedSecondChunkResultMap)
+	}
+
+	expectedMainMap := version2.Map{
+		Source:   "$vs_default_cafe_matches_0_chunk_0$vs_default_cafe_matches_0_chunk_1",
+		Variable: "$vs_default_cafe_matches_0",
+		Parameters: []version2.Parameter{
+			{
+				Value:  "~^1",
+				Result: "$vs_default_cafe_matches_0_chunk_0_result",
+			},
+			{
+				Value:  "~^01",
+				Result: "$vs_default_cafe_matches_0_chunk_1_result",
+			},
+			{
+				Value:  "default",
+				Result: "@matches_0_default",
+			},
+		},
+	}
+	if !reflect.DeepEqual(maps[4], expectedMainMap) {
+		t.Errorf("generateChunkedFirstMatchMaps() returned main map \n%+v but expected \n%+v", maps[4], expectedMainMap)
+	}
+}
+
+func TestCheckJWTClaimConditions(t *testing.T) {
+	virtualServer := &conf_v1.VirtualServer{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "cafe",
+			Namespace: "default",
+		},
+	}
+	route := conf_v1.Route{
+		Path: "/coffee",
+		Matches: []conf_v1.Match{
+			{
+				Conditions: []conf_v1.Condition{
+					{
+						Variable: "$jwt_claim_tenant",
+						Value:    "cafe",
+					},
+				},
+			},
+		},
+	}
+
+	vsc := newVirtualServerConfigurator(&ConfigParams{}, true, false)
+
+	vsc.checkJWTClaimConditions(virtualServer, route, true)
+	if len(vsc.warnings) != 0 {
+		t.Errorf("checkJWTClaimConditions() returned warnings %v for a route with a JWT policy", vsc.warnings)
+	}
+
+	vsc.checkJWTClaimConditions(virtualServer, route, false)
+	if len(vsc.warnings[virtualServer]) != 1 {
+		t.Errorf("checkJWTClaimConditions() returned warnings %v but expected one warning for a route without a JWT policy", vsc.warnings)
+	}
+}
+
+func TestGetMatchesEvaluationOrder(t *testing.T) {
+	matches := []conf_v1.Match{
+		{
+			Priority: 0,
+		},
+		{
+			Priority: 10,
+		},
+		{
+			Priority: 0,
+		},
+		{
+			Priority: 20,
+		},
+	}
+	expected := []int{3, 1, 0, 2}
+
+	result := getMatchesEvaluationOrder(matches)
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("getMatchesEvaluationOrder() returned %v but expected %v", result, expected)
+	}
+}
+
+func TestCheckOverlappingMatches(t *testing.T) {
+	virtualServer := &conf_v1.VirtualServer{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "cafe",
+			Namespace: "default",
+		},
+	}
+	versionCondition := conf_v1.Condition{
+		Header: "x-version",
+		Value:  "v2",
+	}
+	betaCondition := conf_v1.Condition{
+		Cookie: "beta",
+		Value:  "1",
+	}
+	route := conf_v1.Route{
+		Path: "/coffee",
+		Matches: []conf_v1.Match{
+			{
+				Conditions: []conf_v1.Condition{versionCondition, betaCondition},
+			},
+			{
+				Any:        true,
+				Conditions: []conf_v1.Condition{versionCondition, betaCondition},
+			},
+			{
+				Conditions: []conf_v1.Condition{betaCondition},
+			},
+		},
+	}
+
+	vsc := newVirtualServerConfigurator(&ConfigParams{}, true, false)
+
+	vsc.checkOverlappingMatches(virtualServer, route)
+	if len(vsc.warnings) != 0 {
+		t.Errorf("checkOverlappingMatches() returned warnings %v for a route without overlapping matches", vsc.warnings)
+	}
+
+	route.Matches = append(route.Matches, conf_v1.Match{
+		Priority:   10,
+		Conditions: []conf_v1.Condition{betaCondition, versionCondition},
+	})
+
+	vsc.checkOverlappingMatches(virtualServer, route)
+	if len(vsc.warnings[virtualServer]) != 1 {
+		t.Errorf("checkOverlappingMatches() returned warnings %v but expected one warning for the overlapping matches", vsc.warnings)
+	}
+}
+
+func TestGenerateMatchesConfigWithClientIP(t *testing.T) {
+	route := conf_v1.Route{
+		Path: "/",
+		Matches: []conf_v1.Match{
+			{
+				Conditions: []conf_v1.Condition{
+					{
+						ClientIP: []string{"10.0.0.0/8", "192.168.1.5"},
+					},
+				},
+				Action: &conf_v1.Action{
+					Pass: "coffee-beta",
+				},
+			},
+		},
+		Action: &conf_v1.Action{
+			Pass: "coffee",
+		},
+	}
+	virtualServer := conf_v1.VirtualServer{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "cafe",
+			Namespace: "default",
+		},
+	}
+	upstreamNamer := newUpstreamNamerForVirtualServer(&virtualServer, UpstreamNamingScheme{})
+	variableNamer := newVariableNamer(&virtualServer)
+	conditionMaps := make(conditionMapVariables)
+
+	expectedGeos := []version2.Geo{
+		{
+			Source:   "$remote_addr",
+			Variable: "$vs_default_cafe_matches_0_match_0_cond_0_geo",
+			Parameters: []version2.Parameter{
+				{
+					Value:  "default",
+					Result: "0",
+				},
+				{
+					Value:  "10.0.0.0/8",
+					Result: "1",
+				},
+				{
+					Value:  "192.168.1.5",
+					Result: "1",
+				},
+			},
+		},
+	}
+	expectedConditionMap := version2.Map{
+		Source:   "$vs_default_cafe_matches_0_match_0_cond_0_geo",
+		Variable: "$vs_default_cafe_matches_0_match_0_cond_0",
+		Parameters: []version2.Parameter{
+			{
+				Value:  `"1"`,
+				Result: "1",
+			},
+			{
+				Value:  "default",
+				Result: "0",
+			},
+		},
+	}
+
+	result := generateMatchesConfig(route, upstreamNamer, map[string]conf_v1.Upstream{}, variableNamer, conditionMaps, "0", &ConfigParams{})
+	if !reflect.DeepEqual(result.Geos, expectedGeos) {
+		t.Errorf("generateMatchesConfig() returned geos %v but expected %v", result.Geos, expectedGeos)
+	}
+	if !reflect.DeepEqual(result.Maps[0], expectedConditionMap) {
+		t.Errorf("generateMatchesConfig() returned the map %v but expected %v", result.Maps[0], expectedConditionMap)
+	}
+
+	// another route with the same addresses shares the geo
+	result = generateMatchesConfig(route, upstreamNamer, map[string]conf_v1.Upstream{}, variableNamer, conditionMaps, "1", &ConfigParams{})
+	if len(result.Geos) != 0 {
+		t.Errorf("generateMatchesConfig() returned geos %v but expected the shared geo", result.Geos)
+	}
+}
+
+func TestGenerateMatchesConfigWithAnyCondition(t *testing.T) {
+	route := conf_v1.Route{
+		Path: "/",
+		Matches: []conf_v1.Match{
+			{
+				Any: true,
+				Conditions: []conf_v1.Condition{
+					{
+						Header: "x-version",
+						Value:  "v2",
+					},
+					{
+						Cookie: "beta",
+						Value:  "!0",
+					},
+				},
+				Action: &conf_v1.Action{
+					Pass: "coffee-v2",
+				},
+			},
+		},
+		Action: &conf_v1.Action{
+			Pass: "coffee",
+		},
+	}
+	virtualServer := conf_v1.VirtualServer{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "cafe",
+			Namespace: "default",
+		},
+	}
+	upstreamNamer := newUpstreamNamerForVirtualServer(&virtualServer, UpstreamNamingScheme{})
+	variableNamer := newVariableNamer(&virtualServer)
+
+	expectedMaps := []version2.Map{
+		{
+			Source:   "$http_x_version",
+			Variable: "$vs_default_cafe_matches_0_match_0_cond_0",
+			Parameters: []version2.Parameter{
+				{
+					Value:  `"v2"`,
+					Result: "1",
+				},
+				{
+					Value:  "default",
+					Result: "$vs_default_cafe_matches_0_match_0_cond_1",
+				},
+			},
+		},
+		{
+			Source:   "$cookie_beta",
+			Variable: "$vs_default_cafe_matches_0_match_0_cond_1",
+			Parameters: []version2.Parameter{
+				{
+					Value:  `"0"`,
+					Result: "0",
+				},
+				{
+					Value:  "default",
+					Result: "1",
+				},
+			},
+		},
+	}
+
+	result := generateMatchesConfig(route, upstreamNamer, map[string]conf_v1.Upstream{}, variableNamer, make(conditionMapVariables), "0", &ConfigParams{})
+	if !reflect.DeepEqual(result.Maps[:2], expectedMaps) {
+		t.Errorf("generateMatchesConfig() returned the maps %v but expected %v", result.Maps[:2], expectedMaps)
+	}
+}
+
+func TestGenerateMatchesConfig(t *testing.T) {
+	route := conf_v1.Route{
+		Path: "/",
+		Matches: []conf_v1.Match{
+			{
+				Conditions: []conf_v1.Condition{
+					{
+						Header: "x-version",
+						Value:  "v1",
+					},
+					{
+						Cookie: "user",
+						Value:  "john",
+					},
+					{
+						Argument: "answer",
+						Value:    "yes",
+					},
+					{
+						Variable: "$request_method",
+						Value:    "GET",
+					},
+				},
+				Action: &conf_v1.Action{
+					Pass: "coffee-v1",
+				},
+			},
+			{
+				Conditions: []conf_v1.Condition{
+					{
+						Header: "x-version",
+						Value:  "v2",
+					},
+					{
+						Cookie: "user",
+						Value:  "paul",
+					},
+					{
+						Argument: "answer",
+						Value:    "no",
+					},
+					{
+						Variable: "$request_method",
+						Value:    "POST",
+					},
+				},
+				Splits: []conf_v1.Split{
+					{
+						Weight: 90,
+						Action: &conf_v1.Action{
+							Pass: "coffee-v1",
+						},
+					},
+					{
+						Weight: 10,
+						Action: &conf_v1.Action{
+							Pass: "coffee-v2",
+						},
+					},
+				},
+			},
+		},
+		Action: &conf_v1.Action{
+			Pass: "tea",
+		},
+	}
+	virtualServer := conf_v1.VirtualServer{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "cafe",
+			Namespace: "default",
+		},
+	}
+	upstreamNamer := newUpstreamNamerForVirtualServer(&virtualServer, UpstreamNamingScheme{})
+	variableNamer := newVariableNamer(&virtualServer)
+	routeID := "1"
+
+	expected := routingCfg{
+		Maps: []version2.Map{
+			{
+				Source:   "$http_x_version",
+				Variable: "$vs_default_cafe_matches_1_match_0_cond_0",
+				Parameters: []version2.Parameter{
+					{
+						Value:  `"v1"`,
+						Result: "$vs_default_cafe_matches_1_match_0_cond_1",
+					},
+					{
+						Value:  "default",
+						Result: "0",
+					},
+				},
+			},
+			{
+				Source:   "$cookie_user",
+				Variable: "$vs_default_cafe_matches_1_match_0_cond_1",
+				Parameters: []version2.Parameter{
+					{
+						Value:  `"john"`,
+						Result: "$vs_default_cafe_matches_1_match_0_cond_2",
+					},
+					{
+						Value:  "default",
+						Result: "0",
+					},
+				},
+			},
+			{
+				Source:   "$arg_answer",
+				Variable: "$vs_default_cafe_matches_1_match_0_cond_2",
+				Parameters: []version2.Parameter{
+					{
+						Value:  `"yes"`,
+						Result: "$vs_default_cafe_matches_1_match_0_cond_3",
+					},
+					{
+						Value:  "default",
+						Result: "0",
+					},
+				},
+			},
+			{
+				Source:   "$request_method",
+				Variable: "$vs_default_cafe_matches_1_match_0_cond_3",
+				Parameters: []version2.Parameter{
+					{
+						Value:  `"GET"`,
+						Result: "1",
+					},
+					{
+						Value:  "default",
+						Result: "0",
+					},
+				},
+			},
+			{
+				Source:   "$http_x_version",
+				Variable: "$vs_default_cafe_matches_1_match_1_cond_0",
+				Parameters: []version2.Parameter{
+					{
+						Value:  `"v2"`,
+						Result: "$vs_default_cafe_matches_1_match_1_cond_1",
+					},
+					{
+						Value:  "default",
+						Result: "0",
+					},
+				},
+			},
+			{
+				Source:   "$cookie_user",
+				Variable: "$vs_default_cafe_matches_1_match_1_cond_1",
+				Parameters: []version2.Parameter{
+					{
+						Value:  `"paul"`,
+						Result: "$vs_default_cafe_matches_1_match_1_cond_2",
+					},
+					{
+						Value:  "default",
+						Result: "0",
+					},
+				},
+			},
+			{
+				Source:   "$arg_answer",
+				Variable: "$vs_default_cafe_matches_1_match_1_cond_2",
+				Parameters: []version2.Parameter{
+					{
+						Value:  `"no"`,
+						Result: "$vs_default_cafe_matches_1_match_1_cond_3",
+					},
+					{
+						Value:  "default",
+						Result: "0",
+					},
+				},
+			},
+			{
+				Source:   "$request_method",
+				Variable: "$vs_default_cafe_matches_1_match_1_cond_3",
+				Parameters: []version2.Parameter{
+					{
+						Value:  `"POST"`,
+						Result: "1",
+					},
+					{
+						Value:  "default",
+						Result: "0",
+					},
+				},
+			},
+			{
+				Source:   "$vs_default_cafe_matches_1_match_0_cond_0$vs_default_cafe_matches_1_match_1_cond_0",
+				Variable: "$vs_default_cafe_matches_1",
+				Parameters: []version2.Parameter{
+					{
+						Value:  "~^1",
+						Result: "@matches_1_match_0",
+					},
+					{
+						Value:  "~^01",
+						Result: "$vs_default_cafe_splits_1_0",
+					},
+					{
+						Value:  "default",
+						Result: "@matches_1_default",
+					},
+				},
+			},
+		},
+		Locations: []version2.Location{
+			{
+				Path:                     "@matches_1_match_0",
+				ProxyPass:                "http://vs_default_cafe_coffee-v1",
+				ProxyNextUpstream:        "error timeout",
+				ProxyNextUpstreamTimeout: "0s",
+				ProxyNextUpstreamTries:   0,
+			},
+			{
+				Path:                     "@splits_1_0_split_0",
+				ProxyPass:                "http://vs_default_cafe_coffee-v1",
+				ProxyNextUpstream:        "error timeout",
+				ProxyNextUpstreamTimeout: "0s",
+				ProxyNextUpstreamTries:   0,
+			},
+			{
+				Path:                     "@splits_1_0_split_1",
+				ProxyPass:                "http://vs_default_cafe_coffee-v2",
+				ProxyNextUpstream:        "error timeout",
+				ProxyNextUpstreamTimeout: "0s",
+				ProxyNextUpstreamTries:   0,
+			},
+			{
+				Path:                     "@matches_1_default",
+				ProxyPass:                "http://vs_default_cafe_tea",
+				ProxyNextUpstream:        "error timeout",
+				ProxyNextUpstreamTimeout: "0s",
+				ProxyNextUpstreamTries:   0,
+			},
+		},
+		InternalRedirectLocation: version2.InternalRedirectLocation{
+			Path:        "/",
+			Destination: "$vs_default_cafe_matches_1",
+		},
+		SplitClients: []version2.SplitClient{
+			{
+				Source:   "$request_id",
+				Variable: "$vs_default_cafe_splits_1_0",
+				Distributions: []version2.Distribution{
+					{
+						Weight: "90%",
+						Value:  "@splits_1_0_split_0",
+					},
+					{
+						Weight: "10%",
+						Value:  "@splits_1_0_split_1",
+					},
+				},
+			},
+		},
+	}
+
+	cfgParams := ConfigParams{}
+
+	result := generateMatchesConfig(route, upstreamNamer, map[string]conf_v1.Upstream{}, variableNamer, make(conditionMapVariables), routeID, &cfgParams)
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("generateMatchesConfig() returned \n%v but expected \n%v", result, expected)
+	}
+}
+
+func TestGenerateMatchesConfigWithMultipleSplits(t *testing.T) {
+	route := conf_v1.Route{
+		Path: "/",
+		Matches: []conf_v1.Match{
+			{
+				Conditions: []conf_v1.Condition{
+					{
+						Header: "x-version",
+						Value:  "v1",
+					},
+				},
+				Splits: []conf_v1.Split{
+					{
+						Weight: 30,
+						Action: &conf_v1.Action{
+							Pass: "coffee-v1",
+						},
+					},
+					{
+						Weight: 70,
+						Action: &conf_v1.Action{
+							Pass: "coffee-v2",
+						},
+					},
+				},
+			},
+			{
+				Conditions: []conf_v1.Condition{
+					{
+						Header: "x-version",
+						Value:  "v2",
+					},
+				},
+				Splits: []conf_v1.Split{
+					{
+						Weight: 90,
+						Action: &conf_v1.Action{
+							Pass: "coffee-v2",
+						},
+					},
+					{
+						Weight: 10,
+						Action: &conf_v1.Action{
+							Pass: "coffee-v1",
+						},
+					},
+				},
+			},
+		},
+		Splits: []conf_v1.Split{
+			{
+				Weight: 99,
+				Action: &conf_v1.Action{
+					Pass: "coffee-v1",
+				},
+			},
+			{
+				Weight: 1,
+				Action: &conf_v1.Action{
+					Pass: "coffee-v2",
+				},
+			},
+		},
+	}
+	virtualServer := conf_v1.VirtualServer{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "cafe",
+			Namespace: "default",
+		},
+	}
+	upstreamNamer := newUpstreamNamerForVirtualServer(&virtualServer, UpstreamNamingScheme{})
+	variableNamer := newVariableNamer(&virtualServer)
+	routeID := "1"
+
+	expected := routingCfg{
+		Maps: []version2.Map{
+			{
+				Source:   "$http_x_version",
+				Variable: "$vs_default_cafe_matches_1_match_0_cond_0",
+				Parameters: []version2.Parameter{
+					{
+						Value:  `"v1"`,
+						Result: "1",
+					},
+					{
+						Value:  "default",
+						Result: "0",
+					},
+				},
+			},
+			{
+				Source:   "$http_x_version",
+				Variable: "$vs_default_cafe_matches_1_match_1_cond_0",
+				Parameters: []version2.Parameter{
+					{
+						Value:  `"v2"`,
+						Result: "1",
+					},
+					{
+						Value:  "default",
+						Result: "0",
+					},
+				},
+			},
+			{
+				Source:   "$vs_default_cafe_matches_1_match_0_cond_0$vs_default_cafe_matches_1_match_1_cond_0",
+				Variable: "$vs_default_cafe_matches_1",
+				Parameters: []version2.Parameter{
+					{
+						Value:  "~^1",
+						Result: "$vs_default_cafe_splits_1_0",
+					},
+					{
+						Value:  "~^01",
+						Result: "$vs_default_cafe_splits_1_1",
+					},
+					{
+						Value:  "default",
+						Result: "$vs_default_cafe_splits_1_2",
+					},
+				},
+			},
+		},
+		Locations: []version2.Location{
+			{
+				Path:                     "@splits_1_0_split_0",
+				ProxyPass:                "http://vs_default_cafe_coffee-v1",
+				ProxyNextUpstream:        "error timeout",
+				ProxyNextUpstreamTimeout: "0s",
+				ProxyNextUpstreamTries:   0,
+			},
+			{
+				Path:                     "@splits_1_0_split_1",
+				ProxyPass:                "http://vs_default_cafe_coffee-v2",
+				ProxyNextUpstream:        "error timeout",
+				ProxyNextUpstreamTimeout: "0s",
+				ProxyNextUpstreamTries:   0,
+			},
+			{
+				Path:                     "@splits_1_1_split_0",
+				ProxyPass:                "http://vs_default_cafe_coffee-v2",
+				ProxyNextUpstream:        "error timeout",
+				ProxyNextUpstreamTimeout: "0s",
+				ProxyNextUpstreamTries:   0,
+			},
+			{
+				Path:                     "@splits_1_1_split_1",
+				ProxyPass:                "http://vs_default_cafe_coffee-v1",
+				ProxyNextUpstream:        "error timeout",
+				ProxyNextUpstreamTimeout: "0s",
+				ProxyNextUpstreamTries:   0,
+			},
+			{
+				Path:                     "@splits_1_2_split_0",
+				ProxyPass:                "http://vs_default_cafe_coffee-v1",
+				ProxyNextUpstream:        "error timeout",
+				ProxyNextUpstreamTimeout: "0s",
+				ProxyNextUpstreamTries:   0,
+			},
+			{
+				Path:                     "@splits_1_2_split_1",
+				ProxyPass:                "http://vs_default_cafe_coffee-v2",
+				ProxyNextUpstream:        "error timeout",
+				ProxyNextUpstreamTimeout: "0s",
+				ProxyNextUpstreamTries:   0,
+			},
+		},
+		InternalRedirectLocation: version2.InternalRedirectLocation{
+			Path:        "/",
+			Destination: "$vs_default_cafe_matches_1",
+		},
+		SplitClients: []version2.SplitClient{
+			{
+				Source:   "$request_id",
+				Variable: "$vs_default_cafe_splits_1_0",
+				Distributions: []version2.Distribution{
+					{
+						Weight: "30%",
+						Value:  "@splits_1_0_split_0",
+					},
+					{
+						Weight: "70%",
+						Value:  "@splits_1_0_split_1",
+					},
+				},
+			},
+			{
+				Source:   "$request_id",
+				Variable: "$vs_default_cafe_splits_1_1",
+				Distributions: []version2.Distribution{
+					{
+						Weight: "90%",
+						Value:  "@splits_1_1_split_0",
+					},
+					{
+						Weight: "10%",
+						Value:  "@splits_1_1_split_1",
+					},
+				},
+			},
+			{
+				Source:   "$request_id",
+				Variable: "$vs_default_cafe_splits_1_2",
+				Distributions: []version2.Distribution{
+					{
+						Weight: "99%",
+						Value:  "@splits_1_2_split_0",
+					},
+					{
+						Weight: "1%",
+						Value:  "@splits_1_2_split_1",
+					},
+				},
+			},
+		},
+	}
+
+	cfgParams := ConfigParams{}
+
+	result := generateMatchesConfig(route, upstreamNamer, map[string]conf_v1.Upstream{}, variableNamer, make(conditionMapVariables), routeID, &cfgParams)
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("generateMatchesConfig() returned \n%v but expected \n%v", result, expected)
+	}
+}
+
+func TestGenerateMatchesConfigWithSharedConditions(t *testing.T) {
+	newRoute := func(path string, pass string) conf_v1.Route {
+		return conf_v1.Route{
+			Path: path,
+			Matches: []conf_v1.Match{
+				{
+					Conditions: []conf_v1.Condition{
+						{
+							Header: "x-version",
+							Value:  "v2",
+						},
+						{
+							Cookie: "user",
+							Value:  "john",
+						},
+					},
+					Action: &conf_v1.Action{
+						Pass: pass,
+					},
+				},
+			},
+			Action: &conf_v1.Action{
+				Pass: pass,
+			},
+		}
+	}
+
+	virtualServer := conf_v1.VirtualServer{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "cafe",
+			Namespace: "default",
+		},
+	}
+	upstreamNamer := newUpstreamNamerForVirtualServer(&virtualServer, UpstreamNamingScheme{})
+	variableNamer := newVariableNamer(&virtualServer)
+	conditionMaps := make(conditionMapVariables)
+	cfgParams := ConfigParams{}
+
+	coffeeCfg := generateMatchesConfig(newRoute("/coffee", "coffee"), upstreamNamer, map[string]conf_v1.Upstream{}, variableNamer, conditionMaps, "0", &cfgParams)
+	teaCfg := generateMatchesConfig(newRoute("/tea", "tea"), upstreamNamer, map[string]conf_v1.Upstream{}, variableNamer, conditionMaps, "1", &cfgParams)
+
+	expectedCoffeeMaps := []version2.Map{
+		{
+			Source:   "$http_x_version",
+			Variable: "$vs_default_cafe_matches_0_match_0_cond_0",
+			Parameters: []version2.Parameter{
+				{
+					Value:  `"v2"`,
+					Result: "$vs_default_cafe_matches_0_match_0_cond_1",
+				},
+				{
+					Value:  "default",
+					Result: "0",
+				},
+			},
+		},
+		{
+			Source:   "$cookie_user",
+			Variable: "$vs_default_cafe_matches_0_match_0_cond_1",
+			Parameters: []version2.Parameter{
+				{
+					Value:  `"john"`,
+					Result: "1",
+				},
+				{
+					Value:  "default",
+					Result: "0",
+				},
+			},
+		},
+		{
+			Source:   "$vs_default_cafe_matches_0_match_0_cond_0",
+			Variable: "$vs_default_cafe_matches_0",
+			Parameters: []version2.Parameter{
+				{
+					Value:  "~^1",
+					Result: "@matches_0_match_0",
+				},
+				{
+					Value:  "default",
+					Result: "@matches_0_default",
+				},
+			},
+		},
+	}
+	if !reflect.DeepEqual(coffeeCfg.Maps, expectedCoffeeMaps) {
+		t.Errorf("generateMatchesConfig() returned the maps \n%v but expected \n%v", coffeeCfg.Maps, expectedCoffeeMaps)
+	}
+
+	// the maps of the conditions of the second route are the same, so only the main map is generated
+	expectedTeaMaps := []version2.Map{
+		{
+			Source:   "$vs_default_cafe_matches_0_match_0_cond_0",
+			Variable: "$vs_default_cafe_matches_1",
+			Parameters: []version2.Parameter{
+				{
+					Value:  "~^1",
+					Result: "@matches_1_match_0",
+				},
+				{
+					Value:  "default",
+					Result: "@matches_1_default",
+				},
+			},
+		},
+	}
+	if !reflect.DeepEqual(teaCfg.Maps, expectedTeaMaps) {
+		t.Errorf("generateMatchesConfig() returned the maps \n%v but expected \n%v", teaCfg.Maps, expectedTeaMaps)
+	}
+}
+
+func TestCheckVariablesLimit(t *testing.T) {
+	vsCfg := version2.VirtualServerConfig{
+		Maps:         []version2.Map{{Variable: "$vs_default_cafe_matches_0"}, {Variable: "$vs_default_cafe_matches_1"}},
+		SplitClients: []version2.SplitClient{{Variable: "$vs_default_cafe_splits_0"}},
+	}
+
+	tests := []struct {
+		limit     int
+		expectErr bool
+		msg       string
+	}{
+		{
+			limit:     0,
+			expectErr: false,
+			msg:       "no limit",
+		},
+		{
+			limit:     3,
+			expectErr: false,
+			msg:       "variables within the limit",
+		},
+		{
+			limit:     2,
+			expectErr: true,
+			msg:       "variables over the limit",
+		},
+	}
+
+	for _, test := range tests {
+		err := checkVariablesLimit(&vsCfg, test.limit)
+		if (err != nil) != test.expectErr {
+			t.Errorf("checkVariablesLimit() returned %v for the case of %s", err, test.msg)
+		}
+	}
+}
+
+func TestCheckVariableCollisions(t *testing.T) {
+	newConfig := func(variable string, serverSnippets []string, locationSnippets []string) *version2.VirtualServerConfig {
+		return &version2.VirtualServerConfig{
+			Server: version2.Server{
+				Snippets: serverSnippets,
+				Locations: []version2.Location{
+					{
+						Snippets: locationSnippets,
+					},
+				},
+			},
+			Maps:         []version2.Map{{Variable: "$vs_default_cafe_matches_0"}},
+			SplitClients: []version2.SplitClient{{Variable: variable}},
+		}
+	}
+
+	tests := []struct {
+		vsCfg        *version2.VirtualServerConfig
+		httpSnippets []string
+		expectErr    bool
+		msg          string
+	}{
+		{
+			vsCfg:        newConfig("$vs_default_cafe_splits_0", []string{"set $tea 1;"}, []string{"set $coffee 1;"}),
+			httpSnippets: []string{"map $http_x_tea $green_tea { default 0; }"},
+			expectErr:    false,
+			msg:          "no collisions",
+		},
+		{
+			vsCfg:     newConfig("$request_id", nil, nil),
+			expectErr: true,
+			msg:       "NGINX variable",
+		},
+		{
+			vsCfg:     newConfig("$http_cafe_tea_address", nil, nil),
+			expectErr: true,
+			msg:       "NGINX variable of a header",
+		},
+		{
+			vsCfg:     newConfig("$oidc_client", nil, nil),
+			expectErr: true,
+			msg:       "variable of the OIDC policy",
+		},
+		{
+			vsCfg:     newConfig("$vs_default_cafe_splits_0", []string{"set $vs_default_cafe_splits_0 1;"}, nil),
+			expectErr: true,
+			msg:       "variable of the server snippets",
+		},
+		{
+			vsCfg:     newConfig("$vs_default_cafe_splits_0", nil, []string{"set $vs_default_cafe_matches_0 1;"}),
+			expectErr: true,
+			msg:       "variable of the location snippets",
+		},
+		{
+			vsCfg:        newConfig("$vs_default_cafe_splits_0", nil, nil),
+			httpSnippets: []string{"split_clients $remote_addr $vs_default_cafe_splits_0 { * a; }"},
+			expectErr:    true,
+			msg:          "variable of the http snippets",
+		},
+	}
+
+	for _, test := range tests {
+		err := checkVariableCollisions(test.vsCfg, test.httpSnippets)
+		if (err != nil) != test.expectErr {
+			t.Errorf("checkVariableCollisions() returned %v for the case of %s", err, test.msg)
+		}
+	}
+}
+
+func TestGenerateValueForMatchesRouteMap(t *testing.T) {
+	tests := []struct {
+		input              string
+		expectedValue      string
+		expectedIsNegative bool
+	}{
+		{
+			input:              "default",
+			expectedValue:      `\default`,
+			expectedIsNegative: false,
+		},
+		{
+			input:              "!default",
+			expectedValue:      `\default`,
+			expectedIsNegative: true,
+		},
+		{
+			input:              "hostnames",
+			expectedValue:      `\hostnames`,
+			expectedIsNegative: false,
+		},
+		{
+			input:              "include",
+			expectedValue:      `\include`,
+			expectedIsNegative: false,
+		},
+		{
+			input:              "volatile",
+			expectedValue:      `\volatile`,
+			expectedIsNegative: false,
+		},
+		{
+			input:              "abc",
+			expectedValue:      `"abc"`,
+			expectedIsNegative: false,
+		},
+		{
+			input:              "!abc",
+			expectedValue:      `"abc"`,
+			expectedIsNegative: true,
+		},
+		{
+			input:              "",
+			expectedValue:      `""`,
+			expectedIsNegative: false,
+		},
+		{
+			input:              "!",
+			expectedValue:      `""`,
+			expectedIsNegative: true,
+		},
+	}
+
+	for _, test := range tests {
+		resultValue, resultIsNegative := generateValueForMatchesRouteMap(test.input)
+		if resultValue != test.expectedValue {
+			t.Errorf("generateValueForMatchesRouteMap(%q) returned %q but expected %q as the value", test.input, resultValue, test.expectedValue)
+		}
+		if resultIsNegative != test.expectedIsNegative {
+			t.Errorf("generateValueForMatchesRouteMap(%q) returned %v but expected %v as the isNegative", test.input, resultIsNegative, test.expectedIsNegative)
+		}
+	}
+}
+
+func TestGetMatchedValueFromCondition(t *testing.T) {
+	tests := []struct {
+		condition conf_v1.Condition
+		expected  string
+	}{
+		{
+			condition: conf_v1.Condition{
+				Header: "x-version",
+				Value:  "v1",
+			},
+			expected: "v1",
+		},
+		{
+			condition: conf_v1.Condition{
+				Header:     "x-version",
+				ValueRegex: `^v1\..*`,
+			},
+			expected: `~^v1\\..*`,
+		},
+		{
+			condition: conf_v1.Condition{
+				Header:     "user-agent",
+				ValueRegex: `!"quoted"`,
+			},
+			expected: `!~\"quoted\"`,
+		},
+	}
+
+	for _, test := range tests {
+		result := getMatchedValueFromCondition(test.condition)
+		if result != test.expected {
+			t.Errorf("getMatchedValueFromCondition(%v) returned %q but expected %q", test.condition, result, test.expected)
+		}
+	}
+
+	params := generateParametersForMatchesRouteMap(getMatchedValueFromCondition(tests[1].condition), "1", "0")
+	expectedValue := `"~^v1\\..*"`
+	if params[0].Value != expectedValue {
+		t.Errorf("generateParametersForMatchesRouteMap() returned the value %s but expected %s", params[0].Value, expectedValue)
+	}
+}
+
+func TestGenerateParametersForMatchesRouteMap(t *testing.T) {
+	tests := []struct {
+		inputMatchedValue     string
+		inputSuccessfulResult string
+		inputFailedResult     string
+		expected              []version2.Parameter
+	}{
+		{
+			inputMatchedValue:     "abc",
+			inputSuccessfulResult: "1",
+			inputFailedResult:     "0",
+			expected: []version2.Parameter{
+				{
+					Value:  `"abc"`,
+					Result: "1",
+				},
+				{
+					Value:  "default",
+					Result: "0",
+				},
+			},
+		},
+		{
+			inputMatchedValue:     "!abc",
+			inputSuccessfulResult: "1",
+			inputFailedResult:     "0",
+			expected: []version2.Parameter{
+				{
+					Value:  `"abc"`,
+					Result: "0",
+				},
+				{
+					Value:  "default",
+					Result: "1",
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		result := generateParametersForMatchesRouteMap(test.inputMatchedValue, test.inputSuccessfulResult, test.inputFailedResult)
+		if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("generateParametersForMatchesRouteMap(%q, %q, %q) returned %v but expected %v", test.inputMatchedValue, test.inputSuccessfulResult, test.inputFailedResult, result, test.expected)
+		}
+	}
+}
+
+func TestGenerateParametersForMatchesRouteMapWithValues(t *testing.T) {
+	values := []string{"GET", "~^P", "default"}
+	expected := []version2.Parameter{
+		{
+			Value:  `"GET"`,
+			Result: "$vs_default_cafe_matches_0_match_0_cond_1",
+		},
+		{
+			Value:  `"~^P"`,
+			Result: "$vs_default_cafe_matches_0_match_0_cond_1",
+		},
+		{
+			Value:  `\default`,
+			Result: "$vs_default_cafe_matches_0_match_0_cond_1",
+		},
+		{
+			Value:  "default",
+			Result: "0",
+		},
+	}
+
+	result := generateParametersForMatchesRouteMapWithValues(values, "$vs_default_cafe_matches_0_match_0_cond_1", "0")
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("generateParametersForMatchesRouteMapWithValues() returned %v but expected %v", result, expected)
+	}
+}
+
+func TestGetNameForSourceForMatchesRouteMapFromCondition(t *testing.T) {
+	tests := []struct {
+		input    conf_v1.Condition
+		expected string
+	}{
+		{
+			input: conf_v1.Condition{
+				Header: "x-version",
+			},
+			expected: "$http_x_version",
+		},
+		{
+			input: conf_v1.Condition{
+				Cookie: "mycookie",
+			},
+			expected: "$cookie_mycookie",
+		},
+		{
+			input: conf_v1.Condition{
+				Argument: "arg",
+			},
+			expected: "$arg_arg",
+		},
+		{
+			input: conf_v1.Condition{
+				Variable: "$request_method",
+			},
+			expected: "$request_method",
+		},
+	}
+
+	for _, test := range tests {
+		result := getNameForSourceForMatchesRouteMapFromCondition(test.input)
+		if result != test.expected {
+			t.Errorf("getNameForSourceForMatchesRouteMapFromCondition() returned %q but expected %q for input %v", result, test.expected, test.input)
+		}
+	}
+}
+
+func TestGenerateLBMethod(t *testing.T) {
+	defaultMethod := "random two least_conn"
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			input:    "",
+			expected: defaultMethod,
+		},
+		{
+			input:    "round_robin",
+			expected: "",
+		},
+		{
+			input:    "random",
+			expected: "random",
+		},
+	}
+	for _, test := range tests {
+		result := generateLBMethod(test.input, defaultMethod)
+		if result != test.expected {
+			t.Errorf("generateLBMethod() returned %q but expected %q for input '%v'", result, test.expected, test.input)
+		}
+	}
+}
+
+func TestUpstreamHasKeepalive(t *testing.T) {
+	noKeepalive := 0
+	keepalive := 32
+
+	tests := []struct {
+		upstream  conf_v1.Upstream
+		cfgParams *ConfigParams
+		expected  bool
+		msg       string
+	}{
+		{
+			conf_v1.Upstream{},
+			&ConfigParams{Keepalive: keepalive},
+			true,
+			"upstream keepalive not set, configparam keepalive set",
+		},
+		{
+			conf_v1.Upstream{Keepalive: &noKeepalive},
+			&ConfigParams{Keepalive: keepalive},
+			false,
+			"upstream keepalive set to 0, configparam keepive set",
+		},
+		{
+			conf_v1.Upstream{Keepalive: &keepalive},
+			&ConfigParams{Keepalive: noKeepalive},
+			true,
+			"upstream keepalive set, configparam keepalive set to 0",
+		},
+	}
+
+	for _, test := range tests {
+		result := upstreamHasKeepalive(test.upstream, test.cfgParams)
+		if result != test.expected {
+			t.Errorf("upstreamHasKeepalive() returned %v, but expected %v for the case of %v", result, test.expected, test.msg)
+		}
+	}
+}
+
+func TestNewHealthCheckWithDefaults(t *testing.T) {
+	upstreamName := "test-upstream"
+	baseCfgParams := &ConfigParams{
+		ProxySendTimeout:    "5s",
+		ProxyReadTimeout:    "5s",
+		ProxyConnectTimeout: "5s",
+	}
+	expected := &version2.HealthCheck{
+		Name:                upstreamName,
+		ProxySendTimeout:    "5s",
+		ProxyReadTimeout:    "5s",
+		ProxyConnectTimeout: "5s",
+		ProxyPass:           fmt.Sprintf("http://%v", upstreamName),
+		URI:                 "/",
+		Interval:            "5s",
+		Jitter:              "0s",
+		Fails:               1,
+		Passes:              1,
+		Headers:             make(map[string]string),
+	}
+
+	result := newHealthCheckWithDefaults(conf_v1.Upstream{}, upstreamName, baseCfgParams)
+
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("newHealthCheckWithDefaults returned \n%v but expected \n%v", result, expected)
+	}
+}
+
+func TestAddHealthCheckTrustedCert(t *testing.T) {
+	virtualServer := &conf_v1.VirtualServer{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "cafe",
+			Namespace: "default",
+		},
+	}
+	upstream := conf_v1.Upstream{
+		Name: "tea",
+		HealthCheck: &conf_v1.HealthCheck{
+			Enable: true,
+			TLS: &conf_v1.HealthCheckTLS{
+				Enable:            true,
+				VerifyServer:      true,
+				TrustedCertSecret: "tea-ca",
+			},
+		},
+	}
+
+	vsc := newVirtualServerConfigurator(&ConfigParams{}, true, false)
+
+	hc := &version2.HealthCheck{}
+	vsc.addHealthCheckTrustedCert(virtualServer, "default", upstream, hc, map[string]string{"default/tea-ca": "/etc/nginx/secrets/default-tea-ca"})
+	if !hc.ProxySSLVerify || hc.ProxySSLTrustedCert != "/etc/nginx/secrets/default-tea-ca" {
+		t.Errorf("addHealthCheckTrustedCert() configured %+v but expected the verification with the CA certificate", hc)
+	}
+	if len(vsc.warnings) != 0 {
+		t.Errorf("addHealthCheckTrustedCert() returned warnings %v for a valid Secret", vsc.warnings)
+	}
+
+	hc = &version2.HealthCheck{}
+	vsc.addHealthCheckTrustedCert(virtualServer, "default", upstream, hc, map[string]string{})
+	if hc.ProxySSLVerify {
+		t.Errorf("addHealthCheckTrustedCert() configured %+v but expected no verification for a missing Secret", hc)
+	}
+	if len(vsc.warnings[virtualServer]) != 1 {
+		t.Errorf("addHealthCheckTrustedCert() returned warnings %v but expected one warning for a missing Secret", vsc.warnings)
+	}
+}
+
+func TestAddHealthCheckServicePort(t *testing.T) {
+	virtualServer := &conf_v1.VirtualServer{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "cafe",
+			Namespace: "default",
+		},
+	}
+	upstream := conf_v1.Upstream{
+		Name:    "tea",
+		Service: "tea-svc",
+		HealthCheck: &conf_v1.HealthCheck{
+			Enable:   true,
+			PortName: "admin",
+		},
+	}
+
+	vsc := newVirtualServerConfigurator(&ConfigParams{}, true, false)
+
+	hc := &version2.HealthCheck{}
+	vsc.addHealthCheckServicePort(virtualServer, "default", upstream, hc, map[string]int32{"default/tea-svc:admin": 9090})
+	if hc.Port != 9090 {
+		t.Errorf("addHealthCheckServicePort() configured the port %v but expected 9090", hc.Port)
+	}
+	if len(vsc.warnings) != 0 {
+		t.Errorf("addHealthCheckServicePort() returned warnings %v for a resolved port", vsc.warnings)
+	}
+
+	hc = &version2.HealthCheck{}
+	vsc.addHealthCheckServicePort(virtualServer, "default", upstream, hc, map[string]int32{})
+	if hc.Port != 0 {
+		t.Errorf("addHealthCheckServicePort() configured the port %v but expected the ports of the upstream servers", hc.Port)
+	}
+	if len(vsc.warnings[virtualServer]) != 1 {
+		t.Errorf("addHealthCheckServicePort() returned warnings %v but expected one warning for an unresolved port", vsc.warnings)
+	}
+}
+
+func TestGenerateUpstreamStatusMatch(t *testing.T) {
+	tests := []struct {
+		hc       *conf_v1.HealthCheck
+		expected version2.StatusMatch
+		msg      string
+	}{
+		{
+			hc: &conf_v1.HealthCheck{
+				StatusMatch: "! 500",
+			},
+			expected: version2.StatusMatch{
+				Name: "test-upstream_match",
+				Code: "! 500",
+			},
+			msg: "status codes",
+		},
+		{
+			hc: &conf_v1.HealthCheck{
+				BodyMatch: `"status":\s*"ok"`,
+				HeaderMatches: []conf_v1.HeaderMatch{
+					{
+						Name: "X-Ready",
+					},
+					{
+						Name:  "Content-Type",
+						Value: "application/json",
+					},
+					{
+						Name:       "X-Version",
+						ValueRegex: "!^v1",
+					},
+				},
+			},
+			expected: version2.StatusMatch{
+				Name: "test-upstream_match",
+				Code: "200-399",
+				Headers: []string{
+					"X-Ready",
+					`Content-Type = "application/json"`,
+					`X-Version !~ "^v1"`,
+				},
+				Body: `~ "\"status\":\\s*\"ok\""`,
+			},
+			msg: "body and headers with the default status codes",
+		},
+	}
+
+	for _, test := range tests {
+		result := generateUpstreamStatusMatch("test-upstream", test.hc)
+		if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("generateUpstreamStatusMatch() returned %v but expected %v for the case of %s", result, test.expected, test.msg)
+		}
+	}
+}
+
+func TestGenerateHealthCheck(t *testing.T) {
+	upstreamName := "test-upstream"
+	tests := []struct {
+		upstream     conf_v1.Upstream
+		upstreamName string
+		expected     *version2.HealthCheck
+		msg          string
+	}{
+		{
+
+			upstream: conf_v1.Upstream{
+				HealthCheck: &conf_v1.HealthCheck{
+					Enable:         true,
+					Path:           "/healthz",
+					Interval:       "5s",
+					Jitter:         "2s",
+					Fails:          3,
+					Passes:         2,
+					Port:           8080,
+					ConnectTimeout: "20s",
+					SendTimeout:    "20s",
+					ReadTimeout:    "20s",
+					Headers: []conf_v1.Header{
+						{
+							Name:  "Host",
+							Value: "my.service",
+						},
+						{
+							Name:  "User-Agent",
+							Value: "nginx",
+						},
+					},
+					StatusMatch:   "! 500",
+					Mandatory:     true,
+					Persistent:    true,
+					KeepaliveTime: "60s",
+				},
+			},
+			upstreamName: upstreamName,
+			expected: &version2.HealthCheck{
+				Name:                upstreamName,
+				ProxyConnectTimeout: "20s",
+				ProxySendTimeout:    "20s",
+				ProxyReadTimeout:    "20s",
+				ProxyPass:           fmt.Sprintf("http://%v", upstreamName),
+				URI:                 "/healthz",
+				Interval:            "5s",
+				Jitter:              "2s",
+				Fails:               3,
+				Passes:              2,
+				Port:                8080,
+				Headers: map[string]string{
+					"Host":       "my.service",
+					"User-Agent": "nginx",
+				},
+				Match:         fmt.Sprintf("%v_match", upstreamName),
+				Mandatory:     true,
+				Persistent:    true,
+				KeepaliveTime: "60s",
+			},
+			msg: "HealthCheck with changed parameters",
+		},
+		{
+			upstream: conf_v1.Upstream{
+				HealthCheck: &conf_v1.HealthCheck{
+					Enable: true,
+				},
+				ProxyConnectTimeout: "30s",
+				ProxyReadTimeout:    "30s",
+				ProxySendTimeout:    "30s",
+			},
+			upstreamName: upstreamName,
+			expected: &version2.HealthCheck{
+				Name:                upstreamName,
+				ProxyConnectTimeout: "30s",
+				ProxyReadTimeout:    "30s",
+				ProxySendTimeout:    "30s",
+				ProxyPass:           fmt.Sprintf("http://%v", upstreamName),
+				URI:                 "/",
+				Interval:            "5s",
+				Jitter:              "0s",
+				Fails:               1,
+				Passes:              1,
+				Headers:             make(map[string]string),
+			},
+			msg: "HealthCheck with default parameters from Upstream",
+		},
+		{
+			upstream: conf_v1.Upstream{
+				HealthCheck: &conf_v1.HealthCheck{
+					Enable: true,
+				},
+			},
+			upstreamName: upstreamName,
+			expected: &version2.HealthCheck{
+				Name:                upstreamName,
+				ProxyConnectTimeout: "5s",
+				ProxyReadTimeout:    "5s",
+				ProxySendTimeout:    "5s",
+				ProxyPass:           fmt.Sprintf("http://%v", upstreamName),
+				URI:                 "/",
+				Interval:            "5s",
+				Jitter:              "0s",
+				Fails:               1,
+				Passes:              1,
+				Headers:             make(map[string]string),
+			},
+			msg: "HealthCheck with default parameters from ConfigMap (not defined in Upstream)",
+		},
+		{
+			upstream: conf_v1.Upstream{
+				HealthCheck: &conf_v1.HealthCheck{
+					Enable:          true,
+					CloseConnection: true,
+				},
+			},
+			upstreamName: upstreamName,
+			expected: &version2.HealthCheck{
+				Name:                upstreamName,
+				ProxyConnectTimeout: "5s",
+				ProxyReadTimeout:    "5s",
+				ProxySendTimeout:    "5s",
+				ProxyPass:           fmt.Sprintf("http://%v", upstreamName),
+				URI:                 "/",
+				Interval:            "5s",
+				Jitter:              "0s",
+				Fails:               1,
+				Passes:              1,
+				Headers: map[string]string{
+					"Connection": "close",
+				},
+			},
+			msg: "HealthCheck with closed connections",
+		},
+		{
+			upstream: conf_v1.Upstream{
+				HealthCheck: &conf_v1.HealthCheck{
+					Enable: true,
+					TLS: &conf_v1.HealthCheckTLS{
+						Enable:     true,
+						ServerName: true,
+						SSLName:    "tea.example.com",
+					},
+				},
+			},
+			upstreamName: upstreamName,
+			expected: &version2.HealthCheck{
+				Name:                upstreamName,
+				ProxyConnectTimeout: "5s",
+				ProxyReadTimeout:    "5s",
+				ProxySendTimeout:    "5s",
+				ProxyPass:           fmt.Sprintf("https://%v", upstreamName),
+				URI:                 "/",
+				Interval:            "5s",
+				Jitter:              "0s",
+				Fails:               1,
+				Passes:              1,
+				Headers: map[string]string{
+					"Host": "tea.example.com",
+				},
+				ProxySSLServerName: true,
+				ProxySSLName:       "tea.example.com",
+			},
+			msg: "HealthCheck with SNI",
+		},
+		{
+			upstream:     conf_v1.Upstream{},
+			upstreamName: upstreamName,
+			expected:     nil,
+			msg:          "HealthCheck not enabled",
+		},
+	}
+
+	baseCfgParams := &ConfigParams{
+		ProxySendTimeout:    "5s",
+		ProxyReadTimeout:    "5s",
+		ProxyConnectTimeout: "5s",
+	}
+
+	for _, test := range tests {
+		result := generateHealthCheck(test.upstream, test.upstreamName, baseCfgParams)
+		if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("generateHealthCheck returned \n%v but expected \n%v \n for case: %v", result, test.expected, test.msg)
+		}
+	}
+}
+
+func TestGenerateHealthCheckWithConfigMapDefaults(t *testing.T) {
+	upstream := conf_v1.Upstream{
+		HealthCheck: &conf_v1.HealthCheck{
+			Enable: true,
+			Passes: 3,
+		},
+	}
+	cfgParams := &ConfigParams{
+		ProxySendTimeout:    "5s",
+		ProxyReadTimeout:    "5s",
+		ProxyConnectTimeout: "5s",
+		HealthCheckInterval: "10s",
+		HealthCheckJitter:   "1s",
+		HealthCheckFails:    2,
+		HealthCheckPasses:   2,
+	}
+	expected := &version2.HealthCheck{
+		Name:                "test-upstream",
+		ProxyConnectTimeout: "5s",
+		ProxyReadTimeout:    "5s",
+		ProxySendTimeout:    "5s",
+		ProxyPass:           "http://test-upstream",
+		URI:                 "/",
+		Interval:            "10s",
+		Jitter:              "1s",
+		Fails:               2,
+		Passes:              3,
+		Headers:             make(map[string]string),
+	}
+
+	result := generateHealthCheck(upstream, "test-upstream", cfgParams)
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("generateHealthCheck() returned %v but expected %v", result, expected)
+	}
+}
+
+func TestAddExternalNameAddressesToLocations(t *testing.T) {
+	upstreams := []version2.Upstream{
+		{Name: "vs_default_cafe_tea"},
+		{Name: "vs_default_cafe_coffee"},
+		{Name: "vs_default_cafe_external"},
+	}
+	crUpstreams := map[string]conf_v1.Upstream{
+		"vs_default_cafe_tea":      {Service: "tea-svc"},
+		"vs_default_cafe_coffee":   {Service: "coffee-svc"},
+		"vs_default_cafe_external": {Service: "external-svc", TLS: conf_v1.UpstreamTLS{Enable: true}},
+	}
+	addresses := map[string]string{
+		"vs_default_cafe_external": "example.com:443",
+	}
+	locations := []version2.Location{
+		{Path: "/tea", ProxyPass: "http://vs_default_cafe_tea"},
+		{Path: "/external", ProxyPass: "https://vs_default_cafe_external"},
+		{Path: "@splits_0_split_0", ProxyPass: "https://vs_default_cafe_external"},
+	}
+
+	expectedLocations := []version2.Location{
+		{Path: "/tea", ProxyPass: "http://vs_default_cafe_tea"},
+		{Path: "/external", ProxyPass: "https://$vs_default_cafe_external_address"},
+		{Path: "@splits_0_split_0", ProxyPass: "https://$vs_default_cafe_external_address"},
+	}
+	expectedMaps := []version2.Map{
+		{
+			Source:   "$host",
+			Variable: "$vs_default_cafe_external_address",
+			Parameters: []version2.Parameter{
+				{
+					Value:  "default",
+					Result: `"example.com:443"`,
+				},
+			},
+		},
+	}
+
+	maps := addExternalNameAddressesToLocations(upstreams, crUpstreams, addresses, locations)
+	if !reflect.DeepEqual(maps, expectedMaps) {
+		t.Errorf("addExternalNameAddressesToLocations() returned maps %+v but expected %+v", maps, expectedMaps)
+	}
+	if !reflect.DeepEqual(locations, expectedLocations) {
+		t.Errorf("addExternalNameAddressesToLocations() changed the locations to %+v but expected %+v", locations, expectedLocations)
+	}
+}
+
+func TestGenerateEndpointsForUpstream(t *testing.T) {
+	name := "test"
+	namespace := "test-namespace"
+
+	tests := []struct {
+		upstream             conf_v1.Upstream
+		vsEx                 *VirtualServerEx
+		isPlus               bool
+		isResolverConfigured bool
+		expected             []string
+		warningsExpected     bool
+		msg                  string
+	}{
+		{
+			upstream: conf_v1.Upstream{
+				Service: name,
+				Port:    80,
+			},
+			vsEx: &VirtualServerEx{
+				VirtualServer: &conf_v1.VirtualServer{
+					ObjectMeta: meta_v1.ObjectMeta{
+						Name:      name,
+						Namespace: namespace,
+					},
+				},
+				Endpoints: map[string][]string{
+					"test-namespace/test:80": {"example.com:80"},
+				},
+				ExternalNameSvcs: map[string]bool{
+					"test-namespace/test": true,
+				},
+			},
+			isPlus:               true,
+			isResolverConfigured: true,
+			expected:             []string{"example.com:80"},
+			msg:                  "ExternalName service",
+		},
+		{
+			upstream: conf_v1.Upstream{
+				Service: name,
+				Port:    80,
+			},
+			vsEx: &VirtualServerEx{
+				VirtualServer: &conf_v1.VirtualServer{
+					ObjectMeta: meta_v1.ObjectMeta{
+						Name:      name,
+						Namespace: namespace,
+					},
+				},
+				Endpoints: map[string][]string{
+					"test-namespace/test:80": {"example.com:80"},
+				},
+				ExternalNameSvcs: map[string]bool{
+					"test-namespace/test": true,
+				},
+			},
+			isPlus:               true,
+			isResolverConfigured: false,
+			warningsExpected:     true,
+			expected:             []string{},
+			msg:                  "ExternalName service without resolver configured",
+		},
+		{
+			upstream: conf_v1.Upstream{
+				Service: name,
+				Port:    80,
+			},
+			vsEx: &VirtualServerEx{
+				VirtualServer: &conf_v1.VirtualServer{
+					ObjectMeta: meta_v1.ObjectMeta{
+						Name:      name,
+						Namespace: namespace,
+					},
+				},
+				Endpoints: map[string][]string{
+					"test-namespace/test:80": {"example.com:80"},
+				},
+				ExternalNameSvcs: map[string]bool{
+					"test-namespace/test": true,
+				},
+			},
+			isPlus:               false,
+			isResolverConfigured: true,
+			expected:             []string{nginx502Server},
+			msg:                  "ExternalName service in NGINX",
+		},
+		{
+			upstream: conf_v1.Upstream{
+				Service: name,
+				Port:    80,
+			},
+			vsEx: &VirtualServerEx{
+				VirtualServer: &conf_v1.VirtualServer{
+					ObjectMeta: meta_v1.ObjectMeta{
+						Name:      name,
+						Namespace: namespace,
+					},
+				},
+				Endpoints: map[string][]string{
+					"test-namespace/test:80": {"example.com:80"},
+				},
+				ExternalNameSvcs: map[string]bool{
+					"test-namespace/test": true,
+				},
+			},
+			isPlus:               false,
+			isResolverConfigured: false,
+			warningsExpected:     true,
+			expected:             []string{nginx502Server},
+			msg:                  "ExternalName service in NGINX without resolver configured",
+		},
+		{
+			upstream: conf_v1.Upstream{
+				Service: name,
+				Port:    8080,
+			},
+			vsEx: &VirtualServerEx{
+				VirtualServer: &conf_v1.VirtualServer{
+					ObjectMeta: meta_v1.ObjectMeta{
+						Name:      name,
+						Namespace: namespace,
+					},
+				},
+				Endpoints: map[string][]string{
+					"test-namespace/test:8080": {"192.168.10.10:8080"},
+				},
+			},
+			isPlus:               false,
+			isResolverConfigured: false,
+			expected:             []string{"192.168.10.10:8080"},
+			msg:                  "Service with endpoints",
+		},
+		{
+			upstream: conf_v1.Upstream{
+				Service: name,
+				Port:    8080,
+			},
+			vsEx: &VirtualServerEx{
+				VirtualServer: &conf_v1.VirtualServer{
+					ObjectMeta: meta_v1.ObjectMeta{
+						Name:      name,
+						Namespace: namespace,
+					},
+				},
+				Endpoints: map[string][]string{},
+			},
+			isPlus:               false,
+			isResolverConfigured: false,
+			expected:             []string{nginx502Server},
+			msg:                  "Service with no endpoints",
+		},
+		{
+			upstream: conf_v1.Upstream{
+				Service: name,
+				Port:    8080,
+			},
+			vsEx: &VirtualServerEx{
+				VirtualServer: &conf_v1.VirtualServer{
+					ObjectMeta: meta_v1.ObjectMeta{
+						Name:      name,
+						Namespace: namespace,
+					},
+				},
+				Endpoints: map[string][]string{},
+			},
+			isPlus:               true,
+			isResolverConfigured: false,
+			expected:             nil,
+			msg:                  "Service with no endpoints",
+		},
+		{
+			upstream: conf_v1.Upstream{
+				Service:     name,
+				Port:        8080,
+				Subselector: map[string]string{"version": "test"},
+			},
+			vsEx: &VirtualServerEx{
+				VirtualServer: &conf_v1.VirtualServer{
+					ObjectMeta: meta_v1.ObjectMeta{
+						Name:      name,
+						Namespace: namespace,
+					},
+				},
+				Endpoints: map[string][]string{
+					"test-namespace/test_version=test:8080": {"192.168.10.10:8080"},
+				},
+			},
+			isPlus:               false,
+			isResolverConfigured: false,
+			expected:             []string{"192.168.10.10:8080"},
+			msg:                  "Upstream with subselector, with a matching endpoint",
+		},
+		{
+			upstream: conf_v1.Upstream{
+				Service:     name,
+				Port:        8080,
+				Subselector: map[string]string{"version": "test"},
+			},
+			vsEx: &VirtualServerEx{
+				VirtualServer: &conf_v1.VirtualServer{
+					ObjectMeta: meta_v1.ObjectMeta{
+						Name:      name,
+						Namespace: namespace,
+					},
+				},
+				Endpoints: map[string][]string{
+					"test-namespace/test:8080": {"192.168.10.10:8080"},
+				},
+			},
+			isPlus:               false,
+			isResolverConfigured: false,
+			expected:             []string{nginx502Server},
+			msg:                  "Upstream with subselector, without a matching endpoint",
+		},
+		{
+			upstream: conf_v1.Upstream{
+				Service: name,
+				Port:    8080,
+				Resolve: true,
+			},
+			vsEx: &VirtualServerEx{
+				VirtualServer: &conf_v1.VirtualServer{
+					ObjectMeta: meta_v1.ObjectMeta{
+						Name:      name,
+						Namespace: namespace,
+					},
+				},
+				Endpoints: map[string][]string{
+					"test-namespace/test:8080": {"192.168.10.10:8080"},
+				},
+			},
+			isPlus:               true,
+			isResolverConfigured: true,
+			expected:             []string{"test.test-namespace.svc.cluster.local:8080"},
+			msg:                  "Upstream with resolve",
+		},
+		{
+			upstream: conf_v1.Upstream{
+				Service: name,
+				Port:    8080,
+				Resolve: true,
+			},
+			vsEx: &VirtualServerEx{
+				VirtualServer: &conf_v1.VirtualServer{
+					ObjectMeta: meta_v1.ObjectMeta{
+						Name:      name,
+						Namespace: namespace,
+					},
+				},
+				Endpoints: map[string][]string{
+					"test-namespace/test:8080": {"192.168.10.10:8080"},
+				},
+			},
+			isPlus:               true,
+			isResolverConfigured: false,
+			warningsExpected:     true,
+			expected:             []string{"192.168.10.10:8080"},
+			msg:                  "Upstream with resolve without resolver configured",
+		},
+		{
+			upstream: conf_v1.Upstream{
+				Service: name,
+				Port:    8080,
+			},
+			vsEx: &VirtualServerEx{
+				VirtualServer: &conf_v1.VirtualServer{
+					ObjectMeta: meta_v1.ObjectMeta{
+						Name:      name,
+						Namespace: namespace,
+					},
+				},
+				Endpoints: map[string][]string{
+					"test-namespace/test:8080": {"192.168.10.10:8080", "192.168.10.11:8080"},
+				},
+				DrainedEndpoints: map[string]bool{
+					"192.168.10.11:8080": true,
+				},
+			},
+			isPlus:               false,
+			isResolverConfigured: false,
+			expected:             []string{"192.168.10.10:8080"},
+			msg:                  "Upstream with a drained endpoint",
+		},
+		{
+			upstream: conf_v1.Upstream{
+				Service: name,
+				Port:    8080,
+			},
+			vsEx: &VirtualServerEx{
+				VirtualServer: &conf_v1.VirtualServer{
+					ObjectMeta: meta_v1.ObjectMeta{
+						Name:      name,
+						Namespace: namespace,
+					},
+				},
+				Endpoints: map[string][]string{
+					"test-namespace/test:8080": {"192.168.10.10:8080"},
+				},
+				DrainedEndpoints: map[string]bool{
+					"192.168.10.10:8080": true,
+				},
+			},
+			isPlus:               false,
+			isResolverConfigured: false,
+			expected:             []string{nginx502Server},
+			msg:                  "Upstream with all endpoints drained",
+		},
+		{
+			upstream: conf_v1.Upstream{
+				Service: name,
+				Port:    8080,
+			},
+			vsEx: &VirtualServerEx{
+				VirtualServer: &conf_v1.VirtualServer{
+					ObjectMeta: meta_v1.ObjectMeta{
+						Name:      name,
+						Namespace: namespace,
+					},
+				},
+				Endpoints: map[string][]string{
+					"test-namespace/test:8080": {"192.168.10.10:8080", "192.168.10.11:8080"},
+				},
+				DrainedEndpoints: map[string]bool{
+					"192.168.10.11:8080": true,
+				},
+			},
+			isPlus:               true,
+			isResolverConfigured: false,
+			expected:             []string{"192.168.10.10:8080", "192.168.10.11:8080"},
+			msg:                  "Upstream with a drained endpoint in NGINX Plus",
+		},
+	}
+
+	for _, test := range tests {
+		vsc := newVirtualServerConfigurator(&ConfigParams{ResolverClusterDomain: "cluster.local"}, test.isPlus, test.isResolverConfigured)
+		result := vsc.generateEndpointsForUpstream(test.vsEx.VirtualServer, namespace, test.upstream, test.vsEx)
+		if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("generateEndpointsForUpstream(isPlus=%v, isResolverConfigured=%v) returned %v, but expected %v for case: %v",
+				test.isPlus, test.isResolverConfigured, result, test.expected, test.msg)
+		}
+
+		if len(vsc.warnings) == 0 && test.warningsExpected {
+			t.Errorf("generateEndpointsForUpstream(isPlus=%v, isResolverConfigured=%v) didn't return any warnings for %v but warnings expected",
+				test.isPlus, test.isResolverConfigured, test.upstream)
+		}
+
+		if len(vsc.warnings) != 0 && !test.warningsExpected {
+			t.Errorf("generateEndpointsForUpstream(isPlus=%v, isResolverConfigured=%v) returned warnings for %v",
+				test.isPlus, test.isResolverConfigured, test.upstream)
+		}
+	}
+}
+
+func TestGenerateSlowStartForPlusWithInCompatibleLBMethods(t *testing.T) {
+	serviceName := "test-slowstart-with-incompatible-LBMethods"
+	upstream := conf_v1.Upstream{Service: serviceName, Port: 80, SlowStart: "10s"}
+	expected := ""
+
+	var tests = []string{
+		"random",
+		"ip_hash",
+		"hash 123",
+		"random two",
+		"random two least_conn",
+		"random two least_time=header",
+		"random two least_time=last_byte",
+	}
+
+	for _, lbMethod := range tests {
+		vsc := newVirtualServerConfigurator(&ConfigParams{}, true, false)
+		result := vsc.generateSlowStartForPlus(&conf_v1.VirtualServer{}, upstream, lbMethod)
+
+		if !reflect.DeepEqual(result, expected) {
+			t.Errorf("generateSlowStartForPlus returned %v, but expected %v for lbMethod %v", result, expected, lbMethod)
+		}
+
+		if len(vsc.warnings) == 0 {
+			t.Errorf("generateSlowStartForPlus returned no warnings for %v but warnings expected", upstream)
+		}
+	}
+
+}
+
+func TestCheckContradictoryUpstreamSettings(t *testing.T) {
+	owner := &conf_v1.VirtualServer{}
+
+	tests := []struct {
+		upstream         conf_v1.Upstream
+		expectedWarnings Warnings
+		msg              string
+	}{
+		{
+			upstream: conf_v1.Upstream{
+				Name: "tea",
+				Port: 443,
+				TLS:  conf_v1.UpstreamTLS{Enable: true},
+				HealthCheck: &conf_v1.HealthCheck{
+					Enable: true,
+					TLS:    &conf_v1.HealthCheckTLS{Enable: true},
+				},
+			},
+			expectedWarnings: Warnings{},
+			msg:              "consistent settings",
+		},
+		{
+			upstream: conf_v1.Upstream{
+				Name: "tea",
+				Port: 80,
+				TLS:  conf_v1.UpstreamTLS{Enable: true},
+			},
+			expectedWarnings: Warnings{
+				owner: {
+					{
+						Code:     WarningCodeContradictorySetting,
+						Severity: WarningSeverityMedium,
+						Message:  "Upstream tea: tls.enable is true, but port 80 is used for plain HTTP. NGINX will attempt a TLS handshake with port 80",
+					},
+				},
+			},
+			msg: "tls with port 80",
+		},
+		{
+			upstream: conf_v1.Upstream{
+				Name: "tea",
+				Port: 443,
+			},
+			expectedWarnings: Warnings{
+				owner: {
+					{
+						Code:     WarningCodeContradictorySetting,
+						Severity: WarningSeverityMedium,
+						Message:  "Upstream tea: port 443 is used for HTTPS, but tls.enable is false. NGINX will send plain HTTP requests to port 443",
+					},
+				},
+			},
+			msg: "no tls with port 443",
+		},
+		{
+			upstream: conf_v1.Upstream{
+				Name:           "tea",
+				Port:           8080,
+				ProxyBuffering: createPointerFromBool(false),
+				ProxyBuffers: &conf_v1.UpstreamBuffers{
+					Number: 8,
+					Size:   "4k",
+				},
+			},
+			expectedWarnings: Warnings{
+				owner: {
+					{
+						Code:     WarningCodeContradictorySetting,
+						Severity: WarningSeverityLow,
+						Message:  "Upstream tea: buffers has no effect because buffering is false",
+					},
+				},
+			},
+			msg: "buffers with buffering off",
+		},
+		{
+			upstream: conf_v1.Upstream{
+				Name:                   "tea",
+				Port:                   8080,
+				ProxyNextUpstream:      "off",
+				ProxyNextUpstreamTries: 3,
+			},
+			expectedWarnings: Warnings{
+				owner: {
+					{
+						Code:     WarningCodeContradictorySetting,
+						Severity: WarningSeverityLow,
+						Message:  "Upstream tea: next-upstream-tries and next-upstream-timeout have no effect because next-upstream is off",
+					},
+				},
+			},
+			msg: "next upstream tries with next upstream off",
+		},
+		{
+			upstream: conf_v1.Upstream{
+				Name: "tea",
+				Port: 8443,
+				TLS:  conf_v1.UpstreamTLS{Enable: true},
+				HealthCheck: &conf_v1.HealthCheck{
+					Enable: true,
+					TLS:    &conf_v1.HealthCheckTLS{Enable: false},
+				},
+			},
+			expectedWarnings: Warnings{
+				owner: {
+					{
+						Code:     WarningCodeContradictorySetting,
+						Severity: WarningSeverityMedium,
+						Message:  "Upstream tea: healthCheck.tls.enable is false, but tls.enable is true for the same port. Health checks will use a different protocol than the requests",
+					},
+				},
+			},
+			msg: "health check with a different protocol",
+		},
+		{
+			upstream: conf_v1.Upstream{
+				Name: "tea",
+				Port: 8443,
+				TLS:  conf_v1.UpstreamTLS{Enable: true},
+				HealthCheck: &conf_v1.HealthCheck{
+					Enable: true,
+					Port:   8080,
+					TLS:    &conf_v1.HealthCheckTLS{Enable: false},
+				},
+			},
+			expectedWarnings: Warnings{},
+			msg:              "health check with a different protocol on a different port",
+		},
+		{
+			upstream: conf_v1.Upstream{
+				Name:                   "tea",
+				Port:                   8080,
+				ProxyRequestBuffering:  createPointerFromBool(false),
+				ProxyNextUpstreamTries: 3,
+			},
+			expectedWarnings: Warnings{
+				owner: {
+					{
+						Code:     WarningCodeContradictorySetting,
+						Severity: WarningSeverityLow,
+						Message:  "Upstream tea: request-buffering is false, so a request is not passed to the next server once its body has been sent. The retries only apply before the body is sent",
+					},
+				},
+			},
+			msg: "retries without request buffering",
+		},
+		{
+			upstream: conf_v1.Upstream{
+				Name:                  "tea",
+				Port:                  8080,
+				ProxyRequestBuffering: createPointerFromBool(false),
+				ProxyNextUpstream:     "off",
+			},
+			expectedWarnings: Warnings{},
+			msg:              "no retries without request buffering",
+		},
+	}
+
+	for _, test := range tests {
+		vsc := newVirtualServerConfigurator(&ConfigParams{}, false, false)
+		vsc.checkContradictoryUpstreamSettings(owner, test.upstream)
+
+		if !reflect.DeepEqual(vsc.warnings, test.expectedWarnings) {
+			t.Errorf("checkContradictoryUpstreamSettings() returned warnings of \n%v but expected \n%v for the case of %s", vsc.warnings, test.expectedWarnings, test.msg)
+		}
+	}
+}
+
+func TestCheckUpstreamRetries(t *testing.T) {
+	owner := &conf_v1.VirtualServer{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "cafe",
+			Namespace: "default",
+		},
+	}
+
+	tests := []struct {
+		upstream         conf_v1.Upstream
+		expectedWarnings Warnings
+		msg              string
+	}{
+		{
+			upstream: conf_v1.Upstream{
+				Name: "tea",
+				Retries: &conf_v1.UpstreamRetries{
+					Conditions: []string{"error", "timeout"},
+				},
+			},
+			expectedWarnings: Warnings{},
+			msg:              "retries of idempotent requests",
+		},
+		{
+			upstream: conf_v1.Upstream{
+				Name: "tea",
+				Retries: &conf_v1.UpstreamRetries{
+					Conditions: []string{"error", "non_idempotent"},
+				},
+			},
+			expectedWarnings: Warnings{
+				owner: {
+					{
+						Code:     WarningCodeUnsafeRetries,
+						Severity: WarningSeverityMedium,
+						Message:  "Upstream tea: retries.conditions includes non_idempotent, so the requests with non-idempotent methods, like POST, can be processed by several servers",
+					},
+				},
+			},
+			msg: "retries of non-idempotent requests",
+		},
+		{
+			upstream: conf_v1.Upstream{
+				Name:              "tea",
+				ProxyNextUpstream: "error timeout non_idempotent",
+			},
+			expectedWarnings: Warnings{
+				owner: {
+					{
+						Code:     WarningCodeUnsafeRetries,
+						Severity: WarningSeverityMedium,
+						Message:  "Upstream tea: next-upstream includes non_idempotent, so the requests with non-idempotent methods, like POST, can be processed by several servers",
+					},
+				},
+			},
+			msg: "next-upstream with non-idempotent requests",
+		},
+	}
+
+	for _, test := range tests {
+		vsc := newVirtualServerConfigurator(&ConfigParams{}, false, false)
+		vsc.checkUpstreamRetries(owner, test.upstream)
+
+		if !reflect.DeepEqual(vsc.warnings, test.expectedWarnings) {
+			t.Errorf("checkUpstreamRetries() returned warnings of \n%v but expected \n%v for the case of %s", vsc.warnings, test.expectedWarnings, test.msg)
+		}
+	}
+}
+
+func TestAddRetriesToLocation(t *testing.T) {
+	tests := []struct {
+		retries  *conf_v1.UpstreamRetries
+		expected version2.Location
+		msg      string
+	}{
+		{
+			retries: &conf_v1.UpstreamRetries{},
+			expected: version2.Location{
+				ProxyReadTimeout:         "60s",
+				ProxyNextUpstream:        "error timeout",
+				ProxyNextUpstreamTimeout: "0s",
+				ProxyNextUpstreamTries:   3,
+			},
+			msg: "default retries",
+		},
+		{
+			retries: &conf_v1.UpstreamRetries{
+				Conditions:    []string{"error", "http_503"},
+				Tries:         2,
+				PerTryTimeout: "5s",
+				Budget:        "15s",
+			},
+			expected: version2.Location{
+				ProxyReadTimeout:         "5s",
+				ProxyNextUpstream:        "error http_503",
+				ProxyNextUpstreamTimeout: "15s",
+				ProxyNextUpstreamTries:   2,
+			},
+			msg: "custom retries",
+		},
+	}
+
+	for _, test := range tests {
+		loc := version2.Location{
+			ProxyReadTimeout: "60s",
+		}
+
+		addRetriesToLocation(test.retries, &loc)
+		if !reflect.DeepEqual(loc, test.expected) {
+			t.Errorf("addRetriesToLocation() returned %+v but expected %+v for the case of %s", loc, test.expected, test.msg)
+		}
+	}
+}
+
+func TestGenerateSlowStartForPlus(t *testing.T) {
+	serviceName := "test-slowstart"
+
+	tests := []struct {
+		upstream conf_v1.Upstream
+		lbMethod string
+		expected string
+	}{
+		{
+			upstream: conf_v1.Upstream{Service: serviceName, Port: 80, SlowStart: "", LBMethod: "least_conn"},
+			lbMethod: "least_conn",
+			expected: "",
+		},
+		{
+			upstream: conf_v1.Upstream{Service: serviceName, Port: 80, SlowStart: "10s", LBMethod: "least_conn"},
+			lbMethod: "least_conn",
+			expected: "10s",
+		},
+	}
+
+	for _, test := range tests {
+		vsc := newVirtualServerConfigurator(&ConfigParams{}, true, false)
+		result := vsc.generateSlowStartForPlus(&conf_v1.VirtualServer{}, test.upstream, test.lbMethod)
+		if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("generateSlowStartForPlus returned %v, but expected %v", result, test.expected)
+		}
+
+		if len(vsc.warnings) != 0 {
+			t.Errorf("generateSlowStartForPlus returned warnings for %v", test.upstream)
+		}
+	}
+}
+
+func TestCreateEndpointsFromUpstream(t *testing.T) {
+	ups := version2.Upstream{
+		Servers: []version2.UpstreamServer{
+			{
+				Address: "10.0.0.20:80",
+			},
+			{
+				Address: "10.0.0.30:80",
+			},
+		},
+	}
+
+	expected := []string{
+		"10.0.0.20:80",
+		"10.0.0.30:80",
+	}
+
+	endpoints := createEndpointsFromUpstream(ups)
+	if !reflect.DeepEqual(endpoints, expected) {
+		t.Errorf("createEndpointsFromUpstream returned %v, but expected %v", endpoints, expected)
+	}
+}
+
+func TestGenerateUpstreamWithQueue(t *testing.T) {
+	serviceName := "test-queue"
+
+	tests := []struct {
+		name     string
+		upstream conf_v1.Upstream
+		isPlus   bool
+		expected version2.Upstream
+		msg      string
+	}{
+		{
+			name: "test-upstream-queue",
+			upstream: conf_v1.Upstream{Service: serviceName, Port: 80, Queue: &conf_v1.UpstreamQueue{
+				Size:    10,
+				Timeout: "10s",
+			}},
+			isPlus: true,
+			expected: version2.Upstream{
+				Name: "test-upstream-queue",
+				Queue: &version2.Queue{
+					Size:    10,
+					Timeout: "10s",
+				},
+			},
+			msg: "upstream queue with size and timeout",
+		},
+		{
+			name:     "test-upstream-queue-with-default-timeout",
+			upstream: conf_v1.Upstream{Service: serviceName, Port: 80, Queue: &conf_v1.UpstreamQueue{Size: 10, Timeout: ""}},
+			isPlus:   true,
+			expected: version2.Upstream{
+				Name: "test-upstream-queue-with-default-timeout",
+				Queue: &version2.Queue{
+					Size:    10,
+					Timeout: "60s",
+				},
+			},
+			msg: "upstream queue with only size",
+		},
+		{
+			name:     "test-upstream-queue-nil",
+			upstream: conf_v1.Upstream{Service: serviceName, Port: 80, Queue: nil},
+			isPlus:   false,
+			expected: version2.Upstream{
+				Name: "test-upstream-queue-nil",
+			},
+			msg: "upstream queue with nil for OSS",
+		},
+	}
+
+	for _, test := range tests {
+		vsc := newVirtualServerConfigurator(&ConfigParams{}, test.isPlus, false)
+		result := vsc.generateUpstream(&conf_v1.VirtualServer{}, test.name, test.upstream, false, []string{}, nil, nil, nil)
+		if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("generateUpstream() returned %v but expected %v for the case of %v", result, test.expected, test.msg)
+		}
+	}
+
+}
+
+func TestGenerateQueueForPlus(t *testing.T) {
+	tests := []struct {
+		upstreamQueue *conf_v1.UpstreamQueue
+		expected      *version2.Queue
+		msg           string
+	}{
+		{
+			upstreamQueue: &conf_v1.UpstreamQueue{Size: 10, Timeout: "10s"},
+			expected:      &version2.Queue{Size: 10, Timeout: "10s"},
+			msg:           "upstream queue with size and timeout",
+		},
+		{
+			upstreamQueue: nil,
+			expected:      nil,
+			msg:           "upstream queue with nil",
+		},
+		{
+			upstreamQueue: &conf_v1.UpstreamQueue{Size: 10},
+			expected:      &version2.Queue{Size: 10, Timeout: "60s"},
+			msg:           "upstream queue with only size",
+		},
+	}
+
+	for _, test := range tests {
+		result := generateQueueForPlus(test.upstreamQueue, "60s")
+		if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("generateQueueForPlus() returned %v but expected %v for the case of %v", result, test.expected, test.msg)
+		}
+	}
+
+}
+
+func TestGenerateProxyCache(t *testing.T) {
+	cache := &conf_v1.ActionCache{
+		ZoneName: "tea",
+		Key:      "${scheme}${proxy_host}${request_uri}",
+		Valid: []conf_v1.ActionCacheValid{
+			{Codes: []int{200, 302}, Time: "10m"},
+			{Time: "1m"},
+		},
+		Bypass:  []string{"${cookie_nocache}"},
+		NoCache: []string{"${arg_nocache}"},
+		MinUses: 2,
+	}
+	expected := &version2.ProxyCache{
+		ZoneName: "tea",
+		ZoneSize: "10m",
+		Key:      "${scheme}${proxy_host}${request_uri}",
+		Valid:    []string{"200 302 10m", "1m"},
+		Bypass:   []string{"${cookie_nocache}"},
+		NoCache:  []string{"${arg_nocache}"},
+		MinUses:  2,
+	}
+
+	result := generateProxyCache(cache)
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("generateProxyCache() returned %+v but expected %+v", result, expected)
+	}
+
+	if result := generateProxyCache(nil); result != nil {
+		t.Errorf("generateProxyCache() returned %+v for nil cache", result)
+	}
+}
+
+func TestGenerateCacheZones(t *testing.T) {
+	vs := &conf_v1.VirtualServer{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "cafe",
+			Namespace: "default",
+		},
+	}
+	locations := []version2.Location{
+		{
+			Path:       "/tea",
+			ProxyCache: &version2.ProxyCache{ZoneName: "tea-cache", ZoneSize: "20m"},
+		},
+		{
+			Path: "/coffee",
+		},
+		{
+			Path:       "/tea/green",
+			ProxyCache: &version2.ProxyCache{ZoneName: "tea-cache", ZoneSize: "10m"},
+		},
+	}
+	expected := []version2.CacheZone{
+		{
+			Name: "vs_default_cafe_cache_tea_cache",
+			Path: "/var/cache/nginx/vs_default_cafe_cache_tea_cache",
+			Size: "20m",
+		},
+	}
+
+	result := generateCacheZones(locations, newVariableNamer(vs))
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("generateCacheZones() returned %+v but expected %+v", result, expected)
+	}
+
+	for _, loc := range []version2.Location{locations[0], locations[2]} {
+		if loc.ProxyCache.ZoneName != "vs_default_cafe_cache_tea_cache" {
+			t.Errorf("generateCacheZones() set zone name %v for location %v", loc.ProxyCache.ZoneName, loc.Path)
+		}
+	}
+}
+
+func TestGenerateCachePurges(t *testing.T) {
+	vs := &conf_v1.VirtualServer{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "cafe",
+			Namespace: "default",
+		},
+	}
+	createLocations := func() []version2.Location {
+		return []version2.Location{
+			{
+				Path:       "/tea",
+				ProxyCache: &version2.ProxyCache{ZoneName: "tea"},
+			},
+			{
+				Path: "/coffee",
+				ProxyCache: &version2.ProxyCache{
+					ZoneName: "coffee",
+					Purge:    &version2.ProxyCachePurge{Allow: []string{"10.0.0.1", "192.168.0.0/16"}},
+				},
+			},
+		}
+	}
+
+	expectedGeos := []version2.Geo{
+		{
+			Source:   "$remote_addr",
+			Variable: "$vs_default_cafe_cache_purge_0_allowed",
+			Parameters: []version2.Parameter{
+				{Value: "default", Result: "0"},
+				{Value: "10.0.0.1", Result: "1"},
+				{Value: "192.168.0.0/16", Result: "1"},
+			},
+		},
+	}
+	expectedMaps := []version2.Map{
+		{
+			Source:   "$request_method",
+			Variable: "$vs_default_cafe_cache_purge_0",
+			Parameters: []version2.Parameter{
+				{Value: "PURGE", Result: "$vs_default_cafe_cache_purge_0_allowed"},
+				{Value: "default", Result: "0"},
+			},
+		},
+	}
+
+	locations := createLocations()
+	geos, maps := generateCachePurges(locations, newVariableNamer(vs), true)
+	if !reflect.DeepEqual(geos, expectedGeos) {
+		t.Errorf("generateCachePurges() returned geos %+v but expected %+v", geos, expectedGeos)
+	}
+	if !reflect.DeepEqual(maps, expectedMaps) {
+		t.Errorf("generateCachePurges() returned maps %+v but expected %+v", maps, expectedMaps)
+	}
+	if variable := locations[1].ProxyCache.Purge.Variable; variable != "$vs_default_cafe_cache_purge_0" {
+		t.Errorf("generateCachePurges() set purge variable %v for location %v", variable, locations[1].Path)
+	}
+
+	locations = createLocations()
+	geos, maps = generateCachePurges(locations, newVariableNamer(vs), false)
+	if len(geos) != 0 || len(maps) != 0 {
+		t.Errorf("generateCachePurges() returned geos %+v and maps %+v for NGINX", geos, maps)
+	}
+	if locations[1].ProxyCache.Purge != nil {
+		t.Errorf("generateCachePurges() kept the purge of location %v for NGINX", locations[1].Path)
+	}
+}
+
+func TestGenerateLocationForServe(t *testing.T) {
+	tests := []struct {
+		serve    *conf_v1.ActionServe
+		expected version2.Location
+		msg      string
+	}{
+		{
+			serve: &conf_v1.ActionServe{Directory: "cafe/maintenance"},
+			expected: version2.Location{
+				Path:       "/",
+				Snippets:   []string{"# snippet"},
+				StaticRoot: "/var/lib/nginx/static/cafe/maintenance",
+			},
+			msg: "directory",
+		},
+		{
+			serve: &conf_v1.ActionServe{ConfigMap: "robots", Key: "robots.txt"},
+			expected: version2.Location{
+				Path:         "/",
+				Snippets:     []string{"# snippet"},
+				DefaultType:  "text/plain",
+				ServeContent: &version2.ServeContent{ConfigMap: "robots", Key: "robots.txt"},
+			},
+			msg: "configMap",
+		},
+		{
+			serve: &conf_v1.ActionServe{ConfigMap: "maintenance", Key: "index.html", Type: "text/html"},
+			expected: version2.Location{
+				Path:         "/",
+				Snippets:     []string{"# snippet"},
+				DefaultType:  "text/html",
+				ServeContent: &version2.ServeContent{ConfigMap: "maintenance", Key: "index.html"},
+			},
+			msg: "configMap with type",
+		},
+	}
+
+	for _, test := range tests {
+		result := generateLocationForServe("/", []string{"# snippet"}, test.serve)
+		if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("generateLocationForServe() returned %+v but expected %+v for the case of %s", result, test.expected, test.msg)
+		}
+	}
+}
+
+func TestAddServeContentToLocation(t *testing.T) {
+	vs := &conf_v1.VirtualServer{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "cafe",
+			Namespace: "default",
+		},
+	}
+	configMaps := map[string]*api_v1.ConfigMap{
+		"default/robots": {
+			Data: map[string]string{
+				"robots.txt": "User-agent: *\nDisallow: /$tea \"quoted\" \\",
+			},
+		},
+	}
+
+	tests := []struct {
+		serveContent     *version2.ServeContent
+		expectedReturn   *version2.Return
+		expectedWarnings int
+		msg              string
+	}{
+		{
+			serveContent: &version2.ServeContent{ConfigMap: "robots", Key: "robots.txt"},
+			expectedReturn: &version2.Return{
+				Code: 200,
+				Text: "User-agent: *\nDisallow: /${vs_default_cafe_dollar}tea \\\"quoted\\\" \\\\",
+			},
+			expectedWarnings: 0,
+			msg:              "existing key",
+		},
+		{
+			serveContent:     &version2.ServeContent{ConfigMap: "robots", Key: "index.html"},
+			expectedReturn:   &version2.Return{Code: 500},
+			expectedWarnings: 1,
+			msg:              "missing key",
+		},
+		{
+			serveContent:     &version2.ServeContent{ConfigMap: "maintenance", Key: "index.html"},
+			expectedReturn:   &version2.Return{Code: 500},
+			expectedWarnings: 1,
+			msg:              "missing ConfigMap",
+		},
+	}
+
+	for _, test := range tests {
+		vsc := newVirtualServerConfigurator(&ConfigParams{}, false, false)
+		location := version2.Location{Path: "/robots.txt", ServeContent: test.serveContent}
+
+		vsc.addServeContentToLocation(vs, vs.Namespace, configMaps, newVariableNamer(vs), &location)
+
+		if !reflect.DeepEqual(location.Return, test.expectedReturn) {
+			t.Errorf("addServeContentToLocation() set return %+v but expected %+v for the case of %s", location.Return, test.expectedReturn, test.msg)
+		}
+		if len(vsc.warnings[vs]) != test.expectedWarnings {
+			t.Errorf("addServeContentToLocation() added warnings %v but expected %d for the case of %s", vsc.warnings[vs], test.expectedWarnings, test.msg)
+		}
+	}
+}
+
+func TestGenerateDollarGeo(t *testing.T) {
+	expected := version2.Geo{
+		Source:   "$remote_addr",
+		Variable: "$vs_default_cafe_dollar",
+		Parameters: []version2.Parameter{
+			{Value: "default", Result: `"$"`},
+		},
+	}
+
+	result := generateDollarGeo("$vs_default_cafe_dollar")
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("generateDollarGeo() returned %+v but expected %+v", result, expected)
+	}
+}
+
+func TestGenerateQueueLimitReq(t *testing.T) {
+	tests := []struct {
+		queue            *conf_v1.UpstreamQueue
+		expectedZone     version2.LimitReqZone
+		expectedLimitReq version2.LimitReq
+		msg              string
+	}{
+		{
+			queue: &conf_v1.UpstreamQueue{Size: 10, Timeout: "10s"},
+			expectedZone: version2.LimitReqZone{
+				Key:      "vs_default_cafe_tea",
+				ZoneName: "vs_default_cafe_tea_queue",
+				ZoneSize: "10m",
+				Rate:     "60r/m",
+			},
+			expectedLimitReq: version2.LimitReq{ZoneName: "vs_default_cafe_tea_queue", Burst: 10},
+			msg:              "queue with size and timeout",
+		},
+		{
+			queue: &conf_v1.UpstreamQueue{Size: 7},
+			expectedZone: version2.LimitReqZone{
+				Key:      "vs_default_cafe_tea",
+				ZoneName: "vs_default_cafe_tea_queue",
+				ZoneSize: "10m",
+				Rate:     "7r/m",
+			},
+			expectedLimitReq: version2.LimitReq{ZoneName: "vs_default_cafe_tea_queue", Burst: 7},
+			msg:              "queue with the default timeout",
+		},
+		{
+			queue: &conf_v1.UpstreamQueue{Size: 5, Timeout: "100ms"},
+			expectedZone: version2.LimitReqZone{
+				Key:      "vs_default_cafe_tea",
+				ZoneName: "vs_default_cafe_tea_queue",
+				ZoneSize: "10m",
+				Rate:     "300r/m",
+			},
+			expectedLimitReq: version2.LimitReq{ZoneName: "vs_default_cafe_tea_queue", Burst: 5},
+			msg:              "queue with a timeout under a second",
+		},
+	}
+
+	for _, test := range tests {
+		zone, limitReq := generateQueueLimitReq("vs_default_cafe_tea", test.queue)
+		if !reflect.DeepEqual(zone, test.expectedZone) {
+			t.Errorf("generateQueueLimitReq() returned zone %+v but expected %+v for the case of %v", zone, test.expectedZone, test.msg)
+		}
+		if !reflect.DeepEqual(limitReq, test.expectedLimitReq) {
+			t.Errorf("generateQueueLimitReq() returned limit %+v but expected %+v for the case of %v", limitReq, test.expectedLimitReq, test.msg)
+		}
+	}
+}
+
+func TestEmulateUpstreamQueues(t *testing.T) {
+	upstreams := []version2.Upstream{
+		{
+			Name:     "vs_default_cafe_tea",
+			Servers:  []version2.UpstreamServer{{Address: "10.0.0.20:80"}, {Address: "10.0.0.21:80"}},
+			MaxConns: 4,
+		},
+		{
+			Name:    "vs_default_cafe_coffee",
+			Servers: []version2.UpstreamServer{{Address: "10.0.0.30:80"}},
+		},
+	}
+	crUpstreams := map[string]conf_v1.Upstream{
+		"vs_default_cafe_tea": {
+			Name:  "tea",
+			Queue: &conf_v1.UpstreamQueue{Size: 10, Timeout: "10s"},
+		},
+		"vs_default_cafe_coffee": {
+			Name: "coffee",
+		},
+	}
+	locations := []version2.Location{
+		{
+			Path:      "/tea",
+			ProxyPass: "http://vs_default_cafe_tea",
+		},
+		{
+			Path:      "/tea/limited",
+			ProxyPass: "http://vs_default_cafe_tea",
+			LimitReqs: []version2.LimitReq{{ZoneName: "pol_rl_default_rate-limit-policy", Burst: 5}},
+		},
+		{
+			Path:      "/coffee",
+			ProxyPass: "http://vs_default_cafe_coffee",
+		},
+	}
+	serverPoliciesCfg := policiesCfg{
+		LimitReqOptions: version2.LimitReqOptions{LogLevel: "notice", RejectCode: 429},
+		LimitReqs:       []version2.LimitReq{{ZoneName: "pol_rl_default_server-policy"}},
+	}
+	serverLimitConns := []version2.LimitConn{{ZoneName: "vs_default_cafe_server_lc", Max: 100}}
+
+	expectedLimitReqZones := []version2.LimitReqZone{
+		{
+			Key:      "vs_default_cafe_tea",
+			ZoneName: "vs_default_cafe_tea_queue",
+			ZoneSize: "10m",
+			Rate:     "60r/m",
+		},
+	}
+	expectedLimitConnZones := []version2.LimitConnZone{
+		{
+			Key:      "vs_default_cafe_tea",
+			ZoneName: "vs_default_cafe_tea_queue_conn",
+			ZoneSize: "10m",
+		},
+	}
+	queueLimitReq := version2.LimitReq{ZoneName: "vs_default_cafe_tea_queue", Burst: 10}
+	queueLimitConn := version2.LimitConn{ZoneName: "vs_default_cafe_tea_queue_conn", Max: 8}
+	expectedLocations := []version2.Location{
+		{
+			Path:            "/tea",
+			ProxyPass:       "http://vs_default_cafe_tea",
+			LimitReqOptions: version2.LimitReqOptions{LogLevel: "notice", RejectCode: 429},
+			LimitReqs:       []version2.LimitReq{{ZoneName: "pol_rl_default_server-policy"}, queueLimitReq},
+			LimitConns:      []version2.LimitConn{{ZoneName: "vs_default_cafe_server_lc", Max: 100}, queueLimitConn},
+		},
+		{
+			Path:       "/tea/limited",
+			ProxyPass:  "http://vs_default_cafe_tea",
+			LimitReqs:  []version2.LimitReq{{ZoneName: "pol_rl_default_rate-limit-policy", Burst: 5}, queueLimitReq},
+			LimitConns: []version2.LimitConn{{ZoneName: "vs_default_cafe_server_lc", Max: 100}, queueLimitConn},
+		},
+		{
+			Path:      "/coffee",
+			ProxyPass: "http://vs_default_cafe_coffee",
+		},
+	}
+
+	limitReqZones, limitConnZones := emulateUpstreamQueues(upstreams, crUpstreams, locations, serverPoliciesCfg, serverLimitConns)
+	if !reflect.DeepEqual(limitReqZones, expectedLimitReqZones) {
+		t.Errorf("emulateUpstreamQueues() returned limit req zones %+v but expected %+v", limitReqZones, expectedLimitReqZones)
+	}
+	if !reflect.DeepEqual(limitConnZones, expectedLimitConnZones) {
+		t.Errorf("emulateUpstreamQueues() returned limit conn zones %+v but expected %+v", limitConnZones, expectedLimitConnZones)
+	}
+	if !reflect.DeepEqual(locations, expectedLocations) {
+		t.Errorf("emulateUpstreamQueues() produced locations %+v but expected %+v", locations, expectedLocations)
+	}
+}
+
+func TestGenerateUpstreamWithEmulatedQueueWarning(t *testing.T) {
+	vs := &conf_v1.VirtualServer{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "cafe",
+			Namespace: "default",
+		},
+	}
+	upstream := conf_v1.Upstream{
+		Name:    "tea",
+		Service: "tea-svc",
+		Port:    80,
+		Queue:   &conf_v1.UpstreamQueue{Size: 10},
+	}
+
+	vsc := newVirtualServerConfigurator(&ConfigParams{}, false, false)
+	vsc.emulateQueue = true
+
+	result := vsc.generateUpstream(vs, "vs_default_cafe_tea", upstream, false, []string{"10.0.0.20:80"}, nil, nil, nil)
+	if result.Queue != nil {
+		t.Errorf("generateUpstream() returned queue %+v for NGINX", result.Queue)
+	}
+
+	warnings := vsc.warnings[vs]
+	if len(warnings) != 1 || warnings[0].Code != WarningCodeApproximatedSetting {
+		t.Errorf("generateUpstream() returned warnings %v but expected one %v warning", warnings, WarningCodeApproximatedSetting)
+	}
+}
+
+func TestGenerateSessionCookie(t *testing.T) {
+	tests := []struct {
+		sc       *conf_v1.SessionCookie
+		expected *version2.SessionCookie
+		msg      string
+	}{
+		{
+			sc:       &conf_v1.SessionCookie{Enable: true, Name: "test"},
+			expected: &version2.SessionCookie{Enable: true, Name: "test"},
+			msg:      "session cookie with name",
+		},
+		{
+			sc:       nil,
+			expected: nil,
+			msg:      "session cookie with nil",
+		},
+		{
+			sc:       &conf_v1.SessionCookie{Name: "test"},
+			expected: nil,
+			msg:      "session cookie not enabled",
+		},
+	}
+	for _, test := range tests {
+		result := generateSessionCookie(test.sc)
+		if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("generateSessionCookie() returned %v, but expected %v for the case of: %v", result, test.expected, test.msg)
+		}
+	}
+}
+
+func TestGeneratePath(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{
+			path:     "/",
+			expected: "/",
+		},
+		{
+			path:     "=/exact/match",
+			expected: "=/exact/match",
+		},
+		{
+			path:     `~ *\\.jpg`,
+			expected: `~ "*\\.jpg"`,
+		},
+		{
+			path:     `~* *\\.PNG`,
+			expected: `~* "*\\.PNG"`,
+		},
+	}
+
+	for _, test := range tests {
+		result := generatePath(test.path)
+		if result != test.expected {
+			t.Errorf("generatePath() returned %v, but expected %v.", result, test.expected)
+		}
+	}
+}
+
+func TestGeneratePolicies(t *testing.T) {
+	owner := &conf_v1.VirtualServer{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Namespace: "default",
+			Name:      "cafe",
+		},
+	}
+	ownerNamespace := "default"
+	variableNamer := newVariableNamer(owner)
+
+	tests := []struct {
+		policyRefs []conf_v1.PolicyReference
+		policies   map[string]*conf_v1.Policy
+		policyOpts policyOptions
+		expected   policiesCfg
+		msg        string
+	}{
+		{
+			policyRefs: []conf_v1.PolicyReference{
+				{
+					Name:      "rate-limit-policy",
+					Namespace: "default",
+				},
+			},
+			policies: map[string]*conf_v1.Policy{
+				"default/rate-limit-policy": {
+					Spec: conf_v1.PolicySpec{
+						RateLimit: &conf_v1.RateLimit{
+							Key:      "test",
+							ZoneSize: "10M",
+							Rate:     "10r/s",
+							LogLevel: "notice",
+						},
+					},
+				},
+			},
+			expected: policiesCfg{
+				LimitReqZones: []version2.LimitReqZone{
+					{
+						Key:      "test",
+						ZoneSize: "10M",
+						Rate:     "10r/s",
+						ZoneName: "pol_rl_default_rate_limit_policy_default_cafe",
+					},
+				},
+				LimitReqOptions: version2.LimitReqOptions{
+					LogLevel:   "notice",
+					RejectCode: 503,
+				},
+				LimitReqs: []version2.LimitReq{
+					{
+						ZoneName: "pol_rl_default_rate_limit_policy_default_cafe",
+					},
+				},
+			},
+			msg: "rate limit reference",
+		},
+		{
+			policyRefs: []conf_v1.PolicyReference{
+				{
+					Name: "rate-limit-policy",
+				},
+				{
+					Name:      "rate-limit-policy2",
+					Namespace: "default",
+				},
+			},
+			policies: map[string]*conf_v1.Policy{
+				"default/rate-limit-policy": {
+					Spec: conf_v1.PolicySpec{
+						RateLimit: &conf_v1.RateLimit{
+							Key:      "test",
+							ZoneSize: "10M",
+							Rate:     "10r/s",
+							Burst:    createPointerFromInt(5),
+							NoDelay:  createPointerFromBool(true),
+						},
+					},
+				},
+				"default/rate-limit-policy2": {
+					Spec: conf_v1.PolicySpec{
+						RateLimit: &conf_v1.RateLimit{
+							Key:      "test2",
+							ZoneSize: "20M",
+							Rate:     "20r/s",
+							Delay:    createPointerFromInt(10),
+						},
+					},
+				},
+			},
+			expected: policiesCfg{
+				LimitReqZones: []version2.LimitReqZone{
+					{
+						Key:      "test",
+						ZoneSize: "10M",
+						Rate:     "10r/s",
+						ZoneName: "pol_rl_default_rate_limit_policy_default_cafe",
+					},
+					{
+						Key:      "test2",
+						ZoneSize: "20M",
+						Rate:     "20r/s",
+						ZoneName: "pol_rl_default_rate_limit_policy2_default_cafe",
+					},
+				},
+				LimitReqOptions: version2.LimitReqOptions{
+					LogLevel:   "error",
+					RejectCode: 503,
+				},
+				LimitReqs: []version2.LimitReq{
+					{
+						ZoneName: "pol_rl_default_rate_limit_policy_default_cafe",
+						Burst:    5,
+						NoDelay:  true,
+					},
+					{
+						ZoneName: "pol_rl_default_rate_limit_policy2_default_cafe",
+						Delay:    10,
+					},
+				},
+			},
+			msg: "multiple rate limit references with implicit namespace",
+		},
+		{
+			policyRefs: []conf_v1.PolicyReference{
+				{
+					Name:      "jwt-policy",
+					Namespace: "default",
+				},
+			},
+			policies: map[string]*conf_v1.Policy{
+				"default/jwt-policy": {
+					Spec: conf_v1.PolicySpec{
+						JWTAuth: &conf_v1.JWTAuth{
+							Realm:  "My Test API",
+							Secret: "jwt-secret",
+							Token:  "$http_token",
+						},
+					},
+				},
+			},
+			policyOpts: policyOptions{
+				jwtKeyFileNames: map[string]string{
+					"default/jwt-secret": "/etc/nginx/secrets/default-jwt-secret",
+				},
+			},
+			expected: policiesCfg{
+				JWTAuth: &version2.JWTAuth{
+					Secret: "/etc/nginx/secrets/default-jwt-secret",
+					Realm:  "My Test API",
+					Token:  "$http_token",
+				},
+			},
+			msg: "jwt reference",
+		},
+		{
+			policyRefs: []conf_v1.PolicyReference{
+				{
+					Name:      "oidc-policy",
+					Namespace: "default",
+				},
+			},
+			policies: map[string]*conf_v1.Policy{
+				"default/oidc-policy": {
+					Spec: conf_v1.PolicySpec{
+						OIDC: &conf_v1.OIDC{
+							AuthEndpoint:  "https://idp.example.com/auth",
+							TokenEndpoint: "https://idp.example.com/token",
+							JWKSURI:       "https://idp.example.com/certs",
+							ClientID:      "nginx-plus",
+							ClientSecret:  "oidc-secret",
+						},
+					},
+				},
+			},
+			policyOpts: policyOptions{
+				oidcClientSecrets: map[string]string{
+					"default/oidc-secret": "super-secret",
+				},
+			},
+			expected: policiesCfg{
+				OIDC: &version2.OIDC{
+					AuthEndpoint:  "https://idp.example.com/auth",
+					TokenEndpoint: "https://idp.example.com/token",
+					JwksURI:       "https://idp.example.com/certs",
+					ClientID:      "nginx-plus",
+					ClientSecret:  "super-secret",
+					Scope:         "openid",
+					RedirectURI:   "/_codexch",
+					HMACKey:       generateOIDCHMACKey("default/oidc-policy", "super-secret"),
+				},
+			},
+			msg: "oidc reference",
+		},
+		{
+			policyRefs: []conf_v1.PolicyReference{
+				{
+					Name:      "mtls-policy",
+					Namespace: "default",
+				},
+			},
+			policies: map[string]*conf_v1.Policy{
+				"default/mtls-policy": {
+					Spec: conf_v1.PolicySpec{
+						EgressMTLS: &conf_v1.EgressMTLS{
+							TLSSecret:         "mtls-secret",
+							VerifyServer:      true,
+							VerifyDepth:       createPointerFromInt(2),
+							TrustedCertSecret: "ca-secret",
+							Protocols:         "TLSv1.3",
+							SessionReuse:      createPointerFromBool(false),
+							Ciphers:           "HIGH",
+							ServerName:        true,
+							SSLName:           "backend.example.com",
+						},
+					},
+				},
+			},
+			policyOpts: policyOptions{
+				egressTLSSecretFileNames: map[string]string{
+					"default/mtls-secret": "/etc/nginx/secrets/default-mtls-secret",
+				},
+				trustedCAFileNames: map[string]string{
+					"default/ca-secret": "/etc/nginx/secrets/default-ca-secret",
+				},
+			},
+			expected: policiesCfg{
+				EgressMTLS: &version2.EgressMTLS{
+					Certificate:    "/etc/nginx/secrets/default-mtls-secret",
+					CertificateKey: "/etc/nginx/secrets/default-mtls-secret",
+					VerifyServer:   true,
+					VerifyDepth:    2,
+					Protocols:      "TLSv1.3",
+					SessionReuse:   false,
+					Ciphers:        "HIGH",
+					TrustedCert:    "/etc/nginx/secrets/default-ca-secret",
+					ServerName:     true,
+					SSLName:        "backend.example.com",
+				},
+			},
+			msg: "egressMTLS reference",
+		},
+		{
+			policyRefs: []conf_v1.PolicyReference{
+				{
+					Name:      "mtls-policy",
+					Namespace: "default",
+				},
+			},
+			policies: map[string]*conf_v1.Policy{
+				"default/mtls-policy": {
+					Spec: conf_v1.PolicySpec{
+						EgressMTLS: &conf_v1.EgressMTLS{
+							TLSSecret: "mtls-secret",
+						},
+					},
+				},
+			},
+			policyOpts: policyOptions{
+				egressTLSSecretFileNames: map[string]string{
+					"default/mtls-secret": "/etc/nginx/secrets/default-mtls-secret",
+				},
+			},
+			expected: policiesCfg{
+				EgressMTLS: &version2.EgressMTLS{
+					Certificate:    "/etc/nginx/secrets/default-mtls-secret",
+					CertificateKey: "/etc/nginx/secrets/default-mtls-secret",
+					VerifyDepth:    1,
+					Protocols:      "TLSv1 TLSv1.1 TLSv1.2",
+					SessionReuse:   true,
+					Ciphers:        "DEFAULT",
+					SSLName:        "$proxy_host",
+				},
+			},
+			msg: "egressMTLS reference with defaults",
+		},
+		{
+			policyRefs: []conf_v1.PolicyReference{
+				{
+					Name:      "basic-auth-policy",
+					Namespace: "default",
+				},
+			},
+			policies: map[string]*conf_v1.Policy{
+				"default/basic-auth-policy": {
+					Spec: conf_v1.PolicySpec{
+						BasicAuth: &conf_v1.BasicAuth{
+							Realm:  "My Test API",
+							Secret: "htpasswd-secret",
+						},
+					},
+				},
+			},
+			policyOpts: policyOptions{
+				htpasswdFileNames: map[string]string{
+					"default/htpasswd-secret": "/etc/nginx/secrets/default-htpasswd-secret",
+				},
+			},
+			expected: policiesCfg{
+				BasicAuth: &version2.BasicAuth{
+					Secret: "/etc/nginx/secrets/default-htpasswd-secret",
+					Realm:  "My Test API",
+				},
+			},
+			msg: "basicAuth reference",
+		},
+		{
+			policyRefs: []conf_v1.PolicyReference{
+				{
+					Name:      "cors-policy",
+					Namespace: "default",
+				},
+			},
+			policies: map[string]*conf_v1.Policy{
+				"default/cors-policy": {
+					Spec: conf_v1.PolicySpec{
+						CORS: &conf_v1.CORS{
+							AllowOrigins: []string{"https://example.com"},
+						},
+					},
+				},
+			},
+			expected: policiesCfg{
+				CORS: &version2.CORS{
+					Headers: []version2.AddHeader{
+						{Name: "Access-Control-Allow-Origin", Value: "$pol_cors_default_cors_policy_default_cafe_origin"},
+						{Name: "Vary", Value: "Origin"},
+					},
+					PreflightVariable: "$vs_default_cafe_cors_preflight",
+					PreflightHeaders: []version2.AddHeader{
+						{Name: "Access-Control-Allow-Methods", Value: "GET, HEAD, POST, PUT, PATCH, DELETE"},
+						{Name: "Access-Control-Allow-Headers", Value: "$http_access_control_request_headers"},
+					},
+				},
+				Maps: []version2.Map{
+					{
+						Source:   "$http_origin",
+						Variable: "$pol_cors_default_cors_policy_default_cafe_origin",
+						Parameters: []version2.Parameter{
+							{Value: `"https://example.com"`, Result: "$http_origin"},
+							{Value: "default", Result: `""`},
+						},
+					},
+				},
+			},
+			msg: "cors reference",
+		},
+	}
+
+	for _, test := range tests {
+		vsc := newVirtualServerConfigurator(&ConfigParams{}, false, true)
+
+		result := vsc.generatePolicies(owner, ownerNamespace, test.policyRefs, test.policies, specContext, variableNamer, test.policyOpts)
 		if !reflect.DeepEqual(result, test.expected) {
-			t.Errorf("generateHealthCheck returned \n%v but expected \n%v \n for case: %v", result, test.expected, test.msg)
+			t.Errorf("generatePolicies() returned \n%+v but expected \n%+v for the case of %s", result, test.expected, test.msg)
+		}
+		if len(vsc.warnings) > 0 {
+			t.Errorf("generatePolicies() returned unexpected warnings %v for the case of %s", vsc.warnings, test.msg)
 		}
 	}
 }
 
-func TestGenerateEndpointsForUpstream(t *testing.T) {
-	name := "test"
-	namespace := "test-namespace"
+func TestGeneratePoliciesFails(t *testing.T) {
+	owner := &conf_v1.VirtualServer{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Namespace: "default",
+			Name:      "cafe",
+		},
+	}
+	ownerNamespace := "default"
+	variableNamer := newVariableNamer(owner)
 
 	tests := []struct {
-		upstream             conf_v1.Upstream
-		vsEx                 *VirtualServerEx
-		isPlus               bool
+		policyRefs           []conf_v1.PolicyReference
+		policies             map[string]*conf_v1.Policy
+		policyOpts           policyOptions
+		context              string
 		isResolverConfigured bool
-		expected             []string
-		warningsExpected     bool
+		expected             policiesCfg
+		expectedWarnings     Warnings
 		msg                  string
 	}{
 		{
-			upstream: conf_v1.Upstream{
-				Service: name,
-				Port:    80,
+			policyRefs: []conf_v1.PolicyReference{
+				{
+					Name:      "rate-limit-policy",
+					Namespace: "default",
+				},
 			},
-			vsEx: &VirtualServerEx{
-				VirtualServer: &conf_v1.VirtualServer{
-					ObjectMeta: meta_v1.ObjectMeta{
-						Name:      name,
-						Namespace: namespace,
+			policies: map[string]*conf_v1.Policy{},
+			expected: policiesCfg{
+				ErrorReturn: &version2.Return{
+					Code: 500,
+				},
+			},
+			expectedWarnings: Warnings{
+				owner: {
+					{
+						Code:     WarningCodeInvalidPolicy,
+						Severity: WarningSeverityHigh,
+						Message:  "Policy default/rate-limit-policy is missing or invalid",
+					},
+				},
+			},
+			msg: "missing policy",
+		},
+		{
+			policyRefs: []conf_v1.PolicyReference{
+				{
+					Name: "rate-limit-policy",
+				},
+				{
+					Name: "rate-limit-policy2",
+				},
+			},
+			policies: map[string]*conf_v1.Policy{
+				"default/rate-limit-policy": {
+					Spec: conf_v1.PolicySpec{
+						RateLimit: &conf_v1.RateLimit{
+							Key:      "test",
+							ZoneSize: "10M",
+							Rate:     "10r/s",
+						},
+					},
+				},
+				"default/rate-limit-policy2": {
+					Spec: conf_v1.PolicySpec{
+						RateLimit: &conf_v1.RateLimit{
+							Key:      "test2",
+							ZoneSize: "20M",
+							Rate:     "20r/s",
+							DryRun:   createPointerFromBool(true),
+						},
+					},
+				},
+			},
+			expected: policiesCfg{
+				LimitReqZones: []version2.LimitReqZone{
+					{
+						Key:      "test",
+						ZoneSize: "10M",
+						Rate:     "10r/s",
+						ZoneName: "pol_rl_default_rate_limit_policy_default_cafe",
+					},
+					{
+						Key:      "test2",
+						ZoneSize: "20M",
+						Rate:     "20r/s",
+						ZoneName: "pol_rl_default_rate_limit_policy2_default_cafe",
+					},
+				},
+				LimitReqOptions: version2.LimitReqOptions{
+					LogLevel:   "error",
+					RejectCode: 503,
+				},
+				LimitReqs: []version2.LimitReq{
+					{
+						ZoneName: "pol_rl_default_rate_limit_policy_default_cafe",
+					},
+					{
+						ZoneName: "pol_rl_default_rate_limit_policy2_default_cafe",
+					},
+				},
+			},
+			expectedWarnings: Warnings{
+				owner: {
+					{
+						Code:     WarningCodeConflictingPolicies,
+						Severity: WarningSeverityLow,
+						Message:  "Policy default/rate-limit-policy2: the dryRun, logLevel and rejectCode fields of rate limit policies applied to the same context must be equal, the values of the first rate limit policy will be used",
+					},
+				},
+			},
+			msg: "rate limit policies with conflicting options",
+		},
+		{
+			policyRefs: []conf_v1.PolicyReference{
+				{
+					Name:      "jwt-policy",
+					Namespace: "default",
+				},
+			},
+			policies: map[string]*conf_v1.Policy{
+				"default/jwt-policy": {
+					Spec: conf_v1.PolicySpec{
+						JWTAuth: &conf_v1.JWTAuth{
+							Realm:  "My Test API",
+							Secret: "jwt-secret",
+						},
+					},
+				},
+			},
+			policyOpts: policyOptions{
+				jwtKeyFileNames: map[string]string{},
+			},
+			expected: policiesCfg{
+				ErrorReturn: &version2.Return{
+					Code: 500,
+				},
+			},
+			expectedWarnings: Warnings{
+				owner: {
+					{
+						Code:     WarningCodeInvalidSecret,
+						Severity: WarningSeverityHigh,
+						Message:  "Policy default/jwt-policy references a JWK Secret default/jwt-secret which does not exist or is invalid",
+					},
+				},
+			},
+			msg: "jwt reference missing secret",
+		},
+		{
+			policyRefs: []conf_v1.PolicyReference{
+				{
+					Name:      "jwt-policy",
+					Namespace: "default",
+				},
+				{
+					Name:      "jwt-policy2",
+					Namespace: "default",
+				},
+			},
+			policies: map[string]*conf_v1.Policy{
+				"default/jwt-policy": {
+					Spec: conf_v1.PolicySpec{
+						JWTAuth: &conf_v1.JWTAuth{
+							Realm:  "My Test API",
+							Secret: "jwt-secret",
+						},
+					},
+				},
+				"default/jwt-policy2": {
+					Spec: conf_v1.PolicySpec{
+						JWTAuth: &conf_v1.JWTAuth{
+							Realm:  "My Test API",
+							Secret: "jwt-secret2",
+						},
+					},
+				},
+			},
+			policyOpts: policyOptions{
+				jwtKeyFileNames: map[string]string{
+					"default/jwt-secret":  "/etc/nginx/secrets/default-jwt-secret",
+					"default/jwt-secret2": "/etc/nginx/secrets/default-jwt-secret2",
+				},
+			},
+			expected: policiesCfg{
+				JWTAuth: &version2.JWTAuth{
+					Secret: "/etc/nginx/secrets/default-jwt-secret",
+					Realm:  "My Test API",
+				},
+			},
+			expectedWarnings: Warnings{
+				owner: {
+					{
+						Code:     WarningCodeConflictingPolicies,
+						Severity: WarningSeverityMedium,
+						Message:  "Multiple jwt policies in the same context is not valid. Policy default/jwt-policy2 will be ignored",
+					},
+				},
+			},
+			msg: "multiple jwt policies",
+		},
+		{
+			policyRefs: []conf_v1.PolicyReference{
+				{
+					Name:      "oidc-policy",
+					Namespace: "default",
+				},
+			},
+			policies: map[string]*conf_v1.Policy{
+				"default/oidc-policy": {
+					Spec: conf_v1.PolicySpec{
+						OIDC: &conf_v1.OIDC{
+							AuthEndpoint:  "https://idp.example.com/auth",
+							TokenEndpoint: "https://idp.example.com/token",
+							JWKSURI:       "https://idp.example.com/certs",
+							ClientID:      "nginx-plus",
+							ClientSecret:  "oidc-secret",
+						},
+					},
+				},
+			},
+			policyOpts: policyOptions{
+				oidcClientSecrets: map[string]string{
+					"default/oidc-secret": "super-secret",
+				},
+			},
+			context:              routeContext,
+			isResolverConfigured: true,
+			expected:             policiesCfg{},
+			expectedWarnings: Warnings{
+				owner: {
+					{
+						Code:     WarningCodeInvalidPolicy,
+						Severity: WarningSeverityHigh,
+						Message:  "OIDC policies can only be referenced in the spec of a VirtualServer. Policy default/oidc-policy will be ignored",
+					},
+				},
+			},
+			msg: "oidc policy in a route",
+		},
+		{
+			policyRefs: []conf_v1.PolicyReference{
+				{
+					Name:      "oidc-policy",
+					Namespace: "default",
+				},
+			},
+			policies: map[string]*conf_v1.Policy{
+				"default/oidc-policy": {
+					Spec: conf_v1.PolicySpec{
+						OIDC: &conf_v1.OIDC{
+							AuthEndpoint:  "https://idp.example.com/auth",
+							TokenEndpoint: "https://idp.example.com/token",
+							JWKSURI:       "https://idp.example.com/certs",
+							ClientID:      "nginx-plus",
+							ClientSecret:  "oidc-secret",
+						},
+					},
+				},
+			},
+			policyOpts: policyOptions{
+				oidcClientSecrets: map[string]string{
+					"default/oidc-secret": "super-secret",
+				},
+			},
+			context:              specContext,
+			isResolverConfigured: false,
+			expected: policiesCfg{
+				ErrorReturn: &version2.Return{
+					Code: 500,
+				},
+			},
+			expectedWarnings: Warnings{
+				owner: {
+					{
+						Code:     WarningCodeResolverRequired,
+						Severity: WarningSeverityHigh,
+						Message:  "Policy default/oidc-policy requires a resolver to reach the OpenID Connect provider, but the resolver is not configured",
+					},
+				},
+			},
+			msg: "oidc policy without resolver",
+		},
+		{
+			policyRefs: []conf_v1.PolicyReference{
+				{
+					Name:      "oidc-policy",
+					Namespace: "default",
+				},
+			},
+			policies: map[string]*conf_v1.Policy{
+				"default/oidc-policy": {
+					Spec: conf_v1.PolicySpec{
+						OIDC: &conf_v1.OIDC{
+							AuthEndpoint:  "https://idp.example.com/auth",
+							TokenEndpoint: "https://idp.example.com/token",
+							JWKSURI:       "https://idp.example.com/certs",
+							ClientID:      "nginx-plus",
+							ClientSecret:  "oidc-secret",
+						},
+					},
+				},
+			},
+			policyOpts: policyOptions{
+				oidcClientSecrets: map[string]string{},
+			},
+			context:              specContext,
+			isResolverConfigured: true,
+			expected: policiesCfg{
+				ErrorReturn: &version2.Return{
+					Code: 500,
+				},
+			},
+			expectedWarnings: Warnings{
+				owner: {
+					{
+						Code:     WarningCodeInvalidSecret,
+						Severity: WarningSeverityHigh,
+						Message:  "Policy default/oidc-policy references an OIDC client Secret default/oidc-secret which does not exist or is invalid",
+					},
+				},
+			},
+			msg: "oidc reference missing secret",
+		},
+		{
+			policyRefs: []conf_v1.PolicyReference{
+				{
+					Name:      "oidc-policy",
+					Namespace: "default",
+				},
+				{
+					Name:      "jwt-policy",
+					Namespace: "default",
+				},
+			},
+			policies: map[string]*conf_v1.Policy{
+				"default/oidc-policy": {
+					Spec: conf_v1.PolicySpec{
+						OIDC: &conf_v1.OIDC{
+							AuthEndpoint:  "https://idp.example.com/auth",
+							TokenEndpoint: "https://idp.example.com/token",
+							JWKSURI:       "https://idp.example.com/certs",
+							ClientID:      "nginx-plus",
+							ClientSecret:  "oidc-secret",
+						},
+					},
+				},
+				"default/jwt-policy": {
+					Spec: conf_v1.PolicySpec{
+						JWTAuth: &conf_v1.JWTAuth{
+							Realm:  "My Test API",
+							Secret: "jwt-secret",
+						},
+					},
+				},
+			},
+			policyOpts: policyOptions{
+				jwtKeyFileNames: map[string]string{
+					"default/jwt-secret": "/etc/nginx/secrets/default-jwt-secret",
+				},
+				oidcClientSecrets: map[string]string{
+					"default/oidc-secret": "super-secret",
+				},
+			},
+			context:              specContext,
+			isResolverConfigured: true,
+			expected: policiesCfg{
+				OIDC: &version2.OIDC{
+					AuthEndpoint:  "https://idp.example.com/auth",
+					TokenEndpoint: "https://idp.example.com/token",
+					JwksURI:       "https://idp.example.com/certs",
+					ClientID:      "nginx-plus",
+					ClientSecret:  "super-secret",
+					Scope:         "openid",
+					RedirectURI:   "/_codexch",
+					HMACKey:       generateOIDCHMACKey("default/oidc-policy", "super-secret"),
+				},
+			},
+			expectedWarnings: Warnings{
+				owner: {
+					{
+						Code:     WarningCodeConflictingPolicies,
+						Severity: WarningSeverityMedium,
+						Message:  "A jwt policy and an oidc policy in the same context is not valid. Policy default/jwt-policy will be ignored",
+					},
+				},
+			},
+			msg: "oidc and jwt policies",
+		},
+		{
+			policyRefs: []conf_v1.PolicyReference{
+				{
+					Name:      "mtls-policy",
+					Namespace: "default",
+				},
+			},
+			policies: map[string]*conf_v1.Policy{
+				"default/mtls-policy": {
+					Spec: conf_v1.PolicySpec{
+						EgressMTLS: &conf_v1.EgressMTLS{
+							TLSSecret:         "mtls-secret",
+							VerifyServer:      true,
+							TrustedCertSecret: "ca-secret",
+						},
 					},
 				},
-				Endpoints: map[string][]string{
-					"test-namespace/test:80": {"example.com:80"},
+			},
+			policyOpts: policyOptions{
+				egressTLSSecretFileNames: map[string]string{},
+				trustedCAFileNames: map[string]string{
+					"default/ca-secret": "/etc/nginx/secrets/default-ca-secret",
 				},
-				ExternalNameSvcs: map[string]bool{
-					"test-namespace/test": true,
+			},
+			expected: policiesCfg{
+				ErrorReturn: &version2.Return{
+					Code: 500,
 				},
 			},
-			isPlus:               true,
-			isResolverConfigured: true,
-			expected:             []string{"example.com:80"},
-			msg:                  "ExternalName service",
+			expectedWarnings: Warnings{
+				owner: {
+					{
+						Code:     WarningCodeInvalidSecret,
+						Severity: WarningSeverityHigh,
+						Message:  "Policy default/mtls-policy references a TLS Secret default/mtls-secret which does not exist or is invalid",
+					},
+				},
+			},
+			msg: "egressMTLS reference missing TLS secret",
 		},
 		{
-			upstream: conf_v1.Upstream{
-				Service: name,
-				Port:    80,
+			policyRefs: []conf_v1.PolicyReference{
+				{
+					Name:      "mtls-policy",
+					Namespace: "default",
+				},
 			},
-			vsEx: &VirtualServerEx{
-				VirtualServer: &conf_v1.VirtualServer{
-					ObjectMeta: meta_v1.ObjectMeta{
-						Name:      name,
-						Namespace: namespace,
+			policies: map[string]*conf_v1.Policy{
+				"default/mtls-policy": {
+					Spec: conf_v1.PolicySpec{
+						EgressMTLS: &conf_v1.EgressMTLS{
+							TLSSecret:         "mtls-secret",
+							VerifyServer:      true,
+							TrustedCertSecret: "ca-secret",
+						},
 					},
 				},
-				Endpoints: map[string][]string{
-					"test-namespace/test:80": {"example.com:80"},
+			},
+			policyOpts: policyOptions{
+				egressTLSSecretFileNames: map[string]string{
+					"default/mtls-secret": "/etc/nginx/secrets/default-mtls-secret",
 				},
-				ExternalNameSvcs: map[string]bool{
-					"test-namespace/test": true,
+				trustedCAFileNames: map[string]string{},
+			},
+			expected: policiesCfg{
+				ErrorReturn: &version2.Return{
+					Code: 500,
 				},
 			},
-			isPlus:               true,
-			isResolverConfigured: false,
-			warningsExpected:     true,
-			expected:             []string{},
-			msg:                  "ExternalName service without resolver configured",
+			expectedWarnings: Warnings{
+				owner: {
+					{
+						Code:     WarningCodeInvalidSecret,
+						Severity: WarningSeverityHigh,
+						Message:  "Policy default/mtls-policy references a CA Secret default/ca-secret which does not exist or is invalid",
+					},
+				},
+			},
+			msg: "egressMTLS reference missing CA secret",
 		},
 		{
-			upstream: conf_v1.Upstream{
-				Service: name,
-				Port:    8080,
+			policyRefs: []conf_v1.PolicyReference{
+				{
+					Name:      "mtls-policy",
+					Namespace: "default",
+				},
+				{
+					Name:      "mtls-policy2",
+					Namespace: "default",
+				},
 			},
-			vsEx: &VirtualServerEx{
-				VirtualServer: &conf_v1.VirtualServer{
-					ObjectMeta: meta_v1.ObjectMeta{
-						Name:      name,
-						Namespace: namespace,
+			policies: map[string]*conf_v1.Policy{
+				"default/mtls-policy": {
+					Spec: conf_v1.PolicySpec{
+						EgressMTLS: &conf_v1.EgressMTLS{
+							TLSSecret: "mtls-secret",
+						},
 					},
 				},
-				Endpoints: map[string][]string{
-					"test-namespace/test:8080": {"192.168.10.10:8080"},
+				"default/mtls-policy2": {
+					Spec: conf_v1.PolicySpec{
+						EgressMTLS: &conf_v1.EgressMTLS{
+							TLSSecret: "mtls-secret2",
+						},
+					},
 				},
 			},
-			isPlus:               false,
-			isResolverConfigured: false,
-			expected:             []string{"192.168.10.10:8080"},
-			msg:                  "Service with endpoints",
-		},
-		{
-			upstream: conf_v1.Upstream{
-				Service: name,
-				Port:    8080,
+			policyOpts: policyOptions{
+				egressTLSSecretFileNames: map[string]string{
+					"default/mtls-secret":  "/etc/nginx/secrets/default-mtls-secret",
+					"default/mtls-secret2": "/etc/nginx/secrets/default-mtls-secret2",
+				},
 			},
-			vsEx: &VirtualServerEx{
-				VirtualServer: &conf_v1.VirtualServer{
-					ObjectMeta: meta_v1.ObjectMeta{
-						Name:      name,
-						Namespace: namespace,
+			expected: policiesCfg{
+				EgressMTLS: &version2.EgressMTLS{
+					Certificate:    "/etc/nginx/secrets/default-mtls-secret",
+					CertificateKey: "/etc/nginx/secrets/default-mtls-secret",
+					VerifyDepth:    1,
+					Protocols:      "TLSv1 TLSv1.1 TLSv1.2",
+					SessionReuse:   true,
+					Ciphers:        "DEFAULT",
+					SSLName:        "$proxy_host",
+				},
+			},
+			expectedWarnings: Warnings{
+				owner: {
+					{
+						Code:     WarningCodeConflictingPolicies,
+						Severity: WarningSeverityMedium,
+						Message:  "Multiple egressMTLS policies in the same context is not valid. Policy default/mtls-policy2 will be ignored",
 					},
 				},
-				Endpoints: map[string][]string{},
 			},
-			isPlus:               false,
-			isResolverConfigured: false,
-			expected:             []string{nginx502Server},
-			msg:                  "Service with no endpoints",
+			msg: "multiple egressMTLS policies",
 		},
 		{
-			upstream: conf_v1.Upstream{
-				Service: name,
-				Port:    8080,
+			policyRefs: []conf_v1.PolicyReference{
+				{
+					Name:      "basic-auth-policy",
+					Namespace: "default",
+				},
 			},
-			vsEx: &VirtualServerEx{
-				VirtualServer: &conf_v1.VirtualServer{
-					ObjectMeta: meta_v1.ObjectMeta{
-						Name:      name,
-						Namespace: namespace,
+			policies: map[string]*conf_v1.Policy{
+				"default/basic-auth-policy": {
+					Spec: conf_v1.PolicySpec{
+						BasicAuth: &conf_v1.BasicAuth{
+							Realm:  "My Test API",
+							Secret: "htpasswd-secret",
+						},
 					},
 				},
-				Endpoints: map[string][]string{},
 			},
-			isPlus:               true,
-			isResolverConfigured: false,
-			expected:             nil,
-			msg:                  "Service with no endpoints",
+			policyOpts: policyOptions{
+				htpasswdFileNames: map[string]string{},
+			},
+			expected: policiesCfg{
+				ErrorReturn: &version2.Return{
+					Code: 500,
+				},
+			},
+			expectedWarnings: Warnings{
+				owner: {
+					{
+						Code:     WarningCodeInvalidSecret,
+						Severity: WarningSeverityHigh,
+						Message:  "Policy default/basic-auth-policy references an htpasswd Secret default/htpasswd-secret which does not exist or is invalid",
+					},
+				},
+			},
+			msg: "basicAuth reference missing htpasswd secret",
 		},
 		{
-			upstream: conf_v1.Upstream{
-				Service:     name,
-				Port:        8080,
-				Subselector: map[string]string{"version": "test"},
+			policyRefs: []conf_v1.PolicyReference{
+				{
+					Name:      "basic-auth-policy",
+					Namespace: "default",
+				},
+				{
+					Name:      "basic-auth-policy2",
+					Namespace: "default",
+				},
 			},
-			vsEx: &VirtualServerEx{
-				VirtualServer: &conf_v1.VirtualServer{
-					ObjectMeta: meta_v1.ObjectMeta{
-						Name:      name,
-						Namespace: namespace,
+			policies: map[string]*conf_v1.Policy{
+				"default/basic-auth-policy": {
+					Spec: conf_v1.PolicySpec{
+						BasicAuth: &conf_v1.BasicAuth{
+							Realm:  "My Test API",
+							Secret: "htpasswd-secret",
+						},
 					},
 				},
-				Endpoints: map[string][]string{
-					"test-namespace/test_version=test:8080": {"192.168.10.10:8080"},
+				"default/basic-auth-policy2": {
+					Spec: conf_v1.PolicySpec{
+						BasicAuth: &conf_v1.BasicAuth{
+							Realm:  "My Test API",
+							Secret: "htpasswd-secret2",
+						},
+					},
 				},
 			},
-			isPlus:               false,
-			isResolverConfigured: false,
-			expected:             []string{"192.168.10.10:8080"},
-			msg:                  "Upstream with subselector, with a matching endpoint",
+			policyOpts: policyOptions{
+				htpasswdFileNames: map[string]string{
+					"default/htpasswd-secret":  "/etc/nginx/secrets/default-htpasswd-secret",
+					"default/htpasswd-secret2": "/etc/nginx/secrets/default-htpasswd-secret2",
+				},
+			},
+			expected: policiesCfg{
+				BasicAuth: &version2.BasicAuth{
+					Secret: "/etc/nginx/secrets/default-htpasswd-secret",
+					Realm:  "My Test API",
+				},
+			},
+			expectedWarnings: Warnings{
+				owner: {
+					{
+						Code:     WarningCodeConflictingPolicies,
+						Severity: WarningSeverityMedium,
+						Message:  "Multiple basicAuth policies in the same context is not valid. Policy default/basic-auth-policy2 will be ignored",
+					},
+				},
+			},
+			msg: "multiple basicAuth policies",
 		},
 		{
-			upstream: conf_v1.Upstream{
-				Service:     name,
-				Port:        8080,
-				Subselector: map[string]string{"version": "test"},
+			policyRefs: []conf_v1.PolicyReference{
+				{
+					Name:      "cors-policy",
+					Namespace: "default",
+				},
+				{
+					Name:      "cors-policy2",
+					Namespace: "default",
+				},
 			},
-			vsEx: &VirtualServerEx{
-				VirtualServer: &conf_v1.VirtualServer{
-					ObjectMeta: meta_v1.ObjectMeta{
-						Name:      name,
-						Namespace: namespace,
+			policies: map[string]*conf_v1.Policy{
+				"default/cors-policy": {
+					Spec: conf_v1.PolicySpec{
+						CORS: &conf_v1.CORS{
+							AllowOrigins: []string{"*"},
+						},
 					},
 				},
-				Endpoints: map[string][]string{
-					"test-namespace/test:8080": {"192.168.10.10:8080"},
+				"default/cors-policy2": {
+					Spec: conf_v1.PolicySpec{
+						CORS: &conf_v1.CORS{
+							AllowOrigins: []string{"https://example.com"},
+						},
+					},
 				},
 			},
-			isPlus:               false,
-			isResolverConfigured: false,
-			expected:             []string{nginx502Server},
-			msg:                  "Upstream with subselector, without a matching endpoint",
+			expected: policiesCfg{
+				CORS: &version2.CORS{
+					Headers: []version2.AddHeader{
+						{Name: "Access-Control-Allow-Origin", Value: "*"},
+					},
+					PreflightVariable: "$vs_default_cafe_cors_preflight",
+					PreflightHeaders: []version2.AddHeader{
+						{Name: "Access-Control-Allow-Methods", Value: "GET, HEAD, POST, PUT, PATCH, DELETE"},
+						{Name: "Access-Control-Allow-Headers", Value: "$http_access_control_request_headers"},
+					},
+				},
+			},
+			expectedWarnings: Warnings{
+				owner: {
+					{
+						Code:     WarningCodeConflictingPolicies,
+						Severity: WarningSeverityMedium,
+						Message:  "Multiple cors policies in the same context is not valid. Policy default/cors-policy2 will be ignored",
+					},
+				},
+			},
+			msg: "multiple cors policies",
 		},
 	}
 
 	for _, test := range tests {
-		vsc := newVirtualServerConfigurator(&ConfigParams{}, test.isPlus, test.isResolverConfigured)
-		result := vsc.generateEndpointsForUpstream(test.vsEx.VirtualServer, namespace, test.upstream, test.vsEx)
-		if !reflect.DeepEqual(result, test.expected) {
-			t.Errorf("generateEndpointsForUpstream(isPlus=%v, isResolverConfigured=%v) returned %v, but expected %v for case: %v",
-				test.isPlus, test.isResolverConfigured, result, test.expected, test.msg)
-		}
+		vsc := newVirtualServerConfigurator(&ConfigParams{}, false, test.isResolverConfigured)
 
-		if len(vsc.warnings) == 0 && test.warningsExpected {
-			t.Errorf("generateEndpointsForUpstream(isPlus=%v, isResolverConfigured=%v) didn't return any warnings for %v but warnings expected",
-				test.isPlus, test.isResolverConfigured, test.upstream)
+		result := vsc.generatePolicies(owner, ownerNamespace, test.policyRefs, test.policies, test.context, variableNamer, test.policyOpts)
+		if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("generatePolicies() returned \n%+v but expected \n%+v for the case of %s", result, test.expected, test.msg)
 		}
-
-		if len(vsc.warnings) != 0 && !test.warningsExpected {
-			t.Errorf("generateEndpointsForUpstream(isPlus=%v, isResolverConfigured=%v) returned warnings for %v",
-				test.isPlus, test.isResolverConfigured, test.upstream)
+		if !reflect.DeepEqual(vsc.warnings, test.expectedWarnings) {
+			t.Errorf("generatePolicies() returned warnings of \n%v but expected \n%v for the case of %s", vsc.warnings, test.expectedWarnings, test.msg)
 		}
 	}
 }
 
-func TestGenerateSlowStartForPlusWithInCompatibleLBMethods(t *testing.T) {
-	serviceName := "test-slowstart-with-incompatible-LBMethods"
-	upstream := conf_v1.Upstream{Service: serviceName, Port: 80, SlowStart: "10s"}
-	expected := ""
-
-	var tests = []string{
-		"random",
-		"ip_hash",
-		"hash 123",
-		"random two",
-		"random two least_conn",
-		"random two least_time=header",
-		"random two least_time=last_byte",
+func TestGenerateVirtualServerConfigWithRouteLimitReq(t *testing.T) {
+	virtualServerEx := VirtualServerEx{
+		VirtualServer: &conf_v1.VirtualServer{
+			ObjectMeta: meta_v1.ObjectMeta{
+				Name:      "cafe",
+				Namespace: "default",
+			},
+			Spec: conf_v1.VirtualServerSpec{
+				Host: "cafe.example.com",
+				Upstreams: []conf_v1.Upstream{
+					{
+						Name:    "tea",
+						Service: "tea-svc",
+						Port:    80,
+					},
+					{
+						Name:    "coffee",
+						Service: "coffee-svc",
+						Port:    80,
+					},
+				},
+				Routes: []conf_v1.Route{
+					{
+						Path:   "/tea",
+						Action: &conf_v1.Action{Pass: "tea"},
+						LimitReq: &conf_v1.RouteLimitReq{
+							Rate: "10r/s",
+						},
+					},
+					{
+						Path:   "/coffee",
+						Action: &conf_v1.Action{Pass: "coffee"},
+						LimitReq: &conf_v1.RouteLimitReq{
+							Rate:  "30r/m",
+							Burst: createPointerFromInt(5),
+							Key:   "${uri}",
+						},
+					},
+				},
+			},
+		},
 	}
 
-	for _, lbMethod := range tests {
-		vsc := newVirtualServerConfigurator(&ConfigParams{}, true, false)
-		result := vsc.generateSlowStartForPlus(&conf_v1.VirtualServer{}, upstream, lbMethod)
-
-		if !reflect.DeepEqual(result, expected) {
-			t.Errorf("generateSlowStartForPlus returned %v, but expected %v for lbMethod %v", result, expected, lbMethod)
-		}
+	vsc := newVirtualServerConfigurator(&ConfigParams{}, false, false)
+	result, warnings := vsc.GenerateVirtualServerConfig(&virtualServerEx, "", policyOptions{})
 
-		if len(vsc.warnings) == 0 {
-			t.Errorf("generateSlowStartForPlus returned no warnings for %v but warnings expected", upstream)
-		}
+	if len(warnings) != 0 {
+		t.Errorf("GenerateVirtualServerConfig returned warnings: %v", vsc.warnings)
 	}
 
-}
-
-func TestGenerateSlowStartForPlus(t *testing.T) {
-	serviceName := "test-slowstart"
+	expectedZones := []version2.LimitReqZone{
+		{
+			ZoneName: "vs_default_cafe_route_rl_85c724e5",
+			Key:      "${binary_remote_addr}",
+			ZoneSize: "10m",
+			Rate:     "10r/s",
+		},
+		{
+			ZoneName: "vs_default_cafe_route_rl_67dd099d",
+			Key:      "${uri}",
+			ZoneSize: "10m",
+			Rate:     "30r/m",
+		},
+	}
+	if !reflect.DeepEqual(result.LimitReqZones, expectedZones) {
+		t.Errorf("GenerateVirtualServerConfig returned limit req zones %+v but expected %+v", result.LimitReqZones, expectedZones)
+	}
 
-	tests := []struct {
-		upstream conf_v1.Upstream
-		lbMethod string
-		expected string
-	}{
+	expectedLimitReqs := [][]version2.LimitReq{
 		{
-			upstream: conf_v1.Upstream{Service: serviceName, Port: 80, SlowStart: "", LBMethod: "least_conn"},
-			lbMethod: "least_conn",
-			expected: "",
+			{
+				ZoneName: "vs_default_cafe_route_rl_85c724e5",
+			},
 		},
 		{
-			upstream: conf_v1.Upstream{Service: serviceName, Port: 80, SlowStart: "10s", LBMethod: "least_conn"},
-			lbMethod: "least_conn",
-			expected: "10s",
+			{
+				ZoneName: "vs_default_cafe_route_rl_67dd099d",
+				Burst:    5,
+			},
 		},
 	}
+	expectedOptions := version2.LimitReqOptions{
+		LogLevel:   "error",
+		RejectCode: 503,
+	}
 
-	for _, test := range tests {
-		vsc := newVirtualServerConfigurator(&ConfigParams{}, true, false)
-		result := vsc.generateSlowStartForPlus(&conf_v1.VirtualServer{}, test.upstream, test.lbMethod)
-		if !reflect.DeepEqual(result, test.expected) {
-			t.Errorf("generateSlowStartForPlus returned %v, but expected %v", result, test.expected)
+	for i, loc := range result.Server.Locations {
+		if !reflect.DeepEqual(loc.LimitReqs, expectedLimitReqs[i]) {
+			t.Errorf("GenerateVirtualServerConfig returned limit reqs %+v but expected %+v for location %v", loc.LimitReqs, expectedLimitReqs[i], loc.Path)
+		}
+		if loc.LimitReqOptions != expectedOptions {
+			t.Errorf("GenerateVirtualServerConfig returned limit req options %+v but expected %+v for location %v", loc.LimitReqOptions, expectedOptions, loc.Path)
 		}
+	}
 
-		if len(vsc.warnings) != 0 {
-			t.Errorf("generateSlowStartForPlus returned warnings for %v", test.upstream)
+	// reordering the routes keeps the names of the zones
+	routes := virtualServerEx.VirtualServer.Spec.Routes
+	routes[0], routes[1] = routes[1], routes[0]
+
+	result, _ = vsc.GenerateVirtualServerConfig(&virtualServerEx, "", policyOptions{})
+
+	for _, zone := range result.LimitReqZones {
+		if zone.Key == "${uri}" && zone.ZoneName != "vs_default_cafe_route_rl_67dd099d" {
+			t.Errorf("GenerateVirtualServerConfig renamed the limit req zone of the route /coffee to %v after reordering the routes", zone.ZoneName)
 		}
 	}
 }
 
-func TestCreateEndpointsFromUpstream(t *testing.T) {
-	ups := version2.Upstream{
-		Servers: []version2.UpstreamServer{
-			{
-				Address: "10.0.0.20:80",
-			},
+func TestAddRouteLimitReqToPoliciesCfg(t *testing.T) {
+	cfg := policiesCfg{
+		LimitReqOptions: version2.LimitReqOptions{
+			DryRun:     true,
+			LogLevel:   "info",
+			RejectCode: 429,
+		},
+		LimitReqs: []version2.LimitReq{
 			{
-				Address: "10.0.0.30:80",
+				ZoneName: "pol_rl_default_rate_limit_default_cafe",
+				Burst:    10,
 			},
 		},
 	}
 
-	expected := []string{
-		"10.0.0.20:80",
-		"10.0.0.30:80",
+	expected := policiesCfg{
+		LimitReqOptions: version2.LimitReqOptions{
+			DryRun:     true,
+			LogLevel:   "info",
+			RejectCode: 429,
+		},
+		LimitReqs: []version2.LimitReq{
+			{
+				ZoneName: "pol_rl_default_rate_limit_default_cafe",
+				Burst:    10,
+			},
+			{
+				ZoneName: "vs_default_cafe_route_rl_85c724e5",
+				Burst:    20,
+			},
+		},
 	}
 
-	endpoints := createEndpointsFromUpstream(ups)
-	if !reflect.DeepEqual(endpoints, expected) {
-		t.Errorf("createEndpointsFromUpstream returned %v, but expected %v", endpoints, expected)
+	addRouteLimitReqToPoliciesCfg("vs_default_cafe_route_rl_85c724e5", &conf_v1.RouteLimitReq{Rate: "10r/s", Burst: createPointerFromInt(20)}, &cfg)
+	if !reflect.DeepEqual(cfg, expected) {
+		t.Errorf("addRouteLimitReqToPoliciesCfg() returned %+v but expected %+v", cfg, expected)
 	}
 }
 
-func TestGenerateUpstreamWithQueue(t *testing.T) {
-	serviceName := "test-queue"
-
-	tests := []struct {
-		name     string
-		upstream conf_v1.Upstream
-		isPlus   bool
-		expected version2.Upstream
-		msg      string
-	}{
-		{
-			name: "test-upstream-queue",
-			upstream: conf_v1.Upstream{Service: serviceName, Port: 80, Queue: &conf_v1.UpstreamQueue{
-				Size:    10,
-				Timeout: "10s",
-			}},
-			isPlus: true,
-			expected: version2.Upstream{
-				Name: "test-upstream-queue",
-				Queue: &version2.Queue{
-					Size:    10,
-					Timeout: "10s",
-				},
+func TestGenerateVirtualServerConfigWithLimitConn(t *testing.T) {
+	virtualServerEx := VirtualServerEx{
+		VirtualServer: &conf_v1.VirtualServer{
+			ObjectMeta: meta_v1.ObjectMeta{
+				Name:      "cafe",
+				Namespace: "default",
 			},
-			msg: "upstream queue with size and timeout",
-		},
-		{
-			name:     "test-upstream-queue-with-default-timeout",
-			upstream: conf_v1.Upstream{Service: serviceName, Port: 80, Queue: &conf_v1.UpstreamQueue{Size: 10, Timeout: ""}},
-			isPlus:   true,
-			expected: version2.Upstream{
-				Name: "test-upstream-queue-with-default-timeout",
-				Queue: &version2.Queue{
-					Size:    10,
-					Timeout: "60s",
+			Spec: conf_v1.VirtualServerSpec{
+				Host: "cafe.example.com",
+				Server: &conf_v1.VirtualServerServer{
+					LimitConn: &conf_v1.LimitConn{
+						Max: 100,
+					},
+				},
+				Upstreams: []conf_v1.Upstream{
+					{
+						Name:    "tea",
+						Service: "tea-svc",
+						Port:    80,
+					},
+					{
+						Name:    "coffee",
+						Service: "coffee-svc",
+						Port:    80,
+					},
+				},
+				Routes: []conf_v1.Route{
+					{
+						Path:   "/tea",
+						Action: &conf_v1.Action{Pass: "tea"},
+					},
+					{
+						Path:   "/coffee",
+						Action: &conf_v1.Action{Pass: "coffee"},
+						LimitConn: &conf_v1.LimitConn{
+							Key:      "${uri}",
+							ZoneSize: "20m",
+							Max:      5,
+						},
+					},
 				},
 			},
-			msg: "upstream queue with only size",
-		},
-		{
-			name:     "test-upstream-queue-nil",
-			upstream: conf_v1.Upstream{Service: serviceName, Port: 80, Queue: nil},
-			isPlus:   false,
-			expected: version2.Upstream{
-				Name: "test-upstream-queue-nil",
-			},
-			msg: "upstream queue with nil for OSS",
 		},
 	}
 
-	for _, test := range tests {
-		vsc := newVirtualServerConfigurator(&ConfigParams{}, test.isPlus, false)
-		result := vsc.generateUpstream(&conf_v1.VirtualServer{}, test.name, test.upstream, false, []string{})
-		if !reflect.DeepEqual(result, test.expected) {
-			t.Errorf("generateUpstream() returned %v but expected %v for the case of %v", result, test.expected, test.msg)
-		}
-	}
+	vsc := newVirtualServerConfigurator(&ConfigParams{}, false, false)
+	result, warnings := vsc.GenerateVirtualServerConfig(&virtualServerEx, "", policyOptions{})
 
-}
+	if len(warnings) != 0 {
+		t.Errorf("GenerateVirtualServerConfig returned warnings: %v", vsc.warnings)
+	}
 
-func TestGenerateQueueForPlus(t *testing.T) {
-	tests := []struct {
-		upstreamQueue *conf_v1.UpstreamQueue
-		expected      *version2.Queue
-		msg           string
-	}{
+	expectedZones := []version2.LimitConnZone{
 		{
-			upstreamQueue: &conf_v1.UpstreamQueue{Size: 10, Timeout: "10s"},
-			expected:      &version2.Queue{Size: 10, Timeout: "10s"},
-			msg:           "upstream queue with size and timeout",
+			ZoneName: "vs_default_cafe_route_lc_67dd099d",
+			Key:      "${uri}",
+			ZoneSize: "20m",
 		},
 		{
-			upstreamQueue: nil,
-			expected:      nil,
-			msg:           "upstream queue with nil",
+			ZoneName: "vs_default_cafe_server_lc",
+			Key:      "${binary_remote_addr}",
+			ZoneSize: "10m",
 		},
+	}
+	if !reflect.DeepEqual(result.LimitConnZones, expectedZones) {
+		t.Errorf("GenerateVirtualServerConfig returned limit conn zones %+v but expected %+v", result.LimitConnZones, expectedZones)
+	}
+
+	expectedServerLimitConns := []version2.LimitConn{
 		{
-			upstreamQueue: &conf_v1.UpstreamQueue{Size: 10},
-			expected:      &version2.Queue{Size: 10, Timeout: "60s"},
-			msg:           "upstream queue with only size",
+			ZoneName: "vs_default_cafe_server_lc",
+			Max:      100,
 		},
 	}
+	if !reflect.DeepEqual(result.Server.LimitConns, expectedServerLimitConns) {
+		t.Errorf("GenerateVirtualServerConfig returned server limit conns %+v but expected %+v", result.Server.LimitConns, expectedServerLimitConns)
+	}
 
-	for _, test := range tests {
-		result := generateQueueForPlus(test.upstreamQueue, "60s")
-		if !reflect.DeepEqual(result, test.expected) {
-			t.Errorf("generateQueueForPlus() returned %v but expected %v for the case of %v", result, test.expected, test.msg)
+	expectedLimitConns := [][]version2.LimitConn{
+		nil,
+		{
+			{
+				ZoneName: "vs_default_cafe_route_lc_67dd099d",
+				Max:      5,
+			},
+		},
+	}
+	for i, loc := range result.Server.Locations {
+		if !reflect.DeepEqual(loc.LimitConns, expectedLimitConns[i]) {
+			t.Errorf("GenerateVirtualServerConfig returned limit conns %+v but expected %+v for location %v", loc.LimitConns, expectedLimitConns[i], loc.Path)
 		}
 	}
+}
+
+func TestRemoveDuplicateLimitReqZones(t *testing.T) {
+	zones := []version2.LimitReqZone{
+		{ZoneName: "test"},
+		{ZoneName: "test2"},
+		{ZoneName: "test"},
+	}
+	expected := []version2.LimitReqZone{
+		{ZoneName: "test"},
+		{ZoneName: "test2"},
+	}
 
+	result := removeDuplicateLimitReqZones(zones)
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("removeDuplicateLimitReqZones() returned %v but expected %v", result, expected)
+	}
 }
 
-func TestGenerateSessionCookie(t *testing.T) {
-	tests := []struct {
-		sc       *conf_v1.SessionCookie
-		expected *version2.SessionCookie
-		msg      string
-	}{
-		{
-			sc:       &conf_v1.SessionCookie{Enable: true, Name: "test"},
-			expected: &version2.SessionCookie{Enable: true, Name: "test"},
-			msg:      "session cookie with name",
-		},
-		{
-			sc:       nil,
-			expected: nil,
-			msg:      "session cookie with nil",
+func TestGenerateCORS(t *testing.T) {
+	cors := &conf_v1.CORS{
+		AllowOrigins:     []string{"https://example.com"},
+		AllowMethods:     []string{"GET", "POST"},
+		AllowHeaders:     []string{"Authorization", "Content-Type"},
+		ExposeHeaders:    []string{"X-Request-ID", "X-Total-Count"},
+		AllowCredentials: true,
+		MaxAge:           createPointerFromInt(3600),
+	}
+
+	expected := &version2.CORS{
+		Headers: []version2.AddHeader{
+			{Name: "Access-Control-Allow-Origin", Value: "$pol_cors_origin"},
+			{Name: "Access-Control-Allow-Credentials", Value: "true"},
+			{Name: "Access-Control-Expose-Headers", Value: "X-Request-ID, X-Total-Count"},
+			{Name: "Vary", Value: "Origin"},
 		},
-		{
-			sc:       &conf_v1.SessionCookie{Name: "test"},
-			expected: nil,
-			msg:      "session cookie not enabled",
+		PreflightVariable: "$vs_cors_preflight",
+		PreflightHeaders: []version2.AddHeader{
+			{Name: "Access-Control-Allow-Methods", Value: "GET, POST"},
+			{Name: "Access-Control-Allow-Headers", Value: "Authorization, Content-Type"},
+			{Name: "Access-Control-Max-Age", Value: "3600"},
 		},
 	}
-	for _, test := range tests {
-		result := generateSessionCookie(test.sc)
-		if !reflect.DeepEqual(result, test.expected) {
-			t.Errorf("generateSessionCookie() returned %v, but expected %v for the case of: %v", result, test.expected, test.msg)
-		}
+
+	result, maps := generateCORS(cors, "$pol_cors_origin", "$vs_cors_preflight")
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("generateCORS() returned \n%+v but expected \n%+v", result, expected)
+	}
+	if len(maps) != 1 || maps[0].Variable != "$pol_cors_origin" {
+		t.Errorf("generateCORS() returned maps %+v but expected the map of the origin variable", maps)
 	}
 }
 
-func TestGeneratePath(t *testing.T) {
-	tests := []struct {
-		path     string
-		expected string
-	}{
-		{
-			path:     "/",
-			expected: "/",
-		},
-		{
-			path:     "=/exact/match",
-			expected: "=/exact/match",
+func TestGenerateCORSOriginMap(t *testing.T) {
+	origins := []string{
+		"https://example.com",
+		"https://*.example.com",
+		"http://localhost:*",
+		`~*^https://[a-z]+\.example\.org$`,
+	}
+
+	expected := version2.Map{
+		Source:   "$http_origin",
+		Variable: "$pol_cors_origin",
+		Parameters: []version2.Parameter{
+			{Value: `"https://example.com"`, Result: "$http_origin"},
+			{Value: `"~^https://[a-zA-Z0-9-]+(\.[a-zA-Z0-9-]+)*\.example\.com$"`, Result: "$http_origin"},
+			{Value: `"~^http://localhost(:[0-9]+)?$"`, Result: "$http_origin"},
+			{Value: `"~*^https://[a-z]+\.example\.org$"`, Result: "$http_origin"},
+			{Value: "default", Result: `""`},
 		},
-		{
-			path:     `~ *\\.jpg`,
-			expected: `~ "*\\.jpg"`,
+	}
+
+	result := generateCORSOriginMap(origins, "$pol_cors_origin")
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("generateCORSOriginMap() returned \n%+v but expected \n%+v", result, expected)
+	}
+}
+
+func TestAddCORSToLocations(t *testing.T) {
+	routeCORS := &version2.CORS{PreflightVariable: "$route"}
+	specCORS := &version2.CORS{PreflightVariable: "$spec"}
+
+	locations := []version2.Location{
+		{Path: "/tea", CORS: routeCORS},
+		{Path: "/coffee"},
+	}
+
+	if locationsUseCORS(locations[1:]) {
+		t.Errorf("locationsUseCORS() returned true for locations without CORS")
+	}
+
+	addCORSToLocations(specCORS, locations)
+
+	if locations[0].CORS != routeCORS {
+		t.Errorf("addCORSToLocations() replaced the CORS of a route with the CORS of the spec")
+	}
+	if locations[1].CORS != specCORS {
+		t.Errorf("addCORSToLocations() didn't add the CORS of the spec to a location without CORS")
+	}
+	if !locationsUseCORS(locations) {
+		t.Errorf("locationsUseCORS() returned false for locations with CORS")
+	}
+}
+
+func TestRemoveDuplicateMaps(t *testing.T) {
+	maps := []version2.Map{
+		{Variable: "$test"},
+		{Variable: "$test2"},
+		{Variable: "$test"},
+	}
+	expected := []version2.Map{
+		{Variable: "$test"},
+		{Variable: "$test2"},
+	}
+
+	result := removeDuplicateMaps(maps)
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("removeDuplicateMaps() returned %v but expected %v", result, expected)
+	}
+}
+
+func createPointerFromInt(n int) *int {
+	return &n
+}
+
+func createPointerFromBool(b bool) *bool {
+	return &b
+}
+
+// createLargeVirtualServerEx creates a VirtualServer with the number of routes for the benchmarks. Every route passes
+// the requests to its own upstream with 3 endpoints. Every fourth route has matches, and every fourth route has splits.
+func createLargeVirtualServerEx(routes int) *VirtualServerEx {
+	vs := &conf_v1.VirtualServer{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "cafe",
+			Namespace: "default",
 		},
-		{
-			path:     `~* *\\.PNG`,
-			expected: `~* "*\\.PNG"`,
+		Spec: conf_v1.VirtualServerSpec{
+			Host: "cafe.example.com",
 		},
 	}
+	endpoints := make(map[string][]string)
+
+	for i := 0; i < routes; i++ {
+		upstream := fmt.Sprintf("upstream-%d", i)
+		service := fmt.Sprintf("service-%d", i)
+
+		vs.Spec.Upstreams = append(vs.Spec.Upstreams, conf_v1.Upstream{
+			Name:    upstream,
+			Service: service,
+			Port:    80,
+		})
+		endpoints[GenerateEndpointsKey("default", service, nil, 80, "")] = []string{
+			fmt.Sprintf("10.0.%d.%d:80", i/256, i%256),
+			fmt.Sprintf("10.1.%d.%d:80", i/256, i%256),
+			fmt.Sprintf("10.2.%d.%d:80", i/256, i%256),
+		}
 
-	for _, test := range tests {
-		result := generatePath(test.path)
-		if result != test.expected {
-			t.Errorf("generatePath() returned %v, but expected %v.", result, test.expected)
+		route := conf_v1.Route{
+			Path:   fmt.Sprintf("/route-%d", i),
+			Action: &conf_v1.Action{Pass: upstream},
+		}
+		switch i % 4 {
+		case 1:
+			route.Matches = []conf_v1.Match{
+				{
+					Conditions: []conf_v1.Condition{
+						{
+							Header: "x-version",
+							Value:  fmt.Sprintf("v%d", i),
+						},
+					},
+					Action: &conf_v1.Action{Pass: upstream},
+				},
+			}
+		case 2:
+			route.Action = nil
+			route.Splits = []conf_v1.Split{
+				{
+					Weight: 90,
+					Action: &conf_v1.Action{Pass: upstream},
+				},
+				{
+					Weight: 10,
+					Action: &conf_v1.Action{Pass: upstream},
+				},
+			}
 		}
+		vs.Spec.Routes = append(vs.Spec.Routes, route)
+	}
+
+	return &VirtualServerEx{
+		VirtualServer: vs,
+		Endpoints:     endpoints,
+	}
+}
+
+func BenchmarkGenerateVirtualServerConfig(b *testing.B) {
+	for _, routes := range []int{10, 100, 500} {
+		vsEx := createLargeVirtualServerEx(routes)
+
+		b.Run(fmt.Sprintf("routes=%d", routes), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				vsc := newVirtualServerConfigurator(NewDefaultConfigParams(), true, false)
+				vsc.GenerateVirtualServerConfig(vsEx, "", policyOptions{})
+			}
+		})
+	}
+}
+
+func BenchmarkGenerateUpstreams(b *testing.B) {
+	vsEx := createLargeVirtualServerEx(500)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		vsc := newVirtualServerConfigurator(NewDefaultConfigParams(), true, false)
+		vsc.generateUpstreams(vsEx)
 	}
 }
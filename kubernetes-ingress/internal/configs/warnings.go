@@ -1,12 +1,75 @@
 package configs
 
-import "k8s.io/apimachinery/pkg/runtime"
+import (
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/nginxinc/kubernetes-ingress/internal/configs/version2"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	warningsCountHeader = "X-NGINX-Warnings-Count"
+	warningsCodesHeader = "X-NGINX-Warnings-Codes"
+)
+
+// WarningSeverity is the severity of a configuration warning.
+type WarningSeverity string
+
+const (
+	// WarningSeverityLow means that a setting is ignored or has no effect.
+	WarningSeverityLow WarningSeverity = "Low"
+	// WarningSeverityMedium means that NGINX handles the requests differently than the resource specifies.
+	WarningSeverityMedium WarningSeverity = "Medium"
+	// WarningSeverityHigh means that NGINX rejects the requests, for example, with the 500 status code.
+	WarningSeverityHigh WarningSeverity = "High"
+)
+
+// Codes of the configuration warnings. A code identifies the kind of the warning rather than the warning itself.
+const (
+	WarningCodeIgnoredSetting       = "IgnoredSetting"
+	WarningCodeContradictorySetting = "ContradictorySetting"
+	WarningCodeResolverRequired     = "ResolverRequired"
+	WarningCodeInvalidPolicy        = "InvalidPolicy"
+	WarningCodeConflictingPolicies  = "ConflictingPolicies"
+	WarningCodeInvalidSecret        = "InvalidSecret"
+	WarningCodeFallbackCertificate  = "FallbackCertificate"
+	WarningCodeOverlappingHost      = "OverlappingHost"
+	WarningCodeOverlappingMatch     = "OverlappingMatch"
+	WarningCodeUnsafeRetries        = "UnsafeRetries"
+	WarningCodeApproximatedSetting  = "ApproximatedSetting"
+	WarningCodeMissingConfigMap     = "MissingConfigMap"
+)
+
+// Warning is a configuration warning for a resource.
+type Warning struct {
+	Code     string
+	Severity WarningSeverity
+	Message  string
+}
+
+// NewWarning creates a warning with the message formatted according to msgFmt.
+func NewWarning(code string, severity WarningSeverity, msgFmt string, args ...interface{}) Warning {
+	return Warning{
+		Code:     code,
+		Severity: severity,
+		Message:  fmt.Sprintf(msgFmt, args...),
+	}
+}
+
+// String returns the message of the warning, so that warnings are formatted like the plain messages they replace.
+func (w Warning) String() string {
+	return w.Message
+}
 
 // Warnings stores a list of warnings for a given runtime k8s object in a map
-type Warnings map[runtime.Object][]string
+type Warnings map[runtime.Object][]Warning
 
 func newWarnings() Warnings {
-	return make(map[runtime.Object][]string)
+	return make(map[runtime.Object][]Warning)
 }
 
 // Add adds new Warnings to the map
@@ -15,3 +78,61 @@ func (w Warnings) Add(warnings Warnings) {
 		w[k] = v
 	}
 }
+
+// AddWarning adds the warning for the object, unless the object already has a warning with the same code and message.
+func (w Warnings) AddWarning(obj runtime.Object, warning Warning) {
+	for _, existing := range w[obj] {
+		if existing.Code == warning.Code && existing.Message == warning.Message {
+			return
+		}
+	}
+	w[obj] = append(w[obj], warning)
+}
+
+// Messages returns the messages of the warnings for the object.
+func (w Warnings) Messages(obj runtime.Object) []string {
+	var messages []string
+	for _, warning := range w[obj] {
+		messages = append(messages, warning.Message)
+	}
+	return messages
+}
+
+// getWarningCode returns a short code that identifies the warning message. Unlike the code of the warning,
+// it distinguishes the warnings of the same kind.
+func getWarningCode(msg string) string {
+	sum := sha256.Sum256([]byte(msg))
+	return fmt.Sprintf("%x", sum[:4])
+}
+
+// generateWarningsHeaders generates the response headers that report the number of the warnings and, optionally,
+// their sorted codes. It returns nil if there are no warnings.
+func generateWarningsHeaders(warnings Warnings, withCodes bool) []version2.AddHeader {
+	var codes []string
+	for _, objWarnings := range warnings {
+		for _, warning := range objWarnings {
+			codes = append(codes, getWarningCode(warning.Message))
+		}
+	}
+
+	if len(codes) == 0 {
+		return nil
+	}
+
+	headers := []version2.AddHeader{
+		{
+			Name:  warningsCountHeader,
+			Value: strconv.Itoa(len(codes)),
+		},
+	}
+
+	if withCodes {
+		sort.Strings(codes)
+		headers = append(headers, version2.AddHeader{
+			Name:  warningsCodesHeader,
+			Value: strings.Join(codes, ","),
+		})
+	}
+
+	return headers
+}
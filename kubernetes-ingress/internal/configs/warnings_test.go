@@ -0,0 +1,96 @@
+package configs
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/nginxinc/kubernetes-ingress/internal/configs/version2"
+	conf_v1 "github.com/nginxinc/kubernetes-ingress/pkg/apis/configuration/v1"
+)
+
+func TestGenerateWarningsHeaders(t *testing.T) {
+	vs := &conf_v1.VirtualServer{}
+	vsr := &conf_v1.VirtualServerRoute{}
+
+	warnings := Warnings{
+		vs: []Warning{
+			{Code: WarningCodeIgnoredSetting, Severity: WarningSeverityLow, Message: "first warning"},
+			{Code: WarningCodeIgnoredSetting, Severity: WarningSeverityLow, Message: "second warning"},
+		},
+		vsr: []Warning{
+			{Code: WarningCodeInvalidPolicy, Severity: WarningSeverityHigh, Message: "third warning"},
+		},
+	}
+
+	expectedCodes := []string{getWarningCode("first warning"), getWarningCode("second warning"), getWarningCode("third warning")}
+	if expectedCodes[0] == expectedCodes[1] || len(expectedCodes[0]) != 8 {
+		t.Fatalf("getWarningCode() returned the codes %v", expectedCodes)
+	}
+
+	headers := generateWarningsHeaders(warnings, false)
+	expected := []version2.AddHeader{
+		{
+			Name:  "X-NGINX-Warnings-Count",
+			Value: "3",
+		},
+	}
+	if !reflect.DeepEqual(headers, expected) {
+		t.Errorf("generateWarningsHeaders() returned %v but expected %v", headers, expected)
+	}
+
+	headers = generateWarningsHeaders(warnings, true)
+	if len(headers) != 2 || headers[1].Name != "X-NGINX-Warnings-Codes" {
+		t.Fatalf("generateWarningsHeaders() returned %v but expected the count and the codes headers", headers)
+	}
+	if !reflect.DeepEqual(headers, generateWarningsHeaders(warnings, true)) {
+		t.Errorf("generateWarningsHeaders() returned the codes in a different order")
+	}
+	for _, code := range expectedCodes {
+		if !strings.Contains(headers[1].Value, code) {
+			t.Errorf("generateWarningsHeaders() returned the codes %v without the code %v", headers[1].Value, code)
+		}
+	}
+
+	if headers := generateWarningsHeaders(Warnings{vs: nil}, true); headers != nil {
+		t.Errorf("generateWarningsHeaders() returned %v for no warnings", headers)
+	}
+}
+
+func TestWarningsAddWarning(t *testing.T) {
+	vs := &conf_v1.VirtualServer{}
+	vsr := &conf_v1.VirtualServerRoute{}
+
+	warnings := newWarnings()
+	warnings.AddWarning(vs, NewWarning(WarningCodeInvalidPolicy, WarningSeverityHigh, "Policy %s is missing or invalid", "default/jwt"))
+	warnings.AddWarning(vs, NewWarning(WarningCodeInvalidPolicy, WarningSeverityHigh, "Policy %s is missing or invalid", "default/jwt"))
+	warnings.AddWarning(vs, NewWarning(WarningCodeInvalidPolicy, WarningSeverityHigh, "Policy %s is missing or invalid", "default/oidc"))
+	warnings.AddWarning(vsr, NewWarning(WarningCodeIgnoredSetting, WarningSeverityLow, "ignored"))
+
+	expected := Warnings{
+		vs: []Warning{
+			{Code: WarningCodeInvalidPolicy, Severity: WarningSeverityHigh, Message: "Policy default/jwt is missing or invalid"},
+			{Code: WarningCodeInvalidPolicy, Severity: WarningSeverityHigh, Message: "Policy default/oidc is missing or invalid"},
+		},
+		vsr: []Warning{
+			{Code: WarningCodeIgnoredSetting, Severity: WarningSeverityLow, Message: "ignored"},
+		},
+	}
+	if !reflect.DeepEqual(warnings, expected) {
+		t.Errorf("AddWarning() resulted in %v but expected %v", warnings, expected)
+	}
+
+	expectedMessages := []string{"Policy default/jwt is missing or invalid", "Policy default/oidc is missing or invalid"}
+	if messages := warnings.Messages(vs); !reflect.DeepEqual(messages, expectedMessages) {
+		t.Errorf("Messages() returned %v but expected %v", messages, expectedMessages)
+	}
+
+	if messages := warnings.Messages(&conf_v1.Policy{}); messages != nil {
+		t.Errorf("Messages() returned %v for an object without warnings", messages)
+	}
+
+	if s := fmt.Sprintf("%v", warnings[vsr]); s != "[ignored]" {
+		t.Errorf("the warnings were formatted as %q but expected %q", s, "[ignored]")
+	}
+}
@@ -20,6 +20,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net"
+	"strconv"
 	"strings"
 	"time"
 
@@ -31,6 +33,9 @@ import (
 	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/scheme"
 	core_v1 "k8s.io/client-go/kubernetes/typed/core/v1"
@@ -44,12 +49,14 @@ import (
 	"github.com/nginxinc/kubernetes-ingress/pkg/apis/configuration/validation"
 	k8s_nginx "github.com/nginxinc/kubernetes-ingress/pkg/client/clientset/versioned"
 	api_v1 "k8s.io/api/core/v1"
+	discovery "k8s.io/api/discovery/v1alpha1"
 	extensions "k8s.io/api/extensions/v1beta1"
 	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 const (
 	ingressClassKey = "kubernetes.io/ingress.class"
+	drainAnnotation = "nginx.org/drain"
 )
 
 // LoadBalancerController watches Kubernetes API and
@@ -60,20 +67,27 @@ type LoadBalancerController struct {
 	ingressController            cache.Controller
 	svcController                cache.Controller
 	endpointController           cache.Controller
+	endpointSliceController      cache.Controller
 	configMapController          cache.Controller
 	secretController             cache.Controller
 	virtualServerController      cache.Controller
 	virtualServerRouteController cache.Controller
+	policyController             cache.Controller
+	contentConfigMapController   cache.Controller
 	podController                cache.Controller
 	ingressLister                storeToIngressLister
 	svcLister                    cache.Store
 	endpointLister               storeToEndpointLister
+	endpointSliceLister          storeToEndpointSliceLister
 	configMapLister              storeToConfigMapLister
 	podLister                    indexerToPodLister
 	secretLister                 storeToSecretLister
 	virtualServerLister          cache.Store
 	virtualServerRouteLister     cache.Store
+	policyLister                 cache.Store
+	contentConfigMapLister       cache.Store
 	syncQueue                    *taskQueue
+	statusQueue                  *statusQueue
 	ctx                          context.Context
 	cancel                       context.CancelFunc
 	configurator                 *configs.Configurator
@@ -85,6 +99,8 @@ type LoadBalancerController struct {
 	useIngressClassOnly          bool
 	statusUpdater                *statusUpdater
 	leaderElector                *leaderelection.LeaderElector
+	statusRecords                statusRecords
+	enableFinalizers             bool
 	reportIngressStatus          bool
 	isLeaderElectionEnabled      bool
 	leaderElectionLockName       string
@@ -93,6 +109,18 @@ type LoadBalancerController struct {
 	controllerNamespace          string
 	wildcardTLSSecret            string
 	areCustomResourcesEnabled    bool
+	enableOIDC                   bool
+	reservedListenPorts          []int
+	validationStrictness         validation.Strictness
+	emulateQueue                 bool
+	allowedVariables             sets.String
+	upstreamNamingScheme         configs.UpstreamNamingScheme
+	snippetsValidator            *configs.SnippetsValidator
+	endpointsDebouncer           *endpointsDebouncer
+	endpointsDrainer             *endpointsDrainer
+	virtualServerTemplateWatcher *virtualServerTemplateWatcher
+	useEndpointSlices            bool
+	topologyZone                 string
 	metricsCollector             collectors.ControllerCollector
 }
 
@@ -114,9 +142,22 @@ type NewLoadBalancerControllerInput struct {
 	ReportIngressStatus       bool
 	IsLeaderElectionEnabled   bool
 	LeaderElectionLockName    string
+	EnableFinalizers          bool
 	WildcardTLSSecret         string
 	ConfigMaps                string
 	AreCustomResourcesEnabled bool
+	EnableOIDC                bool
+	ReservedListenPorts       []int
+	ValidationStrictness      validation.Strictness
+	EmulateQueue              bool
+	AllowedVariables          sets.String
+	UpstreamNamingScheme      configs.UpstreamNamingScheme
+	SnippetsValidator         *configs.SnippetsValidator
+	EndpointsDebouncePeriod   time.Duration
+	EndpointsDrainDelay       time.Duration
+	VirtualServerTemplatePath string
+	UseEndpointSlices         bool
+	TopologyZone              string
 	MetricsCollector          collectors.ControllerCollector
 }
 
@@ -133,11 +174,21 @@ func NewLoadBalancerController(input NewLoadBalancerControllerInput) *LoadBalanc
 		reportIngressStatus:       input.ReportIngressStatus,
 		isLeaderElectionEnabled:   input.IsLeaderElectionEnabled,
 		leaderElectionLockName:    input.LeaderElectionLockName,
+		enableFinalizers:          input.EnableFinalizers,
 		resync:                    input.ResyncPeriod,
 		namespace:                 input.Namespace,
 		controllerNamespace:       input.ControllerNamespace,
 		wildcardTLSSecret:         input.WildcardTLSSecret,
 		areCustomResourcesEnabled: input.AreCustomResourcesEnabled,
+		enableOIDC:                input.EnableOIDC,
+		reservedListenPorts:       input.ReservedListenPorts,
+		validationStrictness:      input.ValidationStrictness,
+		emulateQueue:              input.EmulateQueue,
+		allowedVariables:          input.AllowedVariables,
+		upstreamNamingScheme:      input.UpstreamNamingScheme,
+		snippetsValidator:         input.SnippetsValidator,
+		useEndpointSlices:         input.UseEndpointSlices,
+		topologyZone:              input.TopologyZone,
 		metricsCollector:          input.MetricsCollector,
 	}
 
@@ -148,8 +199,29 @@ func NewLoadBalancerController(input NewLoadBalancerControllerInput) *LoadBalanc
 	})
 	lbc.recorder = eventBroadcaster.NewRecorder(scheme.Scheme,
 		api_v1.EventSource{Component: "nginx-ingress-controller"})
+	if input.IsLeaderElectionEnabled {
+		lbc.recorder = newLeaderEventRecorder(lbc.recorder, lbc.isLeader)
+	}
 
 	lbc.syncQueue = newTaskQueue(lbc.sync)
+	lbc.statusQueue = newStatusQueue(lbc.syncStatus)
+
+	if input.EndpointsDebouncePeriod > 0 {
+		lbc.endpointsDebouncer = newEndpointsDebouncer(input.EndpointsDebouncePeriod, lbc.getEndpointsByKey,
+			func(endpoints *api_v1.Endpoints) { lbc.AddSyncQueue(endpoints) },
+			lbc.metricsCollector.IncreaseSuppressedEndpointsChanges)
+	}
+
+	if input.AreCustomResourcesEnabled && input.VirtualServerTemplatePath != "" {
+		lbc.virtualServerTemplateWatcher = newVirtualServerTemplateWatcher(input.VirtualServerTemplatePath, func() {
+			lbc.syncQueue.EnqueueTask(task{Kind: virtualServerTemplate, Key: input.VirtualServerTemplatePath})
+		})
+	}
+
+	if input.EndpointsDrainDelay > 0 {
+		lbc.endpointsDrainer = newEndpointsDrainer(input.EndpointsDrainDelay, lbc.getEndpointsByKey,
+			func(endpoints *api_v1.Endpoints) { lbc.AddSyncQueue(endpoints) })
+	}
 
 	glog.V(3).Infof("Nginx Ingress Controller has class: %v", input.IngressClass)
 
@@ -165,12 +237,18 @@ func NewLoadBalancerController(input NewLoadBalancerControllerInput) *LoadBalanc
 	lbc.addSecretHandler(createSecretHandlers(lbc))
 	lbc.addIngressHandler(createIngressHandlers(lbc))
 	lbc.addServiceHandler(createServiceHandlers(lbc))
-	lbc.addEndpointHandler(createEndpointHandlers(lbc))
-	lbc.addPodHandler()
+	if lbc.useEndpointSlices {
+		lbc.addEndpointSliceHandler(createEndpointSliceHandlers(lbc))
+	} else {
+		lbc.addEndpointHandler(createEndpointHandlers(lbc))
+	}
+	lbc.addPodHandler(createPodHandlers(lbc))
 
 	if lbc.areCustomResourcesEnabled {
 		lbc.addVirtualServerHandler(createVirtualServerHandlers(lbc))
 		lbc.addVirtualServerRouteHandler(createVirtualServerRouteHandlers(lbc))
+		lbc.addPolicyHandler(createPolicyHandlers(lbc))
+		lbc.addContentConfigMapHandler(createContentConfigMapHandlers(lbc))
 	}
 
 	if input.ConfigMaps != "" {
@@ -183,7 +261,7 @@ func NewLoadBalancerController(input NewLoadBalancerControllerInput) *LoadBalanc
 		}
 	}
 
-	if input.ReportIngressStatus && input.IsLeaderElectionEnabled {
+	if input.IsLeaderElectionEnabled {
 		lbc.addLeaderHandler(createLeaderHandler(lbc))
 	}
 
@@ -211,6 +289,54 @@ func (lbc *LoadBalancerController) AddSyncQueue(item interface{}) {
 	lbc.syncQueue.Enqueue(item)
 }
 
+// getEndpointsByKey returns the Endpoints of the service with the key. With EndpointSlices, the Endpoints are joined
+// from the EndpointSlices of the service.
+func (lbc *LoadBalancerController) getEndpointsByKey(key string) (*api_v1.Endpoints, bool, error) {
+	if lbc.useEndpointSlices {
+		return lbc.endpointSliceLister.GetEndpointsByKey(key)
+	}
+
+	obj, exists, err := lbc.endpointLister.GetByKey(key)
+	if err != nil || !exists {
+		return nil, exists, err
+	}
+	return obj.(*api_v1.Endpoints), true, nil
+}
+
+func (lbc *LoadBalancerController) getServiceEndpoints(svc *api_v1.Service) (api_v1.Endpoints, error) {
+	if lbc.useEndpointSlices {
+		return lbc.endpointSliceLister.GetServiceEndpoints(svc)
+	}
+	return lbc.endpointLister.GetServiceEndpoints(svc)
+}
+
+// changeEndpoints syncs the changed Endpoints, after the suppression period if it is configured.
+func (lbc *LoadBalancerController) changeEndpoints(old *api_v1.Endpoints, cur *api_v1.Endpoints) {
+	if lbc.endpointsDrainer != nil {
+		lbc.endpointsDrainer.Change(old, cur)
+	}
+	if lbc.endpointsDebouncer != nil {
+		glog.V(3).Infof("Endpoints %v changed, syncing after the suppression period", cur.Name)
+		lbc.endpointsDebouncer.Change(old, cur)
+		return
+	}
+	glog.V(3).Infof("Endpoints %v changed, syncing", cur.Name)
+	lbc.AddSyncQueue(cur)
+}
+
+// deleteEndpoints drops the pending changes of the deleted Endpoints and syncs them.
+func (lbc *LoadBalancerController) deleteEndpoints(endpoints *api_v1.Endpoints) {
+	if key, err := keyFunc(endpoints); err == nil {
+		if lbc.endpointsDebouncer != nil {
+			lbc.endpointsDebouncer.Forget(key)
+		}
+		if lbc.endpointsDrainer != nil {
+			lbc.endpointsDrainer.Forget(key)
+		}
+	}
+	lbc.AddSyncQueue(endpoints)
+}
+
 // addSecretHandler adds the handler for secrets to the controller
 func (lbc *LoadBalancerController) addSecretHandler(handlers cache.ResourceEventHandlerFuncs) {
 	lbc.secretLister.Store, lbc.secretController = cache.NewInformer(
@@ -267,6 +393,22 @@ func (lbc *LoadBalancerController) addEndpointHandler(handlers cache.ResourceEve
 	)
 }
 
+// addEndpointSliceHandler adds the handler for endpoint slices to the controller
+func (lbc *LoadBalancerController) addEndpointSliceHandler(handlers cache.ResourceEventHandlerFuncs) {
+	indexer, controller := cache.NewIndexerInformer(
+		cache.NewListWatchFromClient(
+			lbc.client.DiscoveryV1alpha1().RESTClient(),
+			"endpointslices",
+			lbc.namespace,
+			fields.Everything()),
+		&discovery.EndpointSlice{},
+		lbc.resync,
+		handlers,
+		cache.Indexers{endpointSliceServiceIndex: endpointSliceServiceIndexFunc},
+	)
+	lbc.endpointSliceLister.Indexer, lbc.endpointSliceController = indexer, controller
+}
+
 // addConfigMapHandler adds the handler for config maps to the controller
 func (lbc *LoadBalancerController) addConfigMapHandler(handlers cache.ResourceEventHandlerFuncs, namespace string) {
 	lbc.configMapLister.Store, lbc.configMapController = cache.NewInformer(
@@ -281,7 +423,7 @@ func (lbc *LoadBalancerController) addConfigMapHandler(handlers cache.ResourceEv
 	)
 }
 
-func (lbc *LoadBalancerController) addPodHandler() {
+func (lbc *LoadBalancerController) addPodHandler(handlers cache.ResourceEventHandlerFuncs) {
 	lbc.podLister.Indexer, lbc.podController = cache.NewIndexerInformer(
 		cache.NewListWatchFromClient(
 			lbc.client.CoreV1().RESTClient(),
@@ -290,7 +432,7 @@ func (lbc *LoadBalancerController) addPodHandler() {
 			fields.Everything()),
 		&api_v1.Pod{},
 		lbc.resync,
-		cache.ResourceEventHandlerFuncs{},
+		handlers,
 		cache.Indexers{},
 	)
 }
@@ -321,6 +463,34 @@ func (lbc *LoadBalancerController) addVirtualServerRouteHandler(handlers cache.R
 	)
 }
 
+func (lbc *LoadBalancerController) addPolicyHandler(handlers cache.ResourceEventHandlerFuncs) {
+	lbc.policyLister, lbc.policyController = cache.NewInformer(
+		cache.NewListWatchFromClient(
+			lbc.confClient.K8sV1().RESTClient(),
+			"policies",
+			lbc.namespace,
+			fields.Everything()),
+		&conf_v1.Policy{},
+		lbc.resync,
+		handlers,
+	)
+}
+
+// addContentConfigMapHandler adds the handler for the ConfigMaps with the content served by VirtualServers and
+// VirtualServerRoutes to the controller
+func (lbc *LoadBalancerController) addContentConfigMapHandler(handlers cache.ResourceEventHandlerFuncs) {
+	lbc.contentConfigMapLister, lbc.contentConfigMapController = cache.NewInformer(
+		cache.NewListWatchFromClient(
+			lbc.client.CoreV1().RESTClient(),
+			"configmaps",
+			lbc.namespace,
+			fields.Everything()),
+		&api_v1.ConfigMap{},
+		lbc.resync,
+		handlers,
+	)
+}
+
 // Run starts the loadbalancer controller
 func (lbc *LoadBalancerController) Run() {
 	lbc.ctx, lbc.cancel = context.WithCancel(context.Background())
@@ -330,7 +500,11 @@ func (lbc *LoadBalancerController) Run() {
 	}
 	go lbc.svcController.Run(lbc.ctx.Done())
 	go lbc.podController.Run(lbc.ctx.Done())
-	go lbc.endpointController.Run(lbc.ctx.Done())
+	if lbc.useEndpointSlices {
+		go lbc.endpointSliceController.Run(lbc.ctx.Done())
+	} else {
+		go lbc.endpointController.Run(lbc.ctx.Done())
+	}
 	go lbc.secretController.Run(lbc.ctx.Done())
 	if lbc.watchNginxConfigMaps {
 		go lbc.configMapController.Run(lbc.ctx.Done())
@@ -339,23 +513,60 @@ func (lbc *LoadBalancerController) Run() {
 	if lbc.areCustomResourcesEnabled {
 		go lbc.virtualServerController.Run(lbc.ctx.Done())
 		go lbc.virtualServerRouteController.Run(lbc.ctx.Done())
+		go lbc.policyController.Run(lbc.ctx.Done())
+		go lbc.contentConfigMapController.Run(lbc.ctx.Done())
+		go wait.Until(lbc.enqueueVirtualServersWithFallbackCertificatesDueForRotation, fallbackCertificatesCheckPeriod, lbc.ctx.Done())
+	}
+	if lbc.virtualServerTemplateWatcher != nil {
+		go wait.Until(lbc.virtualServerTemplateWatcher.check, virtualServerTemplateCheckPeriod, lbc.ctx.Done())
 	}
 	go lbc.syncQueue.Run(time.Second, lbc.ctx.Done())
+	go lbc.statusQueue.Run(time.Second, lbc.ctx.Done())
 	<-lbc.ctx.Done()
 }
 
+// virtualServerTemplateCheckPeriod is how often the controller checks if the file of the template of VirtualServers changed.
+const virtualServerTemplateCheckPeriod = 10 * time.Second
+
+// fallbackCertificatesCheckPeriod is how often the controller checks if self-signed fallback certificates need to be rotated.
+const fallbackCertificatesCheckPeriod = time.Hour
+
+// enqueueVirtualServersWithFallbackCertificatesDueForRotation enqueues the VirtualServers
+// which hosts use fallback certificates that are due for rotation.
+// The certificates are regenerated when the VirtualServers are synced.
+func (lbc *LoadBalancerController) enqueueVirtualServersWithFallbackCertificatesDueForRotation() {
+	hosts := lbc.configurator.GetHostsWithFallbackCertificatesDueForRotation()
+	if len(hosts) == 0 {
+		return
+	}
+
+	hostsDue := make(map[string]bool)
+	for _, host := range hosts {
+		hostsDue[host] = true
+	}
+
+	for _, obj := range lbc.virtualServerLister.List() {
+		vs := obj.(*conf_v1.VirtualServer)
+		if hostsDue[vs.Spec.Host] {
+			glog.V(3).Infof("Rotating the fallback certificate for VirtualServer %s/%s", vs.Namespace, vs.Name)
+			lbc.syncQueue.Enqueue(vs)
+		}
+	}
+}
+
 // Stop shutdowns the load balancer controller
 func (lbc *LoadBalancerController) Stop() {
 	lbc.cancel()
 
 	lbc.syncQueue.Shutdown()
+	lbc.statusQueue.Shutdown()
 }
 
 func (lbc *LoadBalancerController) syncEndpoint(task task) {
 	key := task.Key
 	glog.V(3).Infof("Syncing endpoints %v", key)
 
-	obj, endpExists, err := lbc.endpointLister.GetByKey(key)
+	obj, endpExists, err := lbc.getEndpointsByKey(key)
 	if err != nil {
 		lbc.syncQueue.Requeue(task, err)
 		return
@@ -417,7 +628,7 @@ func (lbc *LoadBalancerController) syncEndpoint(task task) {
 		}
 
 		if lbc.areCustomResourcesEnabled {
-			virtualServers := lbc.getVirtualServersForEndpoints(obj.(*api_v1.Endpoints))
+			virtualServers := lbc.getVirtualServersForEndpoints(obj)
 			virtualServersExes := lbc.virtualServersToVirtualServerExes(virtualServers)
 
 			if len(virtualServersExes) > 0 {
@@ -426,6 +637,12 @@ func (lbc *LoadBalancerController) syncEndpoint(task task) {
 				if err != nil {
 					glog.Errorf("Error updating endpoints for %v: %v", virtualServersExes, err)
 				}
+
+				for _, vsEx := range virtualServersExes {
+					if quarantineErr := lbc.configurator.GetVirtualServerQuarantineError(vsEx.VirtualServer); quarantineErr != nil {
+						lbc.emitQuarantineEventForVirtualServer(vsEx, quarantineErr)
+					}
+				}
 			}
 		}
 	}
@@ -446,6 +663,14 @@ func (lbc *LoadBalancerController) syncConfig(task task) {
 		cfgm := obj.(*api_v1.ConfigMap)
 		cfgParams = configs.ParseConfigMap(cfgm, lbc.isNginxPlus)
 
+		if lbc.snippetsValidator != nil {
+			snippetsErr := lbc.snippetsValidator.ValidateConfigMapSnippets(cfgParams)
+			if snippetsErr != nil {
+				glog.Errorf("ConfigMap %v has invalid snippets: %v", key, snippetsErr)
+				lbc.recorder.Eventf(cfgm, api_v1.EventTypeWarning, "InvalidSnippets", "Invalid snippets: %v", snippetsErr)
+			}
+		}
+
 		lbc.statusUpdater.SaveStatusFromExternalStatus(cfgm.Data["external-status-address"])
 	}
 
@@ -458,6 +683,7 @@ func (lbc *LoadBalancerController) syncConfig(task task) {
 			glog.V(3).Infof("error updating status on ConfigMap change: %v", err)
 		}
 	}
+	lbc.updateVirtualServersExternalEndpoints()
 
 	var virtualServerExes []*configs.VirtualServerEx
 	if lbc.areCustomResourcesEnabled {
@@ -503,10 +729,15 @@ func (lbc *LoadBalancerController) syncConfig(task task) {
 		vsEventTitle := eventTitle
 		vsEventWarningMessage := eventWarningMessage
 
-		if messages, ok := warnings[vsEx.VirtualServer]; ok && updateErr == nil {
+		if quarantineErr := lbc.configurator.GetVirtualServerQuarantineError(vsEx.VirtualServer); quarantineErr != nil {
+			lbc.emitQuarantineEventForVirtualServer(vsEx, quarantineErr)
+			continue
+		}
+
+		if _, ok := warnings[vsEx.VirtualServer]; ok && updateErr == nil {
 			vsEventType = api_v1.EventTypeWarning
 			vsEventTitle = "UpdatedWithWarning"
-			vsEventWarningMessage = fmt.Sprintf("with warning(s): %v", formatWarningMessages(messages))
+			vsEventWarningMessage = fmt.Sprintf("with warning(s): %v", formatWarningMessages(warnings.Messages(vsEx.VirtualServer)))
 		}
 
 		lbc.recorder.Eventf(vsEx.VirtualServer, vsEventType, vsEventTitle, "Configuration for %v/%v was updated %s",
@@ -516,10 +747,10 @@ func (lbc *LoadBalancerController) syncConfig(task task) {
 			vsrEventType := eventType
 			vsrEventTitle := eventTitle
 			vsrEventWarningMessage := eventWarningMessage
-			if messages, ok := warnings[vsr]; ok && updateErr == nil {
+			if _, ok := warnings[vsr]; ok && updateErr == nil {
 				vsrEventType = api_v1.EventTypeWarning
 				vsrEventTitle = "UpdatedWithWarning"
-				vsrEventWarningMessage = fmt.Sprintf("with warning(s): %v", formatWarningMessages(messages))
+				vsrEventWarningMessage = fmt.Sprintf("with warning(s): %v", formatWarningMessages(warnings.Messages(vsr)))
 			}
 			lbc.recorder.Eventf(vsr, vsrEventType, vsrEventTitle, "Configuration for %v/%v was updated %s",
 				vsr.Namespace, vsr.Name, vsrEventWarningMessage)
@@ -580,6 +811,11 @@ func (lbc *LoadBalancerController) virtualServersToVirtualServerExes(virtualServ
 	var virtualServersExes []*configs.VirtualServerEx
 
 	for _, vs := range virtualServers {
+		if err := lbc.findVirtualServerConflict(vs, virtualServers); err != nil {
+			glog.V(3).Infof("Skipping VirtualServer %s/%s: %v", vs.Namespace, vs.Name, err)
+			continue
+		}
+
 		vsEx, _ := lbc.createVirtualServer(vs) // ignoring VirtualServerRouteErrors
 		virtualServersExes = append(virtualServersExes, vsEx)
 	}
@@ -611,7 +847,73 @@ func (lbc *LoadBalancerController) sync(task task) {
 	case virtualServerRoute:
 		lbc.syncVirtualServerRoute(task)
 		lbc.updateVirtualServerMetrics()
+	case policy:
+		lbc.syncPolicy(task)
+	case virtualServerTemplate:
+		lbc.syncVirtualServerTemplate(task)
+	case namespaceResync:
+		lbc.syncNamespaceResync(task)
+	}
+}
+
+// syncVirtualServerTemplate applies the changed template file to the VirtualServers. If the template is invalid,
+// the previous template stays in use.
+func (lbc *LoadBalancerController) syncVirtualServerTemplate(task task) {
+	glog.V(3).Infof("Syncing the virtualserver template %v", task.Key)
+
+	virtualServerExes := lbc.virtualServersToVirtualServerExes(lbc.getVirtualServers())
+
+	if err := lbc.configurator.UpdateVirtualServerTemplateFromFile(virtualServerExes); err != nil {
+		glog.Errorf("Error updating the virtualserver template %v: %v", task.Key, err)
+	}
+
+	for _, vsEx := range virtualServerExes {
+		if quarantineErr := lbc.configurator.GetVirtualServerQuarantineError(vsEx.VirtualServer); quarantineErr != nil {
+			lbc.emitQuarantineEventForVirtualServer(vsEx, quarantineErr)
+		}
+	}
+}
+
+func (lbc *LoadBalancerController) syncPolicy(task task) {
+	key := task.Key
+	obj, polExists, err := lbc.policyLister.GetByKey(key)
+	if err != nil {
+		lbc.syncQueue.Requeue(task, err)
+		return
+	}
+
+	glog.V(2).Infof("Adding, Updating or Deleting Policy: %v\n", key)
+
+	if polExists {
+		pol := obj.(*conf_v1.Policy)
+		err := validation.ValidatePolicy(pol, lbc.isNginxPlus, lbc.enableOIDC)
+		if err != nil {
+			lbc.recorder.Eventf(pol, api_v1.EventTypeWarning, "Rejected", "Policy %v is invalid and was rejected: %v", key, err)
+		} else {
+			lbc.recorder.Eventf(pol, api_v1.EventTypeNormal, "AddedOrUpdated", "Policy %v was added or updated", key)
+		}
+	}
+
+	// it is safe to ignore the validation result here: for both valid and invalid policies,
+	// the referencing VirtualServers must be reconfigured
+
+	virtualServers := lbc.getVirtualServersForPolicyKey(key)
+	for _, vs := range virtualServers {
+		lbc.syncQueue.Enqueue(vs)
+	}
+}
+
+// deleteVirtualServer removes the configuration of the deleted VirtualServer.
+func (lbc *LoadBalancerController) deleteVirtualServer(key string) {
+	glog.V(2).Infof("Deleting VirtualServer: %v\n", key)
+
+	err := lbc.configurator.DeleteVirtualServer(key)
+	// TO-DO: emit events for referenced VirtualServerRoutes
+	if err != nil {
+		glog.Errorf("Error when deleting configuration for %v: %v", key, err)
 	}
+	lbc.statusRecords.deleteVirtualServer(key)
+	lbc.clearVirtualServerRoutesReferences(key)
 }
 
 func (lbc *LoadBalancerController) syncVirtualServer(task task) {
@@ -623,28 +925,47 @@ func (lbc *LoadBalancerController) syncVirtualServer(task task) {
 	}
 
 	if !vsExists {
-		glog.V(2).Infof("Deleting VirtualServer: %v\n", key)
+		lbc.deleteVirtualServer(key)
+		return
+	}
 
-		err := lbc.configurator.DeleteVirtualServer(key)
-		// TO-DO: emit events for referenced VirtualServerRoutes
+	vs := obj.(*conf_v1.VirtualServer)
+
+	if vs.DeletionTimestamp != nil {
+		lbc.deleteVirtualServer(key)
+
+		err := lbc.removeVirtualServerFinalizer(vs)
 		if err != nil {
-			glog.Errorf("Error when deleting configuration for %v: %v", key, err)
+			lbc.syncQueue.Requeue(task, err)
 		}
 		return
 	}
 
 	glog.V(2).Infof("Adding or Updating VirtualServer: %v\n", key)
 
-	vs := obj.(*conf_v1.VirtualServer)
+	vs, err = lbc.addVirtualServerFinalizer(vs)
+	if err != nil {
+		glog.Warningf("Failed to add the finalizer to VirtualServer %v: %v", key, err)
+	}
+
+	validationWarnings, validationErr := validation.ValidateVirtualServerWithWarnings(vs, lbc.isNginxPlus, lbc.emulateQueue, lbc.allowedVariables, lbc.validationStrictness)
+	if validationErr == nil {
+		validationErr = lbc.findVirtualServerConflict(vs, lbc.getVirtualServers())
+	}
+
+	routesResolved := newRoutesResolvedCondition(lbc.validateVirtualServerRouteReferences(vs))
 
-	validationErr := validation.ValidateVirtualServer(vs, lbc.isNginxPlus)
 	if validationErr != nil {
 		err := lbc.configurator.DeleteVirtualServer(key)
 		if err != nil {
 			glog.Errorf("Error when deleting configuration for %v: %v", key, err)
 		}
-		lbc.recorder.Eventf(vs, api_v1.EventTypeWarning, "Rejected", "VirtualServer %v is invalid and was rejected: %v", key, validationErr)
+		message := fmt.Sprintf("VirtualServer %v is invalid and was rejected: %v", key, validationErr)
+		lbc.recorder.Event(vs, api_v1.EventTypeWarning, "Rejected", message)
 		// TO-DO: emit events for referenced VirtualServerRoutes
+
+		lbc.updateVirtualServerStatus(vs, virtualServerState{State: stateInvalid, Reason: "Rejected", Message: message},
+			routesResolved, newNotGeneratedWarningsCondition())
 		return
 	}
 
@@ -653,11 +974,25 @@ func (lbc *LoadBalancerController) syncVirtualServer(task task) {
 	for _, vsrError := range vsrErrors {
 		lbc.recorder.Eventf(vs, api_v1.EventTypeWarning, "IgnoredVirtualServerRoute", "Ignored VirtualServerRoute %v: %v", vsrError.VirtualServerRouteNsName, vsrError.Error)
 		if vsrError.VirtualServerRoute != nil {
-			lbc.recorder.Eventf(vsrError.VirtualServerRoute, api_v1.EventTypeWarning, "Ignored", "Ignored by VirtualServer %v/%v: %v", vs.Namespace, vs.Name, vsrError.Error)
+			message := fmt.Sprintf("Ignored by VirtualServer %v/%v: %v", vs.Namespace, vs.Name, vsrError.Error)
+			vsrState := virtualServerState{State: stateInvalid, Reason: "Ignored", Message: message}
+			if lbc.isVirtualServerRouteStatusRecorded(vsrError.VirtualServerRoute, vsrState, key) {
+				continue
+			}
+
+			lbc.recorder.Event(vsrError.VirtualServerRoute, api_v1.EventTypeWarning, "Ignored", message)
+
+			lbc.updateVirtualServerRouteStatus(vsrError.VirtualServerRoute, vsrState, key)
 		}
 	}
 
 	warnings, addErr := lbc.configurator.AddOrUpdateVirtualServer(vsEx)
+	warnings = lbc.addValidationWarnings(warnings, vsEx, validationWarnings)
+	if overlaps := findRegexHostOverlaps(vs, lbc.getVirtualServers()); len(overlaps) > 0 {
+		for _, overlap := range overlaps {
+			warnings.AddWarning(vsEx.VirtualServer, configs.NewWarning(configs.WarningCodeOverlappingHost, configs.WarningSeverityMedium, "%s", overlap))
+		}
+	}
 
 	eventTitle := "AddedOrUpdated"
 	eventType := api_v1.EventTypeNormal
@@ -667,33 +1002,85 @@ func (lbc *LoadBalancerController) syncVirtualServer(task task) {
 		eventTitle = "AddedOrUpdatedWithError"
 		eventType = api_v1.EventTypeWarning
 		eventWarningMessage = fmt.Sprintf("but was not applied: %v", addErr)
+
+		if lbc.configurator.GetVirtualServerQuarantineError(vs) != nil {
+			eventTitle = "Quarantined"
+			eventWarningMessage = fmt.Sprintf("but was not applied and was quarantined: %v", addErr)
+		}
 	}
 
 	vsEventType := eventType
 	vsEventTitle := eventTitle
 	vsEventWarningMessage := eventWarningMessage
+	vsState := stateValid
 
-	if messages, ok := warnings[vsEx.VirtualServer]; ok && addErr == nil {
+	if addErr != nil {
+		vsState = stateInvalid
+	} else if _, ok := warnings[vsEx.VirtualServer]; ok {
 		vsEventType = api_v1.EventTypeWarning
 		vsEventTitle = "AddedOrUpdatedWithWarning"
-		vsEventWarningMessage = fmt.Sprintf("with warning(s): %v", formatWarningMessages(messages))
+		vsEventWarningMessage = fmt.Sprintf("with warning(s): %v", formatWarningMessages(warnings.Messages(vsEx.VirtualServer)))
+		vsState = stateWarning
 	}
 
-	lbc.recorder.Eventf(vs, vsEventType, vsEventTitle, "Configuration for %v was added or updated %s", key, vsEventWarningMessage)
+	vsMessage := fmt.Sprintf("Configuration for %v was added or updated %s", key, vsEventWarningMessage)
+	lbc.recorder.Event(vs, vsEventType, vsEventTitle, vsMessage)
+
+	lbc.updateVirtualServerStatus(vs, virtualServerState{State: vsState, Reason: vsEventTitle, Message: strings.TrimSpace(vsMessage)},
+		routesResolved, newWarningsCondition(vsEx, warnings))
 
 	for _, vsr := range vsEx.VirtualServerRoutes {
 		vsrEventType := eventType
 		vsrEventTitle := eventTitle
 		vsrEventWarningMessage := eventWarningMessage
+		vsrState := stateValid
 
-		if messages, ok := warnings[vsr]; ok && addErr == nil {
+		if addErr != nil {
+			vsrState = stateInvalid
+		} else if _, ok := warnings[vsr]; ok {
 			vsrEventType = api_v1.EventTypeWarning
 			vsrEventTitle = "AddedOrUpdatedWithWarning"
-			vsrEventWarningMessage = fmt.Sprintf("with warning(s): %v", formatWarningMessages(messages))
+			vsrEventWarningMessage = fmt.Sprintf("with warning(s): %v", formatWarningMessages(warnings.Messages(vsr)))
+			vsrState = stateWarning
+		}
+
+		vsrMessage := fmt.Sprintf("Configuration for %v/%v was added or updated %s", vsr.Namespace, vsr.Name, vsrEventWarningMessage)
+		state := virtualServerState{State: vsrState, Reason: vsrEventTitle, Message: strings.TrimSpace(vsrMessage)}
+		if lbc.isVirtualServerRouteStatusRecorded(vsr, state, key) {
+			continue
 		}
-		lbc.recorder.Eventf(vsr, vsrEventType, vsrEventTitle, "Configuration for %v/%v was added or updated %s", vsr.Namespace, vsr.Name, vsrEventWarningMessage)
+
+		lbc.recorder.Event(vsr, vsrEventType, vsrEventTitle, vsrMessage)
+
+		lbc.updateVirtualServerRouteStatus(vsr, state, key)
+	}
+
+}
+
+// addValidationWarnings adds the warnings of the lenient validation of the VirtualServer and its VirtualServerRoutes
+// to the warnings of the config generation.
+func (lbc *LoadBalancerController) addValidationWarnings(warnings configs.Warnings, vsEx *configs.VirtualServerEx, vsWarnings field.ErrorList) configs.Warnings {
+	if warnings == nil {
+		warnings = make(configs.Warnings)
+	}
+
+	for _, w := range vsWarnings {
+		warnings.AddWarning(vsEx.VirtualServer, newValidationWarning(w))
 	}
 
+	for _, vsr := range vsEx.VirtualServerRoutes {
+		vsrWarnings, _ := validation.ValidateVirtualServerRouteWithWarnings(vsr, lbc.isNginxPlus, lbc.emulateQueue, lbc.allowedVariables, lbc.validationStrictness)
+		for _, w := range vsrWarnings {
+			warnings.AddWarning(vsr, newValidationWarning(w))
+		}
+	}
+
+	return warnings
+}
+
+// newValidationWarning creates the warning for a field that the lenient validation ignores.
+func newValidationWarning(err *field.Error) configs.Warning {
+	return configs.NewWarning(configs.WarningCodeIgnoredSetting, configs.WarningSeverityLow, "%s", err.Error())
 }
 
 func (lbc *LoadBalancerController) syncVirtualServerRoute(task task) {
@@ -708,23 +1095,59 @@ func (lbc *LoadBalancerController) syncVirtualServerRoute(task task) {
 	if !exists {
 		glog.V(2).Infof("Deleting VirtualServerRoute: %v\n", key)
 
+		lbc.statusRecords.deleteVirtualServerRoute(key)
 		lbc.enqueueVirtualServersForVirtualServerRouteKey(key)
 		return
 	}
 
+	vsr := obj.(*conf_v1.VirtualServerRoute)
+
+	if vsr.DeletionTimestamp != nil {
+		glog.V(2).Infof("Deleting VirtualServerRoute: %v\n", key)
+
+		lbc.statusRecords.deleteVirtualServerRoute(key)
+
+		// the VirtualServers are reconfigured without the VirtualServerRoute before Kubernetes deletes it
+		for _, vs := range findVirtualServersForVirtualServerRouteKey(lbc.getVirtualServers(), key) {
+			vsTask, err := newTask(fmt.Sprintf("%s/%s", vs.Namespace, vs.Name), vs)
+			if err != nil {
+				glog.Errorf("Error when reconfiguring VirtualServer %v/%v: %v", vs.Namespace, vs.Name, err)
+				continue
+			}
+			lbc.syncVirtualServer(vsTask)
+		}
+
+		err := lbc.removeVirtualServerRouteFinalizer(vsr)
+		if err != nil {
+			lbc.syncQueue.Requeue(task, err)
+		}
+		return
+	}
+
 	glog.V(2).Infof("Adding or Updating VirtualServerRoute: %v\n", key)
 
-	vsr := obj.(*conf_v1.VirtualServerRoute)
+	vsr, err = lbc.addVirtualServerRouteFinalizer(vsr)
+	if err != nil {
+		glog.Warningf("Failed to add the finalizer to VirtualServerRoute %v: %v", key, err)
+	}
 
-	validationErr := validation.ValidateVirtualServerRoute(vsr, lbc.isNginxPlus)
+	_, validationErr := validation.ValidateVirtualServerRouteWithWarnings(vsr, lbc.isNginxPlus, lbc.emulateQueue, lbc.allowedVariables, lbc.validationStrictness)
 	if validationErr != nil {
-		lbc.recorder.Eventf(vsr, api_v1.EventTypeWarning, "Rejected", "VirtualServerRoute %s is invalid and was rejected: %v", key, validationErr)
+		message := fmt.Sprintf("VirtualServerRoute %s is invalid and was rejected: %v", key, validationErr)
+		lbc.recorder.Event(vsr, api_v1.EventTypeWarning, "Rejected", message)
+
+		lbc.updateVirtualServerRouteStatus(vsr, virtualServerState{State: stateInvalid, Reason: "Rejected", Message: message}, vsr.Status.ReferencedBy)
 	}
 
 	vsCount := lbc.enqueueVirtualServersForVirtualServerRouteKey(key)
 
 	if vsCount == 0 {
-		lbc.recorder.Eventf(vsr, api_v1.EventTypeWarning, "NoVirtualServersFound", "No VirtualServer references VirtualServerRoute %s", key)
+		message := fmt.Sprintf("No VirtualServer references VirtualServerRoute %s", key)
+		lbc.recorder.Event(vsr, api_v1.EventTypeWarning, "NoVirtualServersFound", message)
+
+		if validationErr == nil {
+			lbc.updateVirtualServerRouteStatus(vsr, virtualServerState{State: stateWarning, Reason: "NoVirtualServersFound", Message: message}, "")
+		}
 	}
 
 }
@@ -884,6 +1307,7 @@ func (lbc *LoadBalancerController) syncExternalService(task task) {
 			glog.Errorf("error updating ingress status in syncExternalService: %v", err)
 		}
 	}
+	lbc.updateVirtualServersExternalEndpoints()
 }
 
 // IsExternalServiceForStatus matches the service specified by the external-service arg
@@ -893,13 +1317,15 @@ func (lbc *LoadBalancerController) IsExternalServiceForStatus(svc *api_v1.Servic
 
 // reportStatusEnabled determines if we should attempt to report status
 func (lbc *LoadBalancerController) reportStatusEnabled() bool {
-	if lbc.reportIngressStatus {
-		if lbc.isLeaderElectionEnabled {
-			return lbc.leaderElector != nil && lbc.leaderElector.IsLeader()
-		}
-		return true
+	return lbc.reportIngressStatus && lbc.isLeader()
+}
+
+// isLeader determines if the Ingress Controller is the leader. Without leader election, every replica is the leader.
+func (lbc *LoadBalancerController) isLeader() bool {
+	if lbc.isLeaderElectionEnabled {
+		return lbc.leaderElector != nil && lbc.leaderElector.IsLeader()
 	}
-	return false
+	return true
 }
 
 func (lbc *LoadBalancerController) syncSecret(task task) {
@@ -983,7 +1409,7 @@ func (lbc *LoadBalancerController) handleRegularSecretDeletion(key string, ings
 	}
 
 	lbc.emitEventForIngresses(eventType, title, message, ings)
-	lbc.emitEventForVirtualServers(eventType, title, message, virtualServers)
+	lbc.emitUpdateEventForVirtualServers(eventType, title, message, virtualServers)
 }
 
 func (lbc *LoadBalancerController) handleSecretUpdate(secret *api_v1.Secret, ings []extensions.Ingress, virtualServers []*conf_v1.VirtualServer) {
@@ -1008,8 +1434,29 @@ func (lbc *LoadBalancerController) handleSecretUpdate(secret *api_v1.Secret, ing
 	// we can safely ignore the error because the secret is valid in this function
 	kind, _ := GetSecretKind(secret)
 
-	if kind == JWK {
-		lbc.configurator.AddOrUpdateJWKSecret(secret)
+	if kind == JWK || kind == OIDC || kind == CA || kind == Htpasswd {
+		if kind == JWK {
+			lbc.configurator.AddOrUpdateJWKSecret(secret)
+		} else if kind == CA {
+			lbc.configurator.AddOrUpdateCASecret(secret)
+		} else if kind == Htpasswd {
+			lbc.configurator.AddOrUpdateHtpasswdSecret(secret)
+		}
+
+		if len(virtualServers) > 0 {
+			// VirtualServers reference JWK, OIDC, CA and htpasswd Secrets through jwt, oidc, egressMTLS and basicAuth policies
+			virtualServerExes := lbc.virtualServersToVirtualServerExes(virtualServers)
+
+			err := lbc.configurator.UpdateVirtualServers(virtualServerExes)
+			if err != nil {
+				glog.Errorf("Error when updating Secret %v: %v", secretNsName, err)
+				lbc.recorder.Eventf(secret, api_v1.EventTypeWarning, "UpdatedWithError", "%v was updated, but not applied: %v", secretNsName, err)
+
+				eventType = api_v1.EventTypeWarning
+				title = "UpdatedWithError"
+				message = fmt.Sprintf("Configuration was updated due to updated secret %v, but not applied: %v", secretNsName, err)
+			}
+		}
 	} else {
 		regular, mergeable := lbc.createIngresses(ings)
 
@@ -1027,7 +1474,7 @@ func (lbc *LoadBalancerController) handleSecretUpdate(secret *api_v1.Secret, ing
 	}
 
 	lbc.emitEventForIngresses(eventType, title, message, ings)
-	lbc.emitEventForVirtualServers(eventType, title, message, virtualServers)
+	lbc.emitUpdateEventForVirtualServers(eventType, title, message, virtualServers)
 }
 
 func (lbc *LoadBalancerController) handleSpecialSecretUpdate(secret *api_v1.Secret) {
@@ -1078,6 +1525,27 @@ func (lbc *LoadBalancerController) emitEventForVirtualServers(eventType string,
 	}
 }
 
+// emitUpdateEventForVirtualServers emits the event for the VirtualServers after their configuration was updated.
+// The quarantined VirtualServers get a Quarantined event instead.
+func (lbc *LoadBalancerController) emitUpdateEventForVirtualServers(eventType string, title string, message string, virtualServers []*conf_v1.VirtualServer) {
+	for _, vs := range virtualServers {
+		if quarantineErr := lbc.configurator.GetVirtualServerQuarantineError(vs); quarantineErr != nil {
+			lbc.recorder.Eventf(vs, api_v1.EventTypeWarning, "Quarantined", "Configuration for %v/%v was not applied and was quarantined: %v", vs.Namespace, vs.Name, quarantineErr)
+			continue
+		}
+		lbc.recorder.Eventf(vs, eventType, title, message)
+	}
+}
+
+func (lbc *LoadBalancerController) emitQuarantineEventForVirtualServer(vsEx *configs.VirtualServerEx, quarantineErr error) {
+	vs := vsEx.VirtualServer
+	lbc.recorder.Eventf(vs, api_v1.EventTypeWarning, "Quarantined", "Configuration for %v/%v was not applied and was quarantined: %v", vs.Namespace, vs.Name, quarantineErr)
+
+	for _, vsr := range vsEx.VirtualServerRoutes {
+		lbc.recorder.Eventf(vsr, api_v1.EventTypeWarning, "Quarantined", "Configuration for %v/%v was not applied because VirtualServer %v/%v was quarantined", vsr.Namespace, vsr.Name, vs.Namespace, vs.Name)
+	}
+}
+
 func (lbc *LoadBalancerController) createIngresses(ings []extensions.Ingress) (regular []configs.IngressEx, mergeable []configs.MergeableIngresses) {
 	for i := range ings {
 		if isMaster(&ings[i]) {
@@ -1318,66 +1786,317 @@ func findVirtualServerRoutesForService(virtualServerRoutes []*conf_v1.VirtualSer
 
 func (lbc *LoadBalancerController) getVirtualServersForSecret(secretNamespace string, secretName string) []*conf_v1.VirtualServer {
 	virtualServers := lbc.getVirtualServers()
-	return findVirtualServersForSecret(virtualServers, secretNamespace, secretName)
+	result := findVirtualServersForSecret(virtualServers, secretNamespace, secretName)
+
+	for _, vsr := range findVirtualServerRoutesForHealthCheckSecret(lbc.getVirtualServerRoutes(), secretNamespace, secretName) {
+		result = append(result, findVirtualServersForVirtualServerRoute(virtualServers, vsr)...)
+	}
+
+	for _, pol := range findPoliciesForSecret(lbc.getPolicies(), secretNamespace, secretName) {
+		policyKey := pol.Namespace + "/" + pol.Name
+		result = append(result, lbc.getVirtualServersForPolicyKey(policyKey)...)
+	}
+
+	return removeDuplicateVirtualServers(result)
 }
 
-func findVirtualServersForSecret(virtualServers []*conf_v1.VirtualServer, secretNamespace string, secretName string) []*conf_v1.VirtualServer {
-	var result []*conf_v1.VirtualServer
+func (lbc *LoadBalancerController) getPolicies() []*conf_v1.Policy {
+	var policies []*conf_v1.Policy
 
-	for _, vs := range virtualServers {
-		if vs.Spec.TLS == nil {
-			continue
-		}
-		if vs.Spec.TLS.Secret == "" {
+	for _, obj := range lbc.policyLister.List() {
+		policies = append(policies, obj.(*conf_v1.Policy))
+	}
+
+	return policies
+}
+
+func findPoliciesForSecret(policies []*conf_v1.Policy, secretNamespace string, secretName string) []*conf_v1.Policy {
+	var result []*conf_v1.Policy
+
+	for _, pol := range policies {
+		if pol.Namespace != secretNamespace {
 			continue
 		}
 
-		if vs.Namespace == secretNamespace && vs.Spec.TLS.Secret == secretName {
-			result = append(result, vs)
+		if pol.Spec.JWTAuth != nil && pol.Spec.JWTAuth.Secret == secretName {
+			result = append(result, pol)
+		} else if pol.Spec.OIDC != nil && pol.Spec.OIDC.ClientSecret == secretName {
+			result = append(result, pol)
+		} else if pol.Spec.EgressMTLS != nil && (pol.Spec.EgressMTLS.TLSSecret == secretName || pol.Spec.EgressMTLS.TrustedCertSecret == secretName) {
+			result = append(result, pol)
+		} else if pol.Spec.BasicAuth != nil && pol.Spec.BasicAuth.Secret == secretName {
+			result = append(result, pol)
 		}
 	}
 
 	return result
 }
 
-func (lbc *LoadBalancerController) getVirtualServers() []*conf_v1.VirtualServer {
-	var virtualServers []*conf_v1.VirtualServer
-
-	for _, obj := range lbc.virtualServerLister.List() {
-		vs := obj.(*conf_v1.VirtualServer)
+func removeDuplicateVirtualServers(virtualServers []*conf_v1.VirtualServer) []*conf_v1.VirtualServer {
+	var result []*conf_v1.VirtualServer
+	seen := make(map[string]bool)
 
-		err := validation.ValidateVirtualServer(vs, lbc.isNginxPlus)
-		if err != nil {
-			glog.V(3).Infof("Skipping invalid VirtualServer %s/%s: %v", vs.Namespace, vs.Name, err)
+	for _, vs := range virtualServers {
+		key := vs.Namespace + "/" + vs.Name
+		if seen[key] {
 			continue
 		}
 
-		virtualServers = append(virtualServers, vs)
+		seen[key] = true
+		result = append(result, vs)
 	}
 
-	return virtualServers
+	return result
 }
 
-func (lbc *LoadBalancerController) getVirtualServerRoutes() []*conf_v1.VirtualServerRoute {
-	var virtualServerRoutes []*conf_v1.VirtualServerRoute
+// isHealthCheckSecret returns true if the health checks of the upstreams reference the Secret.
+func isHealthCheckSecret(namespace string, upstreams []conf_v1.Upstream, secretKey string) bool {
+	for _, key := range configs.GetHealthCheckCASecretKeys(namespace, upstreams) {
+		if key == secretKey {
+			return true
+		}
+	}
+	return false
+}
 
-	for _, obj := range lbc.virtualServerRouteLister.List() {
-		vsr := obj.(*conf_v1.VirtualServerRoute)
+func findVirtualServerRoutesForHealthCheckSecret(virtualServerRoutes []*conf_v1.VirtualServerRoute, secretNamespace string, secretName string) []*conf_v1.VirtualServerRoute {
+	var result []*conf_v1.VirtualServerRoute
 
-		err := validation.ValidateVirtualServerRoute(vsr, lbc.isNginxPlus)
-		if err != nil {
-			glog.V(3).Infof("Skipping invalid VirtualServerRoute %s/%s: %v", vsr.Namespace, vsr.Name, err)
-			continue
+	for _, vsr := range virtualServerRoutes {
+		if isHealthCheckSecret(vsr.Namespace, vsr.Spec.Upstreams, secretNamespace+"/"+secretName) {
+			result = append(result, vsr)
 		}
-
-		virtualServerRoutes = append(virtualServerRoutes, vsr)
 	}
 
-	return virtualServerRoutes
+	return result
 }
 
-func (lbc *LoadBalancerController) enqueueVirtualServersForVirtualServerRouteKey(key string) int {
-	virtualServers := findVirtualServersForVirtualServerRouteKey(lbc.getVirtualServers(), key)
+func findVirtualServersForSecret(virtualServers []*conf_v1.VirtualServer, secretNamespace string, secretName string) []*conf_v1.VirtualServer {
+	var result []*conf_v1.VirtualServer
+
+	for _, vs := range virtualServers {
+		if isHealthCheckSecret(vs.Namespace, vs.Spec.Upstreams, secretNamespace+"/"+secretName) {
+			result = append(result, vs)
+			continue
+		}
+
+		if vs.Spec.TLS == nil {
+			continue
+		}
+		if vs.Spec.TLS.Secret == "" {
+			continue
+		}
+
+		if vs.Namespace == secretNamespace && vs.Spec.TLS.Secret == secretName {
+			result = append(result, vs)
+		}
+	}
+
+	return result
+}
+
+// getRouteActions returns the actions of the route, including the actions of its splits and matches.
+func getRouteActions(route conf_v1.Route) []*conf_v1.Action {
+	var actions []*conf_v1.Action
+
+	if route.Action != nil {
+		actions = append(actions, route.Action)
+	}
+	for _, s := range route.Splits {
+		actions = append(actions, s.Action)
+	}
+	for _, m := range route.Matches {
+		if m.Action != nil {
+			actions = append(actions, m.Action)
+		}
+		for _, s := range m.Splits {
+			actions = append(actions, s.Action)
+		}
+	}
+
+	return actions
+}
+
+// getServedConfigMapNames returns the names of the ConfigMaps with the content served by the actions of the routes.
+func getServedConfigMapNames(routes []conf_v1.Route) []string {
+	var names []string
+
+	for _, r := range routes {
+		for _, a := range getRouteActions(r) {
+			if a != nil && a.Serve != nil && a.Serve.ConfigMap != "" {
+				names = append(names, a.Serve.ConfigMap)
+			}
+		}
+	}
+
+	return names
+}
+
+func isConfigMapServed(routes []conf_v1.Route, name string) bool {
+	for _, n := range getServedConfigMapNames(routes) {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+func findVirtualServersForConfigMap(virtualServers []*conf_v1.VirtualServer, configMapNamespace string, configMapName string) []*conf_v1.VirtualServer {
+	var result []*conf_v1.VirtualServer
+
+	for _, vs := range virtualServers {
+		if vs.Namespace == configMapNamespace && isConfigMapServed(vs.Spec.Routes, configMapName) {
+			result = append(result, vs)
+		}
+	}
+
+	return result
+}
+
+func findVirtualServerRoutesForConfigMap(virtualServerRoutes []*conf_v1.VirtualServerRoute, configMapNamespace string, configMapName string) []*conf_v1.VirtualServerRoute {
+	var result []*conf_v1.VirtualServerRoute
+
+	for _, vsr := range virtualServerRoutes {
+		if vsr.Namespace == configMapNamespace && isConfigMapServed(vsr.Spec.Subroutes, configMapName) {
+			result = append(result, vsr)
+		}
+	}
+
+	return result
+}
+
+func (lbc *LoadBalancerController) getVirtualServersForConfigMap(configMapNamespace string, configMapName string) []*conf_v1.VirtualServer {
+	virtualServers := lbc.getVirtualServers()
+	result := findVirtualServersForConfigMap(virtualServers, configMapNamespace, configMapName)
+
+	for _, vsr := range findVirtualServerRoutesForConfigMap(lbc.getVirtualServerRoutes(), configMapNamespace, configMapName) {
+		result = append(result, findVirtualServersForVirtualServerRoute(virtualServers, vsr)...)
+	}
+
+	return removeDuplicateVirtualServers(result)
+}
+
+// enqueueVirtualServersForConfigMap enqueues the VirtualServers that serve the content of the ConfigMap
+// directly or through their VirtualServerRoutes.
+func (lbc *LoadBalancerController) enqueueVirtualServersForConfigMap(configMap *api_v1.ConfigMap) {
+	virtualServers := lbc.getVirtualServersForConfigMap(configMap.Namespace, configMap.Name)
+	if len(virtualServers) > 0 {
+		glog.V(3).Infof("Found %v VirtualServers with ConfigMap %v/%v", len(virtualServers), configMap.Namespace, configMap.Name)
+	}
+
+	for _, vs := range virtualServers {
+		lbc.syncQueue.Enqueue(vs)
+	}
+}
+
+func (lbc *LoadBalancerController) getVirtualServersForPolicyKey(policyKey string) []*conf_v1.VirtualServer {
+	virtualServers := lbc.getVirtualServers()
+	virtualServerRoutes := lbc.getVirtualServerRoutes()
+
+	var result []*conf_v1.VirtualServer
+
+	result = append(result, findVirtualServersForPolicyKey(virtualServers, policyKey)...)
+
+	for _, vsr := range findVirtualServerRoutesForPolicyKey(virtualServerRoutes, policyKey) {
+		result = append(result, findVirtualServersForVirtualServerRoute(virtualServers, vsr)...)
+	}
+
+	return result
+}
+
+func findVirtualServersForPolicyKey(virtualServers []*conf_v1.VirtualServer, policyKey string) []*conf_v1.VirtualServer {
+	var result []*conf_v1.VirtualServer
+
+	for _, vs := range virtualServers {
+		if isPolicyReferenced(vs.Spec.Policies, vs.Namespace, policyKey) {
+			result = append(result, vs)
+			continue
+		}
+
+		for _, r := range vs.Spec.Routes {
+			if isPolicyReferenced(r.Policies, vs.Namespace, policyKey) {
+				result = append(result, vs)
+				break
+			}
+		}
+	}
+
+	return result
+}
+
+func findVirtualServerRoutesForPolicyKey(virtualServerRoutes []*conf_v1.VirtualServerRoute, policyKey string) []*conf_v1.VirtualServerRoute {
+	var result []*conf_v1.VirtualServerRoute
+
+	for _, vsr := range virtualServerRoutes {
+		for _, r := range vsr.Spec.Subroutes {
+			if isPolicyReferenced(r.Policies, vsr.Namespace, policyKey) {
+				result = append(result, vsr)
+				break
+			}
+		}
+	}
+
+	return result
+}
+
+func isPolicyReferenced(policies []conf_v1.PolicyReference, resourceNamespace string, policyKey string) bool {
+	for _, p := range policies {
+		namespace := p.Namespace
+		if namespace == "" {
+			namespace = resourceNamespace
+		}
+
+		if fmt.Sprintf("%s/%s", namespace, p.Name) == policyKey {
+			return true
+		}
+	}
+
+	return false
+}
+
+// GetVirtualServers returns the valid VirtualServers that the Ingress Controller handles.
+func (lbc *LoadBalancerController) GetVirtualServers() []*conf_v1.VirtualServer {
+	return lbc.getVirtualServers()
+}
+
+func (lbc *LoadBalancerController) getVirtualServers() []*conf_v1.VirtualServer {
+	var virtualServers []*conf_v1.VirtualServer
+
+	for _, obj := range lbc.virtualServerLister.List() {
+		vs := obj.(*conf_v1.VirtualServer)
+
+		_, err := validation.ValidateVirtualServerWithWarnings(vs, lbc.isNginxPlus, lbc.emulateQueue, lbc.allowedVariables, lbc.validationStrictness)
+		if err != nil {
+			glog.V(3).Infof("Skipping invalid VirtualServer %s/%s: %v", vs.Namespace, vs.Name, err)
+			continue
+		}
+
+		virtualServers = append(virtualServers, vs)
+	}
+
+	return virtualServers
+}
+
+func (lbc *LoadBalancerController) getVirtualServerRoutes() []*conf_v1.VirtualServerRoute {
+	var virtualServerRoutes []*conf_v1.VirtualServerRoute
+
+	for _, obj := range lbc.virtualServerRouteLister.List() {
+		vsr := obj.(*conf_v1.VirtualServerRoute)
+
+		_, err := validation.ValidateVirtualServerRouteWithWarnings(vsr, lbc.isNginxPlus, lbc.emulateQueue, lbc.allowedVariables, lbc.validationStrictness)
+		if err != nil {
+			glog.V(3).Infof("Skipping invalid VirtualServerRoute %s/%s: %v", vsr.Namespace, vsr.Name, err)
+			continue
+		}
+
+		virtualServerRoutes = append(virtualServerRoutes, vsr)
+	}
+
+	return virtualServerRoutes
+}
+
+func (lbc *LoadBalancerController) enqueueVirtualServersForVirtualServerRouteKey(key string) int {
+	virtualServers := findVirtualServersForVirtualServerRouteKey(lbc.getVirtualServers(), key)
 
 	for _, vs := range virtualServers {
 		lbc.syncQueue.Enqueue(vs)
@@ -1386,6 +2105,212 @@ func (lbc *LoadBalancerController) enqueueVirtualServersForVirtualServerRouteKey
 	return len(virtualServers)
 }
 
+// enqueueVirtualServersWithListener enqueues the VirtualServers with a listener except for the given one,
+// so that the conflicts between their ports get resolved again.
+func (lbc *LoadBalancerController) enqueueVirtualServersWithListener(virtualServer *conf_v1.VirtualServer) {
+	for _, vs := range lbc.getVirtualServers() {
+		if vs.Spec.Listener == nil || (vs.Namespace == virtualServer.Namespace && vs.Name == virtualServer.Name) {
+			continue
+		}
+
+		lbc.syncQueue.Enqueue(vs)
+	}
+}
+
+// enqueueVirtualServersWithHost enqueues the VirtualServers with the host or with a regex host that matches it
+// except for the given one, so that the conflicts between their hosts get resolved again.
+func (lbc *LoadBalancerController) enqueueVirtualServersWithHost(virtualServer *conf_v1.VirtualServer, host string) {
+	for _, vs := range lbc.getVirtualServers() {
+		if vs.Namespace == virtualServer.Namespace && vs.Name == virtualServer.Name {
+			continue
+		}
+
+		if vs.Spec.Host != host && !(validation.IsRegexHost(vs.Spec.Host) && validation.MatchRegexHost(vs.Spec.Host, host)) {
+			continue
+		}
+
+		lbc.syncQueue.Enqueue(vs)
+	}
+}
+
+// enqueueVirtualServersWithSafeNsName enqueues the VirtualServers whose names in the config collide with the names
+// of the given one, so that the conflicts between their names get resolved again.
+func (lbc *LoadBalancerController) enqueueVirtualServersWithSafeNsName(virtualServer *conf_v1.VirtualServer) {
+	safeNsName := configs.GetSafeNsNameForVirtualServer(virtualServer)
+	upstreamNamePrefix := lbc.upstreamNamingScheme.GetUpstreamNamePrefixForVirtualServer(virtualServer)
+
+	for _, vs := range lbc.getVirtualServers() {
+		if vs.Namespace == virtualServer.Namespace && vs.Name == virtualServer.Name {
+			continue
+		}
+
+		if configs.GetSafeNsNameForVirtualServer(vs) == safeNsName ||
+			lbc.upstreamNamingScheme.GetUpstreamNamePrefixForVirtualServer(vs) == upstreamNamePrefix {
+			lbc.syncQueue.Enqueue(vs)
+		}
+	}
+}
+
+// findVirtualServerConflict returns an error if the VirtualServer conflicts with another VirtualServer
+// over its host, the ports of its listener or the names in its config.
+func (lbc *LoadBalancerController) findVirtualServerConflict(virtualServer *conf_v1.VirtualServer, virtualServers []*conf_v1.VirtualServer) error {
+	err := findHostConflict(virtualServer, virtualServers)
+	if err != nil {
+		return err
+	}
+
+	err = findNameConflict(virtualServer, virtualServers, lbc.upstreamNamingScheme)
+	if err != nil {
+		return err
+	}
+
+	return findListenerConflict(virtualServer, virtualServers, lbc.reservedListenPorts)
+}
+
+// findHostConflict returns an error if an older VirtualServer uses the host of the VirtualServer.
+// The oldest VirtualServer keeps the host, because NGINX would only use one of the servers with the same name.
+func findHostConflict(virtualServer *conf_v1.VirtualServer, virtualServers []*conf_v1.VirtualServer) error {
+	for _, vs := range virtualServers {
+		if vs.Spec.Host == virtualServer.Spec.Host && isOlderVirtualServer(vs, virtualServer) {
+			return fmt.Errorf("host %s is used by VirtualServer %s/%s", vs.Spec.Host, vs.Namespace, vs.Name)
+		}
+	}
+
+	return nil
+}
+
+// findNameConflict returns an error if an older VirtualServer gets the same names of upstreams, variables and zones
+// in its config as the VirtualServer. The names replace dashes with underscores and shorten long namespaces and names,
+// so different VirtualServers, such as cafe-a/tea and cafe/a-tea, can collide. The names of upstreams can also exclude
+// the namespaces, depending on upstreamNamingScheme. The oldest VirtualServer keeps the names.
+func findNameConflict(virtualServer *conf_v1.VirtualServer, virtualServers []*conf_v1.VirtualServer, upstreamNamingScheme configs.UpstreamNamingScheme) error {
+	safeNsName := configs.GetSafeNsNameForVirtualServer(virtualServer)
+	upstreamNamePrefix := upstreamNamingScheme.GetUpstreamNamePrefixForVirtualServer(virtualServer)
+
+	for _, vs := range virtualServers {
+		if vs.Namespace == virtualServer.Namespace && vs.Name == virtualServer.Name {
+			continue
+		}
+
+		if !isOlderVirtualServer(vs, virtualServer) {
+			continue
+		}
+
+		if configs.GetSafeNsNameForVirtualServer(vs) == safeNsName {
+			return fmt.Errorf("the names generated for the config collide with the names of VirtualServer %s/%s", vs.Namespace, vs.Name)
+		}
+
+		if upstreamNamingScheme.GetUpstreamNamePrefixForVirtualServer(vs) == upstreamNamePrefix {
+			return fmt.Errorf("the names of the upstreams collide with the names of the upstreams of VirtualServer %s/%s", vs.Namespace, vs.Name)
+		}
+	}
+
+	return nil
+}
+
+// findRegexHostOverlaps returns a warning for every host of another VirtualServer that the regex host of the VirtualServer
+// matches. Those hosts are not a conflict: NGINX chooses a server with an exact name over a server with a regex name,
+// so the requests for them go to the other VirtualServers.
+func findRegexHostOverlaps(virtualServer *conf_v1.VirtualServer, virtualServers []*conf_v1.VirtualServer) []string {
+	if !validation.IsRegexHost(virtualServer.Spec.Host) {
+		return nil
+	}
+
+	var warnings []string
+
+	for _, vs := range virtualServers {
+		if validation.IsRegexHost(vs.Spec.Host) || !validation.MatchRegexHost(virtualServer.Spec.Host, vs.Spec.Host) {
+			continue
+		}
+
+		warnings = append(warnings, fmt.Sprintf("the requests for host %s are handled by VirtualServer %s/%s, because an exact host takes precedence over a regex host",
+			vs.Spec.Host, vs.Namespace, vs.Name))
+	}
+
+	return warnings
+}
+
+// findListenerConflict returns an error if a port of the listener of the VirtualServer is reserved by the Ingress Controller
+// or is used with a different protocol by the listener of another VirtualServer.
+// In case of a conflict between two VirtualServers, the oldest VirtualServer keeps the port.
+func findListenerConflict(virtualServer *conf_v1.VirtualServer, virtualServers []*conf_v1.VirtualServer, reservedPorts []int) error {
+	if virtualServer.Spec.Listener == nil {
+		return nil
+	}
+
+	var olderVirtualServers []*conf_v1.VirtualServer
+	for _, vs := range virtualServers {
+		if vs.Spec.Listener != nil && isOlderVirtualServer(vs, virtualServer) {
+			olderVirtualServers = append(olderVirtualServers, vs)
+		}
+	}
+
+	sort.Slice(olderVirtualServers, func(i, j int) bool {
+		return isOlderVirtualServer(olderVirtualServers[i], olderVirtualServers[j])
+	})
+
+	ports := listenerPorts{
+		reserved: reservedPorts,
+		http:     make(map[int]string),
+		https:    make(map[int]string),
+	}
+
+	// the older VirtualServers that lost their ports to a conflict don't take the ports from the newer ones
+	for _, vs := range olderVirtualServers {
+		if ports.findConflict(vs.Spec.Listener) == nil {
+			ports.add(vs)
+		}
+	}
+
+	return ports.findConflict(virtualServer.Spec.Listener)
+}
+
+// listenerPorts holds the ports used by the listeners of VirtualServers.
+// The http and https ports map to the namespace/name of the VirtualServer that uses them.
+type listenerPorts struct {
+	reserved []int
+	http     map[int]string
+	https    map[int]string
+}
+
+func (lp *listenerPorts) findConflict(listener *conf_v1.VirtualServerListener) error {
+	for _, port := range lp.reserved {
+		if listener.HTTP == port || listener.HTTPS == port {
+			return fmt.Errorf("listener port %d is reserved by the Ingress Controller", port)
+		}
+	}
+
+	if owner, exists := lp.https[listener.HTTP]; exists && listener.HTTP != 0 {
+		return fmt.Errorf("listener http port %d is used as an https port by VirtualServer %s", listener.HTTP, owner)
+	}
+
+	if owner, exists := lp.http[listener.HTTPS]; exists && listener.HTTPS != 0 {
+		return fmt.Errorf("listener https port %d is used as an http port by VirtualServer %s", listener.HTTPS, owner)
+	}
+
+	return nil
+}
+
+func (lp *listenerPorts) add(vs *conf_v1.VirtualServer) {
+	key := fmt.Sprintf("%s/%s", vs.Namespace, vs.Name)
+
+	if vs.Spec.Listener.HTTP != 0 {
+		lp.http[vs.Spec.Listener.HTTP] = key
+	}
+
+	if vs.Spec.Listener.HTTPS != 0 {
+		lp.https[vs.Spec.Listener.HTTPS] = key
+	}
+}
+
+func isOlderVirtualServer(a *conf_v1.VirtualServer, b *conf_v1.VirtualServer) bool {
+	if !a.CreationTimestamp.Equal(&b.CreationTimestamp) {
+		return a.CreationTimestamp.Before(&b.CreationTimestamp)
+	}
+
+	return fmt.Sprintf("%s/%s", a.Namespace, a.Name) < fmt.Sprintf("%s/%s", b.Namespace, b.Name)
+}
+
 func findVirtualServersForVirtualServerRoute(virtualServers []*conf_v1.VirtualServer, virtualServerRoute *conf_v1.VirtualServerRoute) []*conf_v1.VirtualServer {
 	key := fmt.Sprintf("%s/%s", virtualServerRoute.Namespace, virtualServerRoute.Name)
 	return findVirtualServersForVirtualServerRouteKey(virtualServers, key)
@@ -1429,7 +2354,82 @@ func (lbc *LoadBalancerController) getAndValidateSecret(secretKey string) (*api_
 	return secret, nil
 }
 
+func (lbc *LoadBalancerController) getAndValidateJWKSecret(secretKey string) (*api_v1.Secret, error) {
+	secretObject, secretExists, err := lbc.secretLister.GetByKey(secretKey)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving secret %v", secretKey)
+	}
+	if !secretExists {
+		return nil, fmt.Errorf("secret %v not found", secretKey)
+	}
+	secret := secretObject.(*api_v1.Secret)
+
+	err = ValidateJWKSecret(secret)
+	if err != nil {
+		return nil, fmt.Errorf("error validating secret %v", secretKey)
+	}
+	return secret, nil
+}
+
+func (lbc *LoadBalancerController) getAndValidateCASecret(secretKey string) (*api_v1.Secret, error) {
+	secretObject, secretExists, err := lbc.secretLister.GetByKey(secretKey)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving secret %v", secretKey)
+	}
+	if !secretExists {
+		return nil, fmt.Errorf("secret %v not found", secretKey)
+	}
+	secret := secretObject.(*api_v1.Secret)
+
+	err = ValidateCASecret(secret)
+	if err != nil {
+		return nil, fmt.Errorf("error validating secret %v", secretKey)
+	}
+	return secret, nil
+}
+
+func (lbc *LoadBalancerController) getAndValidateHtpasswdSecret(secretKey string) (*api_v1.Secret, error) {
+	secretObject, secretExists, err := lbc.secretLister.GetByKey(secretKey)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving secret %v", secretKey)
+	}
+	if !secretExists {
+		return nil, fmt.Errorf("secret %v not found", secretKey)
+	}
+	secret := secretObject.(*api_v1.Secret)
+
+	err = ValidateHtpasswdSecret(secret)
+	if err != nil {
+		return nil, fmt.Errorf("error validating secret %v", secretKey)
+	}
+	return secret, nil
+}
+
+func (lbc *LoadBalancerController) getAndValidateOIDCSecret(secretKey string) (*api_v1.Secret, error) {
+	secretObject, secretExists, err := lbc.secretLister.GetByKey(secretKey)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving secret %v", secretKey)
+	}
+	if !secretExists {
+		return nil, fmt.Errorf("secret %v not found", secretKey)
+	}
+	secret := secretObject.(*api_v1.Secret)
+
+	err = ValidateOIDCSecret(secret)
+	if err != nil {
+		return nil, fmt.Errorf("error validating secret %v", secretKey)
+	}
+	return secret, nil
+}
+
 func (lbc *LoadBalancerController) createIngress(ing *extensions.Ingress) (*configs.IngressEx, error) {
+	if lbc.snippetsValidator != nil {
+		err := lbc.snippetsValidator.ValidateIngressSnippets(ing.Annotations)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	ingEx := &configs.IngressEx{
 		Ingress: ing,
 	}
@@ -1574,110 +2574,374 @@ func (lbc *LoadBalancerController) createVirtualServer(virtualServer *conf_v1.Vi
 		VirtualServer: virtualServer,
 	}
 
-	if virtualServer.Spec.TLS != nil && virtualServer.Spec.TLS.Secret != "" {
-		secretKey := virtualServer.Namespace + "/" + virtualServer.Spec.TLS.Secret
-		secret, err := lbc.getAndValidateSecret(secretKey)
+	if virtualServer.Spec.TLS != nil && virtualServer.Spec.TLS.Secret != "" {
+		secretKey := virtualServer.Namespace + "/" + virtualServer.Spec.TLS.Secret
+		secret, err := lbc.getAndValidateSecret(secretKey)
+		if err != nil {
+			glog.Warningf("Error trying to get the secret %v for VirtualServer %v: %v", secretKey, virtualServer.Name, err)
+		} else {
+			virtualServerEx.TLSSecret = secret
+		}
+	}
+
+	endpoints := make(map[string][]string)
+	externalNameSvcs := make(map[string]bool)
+	drainedEndpoints := make(map[string]bool)
+	terminatingEndpoints := make(map[string]bool)
+	remoteZoneEndpoints := make(map[string]bool)
+	healthCheckPorts := make(map[string]int32)
+
+	for _, u := range virtualServer.Spec.Upstreams {
+		endpointsKey := configs.GenerateEndpointsKey(virtualServer.Namespace, u.Service, u.Subselector, u.Port, u.PortName)
+
+		var endps []string
+		var err error
+
+		if len(u.Subselector) > 0 {
+			endps, err = lbc.getEndpointsForSubselector(virtualServer.Namespace, u)
+		} else {
+			var external bool
+			endps, external, err = lbc.getEndpointsForUpstream(virtualServer.Namespace, u)
+
+			if err == nil && external {
+				externalNameSvcs[configs.GenerateExternalNameSvcKey(virtualServer.Namespace, u.Service)] = true
+			}
+
+			if !external {
+				endps = lbc.addTerminatingEndpoints(terminatingEndpoints, virtualServer.Namespace, u, endps)
+			}
+		}
+
+		if err != nil {
+			glog.Warningf("Error getting Endpoints for Upstream %v: %v", u.Name, err)
+		}
+
+		endpoints[endpointsKey] = endps
+		lbc.addDrainedEndpoints(drainedEndpoints, virtualServer.Namespace, endps)
+		lbc.addRemoteZoneEndpoints(remoteZoneEndpoints, virtualServer.Namespace, u, endps)
+		lbc.addHealthCheckPort(healthCheckPorts, virtualServer.Namespace, u)
+	}
+
+	var virtualServerRoutes []*conf_v1.VirtualServerRoute
+	var virtualServerRouteErrors []virtualServerRouteError
+
+	// gather all referenced VirtualServerRoutes
+	for _, r := range virtualServer.Spec.Routes {
+		if r.Route == "" {
+			continue
+		}
+
+		vsrKey := r.Route
+
+		// if route is defined without a namespace, use the namespace of VirtualServer.
+		if !strings.Contains(r.Route, "/") {
+			vsrKey = fmt.Sprintf("%s/%s", virtualServer.Namespace, r.Route)
+		}
+
+		obj, exists, err := lbc.virtualServerRouteLister.GetByKey(vsrKey)
+		if err != nil {
+			glog.Warningf("Failed to get VirtualServerRoute %s for VirtualServer %s/%s: %v", vsrKey, virtualServer.Namespace, virtualServer.Name, err)
+			virtualServerRouteErrors = append(virtualServerRouteErrors, newVirtualServerRouteErrorFromNsName(vsrKey, err))
+			continue
+		}
+
+		if !exists {
+			glog.Warningf("VirtualServer %s/%s references VirtualServerRoute %s that doesn't exist", virtualServer.Name, virtualServer.Namespace, vsrKey)
+			virtualServerRouteErrors = append(virtualServerRouteErrors, newVirtualServerRouteErrorFromNsName(vsrKey, errors.New("VirtualServerRoute doesn't exist")))
+			continue
+		}
+
+		vsr := obj.(*conf_v1.VirtualServerRoute)
+
+		if vsr.DeletionTimestamp != nil {
+			glog.Warningf("VirtualServer %s/%s references VirtualServerRoute %s that is being deleted", virtualServer.Name, virtualServer.Namespace, vsrKey)
+			virtualServerRouteErrors = append(virtualServerRouteErrors, newVirtualServerRouteErrorFromNsName(vsrKey, errors.New("VirtualServerRoute is being deleted")))
+			continue
+		}
+
+		_, err = validation.ValidateVirtualServerRouteForVirtualServerWithWarnings(vsr, virtualServer.Spec.Host, r.Path, lbc.isNginxPlus, lbc.emulateQueue, lbc.allowedVariables, lbc.validationStrictness)
+		if err != nil {
+			glog.Warningf("VirtualServer %s/%s references invalid VirtualServerRoute %s: %v", virtualServer.Name, virtualServer.Namespace, vsrKey, err)
+			virtualServerRouteErrors = append(virtualServerRouteErrors, newVirtualServerRouteErrorFromVSR(vsr, err))
+			continue
+		}
+
+		virtualServerRoutes = append(virtualServerRoutes, vsr)
+
+		for _, u := range vsr.Spec.Upstreams {
+			endpointsKey := configs.GenerateEndpointsKey(vsr.Namespace, u.Service, u.Subselector, u.Port, u.PortName)
+
+			var endps []string
+			var err error
+			if len(u.Subselector) > 0 {
+				endps, err = lbc.getEndpointsForSubselector(vsr.Namespace, u)
+			} else {
+				var external bool
+				endps, external, err = lbc.getEndpointsForUpstream(vsr.Namespace, u)
+
+				if err == nil && external {
+					externalNameSvcs[configs.GenerateExternalNameSvcKey(vsr.Namespace, u.Service)] = true
+				}
+
+				if !external {
+					endps = lbc.addTerminatingEndpoints(terminatingEndpoints, vsr.Namespace, u, endps)
+				}
+			}
+			if err != nil {
+				glog.Warningf("Error getting Endpoints for Upstream %v: %v", u.Name, err)
+			}
+			endpoints[endpointsKey] = endps
+			lbc.addDrainedEndpoints(drainedEndpoints, vsr.Namespace, endps)
+			lbc.addRemoteZoneEndpoints(remoteZoneEndpoints, vsr.Namespace, u, endps)
+			lbc.addHealthCheckPort(healthCheckPorts, vsr.Namespace, u)
+		}
+	}
+
+	virtualServerEx.Endpoints = endpoints
+	virtualServerEx.VirtualServerRoutes = virtualServerRoutes
+	virtualServerEx.ExternalNameSvcs = externalNameSvcs
+	virtualServerEx.DrainedEndpoints = drainedEndpoints
+	virtualServerEx.TerminatingEndpoints = terminatingEndpoints
+	virtualServerEx.RemoteZoneEndpoints = remoteZoneEndpoints
+	virtualServerEx.HealthCheckPorts = healthCheckPorts
+	virtualServerEx.Policies = lbc.getPoliciesForVirtualServer(virtualServer, virtualServerRoutes)
+	virtualServerEx.JWTKeys = lbc.getJWTKeysForPolicies(virtualServerEx.Policies)
+	virtualServerEx.OIDCSecrets = lbc.getOIDCSecretsForPolicies(virtualServerEx.Policies)
+	virtualServerEx.EgressTLSSecrets, virtualServerEx.TrustedCASecrets = lbc.getEgressMTLSSecretsForPolicies(virtualServerEx.Policies)
+	lbc.addHealthCheckCASecrets(virtualServerEx.TrustedCASecrets, virtualServer.Namespace, virtualServer.Spec.Upstreams)
+	for _, vsr := range virtualServerRoutes {
+		lbc.addHealthCheckCASecrets(virtualServerEx.TrustedCASecrets, vsr.Namespace, vsr.Spec.Upstreams)
+	}
+	virtualServerEx.HtpasswdSecrets = lbc.getHtpasswdSecretsForPolicies(virtualServerEx.Policies)
+	virtualServerEx.ConfigMaps = lbc.getConfigMapsForVirtualServer(virtualServer, virtualServerRoutes)
+
+	return &virtualServerEx, virtualServerRouteErrors
+}
+
+// getConfigMapsForVirtualServer returns the existing ConfigMaps with the content served by the actions of the VirtualServer
+// and its VirtualServerRoutes. The ConfigMaps are keyed by their namespace/name.
+func (lbc *LoadBalancerController) getConfigMapsForVirtualServer(virtualServer *conf_v1.VirtualServer,
+	virtualServerRoutes []*conf_v1.VirtualServerRoute) map[string]*api_v1.ConfigMap {
+	configMaps := make(map[string]*api_v1.ConfigMap)
+
+	addConfigMaps := func(namespace string, routes []conf_v1.Route) {
+		for _, name := range getServedConfigMapNames(routes) {
+			key := namespace + "/" + name
+			if _, exists := configMaps[key]; exists {
+				continue
+			}
+
+			obj, exists, err := lbc.contentConfigMapLister.GetByKey(key)
+			if err != nil {
+				glog.Warningf("Error trying to get the ConfigMap %v for VirtualServer %v/%v: %v", key, virtualServer.Namespace, virtualServer.Name, err)
+				continue
+			}
+			if !exists {
+				continue
+			}
+
+			configMaps[key] = obj.(*api_v1.ConfigMap)
+		}
+	}
+
+	addConfigMaps(virtualServer.Namespace, virtualServer.Spec.Routes)
+	for _, vsr := range virtualServerRoutes {
+		addConfigMaps(vsr.Namespace, vsr.Spec.Subroutes)
+	}
+
+	return configMaps
+}
+
+// getJWTKeysForPolicies returns the valid JWK Secrets referenced by the jwt policies.
+// The Secrets are keyed by their namespace/name.
+func (lbc *LoadBalancerController) getJWTKeysForPolicies(policies map[string]*conf_v1.Policy) map[string]*api_v1.Secret {
+	jwtKeys := make(map[string]*api_v1.Secret)
+
+	for _, pol := range policies {
+		if pol.Spec.JWTAuth == nil {
+			continue
+		}
+
+		secretKey := pol.Namespace + "/" + pol.Spec.JWTAuth.Secret
+		if _, exists := jwtKeys[secretKey]; exists {
+			continue
+		}
+
+		secret, err := lbc.getAndValidateJWKSecret(secretKey)
+		if err != nil {
+			glog.Warningf("Error trying to get the JWK secret %v for Policy %v/%v: %v", secretKey, pol.Namespace, pol.Name, err)
+			continue
+		}
+
+		jwtKeys[secretKey] = secret
+	}
+
+	return jwtKeys
+}
+
+// getOIDCSecretsForPolicies returns the valid OIDC client Secrets referenced by the oidc policies.
+// The Secrets are keyed by their namespace/name.
+func (lbc *LoadBalancerController) getOIDCSecretsForPolicies(policies map[string]*conf_v1.Policy) map[string]*api_v1.Secret {
+	oidcSecrets := make(map[string]*api_v1.Secret)
+
+	for _, pol := range policies {
+		if pol.Spec.OIDC == nil {
+			continue
+		}
+
+		secretKey := pol.Namespace + "/" + pol.Spec.OIDC.ClientSecret
+		if _, exists := oidcSecrets[secretKey]; exists {
+			continue
+		}
+
+		secret, err := lbc.getAndValidateOIDCSecret(secretKey)
+		if err != nil {
+			glog.Warningf("Error trying to get the OIDC secret %v for Policy %v/%v: %v", secretKey, pol.Namespace, pol.Name, err)
+			continue
+		}
+
+		oidcSecrets[secretKey] = secret
+	}
+
+	return oidcSecrets
+}
+
+// getHtpasswdSecretsForPolicies returns the valid htpasswd Secrets referenced by the basicAuth policies.
+// The Secrets are keyed by their namespace/name.
+func (lbc *LoadBalancerController) getHtpasswdSecretsForPolicies(policies map[string]*conf_v1.Policy) map[string]*api_v1.Secret {
+	htpasswdSecrets := make(map[string]*api_v1.Secret)
+
+	for _, pol := range policies {
+		if pol.Spec.BasicAuth == nil {
+			continue
+		}
+
+		secretKey := pol.Namespace + "/" + pol.Spec.BasicAuth.Secret
+		if _, exists := htpasswdSecrets[secretKey]; exists {
+			continue
+		}
+
+		secret, err := lbc.getAndValidateHtpasswdSecret(secretKey)
 		if err != nil {
-			glog.Warningf("Error trying to get the secret %v for VirtualServer %v: %v", secretKey, virtualServer.Name, err)
-		} else {
-			virtualServerEx.TLSSecret = secret
+			glog.Warningf("Error trying to get the htpasswd secret %v for Policy %v/%v: %v", secretKey, pol.Namespace, pol.Name, err)
+			continue
 		}
+
+		htpasswdSecrets[secretKey] = secret
 	}
 
-	endpoints := make(map[string][]string)
-	externalNameSvcs := make(map[string]bool)
+	return htpasswdSecrets
+}
 
-	for _, u := range virtualServer.Spec.Upstreams {
-		endpointsKey := configs.GenerateEndpointsKey(virtualServer.Namespace, u.Service, u.Subselector, u.Port)
+// getEgressMTLSSecretsForPolicies returns the valid TLS and CA Secrets referenced by the egressMTLS policies.
+// The Secrets are keyed by their namespace/name.
+func (lbc *LoadBalancerController) getEgressMTLSSecretsForPolicies(policies map[string]*conf_v1.Policy) (tlsSecrets map[string]*api_v1.Secret, caSecrets map[string]*api_v1.Secret) {
+	tlsSecrets = make(map[string]*api_v1.Secret)
+	caSecrets = make(map[string]*api_v1.Secret)
 
-		var endps []string
-		var err error
+	for _, pol := range policies {
+		if pol.Spec.EgressMTLS == nil {
+			continue
+		}
 
-		if len(u.Subselector) > 0 {
-			endps, err = lbc.getEndpointsForSubselector(virtualServer.Namespace, u)
-		} else {
-			var external bool
-			endps, external, err = lbc.getEndpointsForUpstream(virtualServer.Namespace, u)
+		if pol.Spec.EgressMTLS.TLSSecret != "" {
+			secretKey := pol.Namespace + "/" + pol.Spec.EgressMTLS.TLSSecret
+			if _, exists := tlsSecrets[secretKey]; !exists {
+				secret, err := lbc.getAndValidateSecret(secretKey)
+				if err != nil {
+					glog.Warningf("Error trying to get the TLS secret %v for Policy %v/%v: %v", secretKey, pol.Namespace, pol.Name, err)
+				} else {
+					tlsSecrets[secretKey] = secret
+				}
+			}
+		}
 
-			if err == nil && external && lbc.isNginxPlus {
-				externalNameSvcs[configs.GenerateExternalNameSvcKey(virtualServer.Namespace, u.Service)] = true
+		if pol.Spec.EgressMTLS.TrustedCertSecret != "" {
+			secretKey := pol.Namespace + "/" + pol.Spec.EgressMTLS.TrustedCertSecret
+			if _, exists := caSecrets[secretKey]; !exists {
+				secret, err := lbc.getAndValidateCASecret(secretKey)
+				if err != nil {
+					glog.Warningf("Error trying to get the CA secret %v for Policy %v/%v: %v", secretKey, pol.Namespace, pol.Name, err)
+				} else {
+					caSecrets[secretKey] = secret
+				}
 			}
 		}
+	}
+
+	return tlsSecrets, caSecrets
+}
+
+// addHealthCheckCASecrets adds the valid CA Secrets referenced by the health checks of the upstreams to the caSecrets.
+// The Secrets are keyed by their namespace/name.
+func (lbc *LoadBalancerController) addHealthCheckCASecrets(caSecrets map[string]*api_v1.Secret, namespace string, upstreams []conf_v1.Upstream) {
+	for _, secretKey := range configs.GetHealthCheckCASecretKeys(namespace, upstreams) {
+		if _, exists := caSecrets[secretKey]; exists {
+			continue
+		}
 
+		secret, err := lbc.getAndValidateCASecret(secretKey)
 		if err != nil {
-			glog.Warningf("Error getting Endpoints for Upstream %v: %v", u.Name, err)
+			glog.Warningf("Error trying to get the CA secret %v for the health checks of an upstream: %v", secretKey, err)
+			continue
 		}
 
-		endpoints[endpointsKey] = endps
+		caSecrets[secretKey] = secret
 	}
+}
 
-	var virtualServerRoutes []*conf_v1.VirtualServerRoute
-	var virtualServerRouteErrors []virtualServerRouteError
+// getPoliciesForVirtualServer returns the valid policies referenced by the VirtualServer and its VirtualServerRoutes.
+// The policies are keyed by their namespace/name.
+func (lbc *LoadBalancerController) getPoliciesForVirtualServer(virtualServer *conf_v1.VirtualServer, virtualServerRoutes []*conf_v1.VirtualServerRoute) map[string]*conf_v1.Policy {
+	policies := make(map[string]*conf_v1.Policy)
 
-	// gather all referenced VirtualServerRoutes
+	lbc.addPolicies(policies, virtualServer.Spec.Policies, virtualServer.Namespace)
 	for _, r := range virtualServer.Spec.Routes {
-		if r.Route == "" {
-			continue
+		lbc.addPolicies(policies, r.Policies, virtualServer.Namespace)
+	}
+
+	for _, vsr := range virtualServerRoutes {
+		for _, r := range vsr.Spec.Subroutes {
+			lbc.addPolicies(policies, r.Policies, vsr.Namespace)
 		}
+	}
 
-		vsrKey := r.Route
+	return policies
+}
 
-		// if route is defined without a namespace, use the namespace of VirtualServer.
-		if !strings.Contains(r.Route, "/") {
-			vsrKey = fmt.Sprintf("%s/%s", virtualServer.Namespace, r.Route)
+func (lbc *LoadBalancerController) addPolicies(policies map[string]*conf_v1.Policy, policyRefs []conf_v1.PolicyReference, ownerNamespace string) {
+	for _, p := range policyRefs {
+		polNamespace := p.Namespace
+		if polNamespace == "" {
+			polNamespace = ownerNamespace
 		}
 
-		obj, exists, err := lbc.virtualServerRouteLister.GetByKey(vsrKey)
+		policyKey := fmt.Sprintf("%s/%s", polNamespace, p.Name)
+		if _, exists := policies[policyKey]; exists {
+			continue
+		}
+
+		policyObj, exists, err := lbc.policyLister.GetByKey(policyKey)
 		if err != nil {
-			glog.Warningf("Failed to get VirtualServerRoute %s for VirtualServer %s/%s: %v", vsrKey, virtualServer.Namespace, virtualServer.Name, err)
-			virtualServerRouteErrors = append(virtualServerRouteErrors, newVirtualServerRouteErrorFromNsName(vsrKey, err))
+			glog.Warningf("Failed to get policy %s: %v", policyKey, err)
 			continue
 		}
 
 		if !exists {
-			glog.Warningf("VirtualServer %s/%s references VirtualServerRoute %s that doesn't exist", virtualServer.Name, virtualServer.Namespace, vsrKey)
-			virtualServerRouteErrors = append(virtualServerRouteErrors, newVirtualServerRouteErrorFromNsName(vsrKey, errors.New("VirtualServerRoute doesn't exist")))
+			glog.Warningf("Policy %s doesn't exist", policyKey)
 			continue
 		}
 
-		vsr := obj.(*conf_v1.VirtualServerRoute)
+		policy := policyObj.(*conf_v1.Policy)
 
-		err = validation.ValidateVirtualServerRouteForVirtualServer(vsr, virtualServer.Spec.Host, r.Path, lbc.isNginxPlus)
+		err = validation.ValidatePolicy(policy, lbc.isNginxPlus, lbc.enableOIDC)
 		if err != nil {
-			glog.Warningf("VirtualServer %s/%s references invalid VirtualServerRoute %s: %v", virtualServer.Name, virtualServer.Namespace, vsrKey, err)
-			virtualServerRouteErrors = append(virtualServerRouteErrors, newVirtualServerRouteErrorFromVSR(vsr, err))
+			glog.Warningf("Policy %s is invalid: %v", policyKey, err)
 			continue
 		}
 
-		virtualServerRoutes = append(virtualServerRoutes, vsr)
-
-		for _, u := range vsr.Spec.Upstreams {
-			endpointsKey := configs.GenerateEndpointsKey(vsr.Namespace, u.Service, u.Subselector, u.Port)
-
-			var endps []string
-			var err error
-			if len(u.Subselector) > 0 {
-				endps, err = lbc.getEndpointsForSubselector(vsr.Namespace, u)
-			} else {
-				var external bool
-				endps, external, err = lbc.getEndpointsForUpstream(vsr.Namespace, u)
-
-				if err == nil && external && lbc.isNginxPlus {
-					externalNameSvcs[configs.GenerateExternalNameSvcKey(vsr.Namespace, u.Service)] = true
-				}
-			}
-			if err != nil {
-				glog.Warningf("Error getting Endpoints for Upstream %v: %v", u.Name, err)
-			}
-			endpoints[endpointsKey] = endps
-		}
+		policies[policyKey] = policy
 	}
-
-	virtualServerEx.Endpoints = endpoints
-	virtualServerEx.VirtualServerRoutes = virtualServerRoutes
-	virtualServerEx.ExternalNameSvcs = externalNameSvcs
-
-	return &virtualServerEx, virtualServerRouteErrors
 }
 
 func (lbc *LoadBalancerController) getEndpointsForUpstream(namespace string, upstream conf_v1.Upstream) (endps []string, isExternal bool, err error) {
@@ -1686,9 +2950,36 @@ func (lbc *LoadBalancerController) getEndpointsForUpstream(namespace string, ups
 		return nil, false, fmt.Errorf("Error getting service %v: %v", upstream.Service, err)
 	}
 
+	port, err := getServicePortForUpstream(upstream, svc)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if upstream.UseClusterIP {
+		endp, err := getClusterIPEndpoint(svc, port)
+		if err != nil {
+			return nil, false, err
+		}
+		return []string{endp}, false, nil
+	}
+
 	backend := &extensions.IngressBackend{
 		ServiceName: upstream.Service,
-		ServicePort: intstr.FromInt(int(upstream.Port)),
+		ServicePort: intstr.FromInt(int(port)),
+	}
+
+	// unlike Ingress resources, the upstreams of VirtualServers support ExternalName services in NGINX too
+	if svc.Spec.Type == api_v1.ServiceTypeExternalName {
+		return lbc.getExternalEndpointsForIngressBackend(backend, svc), true, nil
+	}
+
+	if upstream.IncludeNotReadyEndpoints {
+		svcEps, err := lbc.getServiceEndpoints(svc)
+		if err != nil {
+			return nil, false, fmt.Errorf("Error retrieving endpoints for the service %v: %v", upstream.Service, err)
+		}
+		endps, err = lbc.getEndpointsForPort(addNotReadyAddresses(svcEps), backend.ServicePort, svc)
+		return endps, false, err
 	}
 
 	endps, isExternal, err = lbc.getEndpointsForIngressBackend(backend, svc)
@@ -1699,48 +2990,191 @@ func (lbc *LoadBalancerController) getEndpointsForUpstream(namespace string, ups
 	return endps, isExternal, err
 }
 
+// getClusterIPEndpoint returns the endpoint with the cluster IP of the service and the port, so that kube-proxy
+// balances the connections to the endpoint across the pods of the service.
+func getClusterIPEndpoint(svc *api_v1.Service, port int32) (string, error) {
+	if svc.Spec.ClusterIP == "" || svc.Spec.ClusterIP == api_v1.ClusterIPNone {
+		return "", fmt.Errorf("Service %s has no cluster IP", svc.Name)
+	}
+	return net.JoinHostPort(svc.Spec.ClusterIP, strconv.Itoa(int(port))), nil
+}
+
 func (lbc *LoadBalancerController) getEndpointsForSubselector(namespace string, upstream conf_v1.Upstream) (endps []string, err error) {
 	svc, err := lbc.getServiceForUpstream(upstream, namespace)
 	if err != nil {
 		return nil, fmt.Errorf("Error getting service %v: %v", upstream.Service, err)
 	}
 
-	var targetPort int32
+	targetPort, err := lbc.getTargetPortForUpstream(upstream, svc)
+	if err != nil {
+		return nil, err
+	}
+
+	endps, err = lbc.getEndpointsForServiceWithSubselector(targetPort, upstream.Subselector, svc, upstream.IncludeNotReadyEndpoints)
+	if err != nil {
+		return nil, fmt.Errorf("Error retrieving endpoints for the service %v: %v", upstream.Service, err)
+	}
+
+	return endps, err
+}
+
+func (lbc *LoadBalancerController) getTargetPortForUpstream(upstream conf_v1.Upstream, svc *api_v1.Service) (int32, error) {
+	svcPort, err := getServicePortForUpstream(upstream, svc)
+	if err != nil {
+		return 0, err
+	}
 
 	for _, port := range svc.Spec.Ports {
-		if port.Port == int32(upstream.Port) {
-			targetPort, err = lbc.getTargetPort(&port, svc)
+		if port.Port == svcPort {
+			targetPort, err := lbc.getTargetPort(&port, svc)
 			if err != nil {
-				return nil, fmt.Errorf("Error determining target port for port %v in service %v: %v", upstream.Port, svc.Name, err)
+				return 0, fmt.Errorf("Error determining target port for port %v in service %v: %v", svcPort, svc.Name, err)
 			}
-			break
+			return targetPort, nil
 		}
 	}
 
-	if targetPort == 0 {
-		return nil, fmt.Errorf("No port %v in service %s", upstream.Port, svc.Name)
+	return 0, fmt.Errorf("No port %v in service %s", svcPort, svc.Name)
+}
+
+// addTerminatingEndpoints adds to the endpoints of the upstream the terminating endpoints of its service, whose
+// drain delay hasn't ended yet. The terminating endpoints are also added to terminatingEndpoints.
+func (lbc *LoadBalancerController) addTerminatingEndpoints(terminatingEndpoints map[string]bool, namespace string, upstream conf_v1.Upstream,
+	endps []string) []string {
+	if lbc.endpointsDrainer == nil || upstream.UseClusterIP {
+		return endps
 	}
 
-	endps, err = lbc.getEndpointsForServiceWithSubselector(targetPort, upstream.Subselector, svc)
+	svc, err := lbc.getServiceForUpstream(upstream, namespace)
 	if err != nil {
-		return nil, fmt.Errorf("Error retrieving endpoints for the service %v: %v", upstream.Service, err)
+		return endps
 	}
 
-	return endps, err
+	targetPort, err := lbc.getTargetPortForUpstream(upstream, svc)
+	if err != nil {
+		return endps
+	}
+
+	current := make(map[string]bool)
+	for _, endp := range endps {
+		current[endp] = true
+	}
+
+	for _, endp := range lbc.endpointsDrainer.GetTerminatingEndpoints(svc.Namespace+"/"+svc.Name, targetPort) {
+		if current[endp] {
+			continue
+		}
+		endps = append(endps, endp)
+		terminatingEndpoints[endp] = true
+	}
+
+	return endps
+}
+
+// addRemoteZoneEndpoints adds to remoteZoneEndpoints the endpoints of the upstream that are in other zones than
+// the zone of the Ingress Controller, if the upstream prefers the endpoints in the same zone. The zones of the endpoints
+// come from the EndpointSlices, so the endpoints are only added if the EndpointSlices are used.
+func (lbc *LoadBalancerController) addRemoteZoneEndpoints(remoteZoneEndpoints map[string]bool, namespace string, upstream conf_v1.Upstream,
+	endps []string) {
+	if upstream.TopologyPolicy != "prefer-zone" || !lbc.useEndpointSlices || lbc.topologyZone == "" || len(endps) == 0 {
+		return
+	}
+
+	svc, err := lbc.getServiceForUpstream(upstream, namespace)
+	if err != nil {
+		return
+	}
+
+	zones, err := lbc.endpointSliceLister.GetEndpointZones(svc.Namespace + "/" + svc.Name)
+	if err != nil {
+		glog.V(3).Infof("Error getting the zones of the endpoints of service %v/%v: %v", svc.Namespace, svc.Name, err)
+		return
+	}
+
+	for endp := range getRemoteZoneEndpoints(zones, lbc.topologyZone, endps) {
+		remoteZoneEndpoints[endp] = true
+	}
+}
+
+// getRemoteZoneEndpoints returns the endpoints whose zones are known and differ from the zone.
+func getRemoteZoneEndpoints(zones map[string]string, zone string, endps []string) map[string]bool {
+	remoteEndps := make(map[string]bool)
+
+	for _, endp := range endps {
+		ip, _, err := net.SplitHostPort(endp)
+		if err != nil {
+			continue
+		}
+		if endpZone, exists := zones[ip]; exists && endpZone != zone {
+			remoteEndps[endp] = true
+		}
+	}
+
+	return remoteEndps
+}
+
+// addHealthCheckPort adds to healthCheckPorts the target port of the port of the service that the health check
+// of the upstream references by name, such as an admin port of the pods.
+func (lbc *LoadBalancerController) addHealthCheckPort(healthCheckPorts map[string]int32, namespace string, upstream conf_v1.Upstream) {
+	if upstream.HealthCheck == nil || upstream.HealthCheck.PortName == "" {
+		return
+	}
+
+	svc, err := lbc.getServiceForUpstream(upstream, namespace)
+	if err != nil {
+		return
+	}
+
+	for _, port := range svc.Spec.Ports {
+		if port.Name != upstream.HealthCheck.PortName {
+			continue
+		}
+
+		targetPort, err := lbc.getTargetPort(&port, svc)
+		if err != nil {
+			glog.Warningf("Error determining the target port of port %v of service %v for the health checks of upstream %v: %v",
+				port.Name, svc.Name, upstream.Name, err)
+			return
+		}
+
+		healthCheckPorts[configs.GenerateHealthCheckPortKey(namespace, upstream.Service, port.Name)] = targetPort
+		return
+	}
+}
+
+// getServicePortForUpstream returns the port of the service of the upstream. If the upstream references the port by
+// its name, the name is resolved against the named ports of the service.
+func getServicePortForUpstream(upstream conf_v1.Upstream, svc *api_v1.Service) (int32, error) {
+	if upstream.PortName == "" {
+		return int32(upstream.Port), nil
+	}
+
+	for _, port := range svc.Spec.Ports {
+		if port.Name == upstream.PortName {
+			return port.Port, nil
+		}
+	}
+
+	return 0, fmt.Errorf("No port named %v in service %s", upstream.PortName, svc.Name)
 }
 
-func (lbc *LoadBalancerController) getEndpointsForServiceWithSubselector(targetPort int32, subselector map[string]string, svc *api_v1.Service) (endps []string, err error) {
+func (lbc *LoadBalancerController) getEndpointsForServiceWithSubselector(targetPort int32, subselector map[string]string, svc *api_v1.Service,
+	includeNotReady bool) (endps []string, err error) {
 	pods, err := lbc.podLister.ListByNamespace(svc.Namespace, labels.Merge(svc.Spec.Selector, subselector).AsSelector())
 	if err != nil {
 		return nil, fmt.Errorf("Error getting pods in namespace %v that match the selector %v: %v", svc.Namespace, labels.Merge(svc.Spec.Selector, subselector), err)
 	}
 
-	svcEps, err := lbc.endpointLister.GetServiceEndpoints(svc)
+	svcEps, err := lbc.getServiceEndpoints(svc)
 	if err != nil {
 		glog.V(3).Infof("Error getting endpoints for service %s from the cache: %v", svc.Name, err)
 		return nil, err
 	}
 
+	if includeNotReady {
+		svcEps = addNotReadyAddresses(svcEps)
+	}
+
 	endps = getEndpointsBySubselectedPods(targetPort, pods, svcEps)
 	return endps, nil
 }
@@ -1753,8 +3187,8 @@ func getEndpointsBySubselectedPods(targetPort int32, pods []*api_v1.Pod, svcEps
 					continue
 				}
 				for _, address := range subset.Addresses {
-					if address.IP == pod.Status.PodIP {
-						podEndpoint := fmt.Sprintf("%v:%v", pod.Status.PodIP, targetPort)
+					if isPodIP(pod, address.IP) {
+						podEndpoint := net.JoinHostPort(address.IP, strconv.Itoa(int(targetPort)))
 						endps = append(endps, podEndpoint)
 					}
 				}
@@ -1764,6 +3198,114 @@ func getEndpointsBySubselectedPods(targetPort int32, pods []*api_v1.Pod, svcEps
 	return endps
 }
 
+// addNotReadyAddresses returns a copy of the Endpoints whose subsets include the not ready addresses in the addresses,
+// so that the endpoints that are not ready are added to the upstreams.
+func addNotReadyAddresses(endps api_v1.Endpoints) api_v1.Endpoints {
+	result := *endps.DeepCopy()
+	for i := range result.Subsets {
+		result.Subsets[i].Addresses = append(result.Subsets[i].Addresses, result.Subsets[i].NotReadyAddresses...)
+		result.Subsets[i].NotReadyAddresses = nil
+	}
+	return result
+}
+
+// getPodIPs returns the IP addresses of the pod. In a dual-stack cluster, a pod has an IPv4 and an IPv6 address.
+func getPodIPs(pod *api_v1.Pod) []string {
+	var ips []string
+	if pod.Status.PodIP != "" {
+		ips = append(ips, pod.Status.PodIP)
+	}
+	for _, podIP := range pod.Status.PodIPs {
+		if podIP.IP != "" && podIP.IP != pod.Status.PodIP {
+			ips = append(ips, podIP.IP)
+		}
+	}
+	return ips
+}
+
+func isPodIP(pod *api_v1.Pod, ip string) bool {
+	for _, podIP := range getPodIPs(pod) {
+		if podIP == ip {
+			return true
+		}
+	}
+	return false
+}
+
+// addDrainedEndpoints adds to drainedEndpoints the endpoints that belong to the pods annotated with nginx.org/drain: "true".
+func (lbc *LoadBalancerController) addDrainedEndpoints(drainedEndpoints map[string]bool, namespace string, endps []string) {
+	if len(endps) == 0 {
+		return
+	}
+
+	pods, err := lbc.podLister.ListByNamespace(namespace, labels.Everything())
+	if err != nil {
+		glog.V(3).Infof("Error getting pods in namespace %v: %v", namespace, err)
+		return
+	}
+
+	for endp := range getDrainedEndpoints(pods, endps) {
+		drainedEndpoints[endp] = true
+	}
+}
+
+// getDrainedEndpoints returns the endpoints that belong to the drained pods.
+func getDrainedEndpoints(pods []*api_v1.Pod, endps []string) map[string]bool {
+	drainedPodIPs := make(map[string]bool)
+	for _, pod := range pods {
+		if !isPodDrained(pod) {
+			continue
+		}
+		for _, ip := range getPodIPs(pod) {
+			drainedPodIPs[ip] = true
+		}
+	}
+
+	drainedEndps := make(map[string]bool)
+	if len(drainedPodIPs) == 0 {
+		return drainedEndps
+	}
+
+	for _, endp := range endps {
+		ip, _, err := net.SplitHostPort(endp)
+		if err != nil {
+			continue
+		}
+		if drainedPodIPs[ip] {
+			drainedEndps[endp] = true
+		}
+	}
+
+	return drainedEndps
+}
+
+// isPodDrained checks if the pod is annotated with nginx.org/drain: "true".
+func isPodDrained(pod *api_v1.Pod) bool {
+	return pod.Annotations[drainAnnotation] == "true"
+}
+
+// EnqueueEndpointsForPod enqueues the endpoints of the services that select the pod.
+func (lbc *LoadBalancerController) EnqueueEndpointsForPod(pod *api_v1.Pod) {
+	for _, obj := range lbc.svcLister.List() {
+		svc := obj.(*api_v1.Service)
+		if svc.Namespace != pod.Namespace || len(svc.Spec.Selector) == 0 {
+			continue
+		}
+		if !labels.SelectorFromSet(svc.Spec.Selector).Matches(labels.Set(pod.Labels)) {
+			continue
+		}
+
+		endps, exists, err := lbc.getEndpointsByKey(svc.Namespace + "/" + svc.Name)
+		if err != nil {
+			glog.V(3).Infof("Error getting endpoints of service %v/%v: %v", svc.Namespace, svc.Name, err)
+			continue
+		}
+		if exists {
+			lbc.AddSyncQueue(endps)
+		}
+	}
+}
+
 func (lbc *LoadBalancerController) getHealthChecksForIngressBackend(backend *extensions.IngressBackend, namespace string) *api_v1.Probe {
 	svc, err := lbc.getServiceForIngressBackend(backend, namespace)
 	if err != nil {
@@ -1820,7 +3362,7 @@ func (lbc *LoadBalancerController) getExternalEndpointsForIngressBackend(backend
 }
 
 func (lbc *LoadBalancerController) getEndpointsForIngressBackend(backend *extensions.IngressBackend, svc *api_v1.Service) (result []string, isExternal bool, err error) {
-	endps, err := lbc.endpointLister.GetServiceEndpoints(svc)
+	endps, err := lbc.getServiceEndpoints(svc)
 	if err != nil {
 		if svc.Spec.Type == api_v1.ServiceTypeExternalName {
 			if !lbc.isNginxPlus {
@@ -1859,20 +3401,27 @@ func (lbc *LoadBalancerController) getEndpointsForPort(endps api_v1.Endpoints, i
 		return nil, fmt.Errorf("No port %v in service %s", ingSvcPort, svc.Name)
 	}
 
+	// the endpoints of the port can be spread across multiple subsets, for example, of multiple EndpointSlices
+	var endpoints []string
+	portFound := false
 	for _, subset := range endps.Subsets {
 		for _, port := range subset.Ports {
 			if port.Port == targetPort {
-				var endpoints []string
+				portFound = true
 				for _, address := range subset.Addresses {
-					endpoint := fmt.Sprintf("%v:%v", address.IP, port.Port)
+					endpoint := net.JoinHostPort(address.IP, strconv.Itoa(int(port.Port)))
 					endpoints = append(endpoints, endpoint)
 				}
-				return endpoints, nil
+				break
 			}
 		}
 	}
 
-	return nil, fmt.Errorf("No endpoints for target port %v in service %s", targetPort, svc.Name)
+	if !portFound {
+		return nil, fmt.Errorf("No endpoints for target port %v in service %s", targetPort, svc.Name)
+	}
+
+	return endpoints, nil
 }
 
 func (lbc *LoadBalancerController) getServicePortForIngressPort(ingSvcPort intstr.IntOrString, svc *api_v1.Service) *api_v1.ServicePort {
@@ -1957,21 +3506,28 @@ func (lbc *LoadBalancerController) isHealthCheckEnabled(ing *extensions.Ingress)
 	return false
 }
 
-// ValidateSecret validates that the secret follows the TLS Secret format.
-// For NGINX Plus, it also checks if the secret follows the JWK Secret format.
+// ValidateSecret validates that the secret follows the TLS, CA or htpasswd Secret format.
+// For NGINX Plus, it also checks if the secret follows the JWK or OIDC Secret format.
 func (lbc *LoadBalancerController) ValidateSecret(secret *api_v1.Secret) error {
 	err1 := ValidateTLSSecret(secret)
+	err2 := ValidateCASecret(secret)
+	err3 := ValidateHtpasswdSecret(secret)
 	if !lbc.isNginxPlus {
-		return err1
+		if err1 == nil || err2 == nil || err3 == nil {
+			return nil
+		}
+
+		return fmt.Errorf("Secret is not a TLS, CA or htpasswd secret")
 	}
 
-	err2 := ValidateJWKSecret(secret)
+	err4 := ValidateJWKSecret(secret)
+	err5 := ValidateOIDCSecret(secret)
 
-	if err1 == nil || err2 == nil {
+	if err1 == nil || err2 == nil || err3 == nil || err4 == nil || err5 == nil {
 		return nil
 	}
 
-	return fmt.Errorf("Secret is not a TLS or JWK secret")
+	return fmt.Errorf("Secret is not a TLS, CA, htpasswd, JWK or OIDC secret")
 }
 
 // getMinionsForHost returns a list of all minion ingress resources for a given master
@@ -1981,9 +3537,17 @@ func (lbc *LoadBalancerController) getMinionsForMaster(master *configs.IngressEx
 		return []*configs.IngressEx{}, err
 	}
 
-	// ingresses are sorted by creation time
+	// ingresses are sorted by creation time. The creation time has a precision of a second,
+	// so the ingresses created in the same second are sorted by their namespace and name to keep the order of the minions stable.
 	sort.Slice(ings.Items[:], func(i, j int) bool {
-		return ings.Items[i].CreationTimestamp.Time.UnixNano() < ings.Items[j].CreationTimestamp.Time.UnixNano()
+		ti, tj := ings.Items[i].CreationTimestamp.Time.UnixNano(), ings.Items[j].CreationTimestamp.Time.UnixNano()
+		if ti != tj {
+			return ti < tj
+		}
+		if ings.Items[i].Namespace != ings.Items[j].Namespace {
+			return ings.Items[i].Namespace < ings.Items[j].Namespace
+		}
+		return ings.Items[i].Name < ings.Items[j].Name
 	})
 
 	var minions []*configs.IngressEx
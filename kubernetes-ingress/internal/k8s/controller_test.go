@@ -3,6 +3,7 @@ package k8s
 import (
 	"fmt"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 	"unsafe"
@@ -632,7 +633,7 @@ func getMergableDefaults() (cafeMaster, coffeeMinion, teaMinion extensions.Ingre
 	cafeMasterIngEx, _ := lbc.createIngress(&cafeMaster)
 	ingExMap["default-cafe-master"] = cafeMasterIngEx
 
-	cnf := configs.NewConfigurator(&nginx.LocalManager{}, &configs.StaticConfigParams{}, &configs.ConfigParams{}, &version1.TemplateExecutor{}, &version2.TemplateExecutor{}, false, false)
+	cnf := configs.NewConfigurator(&nginx.LocalManager{}, &configs.StaticConfigParams{}, &configs.ConfigParams{}, &version1.TemplateExecutor{}, &version2.TemplateExecutor{}, false, false, collectors.NewConfigFakeCollector())
 
 	// edit private field ingresses to use in testing
 	pointerVal := reflect.ValueOf(cnf)
@@ -846,7 +847,7 @@ func TestFindProbeForPods(t *testing.T) {
 
 func TestGetServicePortForIngressPort(t *testing.T) {
 	fakeClient := fake.NewSimpleClientset()
-	cnf := configs.NewConfigurator(&nginx.LocalManager{}, &configs.StaticConfigParams{}, &configs.ConfigParams{}, &version1.TemplateExecutor{}, &version2.TemplateExecutor{}, false, false)
+	cnf := configs.NewConfigurator(&nginx.LocalManager{}, &configs.StaticConfigParams{}, &configs.ConfigParams{}, &version1.TemplateExecutor{}, &version2.TemplateExecutor{}, false, false, collectors.NewConfigFakeCollector())
 	lbc := LoadBalancerController{
 		client:           fakeClient,
 		ingressClass:     "nginx",
@@ -1003,7 +1004,7 @@ func TestFindIngressesForSecret(t *testing.T) {
 
 			manager := nginx.NewFakeManager("/etc/nginx")
 
-			cnf := configs.NewConfigurator(manager, &configs.StaticConfigParams{}, &configs.ConfigParams{}, templateExecutor, templateExecutorV2, false, false)
+			cnf := configs.NewConfigurator(manager, &configs.StaticConfigParams{}, &configs.ConfigParams{}, templateExecutor, templateExecutorV2, false, false, collectors.NewConfigFakeCollector())
 			lbc := LoadBalancerController{
 				client:           fakeClient,
 				ingressClass:     "nginx",
@@ -1194,7 +1195,7 @@ func TestFindIngressesForSecretWithMinions(t *testing.T) {
 
 			manager := nginx.NewFakeManager("/etc/nginx")
 
-			cnf := configs.NewConfigurator(manager, &configs.StaticConfigParams{}, &configs.ConfigParams{}, templateExecutor, templateExecutorV2, false, false)
+			cnf := configs.NewConfigurator(manager, &configs.StaticConfigParams{}, &configs.ConfigParams{}, templateExecutor, templateExecutorV2, false, false, collectors.NewConfigFakeCollector())
 			lbc := LoadBalancerController{
 				client:           fakeClient,
 				ingressClass:     "nginx",
@@ -1436,9 +1437,31 @@ func TestFindVirtualServersForSecret(t *testing.T) {
 		},
 	}
 
-	virtualServers := []*conf_v1.VirtualServer{&vs1, &vs2, &vs3, &vs4, &vs5}
+	vs6 := conf_v1.VirtualServer{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "vs-6",
+			Namespace: "ns-1",
+		},
+		Spec: conf_v1.VirtualServerSpec{
+			Upstreams: []conf_v1.Upstream{
+				{
+					Name: "tea",
+					HealthCheck: &conf_v1.HealthCheck{
+						Enable: true,
+						TLS: &conf_v1.HealthCheckTLS{
+							Enable:            true,
+							VerifyServer:      true,
+							TrustedCertSecret: "test-secret",
+						},
+					},
+				},
+			},
+		},
+	}
 
-	expected := []*conf_v1.VirtualServer{&vs4}
+	virtualServers := []*conf_v1.VirtualServer{&vs1, &vs2, &vs3, &vs4, &vs5, &vs6}
+
+	expected := []*conf_v1.VirtualServer{&vs4, &vs6}
 
 	result := findVirtualServersForSecret(virtualServers, "ns-1", "test-secret")
 	if !reflect.DeepEqual(result, expected) {
@@ -1446,6 +1469,300 @@ func TestFindVirtualServersForSecret(t *testing.T) {
 	}
 }
 
+func TestFindVirtualServersForConfigMap(t *testing.T) {
+	vs1 := conf_v1.VirtualServer{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "vs-1",
+			Namespace: "ns-1",
+		},
+		Spec: conf_v1.VirtualServerSpec{
+			Routes: []conf_v1.Route{
+				{
+					Path: "/",
+					Action: &conf_v1.Action{
+						Pass: "tea",
+					},
+				},
+			},
+		},
+	}
+	vs2 := conf_v1.VirtualServer{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "vs-2",
+			Namespace: "ns-1",
+		},
+		Spec: conf_v1.VirtualServerSpec{
+			Routes: []conf_v1.Route{
+				{
+					Path: "/robots.txt",
+					Action: &conf_v1.Action{
+						Serve: &conf_v1.ActionServe{ConfigMap: "test-configmap", Key: "robots.txt"},
+					},
+				},
+			},
+		},
+	}
+	vs3 := conf_v1.VirtualServer{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "vs-3",
+			Namespace: "ns-1",
+		},
+		Spec: conf_v1.VirtualServerSpec{
+			Routes: []conf_v1.Route{
+				{
+					Path: "/",
+					Matches: []conf_v1.Match{
+						{
+							Splits: []conf_v1.Split{
+								{
+									Weight: 100,
+									Action: &conf_v1.Action{
+										Serve: &conf_v1.ActionServe{ConfigMap: "test-configmap", Key: "index.html"},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	vs4 := conf_v1.VirtualServer{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "vs-4",
+			Namespace: "ns-2",
+		},
+		Spec: conf_v1.VirtualServerSpec{
+			Routes: []conf_v1.Route{
+				{
+					Path: "/robots.txt",
+					Action: &conf_v1.Action{
+						Serve: &conf_v1.ActionServe{ConfigMap: "test-configmap", Key: "robots.txt"},
+					},
+				},
+			},
+		},
+	}
+
+	virtualServers := []*conf_v1.VirtualServer{&vs1, &vs2, &vs3, &vs4}
+
+	expected := []*conf_v1.VirtualServer{&vs2, &vs3}
+
+	result := findVirtualServersForConfigMap(virtualServers, "ns-1", "test-configmap")
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("findVirtualServersForConfigMap returned %v but expected %v", result, expected)
+	}
+}
+
+func TestFindPoliciesForSecret(t *testing.T) {
+	jwtPol1 := &conf_v1.Policy{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "jwt-policy",
+			Namespace: "default",
+		},
+		Spec: conf_v1.PolicySpec{
+			JWTAuth: &conf_v1.JWTAuth{
+				Secret: "jwk-secret",
+			},
+		},
+	}
+	jwtPol2 := &conf_v1.Policy{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "jwt-policy",
+			Namespace: "ns-1",
+		},
+		Spec: conf_v1.PolicySpec{
+			JWTAuth: &conf_v1.JWTAuth{
+				Secret: "jwk-secret",
+			},
+		},
+	}
+	rlPol := &conf_v1.Policy{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "rate-limit-policy",
+			Namespace: "default",
+		},
+		Spec: conf_v1.PolicySpec{
+			RateLimit: &conf_v1.RateLimit{},
+		},
+	}
+
+	oidcPol := &conf_v1.Policy{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "oidc-policy",
+			Namespace: "default",
+		},
+		Spec: conf_v1.PolicySpec{
+			OIDC: &conf_v1.OIDC{
+				ClientSecret: "oidc-secret",
+			},
+		},
+	}
+
+	mtlsPol := &conf_v1.Policy{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "egress-mtls-policy",
+			Namespace: "default",
+		},
+		Spec: conf_v1.PolicySpec{
+			EgressMTLS: &conf_v1.EgressMTLS{
+				TLSSecret:         "mtls-secret",
+				TrustedCertSecret: "ca-secret",
+			},
+		},
+	}
+
+	basicAuthPol := &conf_v1.Policy{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "basic-auth-policy",
+			Namespace: "default",
+		},
+		Spec: conf_v1.PolicySpec{
+			BasicAuth: &conf_v1.BasicAuth{
+				Realm:  "My API",
+				Secret: "htpasswd-secret",
+			},
+		},
+	}
+
+	policies := []*conf_v1.Policy{jwtPol1, jwtPol2, rlPol, oidcPol, mtlsPol, basicAuthPol}
+
+	expected := []*conf_v1.Policy{jwtPol1}
+
+	result := findPoliciesForSecret(policies, "default", "jwk-secret")
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("findPoliciesForSecret returned %v but expected %v", result, expected)
+	}
+
+	expected = []*conf_v1.Policy{oidcPol}
+
+	result = findPoliciesForSecret(policies, "default", "oidc-secret")
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("findPoliciesForSecret returned %v but expected %v", result, expected)
+	}
+
+	expected = []*conf_v1.Policy{mtlsPol}
+
+	for _, secretName := range []string{"mtls-secret", "ca-secret"} {
+		result = findPoliciesForSecret(policies, "default", secretName)
+		if !reflect.DeepEqual(result, expected) {
+			t.Errorf("findPoliciesForSecret returned %v but expected %v for secret %s", result, expected, secretName)
+		}
+	}
+
+	expected = []*conf_v1.Policy{basicAuthPol}
+
+	result = findPoliciesForSecret(policies, "default", "htpasswd-secret")
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("findPoliciesForSecret returned %v but expected %v", result, expected)
+	}
+}
+
+func TestFindVirtualServersForPolicyKey(t *testing.T) {
+	vs1 := conf_v1.VirtualServer{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "vs-1",
+			Namespace: "ns-1",
+		},
+		Spec: conf_v1.VirtualServerSpec{
+			Policies: []conf_v1.PolicyReference{
+				{
+					Name: "test-policy",
+				},
+			},
+		},
+	}
+	vs2 := conf_v1.VirtualServer{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "vs-2",
+			Namespace: "ns-2",
+		},
+		Spec: conf_v1.VirtualServerSpec{
+			Routes: []conf_v1.Route{
+				{
+					Path: "/",
+					Policies: []conf_v1.PolicyReference{
+						{
+							Name:      "test-policy",
+							Namespace: "ns-1",
+						},
+					},
+				},
+			},
+		},
+	}
+	vs3 := conf_v1.VirtualServer{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "vs-3",
+			Namespace: "ns-2",
+		},
+		Spec: conf_v1.VirtualServerSpec{
+			Policies: []conf_v1.PolicyReference{
+				{
+					Name: "test-policy",
+				},
+			},
+		},
+	}
+
+	virtualServers := []*conf_v1.VirtualServer{&vs1, &vs2, &vs3}
+
+	expected := []*conf_v1.VirtualServer{&vs1, &vs2}
+
+	result := findVirtualServersForPolicyKey(virtualServers, "ns-1/test-policy")
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("findVirtualServersForPolicyKey returned %v but expected %v", result, expected)
+	}
+}
+
+func TestFindVirtualServerRoutesForPolicyKey(t *testing.T) {
+	vsr1 := conf_v1.VirtualServerRoute{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "vsr-1",
+			Namespace: "ns-1",
+		},
+		Spec: conf_v1.VirtualServerRouteSpec{
+			Subroutes: []conf_v1.Route{
+				{
+					Path: "/",
+					Policies: []conf_v1.PolicyReference{
+						{
+							Name: "test-policy",
+						},
+					},
+				},
+			},
+		},
+	}
+	vsr2 := conf_v1.VirtualServerRoute{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "vsr-2",
+			Namespace: "ns-2",
+		},
+		Spec: conf_v1.VirtualServerRouteSpec{
+			Subroutes: []conf_v1.Route{
+				{
+					Path: "/",
+					Policies: []conf_v1.PolicyReference{
+						{
+							Name: "test-policy",
+						},
+					},
+				},
+			},
+		},
+	}
+
+	virtualServerRoutes := []*conf_v1.VirtualServerRoute{&vsr1, &vsr2}
+
+	expected := []*conf_v1.VirtualServerRoute{&vsr1}
+
+	result := findVirtualServerRoutesForPolicyKey(virtualServerRoutes, "ns-1/test-policy")
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("findVirtualServerRoutesForPolicyKey returned %v but expected %v", result, expected)
+	}
+}
+
 func TestFindVirtualServersForVirtualServerRoute(t *testing.T) {
 	vs1 := conf_v1.VirtualServer{
 		ObjectMeta: meta_v1.ObjectMeta{
@@ -1526,9 +1843,9 @@ func TestGetEndpointsBySubselectedPods(t *testing.T) {
 		expectedEps []string
 	}{
 		{
-			desc:        "find one endpoint",
+			desc:        "find endpoints",
 			targetPort:  80,
-			expectedEps: []string{"1.2.3.4:80"},
+			expectedEps: []string{"1.2.3.4:80", "[fd00::8]:80"},
 		},
 		{
 			desc:        "targetPort mismatch",
@@ -1543,6 +1860,15 @@ func TestGetEndpointsBySubselectedPods(t *testing.T) {
 				PodIP: "1.2.3.4",
 			},
 		},
+		{
+			Status: v1.PodStatus{
+				PodIP: "5.6.7.8",
+				PodIPs: []v1.PodIP{
+					{IP: "5.6.7.8"},
+					{IP: "fd00::8"},
+				},
+			},
+		},
 	}
 
 	svcEps := v1.Endpoints{
@@ -1553,6 +1879,9 @@ func TestGetEndpointsBySubselectedPods(t *testing.T) {
 						IP:       "1.2.3.4",
 						Hostname: "asdf.com",
 					},
+					{
+						IP: "fd00::8",
+					},
 				},
 				Ports: []v1.EndpointPort{
 					{
@@ -1572,3 +1901,516 @@ func TestGetEndpointsBySubselectedPods(t *testing.T) {
 		})
 	}
 }
+
+func TestGetEndpointsForPort(t *testing.T) {
+	lbc := LoadBalancerController{}
+
+	svc := &v1.Service{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "coffee-svc",
+			Namespace: "default",
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{
+				{
+					Port:       80,
+					TargetPort: intstr.FromInt(8080),
+				},
+			},
+		},
+	}
+
+	// the subsets of multiple EndpointSlices of the service
+	endps := v1.Endpoints{
+		Subsets: []v1.EndpointSubset{
+			{
+				Addresses: []v1.EndpointAddress{{IP: "10.0.0.1"}},
+				Ports:     []v1.EndpointPort{{Port: 8080}},
+			},
+			{
+				Addresses: []v1.EndpointAddress{{IP: "10.0.0.2"}, {IP: "fd00::2"}},
+				Ports:     []v1.EndpointPort{{Port: 8080}},
+			},
+		},
+	}
+
+	expected := []string{"10.0.0.1:8080", "10.0.0.2:8080", "[fd00::2]:8080"}
+
+	result, err := lbc.getEndpointsForPort(endps, intstr.FromInt(80), svc)
+	if err != nil {
+		t.Fatalf("getEndpointsForPort() returned unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("getEndpointsForPort() returned %v but expected %v", result, expected)
+	}
+
+	_, err = lbc.getEndpointsForPort(v1.Endpoints{}, intstr.FromInt(80), svc)
+	if err == nil {
+		t.Errorf("getEndpointsForPort() returned no error for Endpoints without the target port")
+	}
+}
+
+func TestGetServicePortForUpstream(t *testing.T) {
+	svc := &v1.Service{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "coffee-svc",
+			Namespace: "default",
+		},
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{
+				{
+					Name: "http",
+					Port: 8080,
+				},
+				{
+					Name: "https",
+					Port: 8443,
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		upstream conf_v1.Upstream
+		expected int32
+		msg      string
+	}{
+		{
+			upstream: conf_v1.Upstream{
+				Service: "coffee-svc",
+				Port:    80,
+			},
+			expected: 80,
+			msg:      "port number",
+		},
+		{
+			upstream: conf_v1.Upstream{
+				Service:  "coffee-svc",
+				PortName: "https",
+			},
+			expected: 8443,
+			msg:      "port name",
+		},
+	}
+
+	for _, test := range tests {
+		result, err := getServicePortForUpstream(test.upstream, svc)
+		if err != nil {
+			t.Errorf("getServicePortForUpstream() returned unexpected error %v for the case of %s", err, test.msg)
+		}
+		if result != test.expected {
+			t.Errorf("getServicePortForUpstream() returned %v but expected %v for the case of %s", result, test.expected, test.msg)
+		}
+	}
+
+	_, err := getServicePortForUpstream(conf_v1.Upstream{Service: "coffee-svc", PortName: "grpc"}, svc)
+	if err == nil {
+		t.Errorf("getServicePortForUpstream() returned no error for a port name that doesn't exist in the service")
+	}
+}
+
+func TestGetDrainedEndpoints(t *testing.T) {
+	pods := []*v1.Pod{
+		{
+			ObjectMeta: meta_v1.ObjectMeta{
+				Name: "drained",
+				Annotations: map[string]string{
+					"nginx.org/drain": "true",
+				},
+			},
+			Status: v1.PodStatus{
+				PodIP: "10.0.0.1",
+			},
+		},
+		{
+			ObjectMeta: meta_v1.ObjectMeta{
+				Name: "not-drained",
+				Annotations: map[string]string{
+					"nginx.org/drain": "false",
+				},
+			},
+			Status: v1.PodStatus{
+				PodIP: "10.0.0.2",
+			},
+		},
+		{
+			ObjectMeta: meta_v1.ObjectMeta{
+				Name: "no-annotation",
+			},
+			Status: v1.PodStatus{
+				PodIP: "10.0.0.3",
+			},
+		},
+	}
+
+	endps := []string{"10.0.0.1:80", "10.0.0.1:8080", "10.0.0.2:80", "10.0.0.3:80"}
+
+	expected := map[string]bool{
+		"10.0.0.1:80":   true,
+		"10.0.0.1:8080": true,
+	}
+
+	result := getDrainedEndpoints(pods, endps)
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("getDrainedEndpoints() returned %v but expected %v", result, expected)
+	}
+}
+
+func TestAddNotReadyAddresses(t *testing.T) {
+	endps := v1.Endpoints{
+		Subsets: []v1.EndpointSubset{
+			{
+				Addresses:         []v1.EndpointAddress{{IP: "10.0.0.1"}},
+				NotReadyAddresses: []v1.EndpointAddress{{IP: "10.0.0.2"}},
+				Ports:             []v1.EndpointPort{{Port: 8080}},
+			},
+		},
+	}
+
+	expected := v1.Endpoints{
+		Subsets: []v1.EndpointSubset{
+			{
+				Addresses: []v1.EndpointAddress{{IP: "10.0.0.1"}, {IP: "10.0.0.2"}},
+				Ports:     []v1.EndpointPort{{Port: 8080}},
+			},
+		},
+	}
+
+	result := addNotReadyAddresses(endps)
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("addNotReadyAddresses() returned %+v but expected %+v", result, expected)
+	}
+	if len(endps.Subsets[0].Addresses) != 1 || len(endps.Subsets[0].NotReadyAddresses) != 1 {
+		t.Errorf("addNotReadyAddresses() changed the original Endpoints to %+v", endps)
+	}
+}
+
+func TestGetClusterIPEndpoint(t *testing.T) {
+	tests := []struct {
+		clusterIP string
+		expected  string
+	}{
+		{
+			clusterIP: "10.96.0.10",
+			expected:  "10.96.0.10:8080",
+		},
+		{
+			clusterIP: "fd00::10",
+			expected:  "[fd00::10]:8080",
+		},
+	}
+
+	for _, test := range tests {
+		svc := &v1.Service{Spec: v1.ServiceSpec{ClusterIP: test.clusterIP}}
+		result, err := getClusterIPEndpoint(svc, 8080)
+		if err != nil {
+			t.Errorf("getClusterIPEndpoint() returned error %v for cluster IP %v", err, test.clusterIP)
+		}
+		if result != test.expected {
+			t.Errorf("getClusterIPEndpoint() returned %v but expected %v", result, test.expected)
+		}
+	}
+
+	for _, clusterIP := range []string{"", v1.ClusterIPNone} {
+		svc := &v1.Service{Spec: v1.ServiceSpec{ClusterIP: clusterIP}}
+		if _, err := getClusterIPEndpoint(svc, 8080); err == nil {
+			t.Errorf("getClusterIPEndpoint() returned no error for cluster IP %q", clusterIP)
+		}
+	}
+}
+
+func TestGetRemoteZoneEndpoints(t *testing.T) {
+	zones := map[string]string{
+		"10.0.0.1": "zone-a",
+		"10.0.0.2": "zone-b",
+		"fd00::3":  "zone-b",
+	}
+
+	endps := []string{"10.0.0.1:80", "10.0.0.2:80", "[fd00::3]:80", "10.0.0.4:80"}
+
+	expected := map[string]bool{
+		"10.0.0.2:80":  true,
+		"[fd00::3]:80": true,
+	}
+
+	result := getRemoteZoneEndpoints(zones, "zone-a", endps)
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("getRemoteZoneEndpoints() returned %v but expected %v", result, expected)
+	}
+}
+
+func TestFindListenerConflict(t *testing.T) {
+	now := meta_v1.Now()
+	later := meta_v1.NewTime(now.Add(time.Minute))
+
+	createVirtualServer := func(name string, creationTimestamp meta_v1.Time, listener *conf_v1.VirtualServerListener) *conf_v1.VirtualServer {
+		return &conf_v1.VirtualServer{
+			ObjectMeta: meta_v1.ObjectMeta{
+				Name:              name,
+				Namespace:         "default",
+				CreationTimestamp: creationTimestamp,
+			},
+			Spec: conf_v1.VirtualServerSpec{
+				Listener: listener,
+			},
+		}
+	}
+
+	oldVs := createVirtualServer("old", now, &conf_v1.VirtualServerListener{HTTP: 8080, HTTPS: 8443})
+	reservedPorts := []int{9113}
+
+	tests := []struct {
+		virtualServer  *conf_v1.VirtualServer
+		virtualServers []*conf_v1.VirtualServer
+		expectConflict bool
+		msg            string
+	}{
+		{
+			virtualServer:  createVirtualServer("new", later, nil),
+			virtualServers: []*conf_v1.VirtualServer{oldVs},
+			expectConflict: false,
+			msg:            "no listener",
+		},
+		{
+			virtualServer:  createVirtualServer("new", later, &conf_v1.VirtualServerListener{HTTP: 8080, HTTPS: 8443}),
+			virtualServers: []*conf_v1.VirtualServer{oldVs},
+			expectConflict: false,
+			msg:            "same ports with the same protocols",
+		},
+		{
+			virtualServer:  createVirtualServer("new", later, &conf_v1.VirtualServerListener{HTTP: 9113}),
+			virtualServers: []*conf_v1.VirtualServer{oldVs},
+			expectConflict: true,
+			msg:            "reserved port",
+		},
+		{
+			virtualServer:  createVirtualServer("new", later, &conf_v1.VirtualServerListener{HTTP: 8443}),
+			virtualServers: []*conf_v1.VirtualServer{oldVs},
+			expectConflict: true,
+			msg:            "http port used as https port by an older VirtualServer",
+		},
+		{
+			virtualServer:  createVirtualServer("new", later, &conf_v1.VirtualServerListener{HTTPS: 8080}),
+			virtualServers: []*conf_v1.VirtualServer{oldVs},
+			expectConflict: true,
+			msg:            "https port used as http port by an older VirtualServer",
+		},
+		{
+			virtualServer: oldVs,
+			virtualServers: []*conf_v1.VirtualServer{
+				oldVs,
+				createVirtualServer("new", later, &conf_v1.VirtualServerListener{HTTP: 8443}),
+			},
+			expectConflict: false,
+			msg:            "conflict with a newer VirtualServer",
+		},
+		{
+			virtualServer: createVirtualServer("newer", meta_v1.NewTime(later.Add(time.Minute)), &conf_v1.VirtualServerListener{HTTPS: 8081}),
+			virtualServers: []*conf_v1.VirtualServer{
+				oldVs,
+				createVirtualServer("new", later, &conf_v1.VirtualServerListener{HTTP: 8081, HTTPS: 8080}),
+			},
+			expectConflict: false,
+			msg:            "conflict with an older VirtualServer that lost its ports to a conflict",
+		},
+		{
+			virtualServer: createVirtualServer("b", now, &conf_v1.VirtualServerListener{HTTPS: 8080}),
+			virtualServers: []*conf_v1.VirtualServer{
+				createVirtualServer("a", now, &conf_v1.VirtualServerListener{HTTP: 8080}),
+			},
+			expectConflict: true,
+			msg:            "conflict with a VirtualServer created at the same time",
+		},
+	}
+
+	for _, test := range tests {
+		err := findListenerConflict(test.virtualServer, test.virtualServers, reservedPorts)
+		if test.expectConflict && err == nil {
+			t.Errorf("findListenerConflict() returned no error for the case of %s", test.msg)
+		}
+		if !test.expectConflict && err != nil {
+			t.Errorf("findListenerConflict() returned unexpected error %v for the case of %s", err, test.msg)
+		}
+	}
+}
+
+func TestFindHostConflict(t *testing.T) {
+	now := meta_v1.Now()
+	later := meta_v1.NewTime(now.Add(time.Minute))
+
+	createVirtualServer := func(name string, creationTimestamp meta_v1.Time, host string) *conf_v1.VirtualServer {
+		return &conf_v1.VirtualServer{
+			ObjectMeta: meta_v1.ObjectMeta{
+				Name:              name,
+				Namespace:         "default",
+				CreationTimestamp: creationTimestamp,
+			},
+			Spec: conf_v1.VirtualServerSpec{
+				Host: host,
+			},
+		}
+	}
+
+	oldVs := createVirtualServer("old", now, "cafe.example.com")
+
+	tests := []struct {
+		virtualServer  *conf_v1.VirtualServer
+		virtualServers []*conf_v1.VirtualServer
+		expectConflict bool
+		msg            string
+	}{
+		{
+			virtualServer:  oldVs,
+			virtualServers: []*conf_v1.VirtualServer{oldVs},
+			expectConflict: false,
+			msg:            "no other VirtualServers",
+		},
+		{
+			virtualServer:  createVirtualServer("new", later, "tea.example.com"),
+			virtualServers: []*conf_v1.VirtualServer{oldVs},
+			expectConflict: false,
+			msg:            "different hosts",
+		},
+		{
+			virtualServer:  createVirtualServer("new", later, "cafe.example.com"),
+			virtualServers: []*conf_v1.VirtualServer{oldVs},
+			expectConflict: true,
+			msg:            "host used by an older VirtualServer",
+		},
+		{
+			virtualServer: oldVs,
+			virtualServers: []*conf_v1.VirtualServer{
+				oldVs,
+				createVirtualServer("new", later, "cafe.example.com"),
+			},
+			expectConflict: false,
+			msg:            "host used by a newer VirtualServer",
+		},
+		{
+			virtualServer: createVirtualServer("b", now, "cafe.example.com"),
+			virtualServers: []*conf_v1.VirtualServer{
+				createVirtualServer("a", now, "cafe.example.com"),
+			},
+			expectConflict: true,
+			msg:            "host used by a VirtualServer created at the same time",
+		},
+	}
+
+	for _, test := range tests {
+		err := findHostConflict(test.virtualServer, test.virtualServers)
+		if test.expectConflict && err == nil {
+			t.Errorf("findHostConflict() returned no error for the case of %s", test.msg)
+		}
+		if !test.expectConflict && err != nil {
+			t.Errorf("findHostConflict() returned unexpected error %v for the case of %s", err, test.msg)
+		}
+	}
+}
+
+func TestFindNameConflict(t *testing.T) {
+	now := meta_v1.Now()
+	later := meta_v1.NewTime(now.Add(time.Minute))
+
+	createVirtualServer := func(namespace string, name string, creationTimestamp meta_v1.Time) *conf_v1.VirtualServer {
+		return &conf_v1.VirtualServer{
+			ObjectMeta: meta_v1.ObjectMeta{
+				Name:              name,
+				Namespace:         namespace,
+				CreationTimestamp: creationTimestamp,
+			},
+		}
+	}
+
+	oldVs := createVirtualServer("cafe-a", "tea", now)
+
+	tests := []struct {
+		virtualServer        *conf_v1.VirtualServer
+		virtualServers       []*conf_v1.VirtualServer
+		upstreamNamingScheme configs.UpstreamNamingScheme
+		expectConflict       bool
+		msg                  string
+	}{
+		{
+			virtualServer:  oldVs,
+			virtualServers: []*conf_v1.VirtualServer{oldVs},
+			expectConflict: false,
+			msg:            "no other VirtualServers",
+		},
+		{
+			virtualServer:  createVirtualServer("cafe", "tea", later),
+			virtualServers: []*conf_v1.VirtualServer{oldVs},
+			expectConflict: false,
+			msg:            "different names",
+		},
+		{
+			virtualServer:  createVirtualServer("cafe", "a-tea", later),
+			virtualServers: []*conf_v1.VirtualServer{oldVs},
+			expectConflict: true,
+			msg:            "names colliding with an older VirtualServer",
+		},
+		{
+			virtualServer: oldVs,
+			virtualServers: []*conf_v1.VirtualServer{
+				oldVs,
+				createVirtualServer("cafe", "a-tea", later),
+			},
+			expectConflict: false,
+			msg:            "names colliding with a newer VirtualServer",
+		},
+		{
+			virtualServer:  createVirtualServer("cafe-b", "tea", later),
+			virtualServers: []*conf_v1.VirtualServer{oldVs},
+			expectConflict: false,
+			msg:            "same names in different namespaces",
+		},
+		{
+			virtualServer:        createVirtualServer("cafe-b", "tea", later),
+			virtualServers:       []*conf_v1.VirtualServer{oldVs},
+			upstreamNamingScheme: configs.UpstreamNamingScheme{ExcludeNamespace: true},
+			expectConflict:       true,
+			msg:                  "same names in different namespaces with the names of upstreams without namespaces",
+		},
+	}
+
+	for _, test := range tests {
+		err := findNameConflict(test.virtualServer, test.virtualServers, test.upstreamNamingScheme)
+		if test.expectConflict && err == nil {
+			t.Errorf("findNameConflict() returned no error for the case of %s", test.msg)
+		}
+		if !test.expectConflict && err != nil {
+			t.Errorf("findNameConflict() returned unexpected error %v for the case of %s", err, test.msg)
+		}
+	}
+}
+
+func TestFindRegexHostOverlaps(t *testing.T) {
+	createVirtualServer := func(name string, host string) *conf_v1.VirtualServer {
+		return &conf_v1.VirtualServer{
+			ObjectMeta: meta_v1.ObjectMeta{
+				Name:      name,
+				Namespace: "default",
+			},
+			Spec: conf_v1.VirtualServerSpec{
+				Host: host,
+			},
+		}
+	}
+
+	regexVs := createVirtualServer("regex", `~^(?<subdomain>.+)\.example\.com$`)
+	virtualServers := []*conf_v1.VirtualServer{
+		regexVs,
+		createVirtualServer("cafe", "cafe.example.com"),
+		createVirtualServer("other-regex", `~^cafe\.example\.com$`),
+		createVirtualServer("example", "example.com"),
+		createVirtualServer("tea", "tea.example.org"),
+	}
+
+	warnings := findRegexHostOverlaps(regexVs, virtualServers)
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "default/cafe") {
+		t.Errorf("findRegexHostOverlaps() returned %v but expected one warning for VirtualServer default/cafe", warnings)
+	}
+
+	if warnings := findRegexHostOverlaps(virtualServers[1], virtualServers); len(warnings) != 0 {
+		t.Errorf("findRegexHostOverlaps() returned %v for a VirtualServer with an exact host", warnings)
+	}
+}
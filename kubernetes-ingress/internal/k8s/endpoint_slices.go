@@ -0,0 +1,223 @@
+package k8s
+
+import (
+	"fmt"
+	"sort"
+
+	api_v1 "k8s.io/api/core/v1"
+	discovery "k8s.io/api/discovery/v1alpha1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// endpointSliceServiceIndex is the name of the index of the EndpointSlices by the key (namespace/name) of their service.
+const endpointSliceServiceIndex = "service"
+
+// zoneTopologyKey is the topology key of the zone of an endpoint.
+const zoneTopologyKey = "topology.kubernetes.io/zone"
+
+// endpointSliceServiceIndexFunc indexes an EndpointSlice by the key of the service from its kubernetes.io/service-name label.
+// The EndpointSlices without the label don't belong to a service and are not indexed.
+func endpointSliceServiceIndexFunc(obj interface{}) ([]string, error) {
+	slice, ok := obj.(*discovery.EndpointSlice)
+	if !ok {
+		return nil, fmt.Errorf("object %v is not an EndpointSlice", obj)
+	}
+
+	serviceName, exists := slice.Labels[discovery.LabelServiceName]
+	if !exists || serviceName == "" {
+		return nil, nil
+	}
+
+	return []string{slice.Namespace + "/" + serviceName}, nil
+}
+
+// getServiceKeyForEndpointSlice returns the key of the service of the EndpointSlice.
+func getServiceKeyForEndpointSlice(slice *discovery.EndpointSlice) (string, bool) {
+	keys, err := endpointSliceServiceIndexFunc(slice)
+	if err != nil || len(keys) == 0 {
+		return "", false
+	}
+	return keys[0], true
+}
+
+// storeToEndpointSliceLister makes an Indexer that lists the EndpointSlices of services. Because a service can have
+// multiple EndpointSlices, the lister joins them into Endpoints, so that the controller handles the endpoints of
+// a service the same way regardless of the resources they come from.
+type storeToEndpointSliceLister struct {
+	cache.Indexer
+}
+
+// GetServiceEndpoints returns the endpoints of a service joined from its EndpointSlices.
+func (s *storeToEndpointSliceLister) GetServiceEndpoints(svc *api_v1.Service) (ep api_v1.Endpoints, err error) {
+	endpoints, exists, err := s.GetEndpointsByKey(svc.Namespace + "/" + svc.Name)
+	if err != nil {
+		return ep, err
+	}
+	if !exists {
+		return ep, fmt.Errorf("could not find endpoint slices for service: %v", svc.Name)
+	}
+	return *endpoints, nil
+}
+
+// GetEndpointsByKey returns the endpoints of the service with the key joined from its EndpointSlices.
+func (s *storeToEndpointSliceLister) GetEndpointsByKey(key string) (*api_v1.Endpoints, bool, error) {
+	slices, err := s.listEndpointSlices(key)
+	if err != nil || len(slices) == 0 {
+		return nil, false, err
+	}
+	return endpointSlicesToEndpoints(key, slices), true, nil
+}
+
+// GetEndpointsByKeyWithSlice returns the endpoints of the service with the key as if its EndpointSlice with the name
+// of the slice were replaced by the slice. The handlers use it to get the endpoints before a change of an EndpointSlice,
+// because the store already holds the changed EndpointSlice.
+func (s *storeToEndpointSliceLister) GetEndpointsByKeyWithSlice(key string, slice *discovery.EndpointSlice) (*api_v1.Endpoints, error) {
+	slices, err := s.listEndpointSlices(key)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []*discovery.EndpointSlice{slice}
+	for _, sl := range slices {
+		if sl.Name != slice.Name {
+			result = append(result, sl)
+		}
+	}
+
+	return endpointSlicesToEndpoints(key, result), nil
+}
+
+// GetEndpointZones returns the zones of the endpoints of the service with the key, keyed by the addresses
+// of the endpoints. The endpoints without the zone topology key are not included.
+func (s *storeToEndpointSliceLister) GetEndpointZones(key string) (map[string]string, error) {
+	slices, err := s.listEndpointSlices(key)
+	if err != nil {
+		return nil, err
+	}
+
+	zones := make(map[string]string)
+	for _, slice := range slices {
+		for _, endp := range slice.Endpoints {
+			zone, exists := endp.Topology[zoneTopologyKey]
+			if !exists || zone == "" {
+				continue
+			}
+			for _, ip := range endp.Addresses {
+				zones[ip] = zone
+			}
+		}
+	}
+
+	return zones, nil
+}
+
+func (s *storeToEndpointSliceLister) listEndpointSlices(key string) ([]*discovery.EndpointSlice, error) {
+	objs, err := s.Indexer.ByIndex(endpointSliceServiceIndex, key)
+	if err != nil {
+		return nil, err
+	}
+
+	var slices []*discovery.EndpointSlice
+	for _, obj := range objs {
+		slices = append(slices, obj.(*discovery.EndpointSlice))
+	}
+
+	return slices, nil
+}
+
+// isIPAddressType returns true if the addresses of the EndpointSlice are IP addresses. Besides IP, the only type of
+// the v1alpha1 API, the type can be IPv4 or IPv6 in the later APIs, which the API server also serves as v1alpha1.
+// A nil type means IP.
+func isIPAddressType(slice *discovery.EndpointSlice) bool {
+	if slice.AddressType == nil {
+		return true
+	}
+
+	switch *slice.AddressType {
+	case discovery.AddressTypeIP, discovery.AddressType("IPv4"), discovery.AddressType("IPv6"):
+		return true
+	}
+
+	return false
+}
+
+// endpointSlicesToEndpoints joins the EndpointSlices of the service with the key into Endpoints with a subset per
+// EndpointSlice. The subsets are sorted by the names of the EndpointSlices, so that the Endpoints of unchanged
+// EndpointSlices are equal. The endpoints that are not ready are the not ready addresses of the subsets. An endpoint
+// with multiple addresses, such as in a dual-stack cluster, becomes an address of the subset per address.
+// The EndpointSlices with addresses other than IP addresses, such as FQDN, are skipped, because the upstreams
+// can't use them as servers.
+func endpointSlicesToEndpoints(key string, slices []*discovery.EndpointSlice) *api_v1.Endpoints {
+	namespace, name, _ := cache.SplitMetaNamespaceKey(key)
+
+	sorted := make([]*discovery.EndpointSlice, len(slices))
+	copy(sorted, slices)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+
+	endpoints := &api_v1.Endpoints{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Namespace: namespace,
+			Name:      name,
+		},
+	}
+
+	for _, slice := range sorted {
+		if !isIPAddressType(slice) {
+			continue
+		}
+
+		var subset api_v1.EndpointSubset
+
+		for _, endp := range slice.Endpoints {
+			// a nil condition means that the state is unknown, which is interpreted as ready
+			ready := endp.Conditions.Ready == nil || *endp.Conditions.Ready
+
+			for _, ip := range endp.Addresses {
+				address := api_v1.EndpointAddress{
+					IP:        ip,
+					TargetRef: endp.TargetRef,
+				}
+				if endp.Hostname != nil {
+					address.Hostname = *endp.Hostname
+				}
+
+				if ready {
+					subset.Addresses = append(subset.Addresses, address)
+				} else {
+					subset.NotReadyAddresses = append(subset.NotReadyAddresses, address)
+				}
+			}
+		}
+
+		for _, port := range slice.Ports {
+			// a port without a number means all ports, which the upstreams can't use
+			if port.Port == nil {
+				continue
+			}
+
+			endpointPort := api_v1.EndpointPort{
+				Port:     *port.Port,
+				Protocol: api_v1.ProtocolTCP,
+			}
+			if port.Name != nil {
+				endpointPort.Name = *port.Name
+			}
+			if port.Protocol != nil {
+				endpointPort.Protocol = *port.Protocol
+			}
+
+			subset.Ports = append(subset.Ports, endpointPort)
+		}
+
+		if len(subset.Ports) == 0 || len(subset.Addresses)+len(subset.NotReadyAddresses) == 0 {
+			continue
+		}
+
+		endpoints.Subsets = append(endpoints.Subsets, subset)
+	}
+
+	return endpoints
+}
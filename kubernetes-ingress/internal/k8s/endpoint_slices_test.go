@@ -0,0 +1,170 @@
+package k8s
+
+import (
+	"reflect"
+	"testing"
+
+	api_v1 "k8s.io/api/core/v1"
+	discovery "k8s.io/api/discovery/v1alpha1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func createTestEndpointSlice(name string, serviceName string, port int32, endpoints ...discovery.Endpoint) *discovery.EndpointSlice {
+	portName := "http"
+	slice := &discovery.EndpointSlice{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+			Labels:    map[string]string{},
+		},
+		Endpoints: endpoints,
+		Ports: []discovery.EndpointPort{
+			{
+				Name: &portName,
+				Port: &port,
+			},
+		},
+	}
+	if serviceName != "" {
+		slice.Labels[discovery.LabelServiceName] = serviceName
+	}
+	return slice
+}
+
+func createTestEndpointSliceLister(slices ...*discovery.EndpointSlice) storeToEndpointSliceLister {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{endpointSliceServiceIndex: endpointSliceServiceIndexFunc})
+	for _, slice := range slices {
+		_ = indexer.Add(slice)
+	}
+	return storeToEndpointSliceLister{Indexer: indexer}
+}
+
+func TestEndpointSlicesToEndpoints(t *testing.T) {
+	notReady := false
+	hostname := "coffee-0"
+
+	slices := []*discovery.EndpointSlice{
+		createTestEndpointSlice("coffee-svc-b", "coffee-svc", 8080,
+			discovery.Endpoint{Addresses: []string{"10.0.0.3", "fd00::3"}},
+		),
+		createTestEndpointSlice("coffee-svc-a", "coffee-svc", 8080,
+			discovery.Endpoint{Addresses: []string{"10.0.0.1"}, Hostname: &hostname},
+			discovery.Endpoint{Addresses: []string{"10.0.0.2"}, Conditions: discovery.EndpointConditions{Ready: &notReady}},
+		),
+		createTestEndpointSlice("coffee-svc-c", "coffee-svc", 8080),
+	}
+
+	expected := &api_v1.Endpoints{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Namespace: "default",
+			Name:      "coffee-svc",
+		},
+		Subsets: []api_v1.EndpointSubset{
+			{
+				Addresses:         []api_v1.EndpointAddress{{IP: "10.0.0.1", Hostname: "coffee-0"}},
+				NotReadyAddresses: []api_v1.EndpointAddress{{IP: "10.0.0.2"}},
+				Ports:             []api_v1.EndpointPort{{Name: "http", Port: 8080, Protocol: api_v1.ProtocolTCP}},
+			},
+			{
+				Addresses: []api_v1.EndpointAddress{{IP: "10.0.0.3"}, {IP: "fd00::3"}},
+				Ports:     []api_v1.EndpointPort{{Name: "http", Port: 8080, Protocol: api_v1.ProtocolTCP}},
+			},
+		},
+	}
+
+	result := endpointSlicesToEndpoints("default/coffee-svc", slices)
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("endpointSlicesToEndpoints() returned \n%+v but expected \n%+v", result, expected)
+	}
+}
+
+func TestEndpointSlicesToEndpointsSkipsPortsWithoutNumbers(t *testing.T) {
+	slice := createTestEndpointSlice("coffee-svc-a", "coffee-svc", 8080, discovery.Endpoint{Addresses: []string{"10.0.0.1"}})
+	slice.Ports[0].Port = nil
+
+	result := endpointSlicesToEndpoints("default/coffee-svc", []*discovery.EndpointSlice{slice})
+	if len(result.Subsets) != 0 {
+		t.Errorf("endpointSlicesToEndpoints() returned subsets %+v for an EndpointSlice without port numbers", result.Subsets)
+	}
+}
+
+func TestEndpointSlicesToEndpointsSkipsNonIPAddressTypes(t *testing.T) {
+	fqdn := discovery.AddressType("FQDN")
+	fqdnSlice := createTestEndpointSlice("coffee-svc-a", "coffee-svc", 8080, discovery.Endpoint{Addresses: []string{"coffee.example.com"}})
+	fqdnSlice.AddressType = &fqdn
+
+	ipv4 := discovery.AddressType("IPv4")
+	ipv4Slice := createTestEndpointSlice("coffee-svc-b", "coffee-svc", 8080, discovery.Endpoint{Addresses: []string{"10.0.0.1"}})
+	ipv4Slice.AddressType = &ipv4
+
+	result := endpointSlicesToEndpoints("default/coffee-svc", []*discovery.EndpointSlice{fqdnSlice, ipv4Slice})
+
+	expected := []api_v1.EndpointSubset{
+		{
+			Addresses: []api_v1.EndpointAddress{{IP: "10.0.0.1"}},
+			Ports:     []api_v1.EndpointPort{{Name: "http", Port: 8080, Protocol: api_v1.ProtocolTCP}},
+		},
+	}
+	if !reflect.DeepEqual(result.Subsets, expected) {
+		t.Errorf("endpointSlicesToEndpoints() returned subsets \n%+v but expected \n%+v", result.Subsets, expected)
+	}
+}
+
+func TestStoreToEndpointSliceLister(t *testing.T) {
+	sliceA := createTestEndpointSlice("coffee-svc-a", "coffee-svc", 80, discovery.Endpoint{Addresses: []string{"10.0.0.1"}})
+	sliceB := createTestEndpointSlice("coffee-svc-b", "coffee-svc", 80, discovery.Endpoint{Addresses: []string{"10.0.0.2"}})
+	unowned := createTestEndpointSlice("custom", "", 80, discovery.Endpoint{Addresses: []string{"10.0.0.3"}})
+
+	lister := createTestEndpointSliceLister(sliceA, sliceB, unowned)
+
+	endpoints, exists, err := lister.GetEndpointsByKey("default/coffee-svc")
+	if err != nil || !exists {
+		t.Fatalf("GetEndpointsByKey() returned %v, %v for a service with EndpointSlices", exists, err)
+	}
+	if len(endpoints.Subsets) != 2 {
+		t.Errorf("GetEndpointsByKey() returned %v subsets but expected 2", len(endpoints.Subsets))
+	}
+
+	_, exists, err = lister.GetEndpointsByKey("default/tea-svc")
+	if err != nil || exists {
+		t.Errorf("GetEndpointsByKey() returned %v, %v for a service without EndpointSlices", exists, err)
+	}
+
+	svc := &api_v1.Service{ObjectMeta: meta_v1.ObjectMeta{Namespace: "default", Name: "tea-svc"}}
+	if _, err := lister.GetServiceEndpoints(svc); err == nil {
+		t.Errorf("GetServiceEndpoints() returned no error for a service without EndpointSlices")
+	}
+
+	oldSliceB := createTestEndpointSlice("coffee-svc-b", "coffee-svc", 80, discovery.Endpoint{Addresses: []string{"10.0.0.4"}})
+	previous, err := lister.GetEndpointsByKeyWithSlice("default/coffee-svc", oldSliceB)
+	if err != nil {
+		t.Fatalf("GetEndpointsByKeyWithSlice() returned error %v", err)
+	}
+	expected := endpointSlicesToEndpoints("default/coffee-svc", []*discovery.EndpointSlice{sliceA, oldSliceB})
+	if !reflect.DeepEqual(previous, expected) {
+		t.Errorf("GetEndpointsByKeyWithSlice() returned \n%+v but expected \n%+v", previous, expected)
+	}
+}
+
+func TestStoreToEndpointSliceListerGetEndpointZones(t *testing.T) {
+	slice := createTestEndpointSlice("coffee-svc-a", "coffee-svc", 80,
+		discovery.Endpoint{Addresses: []string{"10.0.0.1", "fd00::1"}, Topology: map[string]string{zoneTopologyKey: "zone-a"}},
+		discovery.Endpoint{Addresses: []string{"10.0.0.2"}, Topology: map[string]string{"kubernetes.io/hostname": "node-2"}},
+	)
+
+	lister := createTestEndpointSliceLister(slice)
+
+	expected := map[string]string{
+		"10.0.0.1": "zone-a",
+		"fd00::1":  "zone-a",
+	}
+
+	zones, err := lister.GetEndpointZones("default/coffee-svc")
+	if err != nil {
+		t.Fatalf("GetEndpointZones() returned error %v", err)
+	}
+	if !reflect.DeepEqual(zones, expected) {
+		t.Errorf("GetEndpointZones() returned %v but expected %v", zones, expected)
+	}
+}
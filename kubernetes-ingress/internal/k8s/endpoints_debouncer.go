@@ -0,0 +1,128 @@
+package k8s
+
+import (
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	api_v1 "k8s.io/api/core/v1"
+)
+
+// maxSuppressionPeriods limits the delay of a change of Endpoints, which keep changing, to the number of suppression periods
+// since the first change, so that the upstreams are not stale for as long as the changes last.
+const maxSuppressionPeriods = 2
+
+// endpointsDebouncer delays the sync of changed Endpoints for a suppression period. If the Endpoints change again
+// during the period, the period starts over, but the sync is delayed by no more than maxSuppressionPeriods periods
+// since the first change. When the delay ends and the Endpoints have returned to the state before the first change,
+// the change is a flap -- for example, of a crash-looping pod -- and it is suppressed, so that NGINX is not reloaded.
+type endpointsDebouncer struct {
+	mu      sync.Mutex
+	period  time.Duration
+	pending map[string]*pendingEndpointsChange
+	// getEndpoints returns the current Endpoints for the key
+	getEndpoints func(key string) (*api_v1.Endpoints, bool, error)
+	// enqueue adds the Endpoints to the sync queue
+	enqueue func(endpoints *api_v1.Endpoints)
+	// suppressed is called for every suppressed change
+	suppressed func()
+}
+
+type pendingEndpointsChange struct {
+	// original are the subsets of the Endpoints before the first change of the period
+	original []api_v1.EndpointSubset
+	// deadline is the latest time of the sync of the change
+	deadline time.Time
+	// generation identifies the latest timer, so that a stale timer does nothing
+	generation int
+	timer      *time.Timer
+}
+
+func newEndpointsDebouncer(period time.Duration, getEndpoints func(key string) (*api_v1.Endpoints, bool, error),
+	enqueue func(endpoints *api_v1.Endpoints), suppressed func()) *endpointsDebouncer {
+	return &endpointsDebouncer{
+		period:       period,
+		pending:      make(map[string]*pendingEndpointsChange),
+		getEndpoints: getEndpoints,
+		enqueue:      enqueue,
+		suppressed:   suppressed,
+	}
+}
+
+// Change starts or restarts the suppression period for the changed Endpoints. The restarted period ends
+// no later than the deadline of the first change.
+func (d *endpointsDebouncer) Change(old *api_v1.Endpoints, cur *api_v1.Endpoints) {
+	key, err := keyFunc(cur)
+	if err != nil {
+		glog.V(3).Infof("Couldn't get key for Endpoints %v: %v", cur.Name, err)
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	change, exists := d.pending[key]
+	if !exists {
+		change = &pendingEndpointsChange{
+			original: old.Subsets,
+			deadline: time.Now().Add(maxSuppressionPeriods * d.period),
+		}
+		d.pending[key] = change
+	} else {
+		change.timer.Stop()
+	}
+
+	change.generation++
+	generation := change.generation
+
+	delay := d.period
+	if untilDeadline := time.Until(change.deadline); untilDeadline < delay {
+		delay = untilDeadline
+	}
+
+	change.timer = time.AfterFunc(delay, func() {
+		d.flush(key, generation)
+	})
+}
+
+// Forget drops the pending change of the Endpoints, for example, when they are deleted.
+func (d *endpointsDebouncer) Forget(key string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if change, exists := d.pending[key]; exists {
+		change.timer.Stop()
+		delete(d.pending, key)
+	}
+}
+
+func (d *endpointsDebouncer) flush(key string, generation int) {
+	d.mu.Lock()
+	change, exists := d.pending[key]
+	if !exists || change.generation != generation {
+		d.mu.Unlock()
+		return
+	}
+	delete(d.pending, key)
+	d.mu.Unlock()
+
+	endpoints, exists, err := d.getEndpoints(key)
+	if err != nil {
+		glog.Errorf("Error getting Endpoints %v after the suppression period: %v", key, err)
+		return
+	}
+	if !exists {
+		// the deletion of Endpoints is synced by the handler
+		return
+	}
+
+	if reflect.DeepEqual(endpoints.Subsets, change.original) {
+		glog.V(3).Infof("Suppressed a change of Endpoints %v that was reverted within the suppression period", key)
+		d.suppressed()
+		return
+	}
+
+	glog.V(3).Infof("Endpoints %v changed, syncing after the suppression period", key)
+	d.enqueue(endpoints)
+}
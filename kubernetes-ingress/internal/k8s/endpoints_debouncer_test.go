@@ -0,0 +1,146 @@
+package k8s
+
+import (
+	"testing"
+	"time"
+
+	api_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const testSuppressionPeriod = 20 * time.Millisecond
+
+func createTestEndpoints(ips ...string) *api_v1.Endpoints {
+	var addresses []api_v1.EndpointAddress
+	for _, ip := range ips {
+		addresses = append(addresses, api_v1.EndpointAddress{IP: ip})
+	}
+
+	return &api_v1.Endpoints{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "coffee-svc", Namespace: "default"},
+		Subsets: []api_v1.EndpointSubset{
+			{
+				Addresses: addresses,
+				Ports:     []api_v1.EndpointPort{{Port: 80}},
+			},
+		},
+	}
+}
+
+// createTestEndpointsDebouncer creates a debouncer that reports the enqueued Endpoints and the suppressed changes
+// through the channels. current returns the Endpoints that the debouncer sees at the end of the period.
+func createTestEndpointsDebouncer(current func() *api_v1.Endpoints) (*endpointsDebouncer, chan *api_v1.Endpoints, chan struct{}) {
+	enqueued := make(chan *api_v1.Endpoints, 10)
+	suppressed := make(chan struct{}, 10)
+
+	getEndpoints := func(key string) (*api_v1.Endpoints, bool, error) {
+		endpoints := current()
+		return endpoints, endpoints != nil, nil
+	}
+
+	debouncer := newEndpointsDebouncer(testSuppressionPeriod, getEndpoints,
+		func(endpoints *api_v1.Endpoints) { enqueued <- endpoints },
+		func() { suppressed <- struct{}{} })
+
+	return debouncer, enqueued, suppressed
+}
+
+func TestEndpointsDebouncerSuppressesFlap(t *testing.T) {
+	original := createTestEndpoints("10.0.0.1", "10.0.0.2")
+	flapped := createTestEndpoints("10.0.0.1")
+
+	debouncer, enqueued, suppressed := createTestEndpointsDebouncer(func() *api_v1.Endpoints { return original })
+
+	debouncer.Change(original, flapped)
+	debouncer.Change(flapped, original)
+
+	select {
+	case <-suppressed:
+	case endpoints := <-enqueued:
+		t.Fatalf("endpointsDebouncer enqueued %v for a reverted change", endpoints.Name)
+	case <-time.After(time.Second):
+		t.Fatal("endpointsDebouncer didn't suppress a reverted change")
+	}
+}
+
+func TestEndpointsDebouncerEnqueuesChange(t *testing.T) {
+	original := createTestEndpoints("10.0.0.1")
+	scaled := createTestEndpoints("10.0.0.1", "10.0.0.2")
+	scaledAgain := createTestEndpoints("10.0.0.1", "10.0.0.2", "10.0.0.3")
+
+	debouncer, enqueued, suppressed := createTestEndpointsDebouncer(func() *api_v1.Endpoints { return scaledAgain })
+
+	debouncer.Change(original, scaled)
+	debouncer.Change(scaled, scaledAgain)
+
+	select {
+	case endpoints := <-enqueued:
+		if endpoints != scaledAgain {
+			t.Errorf("endpointsDebouncer enqueued %v but expected the latest Endpoints", endpoints.Subsets)
+		}
+	case <-suppressed:
+		t.Fatal("endpointsDebouncer suppressed a change that wasn't reverted")
+	case <-time.After(time.Second):
+		t.Fatal("endpointsDebouncer didn't enqueue a change")
+	}
+
+	select {
+	case <-enqueued:
+		t.Error("endpointsDebouncer enqueued the Endpoints more than once for the changes within a period")
+	case <-time.After(5 * testSuppressionPeriod):
+	}
+}
+
+func TestEndpointsDebouncerForget(t *testing.T) {
+	original := createTestEndpoints("10.0.0.1")
+	changed := createTestEndpoints("10.0.0.2")
+
+	debouncer, enqueued, suppressed := createTestEndpointsDebouncer(func() *api_v1.Endpoints { return changed })
+
+	debouncer.Change(original, changed)
+	debouncer.Forget("default/coffee-svc")
+
+	select {
+	case <-enqueued:
+		t.Error("endpointsDebouncer enqueued forgotten Endpoints")
+	case <-suppressed:
+		t.Error("endpointsDebouncer suppressed a change of forgotten Endpoints")
+	case <-time.After(5 * testSuppressionPeriod):
+	}
+}
+
+func TestEndpointsDebouncerEnqueuesContinuousChanges(t *testing.T) {
+	original := createTestEndpoints("10.0.0.1")
+	scaled := createTestEndpoints("10.0.0.1", "10.0.0.2")
+
+	debouncer, enqueued, suppressed := createTestEndpointsDebouncer(func() *api_v1.Endpoints { return scaled })
+
+	start := time.Now()
+	done := make(chan struct{})
+	defer close(done)
+
+	// the Endpoints keep changing faster than the period
+	go func() {
+		ticker := time.NewTicker(testSuppressionPeriod / 4)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				debouncer.Change(original, scaled)
+			}
+		}
+	}()
+
+	select {
+	case <-enqueued:
+		if elapsed := time.Since(start); elapsed > 10*testSuppressionPeriod {
+			t.Errorf("endpointsDebouncer enqueued continuously changing Endpoints after %v", elapsed)
+		}
+	case <-suppressed:
+		t.Fatal("endpointsDebouncer suppressed a change that wasn't reverted")
+	case <-time.After(time.Second):
+		t.Fatal("endpointsDebouncer didn't enqueue continuously changing Endpoints")
+	}
+}
@@ -0,0 +1,156 @@
+package k8s
+
+import (
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	api_v1 "k8s.io/api/core/v1"
+)
+
+// endpointsDrainer keeps track of the addresses removed from Endpoints -- for example, of terminating pods -- so that
+// they stay in the upstreams of VirtualServers for the drain delay before they are removed. When the delay ends,
+// the Endpoints are synced again, so that the addresses are removed from the upstreams.
+type endpointsDrainer struct {
+	mu    sync.Mutex
+	delay time.Duration
+	// terminating maps the key of Endpoints to the removed addresses and the times when their delay ends
+	terminating map[string]map[string]time.Time
+	// getEndpoints returns the current Endpoints for the key
+	getEndpoints func(key string) (*api_v1.Endpoints, bool, error)
+	// enqueue adds the Endpoints to the sync queue
+	enqueue func(endpoints *api_v1.Endpoints)
+	now     func() time.Time
+}
+
+func newEndpointsDrainer(delay time.Duration, getEndpoints func(key string) (*api_v1.Endpoints, bool, error),
+	enqueue func(endpoints *api_v1.Endpoints)) *endpointsDrainer {
+	return &endpointsDrainer{
+		delay:        delay,
+		terminating:  make(map[string]map[string]time.Time),
+		getEndpoints: getEndpoints,
+		enqueue:      enqueue,
+		now:          time.Now,
+	}
+}
+
+// Change starts the drain delay for the addresses removed from the Endpoints. The addresses that returned to the
+// Endpoints are no longer terminating.
+func (d *endpointsDrainer) Change(old *api_v1.Endpoints, cur *api_v1.Endpoints) {
+	key, err := keyFunc(cur)
+	if err != nil {
+		glog.V(3).Infof("Couldn't get key for Endpoints %v: %v", cur.Name, err)
+		return
+	}
+
+	oldAddresses := getEndpointsAddresses(old)
+	curAddresses := getEndpointsAddresses(cur)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	terminating := d.terminating[key]
+	for address := range curAddresses {
+		delete(terminating, address)
+	}
+
+	removed := false
+	deadline := d.now().Add(d.delay)
+	for address := range oldAddresses {
+		if curAddresses[address] {
+			continue
+		}
+		if terminating == nil {
+			terminating = make(map[string]time.Time)
+		}
+		terminating[address] = deadline
+		removed = true
+	}
+
+	if len(terminating) == 0 {
+		delete(d.terminating, key)
+		return
+	}
+	d.terminating[key] = terminating
+
+	if removed {
+		time.AfterFunc(d.delay, func() {
+			d.flush(key)
+		})
+	}
+}
+
+// Forget drops the terminating addresses of the Endpoints, for example, when they are deleted.
+func (d *endpointsDrainer) Forget(key string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	delete(d.terminating, key)
+}
+
+// GetTerminatingEndpoints returns the terminating addresses of the Endpoints with the port, whose drain delay
+// hasn't ended yet.
+func (d *endpointsDrainer) GetTerminatingEndpoints(key string, port int32) []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.now()
+	portStr := strconv.Itoa(int(port))
+
+	var endps []string
+	for address, deadline := range d.terminating[key] {
+		if !now.Before(deadline) {
+			continue
+		}
+		if _, p, err := net.SplitHostPort(address); err == nil && p == portStr {
+			endps = append(endps, address)
+		}
+	}
+
+	return endps
+}
+
+func (d *endpointsDrainer) flush(key string) {
+	d.mu.Lock()
+	now := d.now()
+	terminating := d.terminating[key]
+	for address, deadline := range terminating {
+		if !now.Before(deadline) {
+			delete(terminating, address)
+		}
+	}
+	if len(terminating) == 0 {
+		delete(d.terminating, key)
+	}
+	d.mu.Unlock()
+
+	endpoints, exists, err := d.getEndpoints(key)
+	if err != nil {
+		glog.Errorf("Error getting Endpoints %v after the drain delay: %v", key, err)
+		return
+	}
+	if !exists {
+		// the deletion of Endpoints is synced by the handler
+		return
+	}
+
+	glog.V(3).Infof("The drain delay of the removed addresses of Endpoints %v ended, syncing", key)
+	d.enqueue(endpoints)
+}
+
+// getEndpointsAddresses returns the ready addresses of the Endpoints in the ip:port format, with IPv6 addresses in brackets.
+func getEndpointsAddresses(endpoints *api_v1.Endpoints) map[string]bool {
+	addresses := make(map[string]bool)
+
+	for _, subset := range endpoints.Subsets {
+		for _, port := range subset.Ports {
+			for _, address := range subset.Addresses {
+				addresses[net.JoinHostPort(address.IP, strconv.Itoa(int(port.Port)))] = true
+			}
+		}
+	}
+
+	return addresses
+}
@@ -0,0 +1,87 @@
+package k8s
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	api_v1 "k8s.io/api/core/v1"
+)
+
+const testDrainDelay = 20 * time.Millisecond
+
+func createTestEndpointsDrainer(current func() *api_v1.Endpoints) (*endpointsDrainer, chan *api_v1.Endpoints) {
+	enqueued := make(chan *api_v1.Endpoints, 10)
+
+	getEndpoints := func(key string) (*api_v1.Endpoints, bool, error) {
+		endpoints := current()
+		return endpoints, endpoints != nil, nil
+	}
+
+	drainer := newEndpointsDrainer(testDrainDelay, getEndpoints,
+		func(endpoints *api_v1.Endpoints) { enqueued <- endpoints })
+
+	return drainer, enqueued
+}
+
+func TestEndpointsDrainerKeepsRemovedAddresses(t *testing.T) {
+	original := createTestEndpoints("10.0.0.1", "10.0.0.2", "10.0.0.3")
+	scaledDown := createTestEndpoints("10.0.0.1")
+
+	drainer, enqueued := createTestEndpointsDrainer(func() *api_v1.Endpoints { return scaledDown })
+
+	drainer.Change(original, scaledDown)
+
+	expected := []string{"10.0.0.2:80", "10.0.0.3:80"}
+	result := drainer.GetTerminatingEndpoints("default/coffee-svc", 80)
+	sort.Strings(result)
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("GetTerminatingEndpoints() returned %v but expected %v", result, expected)
+	}
+
+	if result := drainer.GetTerminatingEndpoints("default/coffee-svc", 8080); len(result) != 0 {
+		t.Errorf("GetTerminatingEndpoints() returned %v for a port without terminating addresses", result)
+	}
+
+	select {
+	case endpoints := <-enqueued:
+		if endpoints != scaledDown {
+			t.Errorf("endpointsDrainer enqueued %v but expected the current Endpoints", endpoints.Subsets)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("endpointsDrainer didn't enqueue the Endpoints after the drain delay")
+	}
+
+	if result := drainer.GetTerminatingEndpoints("default/coffee-svc", 80); len(result) != 0 {
+		t.Errorf("GetTerminatingEndpoints() returned %v after the drain delay", result)
+	}
+}
+
+func TestEndpointsDrainerForgetsReturnedAddresses(t *testing.T) {
+	original := createTestEndpoints("10.0.0.1", "10.0.0.2")
+	scaledDown := createTestEndpoints("10.0.0.1")
+
+	drainer, _ := createTestEndpointsDrainer(func() *api_v1.Endpoints { return original })
+
+	drainer.Change(original, scaledDown)
+	drainer.Change(scaledDown, original)
+
+	if result := drainer.GetTerminatingEndpoints("default/coffee-svc", 80); len(result) != 0 {
+		t.Errorf("GetTerminatingEndpoints() returned %v for the addresses that returned to the Endpoints", result)
+	}
+}
+
+func TestEndpointsDrainerForget(t *testing.T) {
+	original := createTestEndpoints("10.0.0.1", "10.0.0.2")
+	scaledDown := createTestEndpoints("10.0.0.1")
+
+	drainer, _ := createTestEndpointsDrainer(func() *api_v1.Endpoints { return nil })
+
+	drainer.Change(original, scaledDown)
+	drainer.Forget("default/coffee-svc")
+
+	if result := drainer.GetTerminatingEndpoints("default/coffee-svc", 80); len(result) != 0 {
+		t.Errorf("GetTerminatingEndpoints() returned %v for forgotten Endpoints", result)
+	}
+}
@@ -0,0 +1,121 @@
+package k8s
+
+import (
+	"fmt"
+
+	"github.com/golang/glog"
+	conf_v1 "github.com/nginxinc/kubernetes-ingress/pkg/apis/configuration/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// cleanupFinalizer is the finalizer that the Ingress Controller adds to VirtualServers and VirtualServerRoutes,
+// so that it removes their configuration before Kubernetes deletes them.
+const cleanupFinalizer = "k8s.nginx.org/cleanup"
+
+func hasFinalizer(meta *meta_v1.ObjectMeta, finalizer string) bool {
+	for _, f := range meta.Finalizers {
+		if f == finalizer {
+			return true
+		}
+	}
+	return false
+}
+
+func removeFinalizer(finalizers []string, finalizer string) []string {
+	var result []string
+	for _, f := range finalizers {
+		if f != finalizer {
+			result = append(result, f)
+		}
+	}
+	return result
+}
+
+// addFinalizerEnabled determines if the Ingress Controller adds the finalizer to the resource.
+// With leader election, only the leader adds it.
+func (lbc *LoadBalancerController) addFinalizerEnabled(meta *meta_v1.ObjectMeta) bool {
+	return lbc.enableFinalizers && lbc.confClient != nil && lbc.isLeader() &&
+		meta.DeletionTimestamp == nil && !hasFinalizer(meta, cleanupFinalizer)
+}
+
+// removeFinalizerEnabled determines if the Ingress Controller removes the finalizer from the resource. The finalizer
+// is removed even if finalizers are disabled, so that the resources with the finalizer don't get stuck.
+func (lbc *LoadBalancerController) removeFinalizerEnabled(meta *meta_v1.ObjectMeta) bool {
+	return lbc.confClient != nil && lbc.isLeader() && hasFinalizer(meta, cleanupFinalizer)
+}
+
+// addVirtualServerFinalizer adds the finalizer to the VirtualServer. It returns the updated VirtualServer.
+func (lbc *LoadBalancerController) addVirtualServerFinalizer(vs *conf_v1.VirtualServer) (*conf_v1.VirtualServer, error) {
+	if !lbc.addFinalizerEnabled(&vs.ObjectMeta) {
+		return vs, nil
+	}
+
+	vsCopy := vs.DeepCopy()
+	vsCopy.Finalizers = append(vsCopy.Finalizers, cleanupFinalizer)
+
+	updated, err := lbc.confClient.K8sV1().VirtualServers(vsCopy.Namespace).Update(vsCopy)
+	if err != nil {
+		return vs, fmt.Errorf("error adding the finalizer to VirtualServer %v/%v: %v", vs.Namespace, vs.Name, err)
+	}
+
+	glog.V(3).Infof("Added the finalizer to VirtualServer %v/%v", vs.Namespace, vs.Name)
+
+	return updated, nil
+}
+
+// removeVirtualServerFinalizer removes the finalizer from the VirtualServer, so that Kubernetes can delete it.
+func (lbc *LoadBalancerController) removeVirtualServerFinalizer(vs *conf_v1.VirtualServer) error {
+	if !lbc.removeFinalizerEnabled(&vs.ObjectMeta) {
+		return nil
+	}
+
+	vsCopy := vs.DeepCopy()
+	vsCopy.Finalizers = removeFinalizer(vsCopy.Finalizers, cleanupFinalizer)
+
+	_, err := lbc.confClient.K8sV1().VirtualServers(vsCopy.Namespace).Update(vsCopy)
+	if err != nil {
+		return fmt.Errorf("error removing the finalizer from VirtualServer %v/%v: %v", vs.Namespace, vs.Name, err)
+	}
+
+	glog.V(3).Infof("Removed the finalizer from VirtualServer %v/%v", vs.Namespace, vs.Name)
+
+	return nil
+}
+
+// addVirtualServerRouteFinalizer adds the finalizer to the VirtualServerRoute. It returns the updated VirtualServerRoute.
+func (lbc *LoadBalancerController) addVirtualServerRouteFinalizer(vsr *conf_v1.VirtualServerRoute) (*conf_v1.VirtualServerRoute, error) {
+	if !lbc.addFinalizerEnabled(&vsr.ObjectMeta) {
+		return vsr, nil
+	}
+
+	vsrCopy := vsr.DeepCopy()
+	vsrCopy.Finalizers = append(vsrCopy.Finalizers, cleanupFinalizer)
+
+	updated, err := lbc.confClient.K8sV1().VirtualServerRoutes(vsrCopy.Namespace).Update(vsrCopy)
+	if err != nil {
+		return vsr, fmt.Errorf("error adding the finalizer to VirtualServerRoute %v/%v: %v", vsr.Namespace, vsr.Name, err)
+	}
+
+	glog.V(3).Infof("Added the finalizer to VirtualServerRoute %v/%v", vsr.Namespace, vsr.Name)
+
+	return updated, nil
+}
+
+// removeVirtualServerRouteFinalizer removes the finalizer from the VirtualServerRoute, so that Kubernetes can delete it.
+func (lbc *LoadBalancerController) removeVirtualServerRouteFinalizer(vsr *conf_v1.VirtualServerRoute) error {
+	if !lbc.removeFinalizerEnabled(&vsr.ObjectMeta) {
+		return nil
+	}
+
+	vsrCopy := vsr.DeepCopy()
+	vsrCopy.Finalizers = removeFinalizer(vsrCopy.Finalizers, cleanupFinalizer)
+
+	_, err := lbc.confClient.K8sV1().VirtualServerRoutes(vsrCopy.Namespace).Update(vsrCopy)
+	if err != nil {
+		return fmt.Errorf("error removing the finalizer from VirtualServerRoute %v/%v: %v", vsr.Namespace, vsr.Name, err)
+	}
+
+	glog.V(3).Infof("Removed the finalizer from VirtualServerRoute %v/%v", vsr.Namespace, vsr.Name)
+
+	return nil
+}
@@ -0,0 +1,49 @@
+package k8s
+
+import (
+	"reflect"
+	"testing"
+
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestHasFinalizer(t *testing.T) {
+	meta := &meta_v1.ObjectMeta{
+		Finalizers: []string{"example.com/other", cleanupFinalizer},
+	}
+	if !hasFinalizer(meta, cleanupFinalizer) {
+		t.Errorf("hasFinalizer(%v, %q) returned false", meta.Finalizers, cleanupFinalizer)
+	}
+
+	meta.Finalizers = []string{"example.com/other"}
+	if hasFinalizer(meta, cleanupFinalizer) {
+		t.Errorf("hasFinalizer(%v, %q) returned true", meta.Finalizers, cleanupFinalizer)
+	}
+}
+
+func TestRemoveFinalizer(t *testing.T) {
+	tests := []struct {
+		finalizers []string
+		expected   []string
+	}{
+		{
+			finalizers: []string{cleanupFinalizer},
+			expected:   nil,
+		},
+		{
+			finalizers: []string{"example.com/other", cleanupFinalizer, "example.com/another"},
+			expected:   []string{"example.com/other", "example.com/another"},
+		},
+		{
+			finalizers: []string{"example.com/other"},
+			expected:   []string{"example.com/other"},
+		},
+	}
+
+	for _, test := range tests {
+		result := removeFinalizer(test.finalizers, cleanupFinalizer)
+		if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("removeFinalizer(%v, %q) returned %v but expected %v", test.finalizers, cleanupFinalizer, result, test.expected)
+		}
+	}
+}
@@ -6,9 +6,12 @@ import (
 
 	"github.com/golang/glog"
 	v1 "k8s.io/api/core/v1"
+	discovery "k8s.io/api/discovery/v1alpha1"
 	"k8s.io/api/extensions/v1beta1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/tools/cache"
 
+	"github.com/nginxinc/kubernetes-ingress/internal/configs"
 	conf_v1 "github.com/nginxinc/kubernetes-ingress/pkg/apis/configuration/v1"
 )
 
@@ -76,12 +79,113 @@ func createEndpointHandlers(lbc *LoadBalancerController) cache.ResourceEventHand
 				}
 			}
 			glog.V(3).Infof("Removing endpoints: %v", endpoint.Name)
-			lbc.AddSyncQueue(obj)
+			lbc.deleteEndpoints(endpoint)
 		},
 		UpdateFunc: func(old, cur interface{}) {
 			if !reflect.DeepEqual(old, cur) {
-				glog.V(3).Infof("Endpoints %v changed, syncing", cur.(*v1.Endpoints).Name)
-				lbc.AddSyncQueue(cur)
+				lbc.changeEndpoints(old.(*v1.Endpoints), cur.(*v1.Endpoints))
+			}
+		},
+	}
+}
+
+// createEndpointSliceHandlers builds the handler funcs for endpoint slices. The EndpointSlices of a service are
+// joined into Endpoints, which are synced like the Endpoints resources.
+func createEndpointSliceHandlers(lbc *LoadBalancerController) cache.ResourceEventHandlerFuncs {
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			slice := obj.(*discovery.EndpointSlice)
+			key, ok := getServiceKeyForEndpointSlice(slice)
+			if !ok {
+				return
+			}
+			glog.V(3).Infof("Adding EndpointSlice: %v", slice.Name)
+
+			endpoints, exists, err := lbc.endpointSliceLister.GetEndpointsByKey(key)
+			if err != nil {
+				glog.V(3).Infof("Error getting endpoints of service %v: %v", key, err)
+				return
+			}
+			if exists {
+				lbc.AddSyncQueue(endpoints)
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			slice, isSlice := obj.(*discovery.EndpointSlice)
+			if !isSlice {
+				deletedState, ok := obj.(cache.DeletedFinalStateUnknown)
+				if !ok {
+					glog.V(3).Infof("Error received unexpected object: %v", obj)
+					return
+				}
+				slice, ok = deletedState.Obj.(*discovery.EndpointSlice)
+				if !ok {
+					glog.V(3).Infof("Error DeletedFinalStateUnknown contained non-EndpointSlice object: %v", deletedState.Obj)
+					return
+				}
+			}
+			key, ok := getServiceKeyForEndpointSlice(slice)
+			if !ok {
+				return
+			}
+			glog.V(3).Infof("Removing EndpointSlice: %v", slice.Name)
+
+			endpoints, exists, err := lbc.endpointSliceLister.GetEndpointsByKey(key)
+			if err != nil {
+				glog.V(3).Infof("Error getting endpoints of service %v: %v", key, err)
+				return
+			}
+			if !exists {
+				// the last EndpointSlice of the service was removed
+				lbc.deleteEndpoints(endpointSlicesToEndpoints(key, nil))
+				return
+			}
+
+			previous, err := lbc.endpointSliceLister.GetEndpointsByKeyWithSlice(key, slice)
+			if err != nil {
+				glog.V(3).Infof("Error getting endpoints of service %v: %v", key, err)
+				return
+			}
+			lbc.changeEndpoints(previous, endpoints)
+		},
+		UpdateFunc: func(old, cur interface{}) {
+			if reflect.DeepEqual(old, cur) {
+				return
+			}
+			key, ok := getServiceKeyForEndpointSlice(cur.(*discovery.EndpointSlice))
+			if !ok {
+				return
+			}
+
+			endpoints, exists, err := lbc.endpointSliceLister.GetEndpointsByKey(key)
+			if err != nil || !exists {
+				return
+			}
+
+			previous, err := lbc.endpointSliceLister.GetEndpointsByKeyWithSlice(key, old.(*discovery.EndpointSlice))
+			if err != nil {
+				glog.V(3).Infof("Error getting endpoints of service %v: %v", key, err)
+				return
+			}
+			if !reflect.DeepEqual(previous.Subsets, endpoints.Subsets) {
+				lbc.changeEndpoints(previous, endpoints)
+			}
+		},
+	}
+}
+
+// createPodHandlers builds the handler funcs for pods
+func createPodHandlers(lbc *LoadBalancerController) cache.ResourceEventHandlerFuncs {
+	return cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(old, cur interface{}) {
+			if !lbc.areCustomResourcesEnabled {
+				return
+			}
+			oldPod := old.(*v1.Pod)
+			curPod := cur.(*v1.Pod)
+			if isPodDrained(oldPod) != isPodDrained(curPod) {
+				glog.V(3).Infof("Drain annotation of pod %v changed, syncing", curPod.Name)
+				lbc.EnqueueEndpointsForPod(curPod)
 			}
 		},
 	}
@@ -305,6 +409,49 @@ func hasServicePortChanges(oldServicePorts []v1.ServicePort, curServicePorts []v
 	return false
 }
 
+// isVirtualServerStatusOrFinalizersUpdate checks if the only changes of the VirtualServer are its status or its finalizers,
+// which the Ingress Controller updates itself and which don't affect the configuration.
+func isVirtualServerStatusOrFinalizersUpdate(oldVs, curVs *conf_v1.VirtualServer) bool {
+	if reflect.DeepEqual(oldVs.Status, curVs.Status) && reflect.DeepEqual(oldVs.Finalizers, curVs.Finalizers) {
+		return false
+	}
+
+	oldCopy := oldVs.DeepCopy()
+	curCopy := curVs.DeepCopy()
+
+	oldCopy.Status = conf_v1.VirtualServerStatus{}
+	curCopy.Status = conf_v1.VirtualServerStatus{}
+	clearSelfUpdatedMeta(&oldCopy.ObjectMeta)
+	clearSelfUpdatedMeta(&curCopy.ObjectMeta)
+
+	return reflect.DeepEqual(oldCopy, curCopy)
+}
+
+// isVirtualServerRouteStatusOrFinalizersUpdate checks if the only changes of the VirtualServerRoute are its status
+// or its finalizers.
+func isVirtualServerRouteStatusOrFinalizersUpdate(oldVsr, curVsr *conf_v1.VirtualServerRoute) bool {
+	if oldVsr.Status == curVsr.Status && reflect.DeepEqual(oldVsr.Finalizers, curVsr.Finalizers) {
+		return false
+	}
+
+	oldCopy := oldVsr.DeepCopy()
+	curCopy := curVsr.DeepCopy()
+
+	oldCopy.Status = conf_v1.VirtualServerRouteStatus{}
+	curCopy.Status = conf_v1.VirtualServerRouteStatus{}
+	clearSelfUpdatedMeta(&oldCopy.ObjectMeta)
+	clearSelfUpdatedMeta(&curCopy.ObjectMeta)
+
+	return reflect.DeepEqual(oldCopy, curCopy)
+}
+
+// clearSelfUpdatedMeta clears the metadata that changes when the Ingress Controller updates the status or the finalizers.
+func clearSelfUpdatedMeta(meta *meta_v1.ObjectMeta) {
+	meta.ResourceVersion = ""
+	meta.ManagedFields = nil
+	meta.Finalizers = nil
+}
+
 func createVirtualServerHandlers(lbc *LoadBalancerController) cache.ResourceEventHandlerFuncs {
 	return cache.ResourceEventHandlerFuncs{
 		AddFunc: func(obj interface{}) {
@@ -328,13 +475,33 @@ func createVirtualServerHandlers(lbc *LoadBalancerController) cache.ResourceEven
 			}
 			glog.V(3).Infof("Removing VirtualServer: %v", vs.Name)
 			lbc.AddSyncQueue(vs)
+
+			if vs.Spec.Listener != nil {
+				lbc.enqueueVirtualServersWithListener(vs)
+			}
+
+			lbc.enqueueVirtualServersWithHost(vs, vs.Spec.Host)
+			lbc.enqueueVirtualServersWithSafeNsName(vs)
 		},
 		UpdateFunc: func(old, cur interface{}) {
 			curVs := cur.(*conf_v1.VirtualServer)
-			if !reflect.DeepEqual(old, cur) {
+			oldVs := old.(*conf_v1.VirtualServer)
+			if oldVs.Annotations[configs.RegenerateAnnotation] != curVs.Annotations[configs.RegenerateAnnotation] {
+				glog.V(3).Infof("VirtualServer %v requested a regeneration of its config", curVs.Name)
+			}
+			if !reflect.DeepEqual(old, cur) && !isVirtualServerStatusOrFinalizersUpdate(oldVs, curVs) {
 				glog.V(3).Infof("VirtualServer %v changed, syncing", curVs.Name)
 				lbc.AddSyncQueue(curVs)
 			}
+
+			if !reflect.DeepEqual(oldVs.Spec.Listener, curVs.Spec.Listener) {
+				lbc.enqueueVirtualServersWithListener(curVs)
+			}
+
+			if oldVs.Spec.Host != curVs.Spec.Host {
+				lbc.enqueueVirtualServersWithHost(curVs, oldVs.Spec.Host)
+				lbc.enqueueVirtualServersWithHost(curVs, curVs.Spec.Host)
+			}
 		},
 	}
 }
@@ -365,10 +532,77 @@ func createVirtualServerRouteHandlers(lbc *LoadBalancerController) cache.Resourc
 		},
 		UpdateFunc: func(old, cur interface{}) {
 			curVsr := cur.(*conf_v1.VirtualServerRoute)
-			if !reflect.DeepEqual(old, cur) {
+			oldVsr := old.(*conf_v1.VirtualServerRoute)
+			if !reflect.DeepEqual(old, cur) && !isVirtualServerRouteStatusOrFinalizersUpdate(oldVsr, curVsr) {
 				glog.V(3).Infof("VirtualServerRoute %v changed, syncing", curVsr.Name)
 				lbc.AddSyncQueue(curVsr)
 			}
 		},
 	}
 }
+
+func createPolicyHandlers(lbc *LoadBalancerController) cache.ResourceEventHandlerFuncs {
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			pol := obj.(*conf_v1.Policy)
+			glog.V(3).Infof("Adding Policy: %v", pol.Name)
+			lbc.AddSyncQueue(pol)
+		},
+		DeleteFunc: func(obj interface{}) {
+			pol, isPol := obj.(*conf_v1.Policy)
+			if !isPol {
+				deletedState, ok := obj.(cache.DeletedFinalStateUnknown)
+				if !ok {
+					glog.V(3).Infof("Error received unexpected object: %v", obj)
+					return
+				}
+				pol, ok = deletedState.Obj.(*conf_v1.Policy)
+				if !ok {
+					glog.V(3).Infof("Error DeletedFinalStateUnknown contained non-Policy object: %v", deletedState.Obj)
+					return
+				}
+			}
+			glog.V(3).Infof("Removing Policy: %v", pol.Name)
+			lbc.AddSyncQueue(pol)
+		},
+		UpdateFunc: func(old, cur interface{}) {
+			curPol := cur.(*conf_v1.Policy)
+			if !reflect.DeepEqual(old, cur) {
+				glog.V(3).Infof("Policy %v changed, syncing", curPol.Name)
+				lbc.AddSyncQueue(curPol)
+			}
+		},
+	}
+}
+
+// createContentConfigMapHandlers builds the handler funcs for the ConfigMaps with the content served by
+// VirtualServers and VirtualServerRoutes
+func createContentConfigMapHandlers(lbc *LoadBalancerController) cache.ResourceEventHandlerFuncs {
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			configMap := obj.(*v1.ConfigMap)
+			lbc.enqueueVirtualServersForConfigMap(configMap)
+		},
+		DeleteFunc: func(obj interface{}) {
+			configMap, isConfigMap := obj.(*v1.ConfigMap)
+			if !isConfigMap {
+				deletedState, ok := obj.(cache.DeletedFinalStateUnknown)
+				if !ok {
+					glog.V(3).Infof("Error received unexpected object: %v", obj)
+					return
+				}
+				configMap, ok = deletedState.Obj.(*v1.ConfigMap)
+				if !ok {
+					glog.V(3).Infof("Error DeletedFinalStateUnknown contained non-ConfigMap object: %v", deletedState.Obj)
+					return
+				}
+			}
+			lbc.enqueueVirtualServersForConfigMap(configMap)
+		},
+		UpdateFunc: func(old, cur interface{}) {
+			if !reflect.DeepEqual(old.(*v1.ConfigMap).Data, cur.(*v1.ConfigMap).Data) {
+				lbc.enqueueVirtualServersForConfigMap(cur.(*v1.ConfigMap))
+			}
+		},
+	}
+}
@@ -3,7 +3,9 @@ package k8s
 import (
 	"testing"
 
+	conf_v1 "github.com/nginxinc/kubernetes-ingress/pkg/apis/configuration/v1"
 	v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
@@ -154,3 +156,126 @@ func TestHasServicePortChanges(t *testing.T) {
 		}
 	}
 }
+
+func TestIsVirtualServerStatusOrFinalizersUpdate(t *testing.T) {
+	vs := &conf_v1.VirtualServer{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:            "cafe",
+			Namespace:       "default",
+			ResourceVersion: "1",
+		},
+		Spec: conf_v1.VirtualServerSpec{
+			Host: "cafe.example.com",
+		},
+	}
+
+	statusUpdate := vs.DeepCopy()
+	statusUpdate.ResourceVersion = "2"
+	statusUpdate.Status.Conditions = []conf_v1.VirtualServerCondition{{Type: conditionTypeRoutesResolved, Status: "True"}}
+
+	specUpdate := statusUpdate.DeepCopy()
+	specUpdate.Spec.Host = "tea.example.com"
+
+	labelsUpdate := vs.DeepCopy()
+	labelsUpdate.ResourceVersion = "2"
+	labelsUpdate.Labels = map[string]string{"app": "cafe"}
+
+	finalizersUpdate := vs.DeepCopy()
+	finalizersUpdate.ResourceVersion = "2"
+	finalizersUpdate.Finalizers = []string{cleanupFinalizer}
+
+	deletionUpdate := finalizersUpdate.DeepCopy()
+	deletionUpdate.ResourceVersion = "3"
+	deletionUpdate.DeletionTimestamp = &meta_v1.Time{}
+
+	cases := []struct {
+		cur    *conf_v1.VirtualServer
+		result bool
+		reason string
+	}{
+		{
+			statusUpdate,
+			true,
+			"Only the status changed",
+		},
+		{
+			specUpdate,
+			false,
+			"The status and the spec changed",
+		},
+		{
+			labelsUpdate,
+			false,
+			"The labels changed",
+		},
+		{
+			finalizersUpdate,
+			true,
+			"Only the finalizers changed",
+		},
+		{
+			deletionUpdate,
+			false,
+			"The deletion timestamp changed",
+		},
+	}
+
+	for _, c := range cases {
+		if result := isVirtualServerStatusOrFinalizersUpdate(vs, c.cur); result != c.result {
+			t.Errorf("isVirtualServerStatusOrFinalizersUpdate() returned %v but expected %v for the case of %s", result, c.result, c.reason)
+		}
+	}
+}
+
+func TestIsVirtualServerRouteStatusOrFinalizersUpdate(t *testing.T) {
+	vsr := &conf_v1.VirtualServerRoute{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:            "coffee",
+			Namespace:       "default",
+			ResourceVersion: "1",
+		},
+		Spec: conf_v1.VirtualServerRouteSpec{
+			Host: "cafe.example.com",
+		},
+	}
+
+	statusUpdate := vsr.DeepCopy()
+	statusUpdate.ResourceVersion = "2"
+	statusUpdate.Status.State = stateValid
+	statusUpdate.Status.ReferencedBy = "default/cafe"
+
+	finalizersUpdate := vsr.DeepCopy()
+	finalizersUpdate.ResourceVersion = "2"
+	finalizersUpdate.Finalizers = []string{cleanupFinalizer}
+
+	specUpdate := statusUpdate.DeepCopy()
+	specUpdate.Spec.Host = "tea.example.com"
+
+	cases := []struct {
+		cur    *conf_v1.VirtualServerRoute
+		result bool
+		reason string
+	}{
+		{
+			statusUpdate,
+			true,
+			"Only the status changed",
+		},
+		{
+			finalizersUpdate,
+			true,
+			"Only the finalizers changed",
+		},
+		{
+			specUpdate,
+			false,
+			"The status and the spec changed",
+		},
+	}
+
+	for _, c := range cases {
+		if result := isVirtualServerRouteStatusOrFinalizersUpdate(vsr, c.cur); result != c.result {
+			t.Errorf("isVirtualServerRouteStatusOrFinalizersUpdate() returned %v but expected %v for the case of %s", result, c.result, c.reason)
+		}
+	}
+}
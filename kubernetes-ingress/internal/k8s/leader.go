@@ -9,6 +9,7 @@ import (
 
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/tools/leaderelection"
@@ -49,11 +50,17 @@ func newLeaderElector(client kubernetes.Interface, callbacks leaderelection.Lead
 func createLeaderHandler(lbc *LoadBalancerController) leaderelection.LeaderCallbacks {
 	return leaderelection.LeaderCallbacks{
 		OnStartedLeading: func(ctx context.Context) {
-			glog.V(3).Info("started leading, updating ingress status")
-			ingresses, mergeableIngresses := lbc.GetManagedIngresses()
-			err := lbc.UpdateManagedAndMergeableIngresses(ingresses, mergeableIngresses)
-			if err != nil {
-				glog.V(3).Infof("error updating status when starting leading: %v", err)
+			if lbc.reportIngressStatus {
+				glog.V(3).Info("started leading, updating ingress status")
+				ingresses, mergeableIngresses := lbc.GetManagedIngresses()
+				err := lbc.UpdateManagedAndMergeableIngresses(ingresses, mergeableIngresses)
+				if err != nil {
+					glog.V(3).Infof("error updating status when starting leading: %v", err)
+				}
+			}
+			if lbc.areCustomResourcesEnabled {
+				glog.V(3).Info("started leading, updating the status of VirtualServers and VirtualServerRoutes")
+				lbc.updateStatusesFromRecords()
 			}
 		},
 		OnStoppedLeading: func() {
@@ -61,3 +68,41 @@ func createLeaderHandler(lbc *LoadBalancerController) leaderelection.LeaderCallb
 		},
 	}
 }
+
+// leaderEventRecorder emits events only when the Ingress Controller is the leader, so that the replicas of
+// the Ingress Controller don't emit the same events.
+type leaderEventRecorder struct {
+	record.EventRecorder
+	isLeader func() bool
+}
+
+func newLeaderEventRecorder(recorder record.EventRecorder, isLeader func() bool) *leaderEventRecorder {
+	return &leaderEventRecorder{
+		EventRecorder: recorder,
+		isLeader:      isLeader,
+	}
+}
+
+func (r *leaderEventRecorder) Event(object runtime.Object, eventtype, reason, message string) {
+	if r.isLeader() {
+		r.EventRecorder.Event(object, eventtype, reason, message)
+	}
+}
+
+func (r *leaderEventRecorder) Eventf(object runtime.Object, eventtype, reason, messageFmt string, args ...interface{}) {
+	if r.isLeader() {
+		r.EventRecorder.Eventf(object, eventtype, reason, messageFmt, args...)
+	}
+}
+
+func (r *leaderEventRecorder) PastEventf(object runtime.Object, timestamp metav1.Time, eventtype, reason, messageFmt string, args ...interface{}) {
+	if r.isLeader() {
+		r.EventRecorder.PastEventf(object, timestamp, eventtype, reason, messageFmt, args...)
+	}
+}
+
+func (r *leaderEventRecorder) AnnotatedEventf(object runtime.Object, annotations map[string]string, eventtype, reason, messageFmt string, args ...interface{}) {
+	if r.isLeader() {
+		r.EventRecorder.AnnotatedEventf(object, annotations, eventtype, reason, messageFmt, args...)
+	}
+}
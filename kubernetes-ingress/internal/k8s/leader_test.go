@@ -0,0 +1,43 @@
+package k8s
+
+import (
+	"testing"
+
+	conf_v1 "github.com/nginxinc/kubernetes-ingress/pkg/apis/configuration/v1"
+	api_v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestLeaderEventRecorder(t *testing.T) {
+	fakeRecorder := record.NewFakeRecorder(10)
+	isLeader := false
+
+	recorder := newLeaderEventRecorder(fakeRecorder, func() bool { return isLeader })
+	vs := &conf_v1.VirtualServer{}
+
+	recorder.Event(vs, api_v1.EventTypeNormal, "AddedOrUpdated", "Configuration for default/cafe was added or updated")
+	recorder.Eventf(vs, api_v1.EventTypeWarning, "Rejected", "VirtualServer %v is invalid and was rejected", "default/cafe")
+	if len(fakeRecorder.Events) != 0 {
+		t.Errorf("leaderEventRecorder emitted %d events when not the leader", len(fakeRecorder.Events))
+	}
+
+	isLeader = true
+
+	recorder.Event(vs, api_v1.EventTypeNormal, "AddedOrUpdated", "Configuration for default/cafe was added or updated")
+	recorder.Eventf(vs, api_v1.EventTypeWarning, "Rejected", "VirtualServer %v is invalid and was rejected", "default/cafe")
+
+	expected := []string{
+		"Normal AddedOrUpdated Configuration for default/cafe was added or updated",
+		"Warning Rejected VirtualServer default/cafe is invalid and was rejected",
+	}
+	for _, e := range expected {
+		select {
+		case event := <-fakeRecorder.Events:
+			if event != e {
+				t.Errorf("leaderEventRecorder emitted the event %q but expected %q", event, e)
+			}
+		default:
+			t.Errorf("leaderEventRecorder didn't emit the event %q when the leader", e)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package k8s
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/golang/glog"
+	conf_v1 "github.com/nginxinc/kubernetes-ingress/pkg/apis/configuration/v1"
+)
+
+// resyncEndpoint is the path of the endpoint of the resync listener.
+const resyncEndpoint = "/resync"
+
+// ResyncNamespace enqueues the resync of the Ingress, VirtualServer and VirtualServerRoute resources of the namespace,
+// so that their configs are re-rendered and NGINX is reloaded. It returns the number of resources to resync.
+func (lbc *LoadBalancerController) ResyncNamespace(namespace string) int {
+	count := len(lbc.getNamespaceResources(namespace))
+
+	// the regeneration is forced by the sync of the task, because the configurator is only accessed by the sync queue
+	lbc.syncQueue.EnqueueTask(task{Kind: namespaceResync, Key: namespace})
+	glog.V(3).Infof("Resyncing %d resources in namespace %s", count, namespace)
+
+	return count
+}
+
+// syncNamespaceResync forces the regeneration of the configs of the resources of the namespace and enqueues the resources.
+func (lbc *LoadBalancerController) syncNamespaceResync(task task) {
+	namespace := task.Key
+	lbc.configurator.ForceRegeneration(namespace)
+
+	for _, obj := range lbc.getNamespaceResources(namespace) {
+		lbc.syncQueue.Enqueue(obj)
+	}
+}
+
+// getNamespaceResources returns the Ingress, VirtualServer and VirtualServerRoute resources of the namespace.
+func (lbc *LoadBalancerController) getNamespaceResources(namespace string) []interface{} {
+	var resources []interface{}
+
+	ings, _ := lbc.ingressLister.List()
+	for i := range ings.Items {
+		ing := &ings.Items[i]
+		if ing.Namespace != namespace || !lbc.IsNginxIngress(ing) {
+			continue
+		}
+
+		resources = append(resources, ing)
+	}
+
+	if !lbc.areCustomResourcesEnabled {
+		return resources
+	}
+
+	for _, obj := range lbc.virtualServerLister.List() {
+		vs := obj.(*conf_v1.VirtualServer)
+		if vs.Namespace != namespace {
+			continue
+		}
+
+		resources = append(resources, vs)
+	}
+
+	for _, obj := range lbc.virtualServerRouteLister.List() {
+		vsr := obj.(*conf_v1.VirtualServerRoute)
+		if vsr.Namespace != namespace {
+			continue
+		}
+
+		resources = append(resources, vsr)
+	}
+
+	return resources
+}
+
+// RunResyncListener runs an http server on the IP address and the port that resyncs the resources of a namespace
+// on POST /resync?namespace=<namespace>. The endpoint doesn't authenticate the requests, so the IP address
+// must not be reachable by untrusted clients.
+func RunResyncListener(ip string, port int, lbc *LoadBalancerController) {
+	mux := http.NewServeMux()
+	mux.Handle(resyncEndpoint, newResyncHandler(lbc))
+
+	address := net.JoinHostPort(ip, strconv.Itoa(port))
+	glog.Infof("Starting resync listener on: %v%v", address, resyncEndpoint)
+	glog.Fatal("Error in resync listener server: ", http.ListenAndServe(address, mux))
+}
+
+type namespaceResyncer interface {
+	ResyncNamespace(namespace string) int
+}
+
+func newResyncHandler(resyncer namespaceResyncer) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		namespace := r.URL.Query().Get("namespace")
+		if namespace == "" {
+			http.Error(w, "the namespace parameter is required", http.StatusBadRequest)
+			return
+		}
+
+		count := resyncer.ResyncNamespace(namespace)
+
+		_, err := fmt.Fprintf(w, "Resyncing %d resources in namespace %s\n", count, namespace)
+		if err != nil {
+			glog.Warningf("Error while sending a response for the %v path: %v", resyncEndpoint, err)
+		}
+	})
+}
@@ -0,0 +1,158 @@
+package k8s
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nginxinc/kubernetes-ingress/internal/configs"
+	"github.com/nginxinc/kubernetes-ingress/internal/configs/version1"
+	"github.com/nginxinc/kubernetes-ingress/internal/configs/version2"
+	"github.com/nginxinc/kubernetes-ingress/internal/metrics/collectors"
+	"github.com/nginxinc/kubernetes-ingress/internal/nginx"
+	conf_v1 "github.com/nginxinc/kubernetes-ingress/pkg/apis/configuration/v1"
+	"k8s.io/api/extensions/v1beta1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestResyncNamespace(t *testing.T) {
+	ingLister := storeToIngressLister{Store: cache.NewStore(cache.MetaNamespaceKeyFunc)}
+	vsLister := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	vsrLister := cache.NewStore(cache.MetaNamespaceKeyFunc)
+
+	objects := []struct {
+		store cache.Store
+		obj   interface{}
+	}{
+		{
+			store: ingLister.Store,
+			obj: &v1beta1.Ingress{
+				ObjectMeta: meta_v1.ObjectMeta{Name: "cafe", Namespace: "default"},
+			},
+		},
+		{
+			store: ingLister.Store,
+			obj: &v1beta1.Ingress{
+				ObjectMeta: meta_v1.ObjectMeta{
+					Name:        "cafe-other-class",
+					Namespace:   "default",
+					Annotations: map[string]string{ingressClassKey: "gce"},
+				},
+			},
+		},
+		{
+			store: ingLister.Store,
+			obj: &v1beta1.Ingress{
+				ObjectMeta: meta_v1.ObjectMeta{Name: "cafe", Namespace: "tea"},
+			},
+		},
+		{
+			store: vsLister,
+			obj: &conf_v1.VirtualServer{
+				ObjectMeta: meta_v1.ObjectMeta{Name: "cafe", Namespace: "default"},
+			},
+		},
+		{
+			store: vsLister,
+			obj: &conf_v1.VirtualServer{
+				ObjectMeta: meta_v1.ObjectMeta{Name: "cafe", Namespace: "tea"},
+			},
+		},
+		{
+			store: vsrLister,
+			obj: &conf_v1.VirtualServerRoute{
+				ObjectMeta: meta_v1.ObjectMeta{Name: "coffee", Namespace: "default"},
+			},
+		},
+	}
+
+	for _, o := range objects {
+		err := o.store.Add(o.obj)
+		if err != nil {
+			t.Fatalf("Failed to add an object to the store: %v", err)
+		}
+	}
+
+	cnf := configs.NewConfigurator(nginx.NewFakeManager("/etc/nginx"), &configs.StaticConfigParams{}, &configs.ConfigParams{}, &version1.TemplateExecutor{}, &version2.TemplateExecutor{}, false, false, collectors.NewConfigFakeCollector())
+	lbc := LoadBalancerController{
+		ingressClass:              "nginx",
+		ingressLister:             ingLister,
+		virtualServerLister:       vsLister,
+		virtualServerRouteLister:  vsrLister,
+		areCustomResourcesEnabled: true,
+		configurator:              cnf,
+		syncQueue:                 newTaskQueue(func(task) {}),
+	}
+
+	expected := 3
+
+	result := lbc.ResyncNamespace("default")
+	if result != expected {
+		t.Errorf("ResyncNamespace() returned %d but expected %d", result, expected)
+	}
+	if lbc.syncQueue.queue.Len() != 1 {
+		t.Fatalf("ResyncNamespace() enqueued %d tasks but expected the resync task", lbc.syncQueue.queue.Len())
+	}
+
+	lbc.syncNamespaceResync(task{Kind: namespaceResync, Key: "default"})
+	if lbc.syncQueue.queue.Len() != 1+expected {
+		t.Errorf("syncNamespaceResync() enqueued %d resources but expected %d", lbc.syncQueue.queue.Len()-1, expected)
+	}
+}
+
+type fakeResyncer struct {
+	namespace string
+}
+
+func (r *fakeResyncer) ResyncNamespace(namespace string) int {
+	r.namespace = namespace
+	return 2
+}
+
+func TestResyncHandler(t *testing.T) {
+	tests := []struct {
+		method            string
+		target            string
+		expectedCode      int
+		expectedNamespace string
+		msg               string
+	}{
+		{
+			method:            http.MethodPost,
+			target:            "/resync?namespace=default",
+			expectedCode:      http.StatusOK,
+			expectedNamespace: "default",
+			msg:               "valid request",
+		},
+		{
+			method:            http.MethodGet,
+			target:            "/resync?namespace=default",
+			expectedCode:      http.StatusMethodNotAllowed,
+			expectedNamespace: "",
+			msg:               "unsupported method",
+		},
+		{
+			method:            http.MethodPost,
+			target:            "/resync",
+			expectedCode:      http.StatusBadRequest,
+			expectedNamespace: "",
+			msg:               "missing namespace",
+		},
+	}
+
+	for _, test := range tests {
+		resyncer := &fakeResyncer{}
+		handler := newResyncHandler(resyncer)
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(test.method, test.target, nil))
+
+		if rec.Code != test.expectedCode {
+			t.Errorf("resync handler returned code %d but expected %d for the case of %s", rec.Code, test.expectedCode, test.msg)
+		}
+		if resyncer.namespace != test.expectedNamespace {
+			t.Errorf("resync handler resynced namespace %q but expected %q for the case of %s", resyncer.namespace, test.expectedNamespace, test.msg)
+		}
+	}
+}
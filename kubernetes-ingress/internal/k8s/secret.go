@@ -2,6 +2,7 @@ package k8s
 
 import (
 	"fmt"
+	"strings"
 
 	v1 "k8s.io/api/core/v1"
 )
@@ -9,11 +10,26 @@ import (
 // JWTKeyKey is the key of the data field of a Secret where the JWK must be stored.
 const JWTKeyKey = "jwk"
 
+// ClientSecretKey is the key of the data field of a Secret where the OIDC client secret must be stored.
+const ClientSecretKey = "client-secret"
+
+// CAKey is the key of the data field of a Secret where the certificate authority must be stored.
+const CAKey = "ca.crt"
+
+// HtpasswdKey is the key of the data field of a Secret where the htpasswd file must be stored.
+const HtpasswdKey = "htpasswd"
+
 const (
 	// TLS Secret
 	TLS = iota
 	// JWK Secret
 	JWK
+	// OIDC Secret
+	OIDC
+	// CA Secret
+	CA
+	// Htpasswd Secret
+	Htpasswd
 )
 
 // ValidateTLSSecret validates the secret. If it is valid, the function returns nil.
@@ -38,6 +54,39 @@ func ValidateJWKSecret(secret *v1.Secret) error {
 	return nil
 }
 
+// ValidateOIDCSecret validates the secret. If it is valid, the function returns nil.
+func ValidateOIDCSecret(secret *v1.Secret) error {
+	clientSecret, exists := secret.Data[ClientSecretKey]
+	if !exists {
+		return fmt.Errorf("Secret doesn't have %v", ClientSecretKey)
+	}
+
+	// the client secret is included into the NGINX config as a quoted string
+	if strings.ContainsAny(string(clientSecret), "\"\\$ \t\r\n") {
+		return fmt.Errorf("%v must not contain whitespace, '\"', '\\' or '$'", ClientSecretKey)
+	}
+
+	return nil
+}
+
+// ValidateCASecret validates the secret. If it is valid, the function returns nil.
+func ValidateCASecret(secret *v1.Secret) error {
+	if _, exists := secret.Data[CAKey]; !exists {
+		return fmt.Errorf("Secret doesn't have %v", CAKey)
+	}
+
+	return nil
+}
+
+// ValidateHtpasswdSecret validates the secret. If it is valid, the function returns nil.
+func ValidateHtpasswdSecret(secret *v1.Secret) error {
+	if _, exists := secret.Data[HtpasswdKey]; !exists {
+		return fmt.Errorf("Secret doesn't have %v", HtpasswdKey)
+	}
+
+	return nil
+}
+
 // GetSecretKind returns the kind of the Secret.
 func GetSecretKind(secret *v1.Secret) (int, error) {
 	if err := ValidateTLSSecret(secret); err == nil {
@@ -46,6 +95,15 @@ func GetSecretKind(secret *v1.Secret) (int, error) {
 	if err := ValidateJWKSecret(secret); err == nil {
 		return JWK, nil
 	}
+	if err := ValidateOIDCSecret(secret); err == nil {
+		return OIDC, nil
+	}
+	if err := ValidateCASecret(secret); err == nil {
+		return CA, nil
+	}
+	if err := ValidateHtpasswdSecret(secret); err == nil {
+		return Htpasswd, nil
+	}
 
 	return 0, fmt.Errorf("Unknown Secret")
 }
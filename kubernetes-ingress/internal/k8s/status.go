@@ -4,9 +4,12 @@ import (
 	"fmt"
 	"net"
 	"reflect"
+	"strconv"
+	"strings"
 
 	"github.com/golang/glog"
 	"github.com/nginxinc/kubernetes-ingress/internal/configs"
+	conf_v1 "github.com/nginxinc/kubernetes-ingress/pkg/apis/configuration/v1"
 	api_v1 "k8s.io/api/core/v1"
 	"k8s.io/api/extensions/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -23,6 +26,7 @@ type statusUpdater struct {
 	externalServiceName      string
 	externalStatusAddress    string
 	externalServiceAddresses []string
+	externalServicePorts     string
 	status                   []api_v1.LoadBalancerIngress
 	keyFunc                  func(obj interface{}) (string, error)
 	ingLister                *storeToIngressLister
@@ -179,6 +183,37 @@ func getExternalServiceAddress(svc *api_v1.Service) []string {
 	return addresses
 }
 
+func getExternalServicePorts(svc *api_v1.Service) string {
+	if svc == nil {
+		return ""
+	}
+
+	var ports []string
+	for _, port := range svc.Spec.Ports {
+		ports = append(ports, strconv.Itoa(int(port.Port)))
+	}
+
+	if len(ports) == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("[%v]", strings.Join(ports, ","))
+}
+
+// GetExternalEndpoints returns the external endpoints for the status of VirtualServers,
+// based on the saved status and the ports of the external service.
+func (su *statusUpdater) GetExternalEndpoints() []conf_v1.ExternalEndpoint {
+	var endpoints []conf_v1.ExternalEndpoint
+	for _, ing := range su.status {
+		endpoints = append(endpoints, conf_v1.ExternalEndpoint{
+			IP:       ing.IP,
+			Hostname: ing.Hostname,
+			Ports:    su.externalServicePorts,
+		})
+	}
+	return endpoints
+}
+
 // SaveStatusFromExternalStatus saves the status from a string.
 // For use with the external-status-address ConfigMap setting.
 // This method does not update ingress status - statusUpdater.UpdateIngressStatus must be called separately.
@@ -207,6 +242,7 @@ func (su *statusUpdater) ClearStatusFromExternalService() {
 func (su *statusUpdater) SaveStatusFromExternalService(svc *api_v1.Service) {
 	ips := getExternalServiceAddress(svc)
 	su.externalServiceAddresses = ips
+	su.externalServicePorts = getExternalServicePorts(svc)
 	if su.externalStatusAddress != "" {
 		glog.V(3).Info("skipping external service address - external-status-address is set and takes precedence")
 		return
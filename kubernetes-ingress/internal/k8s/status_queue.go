@@ -0,0 +1,97 @@
+package k8s
+
+import (
+	"time"
+
+	"github.com/golang/glog"
+	"golang.org/x/time/rate"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/workqueue"
+)
+
+const (
+	// statusRetryBaseDelay is the delay before the first retry of a failed status update.
+	// The delay doubles with every failure of the same resource up to statusRetryMaxDelay.
+	statusRetryBaseDelay = 100 * time.Millisecond
+	statusRetryMaxDelay  = 5 * time.Minute
+	// statusMaxRetries is the number of times the queue retries a failed status update before dropping it.
+	statusMaxRetries = 15
+	// statusRetryQPS and statusRetryBurst limit the overall rate of retries, so that
+	// a burst of failures, like conflicts after a resync of a large cluster, doesn't overload the API.
+	statusRetryQPS   = 10
+	statusRetryBurst = 100
+)
+
+// statusQueue manages a work queue of status updates of resources through an independent worker.
+// The queue coalesces the updates of the same resource: while a resource is waiting in the queue,
+// enqueuing it again has no effect, so the sync function must write the latest status of the resource.
+// Failed updates are retried with an exponential backoff.
+type statusQueue struct {
+	// queue is the work queue the worker polls
+	queue workqueue.RateLimitingInterface
+	// sync is called for each item in the queue
+	sync func(task) error
+	// workerDone is closed when the worker exits
+	workerDone chan struct{}
+}
+
+// newStatusQueue creates a new status queue with the given sync function.
+func newStatusQueue(syncFn func(task) error) *statusQueue {
+	rateLimiter := workqueue.NewMaxOfRateLimiter(
+		workqueue.NewItemExponentialFailureRateLimiter(statusRetryBaseDelay, statusRetryMaxDelay),
+		&workqueue.BucketRateLimiter{Limiter: rate.NewLimiter(rate.Limit(statusRetryQPS), statusRetryBurst)},
+	)
+
+	return &statusQueue{
+		queue:      workqueue.NewRateLimitingQueue(rateLimiter),
+		sync:       syncFn,
+		workerDone: make(chan struct{}),
+	}
+}
+
+// Run begins running the worker for the given duration
+func (sq *statusQueue) Run(period time.Duration, stopCh <-chan struct{}) {
+	wait.Until(sq.worker, period, stopCh)
+}
+
+// Enqueue adds the status update of the resource of the kind with the key to the queue.
+func (sq *statusQueue) Enqueue(k kind, key string) {
+	glog.V(3).Infof("Adding a status update with a key: %v", key)
+	sq.queue.Add(task{Kind: k, Key: key})
+}
+
+// worker processes the status updates in the queue through sync.
+func (sq *statusQueue) worker() {
+	for {
+		t, quit := sq.queue.Get()
+		if quit {
+			close(sq.workerDone)
+			return
+		}
+		sq.process(t.(task))
+		sq.queue.Done(t)
+	}
+}
+
+func (sq *statusQueue) process(t task) {
+	err := sq.sync(t)
+	if err == nil {
+		sq.queue.Forget(t)
+		return
+	}
+
+	if sq.queue.NumRequeues(t) >= statusMaxRetries {
+		glog.Errorf("Dropping the status update of %v after %d retries: %v", t.Key, statusMaxRetries, err)
+		sq.queue.Forget(t)
+		return
+	}
+
+	glog.Warningf("Retrying the status update of %v: %v", t.Key, err)
+	sq.queue.AddRateLimited(t)
+}
+
+// Shutdown shuts down the work queue and waits for the worker to ACK
+func (sq *statusQueue) Shutdown() {
+	sq.queue.ShutDown()
+	<-sq.workerDone
+}
@@ -0,0 +1,67 @@
+package k8s
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestStatusQueueProcess(t *testing.T) {
+	failures := 2
+	calls := 0
+
+	sq := newStatusQueue(func(task) error {
+		calls++
+		if calls <= failures {
+			return errors.New("conflict")
+		}
+		return nil
+	})
+	defer sq.queue.ShutDown()
+
+	vsTask := task{Kind: virtualserver, Key: "default/cafe"}
+
+	for i := 0; i <= failures; i++ {
+		sq.process(vsTask)
+	}
+
+	if calls != failures+1 {
+		t.Errorf("process() called sync %d times but expected %d", calls, failures+1)
+	}
+	if requeues := sq.queue.NumRequeues(vsTask); requeues != 0 {
+		t.Errorf("process() left %d requeues after a successful sync but expected 0", requeues)
+	}
+}
+
+func TestStatusQueueProcessDropsAfterMaxRetries(t *testing.T) {
+	sq := newStatusQueue(func(task) error {
+		return errors.New("conflict")
+	})
+	defer sq.queue.ShutDown()
+
+	vsTask := task{Kind: virtualserver, Key: "default/cafe"}
+
+	for i := 0; i < statusMaxRetries; i++ {
+		sq.process(vsTask)
+	}
+	if requeues := sq.queue.NumRequeues(vsTask); requeues != statusMaxRetries {
+		t.Errorf("process() left %d requeues but expected %d", requeues, statusMaxRetries)
+	}
+
+	sq.process(vsTask)
+	if requeues := sq.queue.NumRequeues(vsTask); requeues != 0 {
+		t.Errorf("process() left %d requeues after dropping the task but expected 0", requeues)
+	}
+}
+
+func TestStatusQueueEnqueueCoalesces(t *testing.T) {
+	sq := newStatusQueue(func(task) error { return nil })
+	defer sq.queue.ShutDown()
+
+	sq.Enqueue(virtualserver, "default/cafe")
+	sq.Enqueue(virtualserver, "default/cafe")
+	sq.Enqueue(virtualServerRoute, "default/cafe")
+
+	if length := sq.queue.Len(); length != 2 {
+		t.Errorf("Enqueue() resulted in %d items in the queue but expected 2", length)
+	}
+}
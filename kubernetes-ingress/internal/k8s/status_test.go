@@ -1,8 +1,10 @@
 package k8s
 
 import (
+	"reflect"
 	"testing"
 
+	conf_v1 "github.com/nginxinc/kubernetes-ingress/pkg/apis/configuration/v1"
 	v1 "k8s.io/api/core/v1"
 	extensions "k8s.io/api/extensions/v1beta1"
 	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -120,3 +122,57 @@ func checkStatus(expected string, actual extensions.Ingress) bool {
 	}
 	return expected == actual.Status.LoadBalancer.Ingress[0].IP
 }
+
+func TestGetExternalEndpoints(t *testing.T) {
+	svc := v1.Service{
+		Spec: v1.ServiceSpec{
+			Ports: []v1.ServicePort{
+				{
+					Name: "http",
+					Port: 80,
+				},
+				{
+					Name: "https",
+					Port: 443,
+				},
+			},
+		},
+		Status: v1.ServiceStatus{
+			LoadBalancer: v1.LoadBalancerStatus{
+				Ingress: []v1.LoadBalancerIngress{
+					{
+						IP: "2.2.2.2",
+					},
+					{
+						Hostname: "lb.example.com",
+					},
+				},
+			},
+		},
+	}
+
+	su := statusUpdater{}
+
+	endpoints := su.GetExternalEndpoints()
+	if len(endpoints) != 0 {
+		t.Errorf("GetExternalEndpoints() returned %v but expected no endpoints without a saved status", endpoints)
+	}
+
+	su.SaveStatusFromExternalService(&svc)
+
+	expected := []conf_v1.ExternalEndpoint{
+		{
+			IP:    "2.2.2.2",
+			Ports: "[80,443]",
+		},
+		{
+			Hostname: "lb.example.com",
+			Ports:    "[80,443]",
+		},
+	}
+
+	endpoints = su.GetExternalEndpoints()
+	if !reflect.DeepEqual(endpoints, expected) {
+		t.Errorf("GetExternalEndpoints() returned %v but expected %v", endpoints, expected)
+	}
+}
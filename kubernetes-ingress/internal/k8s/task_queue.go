@@ -57,6 +57,13 @@ func (tq *taskQueue) Enqueue(obj interface{}) {
 	tq.queue.Add(task)
 }
 
+// EnqueueTask enqueues a task that is not created from an api object.
+func (tq *taskQueue) EnqueueTask(t task) {
+	glog.V(3).Infof("Adding an element with a key: %v", t.Key)
+
+	tq.queue.Add(t)
+}
+
 // Requeue adds the task to the queue again and logs the given error
 func (tq *taskQueue) Requeue(task task, err error) {
 	glog.Errorf("Requeuing %v, err %v", task.Key, err)
@@ -112,6 +119,12 @@ const (
 	virtualserver
 	// virtualServeRoute resource
 	virtualServerRoute
+	// policy resource
+	policy
+	// virtualServerTemplate is the file of the template of VirtualServers
+	virtualServerTemplate
+	// namespaceResync is a resync of the resources of a namespace
+	namespaceResync
 )
 
 // task is an element of a taskQueue
@@ -143,6 +156,8 @@ func newTask(key string, obj interface{}) (task, error) {
 		k = virtualserver
 	case *conf_v1.VirtualServerRoute:
 		k = virtualServerRoute
+	case *conf_v1.Policy:
+		k = policy
 	default:
 		return task{}, fmt.Errorf("Unknow type: %v", t)
 	}
@@ -0,0 +1,49 @@
+package k8s
+
+import (
+	"bytes"
+	"io/ioutil"
+
+	"github.com/golang/glog"
+)
+
+// virtualServerTemplateWatcher detects the changes of the file of the template of VirtualServers, for example,
+// when the ConfigMap mounted into the container is updated.
+type virtualServerTemplateWatcher struct {
+	path    string
+	content []byte
+	// changed is called when the content of the file changes
+	changed func()
+}
+
+// newVirtualServerTemplateWatcher creates a watcher of the template file. The current content of the file
+// is considered in use, so that only the later changes are reported.
+func newVirtualServerTemplateWatcher(path string, changed func()) *virtualServerTemplateWatcher {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		glog.Warningf("Error reading the virtualserver template %v: %v", path, err)
+	}
+
+	return &virtualServerTemplateWatcher{
+		path:    path,
+		content: content,
+		changed: changed,
+	}
+}
+
+// check reads the file and calls changed if the content of the file changed since the last check.
+func (w *virtualServerTemplateWatcher) check() {
+	content, err := ioutil.ReadFile(w.path)
+	if err != nil {
+		// the file can be missing for a moment while the mounted ConfigMap is updated
+		glog.V(3).Infof("Error reading the virtualserver template %v: %v", w.path, err)
+		return
+	}
+
+	if bytes.Equal(content, w.content) {
+		return
+	}
+
+	w.content = content
+	w.changed()
+}
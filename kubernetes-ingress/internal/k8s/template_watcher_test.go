@@ -0,0 +1,47 @@
+package k8s
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestVirtualServerTemplateWatcher(t *testing.T) {
+	file, err := ioutil.TempFile("", "virtualserver.tmpl")
+	if err != nil {
+		t.Fatalf("Failed to create a template file: %v", err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	if _, err := file.WriteString("server {}"); err != nil {
+		t.Fatalf("Failed to write a template file: %v", err)
+	}
+
+	changes := 0
+	watcher := newVirtualServerTemplateWatcher(file.Name(), func() { changes++ })
+
+	watcher.check()
+	if changes != 0 {
+		t.Errorf("virtualServerTemplateWatcher reported %d changes of an unchanged template, but expected 0", changes)
+	}
+
+	if err := ioutil.WriteFile(file.Name(), []byte("server { listen 80; }"), 0644); err != nil {
+		t.Fatalf("Failed to update a template file: %v", err)
+	}
+
+	watcher.check()
+	watcher.check()
+	if changes != 1 {
+		t.Errorf("virtualServerTemplateWatcher reported %d changes of a template changed once, but expected 1", changes)
+	}
+
+	if err := os.Remove(file.Name()); err != nil {
+		t.Fatalf("Failed to remove a template file: %v", err)
+	}
+
+	watcher.check()
+	if changes != 1 {
+		t.Errorf("virtualServerTemplateWatcher reported a change of a missing template")
+	}
+}
@@ -0,0 +1,502 @@
+package k8s
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/golang/glog"
+	"github.com/nginxinc/kubernetes-ingress/internal/configs"
+	conf_v1 "github.com/nginxinc/kubernetes-ingress/pkg/apis/configuration/v1"
+	"github.com/nginxinc/kubernetes-ingress/pkg/apis/configuration/validation"
+	api_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+const (
+	// conditionTypeRoutesResolved reports if all VirtualServerRoutes referenced by a VirtualServer exist and are valid for it.
+	conditionTypeRoutesResolved = "RoutesResolved"
+
+	reasonRoutesResolved    = "Resolved"
+	reasonInvalidReferences = "InvalidReferences"
+
+	// conditionTypeWarnings reports if the configuration of a VirtualServer and its VirtualServerRoutes has warnings.
+	conditionTypeWarnings = "Warnings"
+
+	reasonNoWarnings            = "NoWarnings"
+	reasonConfigurationWarnings = "ConfigurationWarnings"
+	reasonNotGenerated          = "NotGenerated"
+)
+
+const (
+	// stateValid means the VirtualServer was accepted and its configuration was applied without warnings.
+	stateValid = "Valid"
+	// stateWarning means the configuration of the VirtualServer was applied with warnings.
+	stateWarning = "Warning"
+	// stateInvalid means the VirtualServer was rejected or its configuration could not be applied.
+	stateInvalid = "Invalid"
+)
+
+// virtualServerState is the state of a VirtualServer reported in its status.
+type virtualServerState struct {
+	State   string
+	Reason  string
+	Message string
+}
+
+// validateVirtualServerRouteReferences resolves the VirtualServerRoutes referenced by the VirtualServer
+// and validates them for the VirtualServer.
+func (lbc *LoadBalancerController) validateVirtualServerRouteReferences(vs *conf_v1.VirtualServer) field.ErrorList {
+	getVirtualServerRoute := func(key string) (*conf_v1.VirtualServerRoute, bool) {
+		obj, exists, err := lbc.virtualServerRouteLister.GetByKey(key)
+		if err != nil || !exists {
+			return nil, false
+		}
+		vsr := obj.(*conf_v1.VirtualServerRoute)
+		// a VirtualServerRoute that is being deleted is no longer used by the VirtualServer
+		return vsr, vsr.DeletionTimestamp == nil
+	}
+
+	return validation.ValidateVirtualServerRouteReferences(vs, getVirtualServerRoute, lbc.isNginxPlus, lbc.emulateQueue, lbc.allowedVariables, lbc.validationStrictness)
+}
+
+// newRoutesResolvedCondition creates the RoutesResolved condition for the errors of the VirtualServerRoute references.
+func newRoutesResolvedCondition(referenceErrs field.ErrorList) conf_v1.VirtualServerCondition {
+	if len(referenceErrs) == 0 {
+		return conf_v1.VirtualServerCondition{
+			Type:    conditionTypeRoutesResolved,
+			Status:  string(api_v1.ConditionTrue),
+			Reason:  reasonRoutesResolved,
+			Message: "All referenced VirtualServerRoutes exist and are valid",
+		}
+	}
+
+	var messages []string
+	for _, err := range referenceErrs {
+		messages = append(messages, err.Error())
+	}
+
+	return conf_v1.VirtualServerCondition{
+		Type:    conditionTypeRoutesResolved,
+		Status:  string(api_v1.ConditionFalse),
+		Reason:  reasonInvalidReferences,
+		Message: strings.Join(messages, "; "),
+	}
+}
+
+// newWarningsCondition creates the Warnings condition that combines the warnings of the VirtualServer and
+// its VirtualServerRoutes. Every warning is prefixed with the resource it was reported for.
+func newWarningsCondition(vsEx *configs.VirtualServerEx, warnings configs.Warnings) conf_v1.VirtualServerCondition {
+	var messages []string
+
+	add := func(kind string, obj runtime.Object, namespace string, name string) {
+		for _, w := range warnings[obj] {
+			messages = append(messages, fmt.Sprintf("%s %s/%s: [%s] %s", kind, namespace, name, w.Severity, w.Message))
+		}
+	}
+
+	add("VirtualServer", vsEx.VirtualServer, vsEx.VirtualServer.Namespace, vsEx.VirtualServer.Name)
+	for _, vsr := range vsEx.VirtualServerRoutes {
+		add("VirtualServerRoute", vsr, vsr.Namespace, vsr.Name)
+	}
+
+	if len(messages) == 0 {
+		return conf_v1.VirtualServerCondition{
+			Type:    conditionTypeWarnings,
+			Status:  string(api_v1.ConditionFalse),
+			Reason:  reasonNoWarnings,
+			Message: "The configuration of the VirtualServer and its VirtualServerRoutes has no warnings",
+		}
+	}
+
+	return conf_v1.VirtualServerCondition{
+		Type:    conditionTypeWarnings,
+		Status:  string(api_v1.ConditionTrue),
+		Reason:  reasonConfigurationWarnings,
+		Message: strings.Join(messages, "; "),
+	}
+}
+
+// newNotGeneratedWarningsCondition creates the Warnings condition for a VirtualServer whose configuration
+// wasn't generated, because the VirtualServer is invalid.
+func newNotGeneratedWarningsCondition() conf_v1.VirtualServerCondition {
+	return conf_v1.VirtualServerCondition{
+		Type:    conditionTypeWarnings,
+		Status:  string(api_v1.ConditionUnknown),
+		Reason:  reasonNotGenerated,
+		Message: "The configuration of the VirtualServer was not generated",
+	}
+}
+
+// setVirtualServerCondition adds the condition to the conditions or replaces the condition of the same type.
+// The last transition time is kept if the status of the condition didn't change. It returns false if the conditions
+// already include the condition.
+func setVirtualServerCondition(conditions []conf_v1.VirtualServerCondition, condition conf_v1.VirtualServerCondition,
+	now meta_v1.Time) ([]conf_v1.VirtualServerCondition, bool) {
+	for i, c := range conditions {
+		if c.Type != condition.Type {
+			continue
+		}
+
+		if c.Status == condition.Status {
+			if c.Reason == condition.Reason && c.Message == condition.Message {
+				return conditions, false
+			}
+			condition.LastTransitionTime = c.LastTransitionTime
+		} else {
+			condition.LastTransitionTime = now
+		}
+
+		result := append([]conf_v1.VirtualServerCondition{}, conditions...)
+		result[i] = condition
+
+		return result, true
+	}
+
+	condition.LastTransitionTime = now
+
+	return append(conditions, condition), true
+}
+
+// virtualServerStatusRecord is the status the Ingress Controller determined for a VirtualServer.
+type virtualServerStatusRecord struct {
+	state      virtualServerState
+	conditions []conf_v1.VirtualServerCondition
+}
+
+// virtualServerRouteStatusRecord is the status the Ingress Controller determined for a VirtualServerRoute.
+type virtualServerRouteStatusRecord struct {
+	state        virtualServerState
+	referencedBy string
+	// generation is the generation of the VirtualServerRoute the status was determined for
+	generation int64
+}
+
+// statusRecords stores the statuses of VirtualServers and VirtualServerRoutes by their keys, including the statuses
+// that a replica doesn't write because it is not the leader. When the replica becomes the leader, it writes them.
+// It also stores the external endpoints of the Ingress Controller, because the status queue worker reads the records
+// concurrently with the sync of the resources.
+// The zero value is ready to use.
+type statusRecords struct {
+	mu                  sync.Mutex
+	virtualServers      map[string]virtualServerStatusRecord
+	virtualServerRoutes map[string]virtualServerRouteStatusRecord
+	externalEndpoints   []conf_v1.ExternalEndpoint
+}
+
+func (r *statusRecords) setExternalEndpoints(endpoints []conf_v1.ExternalEndpoint) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.externalEndpoints = endpoints
+}
+
+func (r *statusRecords) getExternalEndpoints() []conf_v1.ExternalEndpoint {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.externalEndpoints
+}
+
+func (r *statusRecords) virtualServerKeys() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var keys []string
+	for key := range r.virtualServers {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func (r *statusRecords) virtualServerRouteKeys() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var keys []string
+	for key := range r.virtualServerRoutes {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func (r *statusRecords) setVirtualServer(key string, record virtualServerStatusRecord) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.virtualServers == nil {
+		r.virtualServers = make(map[string]virtualServerStatusRecord)
+	}
+	r.virtualServers[key] = record
+}
+
+func (r *statusRecords) getVirtualServer(key string) (virtualServerStatusRecord, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	record, exists := r.virtualServers[key]
+	return record, exists
+}
+
+func (r *statusRecords) deleteVirtualServer(key string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.virtualServers, key)
+}
+
+func (r *statusRecords) setVirtualServerRoute(key string, record virtualServerRouteStatusRecord) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.virtualServerRoutes == nil {
+		r.virtualServerRoutes = make(map[string]virtualServerRouteStatusRecord)
+	}
+	r.virtualServerRoutes[key] = record
+}
+
+func (r *statusRecords) getVirtualServerRoute(key string) (virtualServerRouteStatusRecord, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	record, exists := r.virtualServerRoutes[key]
+	return record, exists
+}
+
+func (r *statusRecords) deleteVirtualServerRoute(key string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.virtualServerRoutes, key)
+}
+
+// virtualServerStatusEnabled determines if the Ingress Controller writes the status of VirtualServers.
+// With leader election, only the leader writes it.
+func (lbc *LoadBalancerController) virtualServerStatusEnabled() bool {
+	return lbc.confClient != nil && lbc.isLeader()
+}
+
+// newVirtualServerStatus returns the status of the VirtualServer with the state, the external endpoints and the conditions.
+// It returns false if the status of the VirtualServer already matches.
+func newVirtualServerStatus(vs *conf_v1.VirtualServer, state virtualServerState, endpoints []conf_v1.ExternalEndpoint,
+	newConditions []conf_v1.VirtualServerCondition, now meta_v1.Time) (conf_v1.VirtualServerStatus, bool) {
+	conditions := vs.Status.Conditions
+	conditionChanged := false
+	for _, condition := range newConditions {
+		var changed bool
+		conditions, changed = setVirtualServerCondition(conditions, condition, now)
+		conditionChanged = conditionChanged || changed
+	}
+
+	status := conf_v1.VirtualServerStatus{
+		State:             state.State,
+		Reason:            state.Reason,
+		Message:           state.Message,
+		ExternalEndpoints: endpoints,
+		Conditions:        conditions,
+	}
+
+	changed := conditionChanged ||
+		status.State != vs.Status.State ||
+		status.Reason != vs.Status.Reason ||
+		status.Message != vs.Status.Message ||
+		!reflect.DeepEqual(status.ExternalEndpoints, vs.Status.ExternalEndpoints)
+
+	return status, changed
+}
+
+// updateVirtualServerStatus records the state and the conditions of the VirtualServer and enqueues
+// the update of its status.
+func (lbc *LoadBalancerController) updateVirtualServerStatus(vs *conf_v1.VirtualServer, state virtualServerState,
+	conditions ...conf_v1.VirtualServerCondition) {
+	key := fmt.Sprintf("%s/%s", vs.Namespace, vs.Name)
+	lbc.statusRecords.setVirtualServer(key, virtualServerStatusRecord{state: state, conditions: conditions})
+
+	if lbc.virtualServerStatusEnabled() {
+		lbc.statusQueue.Enqueue(virtualserver, key)
+	}
+}
+
+// updateVirtualServersExternalEndpoints records the external endpoints of the Ingress Controller and enqueues
+// the update of the status of the VirtualServers that were already processed by the Ingress Controller.
+func (lbc *LoadBalancerController) updateVirtualServersExternalEndpoints() {
+	if !lbc.areCustomResourcesEnabled {
+		return
+	}
+
+	endpoints := lbc.statusUpdater.GetExternalEndpoints()
+	if reflect.DeepEqual(endpoints, lbc.statusRecords.getExternalEndpoints()) {
+		return
+	}
+	lbc.statusRecords.setExternalEndpoints(endpoints)
+
+	if !lbc.virtualServerStatusEnabled() {
+		return
+	}
+
+	for _, key := range lbc.statusRecords.virtualServerKeys() {
+		lbc.statusQueue.Enqueue(virtualserver, key)
+	}
+}
+
+// updateVirtualServerRouteStatus records the state and the referencing VirtualServer of the VirtualServerRoute
+// and enqueues the update of its status.
+func (lbc *LoadBalancerController) updateVirtualServerRouteStatus(vsr *conf_v1.VirtualServerRoute, state virtualServerState,
+	referencedBy string) {
+	key := fmt.Sprintf("%s/%s", vsr.Namespace, vsr.Name)
+	lbc.statusRecords.setVirtualServerRoute(key, virtualServerRouteStatusRecord{state: state, referencedBy: referencedBy, generation: vsr.Generation})
+
+	if lbc.virtualServerStatusEnabled() {
+		lbc.statusQueue.Enqueue(virtualServerRoute, key)
+	}
+}
+
+// isVirtualServerRouteStatusRecorded returns true if the same state and the same referencing VirtualServer were already recorded
+// for the current generation of the VirtualServerRoute. A VirtualServer reports only the VirtualServerRoutes that changed
+// or that got different warnings, so that the events and the status updates scale with the changes rather than
+// with the number of the VirtualServerRoutes of the VirtualServer.
+func (lbc *LoadBalancerController) isVirtualServerRouteStatusRecorded(vsr *conf_v1.VirtualServerRoute, state virtualServerState,
+	referencedBy string) bool {
+	record, exists := lbc.statusRecords.getVirtualServerRoute(fmt.Sprintf("%s/%s", vsr.Namespace, vsr.Name))
+	return exists && record.generation == vsr.Generation && record.state == state && record.referencedBy == referencedBy
+}
+
+// syncStatus writes the recorded status of the VirtualServer or VirtualServerRoute of the task.
+// It is called by the worker of the status queue, which retries the task if syncStatus returns an error.
+func (lbc *LoadBalancerController) syncStatus(task task) error {
+	// a replica that lost the leadership drops its updates. The new leader writes the recorded statuses.
+	if !lbc.virtualServerStatusEnabled() {
+		return nil
+	}
+
+	switch task.Kind {
+	case virtualserver:
+		return lbc.writeVirtualServerStatus(task.Key)
+	case virtualServerRoute:
+		return lbc.writeVirtualServerRouteStatus(task.Key)
+	}
+
+	return nil
+}
+
+// writeVirtualServerStatus writes the recorded state, the external endpoints and the conditions in the status of
+// the VirtualServer, if they changed.
+func (lbc *LoadBalancerController) writeVirtualServerStatus(key string) error {
+	record, exists := lbc.statusRecords.getVirtualServer(key)
+	if !exists {
+		return nil
+	}
+
+	obj, exists, err := lbc.virtualServerLister.GetByKey(key)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return nil
+	}
+	vs := obj.(*conf_v1.VirtualServer)
+
+	status, changed := newVirtualServerStatus(vs, record.state, lbc.statusRecords.getExternalEndpoints(), record.conditions, meta_v1.Now())
+	if !changed {
+		return nil
+	}
+
+	vsCopy := vs.DeepCopy()
+	vsCopy.Status = status
+
+	_, err = lbc.confClient.K8sV1().VirtualServers(vsCopy.Namespace).UpdateStatus(vsCopy)
+	if err != nil {
+		return fmt.Errorf("error updating the status of VirtualServer %v: %v", key, err)
+	}
+
+	glog.V(3).Infof("Updated the status of VirtualServer %v to %v (%v)", key, record.state.State, record.state.Reason)
+
+	return nil
+}
+
+// writeVirtualServerRouteStatus writes the recorded state and the referencing VirtualServer in the status of
+// the VirtualServerRoute, if they changed.
+func (lbc *LoadBalancerController) writeVirtualServerRouteStatus(key string) error {
+	record, exists := lbc.statusRecords.getVirtualServerRoute(key)
+	if !exists {
+		return nil
+	}
+
+	obj, exists, err := lbc.virtualServerRouteLister.GetByKey(key)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return nil
+	}
+	vsr := obj.(*conf_v1.VirtualServerRoute)
+
+	status := conf_v1.VirtualServerRouteStatus{
+		State:        record.state.State,
+		Reason:       record.state.Reason,
+		Message:      record.state.Message,
+		ReferencedBy: record.referencedBy,
+	}
+	if status == vsr.Status {
+		return nil
+	}
+
+	vsrCopy := vsr.DeepCopy()
+	vsrCopy.Status = status
+
+	_, err = lbc.confClient.K8sV1().VirtualServerRoutes(vsrCopy.Namespace).UpdateStatus(vsrCopy)
+	if err != nil {
+		return fmt.Errorf("error updating the status of VirtualServerRoute %v: %v", key, err)
+	}
+
+	glog.V(3).Infof("Updated the status of VirtualServerRoute %v to %v (%v)", key, record.state.State, record.state.Reason)
+
+	return nil
+}
+
+// findVirtualServerRoutesReferencedBy finds the VirtualServerRoutes whose status reports that they are referenced by
+// the VirtualServer with the key.
+func findVirtualServerRoutesReferencedBy(virtualServerRoutes []*conf_v1.VirtualServerRoute, vsKey string) []*conf_v1.VirtualServerRoute {
+	var result []*conf_v1.VirtualServerRoute
+
+	for _, vsr := range virtualServerRoutes {
+		if vsr.Status.ReferencedBy == vsKey {
+			result = append(result, vsr)
+		}
+	}
+
+	return result
+}
+
+// clearVirtualServerRoutesReferences updates the status of the VirtualServerRoutes that were referenced by
+// the deleted VirtualServer with the key.
+func (lbc *LoadBalancerController) clearVirtualServerRoutesReferences(vsKey string) {
+	var virtualServerRoutes []*conf_v1.VirtualServerRoute
+	for _, obj := range lbc.virtualServerRouteLister.List() {
+		virtualServerRoutes = append(virtualServerRoutes, obj.(*conf_v1.VirtualServerRoute))
+	}
+
+	for _, vsr := range findVirtualServerRoutesReferencedBy(virtualServerRoutes, vsKey) {
+		state := virtualServerState{
+			State:   stateWarning,
+			Reason:  "NoVirtualServersFound",
+			Message: fmt.Sprintf("VirtualServer %v was deleted", vsKey),
+		}
+
+		lbc.updateVirtualServerRouteStatus(vsr, state, "")
+	}
+}
+
+// updateStatusesFromRecords enqueues the updates of the recorded statuses of the VirtualServers and VirtualServerRoutes.
+func (lbc *LoadBalancerController) updateStatusesFromRecords() {
+	for _, key := range lbc.statusRecords.virtualServerKeys() {
+		lbc.statusQueue.Enqueue(virtualserver, key)
+	}
+
+	for _, key := range lbc.statusRecords.virtualServerRouteKeys() {
+		lbc.statusQueue.Enqueue(virtualServerRoute, key)
+	}
+}
@@ -0,0 +1,558 @@
+package k8s
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/nginxinc/kubernetes-ingress/internal/configs"
+	conf_v1 "github.com/nginxinc/kubernetes-ingress/pkg/apis/configuration/v1"
+	conf_fake "github.com/nginxinc/kubernetes-ingress/pkg/client/clientset/versioned/fake"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestNewRoutesResolvedCondition(t *testing.T) {
+	condition := newRoutesResolvedCondition(nil)
+	if condition.Status != "True" || condition.Reason != reasonRoutesResolved {
+		t.Errorf("newRoutesResolvedCondition(nil) returned %v but expected a True condition with reason %v", condition, reasonRoutesResolved)
+	}
+
+	referenceErrs := field.ErrorList{
+		field.NotFound(field.NewPath("spec").Child("routes").Index(0).Child("route"), "default/coffee"),
+		field.NotFound(field.NewPath("spec").Child("routes").Index(1).Child("route"), "default/tea"),
+	}
+	expectedMessage := `spec.routes[0].route: Not found: "default/coffee"; spec.routes[1].route: Not found: "default/tea"`
+
+	condition = newRoutesResolvedCondition(referenceErrs)
+	if condition.Status != "False" || condition.Reason != reasonInvalidReferences || condition.Message != expectedMessage {
+		t.Errorf("newRoutesResolvedCondition(%v) returned %v but expected a False condition with reason %v and message %q",
+			referenceErrs, condition, reasonInvalidReferences, expectedMessage)
+	}
+}
+
+func TestSetVirtualServerCondition(t *testing.T) {
+	before := meta_v1.NewTime(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	now := meta_v1.NewTime(time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC))
+
+	resolved := conf_v1.VirtualServerCondition{
+		Type:               conditionTypeRoutesResolved,
+		Status:             "True",
+		Reason:             reasonRoutesResolved,
+		LastTransitionTime: before,
+	}
+	other := conf_v1.VirtualServerCondition{
+		Type:               "Other",
+		Status:             "True",
+		LastTransitionTime: before,
+	}
+
+	tests := []struct {
+		conditions      []conf_v1.VirtualServerCondition
+		condition       conf_v1.VirtualServerCondition
+		expected        []conf_v1.VirtualServerCondition
+		expectedChanged bool
+		msg             string
+	}{
+		{
+			conditions: nil,
+			condition: conf_v1.VirtualServerCondition{
+				Type:   conditionTypeRoutesResolved,
+				Status: "True",
+				Reason: reasonRoutesResolved,
+			},
+			expected: []conf_v1.VirtualServerCondition{
+				{
+					Type:               conditionTypeRoutesResolved,
+					Status:             "True",
+					Reason:             reasonRoutesResolved,
+					LastTransitionTime: now,
+				},
+			},
+			expectedChanged: true,
+			msg:             "new condition",
+		},
+		{
+			conditions: []conf_v1.VirtualServerCondition{other, resolved},
+			condition: conf_v1.VirtualServerCondition{
+				Type:   conditionTypeRoutesResolved,
+				Status: "True",
+				Reason: reasonRoutesResolved,
+			},
+			expected:        []conf_v1.VirtualServerCondition{other, resolved},
+			expectedChanged: false,
+			msg:             "same condition",
+		},
+		{
+			conditions: []conf_v1.VirtualServerCondition{other, resolved},
+			condition: conf_v1.VirtualServerCondition{
+				Type:    conditionTypeRoutesResolved,
+				Status:  "True",
+				Reason:  reasonRoutesResolved,
+				Message: "updated",
+			},
+			expected: []conf_v1.VirtualServerCondition{
+				other,
+				{
+					Type:               conditionTypeRoutesResolved,
+					Status:             "True",
+					Reason:             reasonRoutesResolved,
+					Message:            "updated",
+					LastTransitionTime: before,
+				},
+			},
+			expectedChanged: true,
+			msg:             "same status with a different message",
+		},
+		{
+			conditions: []conf_v1.VirtualServerCondition{other, resolved},
+			condition: conf_v1.VirtualServerCondition{
+				Type:   conditionTypeRoutesResolved,
+				Status: "False",
+				Reason: reasonInvalidReferences,
+			},
+			expected: []conf_v1.VirtualServerCondition{
+				other,
+				{
+					Type:               conditionTypeRoutesResolved,
+					Status:             "False",
+					Reason:             reasonInvalidReferences,
+					LastTransitionTime: now,
+				},
+			},
+			expectedChanged: true,
+			msg:             "different status",
+		},
+	}
+
+	for _, test := range tests {
+		result, changed := setVirtualServerCondition(test.conditions, test.condition, now)
+		if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("setVirtualServerCondition() returned %v but expected %v for the case of %s", result, test.expected, test.msg)
+		}
+		if changed != test.expectedChanged {
+			t.Errorf("setVirtualServerCondition() returned changed %v but expected %v for the case of %s", changed, test.expectedChanged, test.msg)
+		}
+	}
+
+	conditions := []conf_v1.VirtualServerCondition{resolved}
+	setVirtualServerCondition(conditions, conf_v1.VirtualServerCondition{Type: conditionTypeRoutesResolved, Status: "False"}, now)
+	if !reflect.DeepEqual(conditions[0], resolved) {
+		t.Errorf("setVirtualServerCondition() modified the conditions of the VirtualServer")
+	}
+}
+
+func TestNewVirtualServerStatus(t *testing.T) {
+	before := meta_v1.NewTime(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	now := meta_v1.NewTime(time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC))
+
+	resolved := conf_v1.VirtualServerCondition{
+		Type:    conditionTypeRoutesResolved,
+		Status:  "True",
+		Reason:  reasonRoutesResolved,
+		Message: "All referenced VirtualServerRoutes exist and are valid",
+	}
+	resolvedBefore := resolved
+	resolvedBefore.LastTransitionTime = before
+
+	valid := virtualServerState{
+		State:   stateValid,
+		Reason:  "AddedOrUpdated",
+		Message: "Configuration for default/cafe was added or updated",
+	}
+	endpoints := []conf_v1.ExternalEndpoint{
+		{
+			IP:    "10.0.0.1",
+			Ports: "[80,443]",
+		},
+	}
+
+	validStatus := conf_v1.VirtualServerStatus{
+		State:             stateValid,
+		Reason:            "AddedOrUpdated",
+		Message:           "Configuration for default/cafe was added or updated",
+		ExternalEndpoints: endpoints,
+		Conditions:        []conf_v1.VirtualServerCondition{resolvedBefore},
+	}
+
+	tests := []struct {
+		status          conf_v1.VirtualServerStatus
+		state           virtualServerState
+		endpoints       []conf_v1.ExternalEndpoint
+		expected        conf_v1.VirtualServerStatus
+		expectedChanged bool
+		msg             string
+	}{
+		{
+			status:    conf_v1.VirtualServerStatus{},
+			state:     valid,
+			endpoints: endpoints,
+			expected: conf_v1.VirtualServerStatus{
+				State:             stateValid,
+				Reason:            "AddedOrUpdated",
+				Message:           "Configuration for default/cafe was added or updated",
+				ExternalEndpoints: endpoints,
+				Conditions: []conf_v1.VirtualServerCondition{
+					{
+						Type:               conditionTypeRoutesResolved,
+						Status:             "True",
+						Reason:             reasonRoutesResolved,
+						Message:            "All referenced VirtualServerRoutes exist and are valid",
+						LastTransitionTime: now,
+					},
+				},
+			},
+			expectedChanged: true,
+			msg:             "empty status",
+		},
+		{
+			status:          validStatus,
+			state:           valid,
+			endpoints:       endpoints,
+			expected:        validStatus,
+			expectedChanged: false,
+			msg:             "same status",
+		},
+		{
+			status: validStatus,
+			state: virtualServerState{
+				State:   stateInvalid,
+				Reason:  "Rejected",
+				Message: "VirtualServer default/cafe is invalid and was rejected",
+			},
+			endpoints: endpoints,
+			expected: conf_v1.VirtualServerStatus{
+				State:             stateInvalid,
+				Reason:            "Rejected",
+				Message:           "VirtualServer default/cafe is invalid and was rejected",
+				ExternalEndpoints: endpoints,
+				Conditions:        []conf_v1.VirtualServerCondition{resolvedBefore},
+			},
+			expectedChanged: true,
+			msg:             "changed state",
+		},
+		{
+			status:    validStatus,
+			state:     valid,
+			endpoints: nil,
+			expected: conf_v1.VirtualServerStatus{
+				State:      stateValid,
+				Reason:     "AddedOrUpdated",
+				Message:    "Configuration for default/cafe was added or updated",
+				Conditions: []conf_v1.VirtualServerCondition{resolvedBefore},
+			},
+			expectedChanged: true,
+			msg:             "removed external endpoints",
+		},
+	}
+
+	for _, test := range tests {
+		vs := &conf_v1.VirtualServer{
+			Status: test.status,
+		}
+
+		result, changed := newVirtualServerStatus(vs, test.state, test.endpoints, []conf_v1.VirtualServerCondition{resolved}, now)
+		if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("newVirtualServerStatus() returned %v but expected %v for the case of %s", result, test.expected, test.msg)
+		}
+		if changed != test.expectedChanged {
+			t.Errorf("newVirtualServerStatus() returned changed %v but expected %v for the case of %s", changed, test.expectedChanged, test.msg)
+		}
+	}
+}
+
+func TestFindVirtualServerRoutesReferencedBy(t *testing.T) {
+	coffee := &conf_v1.VirtualServerRoute{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "coffee",
+			Namespace: "default",
+		},
+		Status: conf_v1.VirtualServerRouteStatus{
+			ReferencedBy: "default/cafe",
+		},
+	}
+	tea := &conf_v1.VirtualServerRoute{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "tea",
+			Namespace: "default",
+		},
+		Status: conf_v1.VirtualServerRouteStatus{
+			ReferencedBy: "default/tea-house",
+		},
+	}
+	juice := &conf_v1.VirtualServerRoute{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "juice",
+			Namespace: "default",
+		},
+	}
+
+	virtualServerRoutes := []*conf_v1.VirtualServerRoute{coffee, tea, juice}
+
+	expected := []*conf_v1.VirtualServerRoute{coffee}
+
+	result := findVirtualServerRoutesReferencedBy(virtualServerRoutes, "default/cafe")
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("findVirtualServerRoutesReferencedBy() returned %v but expected %v", result, expected)
+	}
+
+	result = findVirtualServerRoutesReferencedBy(virtualServerRoutes, "default/bar")
+	if len(result) != 0 {
+		t.Errorf("findVirtualServerRoutesReferencedBy() returned %v but expected no VirtualServerRoutes", result)
+	}
+}
+
+func TestStatusRecords(t *testing.T) {
+	var records statusRecords
+
+	if _, exists := records.getVirtualServer("default/cafe"); exists {
+		t.Errorf("getVirtualServer() returned a record for an empty statusRecords")
+	}
+
+	vsRecord := virtualServerStatusRecord{
+		state:      virtualServerState{State: stateValid, Reason: "AddedOrUpdated"},
+		conditions: []conf_v1.VirtualServerCondition{newRoutesResolvedCondition(nil)},
+	}
+	records.setVirtualServer("default/cafe", vsRecord)
+
+	record, exists := records.getVirtualServer("default/cafe")
+	if !exists || !reflect.DeepEqual(record, vsRecord) {
+		t.Errorf("getVirtualServer() returned %v, %v but expected %v, true", record, exists, vsRecord)
+	}
+
+	records.deleteVirtualServer("default/cafe")
+	if _, exists := records.getVirtualServer("default/cafe"); exists {
+		t.Errorf("getVirtualServer() returned a deleted record")
+	}
+
+	vsrRecord := virtualServerRouteStatusRecord{
+		state:        virtualServerState{State: stateWarning, Reason: "NoVirtualServersFound"},
+		referencedBy: "",
+	}
+	records.setVirtualServerRoute("default/coffee", vsrRecord)
+
+	routeRecord, exists := records.getVirtualServerRoute("default/coffee")
+	if !exists || routeRecord != vsrRecord {
+		t.Errorf("getVirtualServerRoute() returned %v, %v but expected %v, true", routeRecord, exists, vsrRecord)
+	}
+
+	records.deleteVirtualServerRoute("default/coffee")
+	if _, exists := records.getVirtualServerRoute("default/coffee"); exists {
+		t.Errorf("getVirtualServerRoute() returned a deleted record")
+	}
+}
+
+func TestWriteVirtualServerStatus(t *testing.T) {
+	vs := &conf_v1.VirtualServer{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "cafe",
+			Namespace: "default",
+		},
+	}
+	endpoints := []conf_v1.ExternalEndpoint{
+		{
+			IP:    "2.2.2.2",
+			Ports: "[80,443]",
+		},
+	}
+	state := virtualServerState{State: stateValid, Reason: "AddedOrUpdated", Message: "Configuration for default/cafe was added or updated"}
+
+	lbc := LoadBalancerController{
+		confClient:          conf_fake.NewSimpleClientset(vs),
+		virtualServerLister: cache.NewStore(cache.MetaNamespaceKeyFunc),
+	}
+	err := lbc.virtualServerLister.Add(vs)
+	if err != nil {
+		t.Fatalf("Failed to add the VirtualServer to the lister: %v", err)
+	}
+
+	err = lbc.writeVirtualServerStatus("default/cafe")
+	if err != nil {
+		t.Errorf("writeVirtualServerStatus() returned an unexpected error for a VirtualServer without a record: %v", err)
+	}
+
+	lbc.statusRecords.setVirtualServer("default/cafe", virtualServerStatusRecord{state: state})
+	lbc.statusRecords.setExternalEndpoints(endpoints)
+
+	err = lbc.writeVirtualServerStatus("default/cafe")
+	if err != nil {
+		t.Fatalf("writeVirtualServerStatus() returned an unexpected error: %v", err)
+	}
+
+	apiVS, err := lbc.confClient.K8sV1().VirtualServers("default").Get("cafe", meta_v1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get the VirtualServer: %v", err)
+	}
+
+	expected := conf_v1.VirtualServerStatus{
+		State:             state.State,
+		Reason:            state.Reason,
+		Message:           state.Message,
+		ExternalEndpoints: endpoints,
+	}
+	if !reflect.DeepEqual(apiVS.Status, expected) {
+		t.Errorf("writeVirtualServerStatus() wrote the status %+v but expected %+v", apiVS.Status, expected)
+	}
+
+	lbc.confClient = conf_fake.NewSimpleClientset()
+
+	err = lbc.writeVirtualServerStatus("default/cafe")
+	if err == nil {
+		t.Errorf("writeVirtualServerStatus() returned no error for a VirtualServer that does not exist in the API")
+	}
+}
+
+func TestWriteVirtualServerRouteStatus(t *testing.T) {
+	vsr := &conf_v1.VirtualServerRoute{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "coffee",
+			Namespace: "default",
+		},
+	}
+	state := virtualServerState{State: stateValid, Reason: "AddedOrUpdated", Message: "Configuration for default/coffee was added or updated"}
+
+	lbc := LoadBalancerController{
+		confClient:               conf_fake.NewSimpleClientset(vsr),
+		virtualServerRouteLister: cache.NewStore(cache.MetaNamespaceKeyFunc),
+	}
+	err := lbc.virtualServerRouteLister.Add(vsr)
+	if err != nil {
+		t.Fatalf("Failed to add the VirtualServerRoute to the lister: %v", err)
+	}
+
+	lbc.statusRecords.setVirtualServerRoute("default/coffee", virtualServerRouteStatusRecord{state: state, referencedBy: "default/cafe"})
+
+	err = lbc.writeVirtualServerRouteStatus("default/coffee")
+	if err != nil {
+		t.Fatalf("writeVirtualServerRouteStatus() returned an unexpected error: %v", err)
+	}
+
+	apiVSR, err := lbc.confClient.K8sV1().VirtualServerRoutes("default").Get("coffee", meta_v1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get the VirtualServerRoute: %v", err)
+	}
+
+	expected := conf_v1.VirtualServerRouteStatus{
+		State:        state.State,
+		Reason:       state.Reason,
+		Message:      state.Message,
+		ReferencedBy: "default/cafe",
+	}
+	if apiVSR.Status != expected {
+		t.Errorf("writeVirtualServerRouteStatus() wrote the status %+v but expected %+v", apiVSR.Status, expected)
+	}
+}
+
+func TestIsVirtualServerRouteStatusRecorded(t *testing.T) {
+	vsr := &conf_v1.VirtualServerRoute{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:       "coffee",
+			Namespace:  "default",
+			Generation: 2,
+		},
+	}
+	state := virtualServerState{State: stateValid, Reason: "AddedOrUpdated", Message: "Configuration for default/coffee was added or updated"}
+
+	var lbc LoadBalancerController
+	if lbc.isVirtualServerRouteStatusRecorded(vsr, state, "default/cafe") {
+		t.Errorf("isVirtualServerRouteStatusRecorded() returned true for a VirtualServerRoute without a record")
+	}
+
+	lbc.updateVirtualServerRouteStatus(vsr, state, "default/cafe")
+
+	changedVSR := vsr.DeepCopy()
+	changedVSR.Generation = 3
+
+	tests := []struct {
+		vsr          *conf_v1.VirtualServerRoute
+		state        virtualServerState
+		referencedBy string
+		expected     bool
+		msg          string
+	}{
+		{
+			vsr:          vsr,
+			state:        state,
+			referencedBy: "default/cafe",
+			expected:     true,
+			msg:          "same generation, state and VirtualServer",
+		},
+		{
+			vsr:          changedVSR,
+			state:        state,
+			referencedBy: "default/cafe",
+			expected:     false,
+			msg:          "new generation",
+		},
+		{
+			vsr:          vsr,
+			state:        virtualServerState{State: stateWarning, Reason: "AddedOrUpdatedWithWarning", Message: "with warning(s)"},
+			referencedBy: "default/cafe",
+			expected:     false,
+			msg:          "new state",
+		},
+		{
+			vsr:          vsr,
+			state:        state,
+			referencedBy: "default/tea",
+			expected:     false,
+			msg:          "new VirtualServer",
+		},
+	}
+
+	for _, test := range tests {
+		result := lbc.isVirtualServerRouteStatusRecorded(test.vsr, test.state, test.referencedBy)
+		if result != test.expected {
+			t.Errorf("isVirtualServerRouteStatusRecorded() returned %v but expected %v for the case of %s", result, test.expected, test.msg)
+		}
+	}
+}
+
+func TestNewWarningsCondition(t *testing.T) {
+	vs := &conf_v1.VirtualServer{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "cafe",
+			Namespace: "default",
+		},
+	}
+	coffee := &conf_v1.VirtualServerRoute{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "coffee",
+			Namespace: "default",
+		},
+	}
+	tea := &conf_v1.VirtualServerRoute{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "tea",
+			Namespace: "default",
+		},
+	}
+
+	vsEx := &configs.VirtualServerEx{
+		VirtualServer:       vs,
+		VirtualServerRoutes: []*conf_v1.VirtualServerRoute{coffee, tea},
+	}
+
+	condition := newWarningsCondition(vsEx, configs.Warnings{})
+	if condition.Type != conditionTypeWarnings || condition.Status != "False" || condition.Reason != reasonNoWarnings {
+		t.Errorf("newWarningsCondition() returned %v but expected a False condition with reason %v", condition, reasonNoWarnings)
+	}
+
+	warnings := configs.Warnings{
+		tea: []configs.Warning{
+			{Code: configs.WarningCodeIgnoredSetting, Severity: configs.WarningSeverityLow, Message: "Slow start will be disabled for upstream tea"},
+		},
+		vs: []configs.Warning{
+			{Code: configs.WarningCodeInvalidPolicy, Severity: configs.WarningSeverityHigh, Message: "Policy default/jwt is missing or invalid"},
+		},
+	}
+	expectedMessage := "VirtualServer default/cafe: [High] Policy default/jwt is missing or invalid; " +
+		"VirtualServerRoute default/tea: [Low] Slow start will be disabled for upstream tea"
+
+	condition = newWarningsCondition(vsEx, warnings)
+	if condition.Status != "True" || condition.Reason != reasonConfigurationWarnings || condition.Message != expectedMessage {
+		t.Errorf("newWarningsCondition() returned %v but expected a True condition with reason %v and message %q",
+			condition, reasonConfigurationWarnings, expectedMessage)
+	}
+}
@@ -0,0 +1,266 @@
+package collectors
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	labelNamesResource = []string{"resource_type", "resource_namespace", "resource_name"}
+	labelNamesUpstream = []string{"resource_type", "resource_namespace", "resource_name", "upstream"}
+	labelNamesWarnings = []string{"kind", "namespace", "name", "code"}
+)
+
+// configGenerationDurationBuckets are the buckets in seconds of the histogram of the generation durations.
+// The generation of a typical resource takes less than a millisecond.
+var configGenerationDurationBuckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1}
+
+// ResourceConfigStats describes the NGINX configuration generated for a resource
+type ResourceConfigStats struct {
+	// UpstreamEndpoints is the number of endpoints of each upstream by the name of the upstream
+	UpstreamEndpoints map[string]int
+	Locations         int
+	Warnings          int
+	// GenerationDuration is the time it took to generate the configuration, including the execution of the template
+	GenerationDuration time.Duration
+	// WarningCodes is the number of warnings of each code by the resource with the warnings, which is either the resource
+	// itself or a resource it references, like a VirtualServerRoute
+	WarningCodes map[WarnedResource]map[string]int
+}
+
+// WarnedResource is a resource with warnings of the generated configuration
+type WarnedResource struct {
+	Kind      string
+	Namespace string
+	Name      string
+}
+
+// ConfigCollector is an interface for the metrics of the NGINX configuration generated for the resources
+type ConfigCollector interface {
+	UpdateResourceConfig(resourceType string, namespace string, name string, stats ResourceConfigStats)
+	DeleteResourceConfig(resourceType string, namespace string, name string)
+	Register(registry *prometheus.Registry) error
+}
+
+type resourceKey struct {
+	resourceType string
+	namespace    string
+	name         string
+}
+
+// ConfigMetricsCollector implements the ConfigCollector interface and prometheus.Collector interface
+type ConfigMetricsCollector struct {
+	upstreamEndpoints    *prometheus.GaugeVec
+	upstreamsTotal       *prometheus.GaugeVec
+	locationsTotal       *prometheus.GaugeVec
+	warningsTotal        *prometheus.GaugeVec
+	lastGenerationTime   *prometheus.GaugeVec
+	lastGenerationDur    *prometheus.GaugeVec
+	generationDuration   *prometheus.HistogramVec
+	resourceWarnings     *prometheus.GaugeVec
+	mu                   sync.Mutex
+	upstreamsPerResource map[resourceKey][]string
+	warningsPerResource  map[resourceKey][][]string
+}
+
+// NewConfigMetricsCollector creates a new ConfigMetricsCollector
+func NewConfigMetricsCollector(constLabels map[string]string) *ConfigMetricsCollector {
+	return &ConfigMetricsCollector{
+		upstreamEndpoints: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name:        "upstream_endpoints",
+				Namespace:   metricsNamespace,
+				Help:        "Number of endpoints in a generated upstream",
+				ConstLabels: constLabels,
+			},
+			labelNamesUpstream,
+		),
+		upstreamsTotal: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name:        "resource_upstreams_total",
+				Namespace:   metricsNamespace,
+				Help:        "Number of upstreams in the configuration generated for a resource",
+				ConstLabels: constLabels,
+			},
+			labelNamesResource,
+		),
+		locationsTotal: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name:        "resource_locations_total",
+				Namespace:   metricsNamespace,
+				Help:        "Number of locations in the configuration generated for a resource",
+				ConstLabels: constLabels,
+			},
+			labelNamesResource,
+		),
+		warningsTotal: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name:        "resource_warnings_total",
+				Namespace:   metricsNamespace,
+				Help:        "Number of warnings of the configuration generated for a resource",
+				ConstLabels: constLabels,
+			},
+			labelNamesResource,
+		),
+		lastGenerationTime: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name:        "resource_config_last_generation_timestamp_seconds",
+				Namespace:   metricsNamespace,
+				Help:        "Unix time of the last generation of the configuration for a resource",
+				ConstLabels: constLabels,
+			},
+			labelNamesResource,
+		),
+		lastGenerationDur: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name:        "resource_config_last_generation_duration_seconds",
+				Namespace:   metricsNamespace,
+				Help:        "Duration in seconds of the last generation of the configuration for a resource",
+				ConstLabels: constLabels,
+			},
+			labelNamesResource,
+		),
+		generationDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:        "config_generation_duration_seconds",
+				Namespace:   metricsNamespace,
+				Help:        "Duration in seconds of the generation of the configuration for resources",
+				ConstLabels: constLabels,
+				Buckets:     configGenerationDurationBuckets,
+			},
+			[]string{"resource_type"},
+		),
+		resourceWarnings: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name:        "resource_warnings",
+				Namespace:   metricsNamespace,
+				Help:        "Number of warnings of a code of the configuration generated for a resource",
+				ConstLabels: constLabels,
+			},
+			labelNamesWarnings,
+		),
+		upstreamsPerResource: make(map[resourceKey][]string),
+		warningsPerResource:  make(map[resourceKey][][]string),
+	}
+}
+
+// UpdateResourceConfig updates the metrics of the configuration generated for a resource
+// and sets the time of the last generation to the current time
+func (cc *ConfigMetricsCollector) UpdateResourceConfig(resourceType string, namespace string, name string, stats ResourceConfigStats) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	key := resourceKey{resourceType: resourceType, namespace: namespace, name: name}
+
+	// the upstreams removed from the configuration must not be reported anymore
+	for _, upstream := range cc.upstreamsPerResource[key] {
+		if _, exists := stats.UpstreamEndpoints[upstream]; !exists {
+			cc.upstreamEndpoints.DeleteLabelValues(resourceType, namespace, name, upstream)
+		}
+	}
+
+	var upstreams []string
+	for upstream, count := range stats.UpstreamEndpoints {
+		cc.upstreamEndpoints.WithLabelValues(resourceType, namespace, name, upstream).Set(float64(count))
+		upstreams = append(upstreams, upstream)
+	}
+	cc.upstreamsPerResource[key] = upstreams
+
+	cc.upstreamsTotal.WithLabelValues(resourceType, namespace, name).Set(float64(len(stats.UpstreamEndpoints)))
+	cc.locationsTotal.WithLabelValues(resourceType, namespace, name).Set(float64(stats.Locations))
+	cc.warningsTotal.WithLabelValues(resourceType, namespace, name).Set(float64(stats.Warnings))
+	cc.lastGenerationTime.WithLabelValues(resourceType, namespace, name).SetToCurrentTime()
+	cc.lastGenerationDur.WithLabelValues(resourceType, namespace, name).Set(stats.GenerationDuration.Seconds())
+	cc.generationDuration.WithLabelValues(resourceType).Observe(stats.GenerationDuration.Seconds())
+
+	// the warnings that are gone must not be reported anymore
+	for _, labels := range cc.warningsPerResource[key] {
+		if _, exists := stats.WarningCodes[WarnedResource{Kind: labels[0], Namespace: labels[1], Name: labels[2]}][labels[3]]; !exists {
+			cc.resourceWarnings.DeleteLabelValues(labels...)
+		}
+	}
+
+	var warnings [][]string
+	for r, codes := range stats.WarningCodes {
+		for code, count := range codes {
+			labels := []string{r.Kind, r.Namespace, r.Name, code}
+			cc.resourceWarnings.WithLabelValues(labels...).Set(float64(count))
+			warnings = append(warnings, labels)
+		}
+	}
+	cc.warningsPerResource[key] = warnings
+}
+
+// DeleteResourceConfig deletes the metrics of the configuration generated for a resource
+func (cc *ConfigMetricsCollector) DeleteResourceConfig(resourceType string, namespace string, name string) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	key := resourceKey{resourceType: resourceType, namespace: namespace, name: name}
+
+	for _, upstream := range cc.upstreamsPerResource[key] {
+		cc.upstreamEndpoints.DeleteLabelValues(resourceType, namespace, name, upstream)
+	}
+	delete(cc.upstreamsPerResource, key)
+
+	for _, labels := range cc.warningsPerResource[key] {
+		cc.resourceWarnings.DeleteLabelValues(labels...)
+	}
+	delete(cc.warningsPerResource, key)
+
+	cc.upstreamsTotal.DeleteLabelValues(resourceType, namespace, name)
+	cc.locationsTotal.DeleteLabelValues(resourceType, namespace, name)
+	cc.warningsTotal.DeleteLabelValues(resourceType, namespace, name)
+	cc.lastGenerationTime.DeleteLabelValues(resourceType, namespace, name)
+	cc.lastGenerationDur.DeleteLabelValues(resourceType, namespace, name)
+}
+
+// Describe implements prometheus.Collector interface Describe method
+func (cc *ConfigMetricsCollector) Describe(ch chan<- *prometheus.Desc) {
+	cc.upstreamEndpoints.Describe(ch)
+	cc.upstreamsTotal.Describe(ch)
+	cc.locationsTotal.Describe(ch)
+	cc.warningsTotal.Describe(ch)
+	cc.lastGenerationTime.Describe(ch)
+	cc.lastGenerationDur.Describe(ch)
+	cc.generationDuration.Describe(ch)
+	cc.resourceWarnings.Describe(ch)
+}
+
+// Collect implements the prometheus.Collector interface Collect method
+func (cc *ConfigMetricsCollector) Collect(ch chan<- prometheus.Metric) {
+	cc.upstreamEndpoints.Collect(ch)
+	cc.upstreamsTotal.Collect(ch)
+	cc.locationsTotal.Collect(ch)
+	cc.warningsTotal.Collect(ch)
+	cc.lastGenerationTime.Collect(ch)
+	cc.lastGenerationDur.Collect(ch)
+	cc.generationDuration.Collect(ch)
+	cc.resourceWarnings.Collect(ch)
+}
+
+// Register registers all the metrics of the collector
+func (cc *ConfigMetricsCollector) Register(registry *prometheus.Registry) error {
+	return registry.Register(cc)
+}
+
+// ConfigFakeCollector is a fake collector that implements the ConfigCollector interface
+type ConfigFakeCollector struct{}
+
+// NewConfigFakeCollector creates a fake collector that implements the ConfigCollector interface
+func NewConfigFakeCollector() *ConfigFakeCollector {
+	return &ConfigFakeCollector{}
+}
+
+// Register implements a fake Register
+func (cc *ConfigFakeCollector) Register(registry *prometheus.Registry) error { return nil }
+
+// UpdateResourceConfig implements a fake UpdateResourceConfig
+func (cc *ConfigFakeCollector) UpdateResourceConfig(resourceType string, namespace string, name string, stats ResourceConfigStats) {
+}
+
+// DeleteResourceConfig implements a fake DeleteResourceConfig
+func (cc *ConfigFakeCollector) DeleteResourceConfig(resourceType string, namespace string, name string) {
+}
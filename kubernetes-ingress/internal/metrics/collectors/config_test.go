@@ -0,0 +1,144 @@
+package collectors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func gatherMetricFamilies(t *testing.T, registry *prometheus.Registry) map[string]*dto.MetricFamily {
+	t.Helper()
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("Gather() returned an unexpected error: %v", err)
+	}
+
+	result := make(map[string]*dto.MetricFamily)
+	for _, f := range families {
+		result[f.GetName()] = f
+	}
+	return result
+}
+
+func TestConfigMetricsCollectorGenerationDuration(t *testing.T) {
+	cc := NewConfigMetricsCollector(nil)
+	registry := prometheus.NewRegistry()
+	if err := cc.Register(registry); err != nil {
+		t.Fatalf("Register() returned an unexpected error: %v", err)
+	}
+
+	cc.UpdateResourceConfig("virtualserver", "default", "cafe", ResourceConfigStats{GenerationDuration: 2 * time.Millisecond})
+	cc.UpdateResourceConfig("virtualserver", "default", "cafe", ResourceConfigStats{GenerationDuration: 4 * time.Millisecond})
+	cc.UpdateResourceConfig("ingress", "default", "cafe-ingress", ResourceConfigStats{GenerationDuration: time.Millisecond})
+
+	families := gatherMetricFamilies(t, registry)
+
+	lastDuration := families["nginx_ingress_controller_resource_config_last_generation_duration_seconds"]
+	if lastDuration == nil || len(lastDuration.GetMetric()) != 2 {
+		t.Fatalf("expected the last generation duration of 2 resources, got %v", lastDuration)
+	}
+
+	histogram := families["nginx_ingress_controller_config_generation_duration_seconds"]
+	if histogram == nil {
+		t.Fatal("expected the histogram of the generation durations")
+	}
+
+	counts := make(map[string]uint64)
+	for _, m := range histogram.GetMetric() {
+		counts[m.GetLabel()[0].GetValue()] = m.GetHistogram().GetSampleCount()
+	}
+	if counts["virtualserver"] != 2 || counts["ingress"] != 1 {
+		t.Errorf("expected 2 observations for virtualservers and 1 for ingresses, got %v", counts)
+	}
+
+	cc.DeleteResourceConfig("virtualserver", "default", "cafe")
+
+	families = gatherMetricFamilies(t, registry)
+	if len(families["nginx_ingress_controller_resource_config_last_generation_duration_seconds"].GetMetric()) != 1 {
+		t.Error("expected the last generation duration of the deleted resource to be deleted")
+	}
+}
+
+func TestLocalManagerMetricsCollectorReloadDuration(t *testing.T) {
+	nc := NewLocalManagerMetricsCollector(nil)
+	registry := prometheus.NewRegistry()
+	if err := nc.Register(registry); err != nil {
+		t.Fatalf("Register() returned an unexpected error: %v", err)
+	}
+
+	nc.UpdateLastReloadTime(300 * time.Millisecond)
+	nc.UpdateLastReloadTime(2 * time.Second)
+
+	families := gatherMetricFamilies(t, registry)
+
+	histogram := families["nginx_ingress_controller_nginx_reload_duration_seconds"]
+	if histogram == nil || len(histogram.GetMetric()) != 1 {
+		t.Fatalf("expected the histogram of the reload durations, got %v", histogram)
+	}
+
+	h := histogram.GetMetric()[0].GetHistogram()
+	if h.GetSampleCount() != 2 {
+		t.Errorf("expected 2 observations, got %d", h.GetSampleCount())
+	}
+	if h.GetSampleSum() != 2.3 {
+		t.Errorf("expected the sum of 2.3 seconds, got %v", h.GetSampleSum())
+	}
+}
+
+func TestConfigMetricsCollectorResourceWarnings(t *testing.T) {
+	cc := NewConfigMetricsCollector(nil)
+	registry := prometheus.NewRegistry()
+	if err := cc.Register(registry); err != nil {
+		t.Fatalf("Register() returned an unexpected error: %v", err)
+	}
+
+	vs := WarnedResource{Kind: "VirtualServer", Namespace: "default", Name: "cafe"}
+	vsr := WarnedResource{Kind: "VirtualServerRoute", Namespace: "default", Name: "coffee"}
+
+	cc.UpdateResourceConfig("virtualserver", "default", "cafe", ResourceConfigStats{
+		WarningCodes: map[WarnedResource]map[string]int{
+			vs:  {"FallbackCertificate": 1},
+			vsr: {"ResolverRequired": 2},
+		},
+	})
+
+	families := gatherMetricFamilies(t, registry)
+
+	warnings := families["nginx_ingress_controller_resource_warnings"]
+	if warnings == nil || len(warnings.GetMetric()) != 2 {
+		t.Fatalf("expected the warnings of 2 codes, got %v", warnings)
+	}
+
+	values := make(map[string]float64)
+	for _, m := range warnings.GetMetric() {
+		labels := make(map[string]string)
+		for _, l := range m.GetLabel() {
+			labels[l.GetName()] = l.GetValue()
+		}
+		values[labels["kind"]+"/"+labels["name"]+"/"+labels["code"]] = m.GetGauge().GetValue()
+	}
+	if values["VirtualServer/cafe/FallbackCertificate"] != 1 || values["VirtualServerRoute/coffee/ResolverRequired"] != 2 {
+		t.Errorf("expected 1 FallbackCertificate warning of the VirtualServer and 2 ResolverRequired warnings of the VirtualServerRoute, got %v", values)
+	}
+
+	cc.UpdateResourceConfig("virtualserver", "default", "cafe", ResourceConfigStats{
+		WarningCodes: map[WarnedResource]map[string]int{
+			vsr: {"ResolverRequired": 1},
+		},
+	})
+
+	families = gatherMetricFamilies(t, registry)
+	if len(families["nginx_ingress_controller_resource_warnings"].GetMetric()) != 1 {
+		t.Error("expected the warnings that are gone to be deleted")
+	}
+
+	cc.DeleteResourceConfig("virtualserver", "default", "cafe")
+
+	families = gatherMetricFamilies(t, registry)
+	if _, exists := families["nginx_ingress_controller_resource_warnings"]; exists {
+		t.Error("expected the warnings of the deleted resource to be deleted")
+	}
+}
@@ -9,6 +9,7 @@ type ControllerCollector interface {
 	SetIngresses(ingressType string, count int)
 	SetVirtualServers(count int)
 	SetVirtualServerRoutes(count int)
+	IncreaseSuppressedEndpointsChanges()
 	Register(registry *prometheus.Registry) error
 }
 
@@ -18,6 +19,7 @@ type ControllerMetricsCollector struct {
 	ingressesTotal           *prometheus.GaugeVec
 	virtualServersTotal      prometheus.Gauge
 	virtualServerRoutesTotal prometheus.Gauge
+	suppressedEndpointsTotal prometheus.Counter
 }
 
 // NewControllerMetricsCollector creates a new ControllerMetricsCollector
@@ -32,8 +34,20 @@ func NewControllerMetricsCollector(crdsEnabled bool, constLabels map[string]stri
 		labelNamesController,
 	)
 
+	suppressedEndpointsTotal := prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name:        "endpoints_changes_suppressed_total",
+			Namespace:   metricsNamespace,
+			Help:        "Number of changes of Endpoints that were reverted within the suppression period and didn't cause a reload",
+			ConstLabels: constLabels,
+		},
+	)
+
 	if !crdsEnabled {
-		return &ControllerMetricsCollector{ingressesTotal: ingResTotal}
+		return &ControllerMetricsCollector{
+			ingressesTotal:           ingResTotal,
+			suppressedEndpointsTotal: suppressedEndpointsTotal,
+		}
 	}
 
 	vsResTotal := prometheus.NewGauge(
@@ -59,6 +73,7 @@ func NewControllerMetricsCollector(crdsEnabled bool, constLabels map[string]stri
 		ingressesTotal:           ingResTotal,
 		virtualServersTotal:      vsResTotal,
 		virtualServerRoutesTotal: vsrResTotal,
+		suppressedEndpointsTotal: suppressedEndpointsTotal,
 	}
 }
 
@@ -77,9 +92,15 @@ func (cc *ControllerMetricsCollector) SetVirtualServerRoutes(count int) {
 	cc.virtualServerRoutesTotal.Set(float64(count))
 }
 
+// IncreaseSuppressedEndpointsChanges increases the counter of the suppressed changes of Endpoints
+func (cc *ControllerMetricsCollector) IncreaseSuppressedEndpointsChanges() {
+	cc.suppressedEndpointsTotal.Inc()
+}
+
 // Describe implements prometheus.Collector interface Describe method
 func (cc *ControllerMetricsCollector) Describe(ch chan<- *prometheus.Desc) {
 	cc.ingressesTotal.Describe(ch)
+	cc.suppressedEndpointsTotal.Describe(ch)
 	if cc.crdsEnabled {
 		cc.virtualServersTotal.Describe(ch)
 		cc.virtualServerRoutesTotal.Describe(ch)
@@ -89,6 +110,7 @@ func (cc *ControllerMetricsCollector) Describe(ch chan<- *prometheus.Desc) {
 // Collect implements the prometheus.Collector interface Collect method
 func (cc *ControllerMetricsCollector) Collect(ch chan<- prometheus.Metric) {
 	cc.ingressesTotal.Collect(ch)
+	cc.suppressedEndpointsTotal.Collect(ch)
 	if cc.crdsEnabled {
 		cc.virtualServersTotal.Collect(ch)
 		cc.virtualServerRoutesTotal.Collect(ch)
@@ -119,3 +141,6 @@ func (cc *ControllerFakeCollector) SetVirtualServers(count int) {}
 
 // SetVirtualServerRoutes implements a fake SetVirtualServerRoutes
 func (cc *ControllerFakeCollector) SetVirtualServerRoutes(count int) {}
+
+// IncreaseSuppressedEndpointsChanges implements a fake IncreaseSuppressedEndpointsChanges
+func (cc *ControllerFakeCollector) IncreaseSuppressedEndpointsChanges() {}
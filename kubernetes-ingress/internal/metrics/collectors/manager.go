@@ -21,6 +21,7 @@ type LocalManagerMetricsCollector struct {
 	reloadsError     prometheus.Counter
 	lastReloadStatus prometheus.Gauge
 	lastReloadTime   prometheus.Gauge
+	reloadDuration   prometheus.Histogram
 }
 
 // NewLocalManagerMetricsCollector creates a new LocalManagerMetricsCollector
@@ -58,6 +59,15 @@ func NewLocalManagerMetricsCollector(constLabels map[string]string) *LocalManage
 				ConstLabels: constLabels,
 			},
 		),
+		reloadDuration: prometheus.NewHistogram(
+			prometheus.HistogramOpts{
+				Name:        "nginx_reload_duration_seconds",
+				Namespace:   metricsNamespace,
+				Help:        "Duration in seconds of successful NGINX reloads",
+				ConstLabels: constLabels,
+				Buckets:     []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+			},
+		),
 	}
 	return nc
 }
@@ -83,9 +93,10 @@ func (nc *LocalManagerMetricsCollector) updateLastReloadStatus(up bool) {
 	nc.lastReloadStatus.Set(status)
 }
 
-// UpdateLastReloadTime updates the last NGINX reload time
+// UpdateLastReloadTime updates the last NGINX reload time and observes the duration in the histogram of reloads
 func (nc *LocalManagerMetricsCollector) UpdateLastReloadTime(duration time.Duration) {
 	nc.lastReloadTime.Set(float64(duration / time.Millisecond))
+	nc.reloadDuration.Observe(duration.Seconds())
 }
 
 // Describe implements prometheus.Collector interface Describe method
@@ -94,6 +105,7 @@ func (nc *LocalManagerMetricsCollector) Describe(ch chan<- *prometheus.Desc) {
 	nc.reloadsError.Describe(ch)
 	nc.lastReloadStatus.Describe(ch)
 	nc.lastReloadTime.Describe(ch)
+	nc.reloadDuration.Describe(ch)
 }
 
 // Collect implements the prometheus.Collector interface Collect method
@@ -102,6 +114,7 @@ func (nc *LocalManagerMetricsCollector) Collect(ch chan<- prometheus.Metric) {
 	nc.reloadsError.Collect(ch)
 	nc.lastReloadStatus.Collect(ch)
 	nc.lastReloadTime.Collect(ch)
+	nc.reloadDuration.Collect(ch)
 }
 
 // Register registers all the metrics of the collector
@@ -0,0 +1,200 @@
+package collectors
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/golang/glog"
+	plusclient "github.com/nginxinc/nginx-plus-go-client/client"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const virtualServerUpstreamsNamespace = "nginx_ingress_nginxplus"
+
+var labelNamesVirtualServerUpstreamServer = []string{
+	"virtualserver_namespace",
+	"virtualserver_name",
+	"virtualserverroute_namespace",
+	"virtualserverroute_name",
+	"upstream",
+	"server",
+}
+
+// upstreamServerStates maps the states of upstream servers to the values of the state metric.
+// The values are the same as in the upstream server metrics of the NGINX Plus exporter.
+var upstreamServerStates = map[string]float64{
+	"up":        1.0,
+	"draining":  2.0,
+	"down":      3.0,
+	"unavail":   4.0,
+	"checking":  5.0,
+	"unhealthy": 6.0,
+}
+
+// PlusStatsGetter gets the stats of NGINX Plus
+type PlusStatsGetter interface {
+	GetStats() (*plusclient.Stats, error)
+}
+
+// virtualServerUpstream is the VirtualServer, the VirtualServerRoute and the name of an upstream
+// in the spec of the resource, parsed from the name of the generated upstream.
+type virtualServerUpstream struct {
+	vsNamespace  string
+	vsName       string
+	vsrNamespace string
+	vsrName      string
+	upstream     string
+}
+
+// defaultUpstreamNamePrefix is the default prefix of the names of the upstreams of VirtualServers and VirtualServerRoutes.
+const defaultUpstreamNamePrefix = "vs"
+
+// parseVirtualServerUpstreamName parses the name of an upstream generated for a VirtualServer ("<prefix>_<namespace>_<name>_<upstream>")
+// or for a VirtualServerRoute ("<prefix>_<namespace>_<name>_vsr_<namespace>_<name>_<upstream>"). If excludeNamespace is true,
+// the names don't include the namespaces. Namespaces and names of resources and names of upstreams can't include underscores,
+// neither can the shortened long namespaces and names, so the parts are separated unambiguously. Long namespaces and names
+// are parsed in their shortened form.
+// It returns false for the upstreams of other resources.
+func parseVirtualServerUpstreamName(name string, prefix string, excludeNamespace bool) (virtualServerUpstream, bool) {
+	if !strings.HasPrefix(name, prefix+"_") {
+		return virtualServerUpstream{}, false
+	}
+
+	parts := strings.Split(strings.TrimPrefix(name, prefix+"_"), "_")
+
+	if excludeNamespace {
+		if len(parts) == 2 {
+			return virtualServerUpstream{
+				vsName:   parts[0],
+				upstream: parts[1],
+			}, true
+		}
+
+		if len(parts) == 4 && parts[1] == "vsr" {
+			return virtualServerUpstream{
+				vsName:   parts[0],
+				vsrName:  parts[2],
+				upstream: parts[3],
+			}, true
+		}
+
+		return virtualServerUpstream{}, false
+	}
+
+	if len(parts) == 3 {
+		return virtualServerUpstream{
+			vsNamespace: parts[0],
+			vsName:      parts[1],
+			upstream:    parts[2],
+		}, true
+	}
+
+	if len(parts) == 6 && parts[2] == "vsr" {
+		return virtualServerUpstream{
+			vsNamespace:  parts[0],
+			vsName:       parts[1],
+			vsrNamespace: parts[3],
+			vsrName:      parts[4],
+			upstream:     parts[5],
+		}, true
+	}
+
+	return virtualServerUpstream{}, false
+}
+
+// VirtualServerUpstreamsCollector collects the metrics of the servers of the upstreams of VirtualServers and VirtualServerRoutes
+// from the NGINX Plus API. The metrics are labeled with the namespaces and the names of the resources,
+// and the names of the upstreams as they appear in the resources.
+// It implements the prometheus.Collector interface.
+type VirtualServerUpstreamsCollector struct {
+	client             PlusStatsGetter
+	upstreamNamePrefix string
+	excludeNamespace   bool
+	metrics            map[string]*prometheus.Desc
+	mutex              sync.Mutex
+}
+
+// NewVirtualServerUpstreamsCollector creates a new VirtualServerUpstreamsCollector. The prefix and excludeNamespace
+// must match the naming scheme of the upstreams of the Ingress Controller. The empty prefix means the default prefix.
+// Without the namespaces in the names of the upstreams, the namespace labels are empty.
+func NewVirtualServerUpstreamsCollector(client PlusStatsGetter, upstreamNamePrefix string, excludeNamespace bool,
+	constLabels map[string]string) *VirtualServerUpstreamsCollector {
+	if upstreamNamePrefix == "" {
+		upstreamNamePrefix = defaultUpstreamNamePrefix
+	}
+
+	return &VirtualServerUpstreamsCollector{
+		client:             client,
+		upstreamNamePrefix: upstreamNamePrefix,
+		excludeNamespace:   excludeNamespace,
+		metrics: map[string]*prometheus.Desc{
+			"state":               newVirtualServerUpstreamServerMetric("state", "Current state", nil, constLabels),
+			"active":              newVirtualServerUpstreamServerMetric("active", "Active connections", nil, constLabels),
+			"requests":            newVirtualServerUpstreamServerMetric("requests", "Total client requests", nil, constLabels),
+			"responses":           newVirtualServerUpstreamServerMetric("responses", "Total responses sent to clients", []string{"code"}, constLabels),
+			"fails":               newVirtualServerUpstreamServerMetric("fails", "Number of unsuccessful attempts to communicate with the server", nil, constLabels),
+			"header_time":         newVirtualServerUpstreamServerMetric("header_time", "Average time to get the response header from the server", nil, constLabels),
+			"response_time":       newVirtualServerUpstreamServerMetric("response_time", "Average time to get the full response from the server", nil, constLabels),
+			"health_checks_fails": newVirtualServerUpstreamServerMetric("health_checks_fails", "Failed health checks", nil, constLabels),
+		},
+	}
+}
+
+func newVirtualServerUpstreamServerMetric(name string, help string, extraLabels []string, constLabels map[string]string) *prometheus.Desc {
+	labels := append(append([]string{}, labelNamesVirtualServerUpstreamServer...), extraLabels...)
+	return prometheus.NewDesc(prometheus.BuildFQName(virtualServerUpstreamsNamespace, "virtualserver_upstream_server", name), help, labels, constLabels)
+}
+
+// Describe implements prometheus.Collector interface Describe method
+func (c *VirtualServerUpstreamsCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, m := range c.metrics {
+		ch <- m
+	}
+}
+
+// Collect implements the prometheus.Collector interface Collect method
+func (c *VirtualServerUpstreamsCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mutex.Lock() // To protect metrics from concurrent collects
+	defer c.mutex.Unlock()
+
+	stats, err := c.client.GetStats()
+	if err != nil {
+		glog.Warningf("Error getting stats for the metrics of the upstreams of VirtualServers: %v", err)
+		return
+	}
+
+	for name, upstream := range stats.Upstreams {
+		vsUpstream, ok := parseVirtualServerUpstreamName(name, c.upstreamNamePrefix, c.excludeNamespace)
+		if !ok {
+			continue
+		}
+
+		for _, peer := range upstream.Peers {
+			labels := []string{vsUpstream.vsNamespace, vsUpstream.vsName, vsUpstream.vsrNamespace, vsUpstream.vsrName, vsUpstream.upstream, peer.Server}
+
+			ch <- prometheus.MustNewConstMetric(c.metrics["state"], prometheus.GaugeValue, upstreamServerStates[peer.State], labels...)
+			ch <- prometheus.MustNewConstMetric(c.metrics["active"], prometheus.GaugeValue, float64(peer.Active), labels...)
+			ch <- prometheus.MustNewConstMetric(c.metrics["requests"], prometheus.CounterValue, float64(peer.Requests), labels...)
+			ch <- prometheus.MustNewConstMetric(c.metrics["fails"], prometheus.CounterValue, float64(peer.Fails), labels...)
+			ch <- prometheus.MustNewConstMetric(c.metrics["header_time"], prometheus.GaugeValue, float64(peer.HeaderTime), labels...)
+			ch <- prometheus.MustNewConstMetric(c.metrics["response_time"], prometheus.GaugeValue, float64(peer.ResponseTime), labels...)
+			ch <- prometheus.MustNewConstMetric(c.metrics["health_checks_fails"], prometheus.CounterValue, float64(peer.HealthChecks.Fails), labels...)
+
+			responses := map[string]uint64{
+				"1xx": peer.Responses.Responses1xx,
+				"2xx": peer.Responses.Responses2xx,
+				"3xx": peer.Responses.Responses3xx,
+				"4xx": peer.Responses.Responses4xx,
+				"5xx": peer.Responses.Responses5xx,
+			}
+			for code, count := range responses {
+				ch <- prometheus.MustNewConstMetric(c.metrics["responses"], prometheus.CounterValue, float64(count), append(labels, code)...)
+			}
+		}
+	}
+}
+
+// Register registers all the metrics of the collector
+func (c *VirtualServerUpstreamsCollector) Register(registry *prometheus.Registry) error {
+	return registry.Register(c)
+}
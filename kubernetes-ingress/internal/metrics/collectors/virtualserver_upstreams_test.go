@@ -0,0 +1,196 @@
+package collectors
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	plusclient "github.com/nginxinc/nginx-plus-go-client/client"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestParseVirtualServerUpstreamName(t *testing.T) {
+	shortenedName := strings.Repeat("a", 31) + "-0123abcd"
+
+	tests := []struct {
+		name             string
+		prefix           string
+		excludeNamespace bool
+		expected         virtualServerUpstream
+	}{
+		{
+			name:   "vs_default_cafe_tea",
+			prefix: "vs",
+			expected: virtualServerUpstream{
+				vsNamespace: "default",
+				vsName:      "cafe",
+				upstream:    "tea",
+			},
+		},
+		{
+			name:   "vs_default_cafe.example_vsr_coffee-ns_coffee_coffee-v1",
+			prefix: "vs",
+			expected: virtualServerUpstream{
+				vsNamespace:  "default",
+				vsName:       "cafe.example",
+				vsrNamespace: "coffee-ns",
+				vsrName:      "coffee",
+				upstream:     "coffee-v1",
+			},
+		},
+		{
+			name:   "vs_default_" + shortenedName + "_vsr_" + shortenedName + "_coffee_coffee-v1",
+			prefix: "vs",
+			expected: virtualServerUpstream{
+				vsNamespace:  "default",
+				vsName:       shortenedName,
+				vsrNamespace: shortenedName,
+				vsrName:      "coffee",
+				upstream:     "coffee-v1",
+			},
+		},
+		{
+			name:             "k8s_cafe_tea",
+			prefix:           "k8s",
+			excludeNamespace: true,
+			expected: virtualServerUpstream{
+				vsName:   "cafe",
+				upstream: "tea",
+			},
+		},
+		{
+			name:             "my_prefix_cafe_vsr_coffee_coffee-v1",
+			prefix:           "my_prefix",
+			excludeNamespace: true,
+			expected: virtualServerUpstream{
+				vsName:   "cafe",
+				vsrName:  "coffee",
+				upstream: "coffee-v1",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		result, ok := parseVirtualServerUpstreamName(test.name, test.prefix, test.excludeNamespace)
+		if !ok {
+			t.Errorf("parseVirtualServerUpstreamName(%q, %q, %v) returned false", test.name, test.prefix, test.excludeNamespace)
+		}
+		if result != test.expected {
+			t.Errorf("parseVirtualServerUpstreamName(%q, %q, %v) returned %+v but expected %+v", test.name, test.prefix, test.excludeNamespace, result, test.expected)
+		}
+	}
+}
+
+func TestParseVirtualServerUpstreamNameFails(t *testing.T) {
+	names := []string{
+		"default-cafe-ingress-cafe.example.com-tea-svc-80",
+		"vs_default_cafe",
+		"vs_default_cafe_vsr_default_coffee",
+		"vs_default_cafe_route_default_coffee_coffee",
+	}
+
+	for _, name := range names {
+		if result, ok := parseVirtualServerUpstreamName(name, "vs", false); ok {
+			t.Errorf("parseVirtualServerUpstreamName(%q) returned %+v, true but expected false", name, result)
+		}
+	}
+
+	if result, ok := parseVirtualServerUpstreamName("vs_default_cafe_tea", "vs", true); ok {
+		t.Errorf("parseVirtualServerUpstreamName() returned %+v, true for a name with the namespace but expected false", result)
+	}
+	if result, ok := parseVirtualServerUpstreamName("vs_default_cafe_tea", "k8s", false); ok {
+		t.Errorf("parseVirtualServerUpstreamName() returned %+v, true for a name with another prefix but expected false", result)
+	}
+}
+
+type fakePlusStatsGetter struct {
+	stats *plusclient.Stats
+	err   error
+}
+
+func (f *fakePlusStatsGetter) GetStats() (*plusclient.Stats, error) {
+	return f.stats, f.err
+}
+
+func collectMetrics(c prometheus.Collector) []prometheus.Metric {
+	ch := make(chan prometheus.Metric)
+	go func() {
+		c.Collect(ch)
+		close(ch)
+	}()
+
+	var metrics []prometheus.Metric
+	for m := range ch {
+		metrics = append(metrics, m)
+	}
+	return metrics
+}
+
+func TestVirtualServerUpstreamsCollectorCollect(t *testing.T) {
+	client := &fakePlusStatsGetter{
+		stats: &plusclient.Stats{
+			Upstreams: plusclient.Upstreams{
+				"vs_default_cafe_tea": {
+					Peers: []plusclient.Peer{
+						{Server: "10.0.0.1:80", State: "up"},
+						{Server: "10.0.0.2:80", State: "unhealthy"},
+					},
+				},
+				"default-cafe-ingress-cafe.example.com-tea-svc-80": {
+					Peers: []plusclient.Peer{
+						{Server: "10.0.0.1:80", State: "up"},
+					},
+				},
+			},
+		},
+	}
+
+	collector := NewVirtualServerUpstreamsCollector(client, "", false, map[string]string{"class": "nginx"})
+
+	// 7 metrics and 5 response codes for each of the 2 servers of the VirtualServer upstream
+	expected := 2 * (7 + 5)
+	if metrics := collectMetrics(collector); len(metrics) != expected {
+		t.Errorf("Collect() returned %d metrics but expected %d", len(metrics), expected)
+	}
+
+	client.err = errors.New("connection refused")
+	if metrics := collectMetrics(collector); len(metrics) != 0 {
+		t.Errorf("Collect() returned %d metrics but expected none when the stats are not available", len(metrics))
+	}
+}
+
+func TestVirtualServerUpstreamsCollectorCollectWithShortenedNames(t *testing.T) {
+	shortenedNamespace := strings.Repeat("a", 31) + "-0123abcd"
+	client := &fakePlusStatsGetter{
+		stats: &plusclient.Stats{
+			Upstreams: plusclient.Upstreams{
+				"vs_" + shortenedNamespace + "_cafe_tea": {
+					Peers: []plusclient.Peer{
+						{Server: "10.0.0.1:80", State: "up"},
+					},
+				},
+			},
+		},
+	}
+
+	collector := NewVirtualServerUpstreamsCollector(client, "", false, nil)
+
+	metrics := collectMetrics(collector)
+	if len(metrics) == 0 {
+		t.Fatalf("Collect() returned no metrics for an upstream with a shortened namespace")
+	}
+
+	var m dto.Metric
+	if err := metrics[0].Write(&m); err != nil {
+		t.Fatalf("Failed to write the metric: %v", err)
+	}
+
+	labels := make(map[string]string)
+	for _, pair := range m.GetLabel() {
+		labels[pair.GetName()] = pair.GetValue()
+	}
+	if labels["virtualserver_namespace"] != shortenedNamespace || labels["virtualserver_name"] != "cafe" || labels["upstream"] != "tea" {
+		t.Errorf("Collect() returned a metric with the labels %v for an upstream with a shortened namespace", labels)
+	}
+}
@@ -34,9 +34,11 @@ func RunPrometheusListenerForNginxPlus(port int, plusClient *plusClient.NginxCli
 }
 
 func runServer(port string, registry prometheus.Gatherer) {
-	http.Handle(metricsEndpoint, promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	// the handlers that packages like net/http/pprof register in the default mux must not be exposed with the metrics
+	mux := http.NewServeMux()
+	mux.Handle(metricsEndpoint, promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
 
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		_, err := w.Write([]byte(`<html>
 			<head><title>NGINX Ingress Controller</title></head>
 			<body>
@@ -50,5 +52,5 @@ func runServer(port string, registry prometheus.Gatherer) {
 	})
 	address := fmt.Sprintf(":%v", port)
 	glog.Infof("Starting Prometheus listener on: %v%v", address, metricsEndpoint)
-	glog.Fatal("Error in Prometheus listener server: ", http.ListenAndServe(address, nil))
+	glog.Fatal("Error in Prometheus listener server: ", http.ListenAndServe(address, mux))
 }
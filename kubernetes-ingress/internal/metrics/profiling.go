@@ -0,0 +1,28 @@
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+
+	"github.com/golang/glog"
+)
+
+// profilingEndpoint is the path where the profiles of the Ingress Controller are exposed
+const profilingEndpoint = "/debug/pprof/"
+
+// RunProfilingListener runs an http server to expose the runtime profiles of the Ingress Controller in the format of pprof.
+// The server listens only on the loopback interface, so the profiles are available through kubectl port-forward,
+// but not to other pods.
+func RunProfilingListener(port int) {
+	mux := http.NewServeMux()
+	mux.HandleFunc(profilingEndpoint, pprof.Index)
+	mux.HandleFunc(profilingEndpoint+"cmdline", pprof.Cmdline)
+	mux.HandleFunc(profilingEndpoint+"profile", pprof.Profile)
+	mux.HandleFunc(profilingEndpoint+"symbol", pprof.Symbol)
+	mux.HandleFunc(profilingEndpoint+"trace", pprof.Trace)
+
+	address := fmt.Sprintf("127.0.0.1:%v", port)
+	glog.Infof("Starting profiling listener on: %v%v", address, profilingEndpoint)
+	glog.Fatal("Error in profiling listener server: ", http.ListenAndServe(address, mux))
+}
@@ -1,6 +1,8 @@
 package nginx
 
 import (
+	"bytes"
+	"io"
 	"net/http"
 	"os"
 	"path"
@@ -37,6 +39,19 @@ func (*FakeManager) CreateConfig(name string, content []byte) {
 	glog.V(3).Info(string(content))
 }
 
+// StreamConfig provides a fake implementation of StreamConfig.
+func (*FakeManager) StreamConfig(name string, write func(w io.Writer) error) error {
+	var content bytes.Buffer
+	if err := write(&content); err != nil {
+		return err
+	}
+
+	glog.V(3).Infof("Streaming config %v", name)
+	glog.V(3).Info(content.String())
+
+	return nil
+}
+
 // DeleteConfig provides a fake implementation of DeleteConfig.
 func (*FakeManager) DeleteConfig(name string) {
 	glog.V(3).Infof("Deleting config %v", name)
@@ -95,6 +110,12 @@ func (*FakeManager) UpdateServersInPlus(upstream string, servers []string, confi
 	return nil
 }
 
+// SetKeyValPairInPlus provides a fake implementation of SetKeyValPairInPlus.
+func (*FakeManager) SetKeyValPairInPlus(zone string, key string, value string) error {
+	glog.V(3).Infof("Setting %v key of %v zone to %v", key, zone, value)
+	return nil
+}
+
 // CreateOpenTracingTracerConfig creates a fake implementation of CreateOpenTracingTracerConfig.
 func (*FakeManager) CreateOpenTracingTracerConfig(content string) error {
 	glog.V(3).Infof("Writing OpenTracing tracer config file")
@@ -105,3 +126,27 @@ func (*FakeManager) CreateOpenTracingTracerConfig(content string) error {
 // SetOpenTracing creates a fake implementation of SetOpenTracing.
 func (*FakeManager) SetOpenTracing(openTracing bool) {
 }
+
+// TestConfig is a fake implementation of TestConfig.
+func (*FakeManager) TestConfig(excludedConfigs ...string) error {
+	glog.V(3).Info("Testing nginx config")
+
+	return nil
+}
+
+// PromoteStagedConfigs is a fake implementation of PromoteStagedConfigs.
+func (*FakeManager) PromoteStagedConfigs() {
+	glog.V(3).Info("Promoting staged configs")
+}
+
+// DiscardStagedConfig is a fake implementation of DiscardStagedConfig.
+func (*FakeManager) DiscardStagedConfig(name string) {
+	glog.V(3).Infof("Discarding staged config %v", name)
+}
+
+// TestVirtualServerConfig is a fake implementation of TestVirtualServerConfig.
+func (*FakeManager) TestVirtualServerConfig(content []byte) error {
+	glog.V(3).Infof("Testing VirtualServer config:\n%s", content)
+
+	return nil
+}
@@ -1,11 +1,16 @@
 package nginx
 
 import (
+	"bytes"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
 	"os"
 	"os/exec"
 	"path"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/nginxinc/kubernetes-ingress/internal/metrics/collectors"
@@ -20,22 +25,44 @@ const TLSSecretFileMode = 0600
 // JWKSecretFileMode defines the default filemode for files with JWK Secrets.
 const JWKSecretFileMode = 0644
 
+// HtpasswdSecretFileMode defines the default filemode for files with htpasswd Secrets.
+// Like JWKs, the htpasswd files are read by the worker processes.
+const HtpasswdSecretFileMode = 0644
+
 const configFileMode = 0644
+
+// testMainConfig is the main config for testing the config of a VirtualServer. It defines the variables
+// of the main config that the configs of VirtualServers use.
+const testMainConfig = `events {}
+
+http {
+    map $http_upgrade $vs_connection_header {
+        default upgrade;
+        ''      $default_connection_header;
+    }
+
+    include %s;
+}
+`
 const jsonFileForOpenTracingTracer = "/var/lib/nginx/tracer-config.json"
 
 // ServerConfig holds the config data for an upstream server in NGINX Plus.
 type ServerConfig struct {
-	MaxFails    int
-	MaxConns    int
-	FailTimeout string
-	SlowStart   string
+	MaxFails       int
+	MaxConns       int
+	FailTimeout    string
+	SlowStart      string
+	DrainedServers map[string]bool
+	DownServers    map[string]bool
+	BackupServers  map[string]bool
 }
 
 // The Manager interface updates NGINX configuration, starts, reloads and quits NGINX,
-// updates NGINX Plus upstream servers.
+// updates NGINX Plus upstream servers and key-value pairs.
 type Manager interface {
 	CreateMainConfig(content []byte)
 	CreateConfig(name string, content []byte)
+	StreamConfig(name string, write func(w io.Writer) error) error
 	DeleteConfig(name string)
 	CreateSecret(name string, content []byte, mode os.FileMode) string
 	DeleteSecret(name string)
@@ -48,13 +75,19 @@ type Manager interface {
 	UpdateConfigVersionFile(openTracing bool)
 	SetPlusClients(plusClient *client.NginxClient, plusConfigVersionCheckClient *http.Client)
 	UpdateServersInPlus(upstream string, servers []string, config ServerConfig) error
+	SetKeyValPairInPlus(zone string, key string, value string) error
 	SetOpenTracing(openTracing bool)
+	TestVirtualServerConfig(content []byte) error
+	TestConfig(excludedConfigs ...string) error
+	PromoteStagedConfigs()
+	DiscardStagedConfig(name string)
 }
 
 // LocalManager updates NGINX configuration, starts, reloads and quits NGINX,
 // updates NGINX Plus upstream servers. It assumes that NGINX is running in the same container.
 type LocalManager struct {
 	confdPath                    string
+	stagingPath                  string
 	secretsPath                  string
 	mainConfFilename             string
 	configVersionFilename        string
@@ -69,6 +102,10 @@ type LocalManager struct {
 	plusConfigVersionCheckClient *http.Client
 	metricsCollector             collectors.ManagerCollector
 	OpenTracing                  bool
+	// stagedConfigs are the configs in the staging folder, which are not promoted to the conf.d folder yet.
+	// The value is false for the configs that are deleted.
+	stagedConfigs   map[string]bool
+	stagedConfigsMu sync.Mutex
 }
 
 // NewLocalManager creates a LocalManager.
@@ -78,8 +115,18 @@ func NewLocalManager(confPath string, binaryFilename string, mc collectors.Manag
 		glog.Fatalf("error instantiating a verifyConfigGenerator: %v", err)
 	}
 
+	stagingPath := path.Join(confPath, "staging")
+	// the configs staged before a restart are never promoted
+	if err := os.RemoveAll(stagingPath); err != nil {
+		glog.Fatalf("Failed to clean the staging folder %v: %v", stagingPath, err)
+	}
+	if err := os.MkdirAll(stagingPath, 0755); err != nil {
+		glog.Fatalf("Failed to create the staging folder %v: %v", stagingPath, err)
+	}
+
 	manager := LocalManager{
 		confdPath:             path.Join(confPath, "conf.d"),
+		stagingPath:           stagingPath,
 		secretsPath:           path.Join(confPath, "secrets"),
 		dhparamFilename:       path.Join(confPath, "secrets", "dhparam.pem"),
 		mainConfFilename:      path.Join(confPath, "nginx.conf"),
@@ -91,6 +138,7 @@ func NewLocalManager(confPath string, binaryFilename string, mc collectors.Manag
 		reloadCmd:             fmt.Sprintf("%v -s %v", binaryFilename, "reload"),
 		quitCmd:               fmt.Sprintf("%v -s %v", binaryFilename, "quit"),
 		metricsCollector:      mc,
+		stagedConfigs:         make(map[string]bool),
 	}
 
 	return &manager
@@ -107,9 +155,10 @@ func (lm *LocalManager) CreateMainConfig(content []byte) {
 	}
 }
 
-// CreateConfig creates a configuration file. If the file already exists, it will be overridden.
+// CreateConfig creates a configuration file in the staging folder. If the file already exists, it will be overridden.
+// NGINX uses the file after it is promoted to the conf.d folder by PromoteStagedConfigs.
 func (lm *LocalManager) CreateConfig(name string, content []byte) {
-	filename := lm.getFilenameForConfig(name)
+	filename := lm.getStagedFilenameForConfig(name)
 
 	glog.V(3).Infof("Writing config to %v", filename)
 	glog.V(3).Info(string(content))
@@ -118,23 +167,95 @@ func (lm *LocalManager) CreateConfig(name string, content []byte) {
 	if err != nil {
 		glog.Fatalf("Failed to write config to %v: %v", filename, err)
 	}
+
+	lm.stageConfig(name, true)
 }
 
-// DeleteConfig deletes the configuration file from the conf.d folder.
-func (lm *LocalManager) DeleteConfig(name string) {
-	filename := lm.getFilenameForConfig(name)
+// StreamConfig creates a configuration file in the staging folder with the content that write writes, without keeping
+// the content in memory. The file is replaced atomically. If write fails, the file stays as it is, and the error is returned.
+// It is safe to call it concurrently for different names.
+func (lm *LocalManager) StreamConfig(name string, write func(w io.Writer) error) error {
+	filename := lm.getStagedFilenameForConfig(name)
 
-	glog.V(3).Infof("Deleting config from %v", filename)
+	glog.V(3).Infof("Streaming config to %v", filename)
 
-	if err := os.Remove(filename); err != nil {
+	if err := streamToFileAtomically(filename, lm.stagingPath, configFileMode, write); err != nil {
+		return err
+	}
+
+	lm.stageConfig(name, true)
+
+	return nil
+}
+
+// DeleteConfig stages the deletion of the configuration file from the conf.d folder.
+// The file is deleted when the staged configs are promoted by PromoteStagedConfigs.
+func (lm *LocalManager) DeleteConfig(name string) {
+	filename := lm.getStagedFilenameForConfig(name)
+
+	if err := os.Remove(filename); err != nil && !os.IsNotExist(err) {
 		glog.Warningf("Failed to delete config from %v: %v", filename, err)
 	}
+
+	lm.stageConfig(name, false)
+}
+
+// PromoteStagedConfigs moves the configs of the staging folder to the conf.d folder and deletes the configs
+// which deletion was staged, so that NGINX uses the configs after the next reload.
+func (lm *LocalManager) PromoteStagedConfigs() {
+	lm.stagedConfigsMu.Lock()
+	defer lm.stagedConfigsMu.Unlock()
+
+	for name, created := range lm.stagedConfigs {
+		filename := lm.getFilenameForConfig(name)
+
+		if created {
+			glog.V(3).Infof("Promoting config to %v", filename)
+
+			if err := os.Rename(lm.getStagedFilenameForConfig(name), filename); err != nil {
+				glog.Fatalf("Failed to promote config to %v: %v", filename, err)
+			}
+		} else {
+			glog.V(3).Infof("Deleting config from %v", filename)
+
+			if err := os.Remove(filename); err != nil && !os.IsNotExist(err) {
+				glog.Warningf("Failed to delete config from %v: %v", filename, err)
+			}
+		}
+	}
+
+	lm.stagedConfigs = make(map[string]bool)
+}
+
+// DiscardStagedConfig discards the staged creation or deletion of the configuration file, so that NGINX keeps using
+// the file of the conf.d folder.
+func (lm *LocalManager) DiscardStagedConfig(name string) {
+	lm.stagedConfigsMu.Lock()
+	defer lm.stagedConfigsMu.Unlock()
+
+	filename := lm.getStagedFilenameForConfig(name)
+	if err := os.Remove(filename); err != nil && !os.IsNotExist(err) {
+		glog.Warningf("Failed to discard staged config %v: %v", filename, err)
+	}
+
+	delete(lm.stagedConfigs, name)
+}
+
+func (lm *LocalManager) stageConfig(name string, created bool) {
+	lm.stagedConfigsMu.Lock()
+	defer lm.stagedConfigsMu.Unlock()
+
+	lm.stagedConfigs[name] = created
 }
 
 func (lm *LocalManager) getFilenameForConfig(name string) string {
 	return path.Join(lm.confdPath, name+".conf")
 }
 
+func (lm *LocalManager) getStagedFilenameForConfig(name string) string {
+	return path.Join(lm.stagingPath, name+".conf")
+}
+
 // CreateSecret creates a secret file with the specified name, content and mode. If the file already exists,
 // it will be overridden.
 func (lm *LocalManager) CreateSecret(name string, content []byte, mode os.FileMode) string {
@@ -263,12 +384,18 @@ func (lm *LocalManager) UpdateServersInPlus(upstream string, servers []string, c
 
 	var upsServers []client.UpstreamServer
 	for _, s := range servers {
+		// down and backup are always set, so that the servers that are no longer down, drained or backup are brought back
+		down := config.DownServers[s]
+		backup := config.BackupServers[s]
 		upsServers = append(upsServers, client.UpstreamServer{
 			Server:      s,
 			MaxFails:    &config.MaxFails,
 			MaxConns:    &config.MaxConns,
 			FailTimeout: config.FailTimeout,
 			SlowStart:   config.SlowStart,
+			Drain:       config.DrainedServers[s],
+			Down:        &down,
+			Backup:      &backup,
 		})
 	}
 
@@ -283,6 +410,37 @@ func (lm *LocalManager) UpdateServersInPlus(upstream string, servers []string, c
 	return nil
 }
 
+// SetKeyValPairInPlus sets the value of the key in the keyval zone of NGINX Plus, adding the key if it doesn't exist.
+func (lm *LocalManager) SetKeyValPairInPlus(zone string, key string, value string) error {
+	err := verifyConfigVersion(lm.plusConfigVersionCheckClient, lm.configVersion)
+	if err != nil {
+		return fmt.Errorf("error verifying config version: %v", err)
+	}
+
+	pairs, err := lm.plusClient.GetKeyValPairs(zone)
+	if err != nil {
+		return fmt.Errorf("error getting key-value pairs of %v zone: %v", zone, err)
+	}
+
+	current, exists := pairs[key]
+	if exists && current == value {
+		return nil
+	}
+
+	if exists {
+		err = lm.plusClient.ModifyKeyValPair(zone, key, value)
+	} else {
+		err = lm.plusClient.AddKeyValPair(zone, key, value)
+	}
+	if err != nil {
+		return fmt.Errorf("error setting %v key of %v zone: %v", key, zone, err)
+	}
+
+	glog.V(3).Infof("Set %v key of %v zone to %v", key, zone, value)
+
+	return nil
+}
+
 // CreateOpenTracingTracerConfig creates a json configuration file for the OpenTracing tracer with the content of the string.
 func (lm *LocalManager) CreateOpenTracingTracerConfig(content string) error {
 	glog.V(3).Infof("Writing OpenTracing tracer config file to %v", jsonFileForOpenTracingTracer)
@@ -322,3 +480,93 @@ func verifyConfigVersion(httpClient *http.Client, configVersion int) error {
 func (lm *LocalManager) SetOpenTracing(openTracing bool) {
 	lm.OpenTracing = openTracing
 }
+
+// TestConfig tests the NGINX configuration files with nginx -t, so that NGINX is not reloaded with an invalid config.
+// The staged configs, except for the excluded ones, are tested in place of the configs of the conf.d folder,
+// which are not affected by the test.
+func (lm *LocalManager) TestConfig(excludedConfigs ...string) error {
+	lm.stagedConfigsMu.Lock()
+	defer lm.stagedConfigsMu.Unlock()
+
+	stagedConfigs := make(map[string]bool)
+	for name, created := range lm.stagedConfigs {
+		stagedConfigs[name] = created
+	}
+	for _, name := range excludedConfigs {
+		delete(stagedConfigs, name)
+	}
+
+	if len(stagedConfigs) == 0 {
+		return shellOut(fmt.Sprintf("%v -t -q -c %v", lm.binaryFilename, lm.mainConfFilename))
+	}
+
+	dir, err := ioutil.TempDir("", "nginx-test")
+	if err != nil {
+		return fmt.Errorf("failed to create a directory for the test: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// the conf.d folder of the test links the configs of the conf.d folder, except for the staged ones, and the staged configs
+	confdPath := path.Join(dir, "conf.d")
+	if err := os.Mkdir(confdPath, 0755); err != nil {
+		return fmt.Errorf("failed to create a directory for the test: %v", err)
+	}
+
+	files, err := ioutil.ReadDir(lm.confdPath)
+	if err != nil {
+		return fmt.Errorf("failed to read the configs of %v: %v", lm.confdPath, err)
+	}
+	for _, file := range files {
+		if _, staged := stagedConfigs[strings.TrimSuffix(file.Name(), ".conf")]; staged {
+			continue
+		}
+		if err := os.Symlink(path.Join(lm.confdPath, file.Name()), path.Join(confdPath, file.Name())); err != nil {
+			return fmt.Errorf("failed to link the config %v for the test: %v", file.Name(), err)
+		}
+	}
+	for name, created := range stagedConfigs {
+		if !created {
+			continue
+		}
+		if err := os.Symlink(lm.getStagedFilenameForConfig(name), path.Join(confdPath, name+".conf")); err != nil {
+			return fmt.Errorf("failed to link the staged config %v for the test: %v", name, err)
+		}
+	}
+
+	mainContent, err := ioutil.ReadFile(lm.mainConfFilename)
+	if err != nil {
+		return fmt.Errorf("failed to read the main config: %v", err)
+	}
+	mainContent = bytes.Replace(mainContent, []byte(lm.confdPath+"/"), []byte(confdPath+"/"), -1)
+
+	mainFilename := path.Join(dir, "nginx.conf")
+	if err := createFileAndWrite(mainFilename, mainContent); err != nil {
+		return err
+	}
+
+	return shellOut(fmt.Sprintf("%v -t -q -c %v", lm.binaryFilename, mainFilename))
+}
+
+// TestVirtualServerConfig tests the config of a VirtualServer with nginx -t in a separate main config,
+// so that the running NGINX and its configuration files are not affected.
+func (lm *LocalManager) TestVirtualServerConfig(content []byte) error {
+	dir, err := ioutil.TempDir("", "nginx-test")
+	if err != nil {
+		return fmt.Errorf("failed to create a directory for the test: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	vsFilename := path.Join(dir, "virtualserver.conf")
+	if err := createFileAndWrite(vsFilename, content); err != nil {
+		return err
+	}
+
+	mainFilename := path.Join(dir, "nginx.conf")
+	if err := createFileAndWrite(mainFilename, []byte(fmt.Sprintf(testMainConfig, vsFilename))); err != nil {
+		return err
+	}
+
+	cmd := fmt.Sprintf("%v -t -q -c %v -g 'pid %v; error_log stderr;'", lm.binaryFilename, mainFilename, path.Join(dir, "nginx.pid"))
+
+	return shellOut(cmd)
+}
@@ -0,0 +1,123 @@
+package nginx
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+// fakeNginxBinary is a script which, like nginx -t -q -c <main config>, fails if a config of the conf.d folder
+// that the main config includes is invalid.
+const fakeNginxBinary = `#!/bin/sh
+confd=$(sed -n 's|.*include \(.*\)/\*\.conf;.*|\1|p' "$4")
+! cat "$confd"/*.conf 2>/dev/null | grep -q invalid
+`
+
+func createTestLocalManager(t *testing.T) (*LocalManager, string) {
+	dir, err := ioutil.TempDir("", "nginx")
+	if err != nil {
+		t.Fatalf("Failed to create a temp dir: %v", err)
+	}
+
+	if err := os.Mkdir(path.Join(dir, "conf.d"), 0755); err != nil {
+		t.Fatalf("Failed to create the conf.d dir: %v", err)
+	}
+
+	binaryFilename := path.Join(dir, "nginx")
+	if err := ioutil.WriteFile(binaryFilename, []byte(fakeNginxBinary), 0755); err != nil {
+		t.Fatalf("Failed to write the fake nginx binary: %v", err)
+	}
+
+	lm := NewLocalManager(dir, binaryFilename, nil)
+	lm.CreateMainConfig([]byte(fmt.Sprintf("http {\n    include %s/*.conf;\n}\n", lm.confdPath)))
+
+	return lm, dir
+}
+
+func TestPromoteStagedConfigs(t *testing.T) {
+	lm, dir := createTestLocalManager(t)
+	defer os.RemoveAll(dir)
+
+	filename := path.Join(dir, "conf.d", "vs_default_cafe.conf")
+
+	lm.CreateConfig("vs_default_cafe", []byte("server {}"))
+	if _, err := os.Stat(filename); !os.IsNotExist(err) {
+		t.Errorf("CreateConfig() wrote the config to the conf.d folder before it was promoted")
+	}
+
+	lm.PromoteStagedConfigs()
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("PromoteStagedConfigs() didn't promote the config: %v", err)
+	}
+	if string(content) != "server {}" {
+		t.Errorf("PromoteStagedConfigs() promoted the config %q, expected %q", content, "server {}")
+	}
+
+	lm.DeleteConfig("vs_default_cafe")
+	if _, err := os.Stat(filename); err != nil {
+		t.Errorf("DeleteConfig() deleted the config from the conf.d folder before the deletion was promoted")
+	}
+
+	lm.PromoteStagedConfigs()
+	if _, err := os.Stat(filename); !os.IsNotExist(err) {
+		t.Errorf("PromoteStagedConfigs() didn't delete the config")
+	}
+}
+
+func TestTestConfigWithStagedConfigs(t *testing.T) {
+	lm, dir := createTestLocalManager(t)
+	defer os.RemoveAll(dir)
+
+	lm.CreateConfig("vs_default_cafe", []byte("server {}"))
+	if err := lm.TestConfig(); err != nil {
+		t.Fatalf("TestConfig() returned an unexpected error: %v", err)
+	}
+	lm.PromoteStagedConfigs()
+
+	lm.CreateConfig("vs_default_cafe", []byte("server { invalid; }"))
+	if err := lm.TestConfig(); err == nil {
+		t.Errorf("TestConfig() returned no error for an invalid staged config")
+	}
+	if err := lm.TestConfig("vs_default_cafe"); err != nil {
+		t.Errorf("TestConfig() returned an unexpected error for an excluded invalid staged config: %v", err)
+	}
+
+	content, err := ioutil.ReadFile(path.Join(dir, "conf.d", "vs_default_cafe.conf"))
+	if err != nil {
+		t.Fatalf("Failed to read the config: %v", err)
+	}
+	if string(content) != "server {}" {
+		t.Errorf("TestConfig() changed the config of the conf.d folder to %q", content)
+	}
+
+	lm.DeleteConfig("vs_default_cafe")
+	if err := lm.TestConfig(); err != nil {
+		t.Errorf("TestConfig() returned an unexpected error for a deleted invalid config: %v", err)
+	}
+}
+
+func TestDiscardStagedConfig(t *testing.T) {
+	lm, dir := createTestLocalManager(t)
+	defer os.RemoveAll(dir)
+
+	lm.CreateConfig("default-cafe-ingress", []byte("server {}"))
+	lm.PromoteStagedConfigs()
+
+	lm.CreateConfig("default-cafe-ingress", []byte("server { invalid; }"))
+	lm.DiscardStagedConfig("default-cafe-ingress")
+	if err := lm.TestConfig(); err != nil {
+		t.Errorf("TestConfig() returned an unexpected error after the invalid staged config was discarded: %v", err)
+	}
+
+	lm.PromoteStagedConfigs()
+	content, err := ioutil.ReadFile(path.Join(dir, "conf.d", "default-cafe-ingress.conf"))
+	if err != nil {
+		t.Fatalf("Failed to read the config: %v", err)
+	}
+	if string(content) != "server {}" {
+		t.Errorf("PromoteStagedConfigs() promoted the discarded config %q, expected %q", content, "server {}")
+	}
+}
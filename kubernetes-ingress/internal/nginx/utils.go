@@ -1,8 +1,10 @@
 package nginx
 
 import (
+	"bufio"
 	"bytes"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"os/exec"
@@ -76,3 +78,44 @@ func createFileAndWriteAtomically(filename string, tempPath string, mode os.File
 		glog.Fatalf("Couldn't rename the temp file %v to %v: %v", file.Name(), filename, err)
 	}
 }
+
+// streamToFileAtomically writes the content produced by write to a temp file in tempPath and renames the temp file to filename.
+// If write fails, the temp file is removed, and the file stays as it is. The name of the temp file doesn't end with
+// the extension of filename, so that the temp file doesn't match the includes of the NGINX configuration.
+func streamToFileAtomically(filename string, tempPath string, mode os.FileMode, write func(w io.Writer) error) error {
+	file, err := ioutil.TempFile(tempPath, path.Base(filename)+".tmp")
+	if err != nil {
+		glog.Fatalf("Couldn't create a temp file for the file %v: %v", filename, err)
+	}
+
+	err = file.Chmod(mode)
+	if err != nil {
+		glog.Fatalf("Couldn't change the mode of the temp file %v: %v", file.Name(), err)
+	}
+
+	w := bufio.NewWriter(file)
+	if err := write(w); err != nil {
+		file.Close()
+		if removeErr := os.Remove(file.Name()); removeErr != nil {
+			glog.Warningf("Couldn't remove the temp file %v: %v", file.Name(), removeErr)
+		}
+		return err
+	}
+
+	err = w.Flush()
+	if err != nil {
+		glog.Fatalf("Couldn't write to the temp file %v: %v", file.Name(), err)
+	}
+
+	err = file.Close()
+	if err != nil {
+		glog.Fatalf("Couldn't close the temp file %v: %v", file.Name(), err)
+	}
+
+	err = os.Rename(file.Name(), filename)
+	if err != nil {
+		glog.Fatalf("Couldn't rename the temp file %v to %v: %v", file.Name(), filename, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,54 @@
+package nginx
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestStreamToFileAtomically(t *testing.T) {
+	dir, err := ioutil.TempDir("", "conf.d")
+	if err != nil {
+		t.Fatalf("Failed to create a temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := path.Join(dir, "vs_default_cafe.conf")
+
+	err = streamToFileAtomically(filename, dir, configFileMode, func(w io.Writer) error {
+		_, err := io.WriteString(w, "server {}")
+		return err
+	})
+	if err != nil {
+		t.Fatalf("streamToFileAtomically() returned an unexpected error: %v", err)
+	}
+
+	err = streamToFileAtomically(filename, dir, configFileMode, func(w io.Writer) error {
+		if _, err := io.WriteString(w, "server {"); err != nil {
+			return err
+		}
+		return errors.New("template error")
+	})
+	if err == nil {
+		t.Error("streamToFileAtomically() returned no error for a failed write")
+	}
+
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("Failed to read the file: %v", err)
+	}
+	if string(content) != "server {}" {
+		t.Errorf("streamToFileAtomically() changed the file to %q after a failed write, expected %q", content, "server {}")
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("Failed to read the temp dir: %v", err)
+	}
+	if len(files) != 1 {
+		t.Errorf("streamToFileAtomically() left %d files in the dir, expected 1", len(files))
+	}
+}
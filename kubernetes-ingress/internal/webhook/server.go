@@ -0,0 +1,96 @@
+package webhook
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	api_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+)
+
+// certificateRefreshPeriod is how often the webhook server reloads its TLS Secret,
+// so that a rotated certificate is used without restarting the Ingress Controller.
+const certificateRefreshPeriod = time.Minute
+
+// certificateLoader keeps the certificate of the webhook server in sync with a TLS Secret.
+// It is safe for concurrent use, because the TLS handshakes and the refreshes run in different goroutines.
+type certificateLoader struct {
+	mu              sync.RWMutex
+	cert            *tls.Certificate
+	client          kubernetes.Interface
+	secretNamespace string
+	secretName      string
+}
+
+// load fetches the TLS Secret and replaces the certificate if the Secret is valid.
+func (cl *certificateLoader) load() error {
+	secret, err := cl.client.CoreV1().Secrets(cl.secretNamespace).Get(cl.secretName, meta_v1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("could not get the Secret %v/%v: %v", cl.secretNamespace, cl.secretName, err)
+	}
+
+	cert, err := tls.X509KeyPair(secret.Data[api_v1.TLSCertKey], secret.Data[api_v1.TLSPrivateKeyKey])
+	if err != nil {
+		return fmt.Errorf("the Secret %v/%v is invalid: %v", cl.secretNamespace, cl.secretName, err)
+	}
+
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	cl.cert = &cert
+
+	return nil
+}
+
+func (cl *certificateLoader) refresh() {
+	err := cl.load()
+	if err != nil {
+		glog.Errorf("Error refreshing the certificate of the validation webhook, the previous certificate will be used: %v", err)
+	}
+}
+
+func (cl *certificateLoader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	cl.mu.RLock()
+	defer cl.mu.RUnlock()
+
+	return cl.cert, nil
+}
+
+// RunServer runs an https server that validates VirtualServer and VirtualServerRoute resources
+// for a ValidatingWebhookConfiguration. The certificate of the server is taken from the TLS Secret
+// and reloaded periodically. RunServer fails if the Secret can't be loaded on start.
+func RunServer(port int, validator *Validator, client kubernetes.Interface, secretNamespace string, secretName string, stopCh <-chan struct{}) {
+	loader := &certificateLoader{
+		client:          client,
+		secretNamespace: secretNamespace,
+		secretName:      secretName,
+	}
+
+	err := loader.load()
+	if err != nil {
+		glog.Fatalf("Error loading the certificate of the validation webhook: %v", err)
+	}
+
+	go wait.Until(loader.refresh, certificateRefreshPeriod, stopCh)
+
+	mux := http.NewServeMux()
+	mux.Handle(validateEndpoint, validator)
+
+	server := &http.Server{
+		Addr:    fmt.Sprintf(":%v", port),
+		Handler: mux,
+		TLSConfig: &tls.Config{
+			GetCertificate: loader.getCertificate,
+			MinVersion:     tls.VersionTLS12,
+		},
+	}
+
+	glog.Infof("Starting validation webhook listener on: %v%v", server.Addr, validateEndpoint)
+	glog.Fatal("Error in validation webhook listener server: ", server.ListenAndServeTLS("", ""))
+}
@@ -0,0 +1,218 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/golang/glog"
+	"github.com/nginxinc/kubernetes-ingress/internal/configs"
+	conf_v1 "github.com/nginxinc/kubernetes-ingress/pkg/apis/configuration/v1"
+	"github.com/nginxinc/kubernetes-ingress/pkg/apis/configuration/validation"
+	admission "k8s.io/api/admission/v1beta1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// validateEndpoint is the path where the API server sends the admission reviews.
+const validateEndpoint = "/validate"
+
+// maxRequestBodySize limits the size of an admission review. The API server limits the size of a resource to 1.5MB.
+const maxRequestBodySize = 3 * 1024 * 1024
+
+// HostConflictPolicy defines how the Validator handles a VirtualServer with a host that is used by another VirtualServer.
+type HostConflictPolicy int
+
+const (
+	// OldestWinsHostConflictPolicy admits the VirtualServer. The Ingress Controller configures the oldest VirtualServer
+	// with the host and rejects the others.
+	OldestWinsHostConflictPolicy HostConflictPolicy = iota
+	// RejectHostConflictPolicy denies the VirtualServer.
+	RejectHostConflictPolicy
+)
+
+// ParseHostConflictPolicy parses the name of a HostConflictPolicy.
+func ParseHostConflictPolicy(policy string) (HostConflictPolicy, error) {
+	switch policy {
+	case "oldest-wins":
+		return OldestWinsHostConflictPolicy, nil
+	case "reject":
+		return RejectHostConflictPolicy, nil
+	}
+
+	return OldestWinsHostConflictPolicy, fmt.Errorf("%q is not one of \"oldest-wins\" or \"reject\"", policy)
+}
+
+// VirtualServerLister lists the VirtualServers handled by the Ingress Controller.
+type VirtualServerLister interface {
+	GetVirtualServers() []*conf_v1.VirtualServer
+}
+
+// Validator validates VirtualServer and VirtualServerRoute resources in admission reviews,
+// so that invalid resources are rejected when they are applied.
+type Validator struct {
+	isPlus             bool
+	emulateQueue       bool
+	allowedVariables   sets.String
+	strictness         validation.Strictness
+	hostConflictPolicy HostConflictPolicy
+	virtualServers     VirtualServerLister
+}
+
+// NewValidator creates a Validator. The VirtualServerLister is used to find the VirtualServers with the same host.
+func NewValidator(isPlus bool, emulateQueue bool, allowedVariables sets.String, strictness validation.Strictness,
+	hostConflictPolicy HostConflictPolicy, virtualServers VirtualServerLister) *Validator {
+	return &Validator{
+		isPlus:             isPlus,
+		emulateQueue:       emulateQueue,
+		allowedVariables:   allowedVariables,
+		strictness:         strictness,
+		hostConflictPolicy: hostConflictPolicy,
+		virtualServers:     virtualServers,
+	}
+}
+
+// ServeHTTP handles an admission review sent by the API server.
+func (v *Validator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBodySize))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("error reading the request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	var review admission.AdmissionReview
+	err = json.Unmarshal(body, &review)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("error decoding the admission review: %v", err), http.StatusBadRequest)
+		return
+	}
+	if review.Request == nil {
+		http.Error(w, "the admission review doesn't include a request", http.StatusBadRequest)
+		return
+	}
+
+	review.Response = v.review(review.Request)
+	review.Response.UID = review.Request.UID
+	review.Request = nil
+
+	resp, err := json.Marshal(review)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("error encoding the admission review: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, err = w.Write(resp)
+	if err != nil {
+		glog.Warningf("Error while sending a response for the %v path: %v", validateEndpoint, err)
+	}
+}
+
+func (v *Validator) review(req *admission.AdmissionRequest) *admission.AdmissionResponse {
+	if req.Operation != admission.Create && req.Operation != admission.Update {
+		return allow()
+	}
+
+	var warnings field.ErrorList
+	var err error
+
+	switch req.Kind.Kind {
+	case "VirtualServer":
+		var vs conf_v1.VirtualServer
+		err = json.Unmarshal(req.Object.Raw, &vs)
+		if err != nil {
+			return deny(fmt.Sprintf("error decoding the VirtualServer: %v", err))
+		}
+		warnings, err = validation.ValidateVirtualServerWithWarnings(&vs, v.isPlus, v.emulateQueue, v.allowedVariables, v.strictness)
+		if err == nil {
+			err = v.findHostConflict(&vs)
+		}
+		// an update doesn't change the namespace and the name, so only a new VirtualServer can cause a collision
+		if err == nil && req.Operation == admission.Create {
+			err = v.findNameConflict(&vs)
+		}
+	case "VirtualServerRoute":
+		var vsr conf_v1.VirtualServerRoute
+		err = json.Unmarshal(req.Object.Raw, &vsr)
+		if err != nil {
+			return deny(fmt.Sprintf("error decoding the VirtualServerRoute: %v", err))
+		}
+		warnings, err = validation.ValidateVirtualServerRouteWithWarnings(&vsr, v.isPlus, v.emulateQueue, v.allowedVariables, v.strictness)
+	default:
+		return allow()
+	}
+
+	if err != nil {
+		glog.V(3).Infof("Rejected %v %v/%v: %v", req.Kind.Kind, req.Namespace, req.Name, err)
+		return deny(fmt.Sprintf("%v is invalid: %v", req.Kind.Kind, err))
+	}
+
+	for _, w := range warnings {
+		glog.V(3).Infof("Admitted %v %v/%v with warning: %v", req.Kind.Kind, req.Namespace, req.Name, w)
+	}
+
+	return allow()
+}
+
+// findHostConflict returns an error if another VirtualServer uses the host of the VirtualServer
+// and the policy rejects such VirtualServers.
+func (v *Validator) findHostConflict(virtualServer *conf_v1.VirtualServer) error {
+	for _, vs := range v.virtualServers.GetVirtualServers() {
+		if vs.Spec.Host != virtualServer.Spec.Host || (vs.Namespace == virtualServer.Namespace && vs.Name == virtualServer.Name) {
+			continue
+		}
+
+		if v.hostConflictPolicy == RejectHostConflictPolicy {
+			return fmt.Errorf("host %s is used by VirtualServer %s/%s", vs.Spec.Host, vs.Namespace, vs.Name)
+		}
+
+		glog.V(3).Infof("VirtualServer %s/%s uses the host %s of VirtualServer %s/%s, the oldest VirtualServer keeps the host",
+			virtualServer.Namespace, virtualServer.Name, vs.Spec.Host, vs.Namespace, vs.Name)
+	}
+
+	return nil
+}
+
+// findNameConflict returns an error if the names in the config of the VirtualServer collide with the names
+// of another VirtualServer, because the Ingress Controller would reject the newer VirtualServer anyway.
+func (v *Validator) findNameConflict(virtualServer *conf_v1.VirtualServer) error {
+	safeNsName := configs.GetSafeNsNameForVirtualServer(virtualServer)
+
+	for _, vs := range v.virtualServers.GetVirtualServers() {
+		if vs.Namespace == virtualServer.Namespace && vs.Name == virtualServer.Name {
+			continue
+		}
+
+		if configs.GetSafeNsNameForVirtualServer(vs) == safeNsName {
+			return fmt.Errorf("the names generated for the config collide with the names of VirtualServer %s/%s", vs.Namespace, vs.Name)
+		}
+	}
+
+	return nil
+}
+
+func allow() *admission.AdmissionResponse {
+	return &admission.AdmissionResponse{
+		Allowed: true,
+	}
+}
+
+func deny(msg string) *admission.AdmissionResponse {
+	return &admission.AdmissionResponse{
+		Allowed: false,
+		Result: &meta_v1.Status{
+			Status:  meta_v1.StatusFailure,
+			Reason:  meta_v1.StatusReasonInvalid,
+			Code:    http.StatusUnprocessableEntity,
+			Message: msg,
+		},
+	}
+}
@@ -0,0 +1,317 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	conf_v1 "github.com/nginxinc/kubernetes-ingress/pkg/apis/configuration/v1"
+	"github.com/nginxinc/kubernetes-ingress/pkg/apis/configuration/validation"
+	admission "k8s.io/api/admission/v1beta1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func createAdmissionReview(t *testing.T, kind string, operation admission.Operation, obj interface{}) []byte {
+	raw, err := json.Marshal(obj)
+	if err != nil {
+		t.Fatalf("Failed to marshal the object: %v", err)
+	}
+
+	review := admission.AdmissionReview{
+		Request: &admission.AdmissionRequest{
+			UID:       "test-uid",
+			Kind:      meta_v1.GroupVersionKind{Group: "k8s.nginx.org", Version: "v1", Kind: kind},
+			Operation: operation,
+			Object:    runtime.RawExtension{Raw: raw},
+		},
+	}
+
+	body, err := json.Marshal(review)
+	if err != nil {
+		t.Fatalf("Failed to marshal the admission review: %v", err)
+	}
+
+	return body
+}
+
+type fakeVirtualServerLister struct {
+	virtualServers []*conf_v1.VirtualServer
+}
+
+func (l *fakeVirtualServerLister) GetVirtualServers() []*conf_v1.VirtualServer {
+	return l.virtualServers
+}
+
+func TestValidatorServeHTTP(t *testing.T) {
+	validVirtualServer := conf_v1.VirtualServer{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "cafe", Namespace: "default"},
+		Spec: conf_v1.VirtualServerSpec{
+			Host: "cafe.example.com",
+		},
+	}
+
+	invalidVirtualServer := conf_v1.VirtualServer{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "cafe", Namespace: "default"},
+		Spec:       conf_v1.VirtualServerSpec{},
+	}
+
+	invalidVirtualServerRoute := conf_v1.VirtualServerRoute{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "coffee", Namespace: "default"},
+		Spec:       conf_v1.VirtualServerRouteSpec{},
+	}
+
+	tests := []struct {
+		kind      string
+		operation admission.Operation
+		obj       interface{}
+		expected  bool
+		msg       string
+	}{
+		{
+			kind:      "VirtualServer",
+			operation: admission.Create,
+			obj:       validVirtualServer,
+			expected:  true,
+			msg:       "valid VirtualServer",
+		},
+		{
+			kind:      "VirtualServer",
+			operation: admission.Update,
+			obj:       invalidVirtualServer,
+			expected:  false,
+			msg:       "invalid VirtualServer",
+		},
+		{
+			kind:      "VirtualServerRoute",
+			operation: admission.Create,
+			obj:       invalidVirtualServerRoute,
+			expected:  false,
+			msg:       "invalid VirtualServerRoute",
+		},
+		{
+			kind:      "VirtualServer",
+			operation: admission.Delete,
+			obj:       invalidVirtualServer,
+			expected:  true,
+			msg:       "deletion of an invalid VirtualServer",
+		},
+		{
+			kind:      "Policy",
+			operation: admission.Create,
+			obj:       conf_v1.Policy{},
+			expected:  true,
+			msg:       "unsupported kind",
+		},
+	}
+
+	validator := NewValidator(false, false, nil, validation.StrictValidation, OldestWinsHostConflictPolicy, &fakeVirtualServerLister{})
+
+	for _, test := range tests {
+		body := createAdmissionReview(t, test.kind, test.operation, test.obj)
+
+		rec := httptest.NewRecorder()
+		validator.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, validateEndpoint, bytes.NewReader(body)))
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("ServeHTTP() returned code %d for the case of %s", rec.Code, test.msg)
+		}
+
+		var review admission.AdmissionReview
+		err := json.Unmarshal(rec.Body.Bytes(), &review)
+		if err != nil {
+			t.Fatalf("ServeHTTP() returned an invalid admission review for the case of %s: %v", test.msg, err)
+		}
+
+		if review.Response == nil {
+			t.Fatalf("ServeHTTP() returned an admission review without a response for the case of %s", test.msg)
+		}
+		if review.Response.UID != "test-uid" {
+			t.Errorf("ServeHTTP() returned a response with UID %q for the case of %s", review.Response.UID, test.msg)
+		}
+		if review.Response.Allowed != test.expected {
+			t.Errorf("ServeHTTP() returned allowed %v but expected %v for the case of %s", review.Response.Allowed, test.expected, test.msg)
+		}
+		if !review.Response.Allowed && (review.Response.Result == nil || review.Response.Result.Message == "") {
+			t.Errorf("ServeHTTP() returned a denial without a message for the case of %s", test.msg)
+		}
+	}
+}
+
+func TestValidatorServeHTTPFails(t *testing.T) {
+	validator := NewValidator(false, false, nil, validation.StrictValidation, OldestWinsHostConflictPolicy, &fakeVirtualServerLister{})
+
+	tests := []struct {
+		method       string
+		body         string
+		expectedCode int
+		msg          string
+	}{
+		{
+			method:       http.MethodGet,
+			body:         "",
+			expectedCode: http.StatusMethodNotAllowed,
+			msg:          "unsupported method",
+		},
+		{
+			method:       http.MethodPost,
+			body:         "{",
+			expectedCode: http.StatusBadRequest,
+			msg:          "invalid json",
+		},
+		{
+			method:       http.MethodPost,
+			body:         "{}",
+			expectedCode: http.StatusBadRequest,
+			msg:          "missing request",
+		},
+	}
+
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		validator.ServeHTTP(rec, httptest.NewRequest(test.method, validateEndpoint, bytes.NewReader([]byte(test.body))))
+
+		if rec.Code != test.expectedCode {
+			t.Errorf("ServeHTTP() returned code %d but expected %d for the case of %s", rec.Code, test.expectedCode, test.msg)
+		}
+	}
+}
+
+func TestValidatorHostConflict(t *testing.T) {
+	existing := &conf_v1.VirtualServer{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "cafe", Namespace: "default"},
+		Spec:       conf_v1.VirtualServerSpec{Host: "cafe.example.com"},
+	}
+	lister := &fakeVirtualServerLister{virtualServers: []*conf_v1.VirtualServer{existing}}
+
+	duplicate := conf_v1.VirtualServer{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "cafe-copy", Namespace: "other"},
+		Spec:       conf_v1.VirtualServerSpec{Host: "cafe.example.com"},
+	}
+
+	tests := []struct {
+		policy   HostConflictPolicy
+		obj      conf_v1.VirtualServer
+		expected bool
+		msg      string
+	}{
+		{
+			policy:   RejectHostConflictPolicy,
+			obj:      duplicate,
+			expected: false,
+			msg:      "duplicate host with the reject policy",
+		},
+		{
+			policy:   OldestWinsHostConflictPolicy,
+			obj:      duplicate,
+			expected: true,
+			msg:      "duplicate host with the oldest-wins policy",
+		},
+		{
+			policy:   RejectHostConflictPolicy,
+			obj:      *existing,
+			expected: true,
+			msg:      "update of the VirtualServer that uses the host",
+		},
+	}
+
+	for _, test := range tests {
+		validator := NewValidator(false, false, nil, validation.StrictValidation, test.policy, lister)
+		body := createAdmissionReview(t, "VirtualServer", admission.Update, test.obj)
+
+		rec := httptest.NewRecorder()
+		validator.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, validateEndpoint, bytes.NewReader(body)))
+
+		var review admission.AdmissionReview
+		err := json.Unmarshal(rec.Body.Bytes(), &review)
+		if err != nil || review.Response == nil {
+			t.Fatalf("ServeHTTP() returned an invalid admission review for the case of %s: %v", test.msg, err)
+		}
+		if review.Response.Allowed != test.expected {
+			t.Errorf("ServeHTTP() returned allowed %v but expected %v for the case of %s", review.Response.Allowed, test.expected, test.msg)
+		}
+	}
+}
+
+func TestValidatorNameConflict(t *testing.T) {
+	existing := &conf_v1.VirtualServer{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "tea", Namespace: "cafe-a"},
+		Spec:       conf_v1.VirtualServerSpec{Host: "tea.example.com"},
+	}
+	lister := &fakeVirtualServerLister{virtualServers: []*conf_v1.VirtualServer{existing}}
+
+	colliding := conf_v1.VirtualServer{
+		ObjectMeta: meta_v1.ObjectMeta{Name: "a-tea", Namespace: "cafe"},
+		Spec:       conf_v1.VirtualServerSpec{Host: "a-tea.example.com"},
+	}
+
+	tests := []struct {
+		operation admission.Operation
+		obj       conf_v1.VirtualServer
+		expected  bool
+		msg       string
+	}{
+		{
+			operation: admission.Create,
+			obj:       colliding,
+			expected:  false,
+			msg:       "creation of a VirtualServer with colliding names",
+		},
+		{
+			operation: admission.Update,
+			obj:       colliding,
+			expected:  true,
+			msg:       "update of a VirtualServer with colliding names",
+		},
+	}
+
+	for _, test := range tests {
+		validator := NewValidator(false, false, nil, validation.StrictValidation, OldestWinsHostConflictPolicy, lister)
+		body := createAdmissionReview(t, "VirtualServer", test.operation, test.obj)
+
+		rec := httptest.NewRecorder()
+		validator.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, validateEndpoint, bytes.NewReader(body)))
+
+		var review admission.AdmissionReview
+		err := json.Unmarshal(rec.Body.Bytes(), &review)
+		if err != nil || review.Response == nil {
+			t.Fatalf("ServeHTTP() returned an invalid admission review for the case of %s: %v", test.msg, err)
+		}
+		if review.Response.Allowed != test.expected {
+			t.Errorf("ServeHTTP() returned allowed %v but expected %v for the case of %s", review.Response.Allowed, test.expected, test.msg)
+		}
+	}
+}
+
+func TestParseHostConflictPolicy(t *testing.T) {
+	tests := []struct {
+		policy   string
+		expected HostConflictPolicy
+	}{
+		{
+			policy:   "oldest-wins",
+			expected: OldestWinsHostConflictPolicy,
+		},
+		{
+			policy:   "reject",
+			expected: RejectHostConflictPolicy,
+		},
+	}
+
+	for _, test := range tests {
+		result, err := ParseHostConflictPolicy(test.policy)
+		if err != nil {
+			t.Errorf("ParseHostConflictPolicy(%q) returned unexpected error: %v", test.policy, err)
+		}
+		if result != test.expected {
+			t.Errorf("ParseHostConflictPolicy(%q) returned %v but expected %v", test.policy, result, test.expected)
+		}
+	}
+
+	_, err := ParseHostConflictPolicy("newest-wins")
+	if err == nil {
+		t.Errorf("ParseHostConflictPolicy() returned no error for an invalid policy")
+	}
+}
@@ -1,5 +1,6 @@
 // +k8s:deepcopy-gen=package
 // +groupName=k8s.nginx.org
+// +kubebuilder:validation:Optional
 
 // Package v1 is the v1 version of the API.
 package v1
@@ -6,49 +6,209 @@ import (
 
 // +genclient
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:resource:shortName=vs
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Host",type=string,JSONPath=`.spec.host`
+// +kubebuilder:printcolumn:name="State",type=string,JSONPath=`.status.state`,description="Current state of the VirtualServer. If the resource has a valid status, it means it has been validated and accepted by the Ingress Controller."
+// +kubebuilder:printcolumn:name="IP",type=string,JSONPath=`.status.externalEndpoints[*].ip`
+// +kubebuilder:printcolumn:name="Ports",type=string,JSONPath=`.status.externalEndpoints[*].ports`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // VirtualServer defines the VirtualServer resource.
 type VirtualServer struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
 
-	Spec VirtualServerSpec `json:"spec"`
+	Spec   VirtualServerSpec   `json:"spec"`
+	Status VirtualServerStatus `json:"status"`
 }
 
 // VirtualServerSpec is the spec of the VirtualServer resource.
 type VirtualServerSpec struct {
-	Host      string     `json:"host"`
-	TLS       *TLS       `json:"tls"`
-	Upstreams []Upstream `json:"upstreams"`
-	Routes    []Route    `json:"routes"`
+	Host      string                 `json:"host"`
+	TLS       *TLS                   `json:"tls"`
+	Listener  *VirtualServerListener `json:"listener"`
+	Server    *VirtualServerServer   `json:"server"`
+	Tracing   *Tracing               `json:"tracing"`
+	Policies  []PolicyReference      `json:"policies"`
+	Upstreams []Upstream             `json:"upstreams"`
+	Routes    []Route                `json:"routes"`
+}
+
+// VirtualServerStatus defines the status of the VirtualServer resource.
+type VirtualServerStatus struct {
+	// State is Valid, Warning or Invalid.
+	State string `json:"state,omitempty"`
+	// Reason is a one-word CamelCase reason for the state, such as AddedOrUpdated or Rejected.
+	Reason string `json:"reason,omitempty"`
+	// Message is a human-readable explanation of the state.
+	Message           string                   `json:"message,omitempty"`
+	ExternalEndpoints []ExternalEndpoint       `json:"externalEndpoints,omitempty"`
+	Conditions        []VirtualServerCondition `json:"conditions,omitempty"`
+}
+
+// ExternalEndpoint defines the IP or hostname and the ports used to connect to the VirtualServer.
+type ExternalEndpoint struct {
+	IP       string `json:"ip,omitempty"`
+	Hostname string `json:"hostname,omitempty"`
+	Ports    string `json:"ports,omitempty"`
+}
+
+// VirtualServerCondition describes an aspect of the state of the VirtualServer resource.
+type VirtualServerCondition struct {
+	// Type is the aspect of the state, such as RoutesResolved.
+	Type string `json:"type"`
+	// Status is True, False or Unknown.
+	Status string `json:"status"`
+	// Reason is a one-word CamelCase reason for the last transition of the condition.
+	Reason string `json:"reason,omitempty"`
+	// Message is a human-readable explanation of the condition.
+	Message            string      `json:"message,omitempty"`
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+}
+
+// VirtualServerListener defines the ports a VirtualServer listens on in addition to the default ports 80 and 443.
+type VirtualServerListener struct {
+	HTTP  int `json:"http"`
+	HTTPS int `json:"https"`
+}
+
+// VirtualServerServer defines the configuration of the server of a VirtualServer.
+type VirtualServerServer struct {
+	RealIP     *RealIP     `json:"realIP"`
+	AccessLog  *AccessLog  `json:"accessLog"`
+	ErrorLog   *ErrorLog   `json:"errorLog"`
+	RequestID  *RequestID  `json:"requestID"`
+	LimitConn  *LimitConn  `json:"limitConn"`
+	XForwarded *XForwarded `json:"xForwarded"`
+}
+
+// XForwarded defines how the X-Forwarded-* headers are passed to the upstreams.
+// The fields that are set override the x-forwarded-* keys of the ConfigMap.
+type XForwarded struct {
+	// For is the mode of the X-Forwarded-For header, which is append, overwrite, preserve or remove.
+	For string `json:"for"`
+	// Proto is the mode of the X-Forwarded-Proto header, which is overwrite, preserve or remove.
+	Proto string `json:"proto"`
+	// Host is the mode of the X-Forwarded-Host header, which is overwrite, preserve or remove.
+	Host string `json:"host"`
+	// Port is the mode of the X-Forwarded-Port header, which is overwrite, preserve or remove.
+	Port string `json:"port"`
+}
+
+// LimitConn defines a limit of the number of the connections of a client.
+type LimitConn struct {
+	// Key identifies a client. The default is ${binary_remote_addr}.
+	Key string `json:"key"`
+	// ZoneSize is the size of the shared memory zone that keeps the number of the connections. The default is 10m.
+	ZoneSize string `json:"zoneSize"`
+	// Max is the maximum number of the connections of a client.
+	Max int `json:"max"`
+}
+
+// RealIP defines how the address of a client is taken from a request header.
+// The fields that are set override the real IP keys of the ConfigMap.
+type RealIP struct {
+	SetRealIPFrom []string `json:"setRealIPFrom"`
+	Header        string   `json:"header"`
+	Recursive     *bool    `json:"recursive"`
+}
+
+// AccessLog defines the access log of a VirtualServer.
+// FormatName, FormatPreset and Format are mutually exclusive. If none is set, the main format of the ConfigMap is used.
+type AccessLog struct {
+	// Enable turns the access log on or off for the VirtualServer. The default is true.
+	Enable *bool `json:"enable"`
+	// FormatName is the name of a log format defined in the http context, such as main or combined.
+	FormatName string `json:"formatName"`
+	// FormatPreset is a log format generated by the Ingress Controller for the VirtualServer. The only preset is json.
+	FormatPreset string `json:"formatPreset"`
+	// Format is a log format for the VirtualServer only.
+	Format string `json:"format"`
+	// Destination is a file in /var/log/nginx or a syslog server. The default is /var/log/nginx/access.log.
+	Destination string `json:"destination"`
+}
+
+// ErrorLog defines the error log of a VirtualServer.
+type ErrorLog struct {
+	// Destination is a file in /var/log/nginx, stderr or a syslog server. The default is /var/log/nginx/error.log.
+	Destination string `json:"destination"`
+	// Level is the minimum level of the logged messages. The default is set in the error-log-level ConfigMap key.
+	Level string `json:"level"`
+}
+
+// RequestID defines how the ID of a request is passed to the upstreams and returned to the client.
+type RequestID struct {
+	Enable bool `json:"enable"`
+	// Header is the name of the header with the ID. The default is X-Request-ID.
+	Header string `json:"header"`
+	// Preserve keeps the ID from the header of the request if the client or a downstream proxy set it.
+	// Otherwise, the ID generated by NGINX is used.
+	Preserve bool `json:"preserve"`
+}
+
+// Tracing defines the OpenTracing instrumentation of the requests to a VirtualServer.
+// The tracer and its service name are configured globally through the ConfigMap.
+type Tracing struct {
+	Enable bool `json:"enable"`
+	// OperationName is the name of the span of a request. The default is the name of the location.
+	OperationName string `json:"operationName"`
+	// SamplingPercentage is the percentage of the requests that the tracer is asked to sample
+	// through the sampling.priority tag. If not set, the sampler of the tracer decides.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	SamplingPercentage *int         `json:"samplingPercentage"`
+	Tags               []TracingTag `json:"tags"`
+}
+
+// TracingTag defines a tag added to the span of a request. The value can include NGINX variables.
+type TracingTag struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
 }
 
 // Upstream defines an upstream.
 type Upstream struct {
-	Name                     string            `json:"name"`
-	Service                  string            `json:"service"`
-	Subselector              map[string]string `json:"subselector"`
-	Port                     uint16            `json:"port"`
-	LBMethod                 string            `json:"lb-method"`
-	FailTimeout              string            `json:"fail-timeout"`
-	MaxFails                 *int              `json:"max-fails"`
-	MaxConns                 *int              `json:"max-conns"`
-	Keepalive                *int              `json:"keepalive"`
-	ProxyConnectTimeout      string            `json:"connect-timeout"`
-	ProxyReadTimeout         string            `json:"read-timeout"`
-	ProxySendTimeout         string            `json:"send-timeout"`
-	ProxyNextUpstream        string            `json:"next-upstream"`
-	ProxyNextUpstreamTimeout string            `json:"next-upstream-timeout"`
-	ProxyNextUpstreamTries   int               `json:"next-upstream-tries"`
-	ProxyBuffering           *bool             `json:"buffering"`
-	ProxyBuffers             *UpstreamBuffers  `json:"buffers"`
-	ProxyBufferSize          string            `json:"buffer-size"`
-	ClientMaxBodySize        string            `json:"client-max-body-size"`
-	TLS                      UpstreamTLS       `json:"tls"`
-	HealthCheck              *HealthCheck      `json:"healthCheck"`
-	SlowStart                string            `json:"slow-start"`
-	Queue                    *UpstreamQueue    `json:"queue"`
-	SessionCookie            *SessionCookie    `json:"sessionCookie"`
+	Name        string            `json:"name"`
+	Service     string            `json:"service"`
+	Subselector map[string]string `json:"subselector"`
+	Port        uint16            `json:"port"`
+	PortName    string            `json:"portName"`
+	// +kubebuilder:validation:Pattern=`^\s*(round_robin|least_conn|ip_hash|random|random two|random two least_conn|random two least_time=(header|last_byte)|least_time (header|last_byte)( inflight)?|hash [^ ]+( consistent)?)?\s*$`
+	LBMethod                 string           `json:"lb-method"`
+	FailTimeout              string           `json:"fail-timeout"`
+	MaxFails                 *int             `json:"max-fails"`
+	MaxConns                 *int             `json:"max-conns"`
+	Keepalive                *int             `json:"keepalive"`
+	ProxyConnectTimeout      string           `json:"connect-timeout"`
+	ProxyReadTimeout         string           `json:"read-timeout"`
+	ProxySendTimeout         string           `json:"send-timeout"`
+	ProxyNextUpstream        string           `json:"next-upstream"`
+	ProxyNextUpstreamTimeout string           `json:"next-upstream-timeout"`
+	ProxyNextUpstreamTries   int              `json:"next-upstream-tries"`
+	ProxyBuffering           *bool            `json:"buffering"`
+	ProxyBuffers             *UpstreamBuffers `json:"buffers"`
+	ProxyBufferSize          string           `json:"buffer-size"`
+	ProxyRequestBuffering    *bool            `json:"request-buffering"`
+	ClientMaxBodySize        string           `json:"client-max-body-size"`
+	ClientBodyTimeout        string           `json:"client-body-timeout"`
+	TLS                      UpstreamTLS      `json:"tls"`
+	HealthCheck              *HealthCheck     `json:"healthCheck"`
+	SlowStart                string           `json:"slow-start"`
+	Queue                    *UpstreamQueue   `json:"queue"`
+	SessionCookie            *SessionCookie   `json:"sessionCookie"`
+	Resolve                  bool             `json:"resolve"`
+	// Retries configures when and how many times a request is passed to the next server, replacing next-upstream,
+	// next-upstream-timeout and next-upstream-tries
+	Retries *UpstreamRetries `json:"retries"`
+	// TopologyPolicy configures how the zones of the endpoints affect the servers of the upstream. With prefer-zone,
+	// the endpoints in other zones than the zone of the Ingress Controller are backup servers.
+	TopologyPolicy string `json:"topologyPolicy"`
+	// UseClusterIP makes the cluster IP of the service the only server of the upstream instead of the endpoints of the service.
+	UseClusterIP bool `json:"useClusterIP"`
+	// IncludeNotReadyEndpoints adds the endpoints that are not ready, such as of the pods that fail their readiness probes,
+	// to the upstream.
+	IncludeNotReadyEndpoints bool `json:"includeNotReadyEndpoints"`
 }
 
 // UpstreamBuffers defines Buffer Configuration for an Upstream
@@ -64,19 +224,50 @@ type UpstreamTLS struct {
 
 // HealthCheck defines the parameters for active Upstream HealthChecks.
 type HealthCheck struct {
-	Enable         bool         `json:"enable"`
-	Path           string       `json:"path"`
-	Interval       string       `json:"interval"`
-	Jitter         string       `json:"jitter"`
-	Fails          int          `json:"fails"`
-	Passes         int          `json:"passes"`
-	Port           int          `json:"port"`
-	TLS            *UpstreamTLS `json:"tls"`
-	ConnectTimeout string       `json:"connect-timeout"`
-	ReadTimeout    string       `json:"read-timeout"`
-	SendTimeout    string       `json:"send-timeout"`
-	Headers        []Header     `json:"headers"`
-	StatusMatch    string       `json:"statusMatch"`
+	Enable          bool            `json:"enable"`
+	Path            string          `json:"path"`
+	Interval        string          `json:"interval"`
+	Jitter          string          `json:"jitter"`
+	Fails           int             `json:"fails"`
+	Passes          int             `json:"passes"`
+	Port            int             `json:"port"`
+	TLS             *HealthCheckTLS `json:"tls"`
+	ConnectTimeout  string          `json:"connect-timeout"`
+	ReadTimeout     string          `json:"read-timeout"`
+	SendTimeout     string          `json:"send-timeout"`
+	Headers         []Header        `json:"headers"`
+	StatusMatch     string          `json:"statusMatch"`
+	Mandatory       bool            `json:"mandatory"`
+	Persistent      bool            `json:"persistent"`
+	KeepaliveTime   string          `json:"keepalive-time"`
+	CloseConnection bool            `json:"close-connection"`
+	// BodyMatch matches the body of the responses by a regular expression, which is negated by a leading '!'
+	BodyMatch string `json:"bodyMatch"`
+	// HeaderMatches are the conditions on the headers of the responses, all of which must succeed
+	HeaderMatches []HeaderMatch `json:"headerMatches"`
+	// PortName is the name of a port of the service, such as an admin port, whose target port receives the health checks
+	PortName string `json:"portName"`
+}
+
+// HeaderMatch defines a condition on a header of the responses to health checks.
+type HeaderMatch struct {
+	Name string `json:"name"`
+	// Value is the required value of the header. Without a value, the header only needs to be present
+	Value string `json:"value"`
+	// ValueRegex matches the value of the header by a regular expression, which is negated by a leading '!'
+	ValueRegex string `json:"valueRegex"`
+}
+
+// HealthCheckTLS defines a TLS configuration for the health checks of an Upstream.
+type HealthCheckTLS struct {
+	Enable bool `json:"enable"`
+	// ServerName enables passing the name of the server through SNI
+	ServerName bool `json:"serverName"`
+	// SSLName is the name of the server used to verify its certificate and passed through SNI
+	SSLName string `json:"sslName"`
+	// VerifyServer verifies the certificates of the endpoints with the CA certificate of TrustedCertSecret
+	VerifyServer      bool   `json:"verifyServer"`
+	TrustedCertSecret string `json:"trustedCertSecret"`
 }
 
 // Header defines an HTTP Header.
@@ -98,11 +289,33 @@ type SessionCookie struct {
 
 // Route defines a route.
 type Route struct {
-	Path    string  `json:"path"`
-	Route   string  `json:"route"`
-	Action  *Action `json:"action"`
-	Splits  []Split `json:"splits"`
-	Matches []Match `json:"matches"`
+	Path          string            `json:"path"`
+	Policies      []PolicyReference `json:"policies"`
+	Route         string            `json:"route"`
+	Action        *Action           `json:"action"`
+	Splits        []Split           `json:"splits"`
+	Matches       []Match           `json:"matches"`
+	SplitsCache   *SplitsCache      `json:"splitsCache"`
+	IgnoreHeaders []string          `json:"ignoreHeaders"`
+	// SplitsPersistence pins a client to the split it first received
+	SplitsPersistence *SplitsPersistence `json:"splitsPersistence"`
+	// SplitsDynamicWeights allows to change the weights of the splits through the NGINX Plus API without a reload
+	SplitsDynamicWeights bool `json:"splitsDynamicWeights"`
+	// LimitReq limits the rate of requests of the route without a Policy
+	LimitReq *RouteLimitReq `json:"limitReq"`
+	// LimitConn limits the number of the connections of a client to the route
+	LimitConn *LimitConn `json:"limitConn"`
+	// ClientMaxBodySize overrides the client-max-body-size of the upstreams for the route
+	ClientMaxBodySize string `json:"clientMaxBodySize"`
+	// ClientBodyTimeout overrides the client-body-timeout of the upstreams for the route
+	ClientBodyTimeout string `json:"clientBodyTimeout"`
+}
+
+// RouteLimitReq defines a rate limit of requests of a route.
+type RouteLimitReq struct {
+	Rate  string `json:"rate"`
+	Burst *int   `json:"burst"`
+	Key   string `json:"key"`
 }
 
 // Action defines an action.
@@ -110,12 +323,53 @@ type Action struct {
 	Pass     string          `json:"pass"`
 	Redirect *ActionRedirect `json:"redirect"`
 	Return   *ActionReturn   `json:"return"`
+	Serve    *ActionServe    `json:"serve"`
+	// LimitRate limits the rate of the response to a client per connection. Requires pass.
+	LimitRate string `json:"limitRate"`
+	// LimitRateAfter is the amount of the response after which the rate of the response is limited. Requires pass.
+	LimitRateAfter string `json:"limitRateAfter"`
+	// Cache caches the responses of the upstream. Requires pass.
+	Cache *ActionCache `json:"cache"`
+}
+
+// ActionCache defines the caching of the responses of the upstream in an Action.
+type ActionCache struct {
+	// ZoneName is the name of the cache. The actions of a VirtualServer and its VirtualServerRoutes with the same
+	// zone name share the cache.
+	ZoneName string `json:"zoneName"`
+	// ZoneSize is the size of the shared memory zone of the keys of the cache.
+	ZoneSize string `json:"zoneSize"`
+	// Key is the key of the cached responses.
+	Key string `json:"key"`
+	// Valid sets the caching time of the responses with the status codes.
+	Valid []ActionCacheValid `json:"valid"`
+	// Bypass are the strings that, if any is not empty and not "0", make the response not be taken from the cache.
+	Bypass []string `json:"bypass"`
+	// NoCache are the strings that, if any is not empty and not "0", make the response not be saved to the cache.
+	NoCache []string `json:"noCache"`
+	// MinUses is the number of requests after which a response is cached.
+	MinUses int `json:"minUses"`
+	// Purge allows the clients to remove the cached responses with the PURGE method. Requires NGINX Plus.
+	Purge *ActionCachePurge `json:"purge"`
+}
+
+// ActionCachePurge defines the clients allowed to purge the cache.
+type ActionCachePurge struct {
+	// Allow are the addresses and CIDRs of the clients allowed to purge the cache.
+	Allow []string `json:"allow"`
+}
+
+// ActionCacheValid defines the caching time of the responses with the status codes.
+type ActionCacheValid struct {
+	Codes []int  `json:"codes"`
+	Time  string `json:"time"`
 }
 
 // ActionRedirect defines a redirect in an Action.
 type ActionRedirect struct {
-	URL  string `json:"url"`
-	Code int    `json:"code"`
+	URL string `json:"url"`
+	// +kubebuilder:validation:Enum=301;302;307;308
+	Code int `json:"code,omitempty"`
 }
 
 // ActionReturn defines a return in an Action.
@@ -125,12 +379,39 @@ type ActionReturn struct {
 	Body string `json:"body"`
 }
 
+// ActionServe defines the static content served in an Action, either the files of a directory
+// or the content of a ConfigMap.
+type ActionServe struct {
+	// Directory is the directory of the files, relative to the directory where the volumes with the files are mounted.
+	Directory string `json:"directory"`
+	// ConfigMap is the name of the ConfigMap with the content, in the namespace of the resource.
+	ConfigMap string `json:"configMap"`
+	// Key is the key of the content in the ConfigMap.
+	Key string `json:"key"`
+	// Type is the MIME type of the content of the ConfigMap.
+	Type string `json:"type"`
+}
+
 // Split defines a split.
 type Split struct {
 	Weight int     `json:"weight"`
 	Action *Action `json:"action"`
 }
 
+// SplitsCache defines the headers added to the responses of the splits of a route,
+// so that shared caches don't serve the response of a single split to all clients.
+type SplitsCache struct {
+	NoStore bool     `json:"noStore"`
+	Vary    []string `json:"vary"`
+}
+
+// SplitsPersistence defines the cookie that stores the split a client first received, so that the next requests
+// of the client are handled by the same split.
+type SplitsPersistence struct {
+	Cookie string `json:"cookie"`
+	TTL    string `json:"ttl"`
+}
+
 // Condition defines a condition in a MatchRule.
 type Condition struct {
 	Header   string `json:"header"`
@@ -138,13 +419,23 @@ type Condition struct {
 	Argument string `json:"argument"`
 	Variable string `json:"variable"`
 	Value    string `json:"value"`
+	// ValueRegex matches the value by a regular expression, which is negated by a leading '!'
+	ValueRegex string `json:"valueRegex"`
+	// Values matches any of the values
+	Values []string `json:"values"`
+	// ClientIP matches the addresses of the clients by IP addresses and CIDRs
+	ClientIP []string `json:"clientIP"`
 }
 
 // Match defines a match.
 type Match struct {
+	// Any matches if any of the conditions matches, instead of all of them
+	Any        bool        `json:"any"`
 	Conditions []Condition `json:"conditions"`
 	Action     *Action     `json:"action"`
 	Splits     []Split     `json:"splits"`
+	// Priority orders the matches: the matches with a higher priority are evaluated first
+	Priority int `json:"priority"`
 }
 
 // TLS defines TLS configuration for a VirtualServer.
@@ -155,9 +446,11 @@ type TLS struct {
 
 // TLSRedirect defines a redirect for a TLS.
 type TLSRedirect struct {
-	Enable  bool   `json:"enable"`
-	Code    *int   `json:"code"`
-	BasedOn string `json:"basedOn"`
+	Enable bool `json:"enable"`
+	// +kubebuilder:validation:Enum=301;302;307;308
+	Code *int `json:"code"`
+	// +kubebuilder:validation:Enum=scheme;x-forwarded-proto
+	BasedOn string `json:"basedOn,omitempty"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -172,12 +465,19 @@ type VirtualServerList struct {
 
 // +genclient
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:resource:shortName=vsr
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Host",type=string,JSONPath=`.spec.host`
+// +kubebuilder:printcolumn:name="State",type=string,JSONPath=`.status.state`,description="Current state of the VirtualServerRoute. If the resource has a valid status, it means it has been validated and accepted by the Ingress Controller."
+// +kubebuilder:printcolumn:name="Referenced By",type=string,JSONPath=`.status.referencedBy`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 type VirtualServerRoute struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
 
-	Spec VirtualServerRouteSpec `json:"spec"`
+	Spec   VirtualServerRouteSpec   `json:"spec"`
+	Status VirtualServerRouteStatus `json:"status"`
 }
 
 type VirtualServerRouteSpec struct {
@@ -186,6 +486,18 @@ type VirtualServerRouteSpec struct {
 	Subroutes []Route    `json:"subroutes"`
 }
 
+// VirtualServerRouteStatus defines the status of the VirtualServerRoute resource.
+type VirtualServerRouteStatus struct {
+	// State is Valid, Warning or Invalid.
+	State string `json:"state,omitempty"`
+	// Reason is a one-word CamelCase reason for the state, such as AddedOrUpdated, Ignored or NoVirtualServersFound.
+	Reason string `json:"reason,omitempty"`
+	// Message is a human-readable explanation of the state, including the warnings for the upstreams and subroutes.
+	Message string `json:"message,omitempty"`
+	// ReferencedBy is the namespace/name of the VirtualServer that references the VirtualServerRoute.
+	ReferencedBy string `json:"referencedBy,omitempty"`
+}
+
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 
 type VirtualServerRouteList struct {
@@ -200,3 +512,114 @@ type UpstreamQueue struct {
 	Size    int    `json:"size"`
 	Timeout string `json:"timeout"`
 }
+
+// UpstreamRetries defines when and how many times a request is passed to the next server of an Upstream.
+type UpstreamRetries struct {
+	// Conditions are the cases in which a request is passed to the next server. The default is error and timeout.
+	Conditions []string `json:"conditions"`
+	// Tries limits the number of the tries of a request, including the first one. The default is 3.
+	Tries int `json:"tries"`
+	// PerTryTimeout limits the time of reading a response from a server in every try. Overrides read-timeout.
+	PerTryTimeout string `json:"perTryTimeout"`
+	// Budget limits the time in which a request can be passed to the next server. The default is no limit.
+	Budget string `json:"budget"`
+}
+
+// PolicyReference references a policy by name and an optional namespace.
+type PolicyReference struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+}
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:resource:shortName=pol
+
+// Policy defines a Policy for VirtualServer and VirtualServerRoute resources.
+type Policy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec PolicySpec `json:"spec"`
+}
+
+// PolicySpec is the spec of the Policy resource.
+// Each field represents a different policy. Only one policy (field) is allowed.
+type PolicySpec struct {
+	RateLimit  *RateLimit  `json:"rateLimit"`
+	JWTAuth    *JWTAuth    `json:"jwt"`
+	OIDC       *OIDC       `json:"oidc"`
+	EgressMTLS *EgressMTLS `json:"egressMTLS"`
+	BasicAuth  *BasicAuth  `json:"basicAuth"`
+	CORS       *CORS       `json:"cors"`
+}
+
+// RateLimit defines a rate limit policy.
+type RateLimit struct {
+	Rate       string `json:"rate"`
+	Key        string `json:"key"`
+	Delay      *int   `json:"delay"`
+	NoDelay    *bool  `json:"noDelay"`
+	Burst      *int   `json:"burst"`
+	ZoneSize   string `json:"zoneSize"`
+	DryRun     *bool  `json:"dryRun"`
+	LogLevel   string `json:"logLevel"`
+	RejectCode *int   `json:"rejectCode"`
+}
+
+// JWTAuth holds JWT authentication configuration.
+type JWTAuth struct {
+	Realm  string `json:"realm"`
+	Secret string `json:"secret"`
+	Token  string `json:"token"`
+}
+
+// BasicAuth holds HTTP Basic authentication configuration.
+type BasicAuth struct {
+	Realm  string `json:"realm"`
+	Secret string `json:"secret"`
+}
+
+// OIDC defines an OpenID Connect policy.
+type OIDC struct {
+	AuthEndpoint  string `json:"authEndpoint"`
+	TokenEndpoint string `json:"tokenEndpoint"`
+	JWKSURI       string `json:"jwksURI"`
+	ClientID      string `json:"clientID"`
+	ClientSecret  string `json:"clientSecret"`
+	Scope         string `json:"scope"`
+	RedirectURI   string `json:"redirectURI"`
+}
+
+// EgressMTLS defines an Egress MTLS policy.
+type EgressMTLS struct {
+	TLSSecret         string `json:"tlsSecret"`
+	VerifyServer      bool   `json:"verifyServer"`
+	VerifyDepth       *int   `json:"verifyDepth"`
+	Protocols         string `json:"protocols"`
+	SessionReuse      *bool  `json:"sessionReuse"`
+	Ciphers           string `json:"ciphers"`
+	TrustedCertSecret string `json:"trustedCertSecret"`
+	ServerName        bool   `json:"serverName"`
+	SSLName           string `json:"sslName"`
+}
+
+// CORS defines a Cross-Origin Resource Sharing policy.
+type CORS struct {
+	AllowOrigins     []string `json:"allowOrigins"`
+	AllowMethods     []string `json:"allowMethods"`
+	AllowHeaders     []string `json:"allowHeaders"`
+	ExposeHeaders    []string `json:"exposeHeaders"`
+	AllowCredentials bool     `json:"allowCredentials"`
+	MaxAge           *int     `json:"maxAge"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// PolicyList is a list of the Policy resources.
+type PolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []Policy `json:"items"`
+}
@@ -0,0 +1,557 @@
+package validation
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	v1 "github.com/nginxinc/kubernetes-ingress/pkg/apis/configuration/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// ValidatePolicy validates a Policy.
+func ValidatePolicy(policy *v1.Policy, isPlus bool, enableOIDC bool) error {
+	allErrs := validatePolicySpec(&policy.Spec, field.NewPath("spec"), isPlus, enableOIDC)
+	return allErrs.ToAggregate()
+}
+
+func validatePolicySpec(spec *v1.PolicySpec, fieldPath *field.Path, isPlus bool, enableOIDC bool) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	fieldCount := 0
+
+	if spec.RateLimit != nil {
+		allErrs = append(allErrs, validateRateLimit(spec.RateLimit, fieldPath.Child("rateLimit"))...)
+		fieldCount++
+	}
+
+	if spec.JWTAuth != nil {
+		if !isPlus {
+			return append(allErrs, field.Forbidden(fieldPath.Child("jwt"), "jwt secrets are only supported in NGINX Plus"))
+		}
+
+		allErrs = append(allErrs, validateJWT(spec.JWTAuth, fieldPath.Child("jwt"))...)
+		fieldCount++
+	}
+
+	if spec.OIDC != nil {
+		if !isPlus {
+			return append(allErrs, field.Forbidden(fieldPath.Child("oidc"), "OIDC is only supported in NGINX Plus"))
+		}
+
+		if !enableOIDC {
+			return append(allErrs, field.Forbidden(fieldPath.Child("oidc"), "OIDC must be enabled via the -enable-oidc command-line argument"))
+		}
+
+		allErrs = append(allErrs, validateOIDC(spec.OIDC, fieldPath.Child("oidc"))...)
+		fieldCount++
+	}
+
+	if spec.EgressMTLS != nil {
+		allErrs = append(allErrs, validateEgressMTLS(spec.EgressMTLS, fieldPath.Child("egressMTLS"))...)
+		fieldCount++
+	}
+
+	if spec.BasicAuth != nil {
+		allErrs = append(allErrs, validateBasicAuth(spec.BasicAuth, fieldPath.Child("basicAuth"))...)
+		fieldCount++
+	}
+
+	if spec.CORS != nil {
+		allErrs = append(allErrs, validateCORS(spec.CORS, fieldPath.Child("cors"))...)
+		fieldCount++
+	}
+
+	if fieldCount != 1 {
+		msg := "must specify exactly one of: `rateLimit`, `jwt`, `oidc`, `egressMTLS`, `basicAuth`, `cors`"
+		allErrs = append(allErrs, field.Invalid(fieldPath, "", msg))
+	}
+
+	return allErrs
+}
+
+func validateRateLimit(rateLimit *v1.RateLimit, fieldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	allErrs = append(allErrs, validateRateLimitZoneSize(rateLimit.ZoneSize, fieldPath.Child("zoneSize"))...)
+	allErrs = append(allErrs, validateRate(rateLimit.Rate, fieldPath.Child("rate"))...)
+	allErrs = append(allErrs, validateRateLimitKey(rateLimit.Key, fieldPath.Child("key"))...)
+
+	if rateLimit.Delay != nil {
+		allErrs = append(allErrs, validatePositiveInt(*rateLimit.Delay, fieldPath.Child("delay"))...)
+
+		if rateLimit.NoDelay != nil && *rateLimit.NoDelay {
+			allErrs = append(allErrs, field.Forbidden(fieldPath.Child("noDelay"), "must not be set together with `delay`"))
+		}
+	}
+
+	if rateLimit.Burst != nil {
+		allErrs = append(allErrs, validatePositiveInt(*rateLimit.Burst, fieldPath.Child("burst"))...)
+	}
+
+	if rateLimit.LogLevel != "" {
+		allErrs = append(allErrs, validateRateLimitLogLevel(rateLimit.LogLevel, fieldPath.Child("logLevel"))...)
+	}
+
+	if rateLimit.RejectCode != nil {
+		if *rateLimit.RejectCode < 400 || *rateLimit.RejectCode > 599 {
+			allErrs = append(allErrs, field.Invalid(fieldPath.Child("rejectCode"), rateLimit.RejectCode, "must be within the range [400-599]"))
+		}
+	}
+
+	return allErrs
+}
+
+func validateJWT(jwt *v1.JWTAuth, fieldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	allErrs = append(allErrs, validateRealm(jwt.Realm, fieldPath.Child("realm"))...)
+
+	if jwt.Secret == "" {
+		return append(allErrs, field.Required(fieldPath.Child("secret"), ""))
+	}
+	allErrs = append(allErrs, validateSecretName(jwt.Secret, fieldPath.Child("secret"))...)
+
+	allErrs = append(allErrs, validateJWTToken(jwt.Token, fieldPath.Child("token"))...)
+
+	return allErrs
+}
+
+func validateBasicAuth(basicAuth *v1.BasicAuth, fieldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	allErrs = append(allErrs, validateRealm(basicAuth.Realm, fieldPath.Child("realm"))...)
+
+	if basicAuth.Secret == "" {
+		return append(allErrs, field.Required(fieldPath.Child("secret"), ""))
+	}
+	allErrs = append(allErrs, validateSecretName(basicAuth.Secret, fieldPath.Child("secret"))...)
+
+	return allErrs
+}
+
+func validateRealm(realm string, fieldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if realm == "" {
+		return append(allErrs, field.Required(fieldPath, ""))
+	}
+
+	if !escapedStringsFmtRegexp.MatchString(realm) {
+		msg := validation.RegexError(escapedStringsErrMsg, escapedStringsFmt, "MyAPI", `My \"API\"`)
+		allErrs = append(allErrs, field.Invalid(fieldPath, realm, msg))
+	}
+
+	return allErrs
+}
+
+const jwtTokenFmt = `\$(http_|arg_|cookie_)[_a-z0-9]+`
+const jwtTokenErrMsg = "must be a valid NGINX variable of the form $http_, $arg_ or $cookie_ followed by lowercase letters, digits and underscores"
+
+var jwtTokenRegexp = regexp.MustCompile("^" + jwtTokenFmt + "$")
+
+func validateJWTToken(token string, fieldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if token == "" {
+		return allErrs
+	}
+
+	if !jwtTokenRegexp.MatchString(token) {
+		msg := validation.RegexError(jwtTokenErrMsg, jwtTokenFmt, "$http_token", "$arg_token", "$cookie_auth_token")
+		allErrs = append(allErrs, field.Invalid(fieldPath, token, msg))
+	}
+
+	return allErrs
+}
+
+func validateOIDC(oidc *v1.OIDC, fieldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	allErrs = append(allErrs, validateOIDCURL(oidc.AuthEndpoint, fieldPath.Child("authEndpoint"))...)
+	allErrs = append(allErrs, validateOIDCURL(oidc.TokenEndpoint, fieldPath.Child("tokenEndpoint"))...)
+	allErrs = append(allErrs, validateOIDCURL(oidc.JWKSURI, fieldPath.Child("jwksURI"))...)
+	allErrs = append(allErrs, validateOIDCClientID(oidc.ClientID, fieldPath.Child("clientID"))...)
+
+	if oidc.ClientSecret == "" {
+		allErrs = append(allErrs, field.Required(fieldPath.Child("clientSecret"), ""))
+	} else {
+		allErrs = append(allErrs, validateSecretName(oidc.ClientSecret, fieldPath.Child("clientSecret"))...)
+	}
+
+	if oidc.Scope != "" {
+		allErrs = append(allErrs, validateOIDCScope(oidc.Scope, fieldPath.Child("scope"))...)
+	}
+
+	if oidc.RedirectURI != "" {
+		allErrs = append(allErrs, validatePath(oidc.RedirectURI, fieldPath.Child("redirectURI"))...)
+	}
+
+	return allErrs
+}
+
+func validateOIDCURL(rawURL string, fieldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if rawURL == "" {
+		return append(allErrs, field.Required(fieldPath, ""))
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return append(allErrs, field.Invalid(fieldPath, rawURL, err.Error()))
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return append(allErrs, field.Invalid(fieldPath, rawURL, "must be an http or https URL"))
+	}
+
+	if u.Host == "" {
+		return append(allErrs, field.Invalid(fieldPath, rawURL, "must include a host"))
+	}
+
+	if !oidcValueRegexp.MatchString(rawURL) {
+		msg := validation.RegexError(oidcValueErrMsg, oidcValueFmt, "https://idp.example.com/auth")
+		allErrs = append(allErrs, field.Invalid(fieldPath, rawURL, msg))
+	}
+
+	return allErrs
+}
+
+// oidcValueFmt prevents characters that can break the NGINX directives that include the value.
+const oidcValueFmt = `[^\s"'{};$\\]+`
+const oidcValueErrMsg = "must not contain whitespace, quotes, curly braces, ';', '$' or '\\'"
+
+var oidcValueRegexp = regexp.MustCompile("^" + oidcValueFmt + "$")
+
+func validateOIDCClientID(clientID string, fieldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if clientID == "" {
+		return append(allErrs, field.Required(fieldPath, ""))
+	}
+
+	if !oidcValueRegexp.MatchString(clientID) {
+		msg := validation.RegexError(oidcValueErrMsg, oidcValueFmt, "nginx-plus", "my-client")
+		allErrs = append(allErrs, field.Invalid(fieldPath, clientID, msg))
+	}
+
+	return allErrs
+}
+
+const oidcScopeFmt = `[a-zA-Z0-9_:./-]+(\+[a-zA-Z0-9_:./-]+)*`
+const oidcScopeErrMsg = "must be a list of scopes separated by '+'"
+
+var oidcScopeRegexp = regexp.MustCompile("^" + oidcScopeFmt + "$")
+
+func validateOIDCScope(scope string, fieldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if !oidcScopeRegexp.MatchString(scope) {
+		msg := validation.RegexError(oidcScopeErrMsg, oidcScopeFmt, "openid", "openid+profile+email")
+		return append(allErrs, field.Invalid(fieldPath, scope, msg))
+	}
+
+	for _, s := range strings.Split(scope, "+") {
+		if s == "openid" {
+			return allErrs
+		}
+	}
+
+	return append(allErrs, field.Invalid(fieldPath, scope, "must include the openid scope"))
+}
+
+func validateEgressMTLS(egressMTLS *v1.EgressMTLS, fieldPath *field.Path) field.ErrorList {
+	allErrs := validateSecretName(egressMTLS.TLSSecret, fieldPath.Child("tlsSecret"))
+
+	if egressMTLS.VerifyServer && egressMTLS.TrustedCertSecret == "" {
+		return append(allErrs, field.Required(fieldPath.Child("trustedCertSecret"), "must be set when `verifyServer` is true"))
+	}
+	allErrs = append(allErrs, validateSecretName(egressMTLS.TrustedCertSecret, fieldPath.Child("trustedCertSecret"))...)
+
+	if egressMTLS.VerifyDepth != nil {
+		allErrs = append(allErrs, validatePositiveIntOrZero(*egressMTLS.VerifyDepth, fieldPath.Child("verifyDepth"))...)
+	}
+
+	if egressMTLS.Protocols != "" {
+		allErrs = append(allErrs, validateSSLProtocols(egressMTLS.Protocols, fieldPath.Child("protocols"))...)
+	}
+
+	if egressMTLS.Ciphers != "" {
+		allErrs = append(allErrs, validateSSLCiphers(egressMTLS.Ciphers, fieldPath.Child("ciphers"))...)
+	}
+
+	if egressMTLS.SSLName != "" {
+		for _, msg := range validation.IsDNS1123Subdomain(egressMTLS.SSLName) {
+			allErrs = append(allErrs, field.Invalid(fieldPath.Child("sslName"), egressMTLS.SSLName, msg))
+		}
+	}
+
+	return allErrs
+}
+
+var validSSLProtocols = map[string]bool{
+	"SSLv2":   true,
+	"SSLv3":   true,
+	"TLSv1":   true,
+	"TLSv1.1": true,
+	"TLSv1.2": true,
+	"TLSv1.3": true,
+}
+
+func validateSSLProtocols(protocols string, fieldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	for _, p := range strings.Fields(protocols) {
+		if !validSSLProtocols[p] {
+			allErrs = append(allErrs, field.NotSupported(fieldPath, p, sets.StringKeySet(validSSLProtocols).List()))
+		}
+	}
+
+	return allErrs
+}
+
+const sslCiphersFmt = `[A-Za-z0-9!+@=_.-]+(:[A-Za-z0-9!+@=_.-]+)*`
+const sslCiphersErrMsg = "must be a list of ciphers in the OpenSSL format separated by ':'"
+
+var sslCiphersRegexp = regexp.MustCompile("^" + sslCiphersFmt + "$")
+
+func validateSSLCiphers(ciphers string, fieldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if !sslCiphersRegexp.MatchString(ciphers) {
+		msg := validation.RegexError(sslCiphersErrMsg, sslCiphersFmt, "DEFAULT", "HIGH:!aNULL:!MD5")
+		allErrs = append(allErrs, field.Invalid(fieldPath, ciphers, msg))
+	}
+
+	return allErrs
+}
+
+func validateCORS(cors *v1.CORS, fieldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if len(cors.AllowOrigins) == 0 {
+		allErrs = append(allErrs, field.Required(fieldPath.Child("allowOrigins"), ""))
+	}
+
+	for i, origin := range cors.AllowOrigins {
+		idxPath := fieldPath.Child("allowOrigins").Index(i)
+
+		if origin == "*" {
+			if len(cors.AllowOrigins) > 1 {
+				allErrs = append(allErrs, field.Invalid(idxPath, origin, "must be the only origin"))
+			}
+			if cors.AllowCredentials {
+				allErrs = append(allErrs, field.Forbidden(idxPath, "must not allow any origin when `allowCredentials` is enabled"))
+			}
+			continue
+		}
+
+		allErrs = append(allErrs, validateCORSOrigin(origin, idxPath)...)
+	}
+
+	for i, method := range cors.AllowMethods {
+		allErrs = append(allErrs, validateCORSMethod(method, fieldPath.Child("allowMethods").Index(i))...)
+	}
+
+	for i, header := range cors.AllowHeaders {
+		for _, msg := range validation.IsHTTPHeaderName(header) {
+			allErrs = append(allErrs, field.Invalid(fieldPath.Child("allowHeaders").Index(i), header, msg))
+		}
+	}
+
+	for i, header := range cors.ExposeHeaders {
+		for _, msg := range validation.IsHTTPHeaderName(header) {
+			allErrs = append(allErrs, field.Invalid(fieldPath.Child("exposeHeaders").Index(i), header, msg))
+		}
+	}
+
+	if cors.MaxAge != nil {
+		allErrs = append(allErrs, validatePositiveIntOrZero(*cors.MaxAge, fieldPath.Child("maxAge"))...)
+	}
+
+	return allErrs
+}
+
+const corsOriginFmt = `https?://(\*\.)?[a-zA-Z0-9-]+(\.[a-zA-Z0-9-]+)*(:([0-9]+|\*))?`
+const corsOriginErrMsg = "must be an origin with the http or https scheme, with an optional '*.' prefix of the host and an optional port or ':*'"
+
+var corsOriginRegexp = regexp.MustCompile("^" + corsOriginFmt + "$")
+
+func validateCORSOrigin(origin string, fieldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if !strings.HasPrefix(origin, "~") {
+		if !corsOriginRegexp.MatchString(origin) {
+			msg := validation.RegexError(corsOriginErrMsg, corsOriginFmt, "https://example.com", "https://*.example.com", "http://localhost:*")
+			allErrs = append(allErrs, field.Invalid(fieldPath, origin, msg))
+		}
+		return allErrs
+	}
+
+	if !escapedStringsFmtRegexp.MatchString(origin) {
+		msg := validation.RegexError(escapedStringsErrMsg, escapedStringsFmt, `~^https://[a-z]+\.example\.com$`)
+		return append(allErrs, field.Invalid(fieldPath, origin, msg))
+	}
+
+	// the generator puts the regular expression into a quoted string, which NGINX unescapes before passing it to PCRE
+	expr := unescapeNginxString(strings.TrimPrefix(strings.TrimPrefix(origin, "~"), "*"))
+	if expr == "" {
+		return append(allErrs, field.Invalid(fieldPath, origin, "must include a regular expression after '~' or '~*'"))
+	}
+
+	if _, err := compilePCRE(expr); err != nil {
+		return append(allErrs, field.Invalid(fieldPath, origin, fmt.Sprintf("must be a valid PCRE regular expression supported by NGINX: %v", err)))
+	}
+
+	return allErrs
+}
+
+const corsMethodFmt = `[A-Z]+`
+const corsMethodErrMsg = "must consist of uppercase letters"
+
+var corsMethodRegexp = regexp.MustCompile("^" + corsMethodFmt + "$")
+
+func validateCORSMethod(method string, fieldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if !corsMethodRegexp.MatchString(method) {
+		msg := validation.RegexError(corsMethodErrMsg, corsMethodFmt, "GET", "POST")
+		allErrs = append(allErrs, field.Invalid(fieldPath, method, msg))
+	}
+
+	return allErrs
+}
+
+func validatePositiveInt(n int, fieldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if n <= 0 {
+		return append(allErrs, field.Invalid(fieldPath, n, "must be positive"))
+	}
+
+	return allErrs
+}
+
+func validateRateLimitZoneSize(zoneSize string, fieldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if zoneSize == "" {
+		return append(allErrs, field.Required(fieldPath, ""))
+	}
+
+	allErrs = append(allErrs, validateSize(zoneSize, fieldPath)...)
+
+	return allErrs
+}
+
+const rateFmt = `[1-9]\d*r/[sm]`
+const rateErrMsg = "must consist of numeric characters followed by a valid rate suffix. 'r/s|r/m"
+
+var rateRegexp = regexp.MustCompile("^" + rateFmt + "$")
+
+func validateRate(rate string, fieldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if rate == "" {
+		return append(allErrs, field.Required(fieldPath, ""))
+	}
+
+	if !rateRegexp.MatchString(rate) {
+		msg := validation.RegexError(rateErrMsg, rateFmt, "16r/s", "32r/m", "64r/s")
+		return append(allErrs, field.Invalid(fieldPath, rate, msg))
+	}
+
+	return allErrs
+}
+
+// rateLimitKeyVariables includes NGINX variables allowed to be used in a rateLimit policy key.
+var rateLimitKeyVariables = map[string]bool{
+	"binary_remote_addr": true,
+	"request_uri":        true,
+	"uri":                true,
+	"args":               true,
+}
+
+var rateLimitKeySpecialVariables = []string{"arg_", "http_", "cookie_"}
+
+func validateRateLimitKey(key string, fieldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if key == "" {
+		return append(allErrs, field.Required(fieldPath, ""))
+	}
+
+	if !escapedStringsFmtRegexp.MatchString(key) {
+		msg := validation.RegexError(escapedStringsErrMsg, escapedStringsFmt, "${binary_remote_addr}", "${uri}${args}")
+		return append(allErrs, field.Invalid(fieldPath, key, msg))
+	}
+
+	allErrs = append(allErrs, validateStringWithVariables(key, fieldPath, rateLimitKeyVariables, rateLimitKeySpecialVariables)...)
+
+	return allErrs
+}
+
+var validLogLevels = map[string]bool{
+	"info":   true,
+	"notice": true,
+	"warn":   true,
+	"error":  true,
+}
+
+func validateRateLimitLogLevel(logLevel string, fieldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if !validLogLevels[logLevel] {
+		allErrs = append(allErrs, field.NotSupported(fieldPath, logLevel, sets.StringKeySet(validLogLevels).List()))
+	}
+
+	return allErrs
+}
+
+func validatePolicies(policies []v1.PolicyReference, fieldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	policyKeys := sets.String{}
+
+	for i, p := range policies {
+		idxPath := fieldPath.Index(i)
+
+		refErrs := validatePolicyReference(p, idxPath)
+		if len(refErrs) > 0 {
+			allErrs = append(allErrs, refErrs...)
+			continue
+		}
+
+		key := fmt.Sprintf("%s/%s", p.Namespace, p.Name)
+		if policyKeys.Has(key) {
+			allErrs = append(allErrs, field.Duplicate(idxPath, key))
+		} else {
+			policyKeys.Insert(key)
+		}
+	}
+
+	return allErrs
+}
+
+func validatePolicyReference(ref v1.PolicyReference, fieldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if ref.Name == "" {
+		allErrs = append(allErrs, field.Required(fieldPath.Child("name"), ""))
+	} else {
+		for _, msg := range validation.IsDNS1123Subdomain(ref.Name) {
+			allErrs = append(allErrs, field.Invalid(fieldPath.Child("name"), ref.Name, msg))
+		}
+	}
+
+	if ref.Namespace != "" {
+		for _, msg := range validation.IsDNS1123Label(ref.Namespace) {
+			allErrs = append(allErrs, field.Invalid(fieldPath.Child("namespace"), ref.Namespace, msg))
+		}
+	}
+
+	return allErrs
+}
@@ -0,0 +1,846 @@
+package validation
+
+import (
+	"testing"
+
+	v1 "github.com/nginxinc/kubernetes-ingress/pkg/apis/configuration/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func TestValidatePolicy(t *testing.T) {
+	policy := &v1.Policy{
+		Spec: v1.PolicySpec{
+			RateLimit: &v1.RateLimit{
+				Rate:     "10r/s",
+				ZoneSize: "10M",
+				Key:      "${binary_remote_addr}",
+			},
+		},
+	}
+
+	isPlus := false
+
+	err := ValidatePolicy(policy, isPlus, false)
+	if err != nil {
+		t.Errorf("ValidatePolicy() returned error %v for valid input", err)
+	}
+}
+
+func TestValidatePolicyJWTPlus(t *testing.T) {
+	policy := &v1.Policy{
+		Spec: v1.PolicySpec{
+			JWTAuth: &v1.JWTAuth{
+				Realm:  "My API",
+				Secret: "my-jwk",
+				Token:  "$http_token",
+			},
+		},
+	}
+
+	isPlus := true
+
+	err := ValidatePolicy(policy, isPlus, false)
+	if err != nil {
+		t.Errorf("ValidatePolicy() returned error %v for valid input", err)
+	}
+}
+
+func TestValidatePolicyOIDCPlus(t *testing.T) {
+	policy := &v1.Policy{
+		Spec: v1.PolicySpec{
+			OIDC: createValidOIDC(),
+		},
+	}
+
+	err := ValidatePolicy(policy, true, true)
+	if err != nil {
+		t.Errorf("ValidatePolicy() returned error %v for valid input", err)
+	}
+}
+
+func createValidOIDC() *v1.OIDC {
+	return &v1.OIDC{
+		AuthEndpoint:  "https://idp.example.com/auth",
+		TokenEndpoint: "https://idp.example.com/token",
+		JWKSURI:       "https://idp.example.com/certs",
+		ClientID:      "nginx-plus",
+		ClientSecret:  "oidc-secret",
+		Scope:         "openid+profile",
+		RedirectURI:   "/_codexch",
+	}
+}
+
+func TestValidatePolicyFails(t *testing.T) {
+	tests := []struct {
+		policy     *v1.Policy
+		isPlus     bool
+		enableOIDC bool
+		msg        string
+	}{
+		{
+			policy: &v1.Policy{
+				Spec: v1.PolicySpec{},
+			},
+			msg: "empty policy spec",
+		},
+		{
+			policy: &v1.Policy{
+				Spec: v1.PolicySpec{
+					JWTAuth: &v1.JWTAuth{
+						Realm:  "My API",
+						Secret: "my-jwk",
+					},
+				},
+			},
+			isPlus: false,
+			msg:    "jwt policy in OSS",
+		},
+		{
+			policy: &v1.Policy{
+				Spec: v1.PolicySpec{
+					RateLimit: &v1.RateLimit{
+						Rate:     "10r/s",
+						ZoneSize: "10M",
+						Key:      "${binary_remote_addr}",
+					},
+					JWTAuth: &v1.JWTAuth{
+						Realm:  "My API",
+						Secret: "my-jwk",
+					},
+				},
+			},
+			isPlus: true,
+			msg:    "multiple policies in spec",
+		},
+		{
+			policy: &v1.Policy{
+				Spec: v1.PolicySpec{
+					RateLimit: &v1.RateLimit{
+						Rate: "10r/s",
+						Key:  "${binary_remote_addr}",
+					},
+				},
+			},
+			msg: "missing zone size",
+		},
+		{
+			policy: &v1.Policy{
+				Spec: v1.PolicySpec{
+					OIDC: createValidOIDC(),
+				},
+			},
+			isPlus:     false,
+			enableOIDC: true,
+			msg:        "oidc policy in OSS",
+		},
+		{
+			policy: &v1.Policy{
+				Spec: v1.PolicySpec{
+					OIDC: createValidOIDC(),
+				},
+			},
+			isPlus:     true,
+			enableOIDC: false,
+			msg:        "oidc policy with OIDC disabled",
+		},
+	}
+
+	for _, test := range tests {
+		err := ValidatePolicy(test.policy, test.isPlus, test.enableOIDC)
+		if err == nil {
+			t.Errorf("ValidatePolicy() returned no error for invalid input for the case of %s", test.msg)
+		}
+	}
+}
+
+func TestValidateRateLimit(t *testing.T) {
+	dryRun := true
+	noDelay := false
+
+	tests := []struct {
+		rateLimit *v1.RateLimit
+		msg       string
+	}{
+		{
+			rateLimit: &v1.RateLimit{
+				Rate:     "10r/s",
+				ZoneSize: "10M",
+				Key:      "${request_uri}",
+			},
+			msg: "only required fields are set",
+		},
+		{
+			rateLimit: &v1.RateLimit{
+				Rate:       "30r/m",
+				Key:        "${binary_remote_addr}${http_x_user}",
+				Delay:      createPointerFromInt(5),
+				NoDelay:    &noDelay,
+				Burst:      createPointerFromInt(10),
+				ZoneSize:   "10M",
+				DryRun:     &dryRun,
+				LogLevel:   "info",
+				RejectCode: createPointerFromInt(505),
+			},
+			msg: "ratelimit all fields set",
+		},
+	}
+
+	for _, test := range tests {
+		allErrs := validateRateLimit(test.rateLimit, field.NewPath("rateLimit"))
+		if len(allErrs) > 0 {
+			t.Errorf("validateRateLimit() returned errors %v for valid input for the case of %s", allErrs, test.msg)
+		}
+	}
+}
+
+func TestValidateRateLimitFails(t *testing.T) {
+	noDelay := true
+
+	tests := []struct {
+		rateLimit *v1.RateLimit
+		msg       string
+	}{
+		{
+			rateLimit: &v1.RateLimit{
+				Rate:     "10s",
+				ZoneSize: "10M",
+				Key:      "${request_uri}",
+			},
+			msg: "invalid rate",
+		},
+		{
+			rateLimit: &v1.RateLimit{
+				Rate:     "10r/s",
+				ZoneSize: "10M",
+				Key:      "${request_uri}",
+				Delay:    createPointerFromInt(0),
+			},
+			msg: "invalid delay",
+		},
+		{
+			rateLimit: &v1.RateLimit{
+				Rate:     "10r/s",
+				ZoneSize: "10M",
+				Key:      "${request_uri}",
+				Delay:    createPointerFromInt(5),
+				NoDelay:  &noDelay,
+			},
+			msg: "delay and noDelay both set",
+		},
+		{
+			rateLimit: &v1.RateLimit{
+				Rate:     "10r/s",
+				ZoneSize: "10M",
+				Key:      "${request_uri}",
+				Burst:    createPointerFromInt(-1),
+			},
+			msg: "invalid burst",
+		},
+		{
+			rateLimit: &v1.RateLimit{
+				Rate:     "10r/s",
+				ZoneSize: "10N",
+				Key:      "${request_uri}",
+			},
+			msg: "invalid zoneSize",
+		},
+		{
+			rateLimit: &v1.RateLimit{
+				Rate:     "10r/s",
+				ZoneSize: "10M",
+				Key:      "${remote_addr}",
+			},
+			msg: "invalid variable in key",
+		},
+		{
+			rateLimit: &v1.RateLimit{
+				Rate:     "10r/s",
+				ZoneSize: "10M",
+				Key:      "$request_uri",
+			},
+			msg: "variable without curly braces in key",
+		},
+		{
+			rateLimit: &v1.RateLimit{
+				Rate:     "10r/s",
+				ZoneSize: "10M",
+				Key:      "${request_uri}",
+				LogLevel: "debug",
+			},
+			msg: "invalid logLevel",
+		},
+		{
+			rateLimit: &v1.RateLimit{
+				Rate:       "10r/s",
+				ZoneSize:   "10M",
+				Key:        "${request_uri}",
+				RejectCode: createPointerFromInt(600),
+			},
+			msg: "invalid rejectCode",
+		},
+	}
+
+	for _, test := range tests {
+		allErrs := validateRateLimit(test.rateLimit, field.NewPath("rateLimit"))
+		if len(allErrs) == 0 {
+			t.Errorf("validateRateLimit() returned no errors for invalid input for the case of %s", test.msg)
+		}
+	}
+}
+
+func TestValidateJWT(t *testing.T) {
+	tests := []struct {
+		jwt *v1.JWTAuth
+		msg string
+	}{
+		{
+			jwt: &v1.JWTAuth{
+				Realm:  "My Product API",
+				Secret: "my-jwk",
+			},
+			msg: "basic",
+		},
+		{
+			jwt: &v1.JWTAuth{
+				Realm:  "My Product API",
+				Secret: "my-jwk",
+				Token:  "$cookie_auth_token",
+			},
+			msg: "jwt with token",
+		},
+	}
+
+	for _, test := range tests {
+		allErrs := validateJWT(test.jwt, field.NewPath("jwt"))
+		if len(allErrs) != 0 {
+			t.Errorf("validateJWT() returned errors %v for valid input for the case of %v", allErrs, test.msg)
+		}
+	}
+}
+
+func TestValidateJWTFails(t *testing.T) {
+	tests := []struct {
+		jwt *v1.JWTAuth
+		msg string
+	}{
+		{
+			jwt: &v1.JWTAuth{
+				Realm: "My Product API",
+			},
+			msg: "missing secret",
+		},
+		{
+			jwt: &v1.JWTAuth{
+				Secret: "my-jwk",
+			},
+			msg: "missing realm",
+		},
+		{
+			jwt: &v1.JWTAuth{
+				Realm:  "My Product API",
+				Secret: "my-jwk",
+				Token:  "$uri",
+			},
+			msg: "invalid variable usage in token",
+		},
+		{
+			jwt: &v1.JWTAuth{
+				Realm:  "My Product \"API",
+				Secret: "my-jwk",
+			},
+			msg: "invalid realm due to escaped string",
+		},
+		{
+			jwt: &v1.JWTAuth{
+				Realm:  "My Product API",
+				Secret: "my_jwk",
+			},
+			msg: "invalid secret name",
+		},
+	}
+
+	for _, test := range tests {
+		allErrs := validateJWT(test.jwt, field.NewPath("jwt"))
+		if len(allErrs) == 0 {
+			t.Errorf("validateJWT() returned no errors for invalid input for the case of %v", test.msg)
+		}
+	}
+}
+
+func TestValidateBasicAuth(t *testing.T) {
+	basicAuth := &v1.BasicAuth{
+		Realm:  "My Product API",
+		Secret: "my-htpasswd",
+	}
+
+	allErrs := validateBasicAuth(basicAuth, field.NewPath("basicAuth"))
+	if len(allErrs) != 0 {
+		t.Errorf("validateBasicAuth() returned errors %v for valid input", allErrs)
+	}
+}
+
+func TestValidateBasicAuthFails(t *testing.T) {
+	tests := []struct {
+		basicAuth *v1.BasicAuth
+		msg       string
+	}{
+		{
+			basicAuth: &v1.BasicAuth{
+				Realm: "My Product API",
+			},
+			msg: "missing secret",
+		},
+		{
+			basicAuth: &v1.BasicAuth{
+				Secret: "my-htpasswd",
+			},
+			msg: "missing realm",
+		},
+		{
+			basicAuth: &v1.BasicAuth{
+				Realm:  "My Product \"API",
+				Secret: "my-htpasswd",
+			},
+			msg: "invalid realm due to escaped string",
+		},
+		{
+			basicAuth: &v1.BasicAuth{
+				Realm:  "My Product API",
+				Secret: "my_htpasswd",
+			},
+			msg: "invalid secret name",
+		},
+	}
+
+	for _, test := range tests {
+		allErrs := validateBasicAuth(test.basicAuth, field.NewPath("basicAuth"))
+		if len(allErrs) == 0 {
+			t.Errorf("validateBasicAuth() returned no errors for invalid input for the case of %v", test.msg)
+		}
+	}
+}
+
+func TestValidateCORS(t *testing.T) {
+	tests := []struct {
+		cors *v1.CORS
+		msg  string
+	}{
+		{
+			cors: &v1.CORS{
+				AllowOrigins: []string{"*"},
+			},
+			msg: "any origin",
+		},
+		{
+			cors: &v1.CORS{
+				AllowOrigins:     []string{"https://example.com", "https://*.example.com", "http://localhost:*", `~^https://[a-z]+\.example\.org$`},
+				AllowMethods:     []string{"GET", "POST"},
+				AllowHeaders:     []string{"Authorization", "Content-Type"},
+				ExposeHeaders:    []string{"X-Request-ID"},
+				AllowCredentials: true,
+				MaxAge:           createPointerFromInt(3600),
+			},
+			msg: "all fields",
+		},
+		{
+			cors: &v1.CORS{
+				AllowOrigins: []string{"http://localhost:8080"},
+				MaxAge:       createPointerFromInt(0),
+			},
+			msg: "origin with port and zero max age",
+		},
+	}
+
+	for _, test := range tests {
+		allErrs := validateCORS(test.cors, field.NewPath("cors"))
+		if len(allErrs) != 0 {
+			t.Errorf("validateCORS() returned errors %v for valid input for the case of %v", allErrs, test.msg)
+		}
+	}
+}
+
+func TestValidateCORSFails(t *testing.T) {
+	tests := []struct {
+		cors *v1.CORS
+		msg  string
+	}{
+		{
+			cors: &v1.CORS{},
+			msg:  "missing origins",
+		},
+		{
+			cors: &v1.CORS{
+				AllowOrigins: []string{"*", "https://example.com"},
+			},
+			msg: "any origin with other origins",
+		},
+		{
+			cors: &v1.CORS{
+				AllowOrigins:     []string{"*"},
+				AllowCredentials: true,
+			},
+			msg: "any origin with credentials",
+		},
+		{
+			cors: &v1.CORS{
+				AllowOrigins: []string{"example.com"},
+			},
+			msg: "origin without scheme",
+		},
+		{
+			cors: &v1.CORS{
+				AllowOrigins: []string{"https://example.com/"},
+			},
+			msg: "origin with path",
+		},
+		{
+			cors: &v1.CORS{
+				AllowOrigins: []string{"https://api.*.example.com"},
+			},
+			msg: "wildcard in the middle of the host",
+		},
+		{
+			cors: &v1.CORS{
+				AllowOrigins: []string{`~^https://(example\.com$`},
+			},
+			msg: "invalid regular expression",
+		},
+		{
+			cors: &v1.CORS{
+				AllowOrigins: []string{`~^https://"example\.com$`},
+			},
+			msg: "unescaped double quote in regular expression",
+		},
+		{
+			cors: &v1.CORS{
+				AllowOrigins: []string{"~"},
+			},
+			msg: "missing regular expression",
+		},
+		{
+			cors: &v1.CORS{
+				AllowOrigins: []string{"https://example.com"},
+				AllowMethods: []string{"get"},
+			},
+			msg: "lowercase method",
+		},
+		{
+			cors: &v1.CORS{
+				AllowOrigins: []string{"https://example.com"},
+				AllowHeaders: []string{"Content Type"},
+			},
+			msg: "invalid allowed header",
+		},
+		{
+			cors: &v1.CORS{
+				AllowOrigins:  []string{"https://example.com"},
+				ExposeHeaders: []string{"X-Request-ID;"},
+			},
+			msg: "invalid exposed header",
+		},
+		{
+			cors: &v1.CORS{
+				AllowOrigins: []string{"https://example.com"},
+				MaxAge:       createPointerFromInt(-1),
+			},
+			msg: "negative max age",
+		},
+	}
+
+	for _, test := range tests {
+		allErrs := validateCORS(test.cors, field.NewPath("cors"))
+		if len(allErrs) == 0 {
+			t.Errorf("validateCORS() returned no errors for invalid input for the case of %v", test.msg)
+		}
+	}
+}
+
+func TestValidatePolicies(t *testing.T) {
+	policies := []v1.PolicyReference{
+		{
+			Name: "my-policy",
+		},
+		{
+			Name:      "my-policy",
+			Namespace: "nginx-ingress",
+		},
+	}
+
+	allErrs := validatePolicies(policies, field.NewPath("policies"))
+	if len(allErrs) > 0 {
+		t.Errorf("validatePolicies() returned errors %v for valid input", allErrs)
+	}
+}
+
+func TestValidatePoliciesFails(t *testing.T) {
+	tests := []struct {
+		policies []v1.PolicyReference
+		msg      string
+	}{
+		{
+			policies: []v1.PolicyReference{
+				{
+					Name: "",
+				},
+			},
+			msg: "missing name",
+		},
+		{
+			policies: []v1.PolicyReference{
+				{
+					Name: "-invalid",
+				},
+			},
+			msg: "invalid name",
+		},
+		{
+			policies: []v1.PolicyReference{
+				{
+					Name:      "my-policy",
+					Namespace: "_invalid",
+				},
+			},
+			msg: "invalid namespace",
+		},
+		{
+			policies: []v1.PolicyReference{
+				{
+					Name: "my-policy",
+				},
+				{
+					Name: "my-policy",
+				},
+			},
+			msg: "duplicated policies",
+		},
+	}
+
+	for _, test := range tests {
+		allErrs := validatePolicies(test.policies, field.NewPath("policies"))
+		if len(allErrs) == 0 {
+			t.Errorf("validatePolicies() returned no errors for invalid input for the case of %s", test.msg)
+		}
+	}
+}
+
+func TestValidateOIDC(t *testing.T) {
+	tests := []struct {
+		oidc *v1.OIDC
+		msg  string
+	}{
+		{
+			oidc: createValidOIDC(),
+			msg:  "all fields",
+		},
+		{
+			oidc: &v1.OIDC{
+				AuthEndpoint:  "http://idp.example.com:8080/auth",
+				TokenEndpoint: "http://idp.example.com:8080/token",
+				JWKSURI:       "http://idp.example.com:8080/certs",
+				ClientID:      "nginx-plus",
+				ClientSecret:  "oidc-secret",
+			},
+			msg: "default scope and redirect URI",
+		},
+	}
+
+	for _, test := range tests {
+		allErrs := validateOIDC(test.oidc, field.NewPath("oidc"))
+		if len(allErrs) > 0 {
+			t.Errorf("validateOIDC() returned errors %v for valid input for the case of %s", allErrs, test.msg)
+		}
+	}
+}
+
+func TestValidateOIDCInvalid(t *testing.T) {
+	tests := []struct {
+		oidc *v1.OIDC
+		msg  string
+	}{
+		{
+			oidc: &v1.OIDC{},
+			msg:  "empty oidc",
+		},
+		{
+			oidc: &v1.OIDC{
+				AuthEndpoint:  "idp.example.com/auth",
+				TokenEndpoint: "https://idp.example.com/token",
+				JWKSURI:       "https://idp.example.com/certs",
+				ClientID:      "nginx-plus",
+				ClientSecret:  "oidc-secret",
+			},
+			msg: "auth endpoint without scheme",
+		},
+		{
+			oidc: &v1.OIDC{
+				AuthEndpoint:  "https://idp.example.com/auth",
+				TokenEndpoint: "ftp://idp.example.com/token",
+				JWKSURI:       "https://idp.example.com/certs",
+				ClientID:      "nginx-plus",
+				ClientSecret:  "oidc-secret",
+			},
+			msg: "token endpoint with invalid scheme",
+		},
+		{
+			oidc: &v1.OIDC{
+				AuthEndpoint:  "https://idp.example.com/auth",
+				TokenEndpoint: "https://idp.example.com/token",
+				JWKSURI:       "https://idp.example.com/certs?$arg",
+				ClientID:      "nginx-plus",
+				ClientSecret:  "oidc-secret",
+			},
+			msg: "jwks uri with a variable",
+		},
+		{
+			oidc: &v1.OIDC{
+				AuthEndpoint:  "https://idp.example.com/auth",
+				TokenEndpoint: "https://idp.example.com/token",
+				JWKSURI:       "https://idp.example.com/certs",
+				ClientID:      "nginx plus;",
+				ClientSecret:  "oidc-secret",
+			},
+			msg: "invalid client id",
+		},
+		{
+			oidc: &v1.OIDC{
+				AuthEndpoint:  "https://idp.example.com/auth",
+				TokenEndpoint: "https://idp.example.com/token",
+				JWKSURI:       "https://idp.example.com/certs",
+				ClientID:      "nginx-plus",
+				ClientSecret:  "oidc_secret",
+			},
+			msg: "invalid client secret name",
+		},
+		{
+			oidc: &v1.OIDC{
+				AuthEndpoint:  "https://idp.example.com/auth",
+				TokenEndpoint: "https://idp.example.com/token",
+				JWKSURI:       "https://idp.example.com/certs",
+				ClientID:      "nginx-plus",
+				ClientSecret:  "oidc-secret",
+				Scope:         "profile+email",
+			},
+			msg: "scope without openid",
+		},
+		{
+			oidc: &v1.OIDC{
+				AuthEndpoint:  "https://idp.example.com/auth",
+				TokenEndpoint: "https://idp.example.com/token",
+				JWKSURI:       "https://idp.example.com/certs",
+				ClientID:      "nginx-plus",
+				ClientSecret:  "oidc-secret",
+				Scope:         "openid profile",
+			},
+			msg: "scope separated by a space",
+		},
+		{
+			oidc: &v1.OIDC{
+				AuthEndpoint:  "https://idp.example.com/auth",
+				TokenEndpoint: "https://idp.example.com/token",
+				JWKSURI:       "https://idp.example.com/certs",
+				ClientID:      "nginx-plus",
+				ClientSecret:  "oidc-secret",
+				RedirectURI:   "_codexch",
+			},
+			msg: "redirect uri without a leading slash",
+		},
+	}
+
+	for _, test := range tests {
+		allErrs := validateOIDC(test.oidc, field.NewPath("oidc"))
+		if len(allErrs) == 0 {
+			t.Errorf("validateOIDC() returned no errors for invalid input for the case of %s", test.msg)
+		}
+	}
+}
+
+func TestValidateEgressMTLS(t *testing.T) {
+	tests := []struct {
+		egressMTLS *v1.EgressMTLS
+		msg        string
+	}{
+		{
+			egressMTLS: &v1.EgressMTLS{
+				TLSSecret: "mtls-secret",
+			},
+			msg: "tls secret",
+		},
+		{
+			egressMTLS: &v1.EgressMTLS{
+				TLSSecret:         "mtls-secret",
+				VerifyServer:      true,
+				VerifyDepth:       createPointerFromInt(2),
+				TrustedCertSecret: "ca-secret",
+				Protocols:         "TLSv1.2 TLSv1.3",
+				Ciphers:           "HIGH:!aNULL:!MD5",
+				ServerName:        true,
+				SSLName:           "backend.example.com",
+			},
+			msg: "all fields",
+		},
+	}
+
+	for _, test := range tests {
+		allErrs := validateEgressMTLS(test.egressMTLS, field.NewPath("egressMTLS"))
+		if len(allErrs) > 0 {
+			t.Errorf("validateEgressMTLS() returned errors %v for valid input for the case of %s", allErrs, test.msg)
+		}
+	}
+}
+
+func TestValidateEgressMTLSInvalid(t *testing.T) {
+	tests := []struct {
+		egressMTLS *v1.EgressMTLS
+		msg        string
+	}{
+		{
+			egressMTLS: &v1.EgressMTLS{
+				VerifyServer: true,
+			},
+			msg: "verify server without trusted cert secret",
+		},
+		{
+			egressMTLS: &v1.EgressMTLS{
+				TLSSecret: "-invalid-",
+			},
+			msg: "invalid tls secret name",
+		},
+		{
+			egressMTLS: &v1.EgressMTLS{
+				TrustedCertSecret: "ca_secret",
+			},
+			msg: "invalid trusted cert secret name",
+		},
+		{
+			egressMTLS: &v1.EgressMTLS{
+				VerifyDepth: createPointerFromInt(-1),
+			},
+			msg: "negative verify depth",
+		},
+		{
+			egressMTLS: &v1.EgressMTLS{
+				Protocols: "TLSv1.2 TLSv1.4",
+			},
+			msg: "invalid protocol",
+		},
+		{
+			egressMTLS: &v1.EgressMTLS{
+				Ciphers: "HIGH; !aNULL",
+			},
+			msg: "invalid ciphers",
+		},
+		{
+			egressMTLS: &v1.EgressMTLS{
+				SSLName: "$proxy_host;",
+			},
+			msg: "invalid ssl name",
+		},
+	}
+
+	for _, test := range tests {
+		allErrs := validateEgressMTLS(test.egressMTLS, field.NewPath("egressMTLS"))
+		if len(allErrs) == 0 {
+			t.Errorf("validateEgressMTLS() returned no errors for invalid input for the case of %s", test.msg)
+		}
+	}
+}
@@ -0,0 +1,533 @@
+package validation
+
+import (
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"strconv"
+	"strings"
+)
+
+// NGINX compiles regular expressions with PCRE, while the regexp package implements the RE2 syntax. Each of them accepts
+// constructs that the other rejects. To check that NGINX accepts a regular expression, translatePCRE translates it
+// into the RE2 syntax:
+//
+// * The PCRE constructs without an RE2 equivalent, such as lookarounds, atomic groups, possessive quantifiers
+// and backreferences, are replaced with RE2 constructs, so that the rest of the expression can be checked.
+// The replacements don't match the same strings, so the translation is only good for the validation and for approximate matching.
+// * The PCRE rules that RE2 doesn't have, such as the fixed length of a lookbehind, the existence of the groups
+// referenced by backreferences and the code points limit of a character without UTF-8 mode, are checked.
+// * The PCRE constructs that are rarely used in NGINX, such as recursion and conditional groups, are rejected.
+
+// maxPCREGroupNameLength is the maximum length of the name of a PCRE named group.
+const maxPCREGroupNameLength = 32
+
+// maxPCRECodePoint is the maximum code point of a character in PCRE without UTF-8 mode, which NGINX doesn't enable by default.
+const maxPCRECodePoint = 0xff
+
+var pcreQuantifierEndRegexp = regexp.MustCompile(`\{\d+(,\d*)?\}$`)
+
+type pcreGroup struct {
+	lookbehind bool
+	// start is the position of the content of the group in the translated expression
+	start int
+}
+
+type pcreTranslator struct {
+	expr     string
+	pos      int
+	result   strings.Builder
+	groups   []pcreGroup
+	captures int
+	names    map[string]bool
+	// backreferences to the groups by number and by name
+	numberRefs []int
+	nameRefs   []string
+}
+
+// translatePCRE translates a PCRE regular expression into an RE2 regular expression.
+// It returns an error if PCRE would reject the expression or if the expression uses an unsupported construct.
+func translatePCRE(expr string) (string, error) {
+	t := &pcreTranslator{
+		expr:  expr,
+		names: make(map[string]bool),
+	}
+
+	if err := t.translate(); err != nil {
+		return "", err
+	}
+
+	translated := t.result.String()
+	if _, err := regexp.Compile(translated); err != nil {
+		return "", err
+	}
+
+	for _, n := range t.numberRefs {
+		if n > t.captures {
+			return "", fmt.Errorf("reference to non-existent group %d", n)
+		}
+	}
+	for _, name := range t.nameRefs {
+		if !t.names[name] {
+			return "", fmt.Errorf("reference to non-existent group %q", name)
+		}
+	}
+
+	return translated, nil
+}
+
+// compilePCRE compiles a PCRE regular expression for approximate matching.
+func compilePCRE(expr string) (*regexp.Regexp, error) {
+	translated, err := translatePCRE(expr)
+	if err != nil {
+		return nil, err
+	}
+	return regexp.Compile(translated)
+}
+
+func (t *pcreTranslator) translate() error {
+	for t.pos < len(t.expr) {
+		c := t.expr[t.pos]
+
+		switch c {
+		case '\\':
+			if err := t.translateEscape(false); err != nil {
+				return err
+			}
+		case '[':
+			if err := t.translateClass(); err != nil {
+				return err
+			}
+		case '(':
+			if err := t.translateGroupStart(); err != nil {
+				return err
+			}
+		case ')':
+			if err := t.translateGroupEnd(); err != nil {
+				return err
+			}
+		case '*', '+', '?':
+			t.result.WriteByte(c)
+			t.pos++
+			t.skipPossessive()
+		case '}':
+			t.result.WriteByte(c)
+			t.pos++
+			if pcreQuantifierEndRegexp.MatchString(t.result.String()) {
+				t.skipPossessive()
+			}
+		default:
+			t.result.WriteByte(c)
+			t.pos++
+		}
+	}
+
+	if len(t.groups) > 0 {
+		return fmt.Errorf("missing closing )")
+	}
+
+	return nil
+}
+
+// skipPossessive drops the + of a possessive quantifier, such as a++, which RE2 doesn't support.
+func (t *pcreTranslator) skipPossessive() {
+	if t.pos < len(t.expr) && t.expr[t.pos] == '+' {
+		t.pos++
+	}
+}
+
+func (t *pcreTranslator) translateGroupStart() error {
+	rest := t.expr[t.pos:]
+
+	switch {
+	case strings.HasPrefix(rest, "(?#"):
+		end := strings.IndexByte(rest, ')')
+		if end == -1 {
+			return fmt.Errorf("missing ) after comment")
+		}
+		t.pos += end + 1
+		return nil
+	case strings.HasPrefix(rest, "(?<="), strings.HasPrefix(rest, "(?<!"):
+		t.openGroup("(?:", true)
+		t.pos += 4
+		return nil
+	case strings.HasPrefix(rest, "(?="), strings.HasPrefix(rest, "(?!"),
+		strings.HasPrefix(rest, "(?>"), strings.HasPrefix(rest, "(?|"):
+		t.openGroup("(?:", false)
+		t.pos += 3
+		return nil
+	case strings.HasPrefix(rest, "(?P="):
+		end := strings.IndexByte(rest, ')')
+		if end == -1 {
+			return fmt.Errorf("missing ) after group name")
+		}
+		t.nameRefs = append(t.nameRefs, rest[4:end])
+		t.result.WriteString("(?:)")
+		t.pos += end + 1
+		return nil
+	case strings.HasPrefix(rest, "(?<"), strings.HasPrefix(rest, "(?'"), strings.HasPrefix(rest, "(?P<"):
+		return t.translateNamedGroup()
+	case strings.HasPrefix(rest, "(?P>"), strings.HasPrefix(rest, "(?R"), strings.HasPrefix(rest, "(?&"),
+		strings.HasPrefix(rest, "(?("), strings.HasPrefix(rest, "(*"):
+		return fmt.Errorf("recursion, subroutine calls, conditional groups and verbs are not supported")
+	case strings.HasPrefix(rest, "(?") && len(rest) > 2 && (rest[2] >= '0' && rest[2] <= '9' || rest[2] == '+' || rest[2] == '-' && len(rest) > 3 && rest[3] >= '0' && rest[3] <= '9'):
+		return fmt.Errorf("subroutine calls are not supported")
+	case strings.HasPrefix(rest, "(?"):
+		// non-capturing groups and flags
+		t.openGroup("(?", false)
+		t.pos += 2
+		return nil
+	}
+
+	t.captures++
+	t.openGroup("(", false)
+	t.pos++
+
+	return nil
+}
+
+func (t *pcreTranslator) translateNamedGroup() error {
+	rest := t.expr[t.pos:]
+
+	start := 3
+	terminator := byte('>')
+	if strings.HasPrefix(rest, "(?P<") {
+		start = 4
+	} else if rest[2] == '\'' {
+		terminator = '\''
+	}
+
+	end := strings.IndexByte(rest[start:], terminator)
+	if end == -1 {
+		return fmt.Errorf("missing terminator for the name of group")
+	}
+	name := rest[start : start+end]
+
+	if err := validatePCREGroupName(name); err != nil {
+		return err
+	}
+	if t.names[name] {
+		return fmt.Errorf("duplicate name of group %q", name)
+	}
+
+	t.names[name] = true
+	t.captures++
+	t.openGroup(fmt.Sprintf("(?P<%s>", name), false)
+	t.pos += start + end + 1
+
+	return nil
+}
+
+func validatePCREGroupName(name string) error {
+	if name == "" {
+		return fmt.Errorf("missing name of group")
+	}
+	if len(name) > maxPCREGroupNameLength {
+		return fmt.Errorf("name of group %q is longer than %d characters", name, maxPCREGroupNameLength)
+	}
+	if name[0] >= '0' && name[0] <= '9' {
+		return fmt.Errorf("name of group %q must not start with a digit", name)
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_') {
+			return fmt.Errorf("name of group %q must consist of alphanumeric characters or '_'", name)
+		}
+	}
+	return nil
+}
+
+func (t *pcreTranslator) openGroup(translated string, lookbehind bool) {
+	t.result.WriteString(translated)
+	t.groups = append(t.groups, pcreGroup{
+		lookbehind: lookbehind,
+		start:      t.result.Len(),
+	})
+}
+
+func (t *pcreTranslator) translateGroupEnd() error {
+	if len(t.groups) == 0 {
+		return fmt.Errorf("unmatched )")
+	}
+
+	group := t.groups[len(t.groups)-1]
+	t.groups = t.groups[:len(t.groups)-1]
+
+	if group.lookbehind {
+		if err := checkFixedLength(t.result.String()[group.start:]); err != nil {
+			return err
+		}
+	}
+
+	t.result.WriteByte(')')
+	t.pos++
+
+	return nil
+}
+
+// checkFixedLength checks that every top-level alternative of a lookbehind matches strings of a fixed length, as PCRE requires.
+func checkFixedLength(expr string) error {
+	re, err := syntax.Parse(expr, syntax.Perl)
+	if err != nil {
+		return err
+	}
+
+	alternatives := []*syntax.Regexp{re}
+	if re.Op == syntax.OpAlternate {
+		alternatives = re.Sub
+	}
+
+	for _, alt := range alternatives {
+		if _, fixed := getFixedLength(alt); !fixed {
+			return fmt.Errorf("lookbehind assertion %q is not fixed length", expr)
+		}
+	}
+
+	return nil
+}
+
+func getFixedLength(re *syntax.Regexp) (int, bool) {
+	switch re.Op {
+	case syntax.OpEmptyMatch, syntax.OpBeginLine, syntax.OpEndLine, syntax.OpBeginText, syntax.OpEndText,
+		syntax.OpWordBoundary, syntax.OpNoWordBoundary:
+		return 0, true
+	case syntax.OpLiteral:
+		return len(re.Rune), true
+	case syntax.OpCharClass, syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		return 1, true
+	case syntax.OpCapture:
+		return getFixedLength(re.Sub[0])
+	case syntax.OpConcat:
+		total := 0
+		for _, sub := range re.Sub {
+			n, fixed := getFixedLength(sub)
+			if !fixed {
+				return 0, false
+			}
+			total += n
+		}
+		return total, true
+	case syntax.OpAlternate:
+		length := -1
+		for _, sub := range re.Sub {
+			n, fixed := getFixedLength(sub)
+			if !fixed || (length != -1 && n != length) {
+				return 0, false
+			}
+			length = n
+		}
+		return length, true
+	case syntax.OpRepeat:
+		if re.Min != re.Max {
+			return 0, false
+		}
+		n, fixed := getFixedLength(re.Sub[0])
+		return n * re.Min, fixed
+	}
+
+	return 0, false
+}
+
+// translateEscape translates an escape sequence. Inside a character class, only the escapes of single characters are allowed.
+func (t *pcreTranslator) translateEscape(inClass bool) error {
+	if t.pos+1 >= len(t.expr) {
+		return fmt.Errorf(`trailing \`)
+	}
+
+	c := t.expr[t.pos+1]
+	rest := t.expr[t.pos+2:]
+
+	switch {
+	case c == 'x' && strings.HasPrefix(rest, "{"):
+		end := strings.IndexByte(rest, '}')
+		if end == -1 {
+			return fmt.Errorf(`missing } after \x{`)
+		}
+		code, err := strconv.ParseUint(rest[1:end], 16, 32)
+		if err != nil {
+			return fmt.Errorf(`invalid code point in \x{%s}`, rest[1:end])
+		}
+		if code > maxPCRECodePoint {
+			return fmt.Errorf(`code point in \x{%s} is too large, it must not be greater than \x{%x}`, rest[1:end], maxPCRECodePoint)
+		}
+		t.result.WriteString(t.expr[t.pos : t.pos+2+end+1])
+		t.pos += 2 + end + 1
+		return nil
+	case c == 'Q':
+		end := strings.Index(rest, `\E`)
+		if end == -1 {
+			end = len(rest)
+		}
+		t.result.WriteString(regexp.QuoteMeta(rest[:end]))
+		t.pos += 2 + end
+		if end < len(rest) {
+			t.pos += 2
+		}
+		return nil
+	case c == 'E':
+		t.pos += 2
+		return nil
+	case c == 'h':
+		t.writeClass(inClass, `\t `, false)
+	case c == 'H':
+		t.writeClass(inClass, `\t `, true)
+	case c == 'N' || c == 'V':
+		t.writeClass(inClass, `\n`, true)
+	case c == 'e':
+		t.result.WriteString(`\x1b`)
+	case inClass:
+		t.result.WriteString(t.expr[t.pos : t.pos+2])
+	case c >= '1' && c <= '9':
+		end := 0
+		for end < len(rest) && rest[end] >= '0' && rest[end] <= '9' {
+			end++
+		}
+		n, _ := strconv.Atoi(t.expr[t.pos+1 : t.pos+2+end])
+		t.numberRefs = append(t.numberRefs, n)
+		t.result.WriteString("(?:)")
+		t.pos += 2 + end
+		return nil
+	case c == 'g' || c == 'k':
+		return t.translateBackreference()
+	case c == 'R':
+		t.result.WriteString(`(?:\r\n|\n|\r)`)
+	case c == 'X':
+		t.result.WriteString(`.`)
+	case c == 'K' || c == 'G' || c == 'Z':
+		t.result.WriteString(`(?:)`)
+	default:
+		t.result.WriteString(t.expr[t.pos : t.pos+2])
+	}
+
+	t.pos += 2
+
+	return nil
+}
+
+func (t *pcreTranslator) writeClass(inClass bool, chars string, negated bool) {
+	if inClass {
+		// a negated class can't be nested in RE2, so it is approximated by any character
+		if negated {
+			t.result.WriteString(`\x00-\x{10FFFF}`)
+		} else {
+			t.result.WriteString(chars)
+		}
+		return
+	}
+
+	if negated {
+		t.result.WriteString("[^" + chars + "]")
+	} else {
+		t.result.WriteString("[" + chars + "]")
+	}
+}
+
+var pcreBackreferenceRegexp = regexp.MustCompile(`^\\(?:g(-?\d+)|g\{(-?\d+)\}|g\{(\w+)\}|k<(\w+)>|k'(\w+)'|k\{(\w+)\})`)
+
+func (t *pcreTranslator) translateBackreference() error {
+	m := pcreBackreferenceRegexp.FindStringSubmatch(t.expr[t.pos:])
+	if m == nil {
+		return fmt.Errorf("invalid backreference")
+	}
+
+	number := m[1] + m[2]
+	name := m[3] + m[4] + m[5] + m[6]
+
+	if number != "" {
+		n, err := strconv.Atoi(number)
+		if err != nil || n == 0 {
+			return fmt.Errorf("invalid backreference %v", m[0])
+		}
+		if n < 0 {
+			// a relative backreference to a preceding group
+			n = t.captures + n + 1
+			if n <= 0 {
+				return fmt.Errorf("reference to non-existent group in %v", m[0])
+			}
+		}
+		t.numberRefs = append(t.numberRefs, n)
+	} else {
+		t.nameRefs = append(t.nameRefs, name)
+	}
+
+	t.result.WriteString("(?:)")
+	t.pos += len(m[0])
+
+	return nil
+}
+
+func (t *pcreTranslator) translateClass() error {
+	t.result.WriteByte('[')
+	t.pos++
+
+	if t.pos < len(t.expr) && t.expr[t.pos] == '^' {
+		t.result.WriteByte('^')
+		t.pos++
+	}
+	// a ] right after the opening of a class is a literal
+	if t.pos < len(t.expr) && t.expr[t.pos] == ']' {
+		t.result.WriteString(`\]`)
+		t.pos++
+	}
+
+	for t.pos < len(t.expr) {
+		c := t.expr[t.pos]
+
+		switch {
+		case c == ']':
+			t.result.WriteByte(']')
+			t.pos++
+			return nil
+		case c == '\\':
+			if err := t.translateEscape(true); err != nil {
+				return err
+			}
+		case c == '[' && strings.HasPrefix(t.expr[t.pos:], "[:"):
+			end := strings.Index(t.expr[t.pos:], ":]")
+			if end == -1 {
+				t.result.WriteString(`\[`)
+				t.pos++
+				continue
+			}
+			t.result.WriteString(t.expr[t.pos : t.pos+end+2])
+			t.pos += end + 2
+		case c == '[':
+			// a [ inside a class is a literal in PCRE
+			t.result.WriteString(`\[`)
+			t.pos++
+		default:
+			t.result.WriteByte(c)
+			t.pos++
+		}
+	}
+
+	return fmt.Errorf("missing terminating ] for character class")
+}
+
+// unescapeNginxString unescapes a string like NGINX does for a quoted parameter of a directive.
+func unescapeNginxString(s string) string {
+	var result strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			result.WriteByte(s[i])
+			continue
+		}
+
+		switch s[i+1] {
+		case '"', '\'', '\\':
+			result.WriteByte(s[i+1])
+		case 't':
+			result.WriteByte('\t')
+		case 'r':
+			result.WriteByte('\r')
+		case 'n':
+			result.WriteByte('\n')
+		default:
+			result.WriteByte(s[i])
+			result.WriteByte(s[i+1])
+		}
+		i++
+	}
+
+	return result.String()
+}
@@ -0,0 +1,173 @@
+package validation
+
+import "testing"
+
+func TestTranslatePCRE(t *testing.T) {
+	tests := []struct {
+		expr     string
+		expected string
+		msg      string
+	}{
+		{
+			expr:     `^/foo.*\.jpg$`,
+			expected: `^/foo.*\.jpg$`,
+			msg:      "expression without PCRE constructs",
+		},
+		{
+			expr:     `^/(?<version>v\d+)/(?'id'\d+)`,
+			expected: `^/(?P<version>v\d+)/(?P<id>\d+)`,
+			msg:      "named groups",
+		},
+		{
+			expr:     `^/(?!internal)(?=api)(?<=/)(?<!a)`,
+			expected: `^/(?:internal)(?:api)(?:/)(?:a)`,
+			msg:      "lookarounds",
+		},
+		{
+			expr:     `(?>a+)b++c*+d?+e{1,2}+`,
+			expected: `(?:a+)b+c*d?e{1,2}`,
+			msg:      "atomic group and possessive quantifiers",
+		},
+		{
+			expr:     `(a)(?<n>b)\1\g{-1}\k<n>(?P=n)`,
+			expected: `(a)(?P<n>b)(?:)(?:)(?:)(?:)`,
+			msg:      "backreferences",
+		},
+		{
+			expr:     `a(?#comment)\Qa.b\E\h[\h]`,
+			expected: `a` + `a\.b` + `[\t ][\t ]`,
+			msg:      "comment, quoting and horizontal whitespace",
+		},
+		{
+			expr:     `[]a[]\x{ff}`,
+			expected: `[\]a\[]\x{ff}`,
+			msg:      "character class with brackets and a hex escape",
+		},
+		{
+			expr:     `(?<=ab|c)x`,
+			expected: `(?:ab|c)x`,
+			msg:      "lookbehind with top-level alternatives of different lengths",
+		},
+	}
+
+	for _, test := range tests {
+		result, err := translatePCRE(test.expr)
+		if err != nil {
+			t.Errorf("translatePCRE(%q) returned an unexpected error for the case of %v: %v", test.expr, test.msg, err)
+			continue
+		}
+		if result != test.expected {
+			t.Errorf("translatePCRE(%q) returned %q but expected %q for the case of %v", test.expr, result, test.expected, test.msg)
+		}
+	}
+}
+
+func TestTranslatePCREFails(t *testing.T) {
+	tests := []struct {
+		expr string
+		msg  string
+	}{
+		{
+			expr: `(?<=a*)b`,
+			msg:  "variable length lookbehind",
+		},
+		{
+			expr: `(?<!(ab|c))x`,
+			msg:  "nested alternatives of different lengths in lookbehind",
+		},
+		{
+			expr: `(a)\2`,
+			msg:  "backreference to a non-existent group",
+		},
+		{
+			expr: `(?<a>x)\k<b>`,
+			msg:  "backreference to a non-existent name",
+		},
+		{
+			expr: `\x{100}`,
+			msg:  "code point greater than 0xff",
+		},
+		{
+			expr: `(?<1a>x)`,
+			msg:  "group name starting with a digit",
+		},
+		{
+			expr: `(?<aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa>x)`,
+			msg:  "group name longer than 32 characters",
+		},
+		{
+			expr: `(?<a>x)(?<a>y)`,
+			msg:  "duplicate group names",
+		},
+		{
+			expr: `a(?R)?b`,
+			msg:  "recursion",
+		},
+		{
+			expr: `(a)(?1)`,
+			msg:  "subroutine call",
+		},
+		{
+			expr: `(?(1)a|b)`,
+			msg:  "conditional group",
+		},
+		{
+			expr: `(a`,
+			msg:  "missing closing parenthesis",
+		},
+		{
+			expr: `a)`,
+			msg:  "unmatched closing parenthesis",
+		},
+		{
+			expr: `[a`,
+			msg:  "unterminated character class",
+		},
+		{
+			expr: `a\`,
+			msg:  "trailing backslash",
+		},
+		{
+			expr: `a**`,
+			msg:  "nested repetition",
+		},
+	}
+
+	for _, test := range tests {
+		_, err := translatePCRE(test.expr)
+		if err == nil {
+			t.Errorf("translatePCRE(%q) returned no error for the case of %v", test.expr, test.msg)
+		}
+	}
+}
+
+func TestUnescapeNginxString(t *testing.T) {
+	tests := []struct {
+		s        string
+		expected string
+	}{
+		{
+			s:        `^/foo\.jpg`,
+			expected: `^/foo\.jpg`,
+		},
+		{
+			s:        `\"\'\\d`,
+			expected: `"'\d`,
+		},
+		{
+			s:        `a\tb\nc\r`,
+			expected: "a\tb\nc\r",
+		},
+		{
+			s:        `a\`,
+			expected: `a\`,
+		},
+	}
+
+	for _, test := range tests {
+		result := unescapeNginxString(test.s)
+		if result != test.expected {
+			t.Errorf("unescapeNginxString(%q) returned %q but expected %q", test.s, result, test.expected)
+		}
+	}
+}
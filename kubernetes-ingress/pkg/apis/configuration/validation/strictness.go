@@ -0,0 +1,41 @@
+package validation
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// Strictness defines how the validation treats the problems that NGINX can work around,
+// such as a field that is only supported in NGINX Plus, which NGINX ignores.
+type Strictness int
+
+const (
+	// StrictValidation rejects a resource with such problems.
+	StrictValidation Strictness = iota
+	// LenientValidation accepts a resource with such problems and reports them as warnings.
+	LenientValidation
+)
+
+// ParseStrictness parses the strictness from "strict" or "lenient".
+func ParseStrictness(strictness string) (Strictness, error) {
+	switch strictness {
+	case "strict":
+		return StrictValidation, nil
+	case "lenient":
+		return LenientValidation, nil
+	}
+
+	return StrictValidation, fmt.Errorf("invalid strictness %q: must be either strict or lenient", strictness)
+}
+
+// applyStrictness returns the problems as warnings or adds them to the errors, depending on the strictness.
+func applyStrictness(allErrs field.ErrorList, problems field.ErrorList, strictness Strictness) (field.ErrorList, error) {
+	if strictness == LenientValidation {
+		return problems, allErrs.ToAggregate()
+	}
+
+	allErrs = append(allErrs, problems...)
+
+	return field.ErrorList{}, allErrs.ToAggregate()
+}
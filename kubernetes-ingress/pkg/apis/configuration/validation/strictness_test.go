@@ -0,0 +1,114 @@
+package validation
+
+import (
+	"testing"
+
+	v1 "github.com/nginxinc/kubernetes-ingress/pkg/apis/configuration/v1"
+)
+
+func TestParseStrictness(t *testing.T) {
+	tests := []struct {
+		strictness string
+		expected   Strictness
+	}{
+		{
+			strictness: "strict",
+			expected:   StrictValidation,
+		},
+		{
+			strictness: "lenient",
+			expected:   LenientValidation,
+		},
+	}
+
+	for _, test := range tests {
+		result, err := ParseStrictness(test.strictness)
+		if err != nil {
+			t.Errorf("ParseStrictness(%q) returned unexpected error: %v", test.strictness, err)
+		}
+		if result != test.expected {
+			t.Errorf("ParseStrictness(%q) returned %v but expected %v", test.strictness, result, test.expected)
+		}
+	}
+}
+
+func TestParseStrictnessFails(t *testing.T) {
+	for _, strictness := range []string{"", "Strict", "warn"} {
+		_, err := ParseStrictness(strictness)
+		if err == nil {
+			t.Errorf("ParseStrictness(%q) returned no error", strictness)
+		}
+	}
+}
+
+func TestValidateVirtualServerWithWarnings(t *testing.T) {
+	virtualServer := v1.VirtualServer{
+		Spec: v1.VirtualServerSpec{
+			Host: "example.com",
+			Upstreams: []v1.Upstream{
+				{
+					Name:      "first",
+					Service:   "service-1",
+					Port:      80,
+					SlowStart: "10s",
+				},
+			},
+			Routes: []v1.Route{
+				{
+					Path: "/",
+					Action: &v1.Action{
+						Pass: "first",
+					},
+				},
+			},
+		},
+	}
+
+	warnings, err := ValidateVirtualServerWithWarnings(&virtualServer, false, false, nil, LenientValidation)
+	if err != nil {
+		t.Errorf("ValidateVirtualServerWithWarnings() returned unexpected error for lenient validation: %v", err)
+	}
+	if len(warnings) != 1 {
+		t.Errorf("ValidateVirtualServerWithWarnings() returned %d warnings but expected 1 for lenient validation", len(warnings))
+	}
+
+	warnings, err = ValidateVirtualServerWithWarnings(&virtualServer, false, false, nil, StrictValidation)
+	if err == nil {
+		t.Errorf("ValidateVirtualServerWithWarnings() returned no error for strict validation")
+	}
+	if len(warnings) != 0 {
+		t.Errorf("ValidateVirtualServerWithWarnings() returned warnings %v for strict validation", warnings)
+	}
+
+	warnings, err = ValidateVirtualServerWithWarnings(&virtualServer, true, false, nil, StrictValidation)
+	if err != nil {
+		t.Errorf("ValidateVirtualServerWithWarnings() returned unexpected error for NGINX Plus: %v", err)
+	}
+	if len(warnings) != 0 {
+		t.Errorf("ValidateVirtualServerWithWarnings() returned warnings %v for NGINX Plus", warnings)
+	}
+}
+
+func TestValidateVirtualServerWithWarningsFails(t *testing.T) {
+	virtualServer := v1.VirtualServer{
+		Spec: v1.VirtualServerSpec{
+			Host: "",
+			Upstreams: []v1.Upstream{
+				{
+					Name:      "first",
+					Service:   "service-1",
+					Port:      80,
+					SlowStart: "10s",
+				},
+			},
+		},
+	}
+
+	warnings, err := ValidateVirtualServerWithWarnings(&virtualServer, false, false, nil, LenientValidation)
+	if err == nil {
+		t.Errorf("ValidateVirtualServerWithWarnings() returned no error for an invalid host")
+	}
+	if len(warnings) != 1 {
+		t.Errorf("ValidateVirtualServerWithWarnings() returned %d warnings but expected 1", len(warnings))
+	}
+}
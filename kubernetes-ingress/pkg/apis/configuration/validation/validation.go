@@ -2,6 +2,7 @@ package validation
 
 import (
 	"fmt"
+	"net"
 	"regexp"
 	"strconv"
 	"strings"
@@ -22,21 +23,42 @@ var escapedStringsFmtRegexp = regexp.MustCompile("^" + escapedStringsFmt + "$")
 
 // ValidateVirtualServer validates a VirtualServer.
 func ValidateVirtualServer(virtualServer *v1.VirtualServer, isPlus bool) error {
-	allErrs := validateVirtualServerSpec(&virtualServer.Spec, field.NewPath("spec"), isPlus)
-	return allErrs.ToAggregate()
+	_, err := ValidateVirtualServerWithWarnings(virtualServer, isPlus, false, nil, StrictValidation)
+	return err
+}
+
+// ValidateVirtualServerWithWarnings validates a VirtualServer. It returns the problems that NGINX can work around
+// as warnings if the strictness is LenientValidation, and as errors otherwise. If emulateQueue is true, the queue of
+// the upstreams is accepted for NGINX, which approximates it with limit_conn and limit_req. allowedVariables are
+// the variables, in addition to the built-in ones, allowed in the conditions of matches and in the bodies of return actions.
+func ValidateVirtualServerWithWarnings(virtualServer *v1.VirtualServer, isPlus bool, emulateQueue bool, allowedVariables sets.String,
+	strictness Strictness) (field.ErrorList, error) {
+	fieldPath := field.NewPath("spec")
+
+	allErrs := validateVirtualServerSpec(&virtualServer.Spec, fieldPath, isPlus, allowedVariables)
+	problems := rejectPlusResourcesInOSSForUpstreams(virtualServer.Spec.Upstreams, fieldPath.Child("upstreams"), isPlus, emulateQueue)
+	problems = append(problems, rejectPlusResourcesInOSSForRoutes(virtualServer.Spec.Routes, fieldPath.Child("routes"), isPlus)...)
+
+	return applyStrictness(allErrs, problems, strictness)
 }
 
 // validateVirtualServerSpec validates a VirtualServerSpec.
-func validateVirtualServerSpec(spec *v1.VirtualServerSpec, fieldPath *field.Path, isPlus bool) field.ErrorList {
+func validateVirtualServerSpec(spec *v1.VirtualServerSpec, fieldPath *field.Path, isPlus bool, allowedVariables sets.String) field.ErrorList {
 	allErrs := field.ErrorList{}
 
 	allErrs = append(allErrs, validateHost(spec.Host, fieldPath.Child("host"))...)
 	allErrs = append(allErrs, validateTLS(spec.TLS, fieldPath.Child("tls"))...)
+	allErrs = append(allErrs, validateListener(spec.Listener, spec.TLS != nil, fieldPath.Child("listener"))...)
+	allErrs = append(allErrs, validateVirtualServerServer(spec.Server, fieldPath.Child("server"))...)
+	allErrs = append(allErrs, validatePolicies(spec.Policies, fieldPath.Child("policies"))...)
 
 	upstreamErrs, upstreamNames := validateUpstreams(spec.Upstreams, fieldPath.Child("upstreams"), isPlus)
 	allErrs = append(allErrs, upstreamErrs...)
 
-	allErrs = append(allErrs, validateVirtualServerRoutes(spec.Routes, fieldPath.Child("routes"), upstreamNames)...)
+	hostVariables := getHostVariables(spec.Host)
+	allErrs = append(allErrs, validateTracing(spec.Tracing, fieldPath.Child("tracing"), hostVariables)...)
+	allErrs = append(allErrs, validateVirtualServerRoutes(spec.Routes, fieldPath.Child("routes"), upstreamNames, hostVariables, allowedVariables)...)
+	allErrs = append(allErrs, validateJWTClaimConditions(spec.Routes, fieldPath.Child("routes"), isPlus)...)
 
 	return allErrs
 }
@@ -48,6 +70,10 @@ func validateHost(host string, fieldPath *field.Path) field.ErrorList {
 		return append(allErrs, field.Required(fieldPath, ""))
 	}
 
+	if IsRegexHost(host) {
+		return validateRegexHost(host, fieldPath)
+	}
+
 	for _, msg := range validation.IsDNS1123Subdomain(host) {
 		allErrs = append(allErrs, field.Invalid(fieldPath, host, msg))
 	}
@@ -55,6 +81,94 @@ func validateHost(host string, fieldPath *field.Path) field.ErrorList {
 	return allErrs
 }
 
+// IsRegexHost checks if the host is an NGINX regular expression server name, which starts with '~'.
+func IsRegexHost(host string) bool {
+	return strings.HasPrefix(host, "~")
+}
+
+// MatchRegexHost checks if the regular expression server name regexHost matches the host.
+func MatchRegexHost(regexHost string, host string) bool {
+	re, err := compileRegexHost(regexHost)
+	if err != nil {
+		return false
+	}
+	return re.MatchString(host)
+}
+
+func compileRegexHost(regexHost string) (*regexp.Regexp, error) {
+	return compilePCRE(strings.TrimPrefix(regexHost, "~"))
+}
+
+const regexHostForbiddenChars = " \t\r\n;{}\"'"
+
+// validateRegexHost validates a host expressed as a regular expression server name,
+// for example, ~^(?<subdomain>.+)\.example\.com$.
+func validateRegexHost(host string, fieldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if host == "~" {
+		return append(allErrs, field.Invalid(fieldPath, host, "must include a regular expression after '~'"))
+	}
+
+	if strings.ContainsAny(host, regexHostForbiddenChars) {
+		return append(allErrs, field.Invalid(fieldPath, host, "must not contain whitespace, ';', '{', '}' or quotes"))
+	}
+
+	re, err := compileRegexHost(host)
+	if err != nil {
+		return append(allErrs, field.Invalid(fieldPath, host, fmt.Sprintf("must be a valid regular expression: %v", err)))
+	}
+
+	for _, name := range re.SubexpNames() {
+		if validRedirectVariableNames[name] || returnBodyVariables[name] {
+			msg := fmt.Sprintf("must not capture the NGINX variable '%v'", name)
+			allErrs = append(allErrs, field.Invalid(fieldPath, host, msg))
+		}
+	}
+
+	return allErrs
+}
+
+// getHostVariables returns the names of the captures of a regular expression host, which NGINX makes available
+// as variables in the server.
+func getHostVariables(host string) sets.String {
+	hostVariables := sets.String{}
+
+	if !IsRegexHost(host) {
+		return hostVariables
+	}
+
+	re, err := compileRegexHost(host)
+	if err != nil {
+		return hostVariables
+	}
+
+	for _, name := range re.SubexpNames() {
+		if name != "" {
+			hostVariables.Insert(name)
+		}
+	}
+
+	return hostVariables
+}
+
+// withHostVariables returns the variables extended with the variables of the host.
+func withHostVariables(variables map[string]bool, hostVariables sets.String) map[string]bool {
+	if hostVariables.Len() == 0 {
+		return variables
+	}
+
+	result := make(map[string]bool)
+	for name := range variables {
+		result[name] = true
+	}
+	for name := range hostVariables {
+		result[name] = true
+	}
+
+	return result
+}
+
 func validateTLS(tls *v1.TLS, fieldPath *field.Path) field.ErrorList {
 	allErrs := field.ErrorList{}
 
@@ -88,6 +202,637 @@ func validateTLSRedirect(redirect *v1.TLSRedirect, fieldPath *field.Path) field.
 	return allErrs
 }
 
+// defaultListenPorts are the ports every VirtualServer listens on.
+var defaultListenPorts = map[int]bool{
+	80:  true,
+	443: true,
+}
+
+func validateListener(listener *v1.VirtualServerListener, hasTLS bool, fieldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if listener == nil {
+		return allErrs
+	}
+
+	if listener.HTTP == 0 && listener.HTTPS == 0 {
+		return append(allErrs, field.Required(fieldPath, "must specify at least one of `http` or `https`"))
+	}
+
+	if listener.HTTP != 0 {
+		allErrs = append(allErrs, validateListenerPort(listener.HTTP, fieldPath.Child("http"))...)
+	}
+
+	if listener.HTTPS != 0 {
+		allErrs = append(allErrs, validateListenerPort(listener.HTTPS, fieldPath.Child("https"))...)
+
+		if !hasTLS {
+			allErrs = append(allErrs, field.Forbidden(fieldPath.Child("https"), "requires `tls` to be configured"))
+		}
+	}
+
+	if listener.HTTP != 0 && listener.HTTP == listener.HTTPS {
+		allErrs = append(allErrs, field.Duplicate(fieldPath.Child("https"), listener.HTTPS))
+	}
+
+	return allErrs
+}
+
+func validateListenerPort(port int, fieldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	for _, msg := range validation.IsValidPortNum(port) {
+		allErrs = append(allErrs, field.Invalid(fieldPath, port, msg))
+	}
+
+	if defaultListenPorts[port] {
+		allErrs = append(allErrs, field.Invalid(fieldPath, port, "must not be one of the default ports 80 or 443"))
+	}
+
+	return allErrs
+}
+
+func validateVirtualServerServer(server *v1.VirtualServerServer, fieldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if server == nil {
+		return allErrs
+	}
+
+	allErrs = append(allErrs, validateRealIP(server.RealIP, fieldPath.Child("realIP"))...)
+	allErrs = append(allErrs, validateAccessLog(server.AccessLog, fieldPath.Child("accessLog"))...)
+	allErrs = append(allErrs, validateErrorLog(server.ErrorLog, fieldPath.Child("errorLog"))...)
+	allErrs = append(allErrs, validateRequestID(server.RequestID, fieldPath.Child("requestID"))...)
+
+	if server.LimitConn != nil {
+		allErrs = append(allErrs, validateLimitConn(server.LimitConn, fieldPath.Child("limitConn"))...)
+	}
+
+	if server.XForwarded != nil {
+		allErrs = append(allErrs, validateXForwarded(server.XForwarded, fieldPath.Child("xForwarded"))...)
+	}
+
+	return allErrs
+}
+
+func validateXForwarded(xForwarded *v1.XForwarded, fieldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	allErrs = append(allErrs, validateXForwardedMode(xForwarded.For, fieldPath.Child("for"), true)...)
+	allErrs = append(allErrs, validateXForwardedMode(xForwarded.Proto, fieldPath.Child("proto"), false)...)
+	allErrs = append(allErrs, validateXForwardedMode(xForwarded.Host, fieldPath.Child("host"), false)...)
+	allErrs = append(allErrs, validateXForwardedMode(xForwarded.Port, fieldPath.Child("port"), false)...)
+
+	return allErrs
+}
+
+// validateXForwardedMode validates the mode of an X-Forwarded-* header. Only X-Forwarded-For can be appended.
+func validateXForwardedMode(mode string, fieldPath *field.Path, canAppend bool) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	validModes := []string{"overwrite", "preserve", "remove"}
+	if canAppend {
+		validModes = append([]string{"append"}, validModes...)
+	}
+
+	if mode == "" {
+		return allErrs
+	}
+
+	for _, m := range validModes {
+		if mode == m {
+			return allErrs
+		}
+	}
+
+	return append(allErrs, field.NotSupported(fieldPath, mode, validModes))
+}
+
+func validateLimitConn(limitConn *v1.LimitConn, fieldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if limitConn.Key != "" {
+		allErrs = append(allErrs, validateRateLimitKey(limitConn.Key, fieldPath.Child("key"))...)
+	}
+
+	allErrs = append(allErrs, validateSize(limitConn.ZoneSize, fieldPath.Child("zoneSize"))...)
+	allErrs = append(allErrs, validatePositiveInt(limitConn.Max, fieldPath.Child("max"))...)
+
+	return allErrs
+}
+
+func validateRealIP(realIP *v1.RealIP, fieldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if realIP == nil {
+		return allErrs
+	}
+
+	for i, address := range realIP.SetRealIPFrom {
+		allErrs = append(allErrs, validateSetRealIPFrom(address, fieldPath.Child("setRealIPFrom").Index(i))...)
+	}
+
+	if realIP.Header != "" {
+		allErrs = append(allErrs, validateRealIPHeader(realIP.Header, fieldPath.Child("header"))...)
+	}
+
+	return allErrs
+}
+
+// validateSetRealIPFrom validates an address of the set_real_ip_from directive, which is an IPv4 or IPv6 address,
+// a CIDR or unix: for the connections over UNIX-domain sockets.
+func validateSetRealIPFrom(address string, fieldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if address == "" {
+		return append(allErrs, field.Required(fieldPath, ""))
+	}
+
+	if address == "unix:" {
+		return allErrs
+	}
+
+	if strings.Contains(address, "/") {
+		if _, _, err := net.ParseCIDR(address); err != nil {
+			allErrs = append(allErrs, field.Invalid(fieldPath, address, "must be a valid CIDR, e.g. 10.0.0.0/8 or 2001:db8::/32"))
+		}
+		return allErrs
+	}
+
+	if net.ParseIP(address) == nil {
+		allErrs = append(allErrs, field.Invalid(fieldPath, address, "must be a valid IPv4 or IPv6 address, a CIDR or unix:"))
+	}
+
+	return allErrs
+}
+
+// validateRealIPHeader validates the header of the real_ip_header directive. Besides a header name,
+// the directive accepts proxy_protocol to take the address from the PROXY protocol header.
+func validateRealIPHeader(header string, fieldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if header == "proxy_protocol" {
+		return allErrs
+	}
+
+	for _, msg := range validation.IsHTTPHeaderName(header) {
+		allErrs = append(allErrs, field.Invalid(fieldPath, header, msg))
+	}
+
+	return allErrs
+}
+
+func validateAccessLog(accessLog *v1.AccessLog, fieldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if accessLog == nil {
+		return allErrs
+	}
+
+	formats := 0
+	for _, f := range []string{accessLog.FormatName, accessLog.FormatPreset, accessLog.Format} {
+		if f != "" {
+			formats++
+		}
+	}
+	if formats > 1 {
+		msg := "formatName, formatPreset and format are mutually exclusive"
+		allErrs = append(allErrs, field.Forbidden(fieldPath, msg))
+	}
+
+	if accessLog.FormatName != "" {
+		allErrs = append(allErrs, validateLogFormatName(accessLog.FormatName, fieldPath.Child("formatName"))...)
+	}
+
+	if accessLog.FormatPreset != "" && !validLogFormatPresets[accessLog.FormatPreset] {
+		allErrs = append(allErrs, field.NotSupported(fieldPath.Child("formatPreset"), accessLog.FormatPreset, sets.StringKeySet(validLogFormatPresets).List()))
+	}
+
+	if accessLog.Format != "" {
+		allErrs = append(allErrs, validateLogFormat(accessLog.Format, fieldPath.Child("format"))...)
+	}
+
+	if accessLog.Destination != "" {
+		allErrs = append(allErrs, validateAccessLogDestination(accessLog.Destination, fieldPath.Child("destination"))...)
+	}
+
+	return allErrs
+}
+
+var validLogFormatPresets = map[string]bool{
+	"json": true,
+}
+
+const logFormatNameFmt = `[a-zA-Z0-9_-]+`
+const logFormatNameErrMsg = "must consist of alphanumeric characters, '_' or '-'"
+
+var logFormatNameRegexp = regexp.MustCompile("^" + logFormatNameFmt + "$")
+
+func validateLogFormatName(name string, fieldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if !logFormatNameRegexp.MatchString(name) {
+		msg := validation.RegexError(logFormatNameErrMsg, logFormatNameFmt, "main", "combined")
+		allErrs = append(allErrs, field.Invalid(fieldPath, name, msg))
+	}
+
+	return allErrs
+}
+
+// logFormatVariables includes NGINX variables allowed to be used in a log format.
+var logFormatVariables = map[string]bool{
+	"remote_addr":              true,
+	"remote_user":              true,
+	"time_local":               true,
+	"time_iso8601":             true,
+	"msec":                     true,
+	"request":                  true,
+	"request_id":               true,
+	"request_method":           true,
+	"request_uri":              true,
+	"request_length":           true,
+	"request_time":             true,
+	"uri":                      true,
+	"args":                     true,
+	"scheme":                   true,
+	"server_protocol":          true,
+	"host":                     true,
+	"server_name":              true,
+	"server_port":              true,
+	"status":                   true,
+	"body_bytes_sent":          true,
+	"bytes_sent":               true,
+	"connection":               true,
+	"connection_requests":      true,
+	"pipe":                     true,
+	"ssl_protocol":             true,
+	"ssl_cipher":               true,
+	"upstream_addr":            true,
+	"upstream_status":          true,
+	"upstream_connect_time":    true,
+	"upstream_header_time":     true,
+	"upstream_response_time":   true,
+	"upstream_response_length": true,
+	"upstream_cache_status":    true,
+}
+
+// logFormatSpecialVariables includes the prefixes of the NGINX variables of request arguments, headers and cookies,
+// and of the headers of responses, allowed to be used in a log format.
+var logFormatSpecialVariables = []string{"arg_", "http_", "cookie_", "sent_http_", "upstream_http_"}
+
+// logFormatVariableRegexp matches a variable in a log format, either in curly braces or not, as NGINX supports both forms.
+var logFormatVariableRegexp = regexp.MustCompile(`\$(\{([a-zA-Z0-9_]*)\}?|[a-zA-Z0-9_]*)`)
+
+// validateLogFormat validates a log format that the generator puts into a quoted argument of the log_format directive.
+func validateLogFormat(format string, fieldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if !escapedStringsFmtRegexp.MatchString(format) {
+		msg := validation.RegexError(escapedStringsErrMsg, escapedStringsFmt, "$remote_addr $status", `$remote_addr \"$request\" $status`)
+		return append(allErrs, field.Invalid(fieldPath, format, msg))
+	}
+
+	for _, match := range logFormatVariableRegexp.FindAllStringSubmatch(format, -1) {
+		nVar := match[1]
+		if strings.HasPrefix(nVar, "{") {
+			if !strings.HasSuffix(nVar, "}") {
+				return append(allErrs, field.Invalid(fieldPath, format, "a variable in curly braces must end with '}'"))
+			}
+			nVar = match[2]
+		}
+
+		if nVar == "" {
+			return append(allErrs, field.Invalid(fieldPath, format, "'$' must be followed by the name of a variable"))
+		}
+
+		special := false
+		for _, specialVar := range logFormatSpecialVariables {
+			if strings.HasPrefix(nVar, specialVar) && len(nVar) > len(specialVar) {
+				special = true
+				break
+			}
+		}
+
+		if special {
+			allErrs = append(allErrs, validateLogFormatSpecialVariable(nVar, fieldPath)...)
+		} else {
+			allErrs = append(allErrs, validateVariable(nVar, logFormatVariables, fieldPath)...)
+		}
+	}
+
+	return allErrs
+}
+
+// validateLogFormatSpecialVariable validates a variable of a request argument, a header or a cookie.
+// The names of the variables of the headers of responses must be valid header names.
+func validateLogFormatSpecialVariable(nVar string, fieldPath *field.Path) field.ErrorList {
+	for _, prefix := range []string{"sent_http_", "upstream_http_"} {
+		if strings.HasPrefix(nVar, prefix) {
+			allErrs := field.ErrorList{}
+			for _, msg := range isValidSpecialVariableHeader(strings.TrimPrefix(nVar, prefix)) {
+				allErrs = append(allErrs, field.Invalid(fieldPath, nVar, msg))
+			}
+			return allErrs
+		}
+	}
+
+	return validateSpecialVariable(nVar, fieldPath)
+}
+
+const logFileDir = "/var/log/nginx/"
+
+var logFileNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
+
+// validateAccessLogDestination validates the destination of an access log, which is either a file in /var/log/nginx
+// or a syslog server. Files in other directories are not allowed to prevent a resource from writing anywhere
+// the Ingress Controller can write.
+func validateAccessLogDestination(destination string, fieldPath *field.Path) field.ErrorList {
+	if strings.HasPrefix(destination, "syslog:") {
+		return validateSyslogDestination(destination, fieldPath)
+	}
+
+	return validateLogFile(destination, "access.log", fieldPath)
+}
+
+// validateErrorLogDestination validates the destination of an error log. Besides the destinations of an access log,
+// NGINX can write the error log to the standard error.
+func validateErrorLogDestination(destination string, fieldPath *field.Path) field.ErrorList {
+	if destination == "stderr" {
+		return field.ErrorList{}
+	}
+
+	if strings.HasPrefix(destination, "syslog:") {
+		return validateSyslogDestination(destination, fieldPath)
+	}
+
+	return validateLogFile(destination, "error.log", fieldPath)
+}
+
+func validateLogFile(destination string, exampleFileName string, fieldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	fileName := strings.TrimPrefix(destination, logFileDir)
+	if fileName == destination || !logFileNameRegexp.MatchString(fileName) || fileName == "." || fileName == ".." {
+		msg := fmt.Sprintf("must be a file in %s, such as %s%s, or a syslog server, such as syslog:server=10.0.0.1", logFileDir, logFileDir, exampleFileName)
+		allErrs = append(allErrs, field.Invalid(fieldPath, destination, msg))
+	}
+
+	return allErrs
+}
+
+var validSyslogFacilities = map[string]bool{
+	"kern":     true,
+	"user":     true,
+	"mail":     true,
+	"daemon":   true,
+	"auth":     true,
+	"intern":   true,
+	"lpr":      true,
+	"news":     true,
+	"uucp":     true,
+	"clock":    true,
+	"authpriv": true,
+	"ftp":      true,
+	"ntp":      true,
+	"audit":    true,
+	"alert":    true,
+	"cron":     true,
+	"local0":   true,
+	"local1":   true,
+	"local2":   true,
+	"local3":   true,
+	"local4":   true,
+	"local5":   true,
+	"local6":   true,
+	"local7":   true,
+}
+
+// validErrorLogLevels includes the levels of the error_log directive, which are also the severities of syslog messages.
+var validErrorLogLevels = map[string]bool{
+	"debug":  true,
+	"info":   true,
+	"notice": true,
+	"warn":   true,
+	"error":  true,
+	"crit":   true,
+	"alert":  true,
+	"emerg":  true,
+}
+
+var syslogTagRegexp = regexp.MustCompile(`^[a-zA-Z0-9_]{1,32}$`)
+
+// validateSyslogDestination validates a syslog destination of a log, such as syslog:server=10.0.0.1:514,tag=nginx.
+// See https://nginx.org/en/docs/syslog.html
+func validateSyslogDestination(destination string, fieldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	hasServer := false
+
+	for _, param := range strings.Split(strings.TrimPrefix(destination, "syslog:"), ",") {
+		parts := strings.SplitN(param, "=", 2)
+		name := parts[0]
+
+		if name == "nohostname" {
+			if len(parts) == 2 {
+				allErrs = append(allErrs, field.Invalid(fieldPath, destination, "the nohostname parameter must not have a value"))
+			}
+			continue
+		}
+
+		if len(parts) != 2 {
+			msg := fmt.Sprintf("the parameter '%s' must have a value, for example, server=10.0.0.1", param)
+			allErrs = append(allErrs, field.Invalid(fieldPath, destination, msg))
+			continue
+		}
+		value := parts[1]
+
+		switch name {
+		case "server":
+			hasServer = true
+			for _, msg := range isValidSyslogServer(value) {
+				allErrs = append(allErrs, field.Invalid(fieldPath, destination, msg))
+			}
+		case "facility":
+			if !validSyslogFacilities[value] {
+				msg := fmt.Sprintf("the facility must be one of %s", strings.Join(sets.StringKeySet(validSyslogFacilities).List(), ", "))
+				allErrs = append(allErrs, field.Invalid(fieldPath, destination, msg))
+			}
+		case "severity":
+			if !validErrorLogLevels[value] {
+				msg := fmt.Sprintf("the severity must be one of %s", strings.Join(sets.StringKeySet(validErrorLogLevels).List(), ", "))
+				allErrs = append(allErrs, field.Invalid(fieldPath, destination, msg))
+			}
+		case "tag":
+			if !syslogTagRegexp.MatchString(value) {
+				msg := "the tag must consist of at most 32 alphanumeric characters or '_'"
+				allErrs = append(allErrs, field.Invalid(fieldPath, destination, msg))
+			}
+		default:
+			msg := fmt.Sprintf("the parameter '%s' is not supported. Supported parameters are: server, facility, severity, tag, nohostname", name)
+			allErrs = append(allErrs, field.Invalid(fieldPath, destination, msg))
+		}
+	}
+
+	if !hasServer {
+		allErrs = append(allErrs, field.Invalid(fieldPath, destination, "must include the server parameter, for example, syslog:server=10.0.0.1"))
+	}
+
+	return allErrs
+}
+
+// isValidSyslogServer validates the address of a syslog server -- a domain name or an IP address
+// with an optional port. An IPv6 address must be in square brackets. UNIX-domain sockets are not allowed,
+// because they are local to the Ingress Controller pod.
+func isValidSyslogServer(server string) []string {
+	host := server
+	port := ""
+
+	if strings.LastIndex(server, ":") > strings.LastIndex(server, "]") {
+		var err error
+		host, port, err = net.SplitHostPort(server)
+		if err != nil {
+			return []string{fmt.Sprintf("the server '%s' must be a host with an optional port, for example, syslog.example.com:514", server)}
+		}
+	} else if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+		host = host[1 : len(host)-1]
+	}
+
+	var msgs []string
+
+	if net.ParseIP(host) == nil {
+		for _, msg := range validation.IsDNS1123Subdomain(host) {
+			msgs = append(msgs, fmt.Sprintf("the server host '%s' is invalid: %s", host, msg))
+		}
+	}
+
+	if port != "" {
+		if p, err := strconv.Atoi(port); err != nil || validation.IsValidPortNum(p) != nil {
+			msgs = append(msgs, fmt.Sprintf("the server port '%s' must be a number between 1 and 65535", port))
+		}
+	}
+
+	return msgs
+}
+
+func validateErrorLog(errorLog *v1.ErrorLog, fieldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if errorLog == nil {
+		return allErrs
+	}
+
+	if errorLog.Destination != "" {
+		allErrs = append(allErrs, validateErrorLogDestination(errorLog.Destination, fieldPath.Child("destination"))...)
+	}
+
+	if errorLog.Level != "" && !validErrorLogLevels[errorLog.Level] {
+		allErrs = append(allErrs, field.NotSupported(fieldPath.Child("level"), errorLog.Level, sets.StringKeySet(validErrorLogLevels).List()))
+	}
+
+	return allErrs
+}
+
+func validateRequestID(requestID *v1.RequestID, fieldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if requestID == nil || requestID.Header == "" {
+		return allErrs
+	}
+
+	for _, msg := range validation.IsHTTPHeaderName(requestID.Header) {
+		allErrs = append(allErrs, field.Invalid(fieldPath.Child("header"), requestID.Header, msg))
+	}
+
+	return allErrs
+}
+
+// tracingVariables includes NGINX variables allowed to be used in the operation name and the tags of the spans.
+var tracingVariables = map[string]bool{
+	"request_uri":     true,
+	"request_method":  true,
+	"request_id":      true,
+	"uri":             true,
+	"args":            true,
+	"scheme":          true,
+	"host":            true,
+	"server_name":     true,
+	"remote_addr":     true,
+	"status":          true,
+	"upstream_addr":   true,
+	"upstream_status": true,
+}
+
+// tracingSpecialVariables includes the prefixes of the NGINX variables of request arguments, headers and cookies
+// allowed to be used in the operation name and the tags of the spans.
+var tracingSpecialVariables = []string{"arg_", "http_", "cookie_"}
+
+// samplingPriorityTag is set by the generator when the sampling percentage is configured.
+const samplingPriorityTag = "sampling.priority"
+
+const tracingTagNameFmt = `[a-zA-Z0-9_.:/-]+`
+const tracingTagNameErrMsg = "must consist of alphanumeric characters, '_', '.', ':', '/' or '-'"
+
+var tracingTagNameRegexp = regexp.MustCompile("^" + tracingTagNameFmt + "$")
+
+func validateTracing(tracing *v1.Tracing, fieldPath *field.Path, hostVariables sets.String) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if tracing == nil {
+		return allErrs
+	}
+
+	if tracing.OperationName != "" {
+		allErrs = append(allErrs, validateTracingString(tracing.OperationName, fieldPath.Child("operationName"), hostVariables)...)
+	}
+
+	if tracing.SamplingPercentage != nil {
+		allErrs = append(allErrs, validatePositiveIntOrZero(*tracing.SamplingPercentage, fieldPath.Child("samplingPercentage"))...)
+		if *tracing.SamplingPercentage > 100 {
+			allErrs = append(allErrs, field.Invalid(fieldPath.Child("samplingPercentage"), *tracing.SamplingPercentage, "must be less than or equal to 100"))
+		}
+	}
+
+	tagNames := sets.String{}
+	for i, t := range tracing.Tags {
+		idxPath := fieldPath.Child("tags").Index(i)
+
+		if t.Name == "" {
+			allErrs = append(allErrs, field.Required(idxPath.Child("name"), ""))
+		} else if !tracingTagNameRegexp.MatchString(t.Name) {
+			msg := validation.RegexError(tracingTagNameErrMsg, tracingTagNameFmt, "component", "http.user_agent")
+			allErrs = append(allErrs, field.Invalid(idxPath.Child("name"), t.Name, msg))
+		} else if tagNames.Has(t.Name) {
+			allErrs = append(allErrs, field.Duplicate(idxPath.Child("name"), t.Name))
+		} else if t.Name == samplingPriorityTag && tracing.SamplingPercentage != nil {
+			msg := fmt.Sprintf("must not be %s when samplingPercentage is set", samplingPriorityTag)
+			allErrs = append(allErrs, field.Invalid(idxPath.Child("name"), t.Name, msg))
+		}
+		tagNames.Insert(t.Name)
+
+		if t.Value == "" {
+			allErrs = append(allErrs, field.Required(idxPath.Child("value"), ""))
+		} else {
+			allErrs = append(allErrs, validateTracingString(t.Value, idxPath.Child("value"), hostVariables)...)
+		}
+	}
+
+	return allErrs
+}
+
+// validateTracingString validates a string that the generator puts into a quoted argument of an OpenTracing directive.
+func validateTracingString(str string, fieldPath *field.Path, hostVariables sets.String) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if !escapedStringsFmtRegexp.MatchString(str) {
+		msg := validation.RegexError(escapedStringsErrMsg, escapedStringsFmt, "ingress", "${request_method} ${uri}", `\"${http_user_agent}\"`)
+		return append(allErrs, field.Invalid(fieldPath, str, msg))
+	}
+
+	allErrs = append(allErrs, validateStringWithVariables(str, fieldPath, withHostVariables(tracingVariables, hostVariables), tracingSpecialVariables)...)
+
+	return allErrs
+}
+
 var validRedirectStatusCodes = map[int]bool{
 	301: true,
 	302: true,
@@ -241,16 +986,112 @@ func validateUpstreamHealthCheck(hc *v1.HealthCheck, fieldPath *field.Path) fiel
 	allErrs = append(allErrs, validateTime(hc.ConnectTimeout, fieldPath.Child("connect-timeout"))...)
 	allErrs = append(allErrs, validateTime(hc.ReadTimeout, fieldPath.Child("read-timeout"))...)
 	allErrs = append(allErrs, validateTime(hc.SendTimeout, fieldPath.Child("send-timeout"))...)
+	allErrs = append(allErrs, validateTime(hc.KeepaliveTime, fieldPath.Child("keepalive-time"))...)
 	allErrs = append(allErrs, validateStatusMatch(hc.StatusMatch, fieldPath.Child("statusMatch"))...)
 
-	for i, header := range hc.Headers {
-		idxPath := fieldPath.Child("headers").Index(i)
-		allErrs = append(allErrs, validateHeader(header, idxPath)...)
+	for i, header := range hc.Headers {
+		idxPath := fieldPath.Child("headers").Index(i)
+		allErrs = append(allErrs, validateHeader(header, idxPath)...)
+
+		if hc.CloseConnection && strings.EqualFold(header.Name, "Connection") {
+			allErrs = append(allErrs, field.Forbidden(idxPath.Child("name"), "can't be used with `close-connection`"))
+		}
+	}
+
+	if hc.BodyMatch != "" {
+		allErrs = append(allErrs, validateConditionValueRegex(hc.BodyMatch, fieldPath.Child("bodyMatch"))...)
+	}
+
+	if hc.TLS != nil {
+		allErrs = append(allErrs, validateHealthCheckTLS(hc.TLS, fieldPath.Child("tls"))...)
+	}
+
+	for i, h := range hc.HeaderMatches {
+		allErrs = append(allErrs, validateHealthCheckHeaderMatch(h, fieldPath.Child("headerMatches").Index(i))...)
+	}
+
+	if hc.PortName != "" {
+		for _, msg := range validation.IsValidPortName(hc.PortName) {
+			allErrs = append(allErrs, field.Invalid(fieldPath.Child("portName"), hc.PortName, msg))
+		}
+		if hc.Port != 0 {
+			allErrs = append(allErrs, field.Forbidden(fieldPath.Child("portName"), "cannot be used with port"))
+		}
+	}
+
+	if hc.CloseConnection && hc.KeepaliveTime != "" {
+		allErrs = append(allErrs, field.Forbidden(fieldPath.Child("keepalive-time"), "can't be used with `close-connection`"))
+	}
+
+	if hc.Port > 0 {
+		for _, msg := range validation.IsValidPortNum(hc.Port) {
+			allErrs = append(allErrs, field.Invalid(fieldPath.Child("port"), hc.Port, msg))
+		}
+	}
+
+	// NGINX keeps the state of the servers across reloads only for the mandatory health checks
+	if hc.Persistent && !hc.Mandatory {
+		allErrs = append(allErrs, field.Forbidden(fieldPath.Child("persistent"), "requires `mandatory`"))
+	}
+
+	return allErrs
+}
+
+// validateHealthCheckTLS validates the TLS configuration of the health checks. The SNI and the verification of the certificates
+// apply only to the health checks over TLS.
+func validateHealthCheckTLS(tls *v1.HealthCheckTLS, fieldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if !tls.Enable {
+		if tls.ServerName {
+			allErrs = append(allErrs, field.Forbidden(fieldPath.Child("serverName"), "requires `enable`"))
+		}
+		if tls.SSLName != "" {
+			allErrs = append(allErrs, field.Forbidden(fieldPath.Child("sslName"), "requires `enable`"))
+		}
+		if tls.VerifyServer {
+			allErrs = append(allErrs, field.Forbidden(fieldPath.Child("verifyServer"), "requires `enable`"))
+		}
+	}
+
+	if tls.SSLName != "" {
+		for _, msg := range validation.IsDNS1123Subdomain(tls.SSLName) {
+			allErrs = append(allErrs, field.Invalid(fieldPath.Child("sslName"), tls.SSLName, msg))
+		}
+	}
+
+	if tls.VerifyServer && tls.TrustedCertSecret == "" {
+		return append(allErrs, field.Required(fieldPath.Child("trustedCertSecret"), "must be set when `verifyServer` is true"))
+	}
+	allErrs = append(allErrs, validateSecretName(tls.TrustedCertSecret, fieldPath.Child("trustedCertSecret"))...)
+
+	return allErrs
+}
+
+// validateHealthCheckHeaderMatch validates a condition on a header of the responses to health checks.
+// The value and the regular expression are escaped by the generator, so they can include quotes and backslashes.
+func validateHealthCheckHeaderMatch(h v1.HeaderMatch, fieldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if h.Name == "" {
+		allErrs = append(allErrs, field.Required(fieldPath.Child("name"), ""))
+	} else {
+		for _, msg := range validation.IsHTTPHeaderName(h.Name) {
+			allErrs = append(allErrs, field.Invalid(fieldPath.Child("name"), h.Name, msg))
+		}
+	}
+
+	if h.Value != "" && h.ValueRegex != "" {
+		return append(allErrs, field.Forbidden(fieldPath.Child("valueRegex"), "can't be used with `value`"))
 	}
 
-	if hc.Port > 0 {
-		for _, msg := range validation.IsValidPortNum(hc.Port) {
-			allErrs = append(allErrs, field.Invalid(fieldPath.Child("port"), hc.Port, msg))
+	if h.ValueRegex != "" {
+		allErrs = append(allErrs, validateConditionValueRegex(h.ValueRegex, fieldPath.Child("valueRegex"))...)
+	}
+
+	if h.Value != "" {
+		for _, msg := range isHeaderValue(h.Value) {
+			allErrs = append(allErrs, field.Invalid(fieldPath.Child("value"), h.Value, msg))
 		}
 	}
 
@@ -450,6 +1291,7 @@ func validateUpstreams(upstreams []v1.Upstream, fieldPath *field.Path, isPlus bo
 		allErrs = append(allErrs, validatePositiveIntOrZeroFromPointer(u.Keepalive, idxPath.Child("keepalive"))...)
 		allErrs = append(allErrs, validatePositiveIntOrZeroFromPointer(u.MaxConns, idxPath.Child("max-conns"))...)
 		allErrs = append(allErrs, validateOffset(u.ClientMaxBodySize, idxPath.Child("client-max-body-size"))...)
+		allErrs = append(allErrs, validateTime(u.ClientBodyTimeout, idxPath.Child("client-body-timeout"))...)
 		allErrs = append(allErrs, validateUpstreamHealthCheck(u.HealthCheck, idxPath.Child("healthCheck"))...)
 		allErrs = append(allErrs, validateTime(u.SlowStart, idxPath.Child("slow-start"))...)
 		allErrs = append(allErrs, validateBuffer(u.ProxyBuffers, idxPath.Child("buffers"))...)
@@ -457,16 +1299,108 @@ func validateUpstreams(upstreams []v1.Upstream, fieldPath *field.Path, isPlus bo
 		allErrs = append(allErrs, validateQueue(u.Queue, idxPath.Child("queue"))...)
 		allErrs = append(allErrs, validateSessionCookie(u.SessionCookie, idxPath.Child("sessionCookie"))...)
 
-		for _, msg := range validation.IsValidPortNum(int(u.Port)) {
-			allErrs = append(allErrs, field.Invalid(idxPath.Child("port"), u.Port, msg))
+		allErrs = append(allErrs, validateUpstreamPort(u, idxPath)...)
+
+		if u.Retries != nil {
+			allErrs = append(allErrs, validateUpstreamRetries(u, idxPath)...)
+		}
+
+		if u.Resolve && len(u.Subselector) > 0 {
+			allErrs = append(allErrs, field.Forbidden(idxPath.Child("resolve"), "cannot be used with subselector: the name of the service resolves to all pods of the service"))
 		}
 
-		allErrs = append(allErrs, rejectPlusResourcesInOSS(u, idxPath, isPlus)...)
+		allErrs = append(allErrs, validateUpstreamTopologyPolicy(u, idxPath)...)
+
+		if u.UseClusterIP {
+			allErrs = append(allErrs, validateUpstreamUseClusterIP(u, idxPath)...)
+		}
 	}
 
 	return allErrs, upstreamNames
 }
 
+// validateUpstreamUseClusterIP validates that the fields that select the endpoints of the service are not used
+// with the cluster IP of the service.
+func validateUpstreamUseClusterIP(u v1.Upstream, fieldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if len(u.Subselector) > 0 {
+		allErrs = append(allErrs, field.Forbidden(fieldPath.Child("subselector"), "cannot be used with useClusterIP: the cluster IP balances across all pods of the service"))
+	}
+
+	if u.Resolve {
+		allErrs = append(allErrs, field.Forbidden(fieldPath.Child("resolve"), "cannot be used with useClusterIP"))
+	}
+
+	if u.TopologyPolicy == "prefer-zone" {
+		allErrs = append(allErrs, field.Forbidden(fieldPath.Child("topologyPolicy"), "prefer-zone cannot be used with useClusterIP: the upstream has a single server"))
+	}
+
+	if u.IncludeNotReadyEndpoints {
+		allErrs = append(allErrs, field.Forbidden(fieldPath.Child("includeNotReadyEndpoints"), "cannot be used with useClusterIP: kube-proxy selects the endpoints"))
+	}
+
+	return allErrs
+}
+
+var validTopologyPolicies = map[string]bool{
+	"":            true,
+	"none":        true,
+	"prefer-zone": true,
+}
+
+// validateUpstreamTopologyPolicy validates the topology policy of an upstream. The prefer-zone policy makes the endpoints
+// in other zones backup servers, which NGINX doesn't support with the hash, ip_hash and random load balancing methods.
+func validateUpstreamTopologyPolicy(u v1.Upstream, fieldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	policyPath := fieldPath.Child("topologyPolicy")
+
+	if !validTopologyPolicies[u.TopologyPolicy] {
+		return append(allErrs, field.NotSupported(policyPath, u.TopologyPolicy, []string{"none", "prefer-zone"}))
+	}
+
+	if u.TopologyPolicy != "prefer-zone" {
+		return allErrs
+	}
+
+	lbMethod := strings.TrimSpace(u.LBMethod)
+	if lbMethod == "ip_hash" || strings.HasPrefix(lbMethod, "hash") || strings.HasPrefix(lbMethod, "random") {
+		allErrs = append(allErrs, field.Forbidden(policyPath, fmt.Sprintf("prefer-zone cannot be used with lb-method %v: backup servers are not supported with the hash, ip_hash and random methods", lbMethod)))
+	}
+
+	if u.Resolve {
+		allErrs = append(allErrs, field.Forbidden(policyPath, "prefer-zone cannot be used with resolve: the name of the service resolves to the pods of all zones"))
+	}
+
+	return allErrs
+}
+
+// validateUpstreamPort validates that the upstream references the port of its service either by the number or by the name.
+func validateUpstreamPort(upstream v1.Upstream, fieldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if upstream.PortName == "" {
+		for _, msg := range validation.IsValidPortNum(int(upstream.Port)) {
+			allErrs = append(allErrs, field.Invalid(fieldPath.Child("port"), upstream.Port, msg))
+		}
+		return allErrs
+	}
+
+	if upstream.Port != 0 {
+		allErrs = append(allErrs, field.Forbidden(fieldPath.Child("portName"), "cannot be used with port"))
+	}
+
+	for _, msg := range validation.IsValidPortName(upstream.PortName) {
+		allErrs = append(allErrs, field.Invalid(fieldPath.Child("portName"), upstream.PortName, msg))
+	}
+
+	if upstream.Resolve {
+		allErrs = append(allErrs, field.Forbidden(fieldPath.Child("resolve"), "cannot be used with portName: the address of the service requires the port number"))
+	}
+
+	return allErrs
+}
+
 var validNextUpstreamParams = map[string]bool{
 	"error":          true,
 	"timeout":        true,
@@ -504,6 +1438,39 @@ func validateNextUpstream(nextUpstream string, fieldPath *field.Path) field.Erro
 	return allErrs
 }
 
+// validateUpstreamRetries validates the retries of an upstream, which replace the next-upstream fields.
+func validateUpstreamRetries(u v1.Upstream, fieldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+	retriesPath := fieldPath.Child("retries")
+
+	if u.ProxyNextUpstream != "" {
+		allErrs = append(allErrs, field.Forbidden(fieldPath.Child("next-upstream"), "cannot be used with `retries`: use `retries.conditions`"))
+	}
+	if u.ProxyNextUpstreamTimeout != "" {
+		allErrs = append(allErrs, field.Forbidden(fieldPath.Child("next-upstream-timeout"), "cannot be used with `retries`: use `retries.budget`"))
+	}
+	if u.ProxyNextUpstreamTries != 0 {
+		allErrs = append(allErrs, field.Forbidden(fieldPath.Child("next-upstream-tries"), "cannot be used with `retries`: use `retries.tries`"))
+	}
+
+	conditionsPath := retriesPath.Child("conditions")
+	for i, c := range u.Retries.Conditions {
+		if c == "" {
+			allErrs = append(allErrs, field.Required(conditionsPath.Index(i), ""))
+		}
+		if c == "off" && len(u.Retries.Conditions) > 1 {
+			allErrs = append(allErrs, field.Invalid(conditionsPath.Index(i), c, "cannot be combined with other conditions"))
+		}
+	}
+	allErrs = append(allErrs, validateNextUpstream(strings.Join(u.Retries.Conditions, " "), conditionsPath)...)
+
+	allErrs = append(allErrs, validatePositiveIntOrZero(u.Retries.Tries, retriesPath.Child("tries"))...)
+	allErrs = append(allErrs, validateTime(u.Retries.PerTryTimeout, retriesPath.Child("perTryTimeout"))...)
+	allErrs = append(allErrs, validateTime(u.Retries.Budget, retriesPath.Child("budget"))...)
+
+	return allErrs
+}
+
 // validateUpstreamName checks is an upstream name is valid.
 // The rules for NGINX upstream names are less strict than IsDNS1035Label.
 // However, it is convenient to enforce IsDNS1035Label in the yaml for
@@ -532,7 +1499,7 @@ func validateDNS1035Label(name string, fieldPath *field.Path) field.ErrorList {
 	return allErrs
 }
 
-func validateVirtualServerRoutes(routes []v1.Route, fieldPath *field.Path, upstreamNames sets.String) field.ErrorList {
+func validateVirtualServerRoutes(routes []v1.Route, fieldPath *field.Path, upstreamNames sets.String, hostVariables sets.String, allowedVariables sets.String) field.ErrorList {
 	allErrs := field.ErrorList{}
 
 	allPaths := sets.String{}
@@ -541,7 +1508,7 @@ func validateVirtualServerRoutes(routes []v1.Route, fieldPath *field.Path, upstr
 		idxPath := fieldPath.Index(i)
 
 		isRouteFieldForbidden := false
-		routeErrs := validateRoute(r, idxPath, upstreamNames, isRouteFieldForbidden)
+		routeErrs := validateRoute(r, idxPath, upstreamNames, isRouteFieldForbidden, hostVariables, allowedVariables)
 		if len(routeErrs) > 0 {
 			allErrs = append(allErrs, routeErrs...)
 		} else if allPaths.Has(r.Path) {
@@ -554,27 +1521,32 @@ func validateVirtualServerRoutes(routes []v1.Route, fieldPath *field.Path, upstr
 	return allErrs
 }
 
-func validateRoute(route v1.Route, fieldPath *field.Path, upstreamNames sets.String, isRouteFieldForbidden bool) field.ErrorList {
+func validateRoute(route v1.Route, fieldPath *field.Path, upstreamNames sets.String, isRouteFieldForbidden bool, hostVariables sets.String, allowedVariables sets.String) field.ErrorList {
 	allErrs := field.ErrorList{}
 
 	allErrs = append(allErrs, validateRoutePath(route.Path, fieldPath.Child("path"))...)
+	allErrs = append(allErrs, validatePolicies(route.Policies, fieldPath.Child("policies"))...)
 
 	fieldCount := 0
 
 	if route.Action != nil {
-		allErrs = append(allErrs, validateAction(route.Action, fieldPath.Child("action"), upstreamNames)...)
+		allErrs = append(allErrs, validateAction(route.Action, fieldPath.Child("action"), upstreamNames, hostVariables, allowedVariables)...)
 		fieldCount++
 	}
 
 	if len(route.Splits) > 0 {
-		allErrs = append(allErrs, validateSplits(route.Splits, fieldPath.Child("splits"), upstreamNames)...)
+		allErrs = append(allErrs, validateSplits(route.Splits, fieldPath.Child("splits"), upstreamNames, hostVariables, allowedVariables)...)
 		fieldCount++
 	}
 
 	// Matches are optional. that's why we don't do fieldCount++
-	if len(route.Matches) > 0 {
+	if len(route.Matches) > maxMatches {
+		msg := fmt.Sprintf("must have at most %d matches: split the route into routes with more specific paths, "+
+			"or combine the conditions on the same header, cookie, argument or variable into one condition with a regular expression value", maxMatches)
+		allErrs = append(allErrs, field.Invalid(fieldPath.Child("matches"), len(route.Matches), msg))
+	} else if len(route.Matches) > 0 {
 		for i, m := range route.Matches {
-			allErrs = append(allErrs, validateMatch(m, fieldPath.Child("matches").Index(i), upstreamNames)...)
+			allErrs = append(allErrs, validateMatch(m, fieldPath.Child("matches").Index(i), upstreamNames, hostVariables, allowedVariables)...)
 		}
 	}
 
@@ -587,61 +1559,427 @@ func validateRoute(route v1.Route, fieldPath *field.Path, upstreamNames sets.Str
 		}
 	}
 
+	if route.SplitsCache != nil {
+		allErrs = append(allErrs, validateSplitsCache(route.SplitsCache, routeHasSplits(route), fieldPath.Child("splitsCache"))...)
+	}
+
+	if route.SplitsPersistence != nil {
+		allErrs = append(allErrs, validateSplitsPersistence(route.SplitsPersistence, routeHasSplits(route), fieldPath.Child("splitsPersistence"))...)
+	}
+
+	if route.SplitsDynamicWeights {
+		allErrs = append(allErrs, validateSplitsDynamicWeights(route, fieldPath.Child("splitsDynamicWeights"))...)
+	}
+
+	if len(route.IgnoreHeaders) > 0 {
+		allErrs = append(allErrs, validateIgnoreHeaders(route.IgnoreHeaders, fieldPath.Child("ignoreHeaders"))...)
+	}
+
+	if route.LimitReq != nil {
+		if route.Route != "" {
+			allErrs = append(allErrs, field.Forbidden(fieldPath.Child("limitReq"), "cannot be used with `route`: set it in the subroutes of the VirtualServerRoute"))
+		} else {
+			allErrs = append(allErrs, validateRouteLimitReq(route.LimitReq, fieldPath.Child("limitReq"))...)
+		}
+	}
+
+	if route.ClientMaxBodySize != "" || route.ClientBodyTimeout != "" {
+		allErrs = append(allErrs, validateRouteClientBody(route, fieldPath)...)
+	}
+
+	if route.LimitConn != nil {
+		if route.Route != "" {
+			allErrs = append(allErrs, field.Forbidden(fieldPath.Child("limitConn"), "cannot be used with `route`: set it in the subroutes of the VirtualServerRoute"))
+		} else {
+			allErrs = append(allErrs, validateLimitConn(route.LimitConn, fieldPath.Child("limitConn"))...)
+		}
+	}
+
 	if fieldCount != 1 {
 		msg := "must specify exactly one of `action`, `splits` or `route`"
 		if isRouteFieldForbidden || len(route.Matches) > 0 {
 			msg = "must specify exactly one of `action` or `splits`"
 		}
 
-		allErrs = append(allErrs, field.Invalid(fieldPath, "", msg))
+		allErrs = append(allErrs, field.Invalid(fieldPath, "", msg))
+	}
+
+	return allErrs
+}
+
+func validateRouteLimitReq(limitReq *v1.RouteLimitReq, fieldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	allErrs = append(allErrs, validateRate(limitReq.Rate, fieldPath.Child("rate"))...)
+
+	if limitReq.Burst != nil {
+		allErrs = append(allErrs, validatePositiveInt(*limitReq.Burst, fieldPath.Child("burst"))...)
+	}
+
+	if limitReq.Key != "" {
+		allErrs = append(allErrs, validateRateLimitKey(limitReq.Key, fieldPath.Child("key"))...)
+	}
+
+	return allErrs
+}
+
+func routeHasSplits(route v1.Route) bool {
+	if len(route.Splits) > 0 {
+		return true
+	}
+
+	for _, m := range route.Matches {
+		if len(m.Splits) > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+func validateSplitsCache(splitsCache *v1.SplitsCache, hasSplits bool, fieldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if !hasSplits {
+		return append(allErrs, field.Forbidden(fieldPath, "can only be used in a route with splits"))
+	}
+
+	headers := sets.String{}
+
+	for i, h := range splitsCache.Vary {
+		idxPath := fieldPath.Child("vary").Index(i)
+
+		for _, msg := range validation.IsHTTPHeaderName(h) {
+			allErrs = append(allErrs, field.Invalid(idxPath, h, msg))
+		}
+
+		// header names are case-insensitive
+		name := strings.ToLower(h)
+		if headers.Has(name) {
+			allErrs = append(allErrs, field.Duplicate(idxPath, h))
+		} else {
+			headers.Insert(name)
+		}
+	}
+
+	return allErrs
+}
+
+func validateSplitsPersistence(persistence *v1.SplitsPersistence, hasSplits bool, fieldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if !hasSplits {
+		return append(allErrs, field.Forbidden(fieldPath, "can only be used in a route with splits"))
+	}
+
+	if persistence.Cookie == "" {
+		allErrs = append(allErrs, field.Required(fieldPath.Child("cookie"), ""))
+	} else {
+		for _, msg := range isCookieName(persistence.Cookie) {
+			allErrs = append(allErrs, field.Invalid(fieldPath.Child("cookie"), persistence.Cookie, msg))
+		}
+	}
+
+	allErrs = append(allErrs, validateTime(persistence.TTL, fieldPath.Child("ttl"))...)
+
+	return allErrs
+}
+
+// validateSplitsDynamicWeights validates that the weights of the splits of the route can be changed dynamically.
+// Only the weights of two splits without matches are supported, because a split client is generated for every weight.
+func validateSplitsDynamicWeights(route v1.Route, fieldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if len(route.Splits) != 2 {
+		allErrs = append(allErrs, field.Forbidden(fieldPath, "can only be used in a route with 2 splits"))
+	}
+
+	if len(route.Matches) > 0 {
+		allErrs = append(allErrs, field.Forbidden(fieldPath, "can't be used in a route with matches"))
+	}
+
+	return allErrs
+}
+
+func validateRouteClientBody(route v1.Route, fieldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if route.Route != "" {
+		msg := "cannot be used with `route`: set it in the subroutes of the VirtualServerRoute"
+		if route.ClientMaxBodySize != "" {
+			allErrs = append(allErrs, field.Forbidden(fieldPath.Child("clientMaxBodySize"), msg))
+		}
+		if route.ClientBodyTimeout != "" {
+			allErrs = append(allErrs, field.Forbidden(fieldPath.Child("clientBodyTimeout"), msg))
+		}
+		return allErrs
+	}
+
+	allErrs = append(allErrs, validateOffset(route.ClientMaxBodySize, fieldPath.Child("clientMaxBodySize"))...)
+	allErrs = append(allErrs, validateTime(route.ClientBodyTimeout, fieldPath.Child("clientBodyTimeout"))...)
+
+	return allErrs
+}
+
+// ignoreHeaders lists the upstream response headers that proxy_ignore_headers can disable the processing of.
+var ignoreHeaders = map[string]bool{
+	"X-Accel-Redirect":   true,
+	"X-Accel-Expires":    true,
+	"X-Accel-Limit-Rate": true,
+	"X-Accel-Buffering":  true,
+	"X-Accel-Charset":    true,
+	"Expires":            true,
+	"Cache-Control":      true,
+	"Set-Cookie":         true,
+	"Vary":               true,
+}
+
+func validateIgnoreHeaders(headers []string, fieldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	seen := sets.String{}
+
+	for i, h := range headers {
+		idxPath := fieldPath.Index(i)
+
+		if !ignoreHeaders[h] {
+			allErrs = append(allErrs, field.NotSupported(idxPath, h, sets.StringKeySet(ignoreHeaders).List()))
+			continue
+		}
+
+		if seen.Has(h) {
+			allErrs = append(allErrs, field.Duplicate(idxPath, h))
+		} else {
+			seen.Insert(h)
+		}
+	}
+
+	return allErrs
+}
+
+func countActions(action *v1.Action) int {
+	var count int
+	if action.Pass != "" {
+		count++
+	}
+
+	if action.Redirect != nil {
+		count++
+	}
+
+	if action.Return != nil {
+		count++
+	}
+
+	if action.Serve != nil {
+		count++
+	}
+
+	return count
+}
+
+func validateAction(action *v1.Action, fieldPath *field.Path, upstreamNames sets.String, hostVariables sets.String, allowedVariables sets.String) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if countActions(action) != 1 {
+		return append(allErrs, field.Required(fieldPath, "action must specify exactly one of `pass`, `redirect`, `return` or `serve`"))
+	}
+
+	if action.Pass != "" {
+		allErrs = append(allErrs, validateReferencedUpstream(action.Pass, fieldPath.Child("pass"), upstreamNames)...)
+	}
+
+	if action.Redirect != nil {
+		allErrs = append(allErrs, validateActionRedirect(action.Redirect, fieldPath.Child("redirect"), hostVariables)...)
+	}
+
+	if action.Return != nil {
+		allErrs = append(allErrs, validateActionReturn(action.Return, fieldPath.Child("return"), hostVariables, allowedVariables)...)
+	}
+
+	if action.Serve != nil {
+		allErrs = append(allErrs, validateActionServe(action.Serve, fieldPath.Child("serve"))...)
+	}
+
+	allErrs = append(allErrs, validateActionLimitRate(action, fieldPath)...)
+
+	if action.Cache != nil {
+		if action.Pass == "" {
+			allErrs = append(allErrs, field.Forbidden(fieldPath.Child("cache"), "can only be used with `pass`"))
+		} else {
+			allErrs = append(allErrs, validateActionCache(action.Cache, fieldPath.Child("cache"), hostVariables)...)
+		}
+	}
+
+	return allErrs
+}
+
+// cacheVariables includes NGINX variables allowed to be used in the key of the cache and in the conditions of
+// bypassing the cache and not caching the responses.
+var cacheVariables = map[string]bool{
+	"scheme":         true,
+	"host":           true,
+	"proxy_host":     true,
+	"request_uri":    true,
+	"request_method": true,
+	"uri":            true,
+	"args":           true,
+	"remote_addr":    true,
+}
+
+// cacheSpecialVariables includes the prefixes of the NGINX variables of request arguments, headers and cookies
+// allowed to be used in the key of the cache and in the conditions of bypassing the cache and not caching the responses.
+var cacheSpecialVariables = []string{"arg_", "http_", "cookie_"}
+
+func validateActionCache(cache *v1.ActionCache, fieldPath *field.Path, hostVariables sets.String) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if cache.ZoneName == "" {
+		allErrs = append(allErrs, field.Required(fieldPath.Child("zoneName"), ""))
+	} else {
+		allErrs = append(allErrs, validateDNS1035Label(cache.ZoneName, fieldPath.Child("zoneName"))...)
+	}
+
+	allErrs = append(allErrs, validateSize(cache.ZoneSize, fieldPath.Child("zoneSize"))...)
+
+	if cache.Key != "" {
+		allErrs = append(allErrs, validateCacheString(cache.Key, fieldPath.Child("key"), hostVariables)...)
+	}
+
+	for i, valid := range cache.Valid {
+		idxPath := fieldPath.Child("valid").Index(i)
+
+		for j, code := range valid.Codes {
+			if code < 100 || code > 599 {
+				allErrs = append(allErrs, field.Invalid(idxPath.Child("codes").Index(j), code, "must be a valid HTTP status code"))
+			}
+		}
+
+		if valid.Time == "" {
+			allErrs = append(allErrs, field.Required(idxPath.Child("time"), ""))
+		} else {
+			allErrs = append(allErrs, validateTime(valid.Time, idxPath.Child("time"))...)
+		}
+	}
+
+	for i, b := range cache.Bypass {
+		allErrs = append(allErrs, validateCacheString(b, fieldPath.Child("bypass").Index(i), hostVariables)...)
+	}
+
+	for i, n := range cache.NoCache {
+		allErrs = append(allErrs, validateCacheString(n, fieldPath.Child("noCache").Index(i), hostVariables)...)
+	}
+
+	allErrs = append(allErrs, validatePositiveIntOrZero(cache.MinUses, fieldPath.Child("minUses"))...)
+
+	if cache.Purge != nil {
+		allErrs = append(allErrs, validateClientIP(cache.Purge.Allow, fieldPath.Child("purge").Child("allow"))...)
+	}
+
+	return allErrs
+}
+
+// validateCacheString validates a string that the generator puts into a quoted argument of a proxy_cache directive.
+func validateCacheString(str string, fieldPath *field.Path, hostVariables sets.String) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if str == "" {
+		return append(allErrs, field.Required(fieldPath, ""))
+	}
+
+	if !escapedStringsFmtRegexp.MatchString(str) {
+		msg := validation.RegexError(escapedStringsErrMsg, escapedStringsFmt, "${scheme}${host}${request_uri}", "${cookie_nocache}", `\"${arg_nocache}\"`)
+		return append(allErrs, field.Invalid(fieldPath, str, msg))
 	}
 
+	allErrs = append(allErrs, validateStringWithVariables(str, fieldPath, withHostVariables(cacheVariables, hostVariables), cacheSpecialVariables)...)
+
 	return allErrs
 }
 
-func countActions(action *v1.Action) int {
-	var count int
-	if action.Pass != "" {
-		count++
-	}
+func validateActionLimitRate(action *v1.Action, fieldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
 
-	if action.Redirect != nil {
-		count++
+	if action.Pass == "" {
+		if action.LimitRate != "" {
+			allErrs = append(allErrs, field.Forbidden(fieldPath.Child("limitRate"), "can only be used with `pass`"))
+		}
+		if action.LimitRateAfter != "" {
+			allErrs = append(allErrs, field.Forbidden(fieldPath.Child("limitRateAfter"), "can only be used with `pass`"))
+		}
+		return allErrs
 	}
 
-	if action.Return != nil {
-		count++
-	}
+	allErrs = append(allErrs, validateSize(action.LimitRate, fieldPath.Child("limitRate"))...)
+	allErrs = append(allErrs, validateSize(action.LimitRateAfter, fieldPath.Child("limitRateAfter"))...)
 
-	return count
+	return allErrs
 }
 
-func validateAction(action *v1.Action, fieldPath *field.Path, upstreamNames sets.String) field.ErrorList {
+const serveDirectoryFmt = `[a-zA-Z0-9._-]+(/[a-zA-Z0-9._-]+)*`
+const serveDirectoryErrMsg = "must be a relative path of alphanumeric characters, '.', '_' or '-' separated by '/'"
+
+var serveDirectoryRegexp = regexp.MustCompile("^" + serveDirectoryFmt + "$")
+
+func validateActionServe(serve *v1.ActionServe, fieldPath *field.Path) field.ErrorList {
 	allErrs := field.ErrorList{}
 
-	if countActions(action) != 1 {
-		return append(allErrs, field.Required(fieldPath, "action must specify exactly one of `pass`, `redirect` or `return`"))
+	if (serve.Directory == "") == (serve.ConfigMap == "") {
+		return append(allErrs, field.Required(fieldPath, "must specify exactly one of `directory` or `configMap`"))
 	}
 
-	if action.Pass != "" {
-		allErrs = append(allErrs, validateReferencedUpstream(action.Pass, fieldPath.Child("pass"), upstreamNames)...)
+	if serve.Directory != "" {
+		allErrs = append(allErrs, validateServeDirectory(serve.Directory, fieldPath.Child("directory"))...)
+		if serve.Key != "" {
+			allErrs = append(allErrs, field.Forbidden(fieldPath.Child("key"), "can only be used with `configMap`"))
+		}
+		if serve.Type != "" {
+			allErrs = append(allErrs, field.Forbidden(fieldPath.Child("type"), "can only be used with `configMap`"))
+		}
+		return allErrs
 	}
 
-	if action.Redirect != nil {
-		allErrs = append(allErrs, validateActionRedirect(action.Redirect, fieldPath.Child("redirect"))...)
+	for _, msg := range validation.IsDNS1123Subdomain(serve.ConfigMap) {
+		allErrs = append(allErrs, field.Invalid(fieldPath.Child("configMap"), serve.ConfigMap, msg))
 	}
 
-	if action.Return != nil {
-		allErrs = append(allErrs, validateActionReturn(action.Return, fieldPath.Child("return"))...)
+	if serve.Key == "" {
+		allErrs = append(allErrs, field.Required(fieldPath.Child("key"), ""))
+	} else {
+		for _, msg := range validation.IsConfigMapKey(serve.Key) {
+			allErrs = append(allErrs, field.Invalid(fieldPath.Child("key"), serve.Key, msg))
+		}
+	}
+
+	if serve.Type != "" {
+		allErrs = append(allErrs, validateActionReturnType(serve.Type, fieldPath.Child("type"))...)
+	}
+
+	return allErrs
+}
+
+// validateServeDirectory validates that the directory stays within the directory of the static content.
+func validateServeDirectory(directory string, fieldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if !serveDirectoryRegexp.MatchString(directory) {
+		msg := validation.RegexError(serveDirectoryErrMsg, serveDirectoryFmt, "maintenance", "cafe/well-known")
+		return append(allErrs, field.Invalid(fieldPath, directory, msg))
+	}
+
+	for _, part := range strings.Split(directory, "/") {
+		if part == "." || part == ".." {
+			return append(allErrs, field.Invalid(fieldPath, directory, "must not contain '.' or '..' path segments"))
+		}
 	}
 
 	return allErrs
 }
 
-func validateActionRedirect(redirect *v1.ActionRedirect, fieldPath *field.Path) field.ErrorList {
+func validateActionRedirect(redirect *v1.ActionRedirect, fieldPath *field.Path, hostVariables sets.String) field.ErrorList {
 	allErrs := field.ErrorList{}
 
-	allErrs = append(allErrs, validateRedirectURL(redirect.URL, fieldPath.Child("url"))...)
+	allErrs = append(allErrs, validateRedirectURL(redirect.URL, fieldPath.Child("url"), hostVariables)...)
 
 	if redirect.Code != 0 {
 		allErrs = append(allErrs, validateRedirectStatusCode(redirect.Code, fieldPath.Child("code"))...)
@@ -672,7 +2010,7 @@ var validRedirectVariableNames = map[string]bool{
 	"host":                   true,
 }
 
-func validateRedirectURL(redirectURL string, fieldPath *field.Path) field.ErrorList {
+func validateRedirectURL(redirectURL string, fieldPath *field.Path, hostVariables sets.String) field.ErrorList {
 	allErrs := field.ErrorList{}
 
 	if redirectURL == "" {
@@ -684,7 +2022,7 @@ func validateRedirectURL(redirectURL string, fieldPath *field.Path) field.ErrorL
 		return append(allErrs, field.Invalid(fieldPath, redirectURL, msg))
 	}
 
-	allErrs = append(allErrs, validateStringWithVariables(redirectURL, fieldPath, validRedirectVariableNames, nil)...)
+	allErrs = append(allErrs, validateStringWithVariables(redirectURL, fieldPath, withHostVariables(validRedirectVariableNames, hostVariables), nil)...)
 
 	return allErrs
 }
@@ -782,14 +2120,14 @@ func validateActionReturnCode(code int, fieldPath *field.Path) field.ErrorList {
 	return append(allErrs, field.Invalid(fieldPath, code, msg))
 }
 
-func validateActionReturn(r *v1.ActionReturn, fieldPath *field.Path) field.ErrorList {
+func validateActionReturn(r *v1.ActionReturn, fieldPath *field.Path, hostVariables sets.String, allowedVariables sets.String) field.ErrorList {
 	allErrs := field.ErrorList{}
 
 	if r.Body == "" {
 		return append(allErrs, field.Required(fieldPath.Child("body"), ""))
 	}
 
-	allErrs = append(allErrs, validateActionReturnBody(r.Body, fieldPath.Child("body"))...)
+	allErrs = append(allErrs, validateActionReturnBody(r.Body, fieldPath.Child("body"), hostVariables, allowedVariables)...)
 
 	if r.Type != "" {
 		allErrs = append(allErrs, validateActionReturnType(r.Type, fieldPath.Child("type"))...)
@@ -831,7 +2169,7 @@ var returnBodyVariables = map[string]bool{
 
 var returnBodySpecialVariables = []string{"arg_", "http_", "cookie_"}
 
-func validateActionReturnBody(body string, fieldPath *field.Path) field.ErrorList {
+func validateActionReturnBody(body string, fieldPath *field.Path, hostVariables sets.String, allowedVariables sets.String) field.ErrorList {
 	allErrs := field.ErrorList{}
 
 	if !escapedStringsFmtRegexp.MatchString(body) {
@@ -839,7 +2177,7 @@ func validateActionReturnBody(body string, fieldPath *field.Path) field.ErrorLis
 		allErrs = append(allErrs, field.Invalid(fieldPath, body, msg))
 	}
 
-	allErrs = append(allErrs, validateStringWithVariables(body, fieldPath, returnBodyVariables, returnBodySpecialVariables)...)
+	allErrs = append(allErrs, validateStringWithVariables(body, fieldPath, withHostVariables(returnBodyVariables, hostVariables.Union(allowedVariables)), returnBodySpecialVariables)...)
 
 	return allErrs
 }
@@ -893,7 +2231,7 @@ func validateReferencedUpstream(name string, fieldPath *field.Path, upstreamName
 	return allErrs
 }
 
-func validateSplits(splits []v1.Split, fieldPath *field.Path, upstreamNames sets.String) field.ErrorList {
+func validateSplits(splits []v1.Split, fieldPath *field.Path, upstreamNames sets.String, hostVariables sets.String, allowedVariables sets.String) field.ErrorList {
 	allErrs := field.ErrorList{}
 
 	if len(splits) < 2 {
@@ -905,14 +2243,15 @@ func validateSplits(splits []v1.Split, fieldPath *field.Path, upstreamNames sets
 	for i, s := range splits {
 		idxPath := fieldPath.Index(i)
 
-		for _, msg := range validation.IsInRange(s.Weight, 1, 99) {
+		// a split with the weight 0 keeps its action configured without traffic
+		for _, msg := range validation.IsInRange(s.Weight, 0, 100) {
 			allErrs = append(allErrs, field.Invalid(idxPath.Child("weight"), s.Weight, msg))
 		}
 
 		if s.Action == nil {
 			allErrs = append(allErrs, field.Required(idxPath.Child("action"), ""))
 		} else {
-			allErrs = append(allErrs, validateAction(s.Action, idxPath.Child("action"), upstreamNames)...)
+			allErrs = append(allErrs, validateAction(s.Action, idxPath.Child("action"), upstreamNames, hostVariables, allowedVariables)...)
 		}
 
 		totalWeight += s.Weight
@@ -950,15 +2289,30 @@ func validateRoutePath(path string, fieldPath *field.Path) field.ErrorList {
 func validateRegexPath(path string, fieldPath *field.Path) field.ErrorList {
 	allErrs := field.ErrorList{}
 
-	if _, err := regexp.Compile(path); err != nil {
-		return append(allErrs, field.Invalid(fieldPath, path, fmt.Sprintf("must be a valid regular expression: %v", err)))
-	}
-
 	if !escapedStringsFmtRegexp.MatchString(path) {
 		msg := validation.RegexError(escapedStringsErrMsg, escapedStringsFmt, "*.jpg", "^/images/image_*.png$")
 		return append(allErrs, field.Invalid(fieldPath, path, msg))
 	}
 
+	// the generator puts the regular expression into a quoted string, which NGINX unescapes before passing it to PCRE
+	expr := strings.TrimPrefix(strings.TrimPrefix(path, "~"), "*")
+	expr = unescapeNginxString(strings.TrimPrefix(expr, " "))
+	if expr == "" {
+		return append(allErrs, field.Invalid(fieldPath, path, "must include a regular expression after '~' or '~*'"))
+	}
+
+	re, err := compilePCRE(expr)
+	if err != nil {
+		return append(allErrs, field.Invalid(fieldPath, path, fmt.Sprintf("must be a valid PCRE regular expression supported by NGINX: %v", err)))
+	}
+
+	for _, name := range re.SubexpNames() {
+		if validRedirectVariableNames[name] || returnBodyVariables[name] {
+			msg := fmt.Sprintf("must not capture the NGINX variable '%v'", name)
+			allErrs = append(allErrs, field.Invalid(fieldPath, path, msg))
+		}
+	}
+
 	return allErrs
 }
 
@@ -982,26 +2336,43 @@ func validatePath(path string, fieldPath *field.Path) field.ErrorList {
 	return allErrs
 }
 
-func validateMatch(match v1.Match, fieldPath *field.Path, upstreamNames sets.String) field.ErrorList {
+// maxMatches limits the number of matches of a route. The Ingress Controller generates NGINX maps for the matches
+// with a few regular expressions per match, which slow down the processing of every request to the route.
+const maxMatches = 256
+
+// maxConditions limits the number of conditions of a match. Every condition adds a map to the chain of maps of the match.
+const maxConditions = 32
+
+// maxMatchPriority is the highest priority of a match.
+const maxMatchPriority = 100
+
+func validateMatch(match v1.Match, fieldPath *field.Path, upstreamNames sets.String, hostVariables sets.String, allowedVariables sets.String) field.ErrorList {
 	allErrs := field.ErrorList{}
 
 	if len(match.Conditions) == 0 {
 		allErrs = append(allErrs, field.Required(fieldPath.Child("conditions"), "must specify at least one condition"))
+	} else if len(match.Conditions) > maxConditions {
+		msg := fmt.Sprintf("must have at most %d conditions: combine the conditions on the values of the same variable into one condition with a regular expression value", maxConditions)
+		allErrs = append(allErrs, field.Invalid(fieldPath.Child("conditions"), len(match.Conditions), msg))
 	} else {
 		for i, c := range match.Conditions {
-			allErrs = append(allErrs, validateCondition(c, fieldPath.Child("conditions").Index(i))...)
+			allErrs = append(allErrs, validateCondition(c, fieldPath.Child("conditions").Index(i), allowedVariables)...)
 		}
 	}
 
+	for _, msg := range validation.IsInRange(match.Priority, 0, maxMatchPriority) {
+		allErrs = append(allErrs, field.Invalid(fieldPath.Child("priority"), match.Priority, msg))
+	}
+
 	fieldCount := 0
 
 	if match.Action != nil {
-		allErrs = append(allErrs, validateAction(match.Action, fieldPath.Child("action"), upstreamNames)...)
+		allErrs = append(allErrs, validateAction(match.Action, fieldPath.Child("action"), upstreamNames, hostVariables, allowedVariables)...)
 		fieldCount++
 	}
 
 	if len(match.Splits) > 0 {
-		allErrs = append(allErrs, validateSplits(match.Splits, fieldPath.Child("splits"), upstreamNames)...)
+		allErrs = append(allErrs, validateSplits(match.Splits, fieldPath.Child("splits"), upstreamNames, hostVariables, allowedVariables)...)
 		fieldCount++
 	}
 
@@ -1012,7 +2383,7 @@ func validateMatch(match v1.Match, fieldPath *field.Path, upstreamNames sets.Str
 	return allErrs
 }
 
-func validateCondition(condition v1.Condition, fieldPath *field.Path) field.ErrorList {
+func validateCondition(condition v1.Condition, fieldPath *field.Path, allowedVariables sets.String) field.ErrorList {
 	allErrs := field.ErrorList{}
 
 	fieldCount := 0
@@ -1039,21 +2410,282 @@ func validateCondition(condition v1.Condition, fieldPath *field.Path) field.Erro
 	}
 
 	if condition.Variable != "" {
-		allErrs = append(allErrs, validateVariableName(condition.Variable, fieldPath.Child("variable"))...)
+		allErrs = append(allErrs, validateVariableName(condition.Variable, fieldPath.Child("variable"), allowedVariables)...)
+		fieldCount++
+	}
+
+	if condition.ClientIP != nil {
+		allErrs = append(allErrs, validateClientIP(condition.ClientIP, fieldPath.Child("clientIP"))...)
 		fieldCount++
 	}
 
 	if fieldCount != 1 {
-		allErrs = append(allErrs, field.Invalid(fieldPath, "", "must specify exactly one of: `header`, `cookie`, `argument` or `variable`"))
+		allErrs = append(allErrs, field.Invalid(fieldPath, "", "must specify exactly one of: `header`, `cookie`, `argument`, `variable` or `clientIP`"))
+	}
+
+	valueFields := getConditionValueFields(condition)
+
+	if condition.ClientIP != nil {
+		// the addresses are matched without a value
+		for _, name := range valueFields {
+			allErrs = append(allErrs, field.Forbidden(fieldPath.Child(name), "can't be used with `clientIP`"))
+		}
+		return allErrs
+	}
+
+	if len(valueFields) > 1 {
+		return append(allErrs, field.Invalid(fieldPath, "", "must specify at most one of: `value`, `valueRegex` or `values`"))
+	}
+
+	if condition.ValueRegex != "" {
+		return append(allErrs, validateConditionValueRegex(condition.ValueRegex, fieldPath.Child("valueRegex"))...)
 	}
 
-	for _, msg := range isValidMatchValue(condition.Value) {
+	if condition.Values != nil {
+		return append(allErrs, validateConditionValues(condition, fieldCount == 1, fieldPath.Child("values"))...)
+	}
+
+	msgs := isValidMatchValue(condition.Value)
+	if len(msgs) == 0 && fieldCount == 1 {
+		msgs = isValidConditionValue(condition)
+	}
+
+	for _, msg := range msgs {
 		allErrs = append(allErrs, field.Invalid(fieldPath.Child("value"), condition.Value, msg))
 	}
 
 	return allErrs
 }
 
+// getConditionValueFields returns the names of the fields of the condition that define the values to match.
+func getConditionValueFields(condition v1.Condition) []string {
+	var names []string
+
+	if condition.Value != "" {
+		names = append(names, "value")
+	}
+	if condition.ValueRegex != "" {
+		names = append(names, "valueRegex")
+	}
+	if condition.Values != nil {
+		names = append(names, "values")
+	}
+
+	return names
+}
+
+// validateConditionValues validates the values of a condition, any of which matches. The values can't be negated,
+// because the negations of different values always match together. The values must be unique, so that the parameters
+// of the map of the condition are unique. NGINX compares the strings case-insensitively.
+// The values are checked against the header, cookie, argument or variable of the condition if hasSource is true.
+func validateConditionValues(condition v1.Condition, hasSource bool, fieldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if len(condition.Values) == 0 {
+		return append(allErrs, field.Required(fieldPath, "must include at least one value"))
+	}
+
+	allValues := sets.String{}
+
+	for i, value := range condition.Values {
+		idxPath := fieldPath.Index(i)
+
+		if strings.HasPrefix(value, "!") {
+			allErrs = append(allErrs, field.Invalid(idxPath, value, "must not be negated; use `value` or `valueRegex` for a negated match"))
+			continue
+		}
+
+		msgs := isValidMatchValue(value)
+		if len(msgs) == 0 && hasSource {
+			valueCondition := condition
+			valueCondition.Value = value
+			msgs = isValidConditionValue(valueCondition)
+		}
+
+		for _, msg := range msgs {
+			allErrs = append(allErrs, field.Invalid(idxPath, value, msg))
+		}
+
+		key := value
+		if !strings.HasPrefix(value, "~") {
+			key = strings.ToLower(value)
+		}
+
+		if allValues.Has(key) {
+			allErrs = append(allErrs, field.Duplicate(idxPath, value))
+		} else {
+			allValues.Insert(key)
+		}
+	}
+
+	return allErrs
+}
+
+// validateConditionValueRegex validates the regular expression of a condition, which is negated by a leading '!'.
+// Unlike a regex value, the regular expression is escaped by the generator, so it can include quotes and backslashes.
+func validateConditionValueRegex(regex string, fieldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	expr := strings.TrimPrefix(regex, "!")
+	if expr == "" {
+		return append(allErrs, field.Invalid(fieldPath, regex, "must include a regular expression"))
+	}
+
+	if containsControlCharacters(expr) {
+		return append(allErrs, field.Invalid(fieldPath, regex, "must not include control characters"))
+	}
+
+	if _, err := translatePCRE(expr); err != nil {
+		allErrs = append(allErrs, field.Invalid(fieldPath, regex, fmt.Sprintf("must be a valid PCRE regular expression supported by NGINX: %v", err)))
+	}
+
+	return allErrs
+}
+
+// validateClientIP validates the IPv4 and IPv6 addresses and CIDRs of a clientIP condition.
+func validateClientIP(addresses []string, fieldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if len(addresses) == 0 {
+		return append(allErrs, field.Required(fieldPath, "must include at least one address or CIDR"))
+	}
+
+	for i, address := range addresses {
+		idxPath := fieldPath.Index(i)
+
+		if strings.Contains(address, "/") {
+			if _, _, err := net.ParseCIDR(address); err != nil {
+				allErrs = append(allErrs, field.Invalid(idxPath, address, "must be a valid CIDR, e.g. 10.0.0.0/8 or 2001:db8::/32"))
+			}
+			continue
+		}
+
+		if net.ParseIP(address) == nil {
+			allErrs = append(allErrs, field.Invalid(idxPath, address, "must be a valid IPv4 or IPv6 address or a CIDR"))
+		}
+	}
+
+	return allErrs
+}
+
+// isValidConditionValue checks that the value of a condition can match the header, cookie, argument or variable of the condition.
+// For example, the value `GET POST` of the `$request_method` variable never matches.
+func isValidConditionValue(condition v1.Condition) []string {
+	value := strings.TrimPrefix(condition.Value, "!")
+	if value == "" {
+		return nil
+	}
+
+	// the value is put into a quoted string of a map, which NGINX unescapes
+	value = unescapeNginxString(value)
+
+	if strings.HasPrefix(value, "~") {
+		expr := strings.TrimPrefix(strings.TrimPrefix(value, "~"), "*")
+		if _, err := translatePCRE(expr); err != nil {
+			return []string{fmt.Sprintf("must be a valid PCRE regular expression supported by NGINX: %v", err)}
+		}
+		return nil
+	}
+
+	switch {
+	case condition.Header != "":
+		return isHeaderValue(value)
+	case condition.Cookie != "":
+		return isCookieValue(value)
+	case condition.Argument != "":
+		return isArgumentValue(value)
+	}
+
+	if isValidValue, exists := variableValueValidators[condition.Variable]; exists {
+		return isValidValue(value)
+	}
+
+	return nil
+}
+
+func containsControlCharacters(value string) bool {
+	for _, c := range value {
+		if c < ' ' && c != '\t' || c == 0x7f {
+			return true
+		}
+	}
+	return false
+}
+
+func isHeaderValue(value string) []string {
+	if containsControlCharacters(value) {
+		return []string{"a header value must not include control characters"}
+	}
+	if strings.TrimSpace(value) != value {
+		return []string{"a header value must not start or end with whitespace, which NGINX removes from headers"}
+	}
+	return nil
+}
+
+func isCookieValue(value string) []string {
+	if containsControlCharacters(value) || strings.ContainsAny(value, " \t;") {
+		return []string{"a cookie value must not include whitespace, control characters or ';'"}
+	}
+	return nil
+}
+
+func isArgumentValue(value string) []string {
+	if containsControlCharacters(value) || strings.ContainsAny(value, " \t&#") {
+		return []string{"an argument value must not include whitespace, control characters, '&' or '#'"}
+	}
+	return nil
+}
+
+const httpMethodFmt = "[-!#$%&'*+.^_`|~0-9A-Za-z]+"
+
+var httpMethodRegexp = regexp.MustCompile("^" + httpMethodFmt + "$")
+
+// variableValueValidators includes the validators of the values of the NGINX variables with a known set of values.
+var variableValueValidators = map[string]func(string) []string{
+	"$request_method": func(value string) []string {
+		if !httpMethodRegexp.MatchString(value) {
+			return []string{validation.RegexError("must be a single HTTP method", httpMethodFmt, "GET", "POST")}
+		}
+		return nil
+	},
+	"$scheme": func(value string) []string {
+		return isOneOfValues(value, "http", "https")
+	},
+	"$https": func(value string) []string {
+		return isOneOfValues(value, "on")
+	},
+	"$http2": func(value string) []string {
+		return isOneOfValues(value, "h2", "h2c")
+	},
+	"$remote_addr": func(value string) []string {
+		if net.ParseIP(value) == nil {
+			return []string{"must be a valid IP address"}
+		}
+		return nil
+	},
+	"$remote_port": func(value string) []string {
+		return validation.IsValidPortNum(parsePortNum(value))
+	},
+}
+
+// isOneOfValues checks that the value is one of the allowed values, ignoring the case, as the comparison in a map does.
+func isOneOfValues(value string, allowedValues ...string) []string {
+	for _, v := range allowedValues {
+		if strings.EqualFold(value, v) {
+			return nil
+		}
+	}
+	return []string{fmt.Sprintf("must be one of: '%s' or empty", strings.Join(allowedValues, "', '"))}
+}
+
+func parsePortNum(value string) int {
+	port, err := strconv.Atoi(value)
+	if err != nil {
+		return 0
+	}
+	return port
+}
+
 const cookieNameFmt string = "[_A-Za-z0-9]+"
 const cookieNameErrMsg string = "a valid cookie name must consist of alphanumeric characters or '_'"
 
@@ -1094,20 +2726,64 @@ var validVariableNames = map[string]bool{
 	"$scheme":         true,
 }
 
-func validateVariableName(name string, fieldPath *field.Path) field.ErrorList {
+// validateVariableName validates the variable of a condition. Besides validVariableNames, allowedVariables lists
+// the names of the allowed variables without the leading `$`.
+func validateVariableName(name string, fieldPath *field.Path, allowedVariables sets.String) field.ErrorList {
 	allErrs := field.ErrorList{}
 
 	if !strings.HasPrefix(name, "$") {
 		return append(allErrs, field.Invalid(fieldPath, name, "must start with `$`"))
 	}
 
-	if _, exists := validVariableNames[name]; !exists {
+	if strings.HasPrefix(name, jwtClaimVariablePrefix) {
+		claim := strings.TrimPrefix(name, jwtClaimVariablePrefix)
+		if !jwtClaimNameRegexp.MatchString(claim) {
+			msg := validation.RegexError(jwtClaimNameErrMsg, jwtClaimNameFmt, "tenant", "user_role")
+			return append(allErrs, field.Invalid(fieldPath, name, msg))
+		}
+		return allErrs
+	}
+
+	if !validVariableNames[name] && !allowedVariables.Has(strings.TrimPrefix(name, "$")) {
 		return append(allErrs, field.Invalid(fieldPath, name, "is not allowed or is not an NGINX variable"))
 	}
 
 	return allErrs
 }
 
+// jwtClaimVariablePrefix is the prefix of the NGINX Plus variables of the claims of the JWT validated by a JWT policy.
+const jwtClaimVariablePrefix = "$jwt_claim_"
+
+const (
+	jwtClaimNameFmt    = `[a-zA-Z0-9_]+`
+	jwtClaimNameErrMsg = "the name of a JWT claim must consist of alphanumeric characters or '_'"
+)
+
+var jwtClaimNameRegexp = regexp.MustCompile("^" + jwtClaimNameFmt + "$")
+
+// validateJWTClaimConditions validates that the conditions of the routes use the variables of JWT claims only in NGINX Plus.
+// NGINX doesn't define the variables, so unlike other NGINX Plus features, the conditions can't be ignored.
+func validateJWTClaimConditions(routes []v1.Route, fieldPath *field.Path, isPlus bool) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if isPlus {
+		return allErrs
+	}
+
+	for i, r := range routes {
+		for j, m := range r.Matches {
+			for k, c := range m.Conditions {
+				if strings.HasPrefix(c.Variable, jwtClaimVariablePrefix) {
+					condPath := fieldPath.Index(i).Child("matches").Index(j).Child("conditions").Index(k).Child("variable")
+					allErrs = append(allErrs, field.Forbidden(condPath, "the variables of JWT claims are only supported in NGINX Plus"))
+				}
+			}
+		}
+	}
+
+	return allErrs
+}
+
 func isValidMatchValue(value string) []string {
 	if !escapedStringsFmtRegexp.MatchString(value) {
 		return []string{validation.RegexError(escapedStringsErrMsg, escapedStringsFmt, "value-123")}
@@ -1117,17 +2793,79 @@ func isValidMatchValue(value string) []string {
 
 // ValidateVirtualServerRoute validates a VirtualServerRoute.
 func ValidateVirtualServerRoute(virtualServerRoute *v1.VirtualServerRoute, isPlus bool) error {
-	allErrs := validateVirtualServerRouteSpec(&virtualServerRoute.Spec, field.NewPath("spec"), "", "/", isPlus)
-	return allErrs.ToAggregate()
+	_, err := ValidateVirtualServerRouteWithWarnings(virtualServerRoute, isPlus, false, nil, StrictValidation)
+	return err
+}
+
+// ValidateVirtualServerRouteWithWarnings validates a VirtualServerRoute. It handles the problems that NGINX can work around
+// like ValidateVirtualServerWithWarnings.
+func ValidateVirtualServerRouteWithWarnings(virtualServerRoute *v1.VirtualServerRoute, isPlus bool, emulateQueue bool, allowedVariables sets.String,
+	strictness Strictness) (field.ErrorList, error) {
+	return ValidateVirtualServerRouteForVirtualServerWithWarnings(virtualServerRoute, "", "/", isPlus, emulateQueue, allowedVariables, strictness)
 }
 
 // ValidateVirtualServerRouteForVirtualServer validates a VirtualServerRoute for a VirtualServer represented by its host and path prefix.
 func ValidateVirtualServerRouteForVirtualServer(virtualServerRoute *v1.VirtualServerRoute, virtualServerHost string, vsPath string, isPlus bool) error {
-	allErrs := validateVirtualServerRouteSpec(&virtualServerRoute.Spec, field.NewPath("spec"), virtualServerHost, vsPath, isPlus)
-	return allErrs.ToAggregate()
+	_, err := ValidateVirtualServerRouteForVirtualServerWithWarnings(virtualServerRoute, virtualServerHost, vsPath, isPlus, false, nil, StrictValidation)
+	return err
+}
+
+// ValidateVirtualServerRouteForVirtualServerWithWarnings validates a VirtualServerRoute for a VirtualServer represented by its host and path prefix.
+// It handles the problems that NGINX can work around like ValidateVirtualServerWithWarnings.
+func ValidateVirtualServerRouteForVirtualServerWithWarnings(virtualServerRoute *v1.VirtualServerRoute, virtualServerHost string, vsPath string,
+	isPlus bool, emulateQueue bool, allowedVariables sets.String, strictness Strictness) (field.ErrorList, error) {
+	fieldPath := field.NewPath("spec")
+
+	allErrs := validateVirtualServerRouteSpec(&virtualServerRoute.Spec, fieldPath, virtualServerHost, vsPath, isPlus, allowedVariables)
+	problems := rejectPlusResourcesInOSSForUpstreams(virtualServerRoute.Spec.Upstreams, fieldPath.Child("upstreams"), isPlus, emulateQueue)
+	problems = append(problems, rejectPlusResourcesInOSSForRoutes(virtualServerRoute.Spec.Subroutes, fieldPath.Child("subroutes"), isPlus)...)
+
+	return applyStrictness(allErrs, problems, strictness)
+}
+
+// ValidateVirtualServerRouteReferences resolves the VirtualServerRoutes referenced in the routes of a VirtualServer
+// using getVirtualServerRoute and validates them for the host and the path prefix of the VirtualServer.
+// A route without a namespace references a VirtualServerRoute in the namespace of the VirtualServer.
+func ValidateVirtualServerRouteReferences(virtualServer *v1.VirtualServer, getVirtualServerRoute func(key string) (*v1.VirtualServerRoute, bool),
+	isPlus bool, emulateQueue bool, allowedVariables sets.String, strictness Strictness) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	fieldPath := field.NewPath("spec").Child("routes")
+
+	for i, r := range virtualServer.Spec.Routes {
+		if r.Route == "" {
+			continue
+		}
+
+		routePath := fieldPath.Index(i).Child("route")
+		key := getVirtualServerRouteKey(r.Route, virtualServer.Namespace)
+
+		vsr, exists := getVirtualServerRoute(key)
+		if !exists {
+			allErrs = append(allErrs, field.NotFound(routePath, key))
+			continue
+		}
+
+		_, err := ValidateVirtualServerRouteForVirtualServerWithWarnings(vsr, virtualServer.Spec.Host, r.Path, isPlus, emulateQueue, allowedVariables, strictness)
+		if err != nil {
+			allErrs = append(allErrs, field.Invalid(routePath, key, err.Error()))
+		}
+	}
+
+	return allErrs
+}
+
+// getVirtualServerRouteKey returns the namespace/name key of the VirtualServerRoute referenced by a route of a VirtualServer
+// from the namespace vsNamespace.
+func getVirtualServerRouteKey(route string, vsNamespace string) string {
+	if strings.Contains(route, "/") {
+		return route
+	}
+	return fmt.Sprintf("%s/%s", vsNamespace, route)
 }
 
-func validateVirtualServerRouteSpec(spec *v1.VirtualServerRouteSpec, fieldPath *field.Path, virtualServerHost string, vsPath string, isPlus bool) field.ErrorList {
+func validateVirtualServerRouteSpec(spec *v1.VirtualServerRouteSpec, fieldPath *field.Path, virtualServerHost string, vsPath string, isPlus bool,
+	allowedVariables sets.String) field.ErrorList {
 	allErrs := field.ErrorList{}
 
 	allErrs = append(allErrs, validateVirtualServerRouteHost(spec.Host, virtualServerHost, fieldPath.Child("host"))...)
@@ -1135,7 +2873,9 @@ func validateVirtualServerRouteSpec(spec *v1.VirtualServerRouteSpec, fieldPath *
 	upstreamErrs, upstreamNames := validateUpstreams(spec.Upstreams, fieldPath.Child("upstreams"), isPlus)
 	allErrs = append(allErrs, upstreamErrs...)
 
-	allErrs = append(allErrs, validateVirtualServerRouteSubroutes(spec.Subroutes, fieldPath.Child("subroutes"), upstreamNames, vsPath)...)
+	hostVariables := getHostVariables(spec.Host)
+	allErrs = append(allErrs, validateVirtualServerRouteSubroutes(spec.Subroutes, fieldPath.Child("subroutes"), upstreamNames, vsPath, hostVariables, allowedVariables)...)
+	allErrs = append(allErrs, validateJWTClaimConditions(spec.Subroutes, fieldPath.Child("subroutes"), isPlus)...)
 
 	return allErrs
 }
@@ -1157,7 +2897,7 @@ func isRegexOrExactMatch(path string) bool {
 	return strings.HasPrefix(path, "~") || strings.HasPrefix(path, "=")
 }
 
-func validateVirtualServerRouteSubroutes(routes []v1.Route, fieldPath *field.Path, upstreamNames sets.String, vsPath string) field.ErrorList {
+func validateVirtualServerRouteSubroutes(routes []v1.Route, fieldPath *field.Path, upstreamNames sets.String, vsPath string, hostVariables sets.String, allowedVariables sets.String) field.ErrorList {
 	allErrs := field.ErrorList{}
 
 	allPaths := sets.String{}
@@ -1172,14 +2912,14 @@ func validateVirtualServerRouteSubroutes(routes []v1.Route, fieldPath *field.Pat
 			return append(allErrs, field.Invalid(idxPath.Child("path"), routes[0].Path, "must have the same path as the referenced VirtualServer route path"))
 		}
 
-		return validateRoute(routes[0], idxPath, upstreamNames, true)
+		return validateRoute(routes[0], idxPath, upstreamNames, true, hostVariables, allowedVariables)
 	}
 
 	for i, r := range routes {
 		idxPath := fieldPath.Index(i)
 
 		isRouteFieldForbidden := true
-		routeErrs := validateRoute(r, idxPath, upstreamNames, isRouteFieldForbidden)
+		routeErrs := validateRoute(r, idxPath, upstreamNames, isRouteFieldForbidden, hostVariables, allowedVariables)
 
 		if vsPath != "" && !strings.HasPrefix(r.Path, vsPath) && !isRegexOrExactMatch(r.Path) {
 			msg := fmt.Sprintf("must start with '%s'", vsPath)
@@ -1198,7 +2938,17 @@ func validateVirtualServerRouteSubroutes(routes []v1.Route, fieldPath *field.Pat
 	return allErrs
 }
 
-func rejectPlusResourcesInOSS(upstream v1.Upstream, idxPath *field.Path, isPlus bool) field.ErrorList {
+func rejectPlusResourcesInOSSForUpstreams(upstreams []v1.Upstream, fieldPath *field.Path, isPlus bool, emulateQueue bool) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	for i, u := range upstreams {
+		allErrs = append(allErrs, rejectPlusResourcesInOSS(u, fieldPath.Index(i), isPlus, emulateQueue)...)
+	}
+
+	return allErrs
+}
+
+func rejectPlusResourcesInOSS(upstream v1.Upstream, idxPath *field.Path, isPlus bool, emulateQueue bool) field.ErrorList {
 	allErrs := field.ErrorList{}
 
 	if isPlus {
@@ -1217,10 +2967,54 @@ func rejectPlusResourcesInOSS(upstream v1.Upstream, idxPath *field.Path, isPlus
 		allErrs = append(allErrs, field.Forbidden(idxPath.Child("sessionCookie"), "sticky cookies are only supported in NGINX Plus"))
 	}
 
-	if upstream.Queue != nil {
+	if upstream.Queue != nil && !emulateQueue {
 		allErrs = append(allErrs, field.Forbidden(idxPath.Child("queue"), "queue is only supported in NGINX Plus"))
 	}
 
+	if upstream.Resolve {
+		allErrs = append(allErrs, field.Forbidden(idxPath.Child("resolve"), "resolve is only supported in NGINX Plus"))
+	}
+
+	return allErrs
+}
+
+func rejectPlusResourcesInOSSForRoutes(routes []v1.Route, fieldPath *field.Path, isPlus bool) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if isPlus {
+		return allErrs
+	}
+
+	for i, r := range routes {
+		idxPath := fieldPath.Index(i)
+
+		if r.SplitsDynamicWeights {
+			allErrs = append(allErrs, field.Forbidden(idxPath.Child("splitsDynamicWeights"), "dynamic weights of splits are only supported in NGINX Plus"))
+		}
+
+		allErrs = append(allErrs, rejectCachePurgeInOSS(r.Action, idxPath.Child("action"))...)
+		for j, s := range r.Splits {
+			allErrs = append(allErrs, rejectCachePurgeInOSS(s.Action, idxPath.Child("splits").Index(j).Child("action"))...)
+		}
+		for j, m := range r.Matches {
+			matchPath := idxPath.Child("matches").Index(j)
+			allErrs = append(allErrs, rejectCachePurgeInOSS(m.Action, matchPath.Child("action"))...)
+			for k, s := range m.Splits {
+				allErrs = append(allErrs, rejectCachePurgeInOSS(s.Action, matchPath.Child("splits").Index(k).Child("action"))...)
+			}
+		}
+	}
+
+	return allErrs
+}
+
+func rejectCachePurgeInOSS(action *v1.Action, fieldPath *field.Path) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	if action != nil && action.Cache != nil && action.Cache.Purge != nil {
+		allErrs = append(allErrs, field.Forbidden(fieldPath.Child("cache").Child("purge"), "purging the cache is only supported in NGINX Plus"))
+	}
+
 	return allErrs
 }
 
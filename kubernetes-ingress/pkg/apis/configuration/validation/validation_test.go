@@ -65,6 +65,8 @@ func TestValidateHost(t *testing.T) {
 		"hello",
 		"example.com",
 		"hello-world-1",
+		`~^(?<subdomain>.+)\.example\.com$`,
+		`~^(www\.)?example\.com$`,
 	}
 
 	for _, h := range validHosts {
@@ -80,6 +82,11 @@ func TestValidateHost(t *testing.T) {
 		"..",
 		".example.com",
 		"-hello-world-1",
+		"~",
+		`~^(.+\.example\.com$`,
+		`~^\d{3}\.example\.com$`,
+		`~^(?<host>.+)\.example\.com$`,
+		"~^example.com; return 200",
 	}
 
 	for _, h := range invalidHosts {
@@ -90,6 +97,86 @@ func TestValidateHost(t *testing.T) {
 	}
 }
 
+func TestGetHostVariables(t *testing.T) {
+	tests := []struct {
+		host     string
+		expected sets.String
+	}{
+		{
+			host:     "example.com",
+			expected: sets.NewString(),
+		},
+		{
+			host:     `~^(www\.)?example\.com$`,
+			expected: sets.NewString(),
+		},
+		{
+			host:     `~^(?<subdomain>.+)\.(?<domain>example\.com)$`,
+			expected: sets.NewString("subdomain", "domain"),
+		},
+	}
+
+	for _, test := range tests {
+		result := getHostVariables(test.host)
+		if !result.Equal(test.expected) {
+			t.Errorf("getHostVariables(%q) returned %v but expected %v", test.host, result.List(), test.expected.List())
+		}
+	}
+}
+
+func TestMatchRegexHost(t *testing.T) {
+	regexHost := `~^(?<subdomain>.+)\.example\.com$`
+
+	if !MatchRegexHost(regexHost, "cafe.example.com") {
+		t.Errorf("MatchRegexHost(%q, %q) returned false", regexHost, "cafe.example.com")
+	}
+	if MatchRegexHost(regexHost, "example.com") {
+		t.Errorf("MatchRegexHost(%q, %q) returned true", regexHost, "example.com")
+	}
+}
+
+func TestValidateVirtualServerWithRegexHost(t *testing.T) {
+	virtualServer := v1.VirtualServer{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "cafe",
+			Namespace: "default",
+		},
+		Spec: v1.VirtualServerSpec{
+			Host: `~^(?<subdomain>.+)\.example\.com$`,
+			Routes: []v1.Route{
+				{
+					Path: "/",
+					Action: &v1.Action{
+						Redirect: &v1.ActionRedirect{
+							URL: "${scheme}://example.com/${subdomain}${request_uri}",
+						},
+					},
+				},
+				{
+					Path: "/hello",
+					Action: &v1.Action{
+						Return: &v1.ActionReturn{
+							Body: "Hello from ${subdomain}",
+						},
+					},
+				},
+			},
+		},
+	}
+
+	err := ValidateVirtualServer(&virtualServer, false)
+	if err != nil {
+		t.Errorf("ValidateVirtualServer() returned error %v for valid input %v", err, virtualServer)
+	}
+
+	virtualServer.Spec.Host = "cafe.example.com"
+
+	err = ValidateVirtualServer(&virtualServer, false)
+	if err == nil {
+		t.Errorf("ValidateVirtualServer() returned no error for a variable of a capture without a regex host")
+	}
+}
+
 func TestValidateTLS(t *testing.T) {
 	validTLSes := []*v1.TLS{
 		nil,
@@ -166,6 +253,457 @@ func TestValidateTLS(t *testing.T) {
 	}
 }
 
+func TestValidateListener(t *testing.T) {
+	validListeners := []*v1.VirtualServerListener{
+		nil,
+		{
+			HTTP: 8080,
+		},
+		{
+			HTTP:  8080,
+			HTTPS: 8443,
+		},
+	}
+
+	for _, listener := range validListeners {
+		allErrs := validateListener(listener, true, field.NewPath("listener"))
+		if len(allErrs) > 0 {
+			t.Errorf("validateListener() returned errors %v for valid input %v", allErrs, listener)
+		}
+	}
+
+	allErrs := validateListener(&v1.VirtualServerListener{HTTPS: 8443}, false, field.NewPath("listener"))
+	if len(allErrs) == 0 {
+		t.Errorf("validateListener() returned no errors for an https port without TLS")
+	}
+
+	invalidListeners := []*v1.VirtualServerListener{
+		{},
+		{
+			HTTP: 80,
+		},
+		{
+			HTTPS: 443,
+		},
+		{
+			HTTP: 65536,
+		},
+		{
+			HTTP:  8080,
+			HTTPS: 8080,
+		},
+	}
+
+	for _, listener := range invalidListeners {
+		allErrs := validateListener(listener, true, field.NewPath("listener"))
+		if len(allErrs) == 0 {
+			t.Errorf("validateListener() returned no errors for invalid input %v", listener)
+		}
+	}
+}
+
+func TestValidateRealIP(t *testing.T) {
+	recursive := true
+
+	validRealIPs := []*v1.RealIP{
+		nil,
+		{},
+		{
+			SetRealIPFrom: []string{"10.0.0.1", "192.168.0.0/16", "2001:db8::1", "2001:db8::/32", "unix:"},
+			Header:        "X-Forwarded-For",
+			Recursive:     &recursive,
+		},
+		{
+			Header: "proxy_protocol",
+		},
+	}
+
+	for _, realIP := range validRealIPs {
+		allErrs := validateRealIP(realIP, field.NewPath("realIP"))
+		if len(allErrs) > 0 {
+			t.Errorf("validateRealIP() returned errors %v for valid input %v", allErrs, realIP)
+		}
+	}
+}
+
+func TestValidateRealIPFails(t *testing.T) {
+	invalidRealIPs := []*v1.RealIP{
+		{
+			SetRealIPFrom: []string{""},
+		},
+		{
+			SetRealIPFrom: []string{"10.0.0.256"},
+		},
+		{
+			SetRealIPFrom: []string{"10.0.0.0/33"},
+		},
+		{
+			SetRealIPFrom: []string{"2001:db8::/129"},
+		},
+		{
+			SetRealIPFrom: []string{"example.com"},
+		},
+		{
+			Header: "X Forwarded For",
+		},
+	}
+
+	for _, realIP := range invalidRealIPs {
+		allErrs := validateRealIP(realIP, field.NewPath("realIP"))
+		if len(allErrs) == 0 {
+			t.Errorf("validateRealIP() returned no errors for invalid input %v", realIP)
+		}
+	}
+}
+
+func TestValidateXForwarded(t *testing.T) {
+	validXForwardeds := []*v1.XForwarded{
+		{},
+		{
+			For:   "append",
+			Proto: "overwrite",
+			Host:  "preserve",
+			Port:  "remove",
+		},
+		{
+			For: "overwrite",
+		},
+	}
+
+	for _, xForwarded := range validXForwardeds {
+		allErrs := validateXForwarded(xForwarded, field.NewPath("xForwarded"))
+		if len(allErrs) > 0 {
+			t.Errorf("validateXForwarded() returned errors %v for valid input %v", allErrs, xForwarded)
+		}
+	}
+}
+
+func TestValidateXForwardedFails(t *testing.T) {
+	invalidXForwardeds := []*v1.XForwarded{
+		{
+			For: "set",
+		},
+		{
+			Proto: "append",
+		},
+		{
+			Host: "append",
+		},
+		{
+			Port: "Remove",
+		},
+	}
+
+	for _, xForwarded := range invalidXForwardeds {
+		allErrs := validateXForwarded(xForwarded, field.NewPath("xForwarded"))
+		if len(allErrs) == 0 {
+			t.Errorf("validateXForwarded() returned no errors for invalid input %v", xForwarded)
+		}
+	}
+}
+
+func TestValidateAccessLog(t *testing.T) {
+	disabled := false
+
+	validAccessLogs := []*v1.AccessLog{
+		nil,
+		{},
+		{
+			Enable: &disabled,
+		},
+		{
+			FormatName:  "combined",
+			Destination: "/var/log/nginx/cafe.log",
+		},
+		{
+			Format:      `$remote_addr - [$time_local] \"$request\" $status ${request_time}s $http_user_agent $sent_http_content_type`,
+			Destination: "syslog:server=10.0.0.1:514,tag=cafe,severity=info",
+		},
+		{
+			FormatPreset: "json",
+			Destination:  "/var/log/nginx/cafe.log",
+		},
+		{
+			Format:      "$upstream_http_x_cache $cookie_session $arg_id",
+			Destination: "syslog:server=[2001:db8::1]:514",
+		},
+	}
+
+	for _, accessLog := range validAccessLogs {
+		allErrs := validateAccessLog(accessLog, field.NewPath("accessLog"))
+		if len(allErrs) > 0 {
+			t.Errorf("validateAccessLog() returned errors %v for valid input %v", allErrs, accessLog)
+		}
+	}
+}
+
+func TestValidateAccessLogFails(t *testing.T) {
+	invalidAccessLogs := []*v1.AccessLog{
+		{
+			FormatName: "main",
+			Format:     "$remote_addr",
+		},
+		{
+			FormatName: "my format",
+		},
+		{
+			FormatName:   "main",
+			FormatPreset: "json",
+		},
+		{
+			FormatPreset: "yaml",
+		},
+		{
+			Format: `$remote_addr "$request"`,
+		},
+		{
+			Format: "$remote_addr $invalid",
+		},
+		{
+			Format: "${remote_addr",
+		},
+		{
+			Format: "$remote_addr $",
+		},
+		{
+			Format: "$http_ $status",
+		},
+		{
+			Destination: "/etc/nginx/nginx.conf",
+		},
+		{
+			Destination: "/var/log/nginx/../../../etc/passwd",
+		},
+		{
+			Destination: "/var/log/nginx/",
+		},
+		{
+			Destination: "syslog:server=10.0.0.1 tag=cafe",
+		},
+	}
+
+	for _, accessLog := range invalidAccessLogs {
+		allErrs := validateAccessLog(accessLog, field.NewPath("accessLog"))
+		if len(allErrs) == 0 {
+			t.Errorf("validateAccessLog() returned no errors for invalid input %v", accessLog)
+		}
+	}
+}
+
+func TestValidateErrorLog(t *testing.T) {
+	validErrorLogs := []*v1.ErrorLog{
+		nil,
+		{},
+		{
+			Destination: "/var/log/nginx/cafe-error.log",
+			Level:       "warn",
+		},
+		{
+			Destination: "stderr",
+		},
+		{
+			Destination: "syslog:server=syslog.example.com:514,facility=local7,tag=cafe,severity=error,nohostname",
+			Level:       "debug",
+		},
+	}
+
+	for _, errorLog := range validErrorLogs {
+		allErrs := validateErrorLog(errorLog, field.NewPath("errorLog"))
+		if len(allErrs) > 0 {
+			t.Errorf("validateErrorLog() returned errors %v for valid input %v", allErrs, errorLog)
+		}
+	}
+}
+
+func TestValidateErrorLogFails(t *testing.T) {
+	invalidErrorLogs := []*v1.ErrorLog{
+		{
+			Level: "verbose",
+		},
+		{
+			Destination: "stdout",
+		},
+		{
+			Destination: "/tmp/error.log",
+		},
+		{
+			Destination: "syslog:server=10.0.0.1,severity=verbose",
+		},
+	}
+
+	for _, errorLog := range invalidErrorLogs {
+		allErrs := validateErrorLog(errorLog, field.NewPath("errorLog"))
+		if len(allErrs) == 0 {
+			t.Errorf("validateErrorLog() returned no errors for invalid input %v", errorLog)
+		}
+	}
+}
+
+func TestValidateRequestID(t *testing.T) {
+	validRequestIDs := []*v1.RequestID{
+		nil,
+		{
+			Enable: true,
+		},
+		{
+			Enable:   true,
+			Header:   "X-Correlation-ID",
+			Preserve: true,
+		},
+	}
+
+	for _, requestID := range validRequestIDs {
+		allErrs := validateRequestID(requestID, field.NewPath("requestID"))
+		if len(allErrs) > 0 {
+			t.Errorf("validateRequestID() returned errors %v for valid input %v", allErrs, requestID)
+		}
+	}
+
+	invalidRequestIDs := []*v1.RequestID{
+		{
+			Enable: true,
+			Header: "X Request ID",
+		},
+		{
+			Enable: true,
+			Header: "X-Request-ID:",
+		},
+	}
+
+	for _, requestID := range invalidRequestIDs {
+		allErrs := validateRequestID(requestID, field.NewPath("requestID"))
+		if len(allErrs) == 0 {
+			t.Errorf("validateRequestID() returned no errors for invalid input %v", requestID)
+		}
+	}
+}
+
+func TestValidateSyslogDestination(t *testing.T) {
+	validDestinations := []string{
+		"syslog:server=10.0.0.1",
+		"syslog:server=10.0.0.1:514",
+		"syslog:server=syslog.example.com",
+		"syslog:server=[2001:db8::1]",
+		"syslog:server=[2001:db8::1]:514",
+		"syslog:server=10.0.0.1,facility=local0,severity=info,tag=nginx_cafe,nohostname",
+	}
+
+	for _, destination := range validDestinations {
+		allErrs := validateSyslogDestination(destination, field.NewPath("destination"))
+		if len(allErrs) > 0 {
+			t.Errorf("validateSyslogDestination() returned errors %v for valid input %q", allErrs, destination)
+		}
+	}
+
+	invalidDestinations := []string{
+		"syslog:",
+		"syslog:tag=nginx",
+		"syslog:server=",
+		"syslog:server=unix:/var/log/nginx.sock",
+		"syslog:server=10.0.0.1:0",
+		"syslog:server=10.0.0.1:65536",
+		"syslog:server=2001:db8::1",
+		"syslog:server=syslog_example.com",
+		"syslog:server=10.0.0.1,facility=none",
+		"syslog:server=10.0.0.1,tag=my-tag",
+		"syslog:server=10.0.0.1,tag=abcdefghijklmnopqrstuvwxyz0123456",
+		"syslog:server=10.0.0.1,nohostname=on",
+		"syslog:server=10.0.0.1,buffer=32k",
+		"syslog:server=10.0.0.1,severity",
+	}
+
+	for _, destination := range invalidDestinations {
+		allErrs := validateSyslogDestination(destination, field.NewPath("destination"))
+		if len(allErrs) == 0 {
+			t.Errorf("validateSyslogDestination() returned no errors for invalid input %q", destination)
+		}
+	}
+}
+
+func TestValidateTracing(t *testing.T) {
+	percentage := 10
+	zero := 0
+
+	validTracings := []*v1.Tracing{
+		nil,
+		{},
+		{
+			Enable:             true,
+			OperationName:      "${request_method} ${uri}",
+			SamplingPercentage: &percentage,
+			Tags: []v1.TracingTag{
+				{Name: "component", Value: "nginx-ingress"},
+				{Name: "http.user_agent", Value: "${http_user_agent}"},
+				{Name: "customer", Value: `\"${cookie_customer}\"`},
+			},
+		},
+		{
+			Enable:             true,
+			SamplingPercentage: &zero,
+		},
+		{
+			Enable: true,
+			Tags: []v1.TracingTag{
+				{Name: "sampling.priority", Value: "1"},
+			},
+		},
+	}
+
+	for _, tracing := range validTracings {
+		allErrs := validateTracing(tracing, field.NewPath("tracing"), sets.String{})
+		if len(allErrs) > 0 {
+			t.Errorf("validateTracing() returned errors %v for valid input %v", allErrs, tracing)
+		}
+	}
+}
+
+func TestValidateTracingFails(t *testing.T) {
+	negative := -1
+	tooBig := 101
+	percentage := 50
+
+	invalidTracings := []*v1.Tracing{
+		{
+			OperationName: "${invalid}",
+		},
+		{
+			OperationName: `"unescaped"`,
+		},
+		{
+			SamplingPercentage: &negative,
+		},
+		{
+			SamplingPercentage: &tooBig,
+		},
+		{
+			Tags: []v1.TracingTag{{Name: "", Value: "value"}},
+		},
+		{
+			Tags: []v1.TracingTag{{Name: "my tag", Value: "value"}},
+		},
+		{
+			Tags: []v1.TracingTag{{Name: "tag", Value: ""}},
+		},
+		{
+			Tags: []v1.TracingTag{{Name: "tag", Value: "$uri"}},
+		},
+		{
+			Tags: []v1.TracingTag{{Name: "tag", Value: "one"}, {Name: "tag", Value: "two"}},
+		},
+		{
+			SamplingPercentage: &percentage,
+			Tags:               []v1.TracingTag{{Name: "sampling.priority", Value: "1"}},
+		},
+	}
+
+	for _, tracing := range invalidTracings {
+		allErrs := validateTracing(tracing, field.NewPath("tracing"), sets.String{})
+		if len(allErrs) == 0 {
+			t.Errorf("validateTracing() returned no errors for invalid input %v", tracing)
+		}
+	}
+}
+
 func TestValidateUpstreams(t *testing.T) {
 	tests := []struct {
 		upstreams             []v1.Upstream
@@ -204,6 +742,19 @@ func TestValidateUpstreams(t *testing.T) {
 			},
 			msg: "2 valid upstreams",
 		},
+		{
+			upstreams: []v1.Upstream{
+				{
+					Name:     "upstream1",
+					Service:  "test-1",
+					PortName: "http",
+				},
+			},
+			expectedUpstreamNames: map[string]sets.Empty{
+				"upstream1": {},
+			},
+			msg: "upstream with port name",
+		},
 	}
 	isPlus := false
 	for _, test := range tests {
@@ -269,6 +820,62 @@ func TestValidateUpstreamsFails(t *testing.T) {
 			},
 			msg: "invalid port",
 		},
+		{
+			upstreams: []v1.Upstream{
+				{
+					Name:        "upstream1",
+					Service:     "test-1",
+					Port:        80,
+					Subselector: map[string]string{"version": "v1"},
+					Resolve:     true,
+				},
+			},
+			expectedUpstreamNames: map[string]sets.Empty{
+				"upstream1": {},
+			},
+			msg: "resolve with subselector",
+		},
+		{
+			upstreams: []v1.Upstream{
+				{
+					Name:     "upstream1",
+					Service:  "test-1",
+					Port:     80,
+					PortName: "http",
+				},
+			},
+			expectedUpstreamNames: map[string]sets.Empty{
+				"upstream1": {},
+			},
+			msg: "port with port name",
+		},
+		{
+			upstreams: []v1.Upstream{
+				{
+					Name:     "upstream1",
+					Service:  "test-1",
+					PortName: "-http",
+				},
+			},
+			expectedUpstreamNames: map[string]sets.Empty{
+				"upstream1": {},
+			},
+			msg: "invalid port name",
+		},
+		{
+			upstreams: []v1.Upstream{
+				{
+					Name:     "upstream1",
+					Service:  "test-1",
+					PortName: "http",
+					Resolve:  true,
+				},
+			},
+			expectedUpstreamNames: map[string]sets.Empty{
+				"upstream1": {},
+			},
+			msg: "resolve with port name",
+		},
 		{
 			upstreams: []v1.Upstream{
 				{
@@ -452,30 +1059,122 @@ func TestValidateNextUpstream(t *testing.T) {
 		{
 			inputS: "http_404 timeout",
 		},
-	}
-	for _, test := range tests {
-		allErrs := validateNextUpstream(test.inputS, field.NewPath("next-upstreams"))
-		if len(allErrs) > 0 {
-			t.Errorf("validateNextUpstream(%q) returned errors %v for valid input.", test.inputS, allErrs)
-		}
-	}
-}
-
-func TestValidateNextUpstreamFails(t *testing.T) {
-	tests := []struct {
-		inputS string
-	}{
+	}
+	for _, test := range tests {
+		allErrs := validateNextUpstream(test.inputS, field.NewPath("next-upstreams"))
+		if len(allErrs) > 0 {
+			t.Errorf("validateNextUpstream(%q) returned errors %v for valid input.", test.inputS, allErrs)
+		}
+	}
+}
+
+func TestValidateNextUpstreamFails(t *testing.T) {
+	tests := []struct {
+		inputS string
+	}{
+		{
+			inputS: "error error",
+		},
+		{
+			inputS: "https_404",
+		},
+	}
+	for _, test := range tests {
+		allErrs := validateNextUpstream(test.inputS, field.NewPath("next-upstreams"))
+		if len(allErrs) == 0 {
+			t.Errorf("validateNextUpstream(%q) didn't return errors %v for invalid input.", test.inputS, allErrs)
+		}
+	}
+}
+
+func TestValidateUpstreamRetries(t *testing.T) {
+	tests := []*v1.UpstreamRetries{
+		{},
+		{
+			Conditions:    []string{"error", "timeout", "http_502"},
+			Tries:         2,
+			PerTryTimeout: "5s",
+			Budget:        "30s",
+		},
+		{
+			Conditions: []string{"off"},
+		},
+	}
+
+	for _, retries := range tests {
+		upstream := v1.Upstream{Retries: retries}
+		allErrs := validateUpstreamRetries(upstream, field.NewPath("upstreams").Index(0))
+		if len(allErrs) > 0 {
+			t.Errorf("validateUpstreamRetries() returned errors %v for valid input %v", allErrs, retries)
+		}
+	}
+}
+
+func TestValidateUpstreamRetriesFails(t *testing.T) {
+	tests := []struct {
+		upstream v1.Upstream
+		msg      string
+	}{
+		{
+			upstream: v1.Upstream{
+				ProxyNextUpstream: "error",
+				Retries:           &v1.UpstreamRetries{},
+			},
+			msg: "retries with next-upstream",
+		},
+		{
+			upstream: v1.Upstream{
+				ProxyNextUpstreamTries: 2,
+				Retries:                &v1.UpstreamRetries{},
+			},
+			msg: "retries with next-upstream-tries",
+		},
+		{
+			upstream: v1.Upstream{
+				Retries: &v1.UpstreamRetries{
+					Conditions: []string{"error", "https_404"},
+				},
+			},
+			msg: "invalid condition",
+		},
+		{
+			upstream: v1.Upstream{
+				Retries: &v1.UpstreamRetries{
+					Conditions: []string{"error", "off"},
+				},
+			},
+			msg: "off with other conditions",
+		},
+		{
+			upstream: v1.Upstream{
+				Retries: &v1.UpstreamRetries{
+					Tries: -1,
+				},
+			},
+			msg: "invalid tries",
+		},
 		{
-			inputS: "error error",
+			upstream: v1.Upstream{
+				Retries: &v1.UpstreamRetries{
+					PerTryTimeout: "5 seconds",
+				},
+			},
+			msg: "invalid perTryTimeout",
 		},
 		{
-			inputS: "https_404",
+			upstream: v1.Upstream{
+				Retries: &v1.UpstreamRetries{
+					Budget: "1 minute",
+				},
+			},
+			msg: "invalid budget",
 		},
 	}
+
 	for _, test := range tests {
-		allErrs := validateNextUpstream(test.inputS, field.NewPath("next-upstreams"))
+		allErrs := validateUpstreamRetries(test.upstream, field.NewPath("upstreams").Index(0))
 		if len(allErrs) == 0 {
-			t.Errorf("validateNextUpstream(%q) didn't return errors %v for invalid input.", test.inputS, allErrs)
+			t.Errorf("validateUpstreamRetries() returned no errors for invalid input for the case of %s", test.msg)
 		}
 	}
 }
@@ -535,7 +1234,7 @@ func TestValidateVirtualServerRoutes(t *testing.T) {
 	}
 
 	for _, test := range tests {
-		allErrs := validateVirtualServerRoutes(test.routes, field.NewPath("routes"), test.upstreamNames)
+		allErrs := validateVirtualServerRoutes(test.routes, field.NewPath("routes"), test.upstreamNames, nil, nil)
 		if len(allErrs) > 0 {
 			t.Errorf("validateVirtualServerRoutes() returned errors %v for valid input for the case of %s", allErrs, test.msg)
 		}
@@ -583,7 +1282,7 @@ func TestValidateVirtualServerRoutesFails(t *testing.T) {
 	}
 
 	for _, test := range tests {
-		allErrs := validateVirtualServerRoutes(test.routes, field.NewPath("routes"), test.upstreamNames)
+		allErrs := validateVirtualServerRoutes(test.routes, field.NewPath("routes"), test.upstreamNames, nil, nil)
 		if len(allErrs) == 0 {
 			t.Errorf("validateVirtualServerRoutes() returned no errors for the case of %s", test.msg)
 		}
@@ -673,10 +1372,57 @@ func TestValidateRoute(t *testing.T) {
 			isRouteFieldForbidden: false,
 			msg:                   "valid route with route",
 		},
+		{
+			route: v1.Route{
+				Path: "/",
+				Action: &v1.Action{
+					Pass: "test",
+				},
+				LimitReq: &v1.RouteLimitReq{
+					Rate: "10r/s",
+				},
+			},
+			upstreamNames: map[string]sets.Empty{
+				"test": {},
+			},
+			isRouteFieldForbidden: false,
+			msg:                   "valid route with limitReq",
+		},
+		{
+			route: v1.Route{
+				Path: "/",
+				Action: &v1.Action{
+					Pass: "test",
+				},
+				LimitConn: &v1.LimitConn{
+					Max: 10,
+				},
+			},
+			upstreamNames: map[string]sets.Empty{
+				"test": {},
+			},
+			isRouteFieldForbidden: false,
+			msg:                   "valid route with limitConn",
+		},
+		{
+			route: v1.Route{
+				Path: "/upload",
+				Action: &v1.Action{
+					Pass: "test",
+				},
+				ClientMaxBodySize: "100m",
+				ClientBodyTimeout: "120s",
+			},
+			upstreamNames: map[string]sets.Empty{
+				"test": {},
+			},
+			isRouteFieldForbidden: false,
+			msg:                   "valid route with clientMaxBodySize and clientBodyTimeout",
+		},
 	}
 
 	for _, test := range tests {
-		allErrs := validateRoute(test.route, field.NewPath("route"), test.upstreamNames, test.isRouteFieldForbidden)
+		allErrs := validateRoute(test.route, field.NewPath("route"), test.upstreamNames, test.isRouteFieldForbidden, nil, nil)
 		if len(allErrs) > 0 {
 			t.Errorf("validateRoute() returned errors %v for valid input for the case of %s", allErrs, test.msg)
 		}
@@ -804,10 +1550,58 @@ func TestValidateRouteFails(t *testing.T) {
 			isRouteFieldForbidden: true,
 			msg:                   "route field exists but is forbidden",
 		},
+		{
+			route: v1.Route{
+				Path:  "/",
+				Route: "default/test",
+				LimitReq: &v1.RouteLimitReq{
+					Rate: "10r/s",
+				},
+			},
+			upstreamNames:         map[string]sets.Empty{},
+			isRouteFieldForbidden: false,
+			msg:                   "limitReq with route",
+		},
+		{
+			route: v1.Route{
+				Path:  "/",
+				Route: "default/test",
+				LimitConn: &v1.LimitConn{
+					Max: 10,
+				},
+			},
+			upstreamNames:         map[string]sets.Empty{},
+			isRouteFieldForbidden: false,
+			msg:                   "limitConn with route",
+		},
+		{
+			route: v1.Route{
+				Path:              "/",
+				Route:             "default/test",
+				ClientMaxBodySize: "100m",
+			},
+			upstreamNames:         map[string]sets.Empty{},
+			isRouteFieldForbidden: false,
+			msg:                   "clientMaxBodySize with route",
+		},
+		{
+			route: v1.Route{
+				Path: "/",
+				Action: &v1.Action{
+					Pass: "test",
+				},
+				ClientBodyTimeout: "2 minutes",
+			},
+			upstreamNames: map[string]sets.Empty{
+				"test": {},
+			},
+			isRouteFieldForbidden: false,
+			msg:                   "invalid clientBodyTimeout",
+		},
 	}
 
 	for _, test := range tests {
-		allErrs := validateRoute(test.route, field.NewPath("route"), test.upstreamNames, test.isRouteFieldForbidden)
+		allErrs := validateRoute(test.route, field.NewPath("route"), test.upstreamNames, test.isRouteFieldForbidden, nil, nil)
 		if len(allErrs) == 0 {
 			t.Errorf("validateRoute() returned no errors for invalid input for the case of %s", test.msg)
 		}
@@ -846,10 +1640,18 @@ func TestValidateAction(t *testing.T) {
 
 			msg: "redirect action with status code set",
 		},
+		{
+			action: &v1.Action{
+				Serve: &v1.ActionServe{
+					Directory: "maintenance",
+				},
+			},
+			msg: "base serve action",
+		},
 	}
 
 	for _, test := range tests {
-		allErrs := validateAction(test.action, field.NewPath("action"), upstreamNames)
+		allErrs := validateAction(test.action, field.NewPath("action"), upstreamNames, nil, nil)
 		if len(allErrs) > 0 {
 			t.Errorf("validateAction() returned errors %v for valid input for the case of %s", allErrs, test.msg)
 		}
@@ -892,12 +1694,288 @@ func TestValidateActionFails(t *testing.T) {
 			},
 			msg: "redirect action with invalid status code set",
 		},
+		{
+			action: &v1.Action{
+				Pass: "test",
+				Serve: &v1.ActionServe{
+					Directory: "maintenance",
+				},
+			},
+			msg: "pass and serve actions defined",
+		},
+	}
+
+	for _, test := range tests {
+		allErrs := validateAction(test.action, field.NewPath("action"), upstreamNames, nil, nil)
+		if len(allErrs) == 0 {
+			t.Errorf("validateAction() returned no errors for invalid input for the case of %s", test.msg)
+		}
+	}
+}
+
+func TestValidateActionLimitRate(t *testing.T) {
+	tests := []*v1.Action{
+		{
+			Pass: "test",
+		},
+		{
+			Pass:      "test",
+			LimitRate: "100k",
+		},
+		{
+			Pass:           "test",
+			LimitRate:      "1m",
+			LimitRateAfter: "500k",
+		},
+	}
+
+	for _, action := range tests {
+		allErrs := validateActionLimitRate(action, field.NewPath("action"))
+		if len(allErrs) > 0 {
+			t.Errorf("validateActionLimitRate() returned errors %v for valid input %v", allErrs, action)
+		}
+	}
+}
+
+func TestValidateActionLimitRateFails(t *testing.T) {
+	tests := []struct {
+		action *v1.Action
+		msg    string
+	}{
+		{
+			action: &v1.Action{
+				Pass:      "test",
+				LimitRate: "100kb",
+			},
+			msg: "invalid limitRate",
+		},
+		{
+			action: &v1.Action{
+				Pass:           "test",
+				LimitRateAfter: "1g",
+			},
+			msg: "invalid limitRateAfter",
+		},
+		{
+			action: &v1.Action{
+				Redirect: &v1.ActionRedirect{
+					URL: "http://www.nginx.com",
+				},
+				LimitRate: "100k",
+			},
+			msg: "limitRate with redirect",
+		},
+		{
+			action: &v1.Action{
+				Return: &v1.ActionReturn{
+					Body: "Hello World",
+				},
+				LimitRateAfter: "10m",
+			},
+			msg: "limitRateAfter with return",
+		},
+	}
+
+	for _, test := range tests {
+		allErrs := validateActionLimitRate(test.action, field.NewPath("action"))
+		if len(allErrs) == 0 {
+			t.Errorf("validateActionLimitRate() returned no errors for invalid input for the case of %s", test.msg)
+		}
+	}
+}
+
+func TestValidateActionCache(t *testing.T) {
+	tests := []*v1.ActionCache{
+		{
+			ZoneName: "tea",
+		},
+		{
+			ZoneName: "tea-cache",
+			ZoneSize: "20m",
+			Key:      "${scheme}${proxy_host}${request_uri}",
+			Valid: []v1.ActionCacheValid{
+				{Codes: []int{200, 302}, Time: "10m"},
+				{Codes: []int{404}, Time: "1m"},
+				{Time: "5m"},
+			},
+			Bypass:  []string{"${cookie_nocache}", "${arg_nocache}"},
+			NoCache: []string{"${http_pragma}${http_authorization}"},
+			MinUses: 2,
+			Purge: &v1.ActionCachePurge{
+				Allow: []string{"10.0.0.1", "192.168.0.0/16"},
+			},
+		},
+	}
+
+	for _, cache := range tests {
+		allErrs := validateActionCache(cache, field.NewPath("cache"), sets.String{})
+		if len(allErrs) > 0 {
+			t.Errorf("validateActionCache() returned errors %v for valid input %v", allErrs, cache)
+		}
+	}
+}
+
+func TestValidateActionCacheFails(t *testing.T) {
+	tests := []struct {
+		cache *v1.ActionCache
+		msg   string
+	}{
+		{
+			cache: &v1.ActionCache{},
+			msg:   "missing zoneName",
+		},
+		{
+			cache: &v1.ActionCache{ZoneName: "tea_cache"},
+			msg:   "invalid zoneName",
+		},
+		{
+			cache: &v1.ActionCache{ZoneName: "tea", ZoneSize: "1g"},
+			msg:   "invalid zoneSize",
+		},
+		{
+			cache: &v1.ActionCache{ZoneName: "tea", Key: "${request_body}"},
+			msg:   "key with an unsupported variable",
+		},
+		{
+			cache: &v1.ActionCache{ZoneName: "tea", Key: `"${uri}`},
+			msg:   "key with an unescaped double quote",
+		},
+		{
+			cache: &v1.ActionCache{ZoneName: "tea", Valid: []v1.ActionCacheValid{{Codes: []int{200}}}},
+			msg:   "valid without time",
+		},
+		{
+			cache: &v1.ActionCache{ZoneName: "tea", Valid: []v1.ActionCacheValid{{Codes: []int{99}, Time: "10m"}}},
+			msg:   "valid with an invalid code",
+		},
+		{
+			cache: &v1.ActionCache{ZoneName: "tea", Bypass: []string{""}},
+			msg:   "empty bypass",
+		},
+		{
+			cache: &v1.ActionCache{ZoneName: "tea", NoCache: []string{"$cookie_nocache"}},
+			msg:   "noCache with a variable without curly braces",
+		},
+		{
+			cache: &v1.ActionCache{ZoneName: "tea", MinUses: -1},
+			msg:   "negative minUses",
+		},
+		{
+			cache: &v1.ActionCache{ZoneName: "tea", Purge: &v1.ActionCachePurge{}},
+			msg:   "purge without allow",
+		},
+		{
+			cache: &v1.ActionCache{ZoneName: "tea", Purge: &v1.ActionCachePurge{Allow: []string{"10.0.0.0/33"}}},
+			msg:   "purge with an invalid CIDR",
+		},
+	}
+
+	for _, test := range tests {
+		allErrs := validateActionCache(test.cache, field.NewPath("cache"), sets.String{})
+		if len(allErrs) == 0 {
+			t.Errorf("validateActionCache() returned no errors for invalid input for the case of %s", test.msg)
+		}
+	}
+}
+
+func TestValidateActionCacheRequiresPass(t *testing.T) {
+	action := &v1.Action{
+		Return: &v1.ActionReturn{Body: "Hello World"},
+		Cache:  &v1.ActionCache{ZoneName: "tea"},
+	}
+
+	allErrs := validateAction(action, field.NewPath("action"), sets.String{}, sets.String{}, nil)
+	if len(allErrs) == 0 {
+		t.Errorf("validateAction() returned no errors for the cache of a return action")
+	}
+}
+
+func TestValidateActionServe(t *testing.T) {
+	tests := []*v1.ActionServe{
+		{
+			Directory: "maintenance",
+		},
+		{
+			Directory: "cafe/.well-known",
+		},
+		{
+			ConfigMap: "robots",
+			Key:       "robots.txt",
+		},
+		{
+			ConfigMap: "maintenance",
+			Key:       "index.html",
+			Type:      "text/html",
+		},
+	}
+
+	for _, serve := range tests {
+		allErrs := validateActionServe(serve, field.NewPath("serve"))
+		if len(allErrs) > 0 {
+			t.Errorf("validateActionServe() returned errors %v for valid input %v", allErrs, serve)
+		}
+	}
+}
+
+func TestValidateActionServeFails(t *testing.T) {
+	tests := []struct {
+		serve *v1.ActionServe
+		msg   string
+	}{
+		{
+			serve: &v1.ActionServe{},
+			msg:   "missing directory and configMap",
+		},
+		{
+			serve: &v1.ActionServe{Directory: "maintenance", ConfigMap: "robots", Key: "robots.txt"},
+			msg:   "both directory and configMap",
+		},
+		{
+			serve: &v1.ActionServe{Directory: "/etc"},
+			msg:   "absolute directory",
+		},
+		{
+			serve: &v1.ActionServe{Directory: "cafe/../../etc"},
+			msg:   "directory with '..'",
+		},
+		{
+			serve: &v1.ActionServe{Directory: "cafe/./tea"},
+			msg:   "directory with '.'",
+		},
+		{
+			serve: &v1.ActionServe{Directory: "cafe;"},
+			msg:   "directory with an invalid character",
+		},
+		{
+			serve: &v1.ActionServe{Directory: "maintenance", Key: "index.html"},
+			msg:   "directory with key",
+		},
+		{
+			serve: &v1.ActionServe{Directory: "maintenance", Type: "text/html"},
+			msg:   "directory with type",
+		},
+		{
+			serve: &v1.ActionServe{ConfigMap: "robots"},
+			msg:   "configMap without key",
+		},
+		{
+			serve: &v1.ActionServe{ConfigMap: "Robots_", Key: "robots.txt"},
+			msg:   "invalid configMap",
+		},
+		{
+			serve: &v1.ActionServe{ConfigMap: "robots", Key: "robots/txt"},
+			msg:   "invalid key",
+		},
+		{
+			serve: &v1.ActionServe{ConfigMap: "robots", Key: "robots.txt", Type: `text/plain"`},
+			msg:   "invalid type",
+		},
 	}
 
 	for _, test := range tests {
-		allErrs := validateAction(test.action, field.NewPath("action"), upstreamNames)
+		allErrs := validateActionServe(test.serve, field.NewPath("serve"))
 		if len(allErrs) == 0 {
-			t.Errorf("validateAction() returned no errors for invalid input for the case of %s", test.msg)
+			t.Errorf("validateActionServe() returned no errors for invalid input for the case of %s", test.msg)
 		}
 	}
 }
@@ -968,9 +2046,9 @@ func TestValidateRedirectURL(t *testing.T) {
 	}
 
 	for _, test := range tests {
-		allErrs := validateRedirectURL(test.redirectURL, field.NewPath("url"))
+		allErrs := validateRedirectURL(test.redirectURL, field.NewPath("url"), nil)
 		if len(allErrs) > 0 {
-			t.Errorf("validateRedirectURL(%s) returned errors %v for valid input for the case of %s", test.redirectURL, allErrs, test.msg)
+			t.Errorf("validateRedirectURL(%s, nil) returned errors %v for valid input for the case of %s", test.redirectURL, allErrs, test.msg)
 		}
 	}
 }
@@ -1032,9 +2110,9 @@ func TestValidateRedirectURLFails(t *testing.T) {
 	}
 
 	for _, test := range tests {
-		allErrs := validateRedirectURL(test.redirectURL, field.NewPath("action"))
+		allErrs := validateRedirectURL(test.redirectURL, field.NewPath("action"), nil)
 		if len(allErrs) == 0 {
-			t.Errorf("validateRedirectURL(%s) returned no errors for invalid input for the case of %s", test.redirectURL, test.msg)
+			t.Errorf("validateRedirectURL(%s, nil) returned no errors for invalid input for the case of %s", test.redirectURL, test.msg)
 		}
 	}
 }
@@ -1126,6 +2204,14 @@ func TestValidateRegexPath(t *testing.T) {
 			regexPath: `~ ^/f\"oo.*\\.jpg`,
 			msg:       "regexp with escaped double quotes",
 		},
+		{
+			regexPath: `~ ^/(?<version>v[0-9]+)/(?!internal)`,
+			msg:       "regexp with a PCRE named capture and a negative lookahead",
+		},
+		{
+			regexPath: `~ ^/(a|b)\1/[^/]++$`,
+			msg:       "regexp with a backreference and a possessive quantifier",
+		},
 	}
 
 	for _, test := range tests {
@@ -1157,6 +2243,22 @@ func TestValidateRegexPathFails(t *testing.T) {
 			regexPath: `~ /foo\`,
 			msg:       "ending in backslash",
 		},
+		{
+			regexPath: `~ /(?<=a+)foo`,
+			msg:       "variable length lookbehind",
+		},
+		{
+			regexPath: `~ /(foo)\2`,
+			msg:       "backreference to a non-existent group",
+		},
+		{
+			regexPath: `~ /(?<host>.*)`,
+			msg:       "capture of an NGINX variable",
+		},
+		{
+			regexPath: `~ `,
+			msg:       "empty regex after space",
+		},
 	}
 
 	for _, test := range tests {
@@ -1190,65 +2292,441 @@ func TestValidateRoutePath(t *testing.T) {
 	for _, path := range invalidPaths {
 		allErrs := validateRoutePath(path, field.NewPath("path"))
 		if len(allErrs) == 0 {
-			t.Errorf("validateRoutePath(%v) returned no errors for invalid input", path)
+			t.Errorf("validateRoutePath(%v) returned no errors for invalid input", path)
+		}
+	}
+}
+
+func TestValidatePath(t *testing.T) {
+	validPaths := []string{
+		"/",
+		"/path",
+		"/a-1/_A/",
+	}
+
+	for _, path := range validPaths {
+		allErrs := validatePath(path, field.NewPath("path"))
+		if len(allErrs) > 0 {
+			t.Errorf("validatePath(%q) returned errors %v for valid input", path, allErrs)
+		}
+	}
+
+	invalidPaths := []string{
+		"",
+		" /",
+		"/ ",
+		"/{",
+		"/}",
+		"/abc;",
+	}
+
+	for _, path := range invalidPaths {
+		allErrs := validatePath(path, field.NewPath("path"))
+		if len(allErrs) == 0 {
+			t.Errorf("validatePath(%q) returned no errors for invalid input", path)
+		}
+	}
+}
+
+func TestValidateSplits(t *testing.T) {
+	splits := []v1.Split{
+		{
+			Weight: 90,
+			Action: &v1.Action{
+				Pass: "test-1",
+			},
+		},
+		{
+			Weight: 10,
+			Action: &v1.Action{
+				Pass: "test-2",
+			},
+		},
+	}
+	upstreamNames := map[string]sets.Empty{
+		"test-1": {},
+		"test-2": {},
+	}
+
+	allErrs := validateSplits(splits, field.NewPath("splits"), upstreamNames, nil, nil)
+	if len(allErrs) > 0 {
+		t.Errorf("validateSplits() returned errors %v for valid input", allErrs)
+	}
+
+	// a dark launch keeps a split configured without traffic
+	splits[0].Weight = 100
+	splits[1].Weight = 0
+
+	allErrs = validateSplits(splits, field.NewPath("splits"), upstreamNames, nil, nil)
+	if len(allErrs) > 0 {
+		t.Errorf("validateSplits() returned errors %v for valid input with the weight 0", allErrs)
+	}
+}
+
+func TestValidateSplitsCache(t *testing.T) {
+	tests := []struct {
+		splitsCache *v1.SplitsCache
+		msg         string
+	}{
+		{
+			splitsCache: &v1.SplitsCache{
+				NoStore: true,
+			},
+			msg: "no-store",
+		},
+		{
+			splitsCache: &v1.SplitsCache{
+				Vary: []string{"Cookie", "User-Agent"},
+			},
+			msg: "vary headers",
+		},
+	}
+
+	for _, test := range tests {
+		allErrs := validateSplitsCache(test.splitsCache, true, field.NewPath("splitsCache"))
+		if len(allErrs) > 0 {
+			t.Errorf("validateSplitsCache() returned errors %v for valid input for the case of %s", allErrs, test.msg)
+		}
+	}
+}
+
+func TestValidateSplitsCacheFails(t *testing.T) {
+	tests := []struct {
+		splitsCache *v1.SplitsCache
+		hasSplits   bool
+		msg         string
+	}{
+		{
+			splitsCache: &v1.SplitsCache{
+				NoStore: true,
+			},
+			hasSplits: false,
+			msg:       "route without splits",
+		},
+		{
+			splitsCache: &v1.SplitsCache{
+				Vary: []string{"Invalid Header"},
+			},
+			hasSplits: true,
+			msg:       "invalid header name",
+		},
+		{
+			splitsCache: &v1.SplitsCache{
+				Vary: []string{"Cookie", "cookie"},
+			},
+			hasSplits: true,
+			msg:       "duplicated header names",
+		},
+	}
+
+	for _, test := range tests {
+		allErrs := validateSplitsCache(test.splitsCache, test.hasSplits, field.NewPath("splitsCache"))
+		if len(allErrs) == 0 {
+			t.Errorf("validateSplitsCache() returned no errors for invalid input for the case of %s", test.msg)
+		}
+	}
+}
+
+func TestValidateSplitsPersistence(t *testing.T) {
+	tests := []struct {
+		persistence *v1.SplitsPersistence
+		msg         string
+	}{
+		{
+			persistence: &v1.SplitsPersistence{
+				Cookie: "canary",
+			},
+			msg: "cookie without ttl",
+		},
+		{
+			persistence: &v1.SplitsPersistence{
+				Cookie: "canary",
+				TTL:    "1h",
+			},
+			msg: "cookie with ttl",
+		},
+	}
+
+	for _, test := range tests {
+		allErrs := validateSplitsPersistence(test.persistence, true, field.NewPath("splitsPersistence"))
+		if len(allErrs) > 0 {
+			t.Errorf("validateSplitsPersistence() returned errors %v for valid input for the case of %s", allErrs, test.msg)
+		}
+	}
+}
+
+func TestValidateSplitsPersistenceFails(t *testing.T) {
+	tests := []struct {
+		persistence *v1.SplitsPersistence
+		hasSplits   bool
+		msg         string
+	}{
+		{
+			persistence: &v1.SplitsPersistence{
+				Cookie: "canary",
+			},
+			hasSplits: false,
+			msg:       "route without splits",
+		},
+		{
+			persistence: &v1.SplitsPersistence{},
+			hasSplits:   true,
+			msg:         "missing cookie",
+		},
+		{
+			persistence: &v1.SplitsPersistence{
+				Cookie: "canary;",
+			},
+			hasSplits: true,
+			msg:       "invalid cookie name",
+		},
+		{
+			persistence: &v1.SplitsPersistence{
+				Cookie: "canary",
+				TTL:    "1 hour",
+			},
+			hasSplits: true,
+			msg:       "invalid ttl",
+		},
+	}
+
+	for _, test := range tests {
+		allErrs := validateSplitsPersistence(test.persistence, test.hasSplits, field.NewPath("splitsPersistence"))
+		if len(allErrs) == 0 {
+			t.Errorf("validateSplitsPersistence() returned no errors for invalid input for the case of %s", test.msg)
+		}
+	}
+}
+
+func TestValidateSplitsDynamicWeights(t *testing.T) {
+	splits := []v1.Split{
+		{
+			Weight: 90,
+			Action: &v1.Action{
+				Pass: "test-1",
+			},
+		},
+		{
+			Weight: 10,
+			Action: &v1.Action{
+				Pass: "test-2",
+			},
+		},
+	}
+	route := v1.Route{
+		Path:                 "/",
+		Splits:               splits,
+		SplitsDynamicWeights: true,
+	}
+
+	allErrs := validateSplitsDynamicWeights(route, field.NewPath("splitsDynamicWeights"))
+	if len(allErrs) > 0 {
+		t.Errorf("validateSplitsDynamicWeights() returned errors %v for valid input", allErrs)
+	}
+}
+
+func TestValidateSplitsDynamicWeightsFails(t *testing.T) {
+	split := v1.Split{
+		Weight: 50,
+		Action: &v1.Action{
+			Pass: "test-1",
+		},
+	}
+	tests := []struct {
+		route v1.Route
+		msg   string
+	}{
+		{
+			route: v1.Route{
+				Path: "/",
+				Action: &v1.Action{
+					Pass: "test-1",
+				},
+			},
+			msg: "route without splits",
+		},
+		{
+			route: v1.Route{
+				Path:   "/",
+				Splits: []v1.Split{split, split, split},
+			},
+			msg: "three splits",
+		},
+		{
+			route: v1.Route{
+				Path:   "/",
+				Splits: []v1.Split{split, split},
+				Matches: []v1.Match{
+					{
+						Conditions: []v1.Condition{
+							{
+								Header: "x-version",
+								Value:  "v2",
+							},
+						},
+						Splits: []v1.Split{split, split},
+					},
+				},
+			},
+			msg: "route with matches",
+		},
+	}
+
+	for _, test := range tests {
+		allErrs := validateSplitsDynamicWeights(test.route, field.NewPath("splitsDynamicWeights"))
+		if len(allErrs) == 0 {
+			t.Errorf("validateSplitsDynamicWeights() returned no errors for invalid input for the case of %s", test.msg)
+		}
+	}
+}
+
+func TestValidateIgnoreHeaders(t *testing.T) {
+	headers := []string{"X-Accel-Expires", "Cache-Control", "Set-Cookie"}
+
+	allErrs := validateIgnoreHeaders(headers, field.NewPath("ignoreHeaders"))
+	if len(allErrs) > 0 {
+		t.Errorf("validateIgnoreHeaders() returned errors %v for valid input", allErrs)
+	}
+}
+
+func TestValidateIgnoreHeadersFails(t *testing.T) {
+	tests := []struct {
+		headers []string
+		msg     string
+	}{
+		{
+			headers: []string{"X-Custom-Header"},
+			msg:     "unsupported header",
+		},
+		{
+			headers: []string{"Cache-Control", "Cache-Control"},
+			msg:     "duplicated header",
+		},
+	}
+
+	for _, test := range tests {
+		allErrs := validateIgnoreHeaders(test.headers, field.NewPath("ignoreHeaders"))
+		if len(allErrs) == 0 {
+			t.Errorf("validateIgnoreHeaders() returned no errors for invalid input for the case of %s", test.msg)
+		}
+	}
+}
+
+func TestValidateRouteLimitReq(t *testing.T) {
+	tests := []*v1.RouteLimitReq{
+		{
+			Rate: "10r/s",
+		},
+		{
+			Rate:  "30r/m",
+			Burst: createPointerFromInt(20),
+			Key:   "${binary_remote_addr}${uri}",
+		},
+	}
+
+	for _, limitReq := range tests {
+		allErrs := validateRouteLimitReq(limitReq, field.NewPath("limitReq"))
+		if len(allErrs) > 0 {
+			t.Errorf("validateRouteLimitReq() returned errors %v for valid input %v", allErrs, limitReq)
+		}
+	}
+}
+
+func TestValidateRouteLimitReqFails(t *testing.T) {
+	tests := []struct {
+		limitReq *v1.RouteLimitReq
+		msg      string
+	}{
+		{
+			limitReq: &v1.RouteLimitReq{},
+			msg:      "missing rate",
+		},
+		{
+			limitReq: &v1.RouteLimitReq{
+				Rate: "10r/h",
+			},
+			msg: "invalid rate",
+		},
+		{
+			limitReq: &v1.RouteLimitReq{
+				Rate:  "10r/s",
+				Burst: createPointerFromInt(-1),
+			},
+			msg: "invalid burst",
+		},
+		{
+			limitReq: &v1.RouteLimitReq{
+				Rate: "10r/s",
+				Key:  "${request_method}",
+			},
+			msg: "invalid key",
+		},
+	}
+
+	for _, test := range tests {
+		allErrs := validateRouteLimitReq(test.limitReq, field.NewPath("limitReq"))
+		if len(allErrs) == 0 {
+			t.Errorf("validateRouteLimitReq() returned no errors for invalid input for the case of %s", test.msg)
 		}
 	}
 }
 
-func TestValidatePath(t *testing.T) {
-	validPaths := []string{
-		"/",
-		"/path",
-		"/a-1/_A/",
+func TestValidateLimitConn(t *testing.T) {
+	tests := []*v1.LimitConn{
+		{
+			Max: 10,
+		},
+		{
+			Key:      "${binary_remote_addr}${uri}",
+			ZoneSize: "20m",
+			Max:      1,
+		},
 	}
 
-	for _, path := range validPaths {
-		allErrs := validatePath(path, field.NewPath("path"))
+	for _, limitConn := range tests {
+		allErrs := validateLimitConn(limitConn, field.NewPath("limitConn"))
 		if len(allErrs) > 0 {
-			t.Errorf("validatePath(%q) returned errors %v for valid input", path, allErrs)
-		}
-	}
-
-	invalidPaths := []string{
-		"",
-		" /",
-		"/ ",
-		"/{",
-		"/}",
-		"/abc;",
-	}
-
-	for _, path := range invalidPaths {
-		allErrs := validatePath(path, field.NewPath("path"))
-		if len(allErrs) == 0 {
-			t.Errorf("validatePath(%q) returned no errors for invalid input", path)
+			t.Errorf("validateLimitConn() returned errors %v for valid input %v", allErrs, limitConn)
 		}
 	}
 }
 
-func TestValidateSplits(t *testing.T) {
-	splits := []v1.Split{
+func TestValidateLimitConnFails(t *testing.T) {
+	tests := []struct {
+		limitConn *v1.LimitConn
+		msg       string
+	}{
 		{
-			Weight: 90,
-			Action: &v1.Action{
-				Pass: "test-1",
+			limitConn: &v1.LimitConn{},
+			msg:       "missing max",
+		},
+		{
+			limitConn: &v1.LimitConn{
+				Max: -1,
 			},
+			msg: "invalid max",
 		},
 		{
-			Weight: 10,
-			Action: &v1.Action{
-				Pass: "test-2",
+			limitConn: &v1.LimitConn{
+				Key: "${request_method}",
+				Max: 10,
 			},
+			msg: "invalid key",
+		},
+		{
+			limitConn: &v1.LimitConn{
+				ZoneSize: "10x",
+				Max:      10,
+			},
+			msg: "invalid zoneSize",
 		},
-	}
-	upstreamNames := map[string]sets.Empty{
-		"test-1": {},
-		"test-2": {},
 	}
 
-	allErrs := validateSplits(splits, field.NewPath("splits"), upstreamNames)
-	if len(allErrs) > 0 {
-		t.Errorf("validateSplits() returned errors %v for valid input", allErrs)
+	for _, test := range tests {
+		allErrs := validateLimitConn(test.limitConn, field.NewPath("limitConn"))
+		if len(allErrs) == 0 {
+			t.Errorf("validateLimitConn() returned no errors for invalid input for the case of %s", test.msg)
+		}
 	}
 }
 
@@ -1293,6 +2771,27 @@ func TestValidateSplitsFails(t *testing.T) {
 			},
 			msg: "invalid weight",
 		},
+		{
+			splits: []v1.Split{
+				{
+					Weight: 110,
+					Action: &v1.Action{
+						Pass: "test-1",
+					},
+				},
+				{
+					Weight: -10,
+					Action: &v1.Action{
+						Pass: "test-2",
+					},
+				},
+			},
+			upstreamNames: map[string]sets.Empty{
+				"test-1": {},
+				"test-2": {},
+			},
+			msg: "negative weight",
+		},
 		{
 			splits: []v1.Split{
 				{
@@ -1359,7 +2858,7 @@ func TestValidateSplitsFails(t *testing.T) {
 	}
 
 	for _, test := range tests {
-		allErrs := validateSplits(test.splits, field.NewPath("splits"), test.upstreamNames)
+		allErrs := validateSplits(test.splits, field.NewPath("splits"), test.upstreamNames, nil, nil)
 		if len(allErrs) == 0 {
 			t.Errorf("validateSplits() returned no errors for invalid input for the case of %s", test.msg)
 		}
@@ -1385,6 +2884,13 @@ func TestValidateCondition(t *testing.T) {
 			},
 			msg: "valid cookie",
 		},
+		{
+			condition: v1.Condition{
+				Variable: "$request_method",
+				Values:   []string{"GET", "HEAD", "~^P"},
+			},
+			msg: "valid values",
+		},
 		{
 			condition: v1.Condition{
 				Argument: "arg",
@@ -1399,16 +2905,110 @@ func TestValidateCondition(t *testing.T) {
 			},
 			msg: "valid variable",
 		},
+		{
+			condition: v1.Condition{
+				Variable: "$request_method",
+				Value:    "~^(GET|POST)$",
+			},
+			msg: "valid variable with a regex value",
+		},
+		{
+			condition: v1.Condition{
+				Variable: "$scheme",
+				Value:    "!HTTPS",
+			},
+			msg: "valid variable with a negated value",
+		},
+		{
+			condition: v1.Condition{
+				Variable: "$remote_addr",
+				Value:    "2001:db8::1",
+			},
+			msg: "valid remote address",
+		},
+		{
+			condition: v1.Condition{
+				Header: "user-agent",
+				Value:  `Mozilla/5.0 (X11; Linux x86_64) \"test\"`,
+			},
+			msg: "valid header value with spaces and escaped quotes",
+		},
+		{
+			condition: v1.Condition{
+				Argument: "q",
+				Value:    "a+b%20c",
+			},
+			msg: "valid argument value",
+		},
+		{
+			condition: v1.Condition{
+				ClientIP: []string{"10.0.0.0/8", "192.168.1.5", "2001:db8::/32"},
+			},
+			msg: "valid client addresses",
+		},
+		{
+			condition: v1.Condition{
+				Variable: "$jwt_claim_tenant",
+				Value:    "cafe",
+			},
+			msg: "valid JWT claim",
+		},
+		{
+			condition: v1.Condition{
+				Header:     "x-version",
+				ValueRegex: `^v1\.`,
+			},
+			msg: "valid regex",
+		},
+		{
+			condition: v1.Condition{
+				Header:     "user-agent",
+				ValueRegex: `!(?i)"bot"`,
+			},
+			msg: "valid negated regex with quotes",
+		},
 	}
 
 	for _, test := range tests {
-		allErrs := validateCondition(test.condition, field.NewPath("condition"))
+		allErrs := validateCondition(test.condition, field.NewPath("condition"), nil)
 		if len(allErrs) > 0 {
 			t.Errorf("validateCondition() returned errors %v for valid input for the case of %s", allErrs, test.msg)
 		}
 	}
 }
 
+func TestValidateJWTClaimConditions(t *testing.T) {
+	routes := []v1.Route{
+		{
+			Path: "/",
+			Matches: []v1.Match{
+				{
+					Conditions: []v1.Condition{
+						{
+							Header: "x-version",
+							Value:  "v2",
+						},
+						{
+							Variable: "$jwt_claim_tenant",
+							Value:    "cafe",
+						},
+					},
+				},
+			},
+		},
+	}
+
+	allErrsOSS := validateJWTClaimConditions(routes, field.NewPath("routes"), false)
+	if len(allErrsOSS) != 1 {
+		t.Errorf("validateJWTClaimConditions() returned errors %v but expected one error for the JWT claim in NGINX", allErrsOSS)
+	}
+
+	allErrsPlus := validateJWTClaimConditions(routes, field.NewPath("routes"), true)
+	if len(allErrsPlus) != 0 {
+		t.Errorf("validateJWTClaimConditions() returned errors %v for NGINX Plus", allErrsPlus)
+	}
+}
+
 func TestValidateConditionFails(t *testing.T) {
 	tests := []struct {
 		condition v1.Condition
@@ -1418,6 +3018,111 @@ func TestValidateConditionFails(t *testing.T) {
 			condition: v1.Condition{},
 			msg:       "empty condition",
 		},
+		{
+			condition: v1.Condition{
+				ClientIP: []string{},
+			},
+			msg: "empty client addresses",
+		},
+		{
+			condition: v1.Condition{
+				ClientIP: []string{"10.0.0.0/33", "10.0.0.256"},
+			},
+			msg: "invalid client addresses",
+		},
+		{
+			condition: v1.Condition{
+				ClientIP: []string{"10.0.0.0/8"},
+				Value:    "1",
+			},
+			msg: "client addresses with a value",
+		},
+		{
+			condition: v1.Condition{
+				Header:   "x-version",
+				ClientIP: []string{"10.0.0.0/8"},
+			},
+			msg: "client addresses with a header",
+		},
+		{
+			condition: v1.Condition{
+				Variable: "$jwt_claim_user-role",
+				Value:    "admin",
+			},
+			msg: "invalid JWT claim name",
+		},
+		{
+			condition: v1.Condition{
+				Variable: "$jwt_claim_",
+				Value:    "admin",
+			},
+			msg: "missing JWT claim name",
+		},
+		{
+			condition: v1.Condition{
+				Header:     "x-version",
+				ValueRegex: "v1(",
+			},
+			msg: "invalid regex",
+		},
+		{
+			condition: v1.Condition{
+				Header:     "x-version",
+				ValueRegex: "!",
+			},
+			msg: "negation without a regex",
+		},
+		{
+			condition: v1.Condition{
+				Header:     "x-version",
+				Value:      "v1",
+				ValueRegex: "^v1",
+			},
+			msg: "regex with a value",
+		},
+		{
+			condition: v1.Condition{
+				Header: "x-version",
+				Values: []string{},
+			},
+			msg: "empty values",
+		},
+		{
+			condition: v1.Condition{
+				Header: "x-version",
+				Values: []string{"v1", "!v2"},
+			},
+			msg: "negated value in values",
+		},
+		{
+			condition: v1.Condition{
+				Header: "x-version",
+				Values: []string{"v1", "V1"},
+			},
+			msg: "duplicate values",
+		},
+		{
+			condition: v1.Condition{
+				Variable: "$request_method",
+				Values:   []string{"GET", "get post"},
+			},
+			msg: "invalid HTTP method in values",
+		},
+		{
+			condition: v1.Condition{
+				Header: "x-version",
+				Value:  "v1",
+				Values: []string{"v2"},
+			},
+			msg: "values with a value",
+		},
+		{
+			condition: v1.Condition{
+				ClientIP: []string{"10.0.0.0/8"},
+				Values:   []string{"1"},
+			},
+			msg: "client addresses with values",
+		},
 		{
 			condition: v1.Condition{
 				Header:   "x-version",
@@ -1452,10 +3157,80 @@ func TestValidateConditionFails(t *testing.T) {
 			},
 			msg: "invalid variable",
 		},
+		{
+			condition: v1.Condition{
+				Variable: "$request_method",
+				Value:    "GET POST",
+			},
+			msg: "multiple HTTP methods",
+		},
+		{
+			condition: v1.Condition{
+				Variable: "$request_method",
+				Value:    "~^(GET|POST",
+			},
+			msg: "invalid regex value",
+		},
+		{
+			condition: v1.Condition{
+				Variable: "$scheme",
+				Value:    "ftp",
+			},
+			msg: "invalid scheme",
+		},
+		{
+			condition: v1.Condition{
+				Variable: "$https",
+				Value:    "true",
+			},
+			msg: "invalid https value",
+		},
+		{
+			condition: v1.Condition{
+				Variable: "$remote_port",
+				Value:    "70000",
+			},
+			msg: "invalid port",
+		},
+		{
+			condition: v1.Condition{
+				Variable: "$remote_addr",
+				Value:    "10.0.0.0/8",
+			},
+			msg: "CIDR instead of an IP address",
+		},
+		{
+			condition: v1.Condition{
+				Header: "x-version",
+				Value:  " v1",
+			},
+			msg: "header value with leading whitespace",
+		},
+		{
+			condition: v1.Condition{
+				Header: "x-version",
+				Value:  `v1\nv2`,
+			},
+			msg: "header value with a newline",
+		},
+		{
+			condition: v1.Condition{
+				Cookie: "user",
+				Value:  "john; admin",
+			},
+			msg: "cookie value with a semicolon",
+		},
+		{
+			condition: v1.Condition{
+				Argument: "q",
+				Value:    "a&b=c",
+			},
+			msg: "argument value with an ampersand",
+		},
 	}
 
 	for _, test := range tests {
-		allErrs := validateCondition(test.condition, field.NewPath("condition"))
+		allErrs := validateCondition(test.condition, field.NewPath("condition"), nil)
 		if len(allErrs) == 0 {
 			t.Errorf("validateCondition() returned no errors for invalid input for the case of %s", test.msg)
 		}
@@ -1522,9 +3297,9 @@ func TestValidateVariableName(t *testing.T) {
 	}
 
 	for _, name := range validNames {
-		allErrs := validateVariableName(name, field.NewPath("variable"))
+		allErrs := validateVariableName(name, field.NewPath("variable"), nil)
 		if len(allErrs) > 0 {
-			t.Errorf("validateVariableName(%q) returned errors %v for valid input", name, allErrs)
+			t.Errorf("validateVariableName(%q, nil) returned errors %v for valid input", name, allErrs)
 		}
 	}
 
@@ -1534,9 +3309,9 @@ func TestValidateVariableName(t *testing.T) {
 	}
 
 	for _, name := range invalidNames {
-		allErrs := validateVariableName(name, field.NewPath("variable"))
+		allErrs := validateVariableName(name, field.NewPath("variable"), nil)
 		if len(allErrs) == 0 {
-			t.Errorf("validateVariableName(%q) returned no errors for invalid input", name)
+			t.Errorf("validateVariableName(%q, nil) returned no errors for invalid input", name)
 		}
 	}
 }
@@ -1591,15 +3366,62 @@ func TestValidateMatch(t *testing.T) {
 				"test-1": {},
 				"test-2": {},
 			},
-			msg: "valid match with splits",
+			msg: "valid match with splits",
+		},
+	}
+
+	for _, test := range tests {
+		allErrs := validateMatch(test.match, field.NewPath("match"), test.upstreamNames, nil, nil)
+		if len(allErrs) > 0 {
+			t.Errorf("validateMatch() returned errors %v for valid input for the case of %s", allErrs, test.msg)
+		}
+	}
+}
+
+func TestValidateRouteMatchesLimitFails(t *testing.T) {
+	match := v1.Match{
+		Conditions: []v1.Condition{
+			{
+				Header: "x-version",
+				Value:  "v1",
+			},
+		},
+		Action: &v1.Action{
+			Pass: "test",
 		},
 	}
 
-	for _, test := range tests {
-		allErrs := validateMatch(test.match, field.NewPath("match"), test.upstreamNames)
-		if len(allErrs) > 0 {
-			t.Errorf("validateMatch() returned errors %v for valid input for the case of %s", allErrs, test.msg)
-		}
+	route := v1.Route{
+		Path: "/",
+		Action: &v1.Action{
+			Pass: "test",
+		},
+	}
+	for i := 0; i < maxMatches+1; i++ {
+		route.Matches = append(route.Matches, match)
+	}
+
+	upstreamNames := sets.NewString("test")
+
+	allErrs := validateRoute(route, field.NewPath("route"), upstreamNames, false, nil, nil)
+	if len(allErrs) == 0 {
+		t.Errorf("validateRoute() returned no errors for a route with %d matches", len(route.Matches))
+	}
+
+	route.Matches = route.Matches[:maxMatches]
+
+	allErrs = validateRoute(route, field.NewPath("route"), upstreamNames, false, nil, nil)
+	if len(allErrs) > 0 {
+		t.Errorf("validateRoute() returned errors %v for a route with %d matches", allErrs, len(route.Matches))
+	}
+
+	for i := 0; i < maxConditions; i++ {
+		match.Conditions = append(match.Conditions, match.Conditions[0])
+	}
+
+	allErrs = validateMatch(match, field.NewPath("match"), upstreamNames, nil, nil)
+	if len(allErrs) == 0 {
+		t.Errorf("validateMatch() returned no errors for a match with %d conditions", len(match.Conditions))
 	}
 }
 
@@ -1651,6 +3473,24 @@ func TestValidateMatchFails(t *testing.T) {
 			upstreamNames: map[string]sets.Empty{},
 			msg:           "invalid  action",
 		},
+		{
+			match: v1.Match{
+				Priority: 101,
+				Conditions: []v1.Condition{
+					{
+						Cookie: "version",
+						Value:  "v1",
+					},
+				},
+				Action: &v1.Action{
+					Pass: "test",
+				},
+			},
+			upstreamNames: map[string]sets.Empty{
+				"test": {},
+			},
+			msg: "invalid priority",
+		},
 		{
 			match: v1.Match{
 				Conditions: []v1.Condition{
@@ -1686,7 +3526,7 @@ func TestValidateMatchFails(t *testing.T) {
 	}
 
 	for _, test := range tests {
-		allErrs := validateMatch(test.match, field.NewPath("match"), test.upstreamNames)
+		allErrs := validateMatch(test.match, field.NewPath("match"), test.upstreamNames, nil, nil)
 		if len(allErrs) == 0 {
 			t.Errorf("validateMatch() returned no errors for invalid input for the case of %s", test.msg)
 		}
@@ -1814,6 +3654,118 @@ func TestValidateVirtualServerRouteForVirtualServer(t *testing.T) {
 	}
 }
 
+func TestValidateVirtualServerRouteReferences(t *testing.T) {
+	createVirtualServerRoute := func(namespace string, name string, host string, path string) *v1.VirtualServerRoute {
+		return &v1.VirtualServerRoute{
+			ObjectMeta: meta_v1.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+			},
+			Spec: v1.VirtualServerRouteSpec{
+				Host: host,
+				Upstreams: []v1.Upstream{
+					{
+						Name:    "backend",
+						Service: "backend-svc",
+						Port:    80,
+					},
+				},
+				Subroutes: []v1.Route{
+					{
+						Path: path,
+						Action: &v1.Action{
+							Pass: "backend",
+						},
+					},
+				},
+			},
+		}
+	}
+
+	virtualServerRoutes := map[string]*v1.VirtualServerRoute{
+		"default/coffee": createVirtualServerRoute("default", "coffee", "cafe.example.com", "/coffee"),
+		"tea/tea":        createVirtualServerRoute("tea", "tea", "cafe.example.com", "/tea"),
+		"default/juice":  createVirtualServerRoute("default", "juice", "juice.example.com", "/juice"),
+		"default/latte":  createVirtualServerRoute("default", "latte", "cafe.example.com", "/tea/latte"),
+	}
+	getVirtualServerRoute := func(key string) (*v1.VirtualServerRoute, bool) {
+		vsr, exists := virtualServerRoutes[key]
+		return vsr, exists
+	}
+
+	virtualServer := v1.VirtualServer{
+		ObjectMeta: meta_v1.ObjectMeta{
+			Name:      "cafe",
+			Namespace: "default",
+		},
+		Spec: v1.VirtualServerSpec{
+			Host: "cafe.example.com",
+			Routes: []v1.Route{
+				{
+					Path:  "/coffee",
+					Route: "coffee",
+				},
+				{
+					Path:  "/tea",
+					Route: "tea/tea",
+				},
+				{
+					Path: "/",
+					Action: &v1.Action{
+						Pass: "backend",
+					},
+				},
+				{
+					Path:  "/juice",
+					Route: "juice",
+				},
+				{
+					Path:  "/latte",
+					Route: "latte",
+				},
+				{
+					Path:  "/milk",
+					Route: "milk",
+				},
+			},
+		},
+	}
+
+	expected := []struct {
+		errType field.ErrorType
+		field   string
+		value   string
+	}{
+		{
+			errType: field.ErrorTypeInvalid,
+			field:   "spec.routes[3].route",
+			value:   "default/juice",
+		},
+		{
+			errType: field.ErrorTypeInvalid,
+			field:   "spec.routes[4].route",
+			value:   "default/latte",
+		},
+		{
+			errType: field.ErrorTypeNotFound,
+			field:   "spec.routes[5].route",
+			value:   "default/milk",
+		},
+	}
+
+	allErrs := ValidateVirtualServerRouteReferences(&virtualServer, getVirtualServerRoute, false, false, nil, StrictValidation)
+
+	if len(allErrs) != len(expected) {
+		t.Fatalf("ValidateVirtualServerRouteReferences() returned %d errors but expected %d: %v", len(allErrs), len(expected), allErrs)
+	}
+	for i, err := range allErrs {
+		if err.Type != expected[i].errType || err.Field != expected[i].field || err.BadValue != expected[i].value {
+			t.Errorf("ValidateVirtualServerRouteReferences() returned %v but expected %v error for %v with value %v",
+				err, expected[i].errType, expected[i].field, expected[i].value)
+		}
+	}
+}
+
 func TestValidateVirtualServerRouteHost(t *testing.T) {
 	virtualServerHost := "example.com"
 
@@ -1863,7 +3815,7 @@ func TestValidateVirtualServerRouteSubroutes(t *testing.T) {
 	}
 
 	for _, test := range tests {
-		allErrs := validateVirtualServerRouteSubroutes(test.routes, field.NewPath("subroutes"), test.upstreamNames, test.pathPrefix)
+		allErrs := validateVirtualServerRouteSubroutes(test.routes, field.NewPath("subroutes"), test.upstreamNames, test.pathPrefix, nil, nil)
 		if len(allErrs) > 0 {
 			t.Errorf("validateVirtualServerRouteSubroutes() returned errors %v for valid input for the case of %s", allErrs, test.msg)
 		}
@@ -1928,13 +3880,104 @@ func TestValidateVirtualServerRouteSubroutesFails(t *testing.T) {
 	}
 
 	for _, test := range tests {
-		allErrs := validateVirtualServerRouteSubroutes(test.routes, field.NewPath("subroutes"), test.upstreamNames, test.pathPrefix)
+		allErrs := validateVirtualServerRouteSubroutes(test.routes, field.NewPath("subroutes"), test.upstreamNames, test.pathPrefix, nil, nil)
 		if len(allErrs) == 0 {
 			t.Errorf("validateVirtualServerRouteSubroutes() returned no errors for the case of %s", test.msg)
 		}
 	}
 }
 
+func TestValidateUpstreamUseClusterIP(t *testing.T) {
+	upstream := v1.Upstream{UseClusterIP: true, TopologyPolicy: "none"}
+	allErrs := validateUpstreamUseClusterIP(upstream, field.NewPath("upstreams").Index(0))
+	if len(allErrs) > 0 {
+		t.Errorf("validateUpstreamUseClusterIP() returned errors %v for valid input %+v", allErrs, upstream)
+	}
+}
+
+func TestValidateUpstreamUseClusterIPFails(t *testing.T) {
+	tests := []struct {
+		upstream v1.Upstream
+		msg      string
+	}{
+		{
+			upstream: v1.Upstream{UseClusterIP: true, Subselector: map[string]string{"version": "v1"}},
+			msg:      "useClusterIP with subselector",
+		},
+		{
+			upstream: v1.Upstream{UseClusterIP: true, Resolve: true},
+			msg:      "useClusterIP with resolve",
+		},
+		{
+			upstream: v1.Upstream{UseClusterIP: true, TopologyPolicy: "prefer-zone"},
+			msg:      "useClusterIP with prefer-zone",
+		},
+		{
+			upstream: v1.Upstream{UseClusterIP: true, IncludeNotReadyEndpoints: true},
+			msg:      "useClusterIP with includeNotReadyEndpoints",
+		},
+	}
+
+	for _, test := range tests {
+		allErrs := validateUpstreamUseClusterIP(test.upstream, field.NewPath("upstreams").Index(0))
+		if len(allErrs) == 0 {
+			t.Errorf("validateUpstreamUseClusterIP() returned no errors for invalid input for the case of %s", test.msg)
+		}
+	}
+}
+
+func TestValidateUpstreamTopologyPolicy(t *testing.T) {
+	tests := []v1.Upstream{
+		{},
+		{TopologyPolicy: "none"},
+		{TopologyPolicy: "prefer-zone"},
+		{TopologyPolicy: "prefer-zone", LBMethod: "least_conn"},
+		{TopologyPolicy: "none", LBMethod: "ip_hash"},
+	}
+
+	for _, upstream := range tests {
+		allErrs := validateUpstreamTopologyPolicy(upstream, field.NewPath("upstreams").Index(0))
+		if len(allErrs) > 0 {
+			t.Errorf("validateUpstreamTopologyPolicy() returned errors %v for valid input %+v", allErrs, upstream)
+		}
+	}
+}
+
+func TestValidateUpstreamTopologyPolicyFails(t *testing.T) {
+	tests := []struct {
+		upstream v1.Upstream
+		msg      string
+	}{
+		{
+			upstream: v1.Upstream{TopologyPolicy: "prefer-node"},
+			msg:      "invalid policy",
+		},
+		{
+			upstream: v1.Upstream{TopologyPolicy: "prefer-zone", LBMethod: "ip_hash"},
+			msg:      "prefer-zone with ip_hash",
+		},
+		{
+			upstream: v1.Upstream{TopologyPolicy: "prefer-zone", LBMethod: "hash $request_uri consistent"},
+			msg:      "prefer-zone with hash",
+		},
+		{
+			upstream: v1.Upstream{TopologyPolicy: "prefer-zone", LBMethod: "random two least_conn"},
+			msg:      "prefer-zone with random",
+		},
+		{
+			upstream: v1.Upstream{TopologyPolicy: "prefer-zone", Resolve: true},
+			msg:      "prefer-zone with resolve",
+		},
+	}
+
+	for _, test := range tests {
+		allErrs := validateUpstreamTopologyPolicy(test.upstream, field.NewPath("upstreams").Index(0))
+		if len(allErrs) == 0 {
+			t.Errorf("validateUpstreamTopologyPolicy() returned no errors for invalid input for the case of %s", test.msg)
+		}
+	}
+}
+
 func TestValidateUpstreamLBMethod(t *testing.T) {
 	tests := []struct {
 		method string
@@ -2157,8 +4200,12 @@ func TestValidateUpstreamHealthCheck(t *testing.T) {
 		Fails:    3,
 		Passes:   2,
 		Port:     8080,
-		TLS: &v1.UpstreamTLS{
-			Enable: true,
+		TLS: &v1.HealthCheckTLS{
+			Enable:            true,
+			ServerName:        true,
+			SSLName:           "my.service",
+			VerifyServer:      true,
+			TrustedCertSecret: "my-ca",
 		},
 		ConnectTimeout: "1s",
 		ReadTimeout:    "1s",
@@ -2169,7 +4216,24 @@ func TestValidateUpstreamHealthCheck(t *testing.T) {
 				Value: "my.service",
 			},
 		},
-		StatusMatch: "! 500",
+		StatusMatch:   "! 500",
+		Mandatory:     true,
+		Persistent:    true,
+		KeepaliveTime: "60s",
+		BodyMatch:     `"status":\s*"ok"`,
+		HeaderMatches: []v1.HeaderMatch{
+			{
+				Name: "X-Ready",
+			},
+			{
+				Name:  "Content-Type",
+				Value: "application/json",
+			},
+			{
+				Name:       "X-Version",
+				ValueRegex: "!^v1",
+			},
+		},
 	}
 
 	allErrs := validateUpstreamHealthCheck(hc, field.NewPath("healthCheck"))
@@ -2177,6 +4241,17 @@ func TestValidateUpstreamHealthCheck(t *testing.T) {
 	if len(allErrs) != 0 {
 		t.Errorf("validateUpstreamHealthCheck() returned errors for valid input %v", hc)
 	}
+
+	hc = &v1.HealthCheck{
+		Enable:   true,
+		PortName: "admin",
+	}
+
+	allErrs = validateUpstreamHealthCheck(hc, field.NewPath("healthCheck"))
+
+	if len(allErrs) != 0 {
+		t.Errorf("validateUpstreamHealthCheck() returned errors for valid input %v", hc)
+	}
 }
 
 func TestValidateUpstreamHealthCheckFails(t *testing.T) {
@@ -2195,6 +4270,90 @@ func TestValidateUpstreamHealthCheckFails(t *testing.T) {
 				Path:   "/healthz//;",
 			},
 		},
+		{
+			hc: &v1.HealthCheck{
+				Enable:     true,
+				Persistent: true,
+			},
+		},
+		{
+			hc: &v1.HealthCheck{
+				Enable:          true,
+				KeepaliveTime:   "60s",
+				CloseConnection: true,
+			},
+		},
+		{
+			hc: &v1.HealthCheck{
+				Enable:    true,
+				BodyMatch: "ok(",
+			},
+		},
+		{
+			hc: &v1.HealthCheck{
+				Enable:   true,
+				PortName: "admin_port",
+			},
+		},
+		{
+			hc: &v1.HealthCheck{
+				Enable:   true,
+				Port:     8081,
+				PortName: "admin",
+			},
+		},
+		{
+			hc: &v1.HealthCheck{
+				Enable: true,
+				TLS: &v1.HealthCheckTLS{
+					Enable:       true,
+					VerifyServer: true,
+				},
+			},
+		},
+		{
+			hc: &v1.HealthCheck{
+				Enable: true,
+				TLS: &v1.HealthCheckTLS{
+					Enable:  false,
+					SSLName: "my.service",
+				},
+			},
+		},
+		{
+			hc: &v1.HealthCheck{
+				Enable: true,
+				HeaderMatches: []v1.HeaderMatch{
+					{
+						Name: "X_Ready",
+					},
+				},
+			},
+		},
+		{
+			hc: &v1.HealthCheck{
+				Enable: true,
+				HeaderMatches: []v1.HeaderMatch{
+					{
+						Name:       "X-Version",
+						Value:      "v2",
+						ValueRegex: "^v2",
+					},
+				},
+			},
+		},
+		{
+			hc: &v1.HealthCheck{
+				Enable:          true,
+				CloseConnection: true,
+				Headers: []v1.Header{
+					{
+						Name:  "connection",
+						Value: "keep-alive",
+					},
+				},
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -2415,16 +4574,21 @@ func TestRejectPlusResourcesInOSS(t *testing.T) {
 				Queue: &v1.UpstreamQueue{},
 			},
 		},
+		{
+			upstream: &v1.Upstream{
+				Resolve: true,
+			},
+		},
 	}
 
 	for _, test := range tests {
-		allErrsOSS := rejectPlusResourcesInOSS(*test.upstream, field.NewPath("upstreams"), false)
+		allErrsOSS := rejectPlusResourcesInOSS(*test.upstream, field.NewPath("upstreams"), false, false)
 
 		if len(allErrsOSS) == 0 {
 			t.Errorf("rejectPlusResourcesInOSS() returned no errors for upstream: %v", test.upstream)
 		}
 
-		allErrsPlus := rejectPlusResourcesInOSS(*test.upstream, field.NewPath("upstreams"), true)
+		allErrsPlus := rejectPlusResourcesInOSS(*test.upstream, field.NewPath("upstreams"), true, false)
 
 		if len(allErrsPlus) != 0 {
 			t.Errorf("rejectPlusResourcesInOSS() returned no errors for upstream: %v", test.upstream)
@@ -2432,6 +4596,82 @@ func TestRejectPlusResourcesInOSS(t *testing.T) {
 	}
 }
 
+func TestRejectPlusResourcesInOSSWithEmulatedQueue(t *testing.T) {
+	upstream := v1.Upstream{
+		Queue: &v1.UpstreamQueue{Size: 10},
+	}
+
+	allErrs := rejectPlusResourcesInOSS(upstream, field.NewPath("upstreams"), false, true)
+	if len(allErrs) != 0 {
+		t.Errorf("rejectPlusResourcesInOSS() returned errors %v for the queue emulated in OSS", allErrs)
+	}
+
+	upstream.SlowStart = "10s"
+	allErrs = rejectPlusResourcesInOSS(upstream, field.NewPath("upstreams"), false, true)
+	if len(allErrs) != 1 {
+		t.Errorf("rejectPlusResourcesInOSS() returned %d errors but expected 1 for the slow start with the queue emulated in OSS", len(allErrs))
+	}
+}
+
+func TestRejectPlusResourcesInOSSForRoutes(t *testing.T) {
+	routes := []v1.Route{
+		{
+			Path:                 "/",
+			SplitsDynamicWeights: true,
+		},
+	}
+
+	allErrsOSS := rejectPlusResourcesInOSSForRoutes(routes, field.NewPath("routes"), false)
+	if len(allErrsOSS) == 0 {
+		t.Errorf("rejectPlusResourcesInOSSForRoutes() returned no errors for routes: %v", routes)
+	}
+
+	allErrsPlus := rejectPlusResourcesInOSSForRoutes(routes, field.NewPath("routes"), true)
+	if len(allErrsPlus) != 0 {
+		t.Errorf("rejectPlusResourcesInOSSForRoutes() returned errors %v for routes: %v", allErrsPlus, routes)
+	}
+}
+
+func TestRejectPlusResourcesInOSSForRoutesWithCachePurge(t *testing.T) {
+	cache := &v1.ActionCache{
+		ZoneName: "tea",
+		Purge:    &v1.ActionCachePurge{Allow: []string{"10.0.0.1"}},
+	}
+	routes := []v1.Route{
+		{
+			Path:   "/tea",
+			Action: &v1.Action{Pass: "tea", Cache: cache},
+		},
+		{
+			Path: "/coffee",
+			Splits: []v1.Split{
+				{Weight: 90, Action: &v1.Action{Pass: "coffee-v1"}},
+				{Weight: 10, Action: &v1.Action{Pass: "coffee-v2", Cache: cache}},
+			},
+		},
+		{
+			Path: "/juice",
+			Matches: []v1.Match{
+				{
+					Conditions: []v1.Condition{{Header: "x-version", Value: "v2"}},
+					Action:     &v1.Action{Pass: "juice-v2", Cache: cache},
+				},
+			},
+			Action: &v1.Action{Pass: "juice-v1"},
+		},
+	}
+
+	allErrsOSS := rejectPlusResourcesInOSSForRoutes(routes, field.NewPath("routes"), false)
+	if len(allErrsOSS) != 3 {
+		t.Errorf("rejectPlusResourcesInOSSForRoutes() returned %d errors but expected 3 for routes: %v", len(allErrsOSS), routes)
+	}
+
+	allErrsPlus := rejectPlusResourcesInOSSForRoutes(routes, field.NewPath("routes"), true)
+	if len(allErrsPlus) != 0 {
+		t.Errorf("rejectPlusResourcesInOSSForRoutes() returned errors %v for routes: %v", allErrsPlus, routes)
+	}
+}
+
 func TestValidateQueue(t *testing.T) {
 	tests := []struct {
 		upstreamQueue *v1.UpstreamQueue
@@ -2689,9 +4929,9 @@ func TestValidateActionReturnBody(t *testing.T) {
 	}
 
 	for _, test := range tests {
-		allErrs := validateActionReturnBody(test.body, field.NewPath("body"))
+		allErrs := validateActionReturnBody(test.body, field.NewPath("body"), nil, nil)
 		if len(allErrs) != 0 {
-			t.Errorf("validateActionReturnBody(%v) returned errors %v for valid input for the case of: %v", test.body, allErrs, test.msg)
+			t.Errorf("validateActionReturnBody(%v, nil, nil) returned errors %v for valid input for the case of: %v", test.body, allErrs, test.msg)
 		}
 	}
 }
@@ -2716,9 +4956,9 @@ func TestValidateActionReturnBodyFails(t *testing.T) {
 	}
 
 	for _, test := range tests {
-		allErrs := validateActionReturnBody(test.body, field.NewPath("body"))
+		allErrs := validateActionReturnBody(test.body, field.NewPath("body"), nil, nil)
 		if len(allErrs) == 0 {
-			t.Errorf("validateActionReturnBody(%v) returned no errors for invalid input for the case of: %v", test.body, test.msg)
+			t.Errorf("validateActionReturnBody(%v, nil, nil) returned no errors for invalid input for the case of: %v", test.body, test.msg)
 		}
 	}
 }
@@ -2790,9 +5030,9 @@ func TestValidateActionReturn(t *testing.T) {
 	}
 
 	for _, test := range tests {
-		allErrs := validateActionReturn(test, field.NewPath("return"))
+		allErrs := validateActionReturn(test, field.NewPath("return"), nil, nil)
 		if len(allErrs) != 0 {
-			t.Errorf("validateActionReturn(%v) returned errors for valid input", test)
+			t.Errorf("validateActionReturn(%v, nil, nil) returned errors for valid input", test)
 		}
 	}
 }
@@ -2812,9 +5052,9 @@ func TestValidateActionReturnFails(t *testing.T) {
 	}
 
 	for _, test := range tests {
-		allErrs := validateActionReturn(test, field.NewPath("return"))
+		allErrs := validateActionReturn(test, field.NewPath("return"), nil, nil)
 		if len(allErrs) == 0 {
-			t.Errorf("validateActionReturn(%v) returned no errors for invalid input", test)
+			t.Errorf("validateActionReturn(%v, nil, nil) returned no errors for invalid input", test)
 		}
 	}
 }
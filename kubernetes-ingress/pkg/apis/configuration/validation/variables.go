@@ -0,0 +1,34 @@
+package validation
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const allowedVariableNameFmt = `[a-zA-Z_][a-zA-Z0-9_]*`
+const allowedVariableNameErrMsg = "must start with a letter or '_' and consist of alphanumeric characters or '_'"
+
+var allowedVariableNameRegexp = regexp.MustCompile("^" + allowedVariableNameFmt + "$")
+
+// ParseAllowedVariables parses the NGINX variables allowed, in addition to the built-in ones, in the conditions of matches
+// and in the bodies of return actions, which the validation functions accept as allowedVariables.
+// A variable can be specified with or without the leading `$`, for example, `$ssl_client_s_dn` or `ssl_client_s_dn`.
+// The returned names are without the leading `$`.
+func ParseAllowedVariables(names []string) (sets.String, error) {
+	allowedVariables := sets.NewString()
+
+	for _, name := range names {
+		name = strings.TrimPrefix(name, "$")
+		if !allowedVariableNameRegexp.MatchString(name) {
+			msg := validation.RegexError(allowedVariableNameErrMsg, allowedVariableNameFmt, "ssl_client_s_dn", "http_x_user_id")
+			return nil, fmt.Errorf("invalid variable %q: %v", name, msg)
+		}
+		allowedVariables.Insert(name)
+	}
+
+	return allowedVariables, nil
+}
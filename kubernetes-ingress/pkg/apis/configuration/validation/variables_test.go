@@ -0,0 +1,51 @@
+package validation
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func TestParseAllowedVariables(t *testing.T) {
+	if allErrs := validateVariableName("$ssl_client_s_dn", field.NewPath("variable"), nil); len(allErrs) == 0 {
+		t.Fatalf("validateVariableName() returned no errors for a variable that is not allowed")
+	}
+
+	allowedVariables, err := ParseAllowedVariables([]string{"$ssl_client_s_dn", "http_x_user_id"})
+	if err != nil {
+		t.Fatalf("ParseAllowedVariables() returned unexpected error: %v", err)
+	}
+
+	for _, name := range []string{"$ssl_client_s_dn", "$http_x_user_id"} {
+		if allErrs := validateVariableName(name, field.NewPath("variable"), allowedVariables); len(allErrs) != 0 {
+			t.Errorf("validateVariableName(%q) returned errors for an allowed variable: %v", name, allErrs)
+		}
+	}
+
+	body := "${ssl_client_s_dn} ${http_x_user_id}"
+	if allErrs := validateActionReturnBody(body, field.NewPath("body"), nil, allowedVariables); len(allErrs) != 0 {
+		t.Errorf("validateActionReturnBody(%q) returned errors for allowed variables: %v", body, allErrs)
+	}
+
+	// the allowed variables don't change the validation without them
+	if allErrs := validateActionReturnBody(body, field.NewPath("body"), nil, nil); len(allErrs) == 0 {
+		t.Errorf("validateActionReturnBody(%q) returned no errors for variables that are not allowed", body)
+	}
+}
+
+func TestParseAllowedVariablesFails(t *testing.T) {
+	invalidNames := [][]string{
+		{""},
+		{"$"},
+		{"1abc"},
+		{"ssl_client_s_dn", "http-x-user"},
+		{"host;"},
+	}
+
+	for _, names := range invalidNames {
+		_, err := ParseAllowedVariables(names)
+		if err == nil {
+			t.Errorf("ParseAllowedVariables(%v) returned no error", names)
+		}
+	}
+}
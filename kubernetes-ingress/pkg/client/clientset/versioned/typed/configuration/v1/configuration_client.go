@@ -10,6 +10,7 @@ import (
 
 type K8sV1Interface interface {
 	RESTClient() rest.Interface
+	PoliciesGetter
 	VirtualServersGetter
 	VirtualServerRoutesGetter
 }
@@ -19,6 +20,10 @@ type K8sV1Client struct {
 	restClient rest.Interface
 }
 
+func (c *K8sV1Client) Policies(namespace string) PolicyInterface {
+	return newPolicies(c, namespace)
+}
+
 func (c *K8sV1Client) VirtualServers(namespace string) VirtualServerInterface {
 	return newVirtualServers(c, namespace)
 }
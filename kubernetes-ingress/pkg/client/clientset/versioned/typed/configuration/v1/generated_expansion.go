@@ -2,6 +2,8 @@
 
 package v1
 
+type PolicyExpansion interface{}
+
 type VirtualServerExpansion interface{}
 
 type VirtualServerRouteExpansion interface{}
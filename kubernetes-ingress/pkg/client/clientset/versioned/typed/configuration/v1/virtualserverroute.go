@@ -23,6 +23,7 @@ type VirtualServerRoutesGetter interface {
 type VirtualServerRouteInterface interface {
 	Create(*v1.VirtualServerRoute) (*v1.VirtualServerRoute, error)
 	Update(*v1.VirtualServerRoute) (*v1.VirtualServerRoute, error)
+	UpdateStatus(*v1.VirtualServerRoute) (*v1.VirtualServerRoute, error)
 	Delete(name string, options *metav1.DeleteOptions) error
 	DeleteCollection(options *metav1.DeleteOptions, listOptions metav1.ListOptions) error
 	Get(name string, options metav1.GetOptions) (*v1.VirtualServerRoute, error)
@@ -116,6 +117,22 @@ func (c *virtualServerRoutes) Update(virtualServerRoute *v1.VirtualServerRoute)
 	return
 }
 
+// UpdateStatus was generated because the type contains a Status member.
+// Add a +genclient:noStatus comment above the type to avoid generating UpdateStatus().
+
+func (c *virtualServerRoutes) UpdateStatus(virtualServerRoute *v1.VirtualServerRoute) (result *v1.VirtualServerRoute, err error) {
+	result = &v1.VirtualServerRoute{}
+	err = c.client.Put().
+		Namespace(c.ns).
+		Resource("virtualserverroutes").
+		Name(virtualServerRoute.Name).
+		SubResource("status").
+		Body(virtualServerRoute).
+		Do().
+		Into(result)
+	return
+}
+
 // Delete takes name of the virtualServerRoute and deletes it. Returns an error if one occurs.
 func (c *virtualServerRoutes) Delete(name string, options *metav1.DeleteOptions) error {
 	return c.client.Delete().
@@ -0,0 +1,212 @@
+// Package configgen generates the NGINX configs of VirtualServer resources outside of the Ingress Controller.
+// It allows tools, such as validators in CI pipelines or previewers of GitOps changes, to render the config
+// that the Ingress Controller would generate for a VirtualServer without a cluster.
+//
+// The configs are generated from the resources alone: the Secrets referenced by the resources are considered valid,
+// and the upstreams get the endpoints from Resources. The upstreams without endpoints reference a server
+// that responds with the 502 status code, the same as in the Ingress Controller.
+package configgen
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/nginxinc/kubernetes-ingress/internal/configs"
+	"github.com/nginxinc/kubernetes-ingress/internal/configs/version2"
+	conf_v1 "github.com/nginxinc/kubernetes-ingress/pkg/apis/configuration/v1"
+	"github.com/nginxinc/kubernetes-ingress/pkg/apis/configuration/validation"
+	api_v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// secretsDir is the directory of the Secrets in the generated configs, the same as in the Ingress Controller.
+const secretsDir = "/etc/nginx/secrets"
+
+// Options configures a Generator.
+type Options struct {
+	// IsPlus generates the configs for NGINX Plus instead of NGINX.
+	IsPlus bool
+	// TemplatePath is the path to the VirtualServer template, for example, nginx-plus.virtualserver.tmpl
+	// from the internal/configs/version2 directory of the repository or from the image of the Ingress Controller.
+	TemplatePath string
+	// ConfigMap is the ConfigMap with the global configuration of the Ingress Controller.
+	// Without the ConfigMap, the defaults are used.
+	ConfigMap *api_v1.ConfigMap
+}
+
+// Resources are a VirtualServer and the resources it references.
+type Resources struct {
+	VirtualServer *conf_v1.VirtualServer
+	// VirtualServerRoutes are the VirtualServerRoutes referenced by the routes of the VirtualServer.
+	// The VirtualServerRoutes that the VirtualServer doesn't reference are ignored.
+	VirtualServerRoutes []*conf_v1.VirtualServerRoute
+	// Policies are the Policies referenced by the VirtualServer and the VirtualServerRoutes.
+	Policies []*conf_v1.Policy
+	// Endpoints are the addresses (ip:port) of the pods of the services, keyed by EndpointsKey.
+	Endpoints map[string][]string
+}
+
+// Warning is a warning about a resource, which means that NGINX handles the requests differently
+// than the resource specifies.
+type Warning struct {
+	// Resource is the kind, the namespace and the name of the resource, such as VirtualServer default/cafe.
+	Resource string
+	Code     string
+	Severity string
+	Message  string
+}
+
+// Result is the config generated for a VirtualServer.
+type Result struct {
+	// Config is the content of the config file of the VirtualServer.
+	Config []byte
+	// Warnings are sorted by the resources.
+	Warnings []Warning
+}
+
+// Generator generates the configs of VirtualServers. It is safe for concurrent use.
+type Generator struct {
+	isPlus           bool
+	cfgParams        *configs.ConfigParams
+	templateExecutor *version2.TemplateExecutor
+}
+
+// NewGenerator creates a Generator. It returns an error if the template can't be parsed.
+func NewGenerator(opts Options) (*Generator, error) {
+	if opts.TemplatePath == "" {
+		return nil, errors.New("the path to the VirtualServer template is required")
+	}
+
+	templateExecutor, err := version2.NewTemplateExecutor(opts.TemplatePath)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing the VirtualServer template: %v", err)
+	}
+
+	cfgParams := configs.NewDefaultConfigParams()
+	if opts.ConfigMap != nil {
+		cfgParams = configs.ParseConfigMap(opts.ConfigMap, opts.IsPlus)
+	}
+
+	return &Generator{
+		isPlus:           opts.IsPlus,
+		cfgParams:        cfgParams,
+		templateExecutor: templateExecutor,
+	}, nil
+}
+
+// Generate generates the config for the VirtualServer of the resources. It returns an error if a resource is invalid,
+// because the Ingress Controller would reject it.
+func (g *Generator) Generate(res Resources) (*Result, error) {
+	vs := res.VirtualServer
+	if vs == nil {
+		return nil, errors.New("the VirtualServer is required")
+	}
+
+	if err := validation.ValidateVirtualServer(vs, g.isPlus); err != nil {
+		return nil, fmt.Errorf("VirtualServer %s/%s is invalid: %v", vs.Namespace, vs.Name, err)
+	}
+
+	// OIDC policies are only supported in NGINX Plus, so they are considered enabled for NGINX Plus
+	enableOIDC := g.isPlus
+
+	policies := make(map[string]*conf_v1.Policy)
+	for _, pol := range res.Policies {
+		if err := validation.ValidatePolicy(pol, g.isPlus, enableOIDC); err != nil {
+			return nil, fmt.Errorf("Policy %s/%s is invalid: %v", pol.Namespace, pol.Name, err)
+		}
+		policies[fmt.Sprintf("%s/%s", pol.Namespace, pol.Name)] = pol
+	}
+
+	vsrs := make(map[string]*conf_v1.VirtualServerRoute)
+	for _, vsr := range res.VirtualServerRoutes {
+		vsrs[fmt.Sprintf("%s/%s", vsr.Namespace, vsr.Name)] = vsr
+	}
+
+	virtualServerEx := &configs.VirtualServerEx{
+		VirtualServer: vs,
+		Endpoints:     res.Endpoints,
+		Policies:      policies,
+	}
+
+	for _, r := range vs.Spec.Routes {
+		if r.Route == "" {
+			continue
+		}
+
+		vsrKey := r.Route
+		if !strings.Contains(vsrKey, "/") {
+			vsrKey = fmt.Sprintf("%s/%s", vs.Namespace, vsrKey)
+		}
+
+		vsr, exists := vsrs[vsrKey]
+		if !exists {
+			return nil, fmt.Errorf("VirtualServerRoute %s referenced by VirtualServer %s/%s is missing", vsrKey, vs.Namespace, vs.Name)
+		}
+
+		if err := validation.ValidateVirtualServerRouteForVirtualServer(vsr, vs.Spec.Host, r.Path, g.isPlus); err != nil {
+			return nil, fmt.Errorf("VirtualServerRoute %s is invalid: %v", vsrKey, err)
+		}
+
+		virtualServerEx.VirtualServerRoutes = append(virtualServerEx.VirtualServerRoutes, vsr)
+	}
+
+	var tlsPemFileName string
+	if vs.Spec.TLS != nil && vs.Spec.TLS.Secret != "" {
+		tlsPemFileName = fmt.Sprintf("%s/%s-%s", secretsDir, vs.Namespace, vs.Spec.TLS.Secret)
+	}
+
+	vsCfg, warnings := configs.GenerateVirtualServerConfigForValidSecrets(virtualServerEx, g.cfgParams, g.isPlus, tlsPemFileName)
+
+	content, err := g.templateExecutor.ExecuteVirtualServerTemplate(&vsCfg)
+	if err != nil {
+		return nil, fmt.Errorf("error executing the VirtualServer template: %v", err)
+	}
+
+	return &Result{
+		Config:   content,
+		Warnings: convertWarnings(warnings),
+	}, nil
+}
+
+// EndpointsKey returns the key of the endpoints of the port of the service in Resources.
+func EndpointsKey(namespace string, service string, port uint16) string {
+	return configs.GenerateEndpointsKey(namespace, service, nil, port, "")
+}
+
+func convertWarnings(warnings configs.Warnings) []Warning {
+	var result []Warning
+
+	for obj, objWarnings := range warnings {
+		resource := describeResource(obj)
+		for _, w := range objWarnings {
+			result = append(result, Warning{
+				Resource: resource,
+				Code:     w.Code,
+				Severity: string(w.Severity),
+				Message:  w.Message,
+			})
+		}
+	}
+
+	// the warnings of a resource keep their order
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Resource < result[j].Resource
+	})
+
+	return result
+}
+
+func describeResource(obj runtime.Object) string {
+	switch o := obj.(type) {
+	case *conf_v1.VirtualServer:
+		return fmt.Sprintf("VirtualServer %s/%s", o.Namespace, o.Name)
+	case *conf_v1.VirtualServerRoute:
+		return fmt.Sprintf("VirtualServerRoute %s/%s", o.Namespace, o.Name)
+	case *conf_v1.Policy:
+		return fmt.Sprintf("Policy %s/%s", o.Namespace, o.Name)
+	}
+
+	return fmt.Sprintf("%T", obj)
+}
@@ -0,0 +1,189 @@
+package configgen
+
+import (
+	"strings"
+	"testing"
+
+	conf_v1 "github.com/nginxinc/kubernetes-ingress/pkg/apis/configuration/v1"
+	api_v1 "k8s.io/api/core/v1"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const nginxTemplatePath = "../../internal/configs/version2/nginx.virtualserver.tmpl"
+
+func createTestResources() Resources {
+	return Resources{
+		VirtualServer: &conf_v1.VirtualServer{
+			ObjectMeta: meta_v1.ObjectMeta{
+				Name:      "cafe",
+				Namespace: "default",
+			},
+			Spec: conf_v1.VirtualServerSpec{
+				Host: "cafe.example.com",
+				Upstreams: []conf_v1.Upstream{
+					{
+						Name:    "tea",
+						Service: "tea-svc",
+						Port:    80,
+					},
+				},
+				Routes: []conf_v1.Route{
+					{
+						Path: "/tea",
+						Action: &conf_v1.Action{
+							Pass: "tea",
+						},
+					},
+					{
+						Path:  "/coffee",
+						Route: "coffee",
+					},
+				},
+			},
+		},
+		VirtualServerRoutes: []*conf_v1.VirtualServerRoute{
+			{
+				ObjectMeta: meta_v1.ObjectMeta{
+					Name:      "coffee",
+					Namespace: "default",
+				},
+				Spec: conf_v1.VirtualServerRouteSpec{
+					Host: "cafe.example.com",
+					Upstreams: []conf_v1.Upstream{
+						{
+							Name:    "coffee",
+							Service: "coffee-svc",
+							Port:    80,
+						},
+					},
+					Subroutes: []conf_v1.Route{
+						{
+							Path: "/coffee",
+							Action: &conf_v1.Action{
+								Pass: "coffee",
+							},
+						},
+					},
+				},
+			},
+		},
+		Endpoints: map[string][]string{
+			EndpointsKey("default", "tea-svc", 80):    {"10.0.0.1:80"},
+			EndpointsKey("default", "coffee-svc", 80): {"10.0.0.2:80"},
+		},
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	generator, err := NewGenerator(Options{
+		TemplatePath: nginxTemplatePath,
+		ConfigMap: &api_v1.ConfigMap{
+			Data: map[string]string{
+				"proxy-connect-timeout": "30s",
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewGenerator() returned error %v", err)
+	}
+
+	result, err := generator.Generate(createTestResources())
+	if err != nil {
+		t.Fatalf("Generate() returned error %v", err)
+	}
+
+	config := string(result.Config)
+	expectedContents := []string{
+		"upstream vs_default_cafe_tea {",
+		"server 10.0.0.1:80",
+		"upstream vs_default_cafe_vsr_default_coffee_coffee {",
+		"server 10.0.0.2:80",
+		"server_name cafe.example.com;",
+		"proxy_connect_timeout 30s;",
+	}
+	for _, expected := range expectedContents {
+		if !strings.Contains(config, expected) {
+			t.Errorf("Generate() returned a config without %q:\n%s", expected, config)
+		}
+	}
+	if len(result.Warnings) != 0 {
+		t.Errorf("Generate() returned unexpected warnings %v", result.Warnings)
+	}
+}
+
+func TestGenerateWithWarnings(t *testing.T) {
+	generator, err := NewGenerator(Options{
+		IsPlus:       true,
+		TemplatePath: "../../internal/configs/version2/nginx-plus.virtualserver.tmpl",
+	})
+	if err != nil {
+		t.Fatalf("NewGenerator() returned error %v", err)
+	}
+
+	res := createTestResources()
+	res.VirtualServer.Spec.Upstreams[0].SlowStart = "10s"
+	res.VirtualServer.Spec.Upstreams[0].LBMethod = "random"
+
+	result, err := generator.Generate(res)
+	if err != nil {
+		t.Fatalf("Generate() returned error %v", err)
+	}
+
+	if len(result.Warnings) != 1 || result.Warnings[0].Resource != "VirtualServer default/cafe" {
+		t.Errorf("Generate() returned warnings %v but expected a warning for the slow-start of VirtualServer default/cafe", result.Warnings)
+	}
+}
+
+func TestGenerateFails(t *testing.T) {
+	generator, err := NewGenerator(Options{TemplatePath: nginxTemplatePath})
+	if err != nil {
+		t.Fatalf("NewGenerator() returned error %v", err)
+	}
+
+	invalidHost := createTestResources()
+	invalidHost.VirtualServer.Spec.Host = ""
+
+	missingRoute := createTestResources()
+	missingRoute.VirtualServerRoutes = nil
+
+	invalidRoute := createTestResources()
+	invalidRoute.VirtualServerRoutes[0].Spec.Host = "tea.example.com"
+
+	tests := []struct {
+		res Resources
+		msg string
+	}{
+		{
+			res: Resources{},
+			msg: "no VirtualServer",
+		},
+		{
+			res: invalidHost,
+			msg: "invalid VirtualServer",
+		},
+		{
+			res: missingRoute,
+			msg: "missing VirtualServerRoute",
+		},
+		{
+			res: invalidRoute,
+			msg: "VirtualServerRoute with a different host",
+		},
+	}
+
+	for _, test := range tests {
+		_, err := generator.Generate(test.res)
+		if err == nil {
+			t.Errorf("Generate() returned no error for the case of %s", test.msg)
+		}
+	}
+}
+
+func TestNewGeneratorFails(t *testing.T) {
+	for _, path := range []string{"", "does-not-exist.tmpl"} {
+		_, err := NewGenerator(Options{TemplatePath: path})
+		if err == nil {
+			t.Errorf("NewGenerator() returned no error for the template path %q", path)
+		}
+	}
+}
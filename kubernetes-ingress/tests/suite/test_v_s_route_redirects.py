@@ -72,7 +72,7 @@ class TestVSRRedirects:
         text = f"{v_s_route_setup.namespace}/{v_s_route_setup.route_m.name}"
         event_text = f"VirtualServerRoute {text} is invalid and was rejected: "
         invalid_fields = [
-            "spec.subroutes[0].action.redirect.code", "spec.subroutes[1].action.redirect.url"
+            "spec.subroutes[0].action.redirect.url", "spec.subroutes[1].action.redirect.url"
         ]
         vsr_src = f"{TEST_DATA}/virtual-server-route-redirects/route-multiple-invalid.yaml"
         patch_v_s_route_from_yaml(kube_apis.custom_objects,
@@ -1,5 +1,6 @@
 import requests
 import pytest
+from kubernetes.client.rest import ApiException
 
 from settings import TEST_DATA
 from suite.custom_assertions import assert_event_and_get_count, assert_event_count_increased, assert_response_codes, \
@@ -90,10 +91,11 @@ class TestVSRouteUpstreamTls:
         ic_pod_name = get_first_pod_name(kube_apis.v1, ingress_controller_prerequisites.namespace)
         initial_events_ns_m = get_events(kube_apis.v1, v_s_route_setup.route_m.namespace)
         initial_events_ns_s = get_events(kube_apis.v1, v_s_route_setup.route_s.namespace)
-        patch_v_s_route_from_yaml(kube_apis.custom_objects,
-                                  v_s_route_setup.route_s.name,
-                                  f"{TEST_DATA}/virtual-server-route-upstream-tls/route-single-invalid.yaml",
-                                  v_s_route_setup.route_s.namespace)
+        with pytest.raises(ApiException):
+            patch_v_s_route_from_yaml(kube_apis.custom_objects,
+                                      v_s_route_setup.route_s.name,
+                                      f"{TEST_DATA}/virtual-server-route-upstream-tls/route-single-invalid.yaml",
+                                      v_s_route_setup.route_s.namespace)
         wait_before_test(1)
         config = get_vs_nginx_template_conf(kube_apis.v1,
                                             v_s_route_setup.namespace,
@@ -214,7 +214,7 @@ class TestVirtualServerUpstreamOptionValidation:
     def test_event_message_and_config(self, kube_apis, ingress_controller_prerequisites,
                                       crd_ingress_controller, virtual_server_setup):
         invalid_fields = [
-            "upstreams[0].lb-method", "upstreams[0].fail-timeout",
+            "upstreams[0].fail-timeout",
             "upstreams[0].max-fails", "upstreams[0].connect-timeout",
             "upstreams[0].read-timeout", "upstreams[0].send-timeout",
             "upstreams[0].keepalive", "upstreams[0].max-conns",
@@ -222,7 +222,7 @@ class TestVirtualServerUpstreamOptionValidation:
             "upstreams[0].next-upstream-timeout", "upstreams[0].next-upstream-tries",
             "upstreams[0].client-max-body-size",
             "upstreams[0].buffers.number", "upstreams[0].buffers.size", "upstreams[0].buffer-size",
-            "upstreams[1].lb-method", "upstreams[1].fail-timeout",
+            "upstreams[1].fail-timeout",
             "upstreams[1].max-fails", "upstreams[1].connect-timeout",
             "upstreams[1].read-timeout", "upstreams[1].send-timeout",
             "upstreams[1].keepalive", "upstreams[1].max-conns",